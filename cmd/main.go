@@ -19,13 +19,20 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -35,11 +42,22 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -48,9 +66,13 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/konflux-ci/tekton-queue/internal/audit"
 	"github.com/konflux-ci/tekton-queue/internal/cel"
 	kueueconfig "github.com/konflux-ci/tekton-queue/internal/config"
 	"github.com/konflux-ci/tekton-queue/internal/controller"
+	"github.com/konflux-ci/tekton-queue/internal/crdcheck"
+	"github.com/konflux-ci/tekton-queue/internal/features"
+	"github.com/konflux-ci/tekton-queue/internal/quotacheck"
 	webhookv1 "github.com/konflux-ci/tekton-queue/internal/webhook/v1"
 
 	// +kubebuilder:scaffold:imports
@@ -111,6 +133,7 @@ type ControllerFlags struct {
 	LeaseDuration        time.Duration
 	RenewDeadline        time.Duration
 	RetryPeriod          time.Duration
+	WaitForKueueCRDs     time.Duration
 }
 
 func (c *ControllerFlags) AddFlags(fs *flag.FlagSet) {
@@ -133,6 +156,9 @@ func (c *ControllerFlags) AddFlags(fs *flag.FlagSet) {
 	)
 	fs.DurationVar(&c.RetryPeriod, "leader-elect-retry-period", 2*time.Second,
 		"The duration the clients should wait between attempting acquisition and renewal of a leadership.")
+	fs.DurationVar(&c.WaitForKueueCRDs, "wait-for-kueue-crds", 5*time.Minute,
+		"How long to wait for the Workload and ResourceFlavor CRDs of Kueue to be installed before giving up. "+
+			"The readiness check fails while waiting.")
 }
 
 type WebhookFlags struct {
@@ -140,6 +166,7 @@ type WebhookFlags struct {
 	WebhookCertPath string
 	WebhookCertName string
 	WebhookCertKey  string
+	WaitForCRDs     time.Duration
 }
 
 func (w *WebhookFlags) AddFlags(fs *flag.FlagSet) {
@@ -147,27 +174,159 @@ func (w *WebhookFlags) AddFlags(fs *flag.FlagSet) {
 	fs.StringVar(&w.WebhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
 	fs.StringVar(&w.WebhookCertName, "webhook-cert-name", "tls.crt", "The name of the webhook certificate file.")
 	fs.StringVar(&w.WebhookCertKey, "webhook-cert-key", "tls.key", "The name of the webhook key file.")
+	fs.DurationVar(&w.WaitForCRDs, "wait-for-pipelinerun-crd", 5*time.Minute,
+		"How long to wait for the PipelineRun CRD of Tekton to be installed before giving up. "+
+			"The readiness check fails while waiting.")
 }
 
 type MutateFlags struct {
 	PipelineRunFile string
+	FromCluster     string
+	Strip           string
 	ConfigDir       string
+	Diff            bool
+	Now             string
 	ZapOptions      *zap.Options
 }
 
+// defaultStripFields are the fields of the PipelineRuns read from the
+// cluster removed before their mutation: the webhook doesn't see them when
+// the PipelineRuns are created.
+var defaultStripFields = []string{
+	"status",
+	"metadata.uid",
+	"metadata.resourceVersion",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.managedFields",
+}
+
 func (m *MutateFlags) AddFlags(fs *flag.FlagSet) {
 	fs.StringVar(&m.PipelineRunFile, "pipelinerun-file", "",
-		"Path to the file containing the PipelineRun definition (required)")
+		"Path to the file containing the PipelineRun definition (required, unless --from-cluster is set)")
+	fs.StringVar(&m.FromCluster, "from-cluster", "",
+		"The namespace/name of a PipelineRun to read from the cluster instead of --pipelinerun-file. "+
+			"Nothing is written to the cluster.")
+	fs.StringVar(&m.Strip, "strip", strings.Join(defaultStripFields, ","),
+		"The comma-separated paths of the fields removed from the PipelineRun read from the cluster, "+
+			"before its mutation.")
 	fs.StringVar(&m.ConfigDir, "config-dir", "",
 		"The directory that contains the configuration file for the tekton-kueue (required)")
+	fs.BoolVar(&m.Diff, "diff", false,
+		"Print the changes of the labels and annotations instead of the mutated PipelineRun.")
+	fs.StringVar(&m.Now, "now", "",
+		"The RFC 3339 time, e.g. 2025-06-07T23:30:00Z, of the evaluation of the time variables of the "+
+			"expressions, so the runs are reproducible. Defaults to the current time.")
 	m.ZapOptions = &zap.Options{
 		Development: true,
 	}
 	m.ZapOptions.BindFlags(fs)
+	config.RegisterFlags(fs)
+}
+
+type PrintRBACFlags struct {
+	ConfigDir  string
+	NamePrefix string
+	Namespace  string
+	Diff       bool
+	ZapOptions *zap.Options
+}
+
+func (p *PrintRBACFlags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&p.ConfigDir, "config-dir", "",
+		"The directory that contains the configuration file for the tekton-kueue (required)")
+	fs.StringVar(&p.NamePrefix, "name-prefix", "tekton-kueue-",
+		"The prefix of the names of the tekton-kueue objects, as set by kustomize.")
+	fs.StringVar(&p.Namespace, "namespace", "tekton-kueue", "The namespace tekton-kueue is deployed in.")
+	fs.BoolVar(&p.Diff, "diff", false,
+		"Compare the required rules with the ClusterRoles bound to the ServiceAccounts in the cluster, "+
+			"instead of printing the rules.")
+	p.ZapOptions = &zap.Options{
+		Development: true,
+	}
+	p.ZapOptions.BindFlags(fs)
+}
+
+type ReplayAuditFlags struct {
+	AuditFile  string
+	ConfigDir  string
+	SampleRate float64
+	SampleSeed string
+	MaxRecords int
+	ZapOptions *zap.Options
+}
+
+func (r *ReplayAuditFlags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&r.AuditFile, "audit-file", "",
+		"Path to the file containing the JSON mutation logs of the webhook (required)")
+	fs.StringVar(&r.ConfigDir, "config-dir", "",
+		"The directory that contains the candidate configuration file for the tekton-kueue (required)")
+	fs.Float64Var(&r.SampleRate, "sample-rate", 1, "The fraction of the records to replay.")
+	fs.StringVar(&r.SampleSeed, "sample-seed", "", "Changes the records selected by --sample-rate.")
+	fs.IntVar(&r.MaxRecords, "max-records", 0, "The maximum number of records to replay, 0 for no limit.")
+	r.ZapOptions = &zap.Options{
+		Development: true,
+	}
+	r.ZapOptions.BindFlags(fs)
+}
+
+type ValidateFlags struct {
+	ConfigDir         string
+	KueueManifestsDir string
+	ZapOptions        *zap.Options
+}
+
+func (v *ValidateFlags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&v.ConfigDir, "config-dir", "",
+		"The directory that contains the configuration file for the tekton-kueue (required)")
+	fs.StringVar(&v.KueueManifestsDir, "kueue-manifests-dir", "",
+		"The directory that contains the ClusterQueue and ResourceFlavor manifests to check the requested "+
+			"resources against.")
+	v.ZapOptions = &zap.Options{
+		Development: true,
+	}
+	v.ZapOptions.BindFlags(fs)
+}
+
+type CELReferenceFlags struct {
+	Format     string
+	ZapOptions *zap.Options
+}
+
+func (c *CELReferenceFlags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Format, "format", "markdown",
+		"The format of the reference of the functions and variables of the CEL expressions, markdown or json.")
+	c.ZapOptions = &zap.Options{
+		Development: true,
+	}
+	c.ZapOptions.BindFlags(fs)
+}
+
+type ExplainFlags struct {
+	ConfigDir       string
+	Namespace       string
+	PipelineRunFile string
+	Format          string
+	ZapOptions      *zap.Options
+}
+
+func (e *ExplainFlags) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&e.ConfigDir, "config-dir", "",
+		"The directory that contains the configuration file for the tekton-kueue (required)")
+	fs.StringVar(&e.Namespace, "namespace", "",
+		"The namespace whose admissions are explained (required)")
+	fs.StringVar(&e.PipelineRunFile, "pipelinerun-file", "",
+		"Path to the file containing a sample PipelineRun, whose mutations are explained")
+	fs.StringVar(&e.Format, "format", "text", "The format of the explanation, text or json.")
+	e.ZapOptions = &zap.Options{
+		Development: true,
+	}
+	e.ZapOptions.BindFlags(fs)
 }
 
 func main() {
-	expectedSubcommands := "expected 'controller', 'webhook', or 'mutate' subcommand"
+	expectedSubcommands := "expected 'controller', 'webhook', 'mutate', 'print-rbac', 'replay-audit', 'validate', " +
+		"'cel-reference' or 'explain' subcommand"
 	if len(os.Args) < 2 {
 		fmt.Println(expectedSubcommands)
 		os.Exit(1)
@@ -180,6 +339,16 @@ func main() {
 		runWebhook(os.Args[2:])
 	case "mutate":
 		runMutate(os.Args[2:])
+	case "print-rbac":
+		runPrintRBAC(os.Args[2:])
+	case "replay-audit":
+		runReplayAudit(os.Args[2:])
+	case "validate":
+		runValidate(os.Args[2:])
+	case "cel-reference":
+		runCELReference(os.Args[2:])
+	case "explain":
+		runExplain(os.Args[2:])
 	default:
 		fmt.Printf("Got subcommand %s, %s", os.Args[1], expectedSubcommands)
 		os.Exit(1)
@@ -235,19 +404,85 @@ func runController(args []string) {
 		os.Exit(1)
 	}
 
+	cfg := &kueueconfig.Config{}
+	if controllerFlags.ConfigDir != "" {
+		cfg, err = loadConfig(controllerFlags.ConfigDir)
+		if err != nil {
+			setupLog.Error(err, "unable to load controller configuration")
+			os.Exit(1)
+		}
+	}
+
+	var setupOpts []controller.SetupOption
+	if migration := cfg.ResourceAnnotationPrefixMigration; migration != nil {
+		if err := migration.Validate(); err != nil {
+			setupLog.Error(err, "Invalid resource annotation prefix migration")
+			os.Exit(1)
+		}
+		setupLog.Info("Migrating the resource annotation prefix",
+			"from", migration.From, "to", migration.To, "mode", migration.Mode)
+		setupOpts = append(setupOpts, controller.WithResourceAnnotationPrefixes(migration.ReadPrefixes()))
+	}
+
+	managedNamespaces, err := cfg.ManagedNamespacesSelector()
+	if err != nil {
+		setupLog.Error(err, "Invalid managed namespaces")
+		os.Exit(1)
+	}
+	manageSelector, err := cfg.ManageSelector()
+	if err != nil {
+		setupLog.Error(err, "Invalid manage selector")
+		os.Exit(1)
+	}
+	setupOpts = append(setupOpts,
+		controller.WithManagedNamespaces(managedNamespaces),
+		controller.WithManagedLabelRequired(manageSelector != nil))
+
 	ctx := ctrl.SetupSignalHandler()
-	err = controller.SetupWithManager(mgr)
+	waitForCRDsOrDie(ctx, mgr, controllerFlags.ProbeAddr, controllerFlags.WaitForKueueCRDs, "kueue-crds",
+		kueue.GroupVersion.WithKind("Workload"), kueue.GroupVersion.WithKind("ResourceFlavor"))
+	err = controller.SetupWithManager(mgr, cfg.Controller, setupOpts...)
 	if err != nil {
 		setupLog.Error(err, "Failed to setup the controller")
 		os.Exit(1)
 	}
 
+	if err := controller.RegisterPipelineRunCollector(mgr.GetClient()); err != nil {
+		setupLog.Error(err, "Failed to register the PipelineRun metrics")
+		os.Exit(1)
+	}
+
+	if cfg.Controller.Drain {
+		setupLog.Info("Draining, the new PipelineRuns aren't admitted")
+	}
+	drain := controller.NewDrainReconciler(mgr.GetClient(), cfg.Controller.Drain)
+	if err := drain.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "Failed to setup the drain reconciler")
+		os.Exit(1)
+	}
+	if controllerFlags.ConfigDir != "" {
+		reloader := controller.NewDrainReloader(
+			path.Join(controllerFlags.ConfigDir, "config.yaml"), controller.DefaultDrainReloadInterval, drain)
+		if err := mgr.Add(reloader); err != nil {
+			setupLog.Error(err, "Failed to add the drain reloader")
+			os.Exit(1)
+		}
+	}
+
 	err = controller.SetupIndexer(ctx, mgr.GetFieldIndexer())
 	if err != nil {
 		setupLog.Error(err, "Failed to setup the indexer")
 		os.Exit(1)
 	}
 
+	if cfg.RequeueOnQueueChange {
+		if err := setupQueueChangeReconciler(ctx, mgr, cfg.QueueName, controllerFlags.ConfigDir); err != nil {
+			setupLog.Error(err, "Failed to setup the queue change reconciler")
+			os.Exit(1)
+		}
+		setupLog.Info("Moving the pending PipelineRuns when the default queue changes", "queueName", cfg.QueueName)
+	}
+
 	addMetricsCertWatcher(mgr, metricsCertWatcher)
 	addReadyAndHealthChecksToMgrOrDie(mgr)
 
@@ -270,36 +505,219 @@ func runWebhook(args []string) {
 	webhookOptions, webhookCertWatcher := getWebhookServerOptions(webhookFlags, tlsOpts)
 	webhookServer := webhook.NewServer(webhookOptions)
 
+	// A replica evaluating the expressions differently from the others,
+	// e.g. on another architecture, would mutate the PipelineRuns
+	// inconsistently
+	if err := cel.SelfCheck(); err != nil {
+		setupLog.Error(err, "the evaluation of the CEL expressions differs from the expected one")
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(webhookFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load webhook configuration")
+		os.Exit(1)
+	}
+	canaryCfg, err := loadCanaryConfig(webhookFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load the canary configuration")
+		os.Exit(1)
+	}
+	validations, err := compileValidationPrograms(cfg.CEL)
+	if err != nil {
+		setupLog.Error(err, "invalid CEL validation expressions")
+		os.Exit(1)
+	}
+
+	configHash, err := webhookv1.ConfigHash(cfg, canaryCfg)
+	if err != nil {
+		setupLog.Error(err, "unable to hash the configuration")
+		os.Exit(1)
+	}
+	webhookv1.RecordConfigHash(configHash)
+	setupLog.Info("Serving the configuration", "hash", configHash)
+
+	var defaulterOpts []webhookv1.DefaulterOption
+	if cfg.Webhook.AnnotateDecision {
+		defaulterOpts = append(defaulterOpts, webhookv1.WithDecisionAnnotation())
+	}
+	// The metrics server protects the debug endpoints like the metrics
+	metricsServerOptions.ExtraHandlers = map[string]http.Handler{
+		webhookv1.CELReferencePath: webhookv1.CELReferenceHandler{},
+	}
+	if history := cfg.Webhook.DecisionHistory; history.Enabled {
+		decisions := webhookv1.NewDecisionHistory(history.GetPerNamespace(), history.GetMaxDecisions())
+		defaulterOpts = append(defaulterOpts, webhookv1.WithDecisionHistory(decisions))
+		metricsServerOptions.ExtraHandlers[webhookv1.DecisionsPath] = decisions
+		setupLog.Info("Recording the admission decisions", "path", webhookv1.DecisionsPath,
+			"perNamespace", history.GetPerNamespace(), "maxDecisions", history.GetMaxDecisions())
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		HealthProbeBindAddress: webhookFlags.ProbeAddr,
 		WebhookServer:          webhookServer,
 		LeaderElection:         false,
+		Cache:                  webhookCacheOptions(cfg),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to create manager")
 		os.Exit(1)
 	}
-	cfg, err := loadConfig(webhookFlags.ConfigDir)
+	// The mutator is created with the manager, whose cache serves the
+	// labels of the namespaces
+	var mutatorOpts []cel.MutatorOption
+	if cfg.CEL.ExposeNamespaceLabels {
+		opt, err := namespaceLabelsOption(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to watch the namespaces")
+			os.Exit(1)
+		}
+		mutatorOpts = append(mutatorOpts, opt)
+		setupLog.Info("Exposing the labels of the namespaces to the CEL expressions")
+	}
+	mutator, canary, err := newWebhookMutator(cfg, canaryCfg, mutatorOpts...)
 	if err != nil {
-		setupLog.Error(err, "unable to load webhook configuration")
+		setupLog.Error(err, "unable to create the CEL mutator")
 		os.Exit(1)
 	}
-
-	programs, err := cel.CompileCELPrograms(cfg.CEL.Expressions)
+	if err := mgr.AddMetricsServerExtraHandler(webhookv1.ConfigPath,
+		webhookv1.NewConfigHandler(cfg, canaryCfg, canary)); err != nil {
+		setupLog.Error(err, "Unable to add the configuration handler")
+		os.Exit(1)
+	}
+	ctx := ctrl.SetupSignalHandler()
+	waitForCRDsOrDie(ctx, mgr, webhookFlags.ProbeAddr, webhookFlags.WaitForCRDs, "pipelinerun-crd",
+		tekv1.SchemeGroupVersion.WithKind("PipelineRun"))
+
+	if canary != nil {
+		reloader := webhookv1.NewCanaryPercentReloader(path.Join(webhookFlags.ConfigDir, "config.yaml"),
+			webhookv1.DefaultCanaryReloadInterval, canary)
+		if err := mgr.Add(reloader); err != nil {
+			setupLog.Error(err, "unable to add the canary percentage reloader")
+			os.Exit(1)
+		}
+		setupLog.Info("Not reloading the CEL expressions while a canary configuration is loaded",
+			"file", canaryConfigFile)
+		if cfg.Webhook.AnnotateConfigHash {
+			defaulterOpts = append(defaulterOpts, webhookv1.WithConfigHash(configHash))
+		}
+	} else {
+		// Only the CEL expressions are reloaded, the other settings still
+		// need a restart
+		reloadable := webhookv1.NewReloadableMutator(mutator, configHash)
+		mutator = reloadable
+		reloader, err := webhookv1.NewExpressionsReloader(path.Join(webhookFlags.ConfigDir, "config.yaml"),
+			webhookv1.DefaultExpressionsReloadInterval, reloadable, cfg,
+			func() (*kueueconfig.Config, error) { return loadConfig(webhookFlags.ConfigDir) },
+			func(cfg *kueueconfig.Config) (webhookv1.PipelineRunMutator, error) {
+				// The circuit breakers of the unchanged expressions keep
+				// their state
+				previous, _ := reloadable.Current().(*cel.CELMutator)
+				opts := append(slices.Clone(mutatorOpts), cel.WithCircuitBreakerState(previous))
+				mutator, err := newCELMutatorWithOptions(cfg, opts)
+				if err != nil {
+					return nil, err
+				}
+				return mutator, nil
+			})
+		if err != nil {
+			setupLog.Error(err, "unable to create the CEL expressions reloader")
+			os.Exit(1)
+		}
+		if err := mgr.Add(reloader); err != nil {
+			setupLog.Error(err, "unable to add the CEL expressions reloader")
+			os.Exit(1)
+		}
+		if cfg.Webhook.AnnotateConfigHash {
+			defaulterOpts = append(defaulterOpts, webhookv1.WithReloadableConfigHash(reloadable))
+		}
+	}
+	managedNamespaces, err := cfg.ManagedNamespacesSelector()
+	if err != nil {
+		setupLog.Error(err, "Invalid managed namespaces")
+		os.Exit(1)
+	}
+	if managedNamespaces != nil {
+		opt, err := managedNamespacesFilter(mgr, managedNamespaces)
+		if err != nil {
+			setupLog.Error(err, "unable to watch the namespaces")
+			os.Exit(1)
+		}
+		defaulterOpts = append(defaulterOpts, opt)
+		setupLog.Info("Only queuing the PipelineRuns of the selected namespaces", "selector", managedNamespaces.String())
+	}
+	manageSelector, err := cfg.ManageSelector()
 	if err != nil {
-		setupLog.Error(err, "unable to compile CEL programs")
+		setupLog.Error(err, "Invalid manage selector")
 		os.Exit(1)
 	}
-	mutator := cel.NewCELMutator(programs)
+	if manageSelector != nil {
+		selector := webhookv1.NewManageSelector(manageSelector)
+		reloader := webhookv1.NewManageSelectorReloader(path.Join(webhookFlags.ConfigDir, "config.yaml"),
+			webhookv1.DefaultManageSelectorReloadInterval, selector)
+		if err := mgr.Add(reloader); err != nil {
+			setupLog.Error(err, "unable to add the manage selector reloader")
+			os.Exit(1)
+		}
+		defaulterOpts = append(defaulterOpts, webhookv1.WithManageSelector(selector))
+		setupLog.Info("Only queuing the PipelineRuns matching the manage selector", "selector", manageSelector.String())
+	}
+	if rateLimit := cfg.Webhook.RateLimit; rateLimit.Enabled {
+		opt, err := namespaceRateLimit(mgr, &rateLimit)
+		if err != nil {
+			setupLog.Error(err, "unable to set up the rate limit")
+			os.Exit(1)
+		}
+		defaulterOpts = append(defaulterOpts, opt)
+		setupLog.Info("Rate limiting the PipelineRuns of each namespace",
+			"qps", rateLimit.GetQPS(), "burst", rateLimit.GetBurst())
+	}
+	if cfg.Webhook.ValidateQueueName {
+		opt, err := queueNameValidation(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to watch the LocalQueues")
+			os.Exit(1)
+		}
+		defaulterOpts = append(defaulterOpts, opt)
+		setupLog.Info("Validating the queue names of the PipelineRuns")
+	}
+
+	if cfg.Webhook.TenantOverrides.Enabled {
+		opt, err := tenantOverrides(mgr, cfg)
+		if err != nil {
+			setupLog.Error(err, "unable to set up the tenant overrides")
+			os.Exit(1)
+		}
+		defaulterOpts = append(defaulterOpts, opt)
+		setupLog.Info("Applying the expressions of the tenant overrides",
+			"configMap", kueueconfig.TenantOverridesConfigMap,
+			"allowedTypes", cfg.Webhook.TenantOverrides.GetAllowedTypes(),
+			"allowedKeyPrefixes", cfg.Webhook.TenantOverrides.AllowedKeyPrefixes)
+	}
+
+	if cfg.Webhook.CheckMutationInvariant {
+		checker := webhookv1.NewMutationInvariantChecker(cel.ValidTypes()...)
+		defaulterOpts = append(defaulterOpts, webhookv1.WithMutationInvariantCheck(checker))
+		setupLog.Info("Checking the fields changed by the admissions")
+	}
 
-	customDefaulter, err := webhookv1.NewCustomDefaulter(cfg, []webhookv1.PipelineRunMutator{mutator})
+	customDefaulter, err := webhookv1.NewCustomDefaulter(cfg, []webhookv1.PipelineRunMutator{mutator}, defaulterOpts...)
 
 	if err != nil {
 		setupLog.Error(err, "Unable to create custom defaulter for webhook")
 		os.Exit(1)
 	}
+	explainHandler, err := webhookv1.NewExplainHandler(customDefaulter)
+	if err != nil {
+		setupLog.Error(err, "Unable to create the explain handler")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsServerExtraHandler(webhookv1.ExplainPath, explainHandler); err != nil {
+		setupLog.Error(err, "Unable to add the explain handler")
+		os.Exit(1)
+	}
 	err = webhookv1.SetupPipelineRunWebhookWithManager(
 		mgr,
 		customDefaulter,
@@ -308,6 +726,14 @@ func runWebhook(args []string) {
 		setupLog.Error(err, "Failed to setup the webhook")
 		os.Exit(1)
 	}
+	if err := setupPipelineRunValidator(mgr, cfg, validations); err != nil {
+		setupLog.Error(err, "Failed to setup the validating webhook")
+		os.Exit(1)
+	}
+	if err := setupNamespaceSelector(mgr, cfg.Webhook); err != nil {
+		setupLog.Error(err, "Failed to setup the webhook namespace selector")
+		os.Exit(1)
+	}
 	addRunnableOrDie(
 		mgr,
 		webhookCertWatcher,
@@ -318,13 +744,153 @@ func runWebhook(args []string) {
 	addReadyAndHealthChecksToMgrOrDie(mgr)
 
 	setupLog.Info("starting manager")
-	ctx := ctrl.SetupSignalHandler()
 	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
 
+// setupQueueChangeReconciler registers the reconciler moving the pending
+// PipelineRuns to the default queue, and the reloader of the default queue
+// from the configuration in dir.
+func setupQueueChangeReconciler(ctx context.Context, mgr ctrl.Manager, queueName, dir string) error {
+	if err := controller.SetupQueueNameIndex(ctx, mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	reconciler := controller.NewQueueChangeReconciler(mgr.GetClient(), queueName)
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		return err
+	}
+	return mgr.Add(controller.NewQueueChangeReloader(
+		path.Join(dir, "config.yaml"), controller.DefaultQueueChangeReloadInterval, reconciler))
+}
+
+// queueNameValidation returns the option validating the queue names
+// against the LocalQueues cached by the manager. The admissions aren't
+// validated until the informer of the LocalQueues is synced.
+func queueNameValidation(mgr ctrl.Manager) (webhookv1.DefaulterOption, error) {
+	// The informer is started with the manager
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &kueue.LocalQueue{})
+	if err != nil {
+		return nil, err
+	}
+	return webhookv1.WithQueueNameValidation(mgr.GetCache(), informer.HasSynced), nil
+}
+
+// managedNamespacesFilter returns the option only queuing the PipelineRuns
+// of the namespaces matching the selector, cached by the manager. All the
+// namespaces are managed until the informer of the namespaces is synced.
+func managedNamespacesFilter(mgr ctrl.Manager, selector labels.Selector) (webhookv1.DefaulterOption, error) {
+	// The informer is started with the manager
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Namespace{})
+	if err != nil {
+		return nil, err
+	}
+	return webhookv1.WithManagedNamespaces(mgr.GetCache(), selector, informer.HasSynced), nil
+}
+
+// namespaceLabelsOption returns the option exposing the labels of the
+// namespaces, cached by the manager, to the CEL expressions. They're empty
+// until the informer of the namespaces is synced.
+func namespaceLabelsOption(mgr ctrl.Manager) (cel.MutatorOption, error) {
+	// The informer is started with the manager
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Namespace{})
+	if err != nil {
+		return nil, err
+	}
+	return cel.WithNamespaceLabels(mgr.GetCache(), informer.HasSynced), nil
+}
+
+// namespaceRateLimit returns the option rate limiting the PipelineRuns of
+// each namespace. The exempted namespaces, if any, are cached by the
+// manager, and no namespace is exempted until the informer of the
+// namespaces is synced.
+func namespaceRateLimit(mgr ctrl.Manager, cfg *kueueconfig.RateLimit) (webhookv1.DefaulterOption, error) {
+	limiter := webhookv1.NewRateLimiter(cfg.GetQPS(), cfg.GetBurst(), clock.RealClock{})
+	exempt, err := cfg.ExemptNamespacesSelector()
+	if err != nil || exempt == nil {
+		return webhookv1.WithRateLimit(limiter, nil, nil, nil), err
+	}
+	// The informer is started with the manager
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Namespace{})
+	if err != nil {
+		return nil, err
+	}
+	return webhookv1.WithRateLimit(limiter, mgr.GetCache(), exempt, informer.HasSynced), nil
+}
+
+// webhookCacheOptions returns the options of the cache of the webhook,
+// which only caches the ConfigMaps of the tenant overrides.
+func webhookCacheOptions(cfg *kueueconfig.Config) cache.Options {
+	if !cfg.Webhook.TenantOverrides.Enabled {
+		return cache.Options{}
+	}
+	return cache.Options{ByObject: map[client.Object]cache.ByObject{
+		&corev1.ConfigMap{}: {Field: fields.OneTermEqualSelector("metadata.name", kueueconfig.TenantOverridesConfigMap)},
+	}}
+}
+
+// tenantOverrides returns the option applying the expressions of the
+// ConfigMaps of the tenant overrides, cached by the manager, compiled with
+// the options of the configured expressions.
+func tenantOverrides(mgr ctrl.Manager, cfg *kueueconfig.Config) (webhookv1.DefaulterOption, error) {
+	compileOpts, err := celCompileOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// The informer is started with the manager
+	if _, err := mgr.GetCache().GetInformer(context.Background(), &corev1.ConfigMap{}); err != nil {
+		return nil, err
+	}
+	overrides, err := webhookv1.NewTenantOverrides(mgr.GetCache(), cfg.Webhook.TenantOverrides, compileOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return webhookv1.WithTenantOverrides(overrides), nil
+}
+
+// setupPipelineRunValidator registers the webhook validating the updates of
+// the PipelineRuns with the validation programs, and their deletions, which
+// are all allowed unless a deletion protection policy is configured. The
+// Workloads are then cached by the manager, indexed by owner.
+func setupPipelineRunValidator(mgr ctrl.Manager, cfg *kueueconfig.Config, validations []*cel.ValidationProgram) error {
+	if len(validations) > 0 {
+		setupLog.Info("Validating the updates of the PipelineRuns", "expressions", len(validations))
+	}
+	policy := cfg.Webhook.DeletionProtection
+	if policy != "" {
+		if err := controller.SetupIndexer(context.Background(), mgr.GetFieldIndexer()); err != nil {
+			return err
+		}
+		setupLog.Info("Protecting the pending PipelineRuns from deletion", "policy", policy)
+	}
+	protector, err := webhookv1.NewDeletionProtector(mgr.GetClient(), policy, clock.RealClock{})
+	if err != nil {
+		return err
+	}
+	return webhookv1.SetupPipelineRunValidatorWebhookWithManager(mgr, webhookv1.NewUpdateValidator(validations), protector)
+}
+
+// setupNamespaceSelector registers the reconciler keeping the namespace
+// selector of the webhook in sync, when one is configured.
+func setupNamespaceSelector(mgr ctrl.Manager, cfg kueueconfig.Webhook) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.NamespaceSelector == nil {
+		return nil
+	}
+	setupLog.Info("Syncing the namespace selector of the webhook",
+		"configurationName", cfg.GetConfigurationName(),
+		"selector", metav1.FormatLabelSelector(cfg.NamespaceSelector))
+	return webhookv1.NewNamespaceSelectorReconciler(
+		mgr.GetClient(),
+		mgr.GetAPIReader(),
+		cfg.GetConfigurationName(),
+		cfg.NamespaceSelector,
+	).SetupWithManager(mgr)
+}
+
 func runMutate(args []string) {
 	fs := flag.NewFlagSet("mutate", flag.ExitOnError)
 	var mutateFlags MutateFlags
@@ -334,8 +900,8 @@ func runMutate(args []string) {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(mutateFlags.ZapOptions)))
 
 	// Validate required flags
-	if mutateFlags.PipelineRunFile == "" {
-		fmt.Fprintf(os.Stderr, "Error: --pipelinerun-file is required\n")
+	if (mutateFlags.PipelineRunFile == "") == (mutateFlags.FromCluster == "") {
+		fmt.Fprintf(os.Stderr, "Error: either --pipelinerun-file or --from-cluster is required\n")
 		fs.Usage()
 		os.Exit(1)
 	}
@@ -345,17 +911,33 @@ func runMutate(args []string) {
 		os.Exit(1)
 	}
 
-	// Load PipelineRun from file
-	pipelineRunData, err := os.ReadFile(mutateFlags.PipelineRunFile)
-	if err != nil {
-		setupLog.Error(err, "Failed to read PipelineRun file", "file", mutateFlags.PipelineRunFile)
-		os.Exit(1)
-	}
-
 	var pipelineRun tekv1.PipelineRun
-	if err := yaml.Unmarshal(pipelineRunData, &pipelineRun); err != nil {
-		setupLog.Error(err, "Failed to parse PipelineRun YAML", "file", mutateFlags.PipelineRunFile)
-		os.Exit(1)
+	if mutateFlags.FromCluster != "" {
+		// The client is only used as a reader
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create the client")
+			os.Exit(1)
+		}
+		fetched, err := fetchPipelineRun(context.Background(), c, mutateFlags.FromCluster,
+			splitFieldPaths(mutateFlags.Strip))
+		if err != nil {
+			setupLog.Error(err, "Failed to read the PipelineRun from the cluster", "pipelineRun", mutateFlags.FromCluster)
+			os.Exit(1)
+		}
+		pipelineRun = *fetched
+	} else {
+		// Load PipelineRun from file
+		pipelineRunData, err := os.ReadFile(mutateFlags.PipelineRunFile)
+		if err != nil {
+			setupLog.Error(err, "Failed to read PipelineRun file", "file", mutateFlags.PipelineRunFile)
+			os.Exit(1)
+		}
+
+		if err := yaml.Unmarshal(pipelineRunData, &pipelineRun); err != nil {
+			setupLog.Error(err, "Failed to parse PipelineRun YAML", "file", mutateFlags.PipelineRunFile)
+			os.Exit(1)
+		}
 	}
 
 	// Load config
@@ -365,16 +947,33 @@ func runMutate(args []string) {
 		os.Exit(1)
 	}
 
+	var compileOpts []cel.CompileOption
+	if mutateFlags.Now != "" {
+		now, err := time.Parse(time.RFC3339, mutateFlags.Now)
+		if err != nil {
+			setupLog.Error(err, "Invalid --now time", "now", mutateFlags.Now)
+			os.Exit(1)
+		}
+		compileOpts = append(compileOpts, cel.WithClock(func() time.Time { return now }))
+	}
+
 	// Compile CEL programs and create mutator
-	programs, err := cel.CompileCELPrograms(cfg.CEL.Expressions)
+	mutator, err := newCELMutator(cfg, compileOpts...)
 	if err != nil {
-		setupLog.Error(err, "unable to compile CEL programs")
+		setupLog.Error(err, "unable to create the CEL mutator")
 		os.Exit(1)
 	}
-	mutator := cel.NewCELMutator(programs)
 
 	// Create custom defaulter
-	customDefaulter, err := webhookv1.NewCustomDefaulter(cfg, []webhookv1.PipelineRunMutator{mutator})
+	opts, err := manageSelectorOptions(cfg)
+	if err != nil {
+		setupLog.Error(err, "Invalid manage selector")
+		os.Exit(1)
+	}
+	if cfg.Webhook.AnnotateDecision {
+		opts = append(opts, webhookv1.WithDecisionAnnotation())
+	}
+	customDefaulter, err := webhookv1.NewCustomDefaulter(cfg, []webhookv1.PipelineRunMutator{mutator}, opts...)
 
 	if err != nil {
 		setupLog.Error(err, "Unable to create custom defaulter")
@@ -383,11 +982,17 @@ func runMutate(args []string) {
 
 	// Apply mutation
 	ctx := context.Background()
+	before := pipelineRun.DeepCopy()
 	if err := customDefaulter.Default(ctx, &pipelineRun); err != nil {
 		setupLog.Error(err, "Failed to apply mutation to PipelineRun")
 		os.Exit(1)
 	}
 
+	if mutateFlags.Diff {
+		fmt.Print(cel.DiffMutations(before, &pipelineRun))
+		return
+	}
+
 	// Output the mutated PipelineRun as YAML
 	mutatedData, err := outputyaml.Marshal(&pipelineRun)
 	if err != nil {
@@ -398,30 +1003,318 @@ func runMutate(args []string) {
 	fmt.Print(string(mutatedData))
 }
 
-func getTLSOpts(s *SharedFlags) []func(*tls.Config) {
-	var tlsOpts []func(*tls.Config)
-	// if the enable-http2 flag is false (the default), http/2 should be disabled
-	// due to its vulnerabilities. More specifically, disabling http/2 will
-	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
-	// Rapid Reset CVEs. For more information see:
-	// - https://github.com/advisories/GHSA-qppj-fm5r-hxr3
-	// - https://github.com/advisories/GHSA-4374-p667-p6c8
-	disableHTTP2 := func(c *tls.Config) {
-		setupLog.Info("disabling http/2")
-		c.NextProtos = []string{"http/1.1"}
+// fetchPipelineRun reads the PipelineRun namespace/name from the cluster,
+// and removes the fields of the dot-separated paths, e.g. metadata.uid.
+func fetchPipelineRun(ctx context.Context, reader client.Reader, ref string, strip []string) (*tekv1.PipelineRun, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid PipelineRun %q, expected namespace/name", ref)
+	}
+	pipelineRun := &tekv1.PipelineRun{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pipelineRun); err != nil {
+		return nil, err
 	}
 
-	if !s.EnableHTTP2 {
-		tlsOpts = append(tlsOpts, disableHTTP2)
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pipelineRun)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strip {
+		unstructured.RemoveNestedField(object, strings.Split(path, ".")...)
+	}
+	stripped := &tekv1.PipelineRun{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, stripped); err != nil {
+		return nil, err
 	}
+	return stripped, nil
+}
 
-	return tlsOpts
+// splitFieldPaths splits the comma-separated field paths, ignoring the
+// empty ones.
+func splitFieldPaths(paths string) []string {
+	var split []string
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			split = append(split, path)
+		}
+	}
+	return split
 }
 
-func getMetricsServerOptions(
-	s *SharedFlags,
-	tlsOpts []func(*tls.Config),
-) (metricsserver.Options, *certwatcher.CertWatcher) {
+func runPrintRBAC(args []string) {
+	fs := flag.NewFlagSet("print-rbac", flag.ExitOnError)
+	var printRBACFlags PrintRBACFlags
+	printRBACFlags.AddFlags(fs)
+
+	parseFlagsOrDie(fs, args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(printRBACFlags.ZapOptions)))
+
+	if printRBACFlags.ConfigDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: --config-dir is required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(printRBACFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+	opts := features.RenderOptions{
+		NamePrefix: printRBACFlags.NamePrefix,
+		Namespace:  printRBACFlags.Namespace,
+	}
+
+	if !printRBACFlags.Diff {
+		if err := features.Render(os.Stdout, cfg, opts); err != nil {
+			setupLog.Error(err, "Failed to print the RBAC rules")
+			os.Exit(1)
+		}
+		return
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create the client")
+		os.Exit(1)
+	}
+	missing, err := printRBACDiff(context.Background(), os.Stdout, c, cfg, opts)
+	if err != nil {
+		setupLog.Error(err, "Failed to compare the RBAC rules")
+		os.Exit(1)
+	}
+	if missing {
+		os.Exit(1)
+	}
+}
+
+// printRBACDiff writes, for each component, the permissions missing from
+// and exceeding the rules required by cfg. It returns whether permissions
+// are missing.
+func printRBACDiff(
+	ctx context.Context,
+	w io.Writer,
+	c client.Reader,
+	cfg *kueueconfig.Config,
+	opts features.RenderOptions,
+) (bool, error) {
+	anyMissing := false
+	for _, component := range features.Components {
+		sa := rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      opts.NamePrefix + component.ServiceAccount(),
+			Namespace: opts.Namespace,
+		}
+		live, err := boundClusterRoleRules(ctx, c, sa)
+		if err != nil {
+			return false, err
+		}
+		missing, excess := features.Diff(features.RequiredRules(cfg, component), live)
+		anyMissing = anyMissing || len(missing) > 0
+
+		fmt.Fprintf(w, "%s (ServiceAccount %s/%s):\n", component, sa.Namespace, sa.Name)
+		if len(missing) == 0 && len(excess) == 0 {
+			fmt.Fprintln(w, "  no differences")
+		}
+		for _, p := range missing {
+			fmt.Fprintf(w, "  missing: %s\n", p)
+		}
+		for _, p := range excess {
+			fmt.Fprintf(w, "  excess: %s\n", p)
+		}
+	}
+	return anyMissing, nil
+}
+
+// boundClusterRoleRules returns the rules of the ClusterRoles bound to the
+// subject through ClusterRoleBindings. Namespaced Roles, e.g. the one used
+// for leader election, aren't considered.
+func boundClusterRoleRules(ctx context.Context, c client.Reader, subject rbacv1.Subject) ([]rbacv1.PolicyRule, error) {
+	bindings := &rbacv1.ClusterRoleBindingList{}
+	if err := c.List(ctx, bindings); err != nil {
+		return nil, fmt.Errorf("listing ClusterRoleBindings: %w", err)
+	}
+	var rules []rbacv1.PolicyRule
+	for _, binding := range bindings.Items {
+		bound := false
+		for _, s := range binding.Subjects {
+			if s.Kind == subject.Kind && s.Name == subject.Name && s.Namespace == subject.Namespace {
+				bound = true
+				break
+			}
+		}
+		if !bound || binding.RoleRef.Kind != "ClusterRole" {
+			continue
+		}
+		role := &rbacv1.ClusterRole{}
+		if err := c.Get(ctx, client.ObjectKey{Name: binding.RoleRef.Name}, role); err != nil {
+			if client.IgnoreNotFound(err) == nil {
+				continue
+			}
+			return nil, fmt.Errorf("getting ClusterRole %s: %w", binding.RoleRef.Name, err)
+		}
+		rules = append(rules, role.Rules...)
+	}
+	return rules, nil
+}
+
+func runReplayAudit(args []string) {
+	fs := flag.NewFlagSet("replay-audit", flag.ExitOnError)
+	var replayFlags ReplayAuditFlags
+	replayFlags.AddFlags(fs)
+
+	parseFlagsOrDie(fs, args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(replayFlags.ZapOptions)))
+
+	if replayFlags.AuditFile == "" {
+		fmt.Fprintf(os.Stderr, "Error: --audit-file is required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if replayFlags.ConfigDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: --config-dir is required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(replayFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+	logConfig, err := mutationLogConfig(cfg.Logging)
+	if err != nil {
+		setupLog.Error(err, "invalid logging configuration")
+		os.Exit(1)
+	}
+	// The candidate mutations are compared with the logged ones, they
+	// aren't logged themselves
+	cfg.Logging.LogMutations = false
+	mutator, err := newCELMutator(cfg)
+	if err != nil {
+		setupLog.Error(err, "unable to create the CEL mutator")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(replayFlags.AuditFile)
+	if err != nil {
+		setupLog.Error(err, "Failed to open the audit file", "file", replayFlags.AuditFile)
+		os.Exit(1)
+	}
+	defer func() { _ = f.Close() }()
+
+	report, err := audit.Replay(f, mutator, logConfig, audit.Options{
+		SampleRate: replayFlags.SampleRate,
+		SampleSeed: replayFlags.SampleSeed,
+		MaxRecords: replayFlags.MaxRecords,
+	})
+	if err != nil {
+		setupLog.Error(err, "Failed to replay the audit records", "file", replayFlags.AuditFile)
+		os.Exit(1)
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		setupLog.Error(err, "Failed to write the report")
+		os.Exit(1)
+	}
+}
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var validateFlags ValidateFlags
+	validateFlags.AddFlags(fs)
+
+	parseFlagsOrDie(fs, args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(validateFlags.ZapOptions)))
+
+	if validateFlags.ConfigDir == "" {
+		fmt.Fprintf(os.Stderr, "Error: --config-dir is required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(validateFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load configuration")
+		os.Exit(1)
+	}
+	programs, err := compileConfiguredCELPrograms(cfg)
+	if err != nil {
+		setupLog.Error(err, "invalid CEL expressions")
+		os.Exit(1)
+	}
+	if _, err := compileValidationPrograms(cfg.CEL); err != nil {
+		setupLog.Error(err, "invalid CEL validation expressions")
+		os.Exit(1)
+	}
+	if _, err := celMutatorOptions(cfg); err != nil {
+		setupLog.Error(err, "invalid configuration")
+		os.Exit(1)
+	}
+	canaryCfg, err := loadCanaryConfig(validateFlags.ConfigDir)
+	if err != nil {
+		setupLog.Error(err, "unable to load the canary configuration")
+		os.Exit(1)
+	}
+	if canaryCfg != nil {
+		if _, _, err := newWebhookMutator(cfg, canaryCfg); err != nil {
+			setupLog.Error(err, "invalid canary configuration")
+			os.Exit(1)
+		}
+	}
+	if validateFlags.KueueManifestsDir == "" {
+		fmt.Println("The configuration is valid")
+		return
+	}
+
+	quotas, err := quotacheck.LoadQuotas(validateFlags.KueueManifestsDir)
+	if err != nil {
+		setupLog.Error(err, "Failed to load the Kueue manifests", "dir", validateFlags.KueueManifestsDir)
+		os.Exit(1)
+	}
+	prefixes := []string{cel.ResourceAnnotationPrefix}
+	if migration := cfg.ResourceAnnotationPrefixMigration; migration != nil {
+		prefixes = migration.ReadPrefixes()
+	}
+	var rules []cel.KeyNormalizationRule
+	if cfg.CEL.ResourceKeyNormalization {
+		// Already validated with the mutator options
+		rules, _ = cel.ParseKeyNormalizationRules(cfg.CEL.ResourceKeyNormalizationRules)
+	}
+	producible := quotacheck.ProducibleResources(programs, controller.ResourcePipelineRunCount, prefixes, rules)
+	report := quotacheck.Check(producible, quotas)
+	if err := report.Write(os.Stdout); err != nil {
+		setupLog.Error(err, "Failed to write the report")
+		os.Exit(1)
+	}
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+func getTLSOpts(s *SharedFlags) []func(*tls.Config) {
+	var tlsOpts []func(*tls.Config)
+	// if the enable-http2 flag is false (the default), http/2 should be disabled
+	// due to its vulnerabilities. More specifically, disabling http/2 will
+	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
+	// Rapid Reset CVEs. For more information see:
+	// - https://github.com/advisories/GHSA-qppj-fm5r-hxr3
+	// - https://github.com/advisories/GHSA-4374-p667-p6c8
+	disableHTTP2 := func(c *tls.Config) {
+		setupLog.Info("disabling http/2")
+		c.NextProtos = []string{"http/1.1"}
+	}
+
+	if !s.EnableHTTP2 {
+		tlsOpts = append(tlsOpts, disableHTTP2)
+	}
+
+	return tlsOpts
+}
+
+func getMetricsServerOptions(
+	s *SharedFlags,
+	tlsOpts []func(*tls.Config),
+) (metricsserver.Options, *certwatcher.CertWatcher) {
 	// Metrics endpoint is enabled in 'config/default/kustomization.yaml'. The Metrics options configure the server.
 	// More info:
 	// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.0/pkg/metrics/server
@@ -522,6 +1415,66 @@ func addReadyAndHealthChecksToMgrOrDie(mgr manager.Manager) {
 	}
 }
 
+// waitForCRDsOrDie waits up to timeout for the CRDs of the kinds to be
+// installed, and adds the readiness check of the name to the manager. The
+// manager isn't started yet, so the readiness check is served on the probe
+// address, failing, while waiting.
+func waitForCRDsOrDie(
+	ctx context.Context,
+	mgr manager.Manager,
+	probeAddr string,
+	timeout time.Duration,
+	name string,
+	kinds ...schema.GroupVersionKind,
+) {
+	client, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create the discovery client")
+		os.Exit(1)
+	}
+	waiter := crdcheck.NewWaiter(client, crdcheck.DefaultInterval, kinds...)
+	stop := serveProbes(probeAddr, name, waiter)
+	setupLog.Info("Waiting for the CRDs", "kinds", kinds, "timeout", timeout)
+	err = waiter.Wait(ctx, timeout)
+	stop()
+	if err != nil {
+		setupLog.Error(err, "the required CRDs aren't installed")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck(name, waiter.Check); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", name)
+		os.Exit(1)
+	}
+}
+
+// serveProbes serves the health check and the readiness check of the
+// waiter on the probe address, unless it's disabled, until the returned
+// function is called.
+func serveProbes(probeAddr, name string, waiter *crdcheck.Waiter) func() {
+	if probeAddr == "" || probeAddr == "0" {
+		return func() {}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", &healthz.Handler{Checks: map[string]healthz.Checker{"healthz": healthz.Ping}})
+	mux.Handle("/readyz", &healthz.Handler{Checks: map[string]healthz.Checker{name: waiter.Check}})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	listener, err := net.Listen("tcp", probeAddr)
+	if err != nil {
+		setupLog.Error(err, "unable to serve the probes while waiting for the CRDs", "address", probeAddr)
+		return func() {}
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			setupLog.Error(err, "failed to serve the probes while waiting for the CRDs")
+		}
+	}()
+	return func() {
+		if err := server.Close(); err != nil {
+			setupLog.Error(err, "failed to stop serving the probes")
+		}
+	}
+}
+
 func addRunnableOrDie(mgr ctrl.Manager, runnable manager.Runnable, infoMsg, errMsg string) {
 	if reflect.ValueOf(runnable).IsNil() {
 		return
@@ -533,6 +1486,136 @@ func addRunnableOrDie(mgr ctrl.Manager, runnable manager.Runnable, infoMsg, errM
 	}
 }
 
+func runCELReference(args []string) {
+	fs := flag.NewFlagSet("cel-reference", flag.ExitOnError)
+	var celReferenceFlags CELReferenceFlags
+	celReferenceFlags.AddFlags(fs)
+
+	parseFlagsOrDie(fs, args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(celReferenceFlags.ZapOptions)))
+
+	if err := writeCELReference(os.Stdout, celReferenceFlags.Format); err != nil {
+		setupLog.Error(err, "Failed to write the CEL reference")
+		os.Exit(1)
+	}
+}
+
+// writeCELReference writes the reference of the functions and variables of
+// the CEL expressions in the format, markdown or json.
+func writeCELReference(w io.Writer, format string) error {
+	reference, err := cel.GetReference()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "markdown":
+		return reference.WriteMarkdown(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reference)
+	default:
+		return fmt.Errorf("unsupported format %q, expected markdown or json", format)
+	}
+}
+
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	var explainFlags ExplainFlags
+	explainFlags.AddFlags(fs)
+
+	parseFlagsOrDie(fs, args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(explainFlags.ZapOptions)))
+
+	if explainFlags.ConfigDir == "" || explainFlags.Namespace == "" {
+		fmt.Fprintf(os.Stderr, "Error: --config-dir and --namespace are required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	var sample *tekv1.PipelineRun
+	if explainFlags.PipelineRunFile != "" {
+		data, err := os.ReadFile(explainFlags.PipelineRunFile)
+		if err != nil {
+			setupLog.Error(err, "Failed to read PipelineRun file", "file", explainFlags.PipelineRunFile)
+			os.Exit(1)
+		}
+		sample = &tekv1.PipelineRun{}
+		if err := yaml.Unmarshal(data, sample); err != nil {
+			setupLog.Error(err, "Failed to parse PipelineRun YAML", "file", explainFlags.PipelineRunFile)
+			os.Exit(1)
+		}
+	}
+
+	explanation, err := explainNamespace(explainFlags.ConfigDir, explainFlags.Namespace, sample)
+	if err != nil {
+		setupLog.Error(err, "Failed to explain the admissions")
+		os.Exit(1)
+	}
+	if err := writeExplanation(os.Stdout, explanation, explainFlags.Format); err != nil {
+		setupLog.Error(err, "Failed to write the explanation")
+		os.Exit(1)
+	}
+}
+
+// explainNamespace explains the admission of the PipelineRuns of the
+// namespace, and the mutations of the sample when not nil, with the
+// configuration of the directory. Without access to the cluster, the
+// managed namespaces and the queue names aren't checked.
+func explainNamespace(dir, namespace string, sample *tekv1.PipelineRun) (*webhookv1.Explanation, error) {
+	cfg, err := loadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	canaryCfg, err := loadCanaryConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	mutator, _, err := newWebhookMutator(cfg, canaryCfg)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := manageSelectorOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Webhook.AnnotateConfigHash {
+		hash, err := webhookv1.ConfigHash(cfg, canaryCfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, webhookv1.WithConfigHash(hash))
+	}
+	defaulter, err := webhookv1.NewCustomDefaulter(cfg, []webhookv1.PipelineRunMutator{mutator}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return defaulter.(webhookv1.Explainer).Explain(context.Background(), namespace, sample), nil
+}
+
+// manageSelectorOptions returns the option of the manage selector of the
+// configuration, none when it isn't set. The selector isn't reloaded.
+func manageSelectorOptions(cfg *kueueconfig.Config) ([]webhookv1.DefaulterOption, error) {
+	selector, err := cfg.ManageSelector()
+	if err != nil || selector == nil {
+		return nil, err
+	}
+	return []webhookv1.DefaulterOption{webhookv1.WithManageSelector(webhookv1.NewManageSelector(selector))}, nil
+}
+
+// writeExplanation writes the explanation in the format, text or json.
+func writeExplanation(w io.Writer, explanation *webhookv1.Explanation, format string) error {
+	switch format {
+	case "text":
+		return explanation.WriteText(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanation)
+	default:
+		return fmt.Errorf("unsupported format %q, expected text or json", format)
+	}
+}
+
 func addMetricsCertWatcher(mgr ctrl.Manager, runnable manager.Runnable) {
 	addRunnableOrDie(
 		mgr,
@@ -549,6 +1632,291 @@ func parseFlagsOrDie(fs *flag.FlagSet, args []string) {
 	}
 }
 
+// newCELMutator compiles the configured CEL expressions and creates a
+// mutator with the configured options, the programs being compiled with the
+// extra compile options too.
+func newCELMutator(cfg *kueueconfig.Config, compileOpts ...cel.CompileOption) (*cel.CELMutator, error) {
+	return newCELMutatorWithOptions(cfg, nil, compileOpts...)
+}
+
+// newCELMutatorWithOptions is like newCELMutator, the mutator being also
+// created with the extra mutator options, e.g. the ones depending on the
+// manager.
+func newCELMutatorWithOptions(
+	cfg *kueueconfig.Config,
+	mutatorOpts []cel.MutatorOption,
+	compileOpts ...cel.CompileOption,
+) (*cel.CELMutator, error) {
+	programs, err := compileConfiguredCELPrograms(cfg, compileOpts...)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := celMutatorOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cel.NewCELMutator(programs, append(opts, mutatorOpts...)...), nil
+}
+
+// newWebhookMutator returns the mutator of the webhook. When a canary
+// configuration is loaded, the mutator is a CanaryMutator serving the
+// configured percentage of the admissions with the CEL configuration of the
+// canary, which is also returned. Both mutators are created with the extra
+// mutator options.
+func newWebhookMutator(
+	cfg, canaryCfg *kueueconfig.Config,
+	mutatorOpts ...cel.MutatorOption,
+) (webhookv1.PipelineRunMutator, *webhookv1.CanaryMutator, error) {
+	stable, err := newCELMutatorWithOptions(cfg, mutatorOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if canaryCfg == nil {
+		return stable, nil, nil
+	}
+	if err := cfg.ValidateCanaryPercent(); err != nil {
+		return nil, nil, err
+	}
+	canaryMutator, err := newCELMutatorWithOptions(canaryCfg, mutatorOpts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("canary configuration: %w", err)
+	}
+	setupLog.Info("Serving a percentage of the admissions with the canary configuration",
+		"file", canaryConfigFile, "canaryPercent", cfg.CanaryPercent)
+	canary := webhookv1.NewCanaryMutator(stable, canaryMutator, cfg.CanaryPercent)
+	return canary, canary, nil
+}
+
+// compileConfiguredCELPrograms compiles the configured CEL expressions with
+// the configured options and the extra options, logging the warnings of
+// the expressions.
+func compileConfiguredCELPrograms(
+	cfg *kueueconfig.Config, extraOpts ...cel.CompileOption,
+) ([]*cel.CompiledProgram, error) {
+	compileOpts, err := celCompileOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	programs, err := compileCELPrograms(cfg.CEL, append(compileOpts, extraOpts...)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, program := range programs {
+		for _, warning := range program.GetTaintWarnings() {
+			setupLog.Info("WARNING: CEL expression lets PipelineRun authors choose the value of a policy-sensitive function",
+				"expression", program.GetExpression(), "function", warning.Function+"()", "path", warning.Path)
+		}
+		if refs := program.GetStatusReferences(); len(refs) > 0 {
+			setupLog.Info("WARNING: CEL expression references the PipelineRun status, which is empty when PipelineRuns are admitted",
+				"expression", program.GetExpression(), "references", refs)
+		}
+	}
+	return programs, nil
+}
+
+// celCompileOptions returns the options of the compilation of the CEL
+// expressions set by the configuration: the strictness, the validation of
+// the keys, the definitions and the time zone.
+func celCompileOptions(cfg *kueueconfig.Config) ([]cel.CompileOption, error) {
+	var compileOpts []cel.CompileOption
+	switch cfg.CEL.Strictness {
+	case "", kueueconfig.StrictnessWarn:
+	case kueueconfig.StrictnessEnforce:
+		compileOpts = append(compileOpts, cel.WithEnforcedChecks())
+	default:
+		return nil, fmt.Errorf("invalid CEL strictness %q", cfg.CEL.Strictness)
+	}
+	if cfg.CEL.ExcludeStatus {
+		compileOpts = append(compileOpts, cel.WithExcludeStatus())
+	}
+	if prefixes := cfg.CEL.Validation.AllowedKeyPrefixes; len(prefixes) > 0 {
+		compileOpts = append(compileOpts, cel.WithAllowedKeyPrefixes(prefixes))
+	}
+	protectedKeys := cfg.CEL.Validation.ProtectedKeys
+	compileOpts = append(compileOpts, cel.WithProtectedKeys(cel.ProtectedKeys{
+		Denied:  append(cel.DefaultProtectedKeyPatterns(), protectedKeys.Deny...),
+		Allowed: protectedKeys.Allow,
+	}))
+	if len(cfg.CEL.Definitions) > 0 {
+		compileOpts = append(compileOpts, cel.WithDefinitions(cfg.CEL.Definitions))
+	}
+	location, err := cfg.CEL.GetTimeZone()
+	if err != nil {
+		return nil, err
+	}
+	return append(compileOpts, cel.WithTimeZone(location)), nil
+}
+
+// celMutatorOptions returns the options of the mutator set by the
+// configuration.
+func celMutatorOptions(cfg *kueueconfig.Config) ([]cel.MutatorOption, error) {
+	var opts []cel.MutatorOption
+	if cfg.CEL.ResourceKeyNormalization {
+		rules, err := cel.ParseKeyNormalizationRules(cfg.CEL.ResourceKeyNormalizationRules)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cel.WithResourceKeyNormalization(rules))
+	}
+	if cfg.CEL.TimeoutOverride {
+		opts = append(opts, cel.WithTimeoutOverride())
+	}
+	if cfg.CEL.OverrideServiceAccount {
+		opts = append(opts, cel.WithServiceAccountOverride())
+	}
+	if policy := cfg.CEL.OverwritePolicy; policy != "" {
+		opts = append(opts, cel.WithOverwritePolicy(cel.OverwritePolicy(policy)))
+	}
+	if breaker := cfg.CEL.CircuitBreaker; breaker.Enabled {
+		opts = append(opts, cel.WithCircuitBreaker(ctrl.Log.WithName("circuit-breaker"), cel.CircuitBreakerConfig{
+			FailureThreshold: breaker.GetFailureThreshold(),
+			Window:           breaker.GetWindow(),
+		}))
+	}
+	opts = append(opts, cel.WithMaxObjectBytes(ctrl.Log.WithName("size-guard"), cfg.CEL.GetMaxObjectBytes()))
+	opts = append(opts, cel.WithMutationLimits(cfg.CEL.GetMaxMutationsPerObject(), cfg.CEL.GetMaxAnnotationBytesPerObject()))
+	if cache := cfg.CEL.EvaluationCache; cache.Enabled {
+		opts = append(opts, cel.WithEvaluationCache(ctrl.Log.WithName("evaluation-cache"), cache.GetSize()))
+	}
+	if migration := cfg.ResourceAnnotationPrefixMigration; migration != nil {
+		if err := migration.Validate(); err != nil {
+			return nil, err
+		}
+		opts = append(opts, cel.WithResourceAnnotationPrefixes(migration.WritePrefixes(), migration.ReadPrefixes()))
+	}
+	if cfg.CEL.PruneUnknownResourceRequests {
+		opts = append(opts, cel.WithResourceRequestPruning(cfg.CEL.AllowedResourceRequests))
+	}
+	if cfg.Logging.LogMutations {
+		logConfig, err := mutationLogConfig(cfg.Logging)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cel.WithMutationLogging(ctrl.Log.WithName("mutations"), logConfig))
+	}
+	return opts, nil
+}
+
+func mutationLogConfig(cfg kueueconfig.Logging) (cel.MutationLogConfig, error) {
+	logConfig := cel.MutationLogConfig{
+		MaxValueLength:       cfg.MaxValueLength,
+		RedactKeys:           cfg.RedactKeys,
+		IncludeInputSnapshot: cfg.IncludeInputSnapshot,
+	}
+	return logConfig, logConfig.Validate()
+}
+
+// compileCELPrograms compiles the top-level expressions, in the default
+// group, followed by the expressions of the enabled groups and the perQueue
+// expressions, restricted to their queue, by queue name. All the
+// expressions are compiled, so the error reports every invalid one, but no
+// program is returned unless all of them compile.
+func compileCELPrograms(cfg kueueconfig.CEL, opts ...cel.CompileOption) ([]*cel.CompiledProgram, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var programs []*cel.CompiledProgram
+	var errs []error
+	if len(cfg.Expressions) > 0 {
+		compiled, err := cel.CompileNamedCELProgramsLenient(namedExpressions(cfg.Expressions), opts...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compiling CEL programs: %w", err))
+		}
+		programs = append(programs, compiled...)
+	}
+	for _, group := range cfg.Groups {
+		if !group.IsEnabled() {
+			setupLog.Info("Skipping disabled CEL group", "group", group.Name)
+			continue
+		}
+		compiled, err := cel.CompileNamedCELProgramsLenient(namedExpressions(group.Expressions), append(opts, cel.WithGroup(group.Name))...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compiling CEL programs of group %q: %w", group.Name, err))
+		}
+		programs = append(programs, compiled...)
+	}
+	// The queues are compiled in a stable order, so are their programs
+	for _, queue := range slices.Sorted(maps.Keys(cfg.PerQueue)) {
+		compiled, err := cel.CompileCELProgramsLenient(cfg.PerQueue[queue],
+			append(opts, cel.WithGroup(cel.QueueGroup(queue)), cel.WithQueue(queue))...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compiling CEL programs of queue %q: %w", queue, err))
+		}
+		programs = append(programs, compiled...)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(programs) == 0 {
+		return nil, errors.New("compiling CEL programs: no CEL expressions are enabled")
+	}
+	duplicates := cel.FindDuplicateKeys(programs)
+	if cfg.StrictKeys && len(duplicates) > 0 {
+		for _, duplicate := range duplicates {
+			errs = append(errs, errors.New(duplicate.String()))
+		}
+		return nil, fmt.Errorf("compiling CEL programs with strictKeys: %w", errors.Join(errs...))
+	}
+	for _, duplicate := range duplicates {
+		setupLog.Info("WARNING: several CEL expressions set the same key, the last one wins",
+			"type", duplicate.Type, "key", duplicate.Key, "expressions", duplicate.Expressions)
+	}
+	return programs, nil
+}
+
+// namedExpressions converts the configured expressions to the input of the
+// compilation.
+func namedExpressions(expressions []kueueconfig.CELExpression) []cel.NamedExpression {
+	named := make([]cel.NamedExpression, 0, len(expressions))
+	for _, expression := range expressions {
+		n := cel.NamedExpression{Name: expression.Name, Expression: expression.Expression}
+		if expression.OnError != nil {
+			n.OnError = make([]cel.MutationRequest, 0, len(expression.OnError))
+		}
+		for _, mutation := range expression.OnError {
+			n.OnError = append(n.OnError, cel.MutationRequest{
+				Type:  cel.MutationType(mutation.Type),
+				Key:   mutation.Key,
+				Value: mutation.Value,
+			})
+		}
+		named = append(named, n)
+	}
+	return named
+}
+
+// compileValidationPrograms compiles the configured CEL validation
+// expressions, if any.
+func compileValidationPrograms(cfg kueueconfig.CEL) ([]*cel.ValidationProgram, error) {
+	if len(cfg.Validations) == 0 {
+		return nil, nil
+	}
+	return cel.CompileValidationPrograms(cfg.Validations)
+}
+
+// canaryConfigFile is the file of the canary configuration, next to
+// config.yaml. Only its CEL configuration is used.
+const canaryConfigFile = "config-canary.yaml"
+
+// loadCanaryConfig loads the canary configuration of the directory, or
+// returns nil when there's none.
+func loadCanaryConfig(dir string) (*kueueconfig.Config, error) {
+	data, err := os.ReadFile(path.Join(dir, canaryConfigFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &kueueconfig.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", canaryConfigFile, err)
+	}
+	setupLog.Info("Loaded the canary config", "dir", dir, "file", canaryConfigFile, "cfg", cfg)
+	return cfg, nil
+}
+
 func loadConfig(dir string) (*kueueconfig.Config, error) {
 	setupLog.Info("Loading Kueue config from ", "dir", dir, "file", "config.yaml")
 	if dir == "" {
@@ -564,6 +1932,26 @@ func loadConfig(dir string) (*kueueconfig.Config, error) {
 		setupLog.Error(err, "Failed to parse Kueue config file")
 		return cfg, err
 	}
+	if err := cfg.DefaultResources.Validate(); err != nil {
+		setupLog.Error(err, "Invalid Kueue config file")
+		return cfg, err
+	}
+	if err := cfg.Webhook.RateLimit.Validate(); err != nil {
+		setupLog.Error(err, "Invalid Kueue config file")
+		return cfg, err
+	}
+	if err := cfg.AnnotationMigrations.Validate(); err != nil {
+		setupLog.Error(err, "Invalid Kueue config file")
+		return cfg, err
+	}
+	if err := cfg.Webhook.DeletionProtection.Validate(); err != nil {
+		setupLog.Error(err, "Invalid Kueue config file")
+		return cfg, err
+	}
+	if _, err := cfg.ManageSelector(); err != nil {
+		setupLog.Error(err, "Invalid Kueue config file")
+		return cfg, err
+	}
 	setupLog.Info("Loaded Kueue config from ", "dir", dir, "cfg", cfg)
 	return cfg, nil
 }
@@ -1,9 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	kueueconfig "github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/features"
 )
 
 func TestControllerFlags_AddFlags(t *testing.T) {
@@ -87,3 +103,526 @@ func TestControllerFlags_InvalidDuration(t *testing.T) {
 		t.Error("Expected error for invalid duration format, got nil")
 	}
 }
+
+func TestCompileCELPrograms(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         string
+		expectedGroups []string
+		expectedErr    string
+	}{
+		{
+			name: "flat expressions",
+			config: `
+cel:
+  expressions:
+    - 'priority("high")'
+`,
+			expectedGroups: []string{"default"},
+		},
+		{
+			name: "flat expressions and groups",
+			config: `
+cel:
+  expressions:
+    - 'priority("high")'
+  groups:
+    - name: platform-resources
+      enabled: true
+      expressions:
+        - 'resource("linux-arm64", 1)'
+        - 'resource("linux-amd64", 1)'
+    - name: annotations
+      expressions:
+        - 'annotation("owner", "team-a")'
+`,
+			expectedGroups: []string{"default", "platform-resources", "platform-resources", "annotations"},
+		},
+		{
+			name: "disabled groups are skipped",
+			config: `
+cel:
+  groups:
+    - name: priorities
+      enabled: true
+      expressions:
+        - 'priority("high")'
+    - name: platform-resources
+      enabled: false
+      expressions:
+        - 'invalid expression'
+`,
+			expectedGroups: []string{"priorities"},
+		},
+		{
+			name: "no enabled expressions",
+			config: `
+cel:
+  groups:
+    - name: priorities
+      enabled: false
+      expressions:
+        - 'priority("high")'
+`,
+			expectedErr: "no CEL expressions are enabled",
+		},
+		{
+			name: "onError mutations",
+			config: `
+cel:
+  expressions:
+    - name: platform
+      expression: 'label("platform", pipelineRun.metadata.labels["platform"])'
+      onError:
+        - type: label
+          key: kueue.x-k8s.io/priority-class
+          value: konflux-default
+`,
+			expectedGroups: []string{"default"},
+		},
+		{
+			name: "invalid onError mutations",
+			config: `
+cel:
+  expressions:
+    - name: platform
+      expression: 'label("platform", pipelineRun.metadata.labels["platform"])'
+      onError:
+        - type: label
+          key: invalid key!
+          value: konflux-default
+`,
+			expectedErr: "expression 'platform' (label(\"platform\", pipelineRun.metadata.labels[\"platform\"])): " +
+				"invalid onError mutation at index 0",
+		},
+		{
+			name: "duplicate group names",
+			config: `
+cel:
+  groups:
+    - name: priorities
+      expressions:
+        - 'priority("high")'
+    - name: priorities
+      expressions:
+        - 'priority("low")'
+`,
+			expectedErr: `duplicate CEL group "priorities"`,
+		},
+		{
+			name: "per-queue expressions",
+			config: `
+cel:
+  expressions:
+    - 'priority("high")'
+  perQueue:
+    release:
+      - 'priority("critical")'
+    build:
+      - 'resource("cpu", 1)'
+      - 'resource("memory", 1)'
+`,
+			expectedGroups: []string{"default", "queue:build", "queue:build", "queue:release"},
+		},
+		{
+			name: "per-queue expressions only",
+			config: `
+cel:
+  perQueue:
+    build:
+      - 'resource("cpu", 1)'
+`,
+			expectedGroups: []string{"queue:build"},
+		},
+		{
+			name: "invalid per-queue expression",
+			config: `
+cel:
+  perQueue:
+    build:
+      - 'invalid expression'
+`,
+			expectedErr: `compiling CEL programs of queue "build"`,
+		},
+		{
+			name: "duplicate keys are allowed by default",
+			config: `
+cel:
+  expressions:
+    - 'label("team", "a")'
+  groups:
+    - name: teams
+      expressions:
+        - 'label("team", "b")'
+`,
+			expectedGroups: []string{"default", "teams"},
+		},
+		{
+			name: "duplicate keys with strictKeys",
+			config: `
+cel:
+  strictKeys: true
+  expressions:
+    - 'label("team", "a")'
+    - 'resource("cpu", 1)'
+  groups:
+    - name: teams
+      expressions:
+        - 'label("team", "b")'
+        - 'resource("cpu", 2)'
+`,
+			expectedErr: `the label "team" is set by several expressions`,
+		},
+		{
+			name: "dynamic keys with strictKeys",
+			config: `
+cel:
+  strictKeys: true
+  expressions:
+    - 'label(pipelineRun.metadata.name, "a")'
+    - 'label(pipelineRun.metadata.name, "b")'
+`,
+			expectedGroups: []string{"default", "default"},
+		},
+		{
+			name: "invalid expression in a group",
+			config: `
+cel:
+  groups:
+    - name: priorities
+      expressions:
+        - 'priority(1)'
+`,
+			expectedErr: `compiling CEL programs of group "priorities"`,
+		},
+		{
+			name: "every invalid expression is reported",
+			config: `
+cel:
+  expressions:
+    - 'label("team", 1)'
+  groups:
+    - name: priorities
+      expressions:
+        - 'priority("high")'
+        - 'priority(1)'
+`,
+			expectedErr: `compiling CEL programs of group "priorities": expression 1 (priority(1))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(tt.config), 0o600); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			cfg, err := loadConfig(dir)
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+
+			programs, err := compileCELPrograms(cfg.CEL)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			groups := make([]string, 0, len(programs))
+			for _, program := range programs {
+				groups = append(groups, program.GetGroup())
+			}
+			if !slices.Equal(groups, tt.expectedGroups) {
+				t.Errorf("groups = %v, want %v", groups, tt.expectedGroups)
+			}
+		})
+	}
+}
+
+func TestPrintRBACDiff(t *testing.T) {
+	opts := features.RenderOptions{NamePrefix: "tekton-kueue-", Namespace: "tekton-kueue"}
+	bind := func(name, serviceAccount string, rules ...rbacv1.PolicyRule) []client.Object {
+		return []client.Object{
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}, Rules: rules},
+			&rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+				Subjects: []rbacv1.Subject{{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      serviceAccount,
+					Namespace: "tekton-kueue",
+				}},
+			},
+		}
+	}
+	cfg := &kueueconfig.Config{}
+	cfg.Controller.QueuePosition.Enabled = true
+
+	var objs []client.Object
+	objs = append(objs, bind("tekton-kueue-manager-role", "tekton-kueue-controller-manager",
+		features.BaseRules[features.ComponentController]...)...)
+	objs = append(objs, bind("tekton-kueue-metrics-auth-role", "tekton-kueue-webhook",
+		append(features.BaseRules[features.ComponentWebhook], rbacv1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+			Verbs:     []string{"get"},
+		})...)...)
+	// Bound to another ServiceAccount
+	objs = append(objs, bind("other", "other",
+		rbacv1.PolicyRule{APIGroups: []string{"kueue.x-k8s.io"}, Resources: []string{"localqueues"}, Verbs: []string{"*"}})...)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	var out bytes.Buffer
+	missing, err := printRBACDiff(context.Background(), &out, c, cfg, opts)
+	if err != nil {
+		t.Fatalf("printRBACDiff() error = %v", err)
+	}
+	if !missing {
+		t.Errorf("printRBACDiff() missing = false, want true")
+	}
+	expected := `controller (ServiceAccount tekton-kueue/tekton-kueue-controller-manager):
+  missing: get kueue.x-k8s.io/localqueues
+  missing: list kueue.x-k8s.io/localqueues
+  missing: watch kueue.x-k8s.io/localqueues
+webhook (ServiceAccount tekton-kueue/tekton-kueue-webhook):
+  excess: get secrets
+`
+	if out.String() != expected {
+		t.Errorf("printRBACDiff() output = %q, want %q", out.String(), expected)
+	}
+
+	// Once the optional features' role is applied
+	rendered := &rbacv1.ClusterRole{}
+	rendered.Name = opts.ClusterRoleName(features.ComponentController)
+	rendered.Rules = features.ExtraRules(cfg, features.ComponentController)
+	for _, obj := range bind(rendered.Name, "tekton-kueue-controller-manager", rendered.Rules...) {
+		if err := c.Create(context.Background(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out.Reset()
+	missing, err = printRBACDiff(context.Background(), &out, c, cfg, opts)
+	if err != nil {
+		t.Fatalf("printRBACDiff() error = %v", err)
+	}
+	if missing {
+		t.Errorf("printRBACDiff() missing = true, want false")
+	}
+	if !strings.Contains(out.String(), "tekton-kueue-controller-manager):\n  no differences\n") {
+		t.Errorf("printRBACDiff() output = %q, want no differences for the controller", out.String())
+	}
+}
+
+func TestWriteCELReference(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeCELReference(&out, "markdown"); err != nil {
+		t.Fatalf("writeCELReference() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "# CEL Reference\n") {
+		t.Errorf("writeCELReference() output = %q, want a Markdown reference", out.String())
+	}
+
+	out.Reset()
+	if err := writeCELReference(&out, "json"); err != nil {
+		t.Fatalf("writeCELReference() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `"name": "quantityToMilli"`) {
+		t.Errorf("writeCELReference() output = %q, want a JSON reference", out.String())
+	}
+
+	if err := writeCELReference(&out, "html"); err == nil {
+		t.Errorf("writeCELReference() error = nil, want an unsupported format error")
+	}
+}
+
+func TestNewWebhookMutator_Canary(t *testing.T) {
+	dir := t.TempDir()
+	stableConfig := "queueName: pipelines-queue\ncanaryPercent: 10\ncel:\n  expressions:\n    - 'priority(\"stable\")'\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(stableConfig), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := loadConfig(dir)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	canaryCfg, err := loadCanaryConfig(dir)
+	if err != nil || canaryCfg != nil {
+		t.Fatalf("Expected no canary configuration, got %v, %v", canaryCfg, err)
+	}
+	if _, canary, err := newWebhookMutator(cfg, canaryCfg); err != nil || canary != nil {
+		t.Fatalf("Expected the stable mutator alone, got %v, %v", canary, err)
+	}
+
+	canaryConfig := "cel:\n  expressions:\n    - 'priority(\"canary\")'\n"
+	if err := os.WriteFile(filepath.Join(dir, canaryConfigFile), []byte(canaryConfig), 0o600); err != nil {
+		t.Fatalf("Failed to write the canary config: %v", err)
+	}
+	canaryCfg, err = loadCanaryConfig(dir)
+	if err != nil || canaryCfg == nil {
+		t.Fatalf("Failed to load the canary config: %v", err)
+	}
+	_, canary, err := newWebhookMutator(cfg, canaryCfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if canary == nil || canary.Percent() != 10 {
+		t.Errorf("Expected a canary serving 10%% of the admissions, got %v", canary)
+	}
+
+	canaryCfg.CEL.Expressions = kueueconfig.UnnamedExpressions("priority(1)")
+	if _, _, err := newWebhookMutator(cfg, canaryCfg); err == nil || !strings.Contains(err.Error(), "canary configuration") {
+		t.Errorf("Expected an error of the canary configuration, got %v", err)
+	}
+	cfg.CanaryPercent = 120
+	if _, _, err := newWebhookMutator(cfg, canaryCfg); err == nil || !strings.Contains(err.Error(), "canaryPercent") {
+		t.Errorf("Expected an error of the canary percentage, got %v", err)
+	}
+}
+
+func TestNewCELMutator_TimeZone(t *testing.T) {
+	cfg := &kueueconfig.Config{
+		QueueName: "pipelines-queue",
+		CEL: kueueconfig.CEL{
+			Expressions: kueueconfig.UnnamedExpressions(`[label("weekday", nowWeekday), label("hour", nowHourUTC)]`),
+			TimeZone:    "Europe/Prague",
+		},
+	}
+	// A Saturday at 23:30 UTC, already Sunday in Prague
+	now := time.Date(2025, time.June, 7, 23, 30, 0, 0, time.UTC)
+	mutator, err := newCELMutator(cfg, cel.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+	if err := mutator.Mutate(context.Background(), pipelineRun); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pipelineRun.Labels["weekday"] != "Sunday" || pipelineRun.Labels["hour"] != "23" {
+		t.Errorf("Expected the weekday in Prague and the hour in UTC, got %v", pipelineRun.Labels)
+	}
+
+	cfg.CEL.TimeZone = "Mars/Olympus"
+	if _, err := newCELMutator(cfg); err == nil || !strings.Contains(err.Error(), "timeZone") {
+		t.Errorf("Expected an error of the time zone, got %v", err)
+	}
+}
+
+func TestExplainNamespace(t *testing.T) {
+	dir := t.TempDir()
+	stableConfig := "queueName: pipelines-queue\nmultiKueueOverride: true\ncanaryPercent: 100\n" +
+		"managedNamespaces:\n  matchLabels:\n    konflux.ci/type: user\n" +
+		"cel:\n  groups:\n    - name: priorities\n      expressions:\n        - 'priority(\"stable\")'\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(stableConfig), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	canaryConfig := "cel:\n  expressions:\n    - 'priority(\"canary\")'\n"
+	if err := os.WriteFile(filepath.Join(dir, canaryConfigFile), []byte(canaryConfig), 0o600); err != nil {
+		t.Fatalf("Failed to write the canary config: %v", err)
+	}
+	sample := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "build-"},
+		Spec:       tekv1.PipelineRunSpec{PipelineRef: &tekv1.PipelineRef{Name: "build"}},
+	}
+
+	explanation, err := explainNamespace(dir, "tenant", sample)
+	if err != nil {
+		t.Fatalf("explainNamespace() error = %v", err)
+	}
+	var out bytes.Buffer
+	if err := writeExplanation(&out, explanation, "text"); err != nil {
+		t.Fatalf("writeExplanation() error = %v", err)
+	}
+	for _, expected := range []string{
+		"the selector isn't evaluated without access to the cluster",
+		"change: spec.managedBy=kueue.x-k8s.io/multikueue",
+		"cel (stable): doesn't apply",
+		"priorities[0]: priority(\"stable\")",
+		"applied: label kueue.x-k8s.io/priority-class=canary",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("writeExplanation() output = %q, want %q", out.String(), expected)
+		}
+	}
+
+	out.Reset()
+	if err := writeExplanation(&out, explanation, "json"); err != nil {
+		t.Fatalf("writeExplanation() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `"name": "canary"`) {
+		t.Errorf("writeExplanation() output = %q, want a JSON explanation", out.String())
+	}
+	if err := writeExplanation(&out, explanation, "yaml"); err == nil {
+		t.Errorf("writeExplanation() error = nil, want an unsupported format error")
+	}
+}
+
+func TestFetchPipelineRun(t *testing.T) {
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "build",
+			Namespace:         "tenant",
+			UID:               "6f1d2c0e-5b0c-4bb4-9d0e-2a1f3c4d5e6f",
+			Generation:        2,
+			Labels:            map[string]string{"app": "build", "tekton.dev/pipeline": "build"},
+			Annotations:       map[string]string{"owner": "team-a"},
+			ManagedFields:     []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate}},
+			CreationTimestamp: metav1.NewTime(time.Date(2025, 6, 7, 23, 30, 0, 0, time.UTC)),
+		},
+		Spec: tekv1.PipelineRunSpec{PipelineRef: &tekv1.PipelineRef{Name: "build"}},
+		Status: tekv1.PipelineRunStatus{
+			PipelineRunStatusFields: tekv1.PipelineRunStatusFields{
+				StartTime: &metav1.Time{Time: time.Date(2025, 6, 7, 23, 31, 0, 0, time.UTC)},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun).Build()
+	ctx := context.Background()
+
+	fetched, err := fetchPipelineRun(ctx, c, "tenant/build", defaultStripFields)
+	if err != nil {
+		t.Fatalf("fetchPipelineRun() error = %v", err)
+	}
+	if fetched.UID != "" || fetched.ResourceVersion != "" || fetched.Generation != 0 ||
+		fetched.ManagedFields != nil || !fetched.CreationTimestamp.IsZero() {
+		t.Errorf("fetchPipelineRun() metadata = %+v, want the server-populated fields stripped", fetched.ObjectMeta)
+	}
+	if fetched.Status.StartTime != nil {
+		t.Errorf("fetchPipelineRun() status = %+v, want it stripped", fetched.Status)
+	}
+	if fetched.Name != "build" || fetched.Namespace != "tenant" || fetched.Annotations["owner"] != "team-a" ||
+		fetched.Spec.PipelineRef == nil || fetched.Spec.PipelineRef.Name != "build" {
+		t.Errorf("fetchPipelineRun() = %+v, want the name, annotations and spec kept", fetched)
+	}
+
+	fetched, err = fetchPipelineRun(ctx, c, "tenant/build", splitFieldPaths(" metadata.labels.app, ,status"))
+	if err != nil {
+		t.Fatalf("fetchPipelineRun() error = %v", err)
+	}
+	if _, ok := fetched.Labels["app"]; ok || fetched.Labels["tekton.dev/pipeline"] != "build" || fetched.UID == "" {
+		t.Errorf("fetchPipelineRun() metadata = %+v, want only the app label stripped", fetched.ObjectMeta)
+	}
+
+	// The PipelineRun in the cluster is left unchanged
+	stored := &tekv1.PipelineRun{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pipelineRun), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.UID != pipelineRun.UID || stored.Labels["app"] != "build" || stored.Status.StartTime == nil {
+		t.Errorf("stored PipelineRun = %+v, want it unchanged", stored)
+	}
+
+	for _, ref := range []string{"build", "tenant/", "/build", "tenant/build/x"} {
+		if _, err := fetchPipelineRun(ctx, c, ref, nil); err == nil || !strings.Contains(err.Error(), "namespace/name") {
+			t.Errorf("fetchPipelineRun(%q) error = %v, want an invalid reference error", ref, err)
+		}
+	}
+	if _, err := fetchPipelineRun(ctx, c, "tenant/missing", nil); err == nil {
+		t.Errorf("fetchPipelineRun() of a missing PipelineRun error = nil, want an error")
+	}
+}
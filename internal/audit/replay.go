@@ -0,0 +1,168 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit replays the admissions recorded in the mutation logs of the
+// webhook against a candidate configuration.
+package audit
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+// priorityKey is the key of the label set by the priority() CEL function.
+const priorityKey = "kueue.x-k8s.io/priority-class"
+
+// maxRecordSize is the maximum size of a line of the audit file.
+const maxRecordSize = 16 * 1024 * 1024
+
+// Record is a mutation log record, as written by the JSON encoder of the
+// webhook logger.
+type Record struct {
+	Message      string               `json:"msg"`
+	Namespace    string               `json:"namespace"`
+	GenerateName string               `json:"generateName"`
+	AdmissionUID string               `json:"admissionUID"`
+	Mutations    []cel.LoggedMutation `json:"mutations"`
+	Error        string               `json:"error"`
+	Input        *cel.InputSnapshot   `json:"input"`
+}
+
+// Options configures the sampling of the records.
+type Options struct {
+	// SampleRate is the fraction of the records which are replayed. All
+	// the records are replayed when it's not in (0, 1).
+	SampleRate float64
+	// SampleSeed changes the sampled records. A record is sampled based on
+	// its content and the seed, so the sample doesn't change across runs.
+	SampleSeed string
+	// MaxRecords caps the number of replayed records when positive.
+	MaxRecords int
+}
+
+// sampled returns whether the record on the line is replayed.
+func (o Options) sampled(line []byte) bool {
+	if o.SampleRate <= 0 || o.SampleRate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(o.SampleSeed))
+	_, _ = h.Write(line)
+	return float64(h.Sum64()) < o.SampleRate*math.MaxUint64
+}
+
+// Replay re-runs the mutator over the input snapshots of the records read
+// from r, and compares the outcomes with the recorded ones. The candidate
+// mutations are sanitized with logConfig, as they would be in the logs,
+// before being compared.
+func Replay(r io.Reader, mutator *cel.CELMutator, logConfig cel.MutationLogConfig, opts Options) (*Report, error) {
+	report := newReport()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		record := &Record{}
+		if err := json.Unmarshal(line, record); err != nil ||
+			(record.Message != cel.LogMessageApplied && record.Message != cel.LogMessageFailed) {
+			report.Ignored++
+			continue
+		}
+		if record.Input == nil {
+			report.WithoutInput++
+			continue
+		}
+		if !opts.sampled(line) {
+			report.NotSampled++
+			continue
+		}
+		if opts.MaxRecords > 0 && report.Replayed >= opts.MaxRecords {
+			report.NotSampled++
+			continue
+		}
+
+		mutations, err := mutator.DryRun(record.Input.PipelineRun())
+		candidate := outcome{failed: err != nil}
+		if err == nil {
+			candidate.mutations = logConfig.SanitizeMutations(mutations)
+		}
+		recorded := outcome{failed: record.Message == cel.LogMessageFailed, mutations: record.Mutations}
+		report.add(recorded, candidate)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading the audit records: %w", err)
+	}
+	return report, nil
+}
+
+// outcome is the result of the mutation of a PipelineRun.
+type outcome struct {
+	failed    bool
+	mutations []cel.LoggedMutation
+}
+
+// priority returns the priority class set by the mutations, or "" if
+// there's none.
+func (o outcome) priority() string {
+	priority := ""
+	for _, m := range o.mutations {
+		if m.Type == cel.MutationTypeLabel && m.Key == priorityKey {
+			priority = m.Value
+		}
+	}
+	return priority
+}
+
+// resourceKeys returns the keys of the resource mutations.
+func (o outcome) resourceKeys() []string {
+	var keys []string
+	for _, m := range o.mutations {
+		if m.Type == cel.MutationTypeResource && !slices.Contains(keys, m.Key) {
+			keys = append(keys, m.Key)
+		}
+	}
+	return keys
+}
+
+// equal compares the outcomes regardless of the order of the mutations,
+// which are sorted by the mutator but were applied in evaluation order in
+// the older records.
+func (o outcome) equal(other outcome) bool {
+	if o.failed || other.failed {
+		return o.failed == other.failed
+	}
+	return slices.Equal(sortedMutations(o.mutations), sortedMutations(other.mutations))
+}
+
+// sortedMutations returns a copy of the mutations sorted by type, key and
+// value.
+func sortedMutations(mutations []cel.LoggedMutation) []cel.LoggedMutation {
+	return slices.SortedFunc(slices.Values(mutations), func(a, b cel.LoggedMutation) int {
+		return cmp.Or(
+			strings.Compare(string(a.Type), string(b.Type)),
+			strings.Compare(a.Key, b.Key),
+			strings.Compare(a.Value, b.Value),
+		)
+	})
+}
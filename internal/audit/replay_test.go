@@ -0,0 +1,140 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+var candidateExpressions = []string{
+	`priority(plrNamespace == "team-a" ? "high" : "medium")`,
+	`resource(plrNamespace == "team-a" ? "linux-amd64" : "linux-arm64", 1)`,
+	`annotation("team", pipelineRun.metadata.labels["team"])`,
+	`annotation("example.com/secret", "s3cr3t")`,
+}
+
+var candidateLogConfig = cel.MutationLogConfig{RedactKeys: []string{"example.com/*"}}
+
+func newCandidateMutator(g Gomega) *cel.CELMutator {
+	programs, err := cel.CompileCELPrograms(candidateExpressions)
+	g.Expect(err).NotTo(HaveOccurred())
+	return cel.NewCELMutator(programs)
+}
+
+func replayFile(g Gomega, opts Options) *Report {
+	f, err := os.Open("testdata/audit.jsonl")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = f.Close() }()
+
+	report, err := Replay(f, newCandidateMutator(g), candidateLogConfig, opts)
+	g.Expect(err).NotTo(HaveOccurred())
+	return report
+}
+
+func TestReplay(t *testing.T) {
+	g := NewWithT(t)
+
+	report := replayFile(g, Options{})
+	g.Expect(report).To(Equal(&Report{
+		Replayed:            4,
+		Changed:             3,
+		Ignored:             2,
+		WithoutInput:        1,
+		RecordedErrors:      1,
+		CandidateErrors:     1,
+		RecordedPriorities:  map[string]int{"high": 1, "low": 2},
+		CandidatePriorities: map[string]int{"high": 1, "medium": 2},
+		NewResourceKeys:     map[string]int{"kueue.konflux-ci.dev/requests-linux-arm64": 1},
+		RemovedResourceKeys: map[string]int{"kueue.konflux-ci.dev/requests-linux-amd64": 1},
+	}))
+
+	var out bytes.Buffer
+	g.Expect(report.Write(&out)).To(Succeed())
+	g.Expect(out.String()).To(Equal(`Replayed records:  4
+Changed outcomes:  3
+Skipped records:   1 without input snapshot, 0 not sampled
+Ignored lines:     2
+Error rate:        recorded 25.0% (1), candidate 25.0% (1)
+
+Priority distribution:
+  PRIORITY  RECORDED  CANDIDATE
+  high      1         1
+  low       2         0
+  medium    0         2
+
+Resource keys:
+  + kueue.konflux-ci.dev/requests-linux-arm64  1 records
+  - kueue.konflux-ci.dev/requests-linux-amd64  1 records
+`))
+}
+
+func TestReplay_Sampling(t *testing.T) {
+	g := NewWithT(t)
+
+	sampled := replayFile(g, Options{SampleRate: 0.5, SampleSeed: "seed"})
+	g.Expect(sampled.Replayed + sampled.NotSampled).To(Equal(4))
+	// The sample is deterministic
+	g.Expect(replayFile(g, Options{SampleRate: 0.5, SampleSeed: "seed"})).To(Equal(sampled))
+
+	capped := replayFile(g, Options{MaxRecords: 2})
+	g.Expect(capped.Replayed).To(Equal(2))
+	g.Expect(capped.NotSampled).To(Equal(2))
+}
+
+func TestReplay_RoundTrip(t *testing.T) {
+	g := NewWithT(t)
+
+	// Records written by the webhook logger are replayed without changes
+	// with the same configuration
+	var logs bytes.Buffer
+	logConfig := candidateLogConfig
+	logConfig.IncludeInputSnapshot = true
+	programs, err := cel.CompileCELPrograms(candidateExpressions)
+	g.Expect(err).NotTo(HaveOccurred())
+	logger := zap.New(zap.WriteTo(&logs), zap.JSONEncoder())
+	mutator := cel.NewCELMutator(programs, cel.WithMutationLogging(logger, logConfig))
+
+	for _, namespace := range []string{"team-a", "team-b"} {
+		for _, labels := range []map[string]string{{"team": "x"}, nil} {
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "build-", Namespace: namespace, Labels: labels},
+				Spec:       tekv1.PipelineRunSpec{PipelineRef: &tekv1.PipelineRef{Name: "build"}},
+			}
+			_ = mutator.Mutate(context.Background(), pipelineRun)
+		}
+	}
+
+	records := logs.String()
+	report, err := Replay(bytes.NewBufferString(records), newCandidateMutator(g), candidateLogConfig, Options{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(report.Replayed).To(Equal(4))
+	g.Expect(report.Changed).To(BeZero(), records)
+	g.Expect(report.RecordedErrors).To(Equal(2))
+	g.Expect(report.CandidateErrors).To(Equal(2))
+	g.Expect(report.NewResourceKeys).To(BeEmpty())
+	g.Expect(records).NotTo(ContainSubstring("s3cr3t"))
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+)
+
+// noPriority is the name of the PipelineRuns without priority in the
+// priority distribution.
+const noPriority = "(none)"
+
+// Report aggregates the differences between the recorded outcomes and the
+// outcomes of the candidate configuration.
+type Report struct {
+	// Replayed is the number of replayed records.
+	Replayed int
+	// Changed is the number of replayed records whose outcome changed.
+	Changed int
+	// Ignored is the number of lines which aren't mutation records.
+	Ignored int
+	// WithoutInput is the number of records without input snapshot.
+	WithoutInput int
+	// NotSampled is the number of records left out by the sampling.
+	NotSampled int
+
+	RecordedErrors  int
+	CandidateErrors int
+
+	// RecordedPriorities and CandidatePriorities count the replayed
+	// records by priority class, for the records which didn't fail.
+	RecordedPriorities  map[string]int
+	CandidatePriorities map[string]int
+
+	// NewResourceKeys and RemovedResourceKeys count, by resource key, the
+	// records for which the candidate configuration adds a resource
+	// mutation which wasn't recorded, or no longer adds a recorded one.
+	NewResourceKeys     map[string]int
+	RemovedResourceKeys map[string]int
+}
+
+func newReport() *Report {
+	return &Report{
+		RecordedPriorities:  map[string]int{},
+		CandidatePriorities: map[string]int{},
+		NewResourceKeys:     map[string]int{},
+		RemovedResourceKeys: map[string]int{},
+	}
+}
+
+func (r *Report) add(recorded, candidate outcome) {
+	r.Replayed++
+	if !recorded.equal(candidate) {
+		r.Changed++
+	}
+
+	countOutcome(recorded, &r.RecordedErrors, r.RecordedPriorities)
+	countOutcome(candidate, &r.CandidateErrors, r.CandidatePriorities)
+
+	if recorded.failed || candidate.failed {
+		return
+	}
+	recordedKeys := recorded.resourceKeys()
+	candidateKeys := candidate.resourceKeys()
+	for _, key := range candidateKeys {
+		if !slices.Contains(recordedKeys, key) {
+			r.NewResourceKeys[key]++
+		}
+	}
+	for _, key := range recordedKeys {
+		if !slices.Contains(candidateKeys, key) {
+			r.RemovedResourceKeys[key]++
+		}
+	}
+}
+
+func countOutcome(o outcome, errors *int, priorities map[string]int) {
+	if o.failed {
+		*errors++
+		return
+	}
+	priority := o.priority()
+	if priority == "" {
+		priority = noPriority
+	}
+	priorities[priority]++
+}
+
+// Write writes the report in a human-readable format.
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Replayed records:\t%d\n", r.Replayed)
+	fmt.Fprintf(tw, "Changed outcomes:\t%d\n", r.Changed)
+	fmt.Fprintf(tw, "Skipped records:\t%d without input snapshot, %d not sampled\n", r.WithoutInput, r.NotSampled)
+	fmt.Fprintf(tw, "Ignored lines:\t%d\n", r.Ignored)
+	fmt.Fprintf(tw, "Error rate:\trecorded %s, candidate %s\n",
+		r.rate(r.RecordedErrors), r.rate(r.CandidateErrors))
+
+	fmt.Fprintln(tw, "\nPriority distribution:")
+	fmt.Fprintln(tw, "  PRIORITY\tRECORDED\tCANDIDATE")
+	priorities := slices.Collect(maps.Keys(r.RecordedPriorities))
+	for priority := range r.CandidatePriorities {
+		if !slices.Contains(priorities, priority) {
+			priorities = append(priorities, priority)
+		}
+	}
+	slices.Sort(priorities)
+	for _, priority := range priorities {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\n", priority, r.RecordedPriorities[priority], r.CandidatePriorities[priority])
+	}
+
+	fmt.Fprintln(tw, "\nResource keys:")
+	if len(r.NewResourceKeys) == 0 && len(r.RemovedResourceKeys) == 0 {
+		fmt.Fprintln(tw, "  no changes")
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.NewResourceKeys)) {
+		fmt.Fprintf(tw, "  + %s\t%d records\n", key, r.NewResourceKeys[key])
+	}
+	for _, key := range slices.Sorted(maps.Keys(r.RemovedResourceKeys)) {
+		fmt.Fprintf(tw, "  - %s\t%d records\n", key, r.RemovedResourceKeys[key])
+	}
+	return tw.Flush()
+}
+
+// rate formats the number of errors as a rate of the replayed records.
+func (r *Report) rate(errors int) string {
+	if r.Replayed == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%% (%d)", 100*float64(errors)/float64(r.Replayed), errors)
+}
@@ -0,0 +1,81 @@
+package cel
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// createIntListFunction creates a CEL function aggregating a list of ints
+// with aggregate. The elements of the lists of dyn, e.g. those of the
+// pipelineRun variable, are checked at evaluation.
+func createIntListFunction(name string, aggregate func([]int64) (int64, error)) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_list_int_to_int",
+			[]*cel.Type{cel.ListType(cel.IntType)},
+			cel.IntType,
+			cel.UnaryBinding(func(val ref.Val) ref.Val {
+				list, ok := val.(traits.Lister)
+				if !ok {
+					return types.NewErr("%s function requires a list argument", name)
+				}
+				var values []int64
+				for it := list.Iterator(); it.HasNext() == types.True; {
+					element := it.Next()
+					value, ok := element.(types.Int)
+					if !ok {
+						return types.NewErr("%s function requires a list of ints, got an element of type %s",
+							name, element.Type().TypeName())
+					}
+					values = append(values, int64(value))
+				}
+
+				result, err := aggregate(values)
+				if err != nil {
+					return types.NewErr("%s function %v", name, err)
+				}
+				return types.Int(result)
+			}),
+		),
+	)
+}
+
+// errEmptyList is the failure of the aggregations without a value for the
+// empty lists.
+var errEmptyList = errors.New("requires a non-empty list")
+
+// sumInts returns the sum of the values, 0 for an empty list, or an error
+// when it overflows.
+func sumInts(values []int64) (int64, error) {
+	var sum int64
+	for _, value := range values {
+		if (value > 0 && sum > math.MaxInt64-value) || (value < 0 && sum < math.MinInt64-value) {
+			return 0, errors.New("overflows int")
+		}
+		sum += value
+	}
+	return sum, nil
+}
+
+// maxInt returns the greatest of the values.
+func maxInt(values []int64) (int64, error) {
+	if len(values) == 0 {
+		return 0, errEmptyList
+	}
+	return slices.Max(values), nil
+}
+
+// minInt returns the least of the values.
+func minInt(values []int64) (int64, error) {
+	if len(values) == 0 {
+		return 0, errEmptyList
+	}
+	return slices.Min(values), nil
+}
@@ -0,0 +1,125 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAggregationFunctions_TypeChecking(t *testing.T) {
+	env, err := createCELEnvironment()
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		expression string
+		valid      bool
+	}{
+		{expression: `sum([1, 2])`, valid: true},
+		{expression: `max([1, 2])`, valid: true},
+		{expression: `min([1, 2])`, valid: true},
+		{expression: `sum([])`, valid: true},
+		{expression: `sum(pipelineRun.spec.params.map(p, 1))`, valid: true},
+		{expression: `max(dyn([1, 2]))`, valid: true},
+		{expression: `sum(["a", "b"])`},
+		{expression: `max([1.5, 2.5])`},
+		{expression: `min(1)`},
+		{expression: `sum([1], [2])`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			g := NewWithT(t)
+			_, issues := env.Compile(tt.expression)
+			if tt.valid {
+				g.Expect(issues.Err()).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(issues.Err()).To(MatchError(ContainSubstring("found no matching overload")))
+		})
+	}
+}
+
+func TestAggregationFunctions(t *testing.T) {
+	env, err := createCELEnvironment()
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   int64
+		errorMsg   string
+	}{
+		{name: "sum of an empty list", expression: `sum([])`, expected: 0},
+		{name: "sum of a single value", expression: `sum([7])`, expected: 7},
+		{name: "sum of mixed values", expression: `sum([3, -1, 10, 0])`, expected: 12},
+		{name: "max of a single value", expression: `max([7])`, expected: 7},
+		{name: "max of mixed values", expression: `max([3, -1, 10, 0])`, expected: 10},
+		{name: "min of a single value", expression: `min([7])`, expected: 7},
+		{name: "min of mixed values", expression: `min([3, -1, 10, 0])`, expected: -1},
+		{name: "max of an empty list", expression: `max([])`, errorMsg: "max function requires a non-empty list"},
+		{name: "min of an empty list", expression: `min([])`, errorMsg: "min function requires a non-empty list"},
+		{
+			name:       "sum overflowing",
+			expression: `sum([9223372036854775807, 1])`,
+			errorMsg:   "sum function overflows int",
+		},
+		{
+			name:       "dyn list with other elements",
+			expression: `max(dyn([1, "2"]))`,
+			errorMsg:   "max function requires a list of ints, got an element of type string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred())
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			result, _, err := program.Eval(map[string]interface{}{})
+			if tt.errorMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.Value()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestAggregationFunctions_WithPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`resource("build-vms", sum(pipelineRun.spec.pipelineSpec.tasks.map(t, 1)))`,
+		`resource("parallel-builds", max([paramInt("parallelism", 1), 2]))`,
+		`resource("test-shards", min([paramInt("shards", 16), 8]))`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	plr := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"},
+		Spec: tekv1.PipelineRunSpec{
+			Params: tekv1.Params{{Name: "parallelism", Value: *tekv1.NewStructuredValues("4")}},
+			PipelineSpec: &tekv1.PipelineSpec{Tasks: []tekv1.PipelineTask{
+				{Name: "build-amd64"}, {Name: "build-arm64"}, {Name: "build-s390x"},
+			}},
+		},
+	}
+	var mutations []*MutationRequest
+	for _, program := range programs {
+		result, err := program.Evaluate(plr)
+		g.Expect(err).NotTo(HaveOccurred())
+		mutations = append(mutations, result...)
+	}
+	g.Expect(mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "build-vms", Value: "3"},
+		&MutationRequest{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "parallel-builds", Value: "4"},
+		&MutationRequest{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "test-shards", Value: "8"},
+	))
+}
@@ -0,0 +1,94 @@
+package cel
+
+import (
+	"encoding/json"
+	"strconv"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// AnnotationAppliedResources records, as a JSON object, the amounts added to
+// each resource annotation by resource mutations. It lets the mutator
+// subtract them before mutating the same PipelineRun again, e.g. when the
+// admission webhook is invoked twice for one request, so values set by the
+// user are summed exactly once.
+const AnnotationAppliedResources = "kueue.konflux-ci.dev/cel-resources-applied"
+
+// WithFollowUp makes the mutator apply its mutations after another mutator,
+// to the PipelineRun the other mutator has just mutated, e.g. the
+// expressions of the tenants after the global ones. The amounts recorded in
+// AnnotationAppliedResources by the other mutator aren't reverted, and the
+// amounts of the resource mutations are added to them, so mutating the
+// PipelineRun again with the other mutator reverts both.
+func WithFollowUp() MutatorOption {
+	return func(m *CELMutator) {
+		m.followUp = true
+	}
+}
+
+// revertAppliedResources subtracts the amounts recorded in
+// AnnotationAppliedResources from the resource annotations, restoring the
+// values they had before the previous mutation, and removes the bookkeeping
+// annotation. Records which don't match the annotations, e.g. because the
+// annotation was changed since, are ignored.
+func revertAppliedResources(pipelineRun *tekv1.PipelineRun) {
+	data, ok := pipelineRun.Annotations[AnnotationAppliedResources]
+	if !ok {
+		return
+	}
+	delete(pipelineRun.Annotations, AnnotationAppliedResources)
+
+	applied := map[string]int{}
+	if err := json.Unmarshal([]byte(data), &applied); err != nil {
+		return
+	}
+	for key, amount := range applied {
+		value, ok := pipelineRun.Annotations[key]
+		if !ok {
+			continue
+		}
+		current, err := strconv.Atoi(value)
+		if err != nil || amount < 0 || current < amount {
+			continue
+		}
+		pipelineRun.Annotations[key] = strconv.Itoa(current - amount)
+	}
+}
+
+// recordAppliedResources stores the amounts added by the resource mutations
+// in AnnotationAppliedResources. With merge, they're added to the amounts
+// already recorded, see WithFollowUp.
+func recordAppliedResources(pipelineRun *tekv1.PipelineRun, mutations []*MutationRequest, merge bool) error {
+	applied := map[string]int{}
+	if data, ok := pipelineRun.Annotations[AnnotationAppliedResources]; ok && merge {
+		// A record which can't be parsed is ignored when reverting, so
+		// it's replaced
+		if err := json.Unmarshal([]byte(data), &applied); err != nil {
+			applied = map[string]int{}
+		}
+	}
+	for _, mutation := range mutations {
+		if mutation.Type != MutationTypeResource {
+			continue
+		}
+		value, err := strconv.Atoi(mutation.Value)
+		if err != nil {
+			return err
+		}
+		applied[mutation.Key] += value
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	// Map keys are sorted, so the value is deterministic
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = make(map[string]string)
+	}
+	pipelineRun.Annotations[AnnotationAppliedResources] = string(data)
+	return nil
+}
@@ -0,0 +1,222 @@
+package cel
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_Mutate_Idempotent(t *testing.T) {
+	tests := []struct {
+		name                string
+		expressions         []string
+		opts                []MutatorOption
+		initialAnnotations  map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:        "new resource annotations",
+			expressions: []string{`[resource("aws-vm-x", 2), resource("aws-vm-x", 4), resource("aws-vm-y", 1)]`},
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "6",
+				"kueue.konflux-ci.dev/requests-aws-vm-y":     "1",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":6,"kueue.konflux-ci.dev/requests-aws-vm-y":1}`,
+			},
+		},
+		{
+			name:        "user-set values are added once",
+			expressions: []string{`resource("aws-vm-x", 2048)`},
+			initialAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1024",
+				"kueue.konflux-ci.dev/requests-aws-vm-z": "3",
+			},
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "3072",
+				"kueue.konflux-ci.dev/requests-aws-vm-z":     "3",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":2048}`,
+			},
+		},
+		{
+			name:        "with key normalization",
+			expressions: []string{`[resource("LINUX-AMD64", 2), resource("linux/amd64", 4)]`},
+			opts:        []MutatorOption{WithResourceKeyNormalization(DefaultKeyNormalizationRules())},
+			initialAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-Linux-AMD64": "1",
+			},
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-linux-amd64":  "7",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-linux-amd64":6}`,
+			},
+		},
+		{
+			name:        "no resource mutations",
+			expressions: []string{`annotation("tekton.dev/pipeline", "test")`},
+			initialAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1",
+			},
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1",
+				"tekton.dev/pipeline":                    "test",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs, tt.opts...)
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pipeline",
+					Namespace:   "test-namespace",
+					Annotations: maps.Clone(tt.initialAnnotations),
+				},
+			}
+
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+
+			// The webhook may be invoked again with the mutated PipelineRun
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+		})
+	}
+}
+
+func TestRevertAppliedResources(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		{
+			name: "subtracts the recorded amounts",
+			annotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "5",
+				"kueue.konflux-ci.dev/requests-aws-vm-y":     "2",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":3,"kueue.konflux-ci.dev/requests-aws-vm-y":2}`,
+			},
+			expected: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "2",
+				"kueue.konflux-ci.dev/requests-aws-vm-y": "0",
+			},
+		},
+		{
+			name: "ignores amounts larger than the current value",
+			annotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "1",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":3}`,
+			},
+			expected: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1",
+			},
+		},
+		{
+			name: "ignores negative amounts",
+			annotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "1",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":-3}`,
+			},
+			expected: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1",
+			},
+		},
+		{
+			name: "ignores missing and invalid annotations",
+			annotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "invalid",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":3,"kueue.konflux-ci.dev/requests-aws-vm-y":2}`,
+			},
+			expected: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "invalid",
+			},
+		},
+		{
+			name: "ignores an invalid record",
+			annotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x":     "1",
+				"kueue.konflux-ci.dev/cel-resources-applied": "invalid",
+			},
+			expected: map[string]string{
+				"kueue.konflux-ci.dev/requests-aws-vm-x": "1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			revertAppliedResources(pipelineRun)
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_Mutate_FollowUp(t *testing.T) {
+	tests := []struct {
+		name                string
+		followUp            string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:     "without resource mutations",
+			followUp: `annotation("team", "a")`,
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-cpu": "1500",
+				"team":                              "a",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-cpu":500}`,
+			},
+		},
+		{
+			name:     "with resource mutations",
+			followUp: `[resource("cpu", 250), resource("memory", 2)]`,
+			expectedAnnotations: map[string]string{
+				"kueue.konflux-ci.dev/requests-cpu":    "1750",
+				"kueue.konflux-ci.dev/requests-memory": "2",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-cpu":750,` +
+					`"kueue.konflux-ci.dev/requests-memory":2}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{`resource("cpu", 500)`})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs)
+			programs, err = CompileCELPrograms([]string{tt.followUp})
+			g.Expect(err).NotTo(HaveOccurred())
+			followUp := NewCELMutator(programs, WithFollowUp())
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pipeline",
+					Namespace:   "test-namespace",
+					Annotations: map[string]string{"kueue.konflux-ci.dev/requests-cpu": "1000"},
+				},
+			}
+
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(followUp.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+
+			// The mutator reverts the amounts of both when the webhook is
+			// invoked again with the mutated PipelineRun
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(followUp.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package celgolden evaluates corpora of CEL expressions against fixture
+// PipelineRuns, and compares the results with golden files. It's shared by
+// the test suites pinning the behavior of the expressions.
+//
+// A corpus is a YAML file listing named expressions:
+//
+//   - name: priority-by-namespace
+//     expression: 'plrNamespace == "production" ? priority("high") : priority("default")'
+//
+// The fixtures are YAML files with a PipelineRun each. The results of the
+// expressions for each fixture are rendered by Render, and compared with the
+// golden file of the fixture by Golden.
+package celgolden
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+// Case is a named expression of a corpus.
+type Case struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+// Fixture is a PipelineRun the expressions are evaluated against, named
+// after its file.
+type Fixture struct {
+	Name        string
+	PipelineRun *tekv1.PipelineRun
+}
+
+// Result is the outcome of the evaluation of a case: the mutations of the
+// expression, or the evaluation error.
+type Result struct {
+	Case      string                 `json:"case"`
+	Mutations []*cel.MutationRequest `json:"mutations,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// LoadCorpus reads the cases of the corpus at path. The names of the cases
+// must be unique.
+func LoadCorpus(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	if err := yaml.UnmarshalStrict(data, &cases); err != nil {
+		return nil, fmt.Errorf("failed to parse corpus %s: %w", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("corpus %s is empty", path)
+	}
+	names := map[string]bool{}
+	for _, c := range cases {
+		if c.Name == "" || names[c.Name] {
+			return nil, fmt.Errorf("corpus %s: case names must be unique and not empty, got %q", path, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return cases, nil
+}
+
+// LoadFixtures reads the PipelineRuns of the YAML files of dir, sorted by
+// name.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	slices.Sort(paths)
+
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pipelineRun := &tekv1.PipelineRun{}
+		if err := yaml.UnmarshalStrict(data, pipelineRun); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+		fixtures = append(fixtures, Fixture{
+			Name:        strings.TrimSuffix(filepath.Base(path), ".yaml"),
+			PipelineRun: pipelineRun,
+		})
+	}
+	return fixtures, nil
+}
+
+// Evaluate evaluates each case against the PipelineRun, which isn't
+// modified. The expressions are compiled with opts, and a compilation
+// failure is returned as an error.
+func Evaluate(cases []Case, pipelineRun *tekv1.PipelineRun, opts ...cel.CompileOption) ([]Result, error) {
+	results := make([]Result, 0, len(cases))
+	for _, c := range cases {
+		programs, err := cel.CompileCELPrograms([]string{c.Expression}, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.Name, err)
+		}
+		result := Result{Case: c.Name}
+		mutations, err := programs[0].Evaluate(pipelineRun.DeepCopy())
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Mutations = mutations
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Render evaluates the cases against the PipelineRun of the fixture, see
+// Evaluate, and returns the results as YAML.
+func Render(cases []Case, fixture Fixture, opts ...cel.CompileOption) ([]byte, error) {
+	results, err := Evaluate(cases, fixture.PipelineRun, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("fixture %s: %w", fixture.Name, err)
+	}
+	return yaml.Marshal(results)
+}
+
+// Golden compares actual with the content of the golden file at path,
+// after writing actual to the file when update is set, and reports the
+// first differing line.
+func Golden(t testing.TB, path string, actual []byte, update bool) {
+	t.Helper()
+	if update {
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatalf("failed to update the golden file: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the golden file, run the tests with -update to create it: %v", err)
+	}
+	if bytes.Equal(actual, expected) {
+		return
+	}
+	actualLines := strings.Split(string(actual), "\n")
+	expectedLines := strings.Split(string(expected), "\n")
+	for i := range max(len(actualLines), len(expectedLines)) {
+		var a, e string
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if i < len(expectedLines) {
+			e = expectedLines[i]
+		}
+		if a != e {
+			t.Errorf("%s differs at line %d:\n  got:  %q\n  want: %q", path, i+1, a, e)
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package cel
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// CircuitBreakerConfig configures the circuit breaker of the programs of a
+// CELMutator.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of failures of a program, within
+	// Window, tripping its circuit breaker.
+	FailureThreshold int
+	// Window is the period over which the failures are counted, and for
+	// which a tripped program is skipped.
+	Window time.Duration
+}
+
+// WithCircuitBreaker skips the programs which failed cfg.FailureThreshold
+// times within cfg.Window, for cfg.Window, instead of failing the
+// admissions. The failures below the threshold still fail the admissions.
+// Tripped and re-enabled programs are logged with log.
+func WithCircuitBreaker(log logr.Logger, cfg CircuitBreakerConfig) MutatorOption {
+	return func(m *CELMutator) {
+		m.breakerConfig = &cfg
+		m.breakerLog = log
+	}
+}
+
+// WithCircuitBreakerState carries the state of the circuit breaker of the
+// programs of previous over to the programs with the same expression, e.g.
+// when the mutator is rebuilt after the configuration changed. The state
+// of the changed and new programs starts empty.
+func WithCircuitBreakerState(previous *CELMutator) MutatorOption {
+	return func(m *CELMutator) {
+		if previous != nil {
+			m.previousBreaker = previous.breaker
+		}
+	}
+}
+
+// breakerState is the state of the circuit breaker of a program.
+type breakerState struct {
+	group      string
+	index      int
+	name       string
+	expression string
+	// failures are the times of the failures within the window, from the
+	// oldest.
+	failures []time.Time
+	// openUntil is the end of the period the program is skipped for.
+	openUntil time.Time
+}
+
+// circuitBreaker tracks the failures of the programs of a CELMutator.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	log      logr.Logger
+	programs []breakerState
+	now      func() time.Time
+}
+
+// newCircuitBreaker creates the circuit breaker of the programs, taking
+// over the state of the programs of previous with the same expression.
+func newCircuitBreaker(programs []*CompiledProgram, cfg CircuitBreakerConfig, log logr.Logger, previous *circuitBreaker) *circuitBreaker {
+	b := &circuitBreaker{config: cfg, log: log, programs: make([]breakerState, len(programs)), now: time.Now}
+	var carried []breakerState
+	if previous != nil {
+		previous.mu.Lock()
+		carried = append(carried, previous.programs...)
+		previous.mu.Unlock()
+		b.now = previous.now
+	}
+	indexes := map[string]int{}
+	for i, program := range programs {
+		state := breakerState{group: program.group, index: indexes[program.group], name: program.name,
+			expression: program.expression}
+		indexes[program.group]++
+		// Each previous state is taken over once, by the first program
+		// with its expression
+		for j, old := range carried {
+			if old.expression == program.expression {
+				state.failures = old.failures
+				state.openUntil = old.openUntil
+				carried = append(carried[:j], carried[j+1:]...)
+				break
+			}
+		}
+		b.programs[i] = state
+	}
+	return b
+}
+
+// allow returns whether the program at index i is evaluated, and counts
+// the skipped evaluations.
+func (b *circuitBreaker) allow(i int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &b.programs[i]
+	if p.openUntil.IsZero() {
+		return true
+	}
+	if b.now().Before(p.openUntil) {
+		RecordExpressionSkipped(p.group, indexLabel(p.index, p.name))
+		return false
+	}
+	p.openUntil = time.Time{}
+	b.log.Info("Re-enabling the CEL expression after its circuit breaker window",
+		"group", p.group, "index", p.index, "name", p.name, "expression", p.expression)
+	return true
+}
+
+// openUntil returns the end of the window the program at index i is
+// skipped for, and whether its circuit breaker is open. Unlike allow, it
+// doesn't change the state of the breaker.
+func (b *circuitBreaker) openUntil(i int) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &b.programs[i]
+	return p.openUntil, b.now().Before(p.openUntil)
+}
+
+// recordFailure records a failure of the program at index i, and trips its
+// circuit breaker when the failures within the window reach the
+// threshold.
+func (b *circuitBreaker) recordFailure(i int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := &b.programs[i]
+	now := b.now()
+	if now.Before(p.openUntil) {
+		// The evaluation started before the breaker was tripped
+		return
+	}
+	p.failures = append(pruneFailures(p.failures, now.Add(-b.config.Window)), now)
+	if len(p.failures) < b.config.FailureThreshold {
+		return
+	}
+	p.failures = nil
+	p.openUntil = now.Add(b.config.Window)
+	b.log.Error(err, "CEL expression failed too often, skipping it until its circuit breaker window ends",
+		"group", p.group, "index", p.index, "name", p.name, "expression", p.expression,
+		"failureThreshold", b.config.FailureThreshold, "window", b.config.Window, "until", p.openUntil)
+}
+
+// pruneFailures drops the failures which happened before start.
+func pruneFailures(failures []time.Time, start time.Time) []time.Time {
+	for len(failures) > 0 && !failures[0].After(start) {
+		failures = failures[1:]
+	}
+	return failures
+}
@@ -0,0 +1,139 @@
+package cel
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// flakyExpression fails for the PipelineRuns without the "team" annotation.
+const flakyExpression = `annotation("owner", pipelineRun.metadata.annotations["team"])`
+
+func newBreakerPipelineRun(team string) *tekv1.PipelineRun {
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	if team != "" {
+		plr.Annotations = map[string]string{"team": team}
+	}
+	return plr
+}
+
+// newBreakerMutator creates a mutator with a circuit breaker tripped by 3
+// failures within a minute, and returns it with its clock.
+func newBreakerMutator(g *WithT, group string, opts ...MutatorOption) (*CELMutator, *time.Time) {
+	programs, err := CompileCELPrograms([]string{`priority("high")`, flakyExpression}, WithGroup(group))
+	g.Expect(err).NotTo(HaveOccurred())
+	opts = append([]MutatorOption{
+		WithCircuitBreaker(logr.Discard(), CircuitBreakerConfig{FailureThreshold: 3, Window: time.Minute}),
+	}, opts...)
+	mutator := NewCELMutator(programs, opts...)
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mutator.breaker.now = func() time.Time { return clock }
+	return mutator, &clock
+}
+
+func TestCELMutator_CircuitBreaker(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	mutator, clock := newBreakerMutator(g, "breaker-test")
+	skippedBefore := testutil.ToFloat64(celExpressionSkippedTotal.WithLabelValues("breaker-test", "1"))
+	skipped := func() float64 {
+		return testutil.ToFloat64(celExpressionSkippedTotal.WithLabelValues("breaker-test", "1")) - skippedBefore
+	}
+
+	// Failures older than the window aren't counted
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	*clock = clock.Add(2 * time.Minute)
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+
+	// The third failure within the window trips the breaker
+	*clock = clock.Add(10 * time.Second)
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+
+	plr := newBreakerPipelineRun("")
+	g.Expect(mutator.Mutate(ctx, plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("kueue.x-k8s.io/priority-class", "high"))
+	g.Expect(plr.Annotations).NotTo(HaveKey("owner"))
+	plr = newBreakerPipelineRun("team-a")
+	g.Expect(mutator.Mutate(ctx, plr)).To(Succeed())
+	g.Expect(plr.Annotations).NotTo(HaveKey("owner"))
+	g.Expect(skipped()).To(Equal(2.0))
+
+	// The expression is evaluated again once the window has passed
+	*clock = clock.Add(time.Minute)
+	plr = newBreakerPipelineRun("team-a")
+	g.Expect(mutator.Mutate(ctx, plr)).To(Succeed())
+	g.Expect(plr.Annotations).To(HaveKeyWithValue("owner", "team-a"))
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	g.Expect(skipped()).To(Equal(2.0))
+
+	// Dry runs ignore the breaker
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).To(Succeed())
+	_, err := mutator.DryRun(newBreakerPipelineRun(""))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCELMutator_CircuitBreaker_Concurrent(t *testing.T) {
+	g := NewWithT(t)
+	mutator, _ := newBreakerMutator(g, "breaker-concurrent-test")
+	skippedCounter := celExpressionSkippedTotal.WithLabelValues("breaker-concurrent-test", "1")
+	skippedBefore := testutil.ToFloat64(skippedCounter)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- mutator.Mutate(context.Background(), newBreakerPipelineRun(""))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	failures := 0
+	for err := range errs {
+		if err != nil {
+			failures++
+		}
+	}
+	// The admissions evaluating the expression before the breaker was
+	// tripped fail, the others skip it
+	skipped := testutil.ToFloat64(skippedCounter) - skippedBefore
+	g.Expect(failures).To(BeNumerically(">=", 3))
+	g.Expect(float64(failures) + skipped).To(Equal(50.0))
+	g.Expect(mutator.Mutate(context.Background(), newBreakerPipelineRun(""))).To(Succeed())
+}
+
+func TestCELMutator_CircuitBreakerState(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	previous, clock := newBreakerMutator(g, "breaker-state-test")
+	for range 3 {
+		g.Expect(previous.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+	}
+
+	// The tripped expression stays skipped when it's unchanged
+	mutator, _ := newBreakerMutator(g, "breaker-state-test", WithCircuitBreakerState(previous))
+	mutator.breaker.now = func() time.Time { return *clock }
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).To(Succeed())
+
+	// Its state is reset when it changes
+	programs, err := CompileCELPrograms([]string{`priority("high")`, "[" + flakyExpression + `, label("x", "y")]`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator = NewCELMutator(programs,
+		WithCircuitBreaker(logr.Discard(), CircuitBreakerConfig{FailureThreshold: 3, Window: time.Minute}),
+		WithCircuitBreakerState(previous),
+	)
+	mutator.breaker.now = func() time.Time { return *clock }
+	g.Expect(mutator.Mutate(ctx, newBreakerPipelineRun(""))).NotTo(Succeed())
+}
@@ -1,69 +1,350 @@
 package cel
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/pkg/resources"
 )
 
 // Annotation values can be up to 256KB and contain any UTF-8 characters
 // The main constraint is the size limit
 const maxAnnotationValueSize = 256 * 1024 // 256KB
 
-// CompileCELPrograms compiles a list of CEL expressions into type-safe programs
-func CompileCELPrograms(expressions []string) ([]*CompiledProgram, error) {
-	if len(expressions) == 0 {
-		return nil, fmt.Errorf("expressions list cannot be empty")
+// CompileOption configures CompileCELPrograms.
+type CompileOption func(*compileOptions)
+
+type compileOptions struct {
+	enforceChecks bool
+	excludeStatus bool
+	group         string
+	queue         string
+	keys          keyValidator
+	envOptions    []cel.EnvOption
+	definitions   map[string]string
+	now           func() time.Time
+	location      *time.Location
+	protectedKeys *ProtectedKeys
+	policy        *MutationPolicy
+}
+
+// DefaultGroup is the group of programs compiled without WithGroup.
+const DefaultGroup = "default"
+
+// WithGroup sets the name of the group the compiled programs belong to. The
+// group is used to label the evaluation metrics.
+func WithGroup(name string) CompileOption {
+	return func(o *compileOptions) {
+		o.group = name
+	}
+}
+
+// WithQueue restricts the compiled programs to the PipelineRuns of the
+// queue: they're skipped for the PipelineRuns whose queue label has another
+// value. The programs are evaluated for all the PipelineRuns by default.
+func WithQueue(name string) CompileOption {
+	return func(o *compileOptions) {
+		o.queue = name
+	}
+}
+
+// QueueGroup returns the group of the per-queue expressions of the queue,
+// used to label their metrics.
+func QueueGroup(queue string) string {
+	return queueGroupPrefix + queue
+}
+
+// queueGroupPrefix is the prefix of the groups of the per-queue
+// expressions.
+const queueGroupPrefix = "queue:"
+
+// WithAllowedKeyPrefixes exempts the annotation keys starting with one of
+// the prefixes from the qualified name validation, e.g. for the keys of
+// several path segments like policies.acme.com/build/verify. Their size is
+// still validated. The label keys are always validated, like the API
+// server does.
+func WithAllowedKeyPrefixes(prefixes []string) CompileOption {
+	return func(o *compileOptions) {
+		o.keys = keyValidator{allowedPrefixes: prefixes}
+	}
+}
+
+// WithEnforcedChecks makes CompileCELPrograms fail for expressions passing
+// user-controlled values to priority() or referencing pipelineRun.status,
+// instead of reporting them through CompiledProgram.GetTaintWarnings and
+// CompiledProgram.GetStatusReferences.
+func WithEnforcedChecks() CompileOption {
+	return func(o *compileOptions) {
+		o.enforceChecks = true
+	}
+}
+
+// WithExcludeStatus removes the status from the pipelineRun variable, so
+// has(pipelineRun.status) is false. The status is empty when PipelineRuns are
+// admitted, so expressions shouldn't depend on it.
+func WithExcludeStatus() CompileOption {
+	return func(o *compileOptions) {
+		o.excludeStatus = true
+	}
+}
+
+// NamedExpression is a CEL expression with an optional name, which
+// identifies it instead of its index in the errors, the logs and the
+// metrics.
+type NamedExpression struct {
+	Name       string
+	Expression string
+	// OnError are the static mutations returned instead of failing the
+	// evaluation when the expression fails, an empty list ignoring the
+	// failure. The evaluation fails when it's nil.
+	OnError []MutationRequest
+}
+
+// unnamed returns the expressions without names.
+func unnamed(expressions []string) []NamedExpression {
+	named := make([]NamedExpression, 0, len(expressions))
+	for _, expr := range expressions {
+		named = append(named, NamedExpression{Expression: expr})
 	}
+	return named
+}
+
+// CompileCELPrograms compiles a list of CEL expressions into type-safe
+// programs. It fails at the first expression which doesn't compile, without
+// returning any program, with a *CompileError.
+func CompileCELPrograms(expressions []string, opts ...CompileOption) ([]*CompiledProgram, error) {
+	return CompileNamedCELPrograms(unnamed(expressions), opts...)
+}
 
-	env, err := createCELEnvironment()
+// CompileNamedCELPrograms is CompileCELPrograms for the named expressions.
+func CompileNamedCELPrograms(expressions []NamedExpression, opts ...CompileOption) ([]*CompiledProgram, error) {
+	env, options, err := newCompilation(len(expressions), opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+		return nil, err
 	}
 
 	programs := make([]*CompiledProgram, 0, len(expressions))
 	for i, expr := range expressions {
-		if expr == "" {
-			return nil, fmt.Errorf("expression %d cannot be empty", i)
+		program, err := compileExpression(env, options, expr)
+		if err != nil {
+			return nil, &CompileError{Index: i, Name: expr.Name, Expression: expr.Expression, Err: err}
 		}
+		programs = append(programs, program)
+	}
+
+	return programs, nil
+}
+
+// CompileCELProgramsLenient compiles all the expressions, unlike
+// CompileCELPrograms, and returns the programs which compiled, in order,
+// along with an error joining the failures of the other expressions. Each
+// failure gives the index and the first line of the expression.
+func CompileCELProgramsLenient(expressions []string, opts ...CompileOption) ([]*CompiledProgram, error) {
+	return CompileNamedCELProgramsLenient(unnamed(expressions), opts...)
+}
 
-		program, err := compileSingleExpression(env, expr)
+// CompileNamedCELProgramsLenient is CompileCELProgramsLenient for the named
+// expressions, whose failures give the name instead of the index.
+func CompileNamedCELProgramsLenient(expressions []NamedExpression, opts ...CompileOption) ([]*CompiledProgram, error) {
+	env, options, err := newCompilation(len(expressions), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	programs := make([]*CompiledProgram, 0, len(expressions))
+	var errs []error
+	for i, expr := range expressions {
+		program, err := compileExpression(env, options, expr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to compile expression %d (%q): %w", i, expr, err)
+			errs = append(errs, &ExpressionError{Index: i, Name: expr.Name, Expression: expr.Expression, Err: err})
+			continue
 		}
 		programs = append(programs, program)
 	}
 
-	return programs, nil
+	return programs, errors.Join(errs...)
+}
+
+// ExpressionError is the failure of an expression returned by
+// CompileCELProgramsLenient.
+type ExpressionError struct {
+	Index int
+	// Name is the name of the expression, if any
+	Name       string
+	Expression string
+	Err        error
+}
+
+func (e *ExpressionError) Error() string {
+	ref := expressionRef(e.Index, e.Name)
+	line, _, multiline := strings.Cut(strings.TrimSpace(e.Expression), "\n")
+	if line == "" {
+		return fmt.Sprintf("expression %s: %v", ref, e.Err)
+	}
+	if multiline {
+		line += " ..."
+	}
+	return fmt.Sprintf("expression %s (%s): %v", ref, line, e.Err)
+}
+
+func (e *ExpressionError) Unwrap() error {
+	return e.Err
+}
+
+// expressionRef identifies an expression in the messages: by its quoted
+// name, or by its index when it has no name.
+func expressionRef(index int, name string) string {
+	if name != "" {
+		return "'" + name + "'"
+	}
+	return strconv.Itoa(index)
+}
+
+// newCompilation checks the expressions list, and creates the environment
+// and options of their compilation.
+func newCompilation(count int, opts []CompileOption) (*cel.Env, *compileOptions, error) {
+	if count == 0 {
+		return nil, nil, fmt.Errorf("expressions list cannot be empty")
+	}
+
+	options := &compileOptions{group: DefaultGroup}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	env, err := newCELEnvironment(options.keys, options.envOptions...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	return env, options, nil
+}
+
+// errEmptyExpression is the failure of the empty expressions.
+var errEmptyExpression = errors.New("cannot be empty")
+
+// rejectedError is the failure of the expressions rejected by the enforced
+// checks.
+type rejectedError struct {
+	reason string
+}
+
+func (e *rejectedError) Error() string {
+	return "rejected: " + e.reason
+}
+
+// compileExpression compiles the expression, once its references to the
+// definitions are expanded, and applies the options to the program. The
+// errors don't repeat the expression, but the expanded one.
+func compileExpression(env *cel.Env, options *compileOptions, named NamedExpression) (*CompiledProgram, error) {
+	expr := named.Expression
+	if expr == "" {
+		return nil, errEmptyExpression
+	}
+
+	var definitions []string
+	if len(options.definitions) > 0 {
+		expanded, used, err := expandDefinitions(expr, options.definitions)
+		if err != nil {
+			return nil, err
+		}
+		expr, definitions = expanded, used
+	}
+
+	program, err := compileSingleExpression(env, expr, options.keys)
+	if err != nil && len(definitions) > 0 {
+		return nil, fmt.Errorf("expanded with the definitions %s to %q: %w", strings.Join(definitions, ", "), expr, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if options.enforceChecks && len(program.taintWarnings) > 0 {
+		return nil, &rejectedError{reason: program.taintWarnings[0].String()}
+	}
+	if options.enforceChecks && len(program.statusReferences) > 0 {
+		return nil, &rejectedError{reason: fmt.Sprintf(
+			"it references %s, but the status is empty when PipelineRuns are admitted",
+			strings.Join(program.statusReferences, ", "))}
+	}
+	if mutationType, key, ok := program.protectedLiteralKey(options.protectedKeys); ok {
+		return nil, &rejectedError{reason: fmt.Sprintf("it sets the protected %s %q", mutationType, key)}
+	}
+	if named.OnError != nil {
+		program.onError = make([]*MutationRequest, 0, len(named.OnError))
+	}
+	for i := range named.OnError {
+		mutation := named.OnError[i]
+		// The keys and values are validated like the ones passed to the
+		// mutation functions
+		if err := mutation.ValidateProtected(options.protectedKeys); err != nil {
+			return nil, fmt.Errorf("invalid onError mutation at index %d: %w", i, err)
+		}
+		if mutation.Type == MutationTypeResource {
+			resolved, err := newResourceOnErrorMutation(mutation)
+			if err != nil {
+				return nil, fmt.Errorf("invalid onError mutation at index %d: %w", i, err)
+			}
+			mutation = resolved
+		} else if _, err := newKeyValueMutation(mutation.Type, options.keys, mutation.Key, mutation.Value); err != nil {
+			return nil, fmt.Errorf("invalid onError mutation at index %d: %w", i, err)
+		}
+		if err := options.policy.check(&mutation); err != nil {
+			return nil, fmt.Errorf("onError mutation at index %d denied by the policy: %w", i, err)
+		}
+		program.onError = append(program.onError, &mutation)
+	}
+	program.name = named.Name
+	program.group = options.group
+	program.queue = options.queue
+	program.excludeStatus = options.excludeStatus
+	program.now = options.now
+	program.location = options.location
+	program.protectedKeys = options.protectedKeys
+	program.policy = options.policy
+	return program, nil
 }
 
 // createCELEnvironment sets up a type-safe CEL environment with PipelineRun context
 func createCELEnvironment() (*cel.Env, error) {
-	// Define the MutationRequest type structure for return type validation
-	mutationRequestType := cel.MapType(cel.StringType, cel.AnyType)
+	return newCELEnvironment(keyValidator{})
+}
+
+// newCELEnvironment sets up the environment of createCELEnvironment, whose
+// label and annotation keys are validated by keys, extended by the
+// registered options and extra, see RegisterEnvOption.
+func newCELEnvironment(keys keyValidator, extra ...cel.EnvOption) (*cel.Env, error) {
+	// The variables and functions are declared with their reference, see
+	// GetReference
+	var options []cel.EnvOption
+	for _, variable := range variableDeclarations() {
+		options = append(options, cel.Variable(variable.Name, variable.celType))
+	}
+	for _, function := range functionDeclarations(keys) {
+		options = append(options, function.option)
+	}
+
+	options = append(options, envOptions(extra)...)
+
+	// Enable standard library functions
+	options = append(options, cel.StdLib())
 
 	// Create CEL environment with proper type declarations
-	env, err := cel.NewEnv(
-
-		cel.Variable("pipelineRun", cel.MapType(cel.StringType, cel.AnyType)),
-		cel.Variable("plrNamespace", cel.StringType),
-		cel.Variable("pacEventType", cel.StringType),
-		cel.Variable("pacTestEventType", cel.StringType),
-		// Add type-safe functions for creating MutationRequests
-		createMutationFunction("annotation", MutationTypeAnnotation, mutationRequestType),
-		createMutationFunction("label", MutationTypeLabel, mutationRequestType),
-		createResourceMutationFunction("resource", MutationTypeResource, mutationRequestType),
-		createPriorityMutationFunction("priority", mutationRequestType),
-		// Add string manipulation functions
-		createReplaceFunction("replace"),
-
-		// Enable standard library functions
-		cel.StdLib(),
-	)
+	env, err := cel.NewEnv(options...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create type-safe CEL environment: %w", err)
@@ -72,59 +353,168 @@ func createCELEnvironment() (*cel.Env, error) {
 	return env, nil
 }
 
-// createMutationFunction creates a CEL function for the specified mutation type
-func createMutationFunction(name string, mutationType MutationType, returnType *cel.Type) cel.EnvOption {
+// createMutationFunction creates a CEL function for the specified mutation
+// type. The primary overload takes a string value, the int and bool
+// overloads convert the value to its canonical string form, e.g. 5 to "5"
+// and true to "true", before it's validated. The keys are validated by keys.
+func createMutationFunction(name string, mutationType MutationType, returnType *cel.Type, keys keyValidator) cel.EnvOption {
 	return cel.Function(
 		name,
 		cel.Overload(
 			name+"_string_string_to_mutation",
 			[]*cel.Type{cel.StringType, cel.StringType},
 			returnType,
-			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
-				key, keyOk := lhs.Value().(string)
-				value, valueOk := rhs.Value().(string)
+			cel.BinaryBinding(mutationBinding(name, mutationType, keys, func(val ref.Val) (string, bool) {
+				value, ok := val.Value().(string)
+				return value, ok
+			})),
+		),
+		cel.Overload(
+			name+"_string_int_to_mutation",
+			[]*cel.Type{cel.StringType, cel.IntType},
+			returnType,
+			cel.BinaryBinding(mutationBinding(name, mutationType, keys, func(val ref.Val) (string, bool) {
+				value, ok := val.Value().(int64)
+				return strconv.FormatInt(value, 10), ok
+			})),
+		),
+		cel.Overload(
+			name+"_string_bool_to_mutation",
+			[]*cel.Type{cel.StringType, cel.BoolType},
+			returnType,
+			cel.BinaryBinding(mutationBinding(name, mutationType, keys, func(val ref.Val) (string, bool) {
+				value, ok := val.Value().(bool)
+				return strconv.FormatBool(value), ok
+			})),
+		),
+	)
+}
 
-				if !keyOk || !valueOk {
-					return types.NewErr("%s function requires string arguments", name)
-				}
+// mutationBinding returns the binding of an overload of the function of the
+// mutation type, converting its value argument to a string with toString.
+func mutationBinding(
+	name string,
+	mutationType MutationType,
+	keys keyValidator,
+	toString func(ref.Val) (string, bool),
+) func(lhs, rhs ref.Val) ref.Val {
+	return func(lhs, rhs ref.Val) ref.Val {
+		key, keyOk := lhs.Value().(string)
+		value, valueOk := toString(rhs)
 
-				if key == "" {
-					return types.NewErr("%s key cannot be empty", name)
-				}
+		if !keyOk || !valueOk {
+			return types.NewErr("%s function requires a string key and a string, int or bool value", name)
+		}
 
-				// Validate key based on mutation type
-				var err error
-				switch mutationType {
-				case MutationTypeAnnotation:
-					err = validateKey(key, "annotation")
-				case MutationTypeLabel:
-					err = validateKey(key, "label")
-				}
+		mutationMap, err := newKeyValueMutation(mutationType, keys, key, value)
+		if err != nil {
+			return types.NewErr("%s %v", name, err)
+		}
 
-				if err != nil {
-					return types.NewErr("%s key validation failed: %v", name, err)
-				}
+		return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+	}
+}
+
+// newKeyValueMutation validates the key and the value of the label or
+// annotation and returns the mutation setting it, as a map.
+func newKeyValueMutation(mutationType MutationType, keys keyValidator, key, value string) (map[string]interface{}, error) {
+	if err := validateMutationKey(mutationType, keys, key); err != nil {
+		return nil, err
+	}
+	if err := validateMutationValue(mutationType, value); err != nil {
+		return nil, err
+	}
+
+	// Create strongly-typed MutationRequest structure as map
+	return map[string]interface{}{
+		"type":  string(mutationType),
+		"key":   key,
+		"value": value,
+	}, nil
+}
+
+// validateMutationKey validates the key of the label or annotation.
+func validateMutationKey(mutationType MutationType, keys keyValidator, key string) error {
+	if key == "" {
+		return errors.New("key cannot be empty")
+	}
+
+	// Validate key based on mutation type
+	var err error
+	switch mutationType {
+	case MutationTypeAnnotation:
+		err = keys.validate(key, "annotation")
+	case MutationTypeLabel:
+		// The API server validates the label keys strictly, so the allowed
+		// prefixes don't apply to them
+		err = validateKey(key, "label")
+	}
+
+	if err != nil {
+		return fmt.Errorf("key validation failed: %w", err)
+	}
+	return nil
+}
+
+// validateMutationValue validates the value of the label or annotation.
+func validateMutationValue(mutationType MutationType, value string) error {
+	// Validate value based on mutation type
+	var err error
+	switch mutationType {
+	case MutationTypeAnnotation:
+		err = validateAnnotationValue(value)
+	case MutationTypeLabel:
+		err = validateLabelValue(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("value validation failed: %w", err)
+	}
+	return nil
+}
 
-				// Validate value based on mutation type
-				switch mutationType {
-				case MutationTypeAnnotation:
-					err = validateAnnotationValue(value)
-				case MutationTypeLabel:
-					err = validateLabelValue(value)
+// createBulkMutationFunction creates a CEL function for the specified
+// mutation type taking a map of keys and values, and returning the list of
+// mutations setting each of them, sorted by key. The keys and values are
+// validated like the ones of createMutationFunction, the errors naming the
+// offending key.
+func createBulkMutationFunction(name string, mutationType MutationType, returnType *cel.Type, keys keyValidator) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_map_to_list_mutation",
+			[]*cel.Type{cel.MapType(cel.StringType, cel.StringType)},
+			cel.ListType(returnType),
+			cel.UnaryBinding(func(arg ref.Val) ref.Val {
+				entries, ok := arg.(traits.Mapper)
+				if !ok {
+					return types.NewErr("%s function requires a map of string keys and values", name)
 				}
 
-				if err != nil {
-					return types.NewErr("%s value validation failed: %v", name, err)
+				values := map[string]string{}
+				for it := entries.Iterator(); it.HasNext() == types.True; {
+					keyVal := it.Next()
+					key, keyOk := keyVal.Value().(string)
+					if !keyOk {
+						return types.NewErr("%s function requires string keys, got %s", name, keyVal.Type().TypeName())
+					}
+					value, valueOk := entries.Get(keyVal).Value().(string)
+					if !valueOk {
+						return types.NewErr("%s entry %q requires a string value", name, key)
+					}
+					values[key] = value
 				}
 
-				// Create strongly-typed MutationRequest structure as map
-				mutationMap := map[string]interface{}{
-					"type":  string(mutationType),
-					"key":   key,
-					"value": value,
+				mutations := make([]interface{}, 0, len(values))
+				for _, key := range slices.Sorted(maps.Keys(values)) {
+					mutationMap, err := newKeyValueMutation(mutationType, keys, key, values[key])
+					if err != nil {
+						return types.NewErr("%s entry %q: %v", name, key, err)
+					}
+					mutations = append(mutations, mutationMap)
 				}
 
-				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+				return types.DefaultTypeAdapter.NativeToValue(mutations)
 			}),
 		),
 	)
@@ -145,41 +535,27 @@ func createResourceMutationFunction(name string, mutationType MutationType, retu
 					return types.NewErr("%s function requires string key argument", name)
 				}
 
-				if key == "" {
-					return types.NewErr("%s key cannot be empty", name)
-				}
-
 				intValue, intValueOk := rhs.Value().(int64)
 
 				if !intValueOk {
 					return types.NewErr("%s function requires int value argument", name)
 				}
 
-				// Validate that the value is positive (non-negative)
-				if intValue < 0 {
-					return types.NewErr("%s value must be positive (>= 0), got %d", name, intValue)
+				if err := validateResourceKey(key); err != nil {
+					return types.NewErr("%s %v", name, err)
 				}
-
-				// Convert int value to string for storage
-				value := fmt.Sprintf("%d", intValue)
-
-				// Validate key using annotation validation since resource mutations create annotations
-				err := validateKey(key, "resource annotation")
-				if err != nil {
-					return types.NewErr("%s key validation failed: %v", name, err)
+				if err := validateResourceValue(intValue); err != nil {
+					return types.NewErr("%s %v", name, err)
 				}
 
-				// Validate the converted value using annotation validation
-				err = validateAnnotationValue(value)
-				if err != nil {
-					return types.NewErr("%s value validation failed: %v", name, err)
-				}
+				// Convert int value to string for storage
+				value := strconv.FormatInt(intValue, 10)
 
 				// Create strongly-typed MutationRequest structure as map
 				// Note: This mutation type creates annotations but with special summing behavior for duplicates
 				mutationMap := map[string]interface{}{
 					"type":  string(mutationType),
-					"key":   "kueue.konflux-ci.dev/requests-" + key,
+					"key":   resources.AnnotationKeyFor(key),
 					"value": value,
 				}
 
@@ -189,6 +565,51 @@ func createResourceMutationFunction(name string, mutationType MutationType, retu
 	)
 }
 
+// validateResourceKey validates the name of the resource of a resource
+// mutation.
+func validateResourceKey(key string) error {
+	if key == "" {
+		return errors.New("key cannot be empty")
+	}
+	// Validate key using annotation validation since resource mutations create annotations
+	if err := validateKey(key, "resource annotation"); err != nil {
+		return fmt.Errorf("key validation failed: %w", err)
+	}
+	return nil
+}
+
+// validateResourceValue validates the quantity of a resource mutation.
+func validateResourceValue(value int64) error {
+	// Validate that the value is positive (non-negative)
+	if value < 0 {
+		return fmt.Errorf("value must be positive (>= 0), got %d", value)
+	}
+	// Validate the converted value using annotation validation
+	if err := validateAnnotationValue(strconv.FormatInt(value, 10)); err != nil {
+		return fmt.Errorf("value validation failed: %w", err)
+	}
+	return nil
+}
+
+// newResourceOnErrorMutation validates the resource name and the quantity
+// of a resource onError mutation, like the resource() function, and
+// returns the mutation with the key of the resource annotation.
+func newResourceOnErrorMutation(mutation MutationRequest) (MutationRequest, error) {
+	if err := validateResourceKey(mutation.Key); err != nil {
+		return mutation, err
+	}
+	value, err := strconv.ParseInt(mutation.Value, 10, 64)
+	if err != nil {
+		return mutation, fmt.Errorf("value validation failed: %q isn't an integer", mutation.Value)
+	}
+	if err := validateResourceValue(value); err != nil {
+		return mutation, err
+	}
+	mutation.Key = resources.AnnotationKeyFor(mutation.Key)
+	mutation.Value = strconv.FormatInt(value, 10)
+	return mutation, nil
+}
+
 // createPriorityMutationFunction creates a CEL function for priority mutations with hardcoded key
 func createPriorityMutationFunction(name string, returnType *cel.Type) cel.EnvOption {
 	return cel.Function(
@@ -217,7 +638,211 @@ func createPriorityMutationFunction(name string, returnType *cel.Type) cel.EnvOp
 	)
 }
 
-// createReplaceFunction creates a CEL function for string replacement
+// createTimeoutMutationFunction creates a CEL function for timeout mutations,
+// taking the kind of timeout and a Go duration
+func createTimeoutMutationFunction(name string, returnType *cel.Type) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_string_to_mutation",
+			[]*cel.Type{cel.StringType, cel.StringType},
+			returnType,
+			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
+				kind, kindOk := lhs.Value().(string)
+				value, valueOk := rhs.Value().(string)
+
+				if !kindOk || !valueOk {
+					return types.NewErr("%s function requires string arguments", name)
+				}
+
+				if err := validateTimeout(kind, value); err != nil {
+					return types.NewErr("%s validation failed: %v", name, err)
+				}
+
+				mutationMap := map[string]interface{}{
+					"type":  string(MutationTypeTimeout),
+					"key":   kind,
+					"value": value,
+				}
+
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+			}),
+		),
+	)
+}
+
+// createServiceAccountMutationFunction creates a CEL function for service
+// account mutations, taking the name of the service account
+func createServiceAccountMutationFunction(name string, returnType *cel.Type) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_to_mutation",
+			[]*cel.Type{cel.StringType},
+			returnType,
+			cel.UnaryBinding(func(val ref.Val) ref.Val {
+				value, valueOk := val.Value().(string)
+
+				if !valueOk {
+					return types.NewErr("%s function requires string argument", name)
+				}
+
+				if err := validateServiceAccount(value); err != nil {
+					return types.NewErr("%s validation failed: %v", name, err)
+				}
+
+				mutationMap := map[string]interface{}{
+					"type":  string(MutationTypeServiceAccount),
+					"key":   ServiceAccountKey,
+					"value": value,
+				}
+
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+			}),
+		),
+	)
+}
+
+// createNodeSelectorMutationFunction creates a CEL function for node
+// selector mutations, taking the key and the value of the node label
+func createNodeSelectorMutationFunction(name string, returnType *cel.Type) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_string_to_mutation",
+			[]*cel.Type{cel.StringType, cel.StringType},
+			returnType,
+			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
+				key, keyOk := lhs.Value().(string)
+				value, valueOk := rhs.Value().(string)
+
+				if !keyOk || !valueOk {
+					return types.NewErr("%s function requires string arguments", name)
+				}
+
+				if err := validateNodeSelector(key, value); err != nil {
+					return types.NewErr("%s validation failed: %v", name, err)
+				}
+
+				mutationMap := map[string]interface{}{
+					"type":  string(MutationTypeNodeSelector),
+					"key":   key,
+					"value": value,
+				}
+
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+			}),
+		),
+	)
+}
+
+// createAppendAnnotationMutationFunction creates a CEL function for append
+// annotation mutations, taking the key, the value and the separator of the
+// entries of the annotation
+func createAppendAnnotationMutationFunction(name string, returnType *cel.Type, keys keyValidator) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_string_string_to_mutation",
+			[]*cel.Type{cel.StringType, cel.StringType, cel.StringType},
+			returnType,
+			cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				if len(args) != 3 {
+					return types.NewErr("%s function requires exactly 3 arguments", name)
+				}
+
+				key, keyOk := args[0].Value().(string)
+				value, valueOk := args[1].Value().(string)
+				separator, separatorOk := args[2].Value().(string)
+
+				if !keyOk || !valueOk || !separatorOk {
+					return types.NewErr("%s function requires string arguments", name)
+				}
+
+				if err := keys.validate(key, "annotation"); err != nil {
+					return types.NewErr("%s key validation failed: %v", name, err)
+				}
+
+				if value == "" {
+					return types.NewErr("%s value cannot be empty", name)
+				}
+				if err := validateAnnotationValue(value); err != nil {
+					return types.NewErr("%s value validation failed: %v", name, err)
+				}
+
+				if separator == "" {
+					return types.NewErr("%s separator cannot be empty", name)
+				}
+
+				mutationMap := map[string]interface{}{
+					"type":      string(MutationTypeAppendAnnotation),
+					"key":       key,
+					"value":     value,
+					"separator": separator,
+				}
+
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+			}),
+		),
+	)
+}
+
+// createPodSetMutationFunction creates a CEL function for pod set mutations,
+// taking the name, the count and the requests of each pod of the pod set.
+// The value of the mutation is the JSON of the pod set.
+func createPodSetMutationFunction(name string, returnType *cel.Type) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_int_map_to_mutation",
+			[]*cel.Type{cel.StringType, cel.IntType, cel.MapType(cel.StringType, cel.StringType)},
+			returnType,
+			cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				if len(args) != 3 {
+					return types.NewErr("%s function requires exactly 3 arguments", name)
+				}
+
+				podSetName, nameOk := args[0].Value().(string)
+				count, countOk := args[1].Value().(int64)
+				if !nameOk || !countOk {
+					return types.NewErr("%s function requires a string name and an int count", name)
+				}
+				if count > math.MaxInt32 {
+					return types.NewErr("%s count is too large, got %d", name, count)
+				}
+				requests, err := args[2].ConvertToNative(reflect.TypeOf(map[string]string{}))
+				if err != nil {
+					return types.NewErr("%s function requires a map of string requests: %v", name, err)
+				}
+
+				podSet := common.PodSetSpec{
+					Name:     podSetName,
+					Count:    int32(count),
+					Requests: requests.(map[string]string),
+				}
+				if err := podSet.Validate(); err != nil {
+					return types.NewErr("%s validation failed: %v", name, err)
+				}
+				value, err := json.Marshal(podSet)
+				if err != nil {
+					return types.NewErr("%s failed to serialize the pod set: %v", name, err)
+				}
+
+				mutationMap := map[string]interface{}{
+					"type":  string(MutationTypePodSet),
+					"key":   podSetName,
+					"value": string(value),
+				}
+
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, mutationMap)
+			}),
+		),
+	)
+}
+
+// createReplaceFunction creates a CEL function for string replacement. The
+// overload with a count replaces only the first n occurrences, all of them
+// when n is negative.
 func createReplaceFunction(name string) cel.EnvOption {
 	return cel.Function(
 		name,
@@ -242,6 +867,89 @@ func createReplaceFunction(name string) cel.EnvOption {
 				return types.String(result)
 			}),
 		),
+		cel.Overload(
+			name+"_string_string_string_int_to_string",
+			[]*cel.Type{cel.StringType, cel.StringType, cel.StringType, cel.IntType},
+			cel.StringType,
+			cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				if len(args) != 4 {
+					return types.NewErr("%s function requires exactly 4 arguments", name)
+				}
+
+				source, sourceOk := args[0].Value().(string)
+				search, searchOk := args[1].Value().(string)
+				replacement, replacementOk := args[2].Value().(string)
+				n, nOk := args[3].Value().(int64)
+
+				if !sourceOk || !searchOk || !replacementOk || !nOk {
+					return types.NewErr("%s function requires string arguments and an int count", name)
+				}
+				// There are at most len(source)+1 occurrences, larger
+				// counts would overflow int on 32-bit platforms
+				if n < 0 || n > int64(len(source))+1 {
+					n = -1
+				}
+
+				result := strings.Replace(source, search, replacement, int(n))
+				return types.String(result)
+			}),
+		),
+	)
+}
+
+// createSplitFunction creates a CEL function splitting a string around a
+// separator. The separator can't be empty, so the result always has at
+// least one element.
+func createSplitFunction(name string) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_string_to_list_string",
+			[]*cel.Type{cel.StringType, cel.StringType},
+			cel.ListType(cel.StringType),
+			cel.BinaryBinding(func(lhs, rhs ref.Val) ref.Val {
+				source, sourceOk := lhs.Value().(string)
+				separator, separatorOk := rhs.Value().(string)
+
+				if !sourceOk || !separatorOk {
+					return types.NewErr("%s function requires string arguments", name)
+				}
+				if separator == "" {
+					return types.NewErr("%s separator cannot be empty", name)
+				}
+
+				return types.NewStringList(types.DefaultTypeAdapter, strings.Split(source, separator))
+			}),
+		),
+	)
+}
+
+// createQuantityFunction creates a CEL function parsing a Kubernetes resource
+// quantity string (e.g. "4Gi" or "1500m") into an integer using convert
+func createQuantityFunction(name string, convert func(resource.Quantity) int64) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_to_int",
+			[]*cel.Type{cel.StringType},
+			cel.IntType,
+			cel.UnaryBinding(func(val ref.Val) ref.Val {
+				value, valueOk := val.Value().(string)
+				if !valueOk {
+					return types.NewErr("%s function requires string argument", name)
+				}
+
+				quantity, err := resource.ParseQuantity(value)
+				if err != nil {
+					return types.NewErr("%s failed to parse quantity %q: %v", name, value, err)
+				}
+				if quantity.Sign() < 0 {
+					return types.NewErr("%s quantity must be positive (>= 0), got %q", name, value)
+				}
+
+				return types.Int(convert(quantity))
+			}),
+		),
 	)
 }
 
@@ -267,29 +975,45 @@ func validateExpressionReturnType(ast *cel.Ast) error {
 	return nil
 }
 
-// compileSingleExpression compiles a single CEL expression with comprehensive type checking
-func compileSingleExpression(env *cel.Env, expression string) (*CompiledProgram, error) {
+// interruptCheckFrequency is the number of iterations of the comprehensions
+// between the checks of the cancellation of the context of the evaluation.
+const interruptCheckFrequency = 100
+
+// compileSingleExpression compiles a single CEL expression with comprehensive type checking,
+// validating the literal arguments of the mutation functions with keys
+func compileSingleExpression(env *cel.Env, expression string, keys keyValidator) (*CompiledProgram, error) {
 	// Parse the expression with type checking
 	ast, issues := env.Compile(expression)
 	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("type checking failed for expression %q: %w", expression, issues.Err())
+		return nil, fmt.Errorf("type checking failed: %w", issues.Err())
 	}
 
 	// Validate the output type matches our expected return types
 	if err := validateExpressionReturnType(ast); err != nil {
-		return nil, fmt.Errorf("invalid return type for expression %q: %w", expression, err)
+		return nil, fmt.Errorf("invalid return type: %w", err)
+	}
+	if err := checkVariables(env, ast.NativeRep()); err != nil {
+		return nil, err
+	}
+	if err := validateLiteralArguments(ast.NativeRep(), keys); err != nil {
+		return nil, err
 	}
 
-	// Create the program
-	program, err := env.Program(ast)
+	// Create the program, interruptible by the context of ContextEval
+	program, err := env.Program(ast, cel.InterruptCheckFrequency(interruptCheckFrequency))
 	if err != nil {
-		return nil, fmt.Errorf("program creation failed for expression %q: %w", expression, err)
+		return nil, fmt.Errorf("program creation failed: %w", err)
 	}
 
+	taintWarnings, statusReferences := analyzeExpression(ast)
 	return &CompiledProgram{
-		program:    program,
-		ast:        ast,
-		expression: expression,
+		program:            program,
+		ast:                ast,
+		expression:         expression,
+		taintWarnings:      taintWarnings,
+		statusReferences:   statusReferences,
+		referencesIdentity: referencesIdentity(ast.NativeRep()),
+		referencesTime:     referencesTime(ast.NativeRep()),
 	}, nil
 }
 
@@ -309,6 +1033,35 @@ func validateKey(key, keyType string) error {
 	return nil
 }
 
+// maxKeyNameLength is the maximum length of the name part of the qualified
+// names, after the prefix.
+const maxKeyNameLength = 63
+
+// keyValidator validates the annotation keys, see validateKey, except for
+// the ones starting with an allowed prefix, whose size only is validated.
+type keyValidator struct {
+	allowedPrefixes []string
+}
+
+// validate validates the key, keyType being used for error messages.
+func (v keyValidator) validate(key, keyType string) error {
+	if !slices.ContainsFunc(v.allowedPrefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+		return validateKey(key, keyType)
+	}
+	// The prefix and the name of the key keep the sizes of the qualified
+	// names, even though the name may contain slashes
+	prefix, name, _ := strings.Cut(key, "/")
+	if len(prefix) > validation.DNS1123SubdomainMaxLength {
+		return fmt.Errorf("%s key '%s' is invalid: prefix must be no more than %d characters",
+			keyType, key, validation.DNS1123SubdomainMaxLength)
+	}
+	if name == "" || len(name) > maxKeyNameLength {
+		return fmt.Errorf("%s key '%s' is invalid: name part must be non-empty and no more than %d characters",
+			keyType, key, maxKeyNameLength)
+	}
+	return nil
+}
+
 // validateLabelValue validates that a label value conforms to Kubernetes constraints
 func validateLabelValue(value string) error {
 	// Use official Kubernetes validation for label values
@@ -1,11 +1,14 @@
 package cel
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/google/cel-go/cel"
 	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 )
 
 const (
@@ -37,6 +40,48 @@ func TestCompileCELPrograms_TypeSafety(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "int and bool values",
+			expressions: []string{
+				`annotation("retry-count", 3)`,
+				`annotation("retried", false)`,
+				`label("count", 5)`,
+				`label("enabled", true)`,
+				`has(pipelineRun.spec.params) ? [label("params", size(pipelineRun.spec.params))] : []`,
+			},
+			expectErr: false,
+		},
+		{
+			name: "bulk label and annotation maps",
+			expressions: []string{
+				`labels({})`,
+				`labels({"app": "tekton-pipeline", "env": plrNamespace})`,
+				`annotations({"owner": "team-a"}) + [priority("high")]`,
+			},
+			expectErr: false,
+		},
+		{
+			name: "type error - bulk map with int values",
+			expressions: []string{
+				`labels({"a": 1})`, // only string values are accepted
+			},
+			expectErr: true,
+			errMsg:    "failed to compile expression 0",
+		},
+		{
+			name: "type error - bulk list argument",
+			expressions: []string{
+				`annotations(["owner", "team-a"])`,
+			},
+			expectErr: true,
+		},
+		{
+			name: "type error - double value",
+			expressions: []string{
+				`label("ratio", 0.5)`, // only string, int and bool values are converted
+			},
+			expectErr: true,
+		},
 		{
 			name:        "empty expressions list",
 			expressions: []string{},
@@ -120,6 +165,66 @@ func TestCompileCELPrograms_TypeSafety(t *testing.T) {
 	}
 }
 
+func TestCompileCELPrograms_StopsAtFirstFailure(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`priority("high")`,
+		`annotation(123, "test")`,
+		`resource("test")`,
+	})
+	g.Expect(programs).To(BeNil())
+	g.Expect(err).To(MatchError(ContainSubstring("failed to compile expression 1")))
+	g.Expect(err.Error()).NotTo(ContainSubstring("expression 2"))
+}
+
+func TestCompileCELProgramsLenient(t *testing.T) {
+	g := NewWithT(t)
+
+	expressions := []string{
+		`priority("high")`,
+		`annotation(123, "test")`,
+		"",
+		`label("env", "production")`,
+		"[\n  resource(\"test\"),\n  priority(\"low\")\n]",
+		`priority(pipelineRun.metadata.annotations["x"])`,
+	}
+	programs, err := CompileCELProgramsLenient(expressions, WithEnforcedChecks(), WithGroup("lenient"))
+
+	g.Expect(programs).To(HaveLen(2))
+	g.Expect(programs[0].GetExpression()).To(Equal(expressions[0]))
+	g.Expect(programs[1].GetExpression()).To(Equal(expressions[3]))
+	g.Expect(programs).To(HaveEach(HaveField("GetGroup()", "lenient")))
+
+	g.Expect(err).To(HaveOccurred())
+	lines := strings.Split(err.Error(), "\n")
+	g.Expect(lines).To(ContainElements(
+		HavePrefix(`expression 1 (annotation(123, "test")): type checking failed: `+
+			`ERROR: <input>:1:11: found no matching overload for 'annotation' applied to '(int, string)'`),
+		Equal("expression 2: cannot be empty"),
+		HavePrefix(`expression 4 ([ ...): type checking failed: `+
+			`ERROR: <input>:2:11: found no matching overload for 'resource' applied to '(string)'`),
+		Equal(`expression 5 (priority(pipelineRun.metadata.annotations["x"])): rejected: `+
+			`the argument of priority() is derived from the user-controlled value pipelineRun.metadata.annotations["x"]`),
+	))
+
+	var failures []int
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var exprErr *ExpressionError
+		g.Expect(errors.As(err, &exprErr)).To(BeTrue())
+		failures = append(failures, exprErr.Index)
+	}
+	g.Expect(failures).To(Equal([]int{1, 2, 4, 5}))
+}
+
+func TestCompileCELProgramsLenient_AllValid(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELProgramsLenient([]string{`priority("high")`, `label("env", "production")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(programs).To(HaveLen(2))
+}
+
 func TestValidateExpressionReturnType_ValidCases(t *testing.T) {
 	g := NewWithT(t)
 
@@ -280,6 +385,21 @@ func TestReplaceFunction(t *testing.T) {
 			expression: `replace("old", "old", "new")`,
 			expected:   "new",
 		},
+		{
+			name:       "replace first occurrence",
+			expression: `replace("hello world hello", "hello", "hi", 1)`,
+			expected:   "hi world hello",
+		},
+		{
+			name:       "replace more occurrences than found",
+			expression: `replace("a-b-c", "-", "", 9223372036854775807)`,
+			expected:   "abc",
+		},
+		{
+			name:       "replace all occurrences with negative count",
+			expression: `replace("a-b-c", "-", "", -1)`,
+			expected:   "abc",
+		},
 	}
 
 	for _, tt := range tests {
@@ -304,6 +424,60 @@ func TestReplaceFunction(t *testing.T) {
 	}
 }
 
+func TestStringFunctionSignatures(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		outputType *cel.Type
+		compileErr string
+	}{
+		{
+			name:       "split returns a list of strings",
+			expression: `split("quay.io/konflux-ci/build", "/")`,
+			outputType: cel.ListType(cel.StringType),
+		},
+		{
+			name:       "split element is a string",
+			expression: `split("quay.io/konflux-ci/build", "/")[0]`,
+			outputType: cel.StringType,
+		},
+		{
+			name:       "replace with count returns a string",
+			expression: `replace("linux/amd64", "/", "-", 1)`,
+			outputType: cel.StringType,
+		},
+		{
+			name:       "split with int separator",
+			expression: `split("quay.io/build", 1)`,
+			compileErr: "found no matching overload for 'split'",
+		},
+		{
+			name:       "replace with string count",
+			expression: `replace("linux/amd64", "/", "-", "1")`,
+			compileErr: "found no matching overload for 'replace'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			if tt.compileErr != "" {
+				g.Expect(issues.Err()).To(MatchError(ContainSubstring(tt.compileErr)))
+				return
+			}
+			g.Expect(issues.Err()).NotTo(HaveOccurred())
+			g.Expect(ast.OutputType()).To(Equal(tt.outputType))
+		})
+	}
+}
+
 func TestKubernetesKeyValidation(t *testing.T) {
 	g := NewWithT(t)
 
@@ -690,6 +864,266 @@ func TestResourceFunction_ErrorCases(t *testing.T) {
 	}
 }
 
+func TestTimeoutFunction_ErrorCases(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "invalid kind",
+			expression: `timeout("tasks-and-finally", "1h")`,
+			errorMsg:   `invalid timeout kind "tasks-and-finally"`,
+		},
+		{
+			name:       "duration without unit",
+			expression: `timeout("pipeline", "30")`,
+			errorMsg:   "invalid pipeline timeout",
+		},
+		{
+			name:       "empty duration",
+			expression: `timeout("finally", "")`,
+			errorMsg:   "invalid finally timeout",
+		},
+		{
+			name:       "negative duration",
+			expression: `timeout("tasks", "-10m")`,
+			errorMsg:   "-10m is negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			_, _, err = program.Eval(map[string]interface{}{})
+			g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+			g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+		})
+	}
+}
+
+func TestTaskRunTemplateFunctions_ErrorCases(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "empty service account",
+			expression: `serviceAccount("")`,
+			errorMsg:   `invalid service account name ""`,
+		},
+		{
+			name:       "invalid service account",
+			expression: `serviceAccount("Release_Pipeline")`,
+			errorMsg:   `invalid service account name "Release_Pipeline"`,
+		},
+		{
+			name:       "invalid node selector key",
+			expression: `nodeSelector("kubernetes.io/", "arm64")`,
+			errorMsg:   "node selector key 'kubernetes.io/' is invalid",
+		},
+		{
+			name:       "invalid node selector value",
+			expression: `nodeSelector("kubernetes.io/arch", "arm 64")`,
+			errorMsg:   "label value 'arm 64' is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			_, _, err = program.Eval(map[string]interface{}{})
+			g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+			g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+		})
+	}
+}
+
+func TestAppendAnnotationFunction_ErrorCases(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "invalid key",
+			expression: `appendAnnotation("invalid key", "linux-amd64", ",")`,
+			errorMsg:   "appendAnnotation key validation failed",
+		},
+		{
+			name:       "empty value",
+			expression: `appendAnnotation("platforms", "", ",")`,
+			errorMsg:   "appendAnnotation value cannot be empty",
+		},
+		{
+			name:       "empty separator",
+			expression: `appendAnnotation("platforms", "linux-amd64", "")`,
+			errorMsg:   "appendAnnotation separator cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			_, _, err = program.Eval(map[string]interface{}{})
+			g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+			g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+		})
+	}
+}
+
+func TestBulkMutationFunctions_ErrorCases(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "invalid label key",
+			expression: `labels({"env": "prod", "invalid key": "value"})`,
+			errorMsg:   `labels entry "invalid key": key validation failed: label key 'invalid key' is invalid`,
+		},
+		{
+			name:       "invalid label value",
+			expression: `labels({"env": "prod", "team": "invalid value!"})`,
+			errorMsg:   `labels entry "team": value validation failed: label value 'invalid value!' is invalid`,
+		},
+		{
+			name:       "empty key",
+			expression: `annotations({"": "value"})`,
+			errorMsg:   `annotations entry "": key cannot be empty`,
+		},
+		{
+			name:       "invalid annotation key",
+			expression: `annotations({"owner": "team-a", "-owner": "team-b"})`,
+			errorMsg:   `annotations entry "-owner": key validation failed: annotation key '-owner' is invalid`,
+		},
+		{
+			name:       "dynamic non-string value",
+			expression: `labels({"env": "prod", "count": dyn(1)})`,
+			errorMsg:   `labels entry "count" requires a string value`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			_, _, err = program.Eval(map[string]interface{}{})
+			g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+			g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+		})
+	}
+}
+
+func TestPodSetFunction_ErrorCases(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "invalid name",
+			expression: `podset("ARM builders", 1, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			errorMsg:   "invalid pod set name",
+		},
+		{
+			name:       "reserved name",
+			expression: `podset("pod-set-1", 1, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			errorMsg:   "is reserved",
+		},
+		{
+			name:       "zero count",
+			expression: `podset("arm-builders", 0, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			errorMsg:   "must be positive",
+		},
+		{
+			name:       "count too large",
+			expression: `podset("arm-builders", 4294967296, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			errorMsg:   "podset count is too large",
+		},
+		{
+			name:       "invalid quantity",
+			expression: `podset("arm-builders", 1, {"kueue.konflux-ci.dev/linux-arm64": "one"})`,
+			errorMsg:   "invalid request of kueue.konflux-ci.dev/linux-arm64",
+		},
+		{
+			name:       "negative quantity",
+			expression: `podset("arm-builders", 1, {"cpu": "-1"})`,
+			errorMsg:   "must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			_, _, err = program.Eval(map[string]interface{}{})
+			g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+			g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+		})
+	}
+}
+
 func TestResourceFunctionIntegration(t *testing.T) {
 	g := NewWithT(t)
 
@@ -728,3 +1162,126 @@ func TestResourceFunctionIntegration(t *testing.T) {
 	g.Expect(err).NotTo(HaveOccurred(), "All expressions should compile successfully")
 	g.Expect(programs).To(HaveLen(3), "Should have compiled 3 programs")
 }
+
+func TestQuantityFunctions(t *testing.T) {
+	g := NewWithT(t)
+
+	// Create a CEL environment for testing
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   int64
+		errorMsg   string
+	}{
+		{
+			name:       "bytes from binary suffix",
+			expression: `quantityToBytes("4Gi")`,
+			expected:   4 * 1024 * 1024 * 1024,
+		},
+		{
+			name:       "bytes from decimal suffix",
+			expression: `quantityToBytes("2G")`,
+			expected:   2 * 1000 * 1000 * 1000,
+		},
+		{
+			name:       "bytes from plain integer",
+			expression: `quantityToBytes("1024")`,
+			expected:   1024,
+		},
+		{
+			name:       "bytes are rounded up",
+			expression: `quantityToBytes("1500m")`,
+			expected:   2,
+		},
+		{
+			name:       "milli from milli value",
+			expression: `quantityToMilli("1500m")`,
+			expected:   1500,
+		},
+		{
+			name:       "milli from plain integer",
+			expression: `quantityToMilli("2")`,
+			expected:   2000,
+		},
+		{
+			name:       "milli from decimal value",
+			expression: `quantityToMilli("0.5")`,
+			expected:   500,
+		},
+		{
+			name:       "milli from binary suffix",
+			expression: `quantityToMilli("1Ki")`,
+			expected:   1024 * 1000,
+		},
+		{
+			name:       "usable as a resource value",
+			expression: `quantityToBytes("8Gi") / 1073741824`,
+			expected:   8,
+		},
+		{
+			name:       "negative bytes",
+			expression: `quantityToBytes("-1Gi")`,
+			errorMsg:   `quantityToBytes quantity must be positive (>= 0), got "-1Gi"`,
+		},
+		{
+			name:       "negative milli",
+			expression: `quantityToMilli("-100m")`,
+			errorMsg:   `quantityToMilli quantity must be positive (>= 0), got "-100m"`,
+		},
+		{
+			name:       "invalid quantity",
+			expression: `quantityToBytes("four gigs")`,
+			errorMsg:   `quantityToBytes failed to parse quantity "four gigs"`,
+		},
+		{
+			name:       "empty quantity",
+			expression: `quantityToMilli("")`,
+			errorMsg:   `quantityToMilli failed to parse quantity ""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// Compile the expression
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			// Create program and evaluate
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			// Evaluate the expression
+			result, _, err := program.Eval(map[string]interface{}{})
+			if tt.errorMsg != "" {
+				g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+				g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred(), "Evaluation should succeed")
+			g.Expect(result.Value()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestQuantityFunctions_WithResource(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`resource("memory-units", quantityToBytes("4Gi") / 1073741824)`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mutations, err := programs[0].Evaluate(&tekv1.PipelineRun{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(&MutationRequest{
+		Type:  MutationTypeResource,
+		Key:   ResourceAnnotationPrefix + "memory-units",
+		Value: "4",
+	}))
+}
@@ -0,0 +1,68 @@
+package cel
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// definitionReference matches the references to the definitions in the
+// expressions, ${name}.
+var definitionReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// WithDefinitions replaces the ${name} references in the expressions by the
+// sub-expression of the definition name, in parentheses, before they're
+// compiled. The definitions can reference other definitions. The unknown
+// names and the cycles fail the compilation, and the compilation errors of
+// the expanded expressions report the expanded expression and the
+// definitions it uses. The programs hold the expanded expressions.
+func WithDefinitions(definitions map[string]string) CompileOption {
+	return func(o *compileOptions) {
+		o.definitions = definitions
+	}
+}
+
+// expandDefinitions returns the expression with its references to the
+// definitions replaced, and the names of the definitions used, sorted.
+func expandDefinitions(expression string, definitions map[string]string) (string, []string, error) {
+	used := map[string]bool{}
+	expanded, err := expandReferences(expression, definitions, nil, used)
+	if err != nil {
+		return "", nil, err
+	}
+	return expanded, slices.Sorted(maps.Keys(used)), nil
+}
+
+// expandReferences replaces the references of text, recursively, stack
+// being the definitions being expanded.
+func expandReferences(text string, definitions map[string]string, stack []string, used map[string]bool) (string, error) {
+	var err error
+	expanded := definitionReference.ReplaceAllStringFunc(text, func(reference string) string {
+		if err != nil {
+			return reference
+		}
+		name := definitionReference.FindStringSubmatch(reference)[1]
+		definition, ok := definitions[name]
+		if !ok {
+			err = fmt.Errorf("unknown definition %q", name)
+			if len(stack) > 0 {
+				err = fmt.Errorf("unknown definition %q, referenced by the definition %q", name, stack[len(stack)-1])
+			}
+			return reference
+		}
+		if slices.Contains(stack, name) {
+			err = fmt.Errorf("cycle in the definitions: %s -> %s", strings.Join(stack, " -> "), name)
+			return reference
+		}
+		used[name] = true
+		var inner string
+		inner, err = expandReferences(definition, definitions, append(slices.Clone(stack), name), used)
+		return "(" + inner + ")"
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
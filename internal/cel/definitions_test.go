@@ -0,0 +1,118 @@
+package cel
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var releaseDefinitions = map[string]string{
+	"isRelease":         `${isReleaseLabel} || ${isReleasePipeline}`,
+	"isReleaseLabel":    `"type" in pipelineRun.metadata.labels && pipelineRun.metadata.labels["type"] == "release"`,
+	"isReleasePipeline": `plrPipelineName.startsWith("release-")`,
+}
+
+func TestWithDefinitions(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`${isRelease} ? priority("release") : priority("default")`,
+		`!${isReleaseLabel} ? [label("type", "build")] : []`,
+	}, WithDefinitions(releaseDefinitions))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(programs[0].GetExpression()).To(Equal(
+		`(("type" in pipelineRun.metadata.labels && pipelineRun.metadata.labels["type"] == "release") || ` +
+			`(plrPipelineName.startsWith("release-"))) ` +
+			`? priority("release") : priority("default")`))
+
+	release := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name: "release-1", Namespace: "tenant", Labels: map[string]string{"type": "release"},
+	}}
+	mutations, err := programs[0].Evaluate(release)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(HaveField("Value", "release")))
+	mutations, err = programs[1].Evaluate(release)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(BeEmpty())
+
+	build := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build-1", Namespace: "tenant"}}
+	mutations, err = programs[1].Evaluate(build)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(HaveField("Key", "type")))
+}
+
+func TestWithDefinitions_CompileIdentically(t *testing.T) {
+	g := NewWithT(t)
+
+	expanded, err := CompileCELPrograms([]string{`!${isRelease} ? [label("type", "build")] : []`},
+		WithDefinitions(releaseDefinitions))
+	g.Expect(err).NotTo(HaveOccurred())
+	handWritten, err := CompileCELPrograms([]string{
+		`!("type" in pipelineRun.metadata.labels && pipelineRun.metadata.labels["type"] == "release" || ` +
+			`plrPipelineName.startsWith("release-")) ` +
+			`? [label("type", "build")] : []`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expandedAST, err := cel.AstToString(expanded[0].ast)
+	g.Expect(err).NotTo(HaveOccurred())
+	handWrittenAST, err := cel.AstToString(handWritten[0].ast)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(expandedAST).To(Equal(handWrittenAST))
+	g.Expect(expanded[0].ast.OutputType()).To(Equal(handWritten[0].ast.OutputType()))
+	g.Expect(expanded[0].referencesIdentity).To(Equal(handWritten[0].referencesIdentity))
+}
+
+func TestWithDefinitions_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions map[string]string
+		expression  string
+		errMsg      string
+	}{
+		{
+			name:       "unknown name",
+			expression: `${isNightly} ? priority("low") : priority("default")`,
+			errMsg:     `unknown definition "isNightly"`,
+		},
+		{
+			name:        "unknown nested name",
+			definitions: map[string]string{"isRelease": `${isReleaseLabel} || ${isTagged}`},
+			expression:  `${isRelease} ? priority("release") : priority("default")`,
+			errMsg:      `unknown definition "isTagged", referenced by the definition "isRelease"`,
+		},
+		{
+			name:        "cycle",
+			definitions: map[string]string{"a": `${b} && true`, "b": `${a} || false`},
+			expression:  `${a} ? priority("high") : priority("low")`,
+			errMsg:      "cycle in the definitions: a -> b -> a",
+		},
+		{
+			name:        "self reference",
+			definitions: map[string]string{"a": `${a}`},
+			expression:  `${a} ? priority("high") : priority("low")`,
+			errMsg:      "cycle in the definitions: a -> a",
+		},
+		{
+			name:        "invalid expanded expression",
+			definitions: map[string]string{"replicas": `"3" + 1`},
+			expression:  `resource("replicas", ${replicas})`,
+			errMsg:      `expanded with the definitions replicas to "resource(\"replicas\", (\"3\" + 1))": type checking failed`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			definitions := map[string]string{"isRelease": "true", "isReleaseLabel": "true"}
+			for name, definition := range tt.definitions {
+				definitions[name] = definition
+			}
+			_, err := CompileCELPrograms([]string{tt.expression}, WithDefinitions(definitions))
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
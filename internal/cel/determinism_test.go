@@ -0,0 +1,47 @@
+package cel_test
+
+import (
+	"bytes"
+	"flag"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/cel/celgolden"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestDeterminism evaluates the corpus against each fixture, and checks that
+// the results don't change between evaluations nor from the golden files.
+// The golden files are generated on linux/amd64, so the results diverging
+// on other platforms fail the tests.
+func TestDeterminism(t *testing.T) {
+	dir := filepath.Join("testdata", "determinism")
+	cases, err := celgolden.LoadCorpus(filepath.Join(dir, "corpus.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := celgolden.LoadFixtures(filepath.Join(dir, "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			out, err := celgolden.Render(cases, fixture, cel.WithExcludeStatus())
+			g.Expect(err).NotTo(HaveOccurred())
+			for range 20 {
+				again, err := celgolden.Render(cases, fixture, cel.WithExcludeStatus())
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(bytes.Equal(again, out)).To(BeTrue(), "the results changed between evaluations")
+			}
+
+			celgolden.Golden(t, filepath.Join(dir, fixture.Name+".golden"), out, *update)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// maxDiffValueLength is the number of bytes above which the values of the
+// diffs are elided.
+const maxDiffValueLength = 80
+
+// DiffMutations returns the changes of the labels and annotations between
+// before and after, one per line, sorted by key, the labels first:
+//
+//	labels:
+//	  + env: "production"
+//	  ~ team: "a" → "b"
+//	  - obsolete: "true"
+//
+// Added keys are prefixed with +, changed ones with ~ and removed ones with
+// -. The values are quoted, and elided above 80 bytes. The diff is empty
+// when nothing changed. Unlike the maps, the diff is deterministic, so it
+// can be compared with golden files.
+func DiffMutations(before, after *tekv1.PipelineRun) string {
+	var b strings.Builder
+	writeMapDiff(&b, "labels", before.GetLabels(), after.GetLabels())
+	writeMapDiff(&b, "annotations", before.GetAnnotations(), after.GetAnnotations())
+	return b.String()
+}
+
+// writeMapDiff writes the section of the diff of the map, unless it didn't
+// change.
+func writeMapDiff(b *strings.Builder, name string, before, after map[string]string) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	header := false
+	for _, key := range keys {
+		old, inBefore := before[key]
+		value, inAfter := after[key]
+		var line string
+		switch {
+		case !inBefore:
+			line = fmt.Sprintf("  + %s: %s\n", key, diffValue(value))
+		case !inAfter:
+			line = fmt.Sprintf("  - %s: %s\n", key, diffValue(old))
+		case old != value:
+			line = fmt.Sprintf("  ~ %s: %s → %s\n", key, diffValue(old), diffValue(value))
+		default:
+			continue
+		}
+		if !header {
+			b.WriteString(name + ":\n")
+			header = true
+		}
+		b.WriteString(line)
+	}
+}
+
+// diffValue quotes the value, so whitespace and line breaks are visible,
+// after eliding it when it's longer than maxDiffValueLength.
+func diffValue(value string) string {
+	if len(value) <= maxDiffValueLength {
+		return strconv.Quote(value)
+	}
+	return fmt.Sprintf("%s...(elided, %d bytes)",
+		strconv.Quote(strings.ToValidUTF8(value[:maxDiffValueLength], "")), len(value))
+}
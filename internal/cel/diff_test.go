@@ -0,0 +1,117 @@
+package cel_test
+
+import (
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/cel/celgolden"
+)
+
+type entry struct{ key, value string }
+
+// shuffledMap builds the map inserting the entries in a random order, nil
+// when there are none.
+func shuffledMap(rng *rand.Rand, entries []entry) map[string]string {
+	if entries == nil {
+		return nil
+	}
+	m := make(map[string]string, len(entries))
+	for _, i := range rng.Perm(len(entries)) {
+		m[entries[i].key] = entries[i].value
+	}
+	return m
+}
+
+func TestDiffMutations(t *testing.T) {
+	type metadata struct{ labels, annotations []entry }
+	tests := []struct {
+		name          string
+		before, after metadata
+	}{
+		{
+			name: "changes",
+			before: metadata{
+				labels: []entry{{"team", "a"}, {"env", "staging"}, {"obsolete", "true"}},
+				annotations: []entry{
+					{"kueue.konflux-ci.dev/requests-linux-amd64", "1"},
+					{"build.appstudio.openshift.io/repo", "https://github.com/konflux-ci/build"},
+				},
+			},
+			after: metadata{
+				labels: []entry{
+					{"team", "b"}, {"env", "staging"}, {"kueue.x-k8s.io/queue-name", "pipelines-queue"},
+					{"kueue.x-k8s.io/priority-class", "high"},
+				},
+				annotations: []entry{
+					{"kueue.konflux-ci.dev/requests-linux-amd64", "2"},
+					{"kueue.konflux-ci.dev/requests-linux-arm64", "1"},
+					{"build.appstudio.openshift.io/repo", "https://github.com/konflux-ci/build"},
+					{"kueue.konflux-ci.dev/cel-resources-applied", `{"linux-amd64":1,"linux-arm64":1}`},
+				},
+			},
+		},
+		{
+			name: "no-changes",
+			before: metadata{
+				labels:      []entry{{"team", "a"}, {"env", "production"}},
+				annotations: []entry{{"owner", "team-a"}},
+			},
+			after: metadata{
+				labels:      []entry{{"env", "production"}, {"team", "a"}},
+				annotations: []entry{{"owner", "team-a"}},
+			},
+		},
+		{
+			name: "long-values",
+			before: metadata{
+				annotations: []entry{
+					{"description", strings.Repeat("x", 200)},
+					{"unicode", strings.Repeat("é", 60)},
+				},
+			},
+			after: metadata{
+				labels: []entry{{"empty", ""}},
+				annotations: []entry{
+					{"description", "short"},
+					{"unicode", strings.Repeat("é", 61)},
+					{"script", "set -e\nmake build\nmake test\n"},
+				},
+			},
+		},
+		{
+			name:   "nil-maps",
+			before: metadata{},
+			after: metadata{
+				labels:      []entry{{"kueue.x-k8s.io/queue-name", "pipelines-queue"}},
+				annotations: []entry{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			rng := rand.New(rand.NewPCG(1, 2))
+			pipelineRun := func(m metadata) *tekv1.PipelineRun {
+				return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+					Labels:      shuffledMap(rng, m.labels),
+					Annotations: shuffledMap(rng, m.annotations),
+				}}
+			}
+
+			diff := cel.DiffMutations(pipelineRun(tt.before), pipelineRun(tt.after))
+			// The entries inserted in other orders give the same diff
+			for range 20 {
+				g.Expect(cel.DiffMutations(pipelineRun(tt.before), pipelineRun(tt.after))).To(Equal(diff))
+			}
+			celgolden.Golden(t, filepath.Join("testdata", "diff", tt.name+".golden"), []byte(diff), *update)
+		})
+	}
+}
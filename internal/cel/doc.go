@@ -39,6 +39,20 @@
 //		// Apply mutations to Kubernetes resources...
 //	}
 //
+// EvaluateContext aborts the evaluation once its context is done, e.g. when
+// the deadline of the admission request is exceeded.
+//
+// EvaluateAll evaluates a list of programs and returns the mutations, the
+// evaluation duration and the error of each expression, so the callers know
+// which expression produced which mutation. The failure of an expression
+// doesn't stop the evaluation of the others:
+//
+//	results, err := cel.EvaluateAll(programs, pipelineRun)
+//	for _, result := range results {
+//		log.Printf("%q: %d mutations in %s, error: %v",
+//			result.Expression, len(result.Mutations), result.Duration, result.Err)
+//	}
+//
 // # CELMutator Usage
 //
 // For convenient mutation application, use the CELMutator:
@@ -57,32 +71,34 @@
 //	mutator := cel.NewCELMutator(programs)
 //	pipelineRun := &tekton.PipelineRun{...}
 //
-//	err = mutator.Mutate(pipelineRun)
+//	err = mutator.Mutate(ctx, pipelineRun)
 //	if err != nil {
 //		log.Printf("Mutation failed: %v", err)
 //	}
 //	// PipelineRun is now modified with labels and annotations
 //
-// # Available CEL Functions
+// Mutate requires the exclusive ownership of the PipelineRun. The objects
+// shared with other goroutines, e.g. from an informer cache, are mutated with
+// MutateCopy, which returns a mutated copy:
 //
-//   - annotation(key: string, value: string) -> MutationRequest
-//     Creates an annotation mutation with the specified key and value
+//	mutated, err := mutator.MutateCopy(ctx, cachedPipelineRun)
 //
-//   - label(key: string, value: string) -> MutationRequest
-//     Creates a label mutation with the specified key and value
+// # Available CEL Functions and Variables
 //
-//   - priority(value: string) -> MutationRequest
-//     Creates a label mutation with key "kueue.x-k8s.io/priority-class" and the specified value
+// The functions and variables of the environment, on top of the CEL standard
+// library, are declared with their description, signature and example, see
+// GetReference. The reference is rendered by the cel-reference subcommand and
+// served by the webhook on /debug/cel-reference:
 //
-//   - replace(source: string, search: string, replacement: string) -> string
-//     Replaces all occurrences of search string with replacement string in the source string
-//
-// # Available CEL Variables
+//	reference, err := cel.GetReference()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	err = reference.WriteMarkdown(os.Stdout)
 //
-//   - pipelineRun: map<string, any> - The full PipelineRun object as a CEL-accessible map
-//   - plrNamespace: string - The namespace of the PipelineRun
-//   - pacEventType: string - Value from label "pipelinesascode.tekton.dev/event-type" (empty if not present)
-//   - pacTestEventType: string - Value from label "pac.test.appstudio.openshift.io/event-type" (empty if not present)
+// Expressions passing user-controlled values to priority() are reported, see
+// GetTaintWarnings and WithEnforcedChecks, and references to
+// pipelineRun.status are reported, see GetStatusReferences.
 //
 // # Advanced Usage Examples
 //
@@ -96,6 +112,17 @@
 //	              pacEventType == "pull_request" ? priority("pull-request") :
 //	              priority("default")`
 //
+// Deprioritizing the PipelineRuns retried by Pipelines as Code, whose
+// pacRetryCount variable is read from the
+// pipelinesascode.tekton.dev/retry-count annotation, 0 when it's missing or
+// invalid:
+//
+//	expression := `pacRetryCount > 0 ? priority("retry") : priority("default")`
+//
+// Routing by the labels of the namespace, exposed by WithNamespaceLabels:
+//
+//	expression := `"tier" in namespaceLabels && namespaceLabels["tier"] == "gold" ? priority("high") : priority("default")`
+//
 // Accessing PipelineRun parameters:
 //
 //	expression := `has(pipelineRun.spec.params) &&
@@ -112,14 +139,34 @@
 //	                  p, annotation("kueue.konflux-ci.dev/requests-" + replace(p, "/", "-"), "1")
 //	              ) : []`
 //
+// # Validation Expressions
+//
+// CompileValidationPrograms compiles the expressions validating the updates
+// of the PipelineRuns, evaluated by EvaluateValidations with pipelineRun,
+// the updated PipelineRun, and oldPipelineRun. They can't use the mutation
+// functions, and return a bool or check(condition, message):
+//
+//	check(!("kueue.x-k8s.io/queue-name" in oldPipelineRun.metadata.labels) ||
+//	      "kueue.x-k8s.io/queue-name" in pipelineRun.metadata.labels,
+//	      "the queue label can't be removed")
+//
 // # Package Structure
 //
 // This package is organized into focused modules:
 //
 //   - types.go: Core data types (MutationType, MutationRequest) and validation
 //   - compiler.go: CEL environment setup, compilation, and type checking
+//   - definitions.go: Expansion of the references to the definitions
 //   - evaluator.go: Runtime program evaluation and result conversion
+//   - evaluate_all.go: Evaluation of a list of programs grouped per expression
+//   - aggregate.go: Aggregation functions over lists of ints
 //   - mutator.go: CELMutator for convenient mutation application
+//   - evaluation_cache.go: Cache of the evaluations by PipelineRun template
+//   - mutation_limits.go: Limits of the mutations of a PipelineRun
+//   - resource_pruning.go: Pruning of the unknown resource annotations
+//   - validation.go: Compilation and evaluation of the validation expressions
+//   - reference.go: Declarations and reference of the functions and variables
+//   - env_options.go: Extension of the environment by downstream builds
 //   - metrics.go: Prometheus metrics for monitoring CEL evaluation failures
 //
 // # Validation Hierarchy
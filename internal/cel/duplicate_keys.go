@@ -0,0 +1,114 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	celast "github.com/google/cel-go/common/ast"
+)
+
+// overwritingFunctions are the mutation functions whose mutations of the
+// same key overwrite each other, the last one winning, by the type of the
+// mutations. resource() sums the values and appendAnnotation() accumulates
+// them, so they aren't listed.
+var overwritingFunctions = map[string]MutationType{
+	"label":      MutationTypeLabel,
+	"annotation": MutationTypeAnnotation,
+	"priority":   MutationTypeLabel,
+}
+
+// priorityLabel is the label set by priority().
+const priorityLabel = "kueue.x-k8s.io/priority-class"
+
+// DuplicateKey reports a label or annotation set by several expressions,
+// so the value it gets depends on the order of the expressions.
+type DuplicateKey struct {
+	Type MutationType
+	Key  string
+	// Expressions are the expressions setting the key, in evaluation
+	// order.
+	Expressions []string
+}
+
+// String returns a human-readable description of the duplicate key.
+func (d DuplicateKey) String() string {
+	return fmt.Sprintf("the %s %q is set by several expressions, the last one wins: %s",
+		d.Type, d.Key, strings.Join(d.Expressions, "; "))
+}
+
+// FindDuplicateKeys returns the labels and annotations set by more than one
+// of the programs, in the order they're first set. It's a best-effort
+// static analysis: only the calls of label(), annotation() and priority()
+// with a string literal key are considered, so keys computed at admission
+// aren't reported. The calls of the same program, e.g. in the branches of a
+// conditional, aren't reported either, nor the calls of the programs of
+// different queues, see WithQueue.
+func FindDuplicateKeys(programs []*CompiledProgram) []DuplicateKey {
+	var keys []mutationKey
+	setters := map[mutationKey][]*CompiledProgram{}
+	for _, program := range programs {
+		for _, key := range program.literalKeys() {
+			if _, ok := setters[key]; !ok {
+				keys = append(keys, key)
+			}
+			setters[key] = append(setters[key], program)
+		}
+	}
+	var duplicates []DuplicateKey
+	for _, key := range keys {
+		if !overlap(setters[key]) {
+			continue
+		}
+		duplicate := DuplicateKey{Type: key.mutationType, Key: key.key}
+		for _, program := range setters[key] {
+			duplicate.Expressions = append(duplicate.Expressions, program.expression)
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+	return duplicates
+}
+
+// overlap returns whether several of the programs are evaluated for the
+// same PipelineRuns, the programs of different queues never being.
+func overlap(programs []*CompiledProgram) bool {
+	queues := map[string]bool{}
+	for _, program := range programs {
+		if queues[program.queue] || queues[""] || (program.queue == "" && len(queues) > 0) {
+			return true
+		}
+		queues[program.queue] = true
+	}
+	return false
+}
+
+type mutationKey struct {
+	mutationType MutationType
+	key          string
+}
+
+// literalKeys returns the distinct literal keys set by the calls of the
+// overwriting functions of the program, in the order of the calls.
+func (cp *CompiledProgram) literalKeys() []mutationKey {
+	var keys []mutationKey
+	celast.PreOrderVisit(cp.ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() != celast.CallKind {
+			return
+		}
+		call := expr.AsCall()
+		mutationType, ok := overwritingFunctions[call.FunctionName()]
+		if !ok || len(call.Args()) == 0 {
+			return
+		}
+		key := priorityLabel
+		if call.FunctionName() != "priority" {
+			if key, ok = stringLiteral(call.Args()[0]); !ok {
+				return
+			}
+		}
+		if k := (mutationKey{mutationType: mutationType, key: key}); !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}))
+	return keys
+}
@@ -0,0 +1,106 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFindDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		expressions []string
+		expected    []DuplicateKey
+	}{
+		{
+			name: "duplicate literal keys",
+			expressions: []string{
+				`label("team", "a")`,
+				`annotation("owner", "a")`,
+				`pipelineRun.metadata.namespace == "ci" ? label("team", "b") : annotation("other", "b")`,
+				`[annotation("owner", "b"), label("owner", "c")]`,
+			},
+			expected: []DuplicateKey{
+				{
+					Type: MutationTypeLabel,
+					Key:  "team",
+					Expressions: []string{
+						`label("team", "a")`,
+						`pipelineRun.metadata.namespace == "ci" ? label("team", "b") : annotation("other", "b")`,
+					},
+				},
+				{
+					Type: MutationTypeAnnotation,
+					Key:  "owner",
+					Expressions: []string{
+						`annotation("owner", "a")`,
+						`[annotation("owner", "b"), label("owner", "c")]`,
+					},
+				},
+			},
+		},
+		{
+			name: "priority and the priority class label",
+			expressions: []string{
+				`priority("high")`,
+				`label("kueue.x-k8s.io/priority-class", "low")`,
+			},
+			expected: []DuplicateKey{
+				{
+					Type: MutationTypeLabel,
+					Key:  "kueue.x-k8s.io/priority-class",
+					Expressions: []string{
+						`priority("high")`,
+						`label("kueue.x-k8s.io/priority-class", "low")`,
+					},
+				},
+			},
+		},
+		{
+			name: "dynamic keys aren't flagged",
+			expressions: []string{
+				`label(pipelineRun.metadata.name, "a")`,
+				`label(pipelineRun.metadata.name, "b")`,
+				`annotation("team-" + pipelineRun.metadata.namespace, "a")`,
+				`annotation("team-" + pipelineRun.metadata.namespace, "b")`,
+			},
+		},
+		{
+			name: "summed and accumulated keys aren't flagged",
+			expressions: []string{
+				`resource("cpu", 1)`,
+				`resource("cpu", 2)`,
+				`appendAnnotation("notes", "a", ",")`,
+				`appendAnnotation("notes", "b", ",")`,
+			},
+		},
+		{
+			name: "keys set twice by the same expression aren't flagged",
+			expressions: []string{
+				`pipelineRun.metadata.namespace == "ci" ? priority("high") : priority("low")`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(FindDuplicateKeys(programs)).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestDuplicateKey_String(t *testing.T) {
+	g := NewWithT(t)
+
+	duplicate := DuplicateKey{
+		Type:        MutationTypeLabel,
+		Key:         "team",
+		Expressions: []string{`label("team", "a")`, `label("team", "b")`},
+	}
+	g.Expect(duplicate.String()).To(Equal(
+		`the label "team" is set by several expressions, the last one wins: label("team", "a"); label("team", "b")`))
+}
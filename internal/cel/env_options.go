@@ -0,0 +1,91 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+)
+
+var (
+	registeredEnvOptionsMu sync.RWMutex
+	registeredEnvOptions   []cel.EnvOption
+)
+
+// RegisterEnvOption adds the option, e.g. a cel.Function declaring a
+// company-specific function with its binding, to the environment of the
+// programs compiled afterwards by CompileCELPrograms and
+// CompileCELProgramsLenient, so downstream builds can extend the
+// environment without forking the compiler. It's meant to be called from an
+// init function, before the configuration is compiled: the programs
+// compiled earlier are left unchanged.
+//
+// RegisterEnvOption is safe for concurrent use. The bindings of the
+// functions are called concurrently by the evaluations, and their results
+// may be cached, see WithEvaluationCache, so they must be safe for
+// concurrent use and only depend on their arguments. The variables declared
+// by the options have no value when the PipelineRuns are evaluated, so the
+// expressions referencing them fail to compile.
+func RegisterEnvOption(opt cel.EnvOption) {
+	registeredEnvOptionsMu.Lock()
+	defer registeredEnvOptionsMu.Unlock()
+	registeredEnvOptions = append(registeredEnvOptions, opt)
+}
+
+// WithEnvOptions adds the options to the environment of the compiled
+// programs, after the registered ones, see RegisterEnvOption.
+func WithEnvOptions(opts ...cel.EnvOption) CompileOption {
+	return func(o *compileOptions) {
+		o.envOptions = append(o.envOptions, opts...)
+	}
+}
+
+// envOptions returns the registered options followed by opts.
+func envOptions(opts []cel.EnvOption) []cel.EnvOption {
+	registeredEnvOptionsMu.RLock()
+	defer registeredEnvOptionsMu.RUnlock()
+	return append(append([]cel.EnvOption{}, registeredEnvOptions...), opts...)
+}
+
+// checkVariables returns an error when the checked AST references a
+// variable without value at evaluation, i.e. one declared by an injected
+// option rather than by variableDeclarations. The variables of the
+// comprehensions are local, and the type names are constants of the
+// environment.
+func checkVariables(env *cel.Env, ast *celast.AST) error {
+	declared := map[string]bool{}
+	for _, variable := range variableDeclarations() {
+		declared[variable.Name] = true
+	}
+	celast.PreOrderVisit(ast.Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() != celast.ComprehensionKind {
+			return
+		}
+		comp := expr.AsComprehension()
+		declared[comp.IterVar()] = true
+		if comp.HasIterVar2() {
+			declared[comp.IterVar2()] = true
+		}
+		declared[comp.AccuVar()] = true
+	}))
+
+	var undeclared []string
+	for _, reference := range ast.ReferenceMap() {
+		// The functions have no name, and the enum constants have a value
+		if reference.Name == "" || reference.Value != nil || declared[reference.Name] {
+			continue
+		}
+		if _, constant := env.CELTypeProvider().FindIdent(reference.Name); constant {
+			continue
+		}
+		undeclared = append(undeclared, reference.Name)
+	}
+	if len(undeclared) == 0 {
+		return nil
+	}
+	slices.Sort(undeclared)
+	return fmt.Errorf("variable %q has no value when the PipelineRuns are evaluated, "+
+		"only the variables of the reference can be used", undeclared[0])
+}
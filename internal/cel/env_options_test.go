@@ -0,0 +1,89 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// costCenterFor is a company-specific function, as registered by a
+// downstream build.
+var costCenterFor = cel.Function("costCenterFor",
+	cel.Overload("costCenterFor_string", []*cel.Type{cel.StringType}, cel.StringType,
+		cel.UnaryBinding(func(namespace ref.Val) ref.Val {
+			if namespace.(types.String) == "tenant-a" {
+				return types.String("cc-1234")
+			}
+			return types.String("cc-unknown")
+		}),
+	),
+)
+
+func registerEnvOptionForTest(t *testing.T, opt cel.EnvOption) {
+	registeredEnvOptionsMu.Lock()
+	saved := registeredEnvOptions
+	registeredEnvOptionsMu.Unlock()
+	t.Cleanup(func() {
+		registeredEnvOptionsMu.Lock()
+		defer registeredEnvOptionsMu.Unlock()
+		registeredEnvOptions = saved
+	})
+	RegisterEnvOption(opt)
+}
+
+func TestRegisterEnvOption(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := CompileCELPrograms([]string{`label("cost-center", costCenterFor(plrNamespace))`})
+	g.Expect(err).To(MatchError(ContainSubstring("undeclared reference to 'costCenterFor'")))
+
+	registerEnvOptionForTest(t, costCenterFor)
+	programs, err := CompileCELPrograms([]string{`label("cost-center", costCenterFor(plrNamespace))`})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant-a"}}
+	g.Expect(NewCELMutator(programs).Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("cost-center", "cc-1234"))
+
+	// The registered functions are listed by the reference
+	reference, err := GetReference()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reference.Functions).To(ContainElement(FunctionReference{Name: "costCenterFor"}))
+}
+
+func TestWithEnvOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`label("cost-center", costCenterFor(plrNamespace))`},
+		WithEnvOptions(costCenterFor))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutations, err := programs[0].Evaluate(&tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-b"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(HaveField("Value", "cc-unknown")))
+
+	// The options only apply to the compilation they're passed to
+	_, err = CompileCELPrograms([]string{`label("cost-center", costCenterFor(plrNamespace))`})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestWithEnvOptions_UndeclaredVariables(t *testing.T) {
+	g := NewWithT(t)
+
+	region := WithEnvOptions(cel.Variable("region", cel.StringType))
+	_, err := CompileCELPrograms([]string{`label("region", region)`}, region)
+	g.Expect(err).To(MatchError(ContainSubstring(
+		`variable "region" has no value when the PipelineRuns are evaluated`)))
+
+	// The variables of the comprehensions and the type names are accepted
+	_, err = CompileCELPrograms([]string{
+		`pipelineRun.spec.params.map(p, label(p.name, "true"))`,
+		`type(plrNamespace) == string ? [label("a", "b")] : []`,
+	}, region)
+	g.Expect(err).NotTo(HaveOccurred())
+}
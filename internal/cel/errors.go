@@ -0,0 +1,81 @@
+package cel
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of the package have one of the types below, wrapped or not,
+// so the callers can tell the failure classes apart with errors.As: the
+// compile errors are bugs of the configuration found when it's loaded, the
+// evaluation errors may depend on the PipelineRuns, and the mutation
+// validation errors are bugs of the configuration found once the
+// expressions return invalid mutations.
+
+// CompileError is the failure of the compilation of an expression,
+// returned by CompileCELPrograms.
+type CompileError struct {
+	// Index is the index of the expression in the compiled list
+	Index int
+	// Name is the name of the expression, if any, given in the message
+	// instead of the index
+	Name       string
+	Expression string
+	Err        error
+}
+
+func (e *CompileError) Error() string {
+	ref := expressionRef(e.Index, e.Name)
+	var rejected *rejectedError
+	switch {
+	case errors.Is(e.Err, errEmptyExpression):
+		return fmt.Sprintf("expression %s cannot be empty", ref)
+	case errors.As(e.Err, &rejected):
+		return fmt.Sprintf("expression %s (%q) is rejected: %s", ref, e.Expression, rejected.reason)
+	default:
+		return fmt.Sprintf("failed to compile expression %s (%q): %v", ref, e.Expression, e.Err)
+	}
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// EvaluationError is the failure of the evaluation of an expression, or of
+// the conversion of its result to mutations, returned by Evaluate and
+// Mutate.
+type EvaluationError struct {
+	// Name is the name of the expression, if any
+	Name       string
+	Expression string
+	Err        error
+}
+
+func (e *EvaluationError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("expression '%s' failed to evaluate (%q): %v", e.Name, e.Expression, e.Err)
+	}
+	return fmt.Sprintf("failed to evaluate CEL expression %q: %v", e.Expression, e.Err)
+}
+
+func (e *EvaluationError) Unwrap() error {
+	return e.Err
+}
+
+// MutationValidationError is the failure of a mutation returned by an
+// expression which is invalid, e.g. because of its key, denied by the
+// policy, or which can't be applied. It's returned, wrapped with the
+// expression and the index of the mutation, by Evaluate and Mutate, so its
+// message is the one of Err.
+type MutationValidationError struct {
+	Mutation *MutationRequest
+	Err      error
+}
+
+func (e *MutationValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MutationValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,161 @@
+package cel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompileError(t *testing.T) {
+	tests := []struct {
+		name        string
+		expressions []string
+		index       int
+		errMsg      string
+	}{
+		{
+			name:        "syntax error",
+			expressions: []string{`priority("high")`, `annotation("owner", "team-a"`},
+			index:       1,
+			errMsg:      `failed to compile expression 1 ("annotation(\"owner\", \"team-a\"")`,
+		},
+		{
+			name:        "empty expression",
+			expressions: []string{""},
+			index:       0,
+			errMsg:      "expression 0 cannot be empty",
+		},
+		{
+			name:        "type error",
+			expressions: []string{`priority(1)`},
+			index:       0,
+			errMsg:      "failed to compile expression 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := CompileCELPrograms(tt.expressions)
+
+			var compileErr *CompileError
+			g.Expect(errors.As(err, &compileErr)).To(BeTrue(), "%v", err)
+			g.Expect(compileErr.Index).To(Equal(tt.index))
+			g.Expect(compileErr.Expression).To(Equal(tt.expressions[tt.index]))
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+			g.Expect(errors.As(err, new(*EvaluationError))).To(BeFalse())
+		})
+	}
+
+	g := NewWithT(t)
+	// The rejected expressions are compile errors too
+	_, err := CompileCELPrograms([]string{`annotation("kubernetes.io/owner", "x")`},
+		WithProtectedKeys(ProtectedKeys{Denied: []string{"kubernetes.io/*"}}))
+	var compileErr *CompileError
+	g.Expect(errors.As(err, &compileErr)).To(BeTrue())
+	g.Expect(err).To(MatchError(ContainSubstring("expression 0 (\"annotation(\\\"kubernetes.io/owner\\\", \\\"x\\\")\") is rejected")))
+}
+
+func TestCompileError_Named(t *testing.T) {
+	g := NewWithT(t)
+	_, err := CompileNamedCELPrograms([]NamedExpression{
+		{Expression: `priority("high")`},
+		{Name: "priority-routing", Expression: `priority(1)`},
+	})
+	var compileErr *CompileError
+	g.Expect(errors.As(err, &compileErr)).To(BeTrue(), "%v", err)
+	g.Expect(compileErr.Index).To(Equal(1))
+	g.Expect(compileErr.Name).To(Equal("priority-routing"))
+	g.Expect(err).To(MatchError(ContainSubstring(`failed to compile expression 'priority-routing' ("priority(1)")`)))
+
+	// The lenient compilation names the failures, and still returns the
+	// programs which compiled, with their name
+	programs, err := CompileNamedCELProgramsLenient([]NamedExpression{
+		{Expression: `priority("high")`},
+		{Name: "team-label", Expression: `label("team", "a")`},
+		{Name: "priority-routing", Expression: `priority(1)`},
+		{Expression: `annotation("owner"`},
+	})
+	g.Expect(err).To(MatchError(ContainSubstring("expression 'priority-routing' (priority(1))")))
+	g.Expect(err).To(MatchError(ContainSubstring("expression 3 (annotation(\"owner\")")))
+	g.Expect(programs).To(HaveLen(2))
+	g.Expect(programs[0].GetName()).To(BeEmpty())
+	g.Expect(programs[1].GetName()).To(Equal("team-label"))
+}
+
+func TestEvaluationError(t *testing.T) {
+	g := NewWithT(t)
+	expression := `annotation("owner", pipelineRun.metadata.annotations["missing"])`
+	programs, err := CompileCELPrograms([]string{expression})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+
+	_, err = programs[0].Evaluate(pipelineRun)
+	var evaluationErr *EvaluationError
+	g.Expect(errors.As(err, &evaluationErr)).To(BeTrue(), "%v", err)
+	g.Expect(evaluationErr.Expression).To(Equal(expression))
+	g.Expect(errors.As(err, new(*MutationValidationError))).To(BeFalse())
+
+	// Mutate returns the error of the evaluation
+	err = NewCELMutator(programs).Mutate(context.Background(), pipelineRun)
+	g.Expect(errors.As(err, &evaluationErr)).To(BeTrue(), "%v", err)
+
+	// The aborted evaluations are evaluation errors too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = programs[0].EvaluateContext(ctx, pipelineRun)
+	g.Expect(errors.As(err, &evaluationErr)).To(BeTrue(), "%v", err)
+	g.Expect(err).To(MatchError(context.Canceled))
+
+	// The named expressions are identified by their name
+	programs, err = CompileNamedCELPrograms([]NamedExpression{{Name: "priority-routing", Expression: expression}})
+	g.Expect(err).NotTo(HaveOccurred())
+	err = NewCELMutator(programs).Mutate(context.Background(), pipelineRun)
+	g.Expect(errors.As(err, &evaluationErr)).To(BeTrue(), "%v", err)
+	g.Expect(evaluationErr.Name).To(Equal("priority-routing"))
+	g.Expect(err).To(MatchError(ContainSubstring("expression 'priority-routing' failed to evaluate")))
+}
+
+func TestMutationValidationError(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		opts       []CompileOption
+		mutation   MutationRequest
+	}{
+		{
+			name:       "empty value",
+			expression: `{"type": "annotation", "key": "owner", "value": plrGenerateName}`,
+			mutation:   MutationRequest{Type: MutationTypeAnnotation, Key: "owner"},
+		},
+		{
+			name:       "denied by the policy",
+			expression: `label("team", "a")`,
+			opts:       []CompileOption{WithMutationPolicy(MutationPolicy{Types: []MutationType{MutationTypeAnnotation}})},
+			mutation:   MutationRequest{Type: MutationTypeLabel, Key: "team", Value: "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms([]string{tt.expression}, tt.opts...)
+			g.Expect(err).NotTo(HaveOccurred())
+			pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+
+			for _, err := range []error{
+				func() error { _, err := programs[0].Evaluate(pipelineRun); return err }(),
+				NewCELMutator(programs).Mutate(context.Background(), pipelineRun),
+			} {
+				var validationErr *MutationValidationError
+				g.Expect(errors.As(err, &validationErr)).To(BeTrue(), "%v", err)
+				g.Expect(*validationErr.Mutation).To(Equal(tt.mutation))
+				g.Expect(errors.As(err, new(*EvaluationError))).To(BeFalse())
+			}
+		})
+	}
+}
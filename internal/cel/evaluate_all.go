@@ -0,0 +1,86 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// ExpressionResult is the result of the evaluation of one program by
+// EvaluateAll.
+type ExpressionResult struct {
+	// Index is the index of the program in the evaluated list
+	Index int
+	// Name is the name of the expression, if any
+	Name string
+	// Expression is the CEL expression of the program
+	Expression string
+	// Mutations are the mutations returned by the expression, nil when it
+	// failed
+	Mutations []*MutationRequest
+	// Duration is the time spent evaluating the expression
+	Duration time.Duration
+	// Err is the error of the evaluation, if any
+	Err error
+}
+
+// EvaluateAll evaluates the programs against the PipelineRun and returns the
+// result of each of them, in order, so callers know which expression
+// produced which mutation. The failure of an expression is recorded in its
+// result and doesn't stop the evaluation of the others. The programs of the
+// other queues than the one of the PipelineRun are skipped and have no
+// result. The error is only returned when the PipelineRun can't be
+// evaluated at all.
+func EvaluateAll(programs []*CompiledProgram, pipelineRun *tekv1.PipelineRun) ([]ExpressionResult, error) {
+	return EvaluateAllContext(context.Background(), programs, pipelineRun)
+}
+
+// EvaluateAllContext is like EvaluateAll, but the evaluations are aborted
+// once the context is done.
+func EvaluateAllContext(ctx context.Context, programs []*CompiledProgram, pipelineRun *tekv1.PipelineRun) ([]ExpressionResult, error) {
+	if pipelineRun == nil {
+		return nil, fmt.Errorf("pipelineRun cannot be nil")
+	}
+	pipelineRunMap, err := structToCELMap(pipelineRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert PipelineRun to map: %w", err)
+	}
+	return evaluateAll(ctx, programs, pipelineRun, pipelineRunMap, nil, nil), nil
+}
+
+// evaluateAll evaluates the programs applying to the PipelineRun, see
+// CompiledProgram.appliesTo, with its map, see structToCELMap, which is
+// shared by all of them, and the labels of its namespace, empty when nil.
+// The programs for which allow, if not nil, returns false are skipped and
+// have no result.
+func evaluateAll(
+	ctx context.Context,
+	programs []*CompiledProgram,
+	pipelineRun *tekv1.PipelineRun,
+	pipelineRunMap map[string]interface{},
+	namespaceLabels map[string]string,
+	allow func(i int) bool,
+) []ExpressionResult {
+	results := make([]ExpressionResult, 0, len(programs))
+	for i, program := range programs {
+		if !program.appliesTo(pipelineRun) {
+			continue
+		}
+		if allow != nil && !allow(i) {
+			continue
+		}
+		start := time.Now()
+		mutations, err := program.evaluate(ctx, pipelineRun, pipelineRunMap, namespaceLabels)
+		results = append(results, ExpressionResult{
+			Index:      i,
+			Name:       program.name,
+			Expression: program.expression,
+			Mutations:  mutations,
+			Duration:   time.Since(start),
+			Err:        err,
+		})
+	}
+	return results
+}
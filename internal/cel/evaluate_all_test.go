@@ -0,0 +1,95 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+func TestEvaluateAll(t *testing.T) {
+	g := NewWithT(t)
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "tenant",
+			Labels:    map[string]string{common.QueueLabel: "pipelines-queue"},
+		},
+	}
+	expressions := []string{
+		`annotation("team", "a")`,
+		`[label("env", "prod"), priority("high")]`,
+		`annotation("missing", pipelineRun.metadata.labels["missing"])`,
+		`plrNamespace == "tenant" ? [annotation("tenant", "true")] : []`,
+	}
+	programs, err := CompileCELPrograms(expressions)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	results, err := EvaluateAll(programs, pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(4))
+	for i, result := range results {
+		g.Expect(result.Index).To(Equal(i))
+		g.Expect(result.Expression).To(Equal(expressions[i]))
+		g.Expect(result.Duration).To(BeNumerically(">", 0))
+	}
+	g.Expect(results[0].Err).NotTo(HaveOccurred())
+	g.Expect(results[0].Mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeAnnotation, Key: "team", Value: "a"},
+	))
+	g.Expect(results[1].Err).NotTo(HaveOccurred())
+	g.Expect(results[1].Mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeLabel, Key: "env", Value: "prod"},
+		&MutationRequest{Type: MutationTypeLabel, Key: "kueue.x-k8s.io/priority-class", Value: "high"},
+	))
+	// The failure of an expression doesn't stop the evaluation of the next
+	// ones
+	g.Expect(results[2].Err).To(MatchError(ContainSubstring("failed to evaluate CEL expression")))
+	g.Expect(results[2].Mutations).To(BeNil())
+	g.Expect(results[3].Err).NotTo(HaveOccurred())
+	g.Expect(results[3].Mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeAnnotation, Key: "tenant", Value: "true"},
+	))
+}
+
+func TestEvaluateAll_Queue(t *testing.T) {
+	g := NewWithT(t)
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "tenant",
+			Labels:    map[string]string{common.QueueLabel: "release-queue"},
+		},
+	}
+	shared, err := CompileCELPrograms([]string{`annotation("shared", "true")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelines, err := CompileCELPrograms([]string{`annotation("pipelines", "true")`}, WithQueue("pipelines-queue"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The programs of the other queues have no result
+	results, err := EvaluateAll(append(pipelines, shared...), pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(1))
+	g.Expect(results[0].Index).To(Equal(1))
+	g.Expect(results[0].Expression).To(Equal(`annotation("shared", "true")`))
+}
+
+func TestEvaluateAll_Errors(t *testing.T) {
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{`annotation("team", "a")`})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = EvaluateAll(programs, nil)
+	g.Expect(err).To(MatchError("pipelineRun cannot be nil"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := EvaluateAllContext(ctx, programs, &tekv1.PipelineRun{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(results).To(HaveLen(1))
+	g.Expect(results[0].Err).To(MatchError(context.Canceled))
+}
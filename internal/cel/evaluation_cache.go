@@ -0,0 +1,333 @@
+package cel
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/go-logr/logr"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/utils/lru"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// identityFields are the fields of the metadata of the PipelineRuns which
+// are left out of the key of the evaluation cache, as they differ between
+// the PipelineRuns created from the same template.
+var identityFields = map[string]bool{
+	"name":          true,
+	"generateName":  true,
+	"managedFields": true,
+}
+
+// bookkeepingAnnotations are the annotations written by tekton-kueue
+// itself, left out of the key of the evaluation cache like the identity
+// fields, as some of them, e.g. the UID of the admission request, differ
+// between the PipelineRuns created from the same template.
+var bookkeepingAnnotations = map[string]bool{
+	common.AdmissionUIDAnnotation:     true,
+	AnnotationAppliedResources:        true,
+	common.AppliedMutationsAnnotation: true,
+	common.ConfigHashAnnotation:       true,
+	common.DefaultedQueueAnnotation:   true,
+}
+
+// identityVariables are the variables derived from the identity fields.
+var identityVariables = map[string]bool{
+	"plrPipelineName": true,
+	"plrGenerateName": true,
+}
+
+// pipelineRunBindingFunctions are the functions called by a macro passing
+// the pipelineRun variable as their first argument, see
+// createParamFunction. They only read the params.
+var pipelineRunBindingFunctions = map[string]bool{
+	"paramInt":  true,
+	"paramBool": true,
+}
+
+// WithEvaluationCache caches the mutations returned by the programs for up
+// to size PipelineRuns, by their JSON without the name, generateName,
+// managedFields and bookkeepingAnnotations, so the PipelineRuns created
+// from the same template, e.g. by a trigger, are only evaluated once. The least recently used entries
+// are evicted first, and the cache lives as long as the mutator, so it's
+// emptied when the mutator is rebuilt from a new configuration.
+//
+// The cache is disabled, which is logged with log, when a program may read
+// the name, the generateName or the managedFields, directly, through the
+// whole pipelineRun or metadata, through plrPipelineName and
+// plrGenerateName, or one of the bookkeepingAnnotations by its key, see
+// referencesIdentity, or the time of the evaluation,
+// through the time variables, see referencesTime. It's bypassed
+// while the circuit breaker of a program is open. The lookups are counted
+// in tekton_kueue_cel_cache_lookups_total. A size of zero disables the
+// cache.
+func WithEvaluationCache(log logr.Logger, size int) MutatorOption {
+	return func(m *CELMutator) {
+		m.cacheSize = size
+		m.cacheLog = log
+	}
+}
+
+// cacheable returns whether the evaluation cache is used. It's bypassed
+// while the circuit breaker of a program is open, as the cached results
+// include the mutations of the program. The evaluations where the breaker
+// skipped a program, or a program fell back on its onError mutations,
+// aren't cached.
+func (m *CELMutator) cacheable(breaker *circuitBreaker) bool {
+	if m.cache == nil {
+		return false
+	}
+	if breaker == nil {
+		return true
+	}
+	for i := range m.programs {
+		if _, open := breaker.openUntil(i); open {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluationCache caches the results of the evaluations of the programs of
+// a CELMutator. The underlying LRU cache is safe for concurrent use.
+type evaluationCache struct {
+	entries *lru.Cache
+}
+
+// cachedEvaluation is the result of the evaluation of all the programs for
+// a PipelineRun.
+type cachedEvaluation struct {
+	mutations []*MutationRequest
+	// programResults are the mutations by index of the evaluated program,
+	// observed by the result tracker on the hits.
+	programResults map[int][]*MutationRequest
+}
+
+// newEvaluationCache returns the cache of the programs, or nil if size
+// isn't positive or a program references the identity fields or the time.
+func newEvaluationCache(programs []*CompiledProgram, size int, log logr.Logger) *evaluationCache {
+	if size <= 0 {
+		return nil
+	}
+	for _, program := range programs {
+		if program.referencesIdentity {
+			log.Info("Disabling the CEL evaluation cache, an expression may read the name of the PipelineRuns",
+				"expression", program.expression)
+			return nil
+		}
+		if program.referencesTime {
+			log.Info("Disabling the CEL evaluation cache, an expression reads the time of the evaluation",
+				"expression", program.expression)
+			return nil
+		}
+	}
+	return &evaluationCache{entries: lru.New(size)}
+}
+
+// key returns the key of the PipelineRun, the hash of its JSON without the
+// identity fields and the bookkeeping annotations, and of the labels of its
+// namespace, if exposed.
+func (c *evaluationCache) key(pipelineRun *tekv1.PipelineRun, namespaceLabels map[string]string) ([sha256.Size]byte, error) {
+	template := *pipelineRun
+	template.Name = ""
+	template.GenerateName = ""
+	template.ManagedFields = nil
+	template.Annotations = maps.Clone(template.Annotations)
+	maps.DeleteFunc(template.Annotations, func(key, _ string) bool { return bookkeepingAnnotations[key] })
+	b, err := json.Marshal(&template)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	if namespaceLabels != nil {
+		labels, err := json.Marshal(namespaceLabels)
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		b = append(b, labels...)
+	}
+	return sha256.Sum256(b), nil
+}
+
+// get returns a copy of the cached evaluation of the key, and counts the
+// lookup.
+func (c *evaluationCache) get(key [sha256.Size]byte) (*cachedEvaluation, bool) {
+	value, ok := c.entries.Get(key)
+	if !ok {
+		RecordCacheMiss()
+		return nil, false
+	}
+	RecordCacheHit()
+	return value.(*cachedEvaluation).clone(), true
+}
+
+// add caches a copy of the evaluation, so the mutations returned to the
+// callers can be modified.
+func (c *evaluationCache) add(key [sha256.Size]byte, evaluation *cachedEvaluation) {
+	c.entries.Add(key, evaluation.clone())
+}
+
+func (e *cachedEvaluation) clone() *cachedEvaluation {
+	clone := &cachedEvaluation{
+		mutations:      cloneMutations(e.mutations),
+		programResults: make(map[int][]*MutationRequest, len(e.programResults)),
+	}
+	for i, mutations := range e.programResults {
+		clone.programResults[i] = cloneMutations(mutations)
+	}
+	return clone
+}
+
+func cloneMutations(mutations []*MutationRequest) []*MutationRequest {
+	if mutations == nil {
+		return nil
+	}
+	clones := make([]*MutationRequest, len(mutations))
+	for i, mutation := range mutations {
+		clone := *mutation
+		clones[i] = &clone
+	}
+	return clones
+}
+
+// referencesIdentity returns whether the checked AST may read the identity
+// fields of the pipelineRun variable. The paths of the selections and the
+// indexes with constant keys are followed, e.g.
+// pipelineRun.metadata.labels["app"] doesn't read them, while
+// pipelineRun.metadata.name, pipelineRun.metadata[key], the use of the
+// whole pipelineRun or metadata, e.g. in a comprehension, the
+// identityVariables and the bookkeepingAnnotations indexed or tested by
+// their key may.
+func referencesIdentity(ast *celast.AST) bool {
+	return visitIdentity(ast.Expr())
+}
+
+func visitIdentity(expr celast.Expr) bool {
+	if path, keys, ok := pipelineRunPath(expr); ok {
+		return readsIdentity(path) || slices.ContainsFunc(keys, visitIdentity)
+	}
+
+	switch expr.Kind() {
+	case celast.IdentKind:
+		return identityVariables[expr.AsIdent()]
+
+	case celast.SelectKind:
+		return visitIdentity(expr.AsSelect().Operand())
+
+	case celast.CallKind:
+		call := expr.AsCall()
+		args := call.Args()
+		if pipelineRunBindingFunctions[call.FunctionName()] && len(args) == 3 && isPipelineRunIdent(args[0]) {
+			args = args[1:]
+		}
+		if call.IsMemberFunction() && visitIdentity(call.Target()) {
+			return true
+		}
+		if call.FunctionName() == operators.In && len(args) == 2 && testsBookkeepingAnnotation(args[0], args[1]) {
+			return true
+		}
+		return slices.ContainsFunc(args, visitIdentity)
+
+	case celast.ListKind:
+		return slices.ContainsFunc(expr.AsList().Elements(), visitIdentity)
+
+	case celast.MapKind:
+		for _, entry := range expr.AsMap().Entries() {
+			e := entry.AsMapEntry()
+			if visitIdentity(e.Key()) || visitIdentity(e.Value()) {
+				return true
+			}
+		}
+		return false
+
+	case celast.StructKind:
+		for _, field := range expr.AsStruct().Fields() {
+			if visitIdentity(field.AsStructField().Value()) {
+				return true
+			}
+		}
+		return false
+
+	case celast.ComprehensionKind:
+		comp := expr.AsComprehension()
+		return slices.ContainsFunc([]celast.Expr{
+			comp.IterRange(), comp.AccuInit(), comp.LoopCondition(), comp.LoopStep(), comp.Result(),
+		}, visitIdentity)
+
+	default:
+		return false
+	}
+}
+
+// pipelineRunPath returns the path of the chain of selections and indexes
+// rooted at the pipelineRun variable expr is, with "" for the computed
+// keys, whose expressions are returned as well.
+func pipelineRunPath(expr celast.Expr) ([]string, []celast.Expr, bool) {
+	switch expr.Kind() {
+	case celast.IdentKind:
+		return nil, nil, isPipelineRunIdent(expr)
+
+	case celast.SelectKind:
+		sel := expr.AsSelect()
+		path, keys, ok := pipelineRunPath(sel.Operand())
+		return append(path, sel.FieldName()), keys, ok
+
+	case celast.CallKind:
+		call := expr.AsCall()
+		if call.FunctionName() != operators.Index || len(call.Args()) != 2 {
+			return nil, nil, false
+		}
+		path, keys, ok := pipelineRunPath(call.Args()[0])
+		index := call.Args()[1]
+		if key, isString := literalString(index); isString {
+			return append(path, key), keys, ok
+		}
+		return append(path, ""), append(keys, index), ok
+
+	default:
+		return nil, nil, false
+	}
+}
+
+// readsIdentity returns whether the path of the pipelineRun variable may
+// contain the identity fields or is a bookkeeping annotation.
+func readsIdentity(path []string) bool {
+	if len(path) == 0 || path[0] == "" {
+		return true
+	}
+	if path[0] != "metadata" {
+		return false
+	}
+	if len(path) == 1 || path[1] == "" || identityFields[path[1]] {
+		return true
+	}
+	return path[1] == "annotations" && len(path) > 2 && bookkeepingAnnotations[path[2]]
+}
+
+// testsBookkeepingAnnotation returns whether key in container tests the
+// presence of one of the bookkeeping annotations, e.g.
+// "kueue.konflux-ci.dev/config-hash" in pipelineRun.metadata.annotations.
+func testsBookkeepingAnnotation(key, container celast.Expr) bool {
+	annotation, ok := literalString(key)
+	if !ok || !bookkeepingAnnotations[annotation] {
+		return false
+	}
+	path, _, ok := pipelineRunPath(container)
+	return ok && slices.Equal(path, []string{"metadata", "annotations"})
+}
+
+func isPipelineRunIdent(expr celast.Expr) bool {
+	return expr.Kind() == celast.IdentKind && expr.AsIdent() == "pipelineRun"
+}
+
+func literalString(expr celast.Expr) (string, bool) {
+	if expr.Kind() != celast.LiteralKind {
+		return "", false
+	}
+	s, ok := expr.AsLiteral().Value().(string)
+	return s, ok
+}
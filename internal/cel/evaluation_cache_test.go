@@ -0,0 +1,215 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+func TestReferencesIdentity(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{expression: `label("team", "a")`},
+		{expression: `label("app", pipelineRun.metadata.labels["app"])`},
+		{expression: `label("app", pipelineRun["metadata"]["labels"]["app"])`},
+		{expression: `has(pipelineRun.metadata.annotations.owner) ? [label("owned", "true")] : []`},
+		{expression: `label("namespace", pipelineRun.metadata.namespace)`},
+		{expression: `pipelineRun.spec.params.exists(p, p.name == "x") ? [label("x", "true")] : []`},
+		{expression: `resource("parallel-builds", paramInt("parallelism", 1))`},
+		{expression: `paramBool("skip-tests", false) ? [priority("low")] : []`},
+		{expression: `annotation("x", plrNamespace + pacEventType)`},
+		{expression: `label("name", pipelineRun.metadata.name)`, expected: true},
+		{expression: `label("name", pipelineRun.metadata["name"])`, expected: true},
+		{expression: `label("name", pipelineRun["metadata"].generateName)`, expected: true},
+		{expression: `has(pipelineRun.metadata.generateName) ? [label("generated", "true")] : []`, expected: true},
+		{expression: `pipelineRun.metadata.managedFields.size() > 1 ? [label("x", "y")] : []`, expected: true},
+		{expression: `label("x", string(pipelineRun.metadata))`, expected: true},
+		{expression: `dyn(pipelineRun.metadata).exists(k, k == "x") ? [label("x", "y")] : []`, expected: true},
+		{expression: `label("x", pipelineRun.metadata[pacEventType])`, expected: true},
+		{expression: `label("x", pipelineRun.metadata.labels[pipelineRun.metadata.name])`, expected: true},
+		{expression: `[pipelineRun].size() > 0 ? [label("x", "y")] : []`, expected: true},
+		{expression: `label("pipeline", plrPipelineName)`, expected: true},
+		{expression: `plrGenerateName == "" ? [label("x", "y")] : []`, expected: true},
+		{expression: `label("x", pipelineRun.metadata.labels[pacEventType])`},
+		{expression: `label("x", pipelineRun.metadata.annotations["owner"])`},
+		{expression: `"owner" in pipelineRun.metadata.annotations ? [label("x", "y")] : []`},
+		{expression: `label("uid", pipelineRun.metadata.annotations["kueue.konflux-ci.dev/admission-uid"])`, expected: true},
+		{expression: `"kueue.konflux-ci.dev/config-hash" in pipelineRun.metadata.annotations ? [label("x", "y")] : []`, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs[0].referencesIdentity).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_EvaluationCache(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	programs, err := CompileCELPrograms([]string{
+		`label("team", pipelineRun.metadata.labels["app"] == "web" ? "frontend" : "backend")`,
+		`resource("linux-amd64", 1)`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithEvaluationCache(logr.Discard(), 2))
+	g.Expect(mutator.cache).NotTo(BeNil())
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(celCacheLookupsTotal.WithLabelValues(result))
+	}
+	hitsBefore, missesBefore := counter("hit"), counter("miss")
+
+	newPipelineRun := func(name, app string) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "tenant", Labels: map[string]string{"app": app},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: name}},
+		}}
+	}
+
+	first := newPipelineRun("build-1", "web")
+	g.Expect(mutator.Mutate(ctx, first)).To(Succeed())
+	g.Expect(first.Labels).To(HaveKeyWithValue("team", "frontend"))
+
+	// The PipelineRuns of the same template only differ by their name
+	second := newPipelineRun("build-2", "web")
+	g.Expect(mutator.Mutate(ctx, second)).To(Succeed())
+	g.Expect(second.Labels).To(Equal(first.Labels))
+	g.Expect(second.Annotations).To(Equal(first.Annotations))
+	g.Expect(counter("hit") - hitsBefore).To(Equal(1.0))
+	g.Expect(counter("miss") - missesBefore).To(Equal(1.0))
+
+	// The other fields are part of the key
+	other := newPipelineRun("build-3", "api")
+	g.Expect(mutator.Mutate(ctx, other)).To(Succeed())
+	g.Expect(other.Labels).To(HaveKeyWithValue("team", "backend"))
+	g.Expect(counter("miss") - missesBefore).To(Equal(2.0))
+
+	// The cached mutations aren't shared with the callers
+	mutations, err := mutator.DryRun(newPipelineRun("build-4", "web"))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutations[0].Value = "changed"
+	g.Expect(mutator.Mutate(ctx, newPipelineRun("build-5", "web"))).To(Succeed())
+	third := newPipelineRun("build-6", "web")
+	g.Expect(mutator.Mutate(ctx, third)).To(Succeed())
+	g.Expect(third.Labels).To(HaveKeyWithValue("team", "frontend"))
+
+	// The stability of the results is still tracked on the hits
+	g.Expect(mutator.ResultStability()[0].Admissions).To(Equal(uint64(5)))
+
+	// The cache is empty once the mutator is rebuilt
+	rebuilt := NewCELMutator(programs, WithEvaluationCache(logr.Discard(), 2))
+	g.Expect(rebuilt.cache.entries.Len()).To(Equal(0))
+}
+
+func TestCELMutator_EvaluationCache_BookkeepingAnnotations(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	programs, err := CompileCELPrograms([]string{`label("team", "a")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithEvaluationCache(logr.Discard(), 16))
+	g.Expect(mutator.cache).NotTo(BeNil())
+	hitsBefore := testutil.ToFloat64(celCacheLookupsTotal.WithLabelValues("hit"))
+
+	// The webhook records the UID of the admission request before the
+	// PipelineRuns are mutated
+	newPipelineRun := func(uid string) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+			Name: "build-" + uid, Namespace: "tenant",
+			Annotations: map[string]string{common.AdmissionUIDAnnotation: uid, "owner": "team-a"},
+		}}
+	}
+	first, second := newPipelineRun("1"), newPipelineRun("2")
+	g.Expect(mutator.Mutate(ctx, first)).To(Succeed())
+	g.Expect(mutator.Mutate(ctx, second)).To(Succeed())
+
+	g.Expect(testutil.ToFloat64(celCacheLookupsTotal.WithLabelValues("hit")) - hitsBefore).To(Equal(1.0))
+	g.Expect(mutator.cache.entries.Len()).To(Equal(1))
+	g.Expect(second.Labels).To(HaveKeyWithValue("team", "a"))
+	g.Expect(second.Annotations).To(HaveKeyWithValue(common.AdmissionUIDAnnotation, "2"))
+}
+
+func TestCELMutator_EvaluationCache_Disabled(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`label("team", "a")`,
+		`label("build", pipelineRun.metadata.name)`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithEvaluationCache(logr.Discard(), 16))
+	g.Expect(mutator.cache).To(BeNil())
+
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build-1", Namespace: "tenant"}}
+	g.Expect(mutator.Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("build", "build-1"))
+
+	g.Expect(NewCELMutator(programs[:1], WithEvaluationCache(logr.Discard(), 0)).cache).To(BeNil())
+}
+
+func TestCELMutator_EvaluationCache_CircuitBreaker(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	programs, err := CompileCELPrograms([]string{
+		`label("team", "a")`,
+		`label("owner", pipelineRun.metadata.annotations["owner"])`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs,
+		WithEvaluationCache(logr.Discard(), 16),
+		WithCircuitBreaker(logr.Discard(), CircuitBreakerConfig{FailureThreshold: 1, Window: time.Hour}))
+
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build-1", Namespace: "tenant"}}
+	g.Expect(mutator.Mutate(ctx, plr)).NotTo(Succeed())
+	g.Expect(mutator.cache.entries.Len()).To(Equal(0))
+
+	// The results of the admissions skipping the tripped program aren't
+	// cached
+	plr = &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build-2", Namespace: "tenant"}}
+	g.Expect(mutator.Mutate(ctx, plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("team", "a"))
+	g.Expect(mutator.cache.entries.Len()).To(Equal(0))
+}
+
+func BenchmarkCELMutator_EvaluationCache(b *testing.B) {
+	var expressions []string
+	for i := range 20 {
+		expressions = append(expressions, fmt.Sprintf(
+			`pipelineRun.spec.pipelineSpec.tasks.exists(t, t.name == "task-%d") ? [label("task-%d", "true")] : []`, i, i))
+	}
+	programs, err := CompileCELPrograms(expressions)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []int{0, 128} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			mutator := NewCELMutator(programs, WithEvaluationCache(logr.Discard(), size))
+			template := newGeneratedPipelineRun(20)
+			b.ResetTimer()
+			for i := range b.N {
+				plr := template.DeepCopy()
+				plr.Name = fmt.Sprintf("generated-%d", i)
+				if err := mutator.Mutate(context.Background(), plr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,27 @@
 package cel
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
 	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
 )
 
+// pipelineLabel is the label Tekton sets to the name of the Pipeline of the
+// PipelineRuns.
+const pipelineLabel = "tekton.dev/pipeline"
+
 // CompiledProgram represents a type-safe compiled CEL program
 // Input: *tekv1.PipelineRun
 // Output: []MutationRequest
@@ -16,12 +29,131 @@ type CompiledProgram struct {
 	program    cel.Program
 	ast        *cel.Ast
 	expression string // Store original expression for debugging
+	// name is the name of the expression, if any
+	name string
+	// onError, when not nil, are the mutations returned when the program
+	// fails, instead of failing the evaluation
+	onError []*MutationRequest
+	// taintWarnings lists the user-controlled values passed to priority()
+	taintWarnings []TaintWarning
+	// statusReferences lists the references to pipelineRun.status
+	statusReferences []string
+	// group is the name of the expression group, used to label metrics
+	group string
+	// queue, when set, restricts the program to the PipelineRuns of the
+	// queue
+	queue string
+	// excludeStatus removes the status from the pipelineRun variable
+	excludeStatus bool
+	// referencesIdentity tells that the program may read the name of the
+	// PipelineRun, which disables the evaluation cache
+	referencesIdentity bool
+	// referencesTime tells that the program reads the time of the
+	// evaluation, which disables the evaluation cache
+	referencesTime bool
+	// now returns the time of the evaluations, time.Now when nil
+	now func() time.Time
+	// location is the time zone of the nowWeekday and nowHour variables,
+	// UTC when nil
+	location *time.Location
+	// protectedKeys are the labels and annotations the mutations can't set
+	protectedKeys *ProtectedKeys
+	// policy, when set, restricts the mutations the program may return
+	policy *MutationPolicy
+}
+
+// buildVars returns the values of the variables of the environment, see
+// variableDeclarations, for the PipelineRun and its map, evaluated at now,
+// with the weekday and the hour in location.
+func buildVars(
+	pipelineRun *tekv1.PipelineRun,
+	pipelineRunMap map[string]interface{},
+	namespaceLabels map[string]string,
+	now time.Time,
+	location *time.Location,
+) map[string]interface{} {
+	pacEventType := ""
+	pacTestEventType := ""
+	if pipelineRun.Labels != nil {
+		pacEventType = pipelineRun.Labels["pipelinesascode.tekton.dev/event-type"]
+		pacTestEventType = pipelineRun.Labels["pac.test.appstudio.openshift.io/event-type"]
+	}
+	if namespaceLabels == nil {
+		namespaceLabels = map[string]string{}
+	}
+	vars := map[string]interface{}{
+		"pipelineRun":           pipelineRunMap,
+		"plrNamespace":          pipelineRun.Namespace,
+		"pacEventType":          pacEventType,
+		"pacTestEventType":      pacTestEventType,
+		"pacRetryCount":         pacRetryCount(pipelineRun),
+		"workspaceStorage":      workspaceStorage(pipelineRun),
+		"plrPipelineName":       pipelineName(pipelineRun),
+		"plrGenerateName":       pipelineRun.GenerateName,
+		NamespaceLabelsVariable: namespaceLabels,
+	}
+	maps.Copy(vars, timeVars(now, location))
+	return vars
+}
+
+// pacRetryCountAnnotation is the annotation Pipelines as Code sets to the
+// number of times the PipelineRun was retried.
+const pacRetryCountAnnotation = "pipelinesascode.tekton.dev/retry-count"
+
+// pacRetryCount returns the retry count of the PipelineRun, read from the
+// pacRetryCountAnnotation, or 0 when the annotation is missing or isn't a
+// non-negative integer.
+func pacRetryCount(pipelineRun *tekv1.PipelineRun) int64 {
+	count, err := strconv.ParseInt(pipelineRun.Annotations[pacRetryCountAnnotation], 10, 64)
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// pipelineName returns the logical name of the pipeline of the PipelineRun:
+// the name of its pipelineRef, else the value of its tekton.dev/pipeline
+// label, else its generateName without the trailing dash, e.g. build for
+// build-, or "" when they're all missing.
+func pipelineName(pipelineRun *tekv1.PipelineRun) string {
+	if ref := pipelineRun.Spec.PipelineRef; ref != nil && ref.Name != "" {
+		return ref.Name
+	}
+	if name := pipelineRun.Labels[pipelineLabel]; name != "" {
+		return name
+	}
+	return strings.TrimSuffix(pipelineRun.GenerateName, "-")
+}
+
+// workspaceStorage returns the storage requested by the volumeClaimTemplate
+// of each workspace of the PipelineRun, in bytes, rounded up. The workspaces
+// bound otherwise, or without storage request, are mapped to 0.
+func workspaceStorage(pipelineRun *tekv1.PipelineRun) map[string]int64 {
+	storage := make(map[string]int64, len(pipelineRun.Spec.Workspaces))
+	for _, workspace := range pipelineRun.Spec.Workspaces {
+		storage[workspace.Name] = 0
+		if template := workspace.VolumeClaimTemplate; template != nil {
+			if request, ok := template.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				storage[workspace.Name] = request.Value()
+			}
+		}
+	}
+	return storage
 }
 
 // Evaluate executes the compiled CEL program with a PipelineRun input
 // Input type: *tekv1.PipelineRun (type-safe)
 // Output type: []MutationRequest (validated)
+// The failures of the evaluation are *EvaluationError, those of the
+// returned mutations wrap a *MutationValidationError.
 func (cp *CompiledProgram) Evaluate(pipelineRun *tekv1.PipelineRun) ([]*MutationRequest, error) {
+	return cp.EvaluateContext(context.Background(), pipelineRun)
+}
+
+// EvaluateContext is like Evaluate, but the evaluation is aborted once the
+// context is done, e.g. when the deadline of the admission request is
+// exceeded.
+func (cp *CompiledProgram) EvaluateContext(ctx context.Context, pipelineRun *tekv1.PipelineRun) ([]*MutationRequest, error) {
 	if pipelineRun == nil {
 		return nil, fmt.Errorf("pipelineRun cannot be nil")
 	}
@@ -30,40 +162,65 @@ func (cp *CompiledProgram) Evaluate(pipelineRun *tekv1.PipelineRun) ([]*Mutation
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert PipelineRun to map: %w", err)
 	}
+	return cp.evaluate(ctx, pipelineRun, pipelineRunMap, nil)
+}
 
-	// Create the evaluation context
-	pacEventType := ""
-	pacTestEventType := ""
-	if pipelineRun.Labels != nil {
-		pacEventType = pipelineRun.Labels["pipelinesascode.tekton.dev/event-type"]
-		pacTestEventType = pipelineRun.Labels["pac.test.appstudio.openshift.io/event-type"]
-	}
-	vars := map[string]interface{}{
-		"pipelineRun":      pipelineRunMap,
-		"plrNamespace":     pipelineRun.Namespace,
-		"pacEventType":     pacEventType,
-		"pacTestEventType": pacTestEventType,
+// evaluate executes the program with the PipelineRun and its map, see
+// structToCELMap, and the labels of its namespace, until the context is
+// done. The map isn't modified, so it can be shared by the programs
+// evaluated for the same PipelineRun.
+func (cp *CompiledProgram) evaluate(
+	ctx context.Context,
+	pipelineRun *tekv1.PipelineRun,
+	pipelineRunMap map[string]interface{},
+	namespaceLabels map[string]string,
+) ([]*MutationRequest, error) {
+	if cp.excludeStatus {
+		pipelineRunMap = maps.Clone(pipelineRunMap)
+		delete(pipelineRunMap, "status")
 	}
 
+	// Create the evaluation context. The maps are iterated in the order of
+	// their keys, so the results don't depend on the order of the
+	// iteration of Go maps
+	vars := orderedVars(buildVars(pipelineRun, pipelineRunMap, namespaceLabels, cp.currentTime(), cp.timeZone()))
+
 	// Execute the program
-	out, _, err := cp.program.Eval(vars)
+	out, _, err := cp.program.ContextEval(ctx, vars)
+	if err == nil {
+		// The functions aren't interrupted, only the comprehensions are
+		err = ctx.Err()
+	}
 	if err != nil {
-		RecordEvaluationFailure()
-		return nil, fmt.Errorf("failed to evaluate CEL expression %q: %w", cp.expression, err)
+		RecordEvaluationFailure(cp.group)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, &EvaluationError{Name: cp.name, Expression: cp.expression, Err: fmt.Errorf("aborted: %w", ctxErr)}
+		}
+		return nil, &EvaluationError{Name: cp.name, Expression: cp.expression, Err: err}
 	}
 
 	// Convert the result to []MutationRequest with validation
 	mutations, err := convertToMutationRequests(out)
 	if err != nil {
-		RecordEvaluationFailure()
-		return nil, fmt.Errorf("failed to convert result to MutationRequests for expression %q: %w", cp.expression, err)
+		RecordEvaluationFailure(cp.group)
+		return nil, &EvaluationError{
+			Name:       cp.name,
+			Expression: cp.expression,
+			Err:        fmt.Errorf("failed to convert the result to MutationRequests: %w", err),
+		}
 	}
 
 	// Validate all mutations
 	for i, mutation := range mutations {
-		if err := mutation.Validate(); err != nil {
-			RecordEvaluationFailure()
-			return nil, fmt.Errorf("invalid mutation at index %d for expression %q: %w", i, cp.expression, err)
+		if err := mutation.ValidateProtected(cp.protectedKeys); err != nil {
+			RecordEvaluationFailure(cp.group)
+			return nil, fmt.Errorf("invalid mutation at index %d for expression %s: %w",
+				i, cp.describe(), &MutationValidationError{Mutation: mutation, Err: err})
+		}
+		if err := cp.policy.check(mutation); err != nil {
+			RecordEvaluationFailure(cp.group)
+			return nil, fmt.Errorf("mutation at index %d for expression %s denied by the policy: %w",
+				i, cp.describe(), &MutationValidationError{Mutation: mutation, Err: err})
 		}
 	}
 
@@ -75,10 +232,69 @@ func (cp *CompiledProgram) GetExpression() string {
 	return cp.expression
 }
 
+// fallback returns copies of the onError mutations of the program, nil when
+// it has none. They're returned instead of the failure of the program, and
+// the evaluations falling back on them are neither cached nor tracked by
+// the result stability, as the failures may be transient.
+func (cp *CompiledProgram) fallback() []*MutationRequest {
+	if cp.onError == nil {
+		return nil
+	}
+	mutations := make([]*MutationRequest, 0, len(cp.onError))
+	for _, mutation := range cp.onError {
+		copied := *mutation
+		mutations = append(mutations, &copied)
+	}
+	return mutations
+}
+
+// describe identifies the expression in the messages, by its name, if
+// any, and its source.
+func (cp *CompiledProgram) describe() string {
+	if cp.name != "" {
+		return fmt.Sprintf("'%s' (%q)", cp.name, cp.expression)
+	}
+	return strconv.Quote(cp.expression)
+}
+
+// GetName returns the name of the expression, empty when it has none
+func (cp *CompiledProgram) GetName() string {
+	return cp.name
+}
+
+// GetStatusReferences returns the references to pipelineRun.status, which is
+// empty when PipelineRuns are admitted
+func (cp *CompiledProgram) GetStatusReferences() []string {
+	return cp.statusReferences
+}
+
+// GetGroup returns the name of the group the expression belongs to
+func (cp *CompiledProgram) GetGroup() string {
+	return cp.group
+}
+
+// GetQueue returns the queue whose PipelineRuns the expression mutates,
+// empty when it mutates all of them
+func (cp *CompiledProgram) GetQueue() string {
+	return cp.queue
+}
+
+// appliesTo returns whether the program is evaluated for the PipelineRun,
+// i.e. whether it isn't restricted to the PipelineRuns of another queue.
+func (cp *CompiledProgram) appliesTo(pipelineRun *tekv1.PipelineRun) bool {
+	return cp.queue == "" || cp.queue == pipelineRun.Labels[common.QueueLabel]
+}
+
+// GetTaintWarnings returns the user-controlled values the expression passes
+// to policy-sensitive functions such as priority()
+func (cp *CompiledProgram) GetTaintWarnings() []TaintWarning {
+	return cp.taintWarnings
+}
+
 // convertToMutationRequests converts CEL evaluation result to []MutationRequest with type safety
 func convertToMutationRequests(result ref.Val) ([]*MutationRequest, error) {
 	// Convert the CEL result to a Go native value
-	nativeResult := result.Value()
+	nativeResult := unwrapValue(result)
 
 	// Handle different return types
 	switch v := nativeResult.(type) {
@@ -91,10 +307,11 @@ func convertToMutationRequests(result ref.Val) ([]*MutationRequest, error) {
 		return mutations, nil
 
 	case []ref.Val:
-		// Handle CEL list type containing ref.Val items
+		// Handle CEL list type containing ref.Val items, which are
+		// unwrapped by convertSingleMutation
 		nativeList := make([]interface{}, len(v))
 		for i, item := range v {
-			nativeList[i] = item.Value()
+			nativeList[i] = item
 		}
 		mutations, err := convertListToMutations(nativeList)
 		if err != nil {
@@ -102,7 +319,7 @@ func convertToMutationRequests(result ref.Val) ([]*MutationRequest, error) {
 		}
 		return mutations, nil
 
-	case map[string]interface{}:
+	case map[string]interface{}, map[ref.Val]ref.Val:
 		// Single MutationRequest-compatible map
 		mutation, err := convertSingleMutation(v)
 		if err != nil {
@@ -111,7 +328,7 @@ func convertToMutationRequests(result ref.Val) ([]*MutationRequest, error) {
 		return []*MutationRequest{mutation}, nil
 
 	default:
-		return nil, fmt.Errorf("expected MutationRequest-compatible map or list, got %T", nativeResult)
+		return nil, fmt.Errorf("expected MutationRequest-compatible map or list, got %s", describeValue(nativeResult))
 	}
 }
 
@@ -131,9 +348,9 @@ func convertListToMutations(items []interface{}) ([]*MutationRequest, error) {
 // convertSingleMutation converts a single native Go value to MutationRequest with validation
 // Enforces that maps must be MutationRequest-compatible with proper structure
 func convertSingleMutation(val interface{}) (*MutationRequest, error) {
-	mapVal, ok := val.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("expected MutationRequest-compatible map, got %T", val)
+	mapVal, err := toStringMap(val)
+	if err != nil {
+		return nil, err
 	}
 
 	// Extract and validate all fields
@@ -155,13 +372,80 @@ func convertSingleMutation(val interface{}) (*MutationRequest, error) {
 		return nil, err
 	}
 
+	var separator string
+	if mutationType == MutationTypeAppendAnnotation {
+		separator, err = extractStringField(mapVal, "separator")
+		if err != nil {
+			return nil, err
+		}
+		if separator == "" {
+			return nil, fmt.Errorf("'separator' field cannot be empty")
+		}
+	}
+
 	return &MutationRequest{
-		Type:  mutationType,
-		Key:   key,
-		Value: value,
+		Type:      mutationType,
+		Key:       key,
+		Value:     value,
+		Separator: separator,
 	}, nil
 }
 
+// toStringMap converts the native or CEL representation of a map with
+// string keys to map[string]interface{}. The values are left as is and
+// unwrapped by the field extraction.
+func toStringMap(val interface{}) (map[string]interface{}, error) {
+	val = unwrapValue(val)
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return v, nil
+
+	case map[ref.Val]ref.Val:
+		mapVal := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			keyStr, ok := unwrapValue(key).(string)
+			if !ok {
+				return nil, fmt.Errorf("expected MutationRequest-compatible map with string keys, got a key of type %s", describeValue(unwrapValue(key)))
+			}
+			mapVal[keyStr] = value
+		}
+		return mapVal, nil
+
+	case []interface{}, []ref.Val:
+		return nil, fmt.Errorf("expected MutationRequest-compatible map, got a nested list")
+
+	default:
+		return nil, fmt.Errorf("expected MutationRequest-compatible map, got %s", describeValue(val))
+	}
+}
+
+// unwrapValue returns the native value of CEL values, and nil for CEL nulls.
+// Errors, unknowns and native values are returned as is.
+func unwrapValue(val interface{}) interface{} {
+	rv, ok := val.(ref.Val)
+	switch {
+	case !ok:
+		return val
+	case rv.Type() == types.NullType:
+		return nil
+	case types.IsUnknownOrError(rv):
+		return rv
+	default:
+		return rv.Value()
+	}
+}
+
+// describeValue describes the type of a native value in error messages.
+func describeValue(val interface{}) string {
+	if val == nil {
+		return "null"
+	}
+	if rv, ok := val.(ref.Val); ok {
+		return rv.Type().TypeName()
+	}
+	return fmt.Sprintf("%T", val)
+}
+
 // extractMutationType extracts and validates the mutation type from a map
 func extractMutationType(mapVal map[string]interface{}) (MutationType, error) {
 	typeVal, exists := mapVal["type"]
@@ -169,14 +453,14 @@ func extractMutationType(mapVal map[string]interface{}) (MutationType, error) {
 		return "", fmt.Errorf("missing required 'type' field")
 	}
 
-	typeStr, ok := typeVal.(string)
+	typeStr, ok := unwrapValue(typeVal).(string)
 	if !ok {
-		return "", fmt.Errorf("'type' field must be a string, got %T", typeVal)
+		return "", fmt.Errorf("'type' field must be a string, got %s", describeValue(unwrapValue(typeVal)))
 	}
 
 	mutationType := MutationType(typeStr)
 	if !mutationType.IsValid() {
-		return "", fmt.Errorf("invalid mutation type: %q, must be one of: %v", typeStr, ValidTypes())
+		return "", invalidMutationTypeError(mutationType)
 	}
 
 	return mutationType, nil
@@ -189,20 +473,48 @@ func extractStringField(mapVal map[string]interface{}, fieldName string) (string
 		return "", fmt.Errorf("missing required '%s' field", fieldName)
 	}
 
-	fieldStr, ok := fieldVal.(string)
+	fieldStr, ok := unwrapValue(fieldVal).(string)
 	if !ok {
-		return "", fmt.Errorf("'%s' field must be a string, got %T", fieldName, fieldVal)
+		return "", fmt.Errorf("'%s' field must be a string, got %s", fieldName, describeValue(unwrapValue(fieldVal)))
 	}
 
 	return fieldStr, nil
 }
 
-func structToCELMap(v interface{}) (map[string]interface{}, error) {
-	b, err := json.Marshal(v)
+// structToCELMap converts the PipelineRun to the value of the pipelineRun
+// variable. The JSON round-trip drops the empty fields, so the status,
+// metadata.labels and metadata.annotations keys are added back as empty maps
+// when missing, and expressions don't need has() guards to access them.
+func structToCELMap(pipelineRun *tekv1.PipelineRun) (map[string]interface{}, error) {
+	m, _, err := structToCELMapWithSize(pipelineRun)
+	return m, err
+}
+
+// structToCELMapWithSize is structToCELMap, also returning the size of the
+// JSON of the PipelineRun, in bytes.
+func structToCELMapWithSize(pipelineRun *tekv1.PipelineRun) (map[string]interface{}, int, error) {
+	b, err := json.Marshal(pipelineRun)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	var m map[string]interface{}
-	err = json.Unmarshal(b, &m)
-	return m, err
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, 0, err
+	}
+	ensureMap(m, "status")
+	metadata := ensureMap(m, "metadata")
+	ensureMap(metadata, "labels")
+	ensureMap(metadata, "annotations")
+	return m, len(b), nil
+}
+
+// ensureMap returns the map at the key of m, after setting it to an empty
+// map if it's missing or null.
+func ensureMap(m map[string]interface{}, key string) map[string]interface{} {
+	value, ok := m[key].(map[string]interface{})
+	if !ok {
+		value = map[string]interface{}{}
+		m[key] = value
+	}
+	return value
 }
@@ -1,11 +1,22 @@
 package cel
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
 	. "github.com/onsi/gomega"
 	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/pkg/celtest"
 )
 
 func TestCompiledProgram_Evaluate_TypeSafety(t *testing.T) {
@@ -55,6 +66,95 @@ func TestCompiledProgram_Evaluate_TypeSafety(t *testing.T) {
 				{Type: MutationTypeLabel, Key: "key2", Value: "value2"},
 			},
 		},
+		{
+			name:        "int and bool values are converted to strings",
+			expression:  `[label("count", 5), annotation("negative", -12), annotation("retried", true), label("cached", false)]`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeLabel, Key: "count", Value: "5"},
+				{Type: MutationTypeAnnotation, Key: "negative", Value: "-12"},
+				{Type: MutationTypeAnnotation, Key: "retried", Value: "true"},
+				{Type: MutationTypeLabel, Key: "cached", Value: "false"},
+			},
+		},
+		{
+			name:        "service account and node selector",
+			expression:  `[serviceAccount("release-pipeline"), nodeSelector("kubernetes.io/arch", "arm64")]`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeServiceAccount, Key: ServiceAccountKey, Value: "release-pipeline"},
+				{Type: MutationTypeNodeSelector, Key: "kubernetes.io/arch", Value: "arm64"},
+			},
+		},
+		{
+			name:        "pod set",
+			expression:  `podset("arm-builders", 3, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{
+					Type:  MutationTypePodSet,
+					Key:   "arm-builders",
+					Value: `{"name":"arm-builders","count":3,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}}`,
+				},
+			},
+		},
+		{
+			name:        "computed int value",
+			expression:  `annotation("pipeline-ref-fields", size(pipelineRun.spec.pipelineRef))`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeAnnotation, Key: "pipeline-ref-fields", Value: "1"},
+			},
+		},
+		{
+			name:        "runtime error - converted value validated as a label",
+			expression:  `label("negative", -int("12"))`,
+			pipelineRun: pipelineRun,
+			expectErr:   true,
+			errMsg:      "label value validation failed",
+		},
+		{
+			name:        "split image reference",
+			expression:  `label("registry", split("quay.io/konflux-ci/build:latest", "/")[0])`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeLabel, Key: "registry", Value: "quay.io"},
+			},
+		},
+		{
+			name:        "split without separator match",
+			expression:  `[annotation("first", split(pipelineRun.metadata.name, "/")[0]), annotation("parts", size(split(pipelineRun.metadata.name, "/")))]`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeAnnotation, Key: "first", Value: "test-pipeline"},
+				{Type: MutationTypeAnnotation, Key: "parts", Value: "1"},
+			},
+		},
+		{
+			name:        "runtime error - split with empty separator",
+			expression:  `label("registry", split("quay.io/build", "")[0])`,
+			pipelineRun: pipelineRun,
+			expectErr:   true,
+			errMsg:      "split separator cannot be empty",
+		},
+		{
+			name:        "replace first occurrences",
+			expression:  `[annotation("first", replace("linux/amd64/v2", "/", "-", 1)), annotation("all", replace("linux/amd64/v2", "/", "-", -1)), annotation("none", replace("linux/amd64/v2", "/", "-", 0))]`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeAnnotation, Key: "first", Value: "linux-amd64/v2"},
+				{Type: MutationTypeAnnotation, Key: "all", Value: "linux-amd64-v2"},
+				{Type: MutationTypeAnnotation, Key: "none", Value: "linux/amd64/v2"},
+			},
+		},
+		{
+			name:        "replace first occurrences without match",
+			expression:  `annotation("platform", replace(pipelineRun.metadata.name, "/", "-", 2))`,
+			pipelineRun: pipelineRun,
+			expected: []MutationRequest{
+				{Type: MutationTypeAnnotation, Key: "platform", Value: "test-pipeline"},
+			},
+		},
 		{
 			name:        "nil PipelineRun",
 			expression:  `annotation("test-key", "test-value")`,
@@ -64,7 +164,7 @@ func TestCompiledProgram_Evaluate_TypeSafety(t *testing.T) {
 		},
 		{
 			name:        "runtime error - empty key",
-			expression:  `annotation("", "test-value")`,
+			expression:  `annotation(plrGenerateName, "test-value")`,
 			pipelineRun: pipelineRun,
 			expectErr:   true,
 			errMsg:      "annotation key cannot be empty",
@@ -261,3 +361,799 @@ func TestCompiledProgram_Evaluate_TypeSafety(t *testing.T) {
 		})
 	}
 }
+
+func TestCompiledProgram_Evaluate_ExcludeStatus(t *testing.T) {
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pipeline",
+			Namespace: "test-namespace",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		opts       []CompileOption
+		expected   string
+		expectErr  bool
+	}{
+		{
+			name:       "has() is false when the status is excluded",
+			expression: `annotation("status", has(pipelineRun.status) ? "present" : "absent")`,
+			opts:       []CompileOption{WithExcludeStatus()},
+			expected:   "absent",
+		},
+		{
+			name:       "the status is present by default",
+			expression: `annotation("status", has(pipelineRun.status) ? "present" : "absent")`,
+			expected:   "present",
+		},
+		{
+			name:       "accessing an excluded status fails",
+			expression: `annotation("status", string(pipelineRun.status.startTime))`,
+			opts:       []CompileOption{WithExcludeStatus()},
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression}, tt.opts...)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			mutations, err := programs[0].Evaluate(pipelineRun)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(HaveLen(1))
+			g.Expect(mutations[0].Value).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestStructToCELMap_MinimalPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+
+	m, err := structToCELMap(&tekv1.PipelineRun{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(m).To(HaveKeyWithValue("status", BeEmpty()))
+	g.Expect(m).To(HaveKey("metadata"))
+	metadata := m["metadata"].(map[string]interface{})
+	g.Expect(metadata).To(HaveKeyWithValue("labels", BeEmpty()))
+	g.Expect(metadata).To(HaveKeyWithValue("annotations", BeEmpty()))
+
+	// Existing entries are kept
+	m, err = structToCELMap(&tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "prod"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(m["metadata"]).To(HaveKeyWithValue("labels", map[string]interface{}{"env": "prod"}))
+}
+
+func TestCompiledProgram_Evaluate_MinimalPipelineRun(t *testing.T) {
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pipeline",
+			Namespace: "test-namespace",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{
+			name:       "labels can be checked without has()",
+			expression: `annotation("env", "env" in pipelineRun.metadata.labels ? "set" : "unset")`,
+			expected:   "unset",
+		},
+		{
+			name:       "annotations can be checked without has()",
+			expression: `annotation("owner", "owner" in pipelineRun.metadata.annotations ? "set" : "unset")`,
+			expected:   "unset",
+		},
+		{
+			name:       "the fields of the status can be checked with has()",
+			expression: `annotation("started", has(pipelineRun.status.startTime) ? "yes" : "no")`,
+			expected:   "no",
+		},
+		{
+			name:       "the containers are empty maps",
+			expression: `annotation("sizes", string(size(pipelineRun.metadata.labels) + size(pipelineRun.metadata.annotations) + size(pipelineRun.status)))`,
+			expected:   "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			mutations, err := programs[0].Evaluate(pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(HaveLen(1))
+			g.Expect(mutations[0].Value).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestConvertToMutationRequests(t *testing.T) {
+	adapter := types.DefaultTypeAdapter
+	mutationMap := func(entries ...ref.Val) ref.Val {
+		m := map[ref.Val]ref.Val{}
+		for i := 0; i < len(entries); i += 2 {
+			m[entries[i]] = entries[i+1]
+		}
+		return types.NewDynamicMap(adapter, m)
+	}
+	valid := func() ref.Val {
+		return mutationMap(types.String("type"), types.String("annotation"), types.String("key"), types.String("k"), types.String("value"), types.String("v"))
+	}
+	expected := []*MutationRequest{{Type: MutationTypeAnnotation, Key: "k", Value: "v"}}
+
+	tests := []struct {
+		name     string
+		result   ref.Val
+		expected []*MutationRequest
+		errMsg   string
+	}{
+		{
+			name:     "native map",
+			result:   adapter.NativeToValue(map[string]interface{}{"type": "annotation", "key": "k", "value": "v"}),
+			expected: expected,
+		},
+		{
+			name:     "CEL map",
+			result:   valid(),
+			expected: expected,
+		},
+		{
+			name:     "list of CEL maps",
+			result:   types.NewDynamicList(adapter, []ref.Val{valid(), valid()}),
+			expected: append(expected, expected...),
+		},
+		{
+			name:     "native list of native maps",
+			result:   adapter.NativeToValue([]interface{}{map[string]interface{}{"type": "annotation", "key": "k", "value": "v"}}),
+			expected: expected,
+		},
+		{
+			name:     "empty list",
+			result:   types.NewDynamicList(adapter, []ref.Val{}),
+			expected: []*MutationRequest{},
+		},
+		{
+			name:   "nil result",
+			result: nil,
+			errMsg: "expected MutationRequest-compatible map or list, got null",
+		},
+		{
+			name:   "null result",
+			result: types.NullValue,
+			errMsg: "expected MutationRequest-compatible map or list, got null",
+		},
+		{
+			name:   "error result",
+			result: types.NewErr("no such key"),
+			errMsg: "expected MutationRequest-compatible map or list, got error",
+		},
+		{
+			name:   "string result",
+			result: types.String("annotation"),
+			errMsg: "expected MutationRequest-compatible map or list, got string",
+		},
+		{
+			name:   "map with non-string keys",
+			result: mutationMap(types.Int(1), types.String("annotation"), types.String("key"), types.String("k"), types.String("value"), types.String("v")),
+			errMsg: "expected MutationRequest-compatible map with string keys, got a key of type int64",
+		},
+		{
+			name:   "map with a null key",
+			result: mutationMap(types.NullValue, types.String("annotation")),
+			errMsg: "got a key of type null",
+		},
+		{
+			name:   "nested list",
+			result: types.NewDynamicList(adapter, []ref.Val{types.NewDynamicList(adapter, []ref.Val{valid()})}),
+			errMsg: "failed to convert list item 0: expected MutationRequest-compatible map, got a nested list",
+		},
+		{
+			name:   "null in list",
+			result: types.NewDynamicList(adapter, []ref.Val{valid(), types.NullValue}),
+			errMsg: "failed to convert list item 1: expected MutationRequest-compatible map, got null",
+		},
+		{
+			name:   "string in list",
+			result: types.NewDynamicList(adapter, []ref.Val{types.String("x")}),
+			errMsg: "failed to convert list item 0: expected MutationRequest-compatible map, got string",
+		},
+		{
+			name:   "missing type",
+			result: mutationMap(types.String("key"), types.String("k"), types.String("value"), types.String("v")),
+			errMsg: "missing required 'type' field",
+		},
+		{
+			name:   "type of the wrong type",
+			result: mutationMap(types.String("type"), types.Int(1), types.String("key"), types.String("k"), types.String("value"), types.String("v")),
+			errMsg: "'type' field must be a string, got int64",
+		},
+		{
+			name:   "null key field",
+			result: mutationMap(types.String("type"), types.String("label"), types.String("key"), types.NullValue, types.String("value"), types.String("v")),
+			errMsg: "'key' field must be a string, got null",
+		},
+		{
+			name:   "missing value",
+			result: mutationMap(types.String("type"), types.String("label"), types.String("key"), types.String("k")),
+			errMsg: "missing required 'value' field",
+		},
+		{
+			name:   "list value",
+			result: mutationMap(types.String("type"), types.String("label"), types.String("key"), types.String("k"), types.String("value"), types.NewDynamicList(adapter, []ref.Val{})),
+			errMsg: "'value' field must be a string, got []ref.Val",
+		},
+		{
+			name:   "error value",
+			result: mutationMap(types.String("type"), types.String("label"), types.String("key"), types.String("k"), types.String("value"), types.NewErr("boom")),
+			errMsg: "'value' field must be a string, got error",
+		},
+		{
+			name:   "native map with a null value",
+			result: adapter.NativeToValue(map[string]interface{}{"type": "annotation", "key": "k", "value": nil}),
+			errMsg: "'value' field must be a string, got null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			mutations, err := convertToMutationRequests(tt.result)
+			if tt.errMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCompiledProgram_Evaluate_MapLiteral(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`{"type": "annotation", "key": "k", "value": "v"}`,
+		`[{"type": "label", "key": "k", "value": "v"}]`,
+		`{"type": "annotation", "key": "k", "value": dyn(1)}`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	mutations, err := programs[0].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(Equal([]*MutationRequest{{Type: MutationTypeAnnotation, Key: "k", Value: "v"}}))
+
+	mutations, err = programs[1].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(Equal([]*MutationRequest{{Type: MutationTypeLabel, Key: "k", Value: "v"}}))
+
+	_, err = programs[2].Evaluate(pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("'value' field must be a string, got int64")))
+}
+
+func TestCompiledProgram_Evaluate_BulkMutations(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   []*MutationRequest
+	}{
+		{
+			name:       "empty map",
+			expression: `labels({})`,
+			expected:   []*MutationRequest{},
+		},
+		{
+			name:       "multiple labels sorted by key",
+			expression: `labels({"env": plrNamespace, "app": "tekton-pipeline", "version": "v1"})`,
+			expected: []*MutationRequest{
+				{Type: MutationTypeLabel, Key: "app", Value: "tekton-pipeline"},
+				{Type: MutationTypeLabel, Key: "env", Value: "test-ns"},
+				{Type: MutationTypeLabel, Key: "version", Value: "v1"},
+			},
+		},
+		{
+			name:       "annotations composed with a list",
+			expression: `[priority("high")] + annotations({"owner": "team-a", "tekton.dev/pipeline": plrPipelineName})`,
+			expected: []*MutationRequest{
+				{Type: MutationTypeLabel, Key: "kueue.x-k8s.io/priority-class", Value: "high"},
+				{Type: MutationTypeAnnotation, Key: "owner", Value: "team-a"},
+				{Type: MutationTypeAnnotation, Key: "tekton.dev/pipeline", Value: "build"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"},
+				Spec:       tekv1.PipelineRunSpec{PipelineRef: &tekv1.PipelineRef{Name: "build"}},
+			}
+			mutations, err := programs[0].Evaluate(pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(Equal(tt.expected))
+		})
+	}
+
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{`labels({"team": pipelineRun.metadata.annotations["team"]})`})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name: "test", Namespace: "test-ns", Annotations: map[string]string{"team": "team a"},
+	}}
+	_, err = programs[0].Evaluate(pipelineRun)
+	var evaluationErr *EvaluationError
+	g.Expect(errors.As(err, &evaluationErr)).To(BeTrue(), "%v", err)
+	g.Expect(err).To(MatchError(ContainSubstring(`labels entry "team": value validation failed`)))
+}
+
+func FuzzConvertToMutationRequests(f *testing.F) {
+	for _, seed := range []string{
+		`{"type": "annotation", "key": "k", "value": "v"}`,
+		`[{"type": "label", "key": "k", "value": "v"}, {"type": "resource", "key": "r", "value": "1"}]`,
+		`{dyn(1): "annotation", "key": "k", "value": "v"}`,
+		`[[{"type": "annotation", "key": "k", "value": "v"}]]`,
+		`[null, {"type": "annotation"}]`,
+		`{"type": null, "key": 1, "value": [1]}`,
+		`dyn([{"type": 2.0, "key": b"k", "value": {"a": "b"}}])`,
+		`[dyn({"type": "annotation", "key": "", "value": "v"})]`,
+		`{"type": "annotation", "key": "k", "value": "v"}.key`,
+		`null`,
+		`[]`,
+		`{}`,
+	} {
+		f.Add(seed)
+	}
+
+	env, err := cel.NewEnv()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Fuzz(func(t *testing.T, expression string) {
+		ast, issues := env.Compile(expression)
+		if issues != nil && issues.Err() != nil {
+			return
+		}
+		program, err := env.Program(ast, cel.CostLimit(10000))
+		if err != nil {
+			return
+		}
+		out, _, err := program.Eval(cel.NoVars())
+		if err != nil {
+			return
+		}
+
+		mutations, err := convertToMutationRequests(out)
+		if err != nil {
+			return
+		}
+		for _, mutation := range mutations {
+			if !mutation.Type.IsValid() || mutation.Key == "" {
+				t.Errorf("invalid mutation %+v converted from %q", mutation, expression)
+			}
+		}
+	})
+}
+
+func TestCompiledProgram_Evaluate_PipelineName(t *testing.T) {
+	tests := []struct {
+		name         string
+		pipelineRef  *tekv1.PipelineRef
+		labels       map[string]string
+		generateName string
+		expected     string
+	}{
+		{
+			name:         "pipelineRef",
+			pipelineRef:  &tekv1.PipelineRef{Name: "build"},
+			labels:       map[string]string{"tekton.dev/pipeline": "label"},
+			generateName: "generated-",
+			expected:     "build",
+		},
+		{
+			name:         "label",
+			labels:       map[string]string{"tekton.dev/pipeline": "label"},
+			generateName: "generated-",
+			expected:     "label",
+		},
+		{
+			name:         "resolver pipelineRef without name",
+			pipelineRef:  &tekv1.PipelineRef{ResolverRef: tekv1.ResolverRef{Resolver: "git"}},
+			labels:       map[string]string{"tekton.dev/pipeline": "label"},
+			generateName: "generated-",
+			expected:     "label",
+		},
+		{
+			name:         "generateName",
+			generateName: "generated-",
+			expected:     "generated",
+		},
+		{
+			name:     "all missing",
+			expected: "",
+		},
+	}
+
+	programs, err := CompileCELPrograms([]string{
+		`annotation("pipeline", plrPipelineName == "" ? "none" : plrPipelineName)`,
+		`annotation("generate-name", plrGenerateName == "" ? "none" : plrGenerateName)`,
+	})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "run", GenerateName: tt.generateName, Namespace: "test-namespace", Labels: tt.labels,
+				},
+				Spec: tekv1.PipelineRunSpec{PipelineRef: tt.pipelineRef},
+			}
+
+			expected, generateName := tt.expected, tt.generateName
+			if expected == "" {
+				expected = "none"
+			}
+			if generateName == "" {
+				generateName = "none"
+			}
+			mutations, err := programs[0].Evaluate(pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(ConsistOf(&MutationRequest{
+				Type: MutationTypeAnnotation, Key: "pipeline", Value: expected,
+			}))
+			mutations, err = programs[1].Evaluate(pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(ConsistOf(&MutationRequest{
+				Type: MutationTypeAnnotation, Key: "generate-name", Value: generateName,
+			}))
+		})
+	}
+}
+
+func TestCompiledProgram_Evaluate_PacRetryCount(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:     "absent",
+			expected: "0",
+		},
+		{
+			name:        "retried twice",
+			annotations: map[string]string{"pipelinesascode.tekton.dev/retry-count": "2"},
+			expected:    "2",
+		},
+		{
+			name:        "garbage",
+			annotations: map[string]string{"pipelinesascode.tekton.dev/retry-count": "two"},
+			expected:    "0",
+		},
+		{
+			name:        "negative",
+			annotations: map[string]string{"pipelinesascode.tekton.dev/retry-count": "-1"},
+			expected:    "0",
+		},
+	}
+
+	programs, err := CompileCELPrograms([]string{`annotation("retries", string(pacRetryCount))`})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "test-namespace", Annotations: tt.annotations},
+			}
+
+			mutations, err := programs[0].Evaluate(pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(ConsistOf(&MutationRequest{
+				Type: MutationTypeAnnotation, Key: "retries", Value: tt.expected,
+			}))
+		})
+	}
+}
+
+func TestCompiledProgram_Evaluate_NamespaceLabels(t *testing.T) {
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{
+		`"tier" in namespaceLabels ? annotation("tier", namespaceLabels["tier"]) : annotation("tier", "none")`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "test-namespace"}}
+	pipelineRunMap, err := structToCELMap(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mutations, err := programs[0].evaluate(context.Background(), pipelineRun, pipelineRunMap,
+		map[string]string{"tier": "gold"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(&MutationRequest{Type: MutationTypeAnnotation, Key: "tier", Value: "gold"}))
+
+	// The variable is empty when the namespaces aren't exposed
+	mutations, err = programs[0].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(&MutationRequest{Type: MutationTypeAnnotation, Key: "tier", Value: "none"}))
+}
+
+func TestCompiledProgram_Evaluate_WorkspaceStorage(t *testing.T) {
+	volumeClaimTemplate := func(requests corev1.ResourceList) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.VolumeResourceRequirements{Requests: requests},
+			},
+		}
+	}
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pipeline", Namespace: "test-namespace"},
+		Spec: tekv1.PipelineRunSpec{
+			PipelineRef: &tekv1.PipelineRef{Name: "test-pipeline"},
+			Workspaces: []tekv1.WorkspaceBinding{
+				{
+					Name: "source",
+					VolumeClaimTemplate: volumeClaimTemplate(corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					}),
+				},
+				{
+					Name: "cache",
+					VolumeClaimTemplate: volumeClaimTemplate(corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1.5k"),
+					}),
+				},
+				{Name: "no-request", VolumeClaimTemplate: volumeClaimTemplate(nil)},
+				{Name: "scratch", EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				{
+					Name:                  "shared",
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "shared"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		pipelineRun *tekv1.PipelineRun
+		expression  string
+		expected    string
+	}{
+		{
+			name:        "volumeClaimTemplate request",
+			pipelineRun: pipelineRun,
+			expression:  `annotation("storage", string(workspaceStorage["source"]))`,
+			expected:    "1073741824",
+		},
+		{
+			name:        "fractional request is rounded up",
+			pipelineRun: pipelineRun,
+			expression:  `annotation("storage", string(workspaceStorage["cache"]))`,
+			expected:    "1500",
+		},
+		{
+			name:        "other bindings and missing requests are 0",
+			pipelineRun: pipelineRun,
+			expression: `annotation("storage", string(workspaceStorage["no-request"] + ` +
+				`workspaceStorage["scratch"] + workspaceStorage["shared"]))`,
+			expected: "0",
+		},
+		{
+			name:        "every workspace is listed",
+			pipelineRun: pipelineRun,
+			expression:  `annotation("storage", string(size(workspaceStorage)))`,
+			expected:    "5",
+		},
+		{
+			name:        "aggregation over the workspaces",
+			pipelineRun: pipelineRun,
+			expression: `annotation("storage", string(` +
+				`workspaceStorage.filter(w, workspaceStorage[w] >= quantityToBytes("1Mi")).size()))`,
+			expected: "1",
+		},
+		{
+			name:        "total storage of the workspaces",
+			pipelineRun: pipelineRun,
+			expression:  `annotation("storage", string(sum(workspaceStorage.map(w, workspaceStorage[w]))))`,
+			expected:    "1073743324",
+		},
+		{
+			name: "no workspaces",
+			pipelineRun: &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pipeline", Namespace: "test-namespace"},
+			},
+			expression: `annotation("storage", string(size(workspaceStorage)))`,
+			expected:   "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutations, err := programs[0].Evaluate(tt.pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(HaveLen(1))
+			g.Expect(mutations[0].Value).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCompiledProgram_Evaluate_Params(t *testing.T) {
+	withParam := func(name string, value tekv1.ParamValue) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-pipeline", Namespace: "test-namespace"},
+			Spec: tekv1.PipelineRunSpec{
+				Params: tekv1.Params{
+					{Name: "other", Value: *tekv1.NewStructuredValues("3")},
+					{Name: name, Value: value},
+				},
+			},
+		}
+	}
+	parallelism := func(value string) *tekv1.PipelineRun {
+		return withParam("parallelism", *tekv1.NewStructuredValues(value))
+	}
+
+	tests := []struct {
+		name        string
+		pipelineRun *tekv1.PipelineRun
+		expression  string
+		expected    string
+	}{
+		{
+			name:        "integer",
+			pipelineRun: parallelism("7"),
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "7",
+		},
+		{
+			name:        "surrounding spaces",
+			pipelineRun: parallelism(" 7 "),
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "7",
+		},
+		{
+			name:        "missing",
+			pipelineRun: &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test-pipeline"}},
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "1",
+		},
+		{
+			name:        "decimal",
+			pipelineRun: parallelism("7.5"),
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "1",
+		},
+		{
+			name:        "not a number",
+			pipelineRun: parallelism("banana"),
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "1",
+		},
+		{
+			name:        "array",
+			pipelineRun: withParam("parallelism", *tekv1.NewStructuredValues("7", "8")),
+			expression:  `annotation("parallelism", string(paramInt("parallelism", 1)))`,
+			expected:    "1",
+		},
+		{
+			name:        "comparison",
+			pipelineRun: parallelism("7"),
+			expression:  `annotation("large", string(paramInt("parallelism", 1) > 4))`,
+			expected:    "true",
+		},
+		{
+			name:        "resource request",
+			pipelineRun: parallelism("7"),
+			expression:  `resource("parallel-builds", paramInt("parallelism", 1))`,
+			expected:    "7",
+		},
+		{
+			name:        "bool",
+			pipelineRun: withParam("skip-tests", *tekv1.NewStructuredValues("true")),
+			expression:  `annotation("skip-tests", string(paramBool("skip-tests", false)))`,
+			expected:    "true",
+		},
+		{
+			name:        "missing bool",
+			pipelineRun: parallelism("7"),
+			expression:  `annotation("skip-tests", string(paramBool("skip-tests", true)))`,
+			expected:    "true",
+		},
+		{
+			name:        "invalid bool",
+			pipelineRun: withParam("skip-tests", *tekv1.NewStructuredValues("yes")),
+			expression:  `annotation("skip-tests", string(paramBool("skip-tests", false)))`,
+			expected:    "false",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutations, err := programs[0].Evaluate(tt.pipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(HaveLen(1))
+			g.Expect(mutations[0].Value).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCompiledProgram_EvaluateContext(t *testing.T) {
+	// The nested comprehensions iterate over the params squared
+	programs, err := CompileCELPrograms([]string{`
+		pipelineRun.spec.params.all(a, pipelineRun.spec.params.all(b, a.name != b.name || a == b))
+			? label("unique-params", "true") : label("unique-params", "false")`,
+	})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	program := programs[0]
+
+	manyParams := func(n int) *tekv1.PipelineRun {
+		var params []tekv1.Param
+		for i := range n {
+			params = append(params, celtest.StringParam(fmt.Sprintf("param-%d", i), "value"))
+		}
+		return celtest.NewPipelineRun(celtest.WithParams(params...))
+	}
+
+	t.Run("evaluates until completion", func(t *testing.T) {
+		g := NewWithT(t)
+		mutations, err := program.EvaluateContext(context.Background(), manyParams(10))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(mutations).To(ConsistOf(&MutationRequest{Type: MutationTypeLabel, Key: "unique-params", Value: "true"}))
+	})
+
+	t.Run("aborts once cancelled", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := program.EvaluateContext(ctx, manyParams(3000))
+		g.Expect(err).To(MatchError(context.Canceled))
+		g.Expect(err).To(MatchError(ContainSubstring("aborted")))
+	})
+
+	t.Run("aborts once the deadline is exceeded", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := program.EvaluateContext(ctx, manyParams(3000))
+		g.Expect(err).To(MatchError(context.DeadlineExceeded))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	t.Run("aborts the mutations once cancelled", func(t *testing.T) {
+		g := NewWithT(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		pipelineRun := manyParams(3000)
+		err := NewCELMutator(programs).Mutate(ctx, pipelineRun)
+		g.Expect(err).To(MatchError(context.Canceled))
+		g.Expect(pipelineRun.Labels).NotTo(HaveKey("unique-params"))
+	})
+}
@@ -0,0 +1,133 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// ProgramExplanation describes a program of a CELMutator and, for a sample
+// PipelineRun, the mutations it returns.
+type ProgramExplanation struct {
+	// Group is the name of the expression group of the program.
+	Group string `json:"group,omitempty"`
+	// Index is the position of the program in its group.
+	Index int `json:"index"`
+	// Name is the name of the expression, if any.
+	Name string `json:"name,omitempty"`
+	// Queue, when set, is the queue whose PipelineRuns the program
+	// mutates. It isn't evaluated for the sample PipelineRuns of the other
+	// queues.
+	Queue      string `json:"queue,omitempty"`
+	Expression string `json:"expression"`
+	// Warnings are the warnings of the compilation, e.g. the
+	// policy-sensitive functions whose argument is user-controlled.
+	Warnings []string `json:"warnings,omitempty"`
+	// SkippedUntil, when set, is the end of the window the program is
+	// skipped for, its circuit breaker being open.
+	SkippedUntil *time.Time `json:"skippedUntil,omitempty"`
+	// Mutations are the mutations returned by the program for the sample
+	// PipelineRun.
+	Mutations []*MutationRequest `json:"mutations,omitempty"`
+	// Error is the error of the evaluation of the program for the sample
+	// PipelineRun.
+	Error string `json:"error,omitempty"`
+}
+
+// MutatorExplanation describes the programs of a CELMutator, in evaluation
+// order, the options changing how their mutations are applied and, for a
+// sample PipelineRun, the mutations applied to it.
+type MutatorExplanation struct {
+	Programs                 []ProgramExplanation `json:"programs"`
+	TimeoutOverride          bool                 `json:"timeoutOverride,omitempty"`
+	ServiceAccountOverride   bool                 `json:"serviceAccountOverride,omitempty"`
+	OverwritePolicy          OverwritePolicy      `json:"overwritePolicy,omitempty"`
+	ResourceKeyNormalization bool                 `json:"resourceKeyNormalization,omitempty"`
+	ResourceWritePrefixes    []string             `json:"resourceWritePrefixes,omitempty"`
+	ResourceReadPrefixes     []string             `json:"resourceReadPrefixes,omitempty"`
+	// PruneUnknownResourceRequests tells that the resource annotations
+	// whose resource isn't produced by the programs nor allowed are
+	// removed, see WithResourceRequestPruning.
+	PruneUnknownResourceRequests bool `json:"pruneUnknownResourceRequests,omitempty"`
+	// Mutations are the mutations applied to the sample PipelineRun, once
+	// the resource keys are normalized and the resource mutations expanded
+	// to the write prefixes.
+	Mutations []*MutationRequest `json:"mutations,omitempty"`
+	// Error is the error of the mutation of the sample PipelineRun.
+	Error string `json:"error,omitempty"`
+	// Result is the sample PipelineRun once mutated, unless the mutation
+	// failed.
+	Result *tekv1.PipelineRun `json:"-"`
+}
+
+// Explain describes the programs of the mutator and, when pipelineRun isn't
+// nil, the mutations each program returns for it and the mutations Mutate
+// would apply to it. The programs whose circuit breaker is open are skipped,
+// as by Mutate. Like DryRun, the PipelineRun isn't modified and the results
+// aren't logged or tracked, but the failed evaluations are counted by the
+// metrics.
+func (m *CELMutator) Explain(pipelineRun *tekv1.PipelineRun) *MutatorExplanation {
+	explanation := &MutatorExplanation{
+		Programs:                     make([]ProgramExplanation, 0, len(m.programs)),
+		TimeoutOverride:              m.timeoutOverride,
+		ServiceAccountOverride:       m.serviceAccountOverride,
+		OverwritePolicy:              m.overwritePolicy,
+		ResourceKeyNormalization:     len(m.keyNormalizationRules) > 0,
+		ResourceWritePrefixes:        m.resourceWritePrefixes,
+		ResourceReadPrefixes:         m.resourceReadPrefixes,
+		PruneUnknownResourceRequests: m.pruneResourceRequests,
+	}
+	indexes := map[string]int{}
+	allowed := make([]*CompiledProgram, 0, len(m.programs))
+	for i, program := range m.programs {
+		p := ProgramExplanation{
+			Group:      program.group,
+			Index:      indexes[program.group],
+			Name:       program.name,
+			Queue:      program.queue,
+			Expression: program.expression,
+		}
+		indexes[program.group]++
+		for _, warning := range program.taintWarnings {
+			p.Warnings = append(p.Warnings, warning.String())
+		}
+		if len(program.statusReferences) > 0 {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"references the PipelineRun status, which is empty when PipelineRuns are admitted: %v",
+				program.statusReferences))
+		}
+		if m.breaker != nil {
+			if until, open := m.breaker.openUntil(i); open {
+				p.SkippedUntil = &until
+			}
+		}
+		if p.SkippedUntil == nil {
+			allowed = append(allowed, program)
+			if pipelineRun != nil && program.appliesTo(pipelineRun) {
+				mutations, err := program.Evaluate(pipelineRun.DeepCopy())
+				if err != nil {
+					p.Error = err.Error()
+				}
+				p.Mutations = mutations
+			}
+		}
+		explanation.Programs = append(explanation.Programs, p)
+	}
+	if pipelineRun == nil {
+		return explanation
+	}
+
+	applied := *m
+	applied.programs = allowed
+	result := pipelineRun.DeepCopy()
+	mutations, err := applied.apply(context.Background(), result, nil, nil)
+	if err != nil {
+		explanation.Error = err.Error()
+		return explanation
+	}
+	explanation.Mutations = mutations
+	explanation.Result = result
+	return explanation
+}
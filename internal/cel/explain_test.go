@@ -0,0 +1,59 @@
+package cel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCELMutator_Explain(t *testing.T) {
+	g := NewWithT(t)
+	mutator, clock := newBreakerMutator(g, "explain-test", WithTimeoutOverride())
+
+	// Without sample, the programs are only described
+	explanation := mutator.Explain(nil)
+	g.Expect(explanation.TimeoutOverride).To(BeTrue())
+	g.Expect(explanation.Programs).To(HaveLen(2))
+	g.Expect(explanation.Programs[1]).To(Equal(ProgramExplanation{
+		Group: "explain-test", Index: 1, Expression: flakyExpression,
+	}))
+	g.Expect(explanation.Mutations).To(BeEmpty())
+	g.Expect(explanation.Result).To(BeNil())
+
+	// Each program is evaluated against the sample, which isn't modified
+	sample := newBreakerPipelineRun("team-a")
+	explanation = mutator.Explain(sample)
+	g.Expect(explanation.Error).To(BeEmpty())
+	g.Expect(explanation.Programs[0].Mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeLabel, Key: "kueue.x-k8s.io/priority-class", Value: "high"}))
+	g.Expect(explanation.Programs[1].Mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeAnnotation, Key: "owner", Value: "team-a"}))
+	g.Expect(explanation.Mutations).To(HaveLen(2))
+	g.Expect(explanation.Result.Annotations).To(HaveKeyWithValue("owner", "team-a"))
+	g.Expect(sample.Annotations).NotTo(HaveKey("owner"))
+	g.Expect(sample.Labels).To(BeEmpty())
+
+	// The failure of a program fails the mutation
+	explanation = mutator.Explain(newBreakerPipelineRun(""))
+	g.Expect(explanation.Programs[0].Error).To(BeEmpty())
+	g.Expect(explanation.Programs[1].Error).To(ContainSubstring("failed to evaluate"))
+	g.Expect(explanation.Error).NotTo(BeEmpty())
+	g.Expect(explanation.Result).To(BeNil())
+
+	// The programs whose circuit breaker is open are skipped, as by Mutate
+	for range 3 {
+		g.Expect(mutator.Mutate(context.Background(), newBreakerPipelineRun(""))).NotTo(Succeed())
+	}
+	explanation = mutator.Explain(newBreakerPipelineRun(""))
+	g.Expect(explanation.Programs[1].SkippedUntil).To(HaveValue(Equal(clock.Add(time.Minute))))
+	g.Expect(explanation.Programs[1].Mutations).To(BeEmpty())
+	g.Expect(explanation.Error).To(BeEmpty())
+	g.Expect(explanation.Mutations).To(HaveLen(1))
+
+	// Explaining doesn't close the breaker once the window has passed
+	*clock = clock.Add(2 * time.Minute)
+	g.Expect(mutator.Explain(nil).Programs[1].SkippedUntil).To(BeNil())
+	g.Expect(mutator.breaker.programs[1].openUntil).NotTo(BeZero())
+}
@@ -0,0 +1,91 @@
+package cel
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func TestAllowedKeyPrefixes(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefixes   []string
+		expression string
+		expected   []*MutationRequest
+		errorMsg   string
+	}{
+		{
+			name:       "annotation key of an allowed prefix",
+			prefixes:   []string{"policies.acme.com/"},
+			expression: `annotation("policies.acme.com/build/verify", "true")`,
+			expected: []*MutationRequest{
+				{Type: MutationTypeAnnotation, Key: "policies.acme.com/build/verify", Value: "true"},
+			},
+		},
+		{
+			name:       "appended annotation key of an allowed prefix",
+			prefixes:   []string{"other.acme.com/", "policies.acme.com/build/"},
+			expression: `appendAnnotation("policies.acme.com/build/checks", "sast", ",")`,
+			expected: []*MutationRequest{
+				{Type: MutationTypeAppendAnnotation, Key: "policies.acme.com/build/checks", Value: "sast", Separator: ","},
+			},
+		},
+		{
+			name:       "label key of an allowed prefix",
+			prefixes:   []string{"policies.acme.com/build/"},
+			expression: `label("policies.acme.com/build/tier", "gold")`,
+			errorMsg:   "label key 'policies.acme.com/build/tier' is invalid",
+		},
+		{
+			name:       "labels key of an allowed prefix",
+			prefixes:   []string{"policies.acme.com/build/"},
+			expression: `labels({"policies.acme.com/build/tier": "gold"})`,
+			errorMsg:   "label key 'policies.acme.com/build/tier' is invalid",
+		},
+		{
+			name:       "key not matching the allowed prefixes",
+			prefixes:   []string{"policies.acme.com/"},
+			expression: `annotation("tools.acme.com/build/verify", "true")`,
+			errorMsg:   "annotation key 'tools.acme.com/build/verify' is invalid",
+		},
+		{
+			name:       "no allowed prefixes",
+			expression: `annotation("policies.acme.com/build/verify", "true")`,
+			errorMsg:   "annotation key 'policies.acme.com/build/verify' is invalid",
+		},
+		{
+			name:       "too long name of an allowed prefix",
+			prefixes:   []string{"policies.acme.com/"},
+			expression: `annotation("policies.acme.com/build/` + strings.Repeat("x", 60) + `", "true")`,
+			errorMsg:   "name part must be non-empty and no more than 63 characters",
+		},
+		{
+			name:       "empty name of an allowed prefix",
+			prefixes:   []string{"policies.acme.com/"},
+			expression: `annotation("policies.acme.com/", "true")`,
+			errorMsg:   "name part must be non-empty and no more than 63 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// The literal keys are validated when the expression is compiled,
+			// the keys of the maps when it's evaluated
+			programs, err := CompileCELPrograms([]string{tt.expression}, WithAllowedKeyPrefixes(tt.prefixes))
+			var mutations []*MutationRequest
+			if err == nil {
+				mutations, err = programs[0].Evaluate(&tekv1.PipelineRun{})
+			}
+			if tt.errorMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(mutations).To(Equal(tt.expected))
+		})
+	}
+}
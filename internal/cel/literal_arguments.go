@@ -0,0 +1,80 @@
+package cel
+
+import (
+	"fmt"
+	"strconv"
+
+	celast "github.com/google/cel-go/common/ast"
+)
+
+// validateLiteralArguments validates the literal arguments of the calls of
+// resource(), label(), annotation() and priority() with the validation of
+// their bindings, so obviously wrong arguments, e.g. resource("x", -1), are
+// compile errors instead of failing every evaluation. The other arguments
+// are only known when the expression is evaluated.
+func validateLiteralArguments(native *celast.AST, keys keyValidator) error {
+	var err error
+	celast.PreOrderVisit(native.Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if err != nil || expr.Kind() != celast.CallKind || expr.AsCall().IsMemberFunction() {
+			return
+		}
+		call := expr.AsCall()
+		for i, arg := range call.Args() {
+			if arg.Kind() != celast.LiteralKind {
+				continue
+			}
+			argErr := validateLiteralArgument(call.FunctionName(), i, arg.AsLiteral().Value(), keys)
+			if argErr != nil {
+				location := native.SourceInfo().GetStartLocation(arg.ID())
+				err = fmt.Errorf("invalid argument %d of %s() at line %d, column %d: %w",
+					i+1, call.FunctionName(), location.Line(), location.Column()+1, argErr)
+				return
+			}
+		}
+	}))
+	return err
+}
+
+// validateLiteralArgument validates the value of the argument of the
+// function at the index, if it's one of the validated ones.
+func validateLiteralArgument(function string, index int, value any, keys keyValidator) error {
+	switch function {
+	case "resource":
+		if key, ok := value.(string); ok && index == 0 {
+			return validateResourceKey(key)
+		}
+		if quantity, ok := value.(int64); ok && index == 1 {
+			return validateResourceValue(quantity)
+		}
+	case "label", "annotation":
+		mutationType := MutationType(function)
+		if key, ok := value.(string); ok && index == 0 {
+			return validateMutationKey(mutationType, keys, key)
+		}
+		if index != 1 {
+			return nil
+		}
+		// The values are converted like the bindings of the overloads do
+		switch v := value.(type) {
+		case string:
+			return validateMutationValue(mutationType, v)
+		case int64:
+			return validateMutationValue(mutationType, strconv.FormatInt(v, 10))
+		case bool:
+			return validateMutationValue(mutationType, strconv.FormatBool(v))
+		}
+	case "priority":
+		class, ok := value.(string)
+		if !ok || index != 0 {
+			return nil
+		}
+		// The mutation is validated when the expression is evaluated, and
+		// the API server rejects the invalid label values
+		mutation := MutationRequest{Type: MutationTypeLabel, Key: priorityLabel, Value: class}
+		if err := mutation.Validate(); err != nil {
+			return err
+		}
+		return validateMutationValue(MutationTypeLabel, class)
+	}
+	return nil
+}
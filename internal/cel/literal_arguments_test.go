@@ -0,0 +1,140 @@
+package cel
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCompileCELPrograms_LiteralArguments(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		errMsg     string
+	}{
+		{
+			name:       "invalid resource key",
+			expression: `resource("-bad-key", 5)`,
+			errMsg:     "invalid argument 1 of resource() at line 1, column 10: key validation failed",
+		},
+		{
+			name:       "empty resource key",
+			expression: `resource("", 5)`,
+			errMsg:     "invalid argument 1 of resource() at line 1, column 10: key cannot be empty",
+		},
+		{
+			name:       "negative resource quantity",
+			expression: `resource("x", -1)`,
+			errMsg:     "invalid argument 2 of resource() at line 1, column 15: value must be positive (>= 0), got -1",
+		},
+		{
+			name:       "invalid label key",
+			expression: `label("team!", "a")`,
+			errMsg:     "invalid argument 1 of label() at line 1, column 7: key validation failed",
+		},
+		{
+			name:       "invalid label value",
+			expression: `label("team", "a b")`,
+			errMsg:     "invalid argument 2 of label() at line 1, column 15: value validation failed",
+		},
+		{
+			name:       "label value converted from an int",
+			expression: `label("negative", -12)`,
+			errMsg:     "invalid argument 2 of label() at line 1, column 19: value validation failed",
+		},
+		{
+			name:       "invalid annotation key",
+			expression: `annotation("", "a")`,
+			errMsg:     "invalid argument 1 of annotation() at line 1, column 12: key cannot be empty",
+		},
+		{
+			name:       "empty priority class",
+			expression: `priority("")`,
+			errMsg:     "invalid argument 1 of priority() at line 1, column 10: mutation value cannot be empty",
+		},
+		{
+			name:       "invalid priority class",
+			expression: `priority("-high")`,
+			errMsg:     "invalid argument 1 of priority() at line 1, column 10: value validation failed",
+		},
+		{
+			name: "nested call",
+			expression: `plrNamespace == "production" ?
+  [priority("high"), resource("x", -1)] :
+  []`,
+			errMsg: "invalid argument 2 of resource() at line 2, column 36",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := CompileCELPrograms([]string{tt.expression})
+			var compileErr *CompileError
+			g.Expect(errors.As(err, &compileErr)).To(BeTrue(), "%v", err)
+			g.Expect(compileErr.Expression).To(Equal(tt.expression))
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
+func TestCompileCELPrograms_ValidLiteralArguments(t *testing.T) {
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{
+		`resource("linux-amd64", 0)`,
+		`[label("team", "a"), label("count", 3), label("enabled", true)]`,
+		`annotation("owner", "")`,
+		`priority("high")`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(programs).To(HaveLen(4))
+}
+
+func TestCompileCELPrograms_DynamicArgumentsAtRuntime(t *testing.T) {
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test",
+		Namespace:   "test-ns",
+		Annotations: map[string]string{"key": "-bad-key"},
+	}}
+	tests := []struct {
+		name       string
+		expression string
+		errMsg     string
+	}{
+		{
+			name:       "resource key",
+			expression: `resource(pipelineRun.metadata.annotations["key"], 5)`,
+			errMsg:     "resource key validation failed",
+		},
+		{
+			name:       "resource quantity",
+			expression: `resource("x", -size(pipelineRun.metadata.name))`,
+			errMsg:     "resource value must be positive (>= 0), got -4",
+		},
+		{
+			name:       "label value",
+			expression: `label("team", pipelineRun.metadata.annotations["key"])`,
+			errMsg:     "label value validation failed",
+		},
+		{
+			name:       "priority class",
+			expression: `priority(plrGenerateName)`,
+			errMsg:     "mutation value cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			// The dynamic arguments are only validated when the expression
+			// is evaluated
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			_, err = programs[0].Evaluate(pipelineRun)
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
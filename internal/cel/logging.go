@@ -0,0 +1,179 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultMaxLoggedValueLength is the length above which logged mutation
+	// values are truncated when no limit is configured.
+	DefaultMaxLoggedValueLength = 256
+
+	// RedactedValue replaces the values of redacted keys in the logs.
+	RedactedValue = "[redacted]"
+
+	// LogMessageApplied is the message of the records of PipelineRuns
+	// which were mutated.
+	LogMessageApplied = "Applied mutations"
+	// LogMessageFailed is the message of the records of PipelineRuns
+	// which couldn't be mutated.
+	LogMessageFailed = "Failed to apply mutations"
+)
+
+// MutationLogConfig configures the logging of applied mutations.
+type MutationLogConfig struct {
+	// MaxValueLength is the number of bytes above which values are
+	// truncated. DefaultMaxLoggedValueLength is used when not positive.
+	MaxValueLength int
+	// RedactKeys lists the keys whose values must not be logged. Each entry
+	// is a pattern as supported by path.Match, e.g. "example.com/*".
+	RedactKeys []string
+	// IncludeInputSnapshot adds the PipelineRun, as received by the
+	// mutator, to the logs so the admission can be replayed. The values of
+	// the redacted labels and annotations are redacted, the status and
+	// managed fields are omitted.
+	IncludeInputSnapshot bool
+}
+
+// Validate ensures the redaction patterns are well-formed
+func (c MutationLogConfig) Validate() error {
+	for _, pattern := range c.RedactKeys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// WithMutationLogging enables logging the mutations applied to each
+// PipelineRun at Info level.
+func WithMutationLogging(log logr.Logger, cfg MutationLogConfig) MutatorOption {
+	return func(m *CELMutator) {
+		m.mutationLogger = &mutationLogger{log: log, config: cfg}
+	}
+}
+
+// LoggedMutation is the representation of an applied mutation in the logs.
+type LoggedMutation struct {
+	Type  MutationType `json:"type"`
+	Key   string       `json:"key"`
+	Value string       `json:"value"`
+}
+
+type mutationLogger struct {
+	log    logr.Logger
+	config MutationLogConfig
+}
+
+// SanitizeMutations returns the representation of the mutations in the logs,
+// see Sanitize.
+func (c MutationLogConfig) SanitizeMutations(mutations []*MutationRequest) []LoggedMutation {
+	logged := make([]LoggedMutation, 0, len(mutations))
+	for _, mutation := range mutations {
+		logged = append(logged, LoggedMutation{
+			Type:  mutation.Type,
+			Key:   mutation.Key,
+			Value: c.Sanitize(mutation.Key, mutation.Value),
+		})
+	}
+	return logged
+}
+
+// logMutations logs the mutations applied to the PipelineRun, truncating
+// long values and redacting the values of sensitive keys. The UID of the
+// admission request is logged when ctx carries one.
+func (l *mutationLogger) logMutations(ctx context.Context, input, pipelineRun *tekv1.PipelineRun, mutations []*MutationRequest) {
+	keysAndValues := append(l.commonValues(ctx, input, pipelineRun),
+		"mutations", l.config.SanitizeMutations(mutations))
+	l.log.Info(LogMessageApplied, keysAndValues...)
+}
+
+// logFailure logs that the PipelineRun couldn't be mutated.
+func (l *mutationLogger) logFailure(ctx context.Context, input, pipelineRun *tekv1.PipelineRun, err error) {
+	keysAndValues := append(l.commonValues(ctx, input, pipelineRun), "error", err.Error())
+	l.log.Info(LogMessageFailed, keysAndValues...)
+}
+
+func (l *mutationLogger) commonValues(ctx context.Context, input, pipelineRun *tekv1.PipelineRun) []any {
+	keysAndValues := []any{
+		"namespace", pipelineRun.Namespace,
+		"generateName", pipelineRun.GenerateName,
+	}
+	if req, err := admission.RequestFromContext(ctx); err == nil {
+		keysAndValues = append(keysAndValues, "admissionUID", req.UID)
+	}
+	if l.config.IncludeInputSnapshot && input != nil {
+		keysAndValues = append(keysAndValues, "input", l.config.snapshot(input))
+	}
+	return keysAndValues
+}
+
+// InputSnapshot is the representation of the PipelineRun received by the
+// mutator in the logs. It isn't a runtime.Object, so the logger doesn't
+// reduce it to its namespace and name.
+type InputSnapshot struct {
+	ObjectMeta metav1.ObjectMeta     `json:"metadata"`
+	Spec       tekv1.PipelineRunSpec `json:"spec"`
+}
+
+// PipelineRun returns the PipelineRun the snapshot was taken from, without
+// its status.
+func (s *InputSnapshot) PipelineRun() *tekv1.PipelineRun {
+	return &tekv1.PipelineRun{
+		ObjectMeta: *s.ObjectMeta.DeepCopy(),
+		Spec:       *s.Spec.DeepCopy(),
+	}
+}
+
+// snapshot returns the snapshot of the PipelineRun without its managed
+// fields, and with the values of the redacted labels and annotations
+// replaced.
+func (c MutationLogConfig) snapshot(pipelineRun *tekv1.PipelineRun) *InputSnapshot {
+	snapshot := &InputSnapshot{
+		ObjectMeta: *pipelineRun.ObjectMeta.DeepCopy(),
+		Spec:       *pipelineRun.Spec.DeepCopy(),
+	}
+	snapshot.ObjectMeta.ManagedFields = nil
+	for _, values := range []map[string]string{snapshot.ObjectMeta.Labels, snapshot.ObjectMeta.Annotations} {
+		for key := range values {
+			if c.redacted(key) {
+				values[key] = RedactedValue
+			}
+		}
+	}
+	return snapshot
+}
+
+// Sanitize returns the value logged for the key: RedactedValue for the
+// redacted keys, and the value truncated to MaxValueLength otherwise.
+func (c MutationLogConfig) Sanitize(key, value string) string {
+	if c.redacted(key) {
+		return RedactedValue
+	}
+	maxLength := c.MaxValueLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLoggedValueLength
+	}
+	if len(value) <= maxLength {
+		return value
+	}
+	return fmt.Sprintf("%s...(truncated, %d bytes)", strings.ToValidUTF8(value[:maxLength], ""), len(value))
+}
+
+func (c MutationLogConfig) redacted(key string) bool {
+	for _, pattern := range c.RedactKeys {
+		// Invalid patterns are rejected by Validate
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package cel
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// newCapturingLogger returns a JSON logger which appends every log line to lines.
+func newCapturingLogger(lines *[]string) logr.Logger {
+	return funcr.NewJSON(func(obj string) {
+		*lines = append(*lines, obj)
+	}, funcr.Options{})
+}
+
+func TestMutationLogConfig_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(MutationLogConfig{RedactKeys: []string{"example.com/*", "secret"}}.Validate()).To(Succeed())
+	g.Expect(MutationLogConfig{RedactKeys: []string{"example.com/["}}.Validate()).
+		To(MatchError(ContainSubstring(`invalid redaction pattern "example.com/["`)))
+}
+
+func TestCELMutator_Mutate_MutationLogging(t *testing.T) {
+	longValue := strings.Repeat("x", 20)
+
+	tests := []struct {
+		name        string
+		expressions []string
+		config      MutationLogConfig
+		expected    []string
+		unexpected  []string
+	}{
+		{
+			name: "logs every applied mutation",
+			expressions: []string{
+				`[annotation("example.com/owner", "team-a"), label("env", "prod")]`,
+				`resource("linux-amd64", 2)`,
+			},
+			expected: []string{
+				`"msg":"Applied mutations"`,
+				`"namespace":"test-namespace"`,
+				`"generateName":"test-pipeline-"`,
+				`{"type":"annotation","key":"example.com/owner","value":"team-a"}`,
+				`{"type":"label","key":"env","value":"prod"}`,
+				`{"type":"resource","key":"kueue.konflux-ci.dev/requests-linux-amd64","value":"2"}`,
+			},
+		},
+		{
+			name: "truncates long values",
+			expressions: []string{
+				`annotation("example.com/long", "` + longValue + `")`,
+				`annotation("example.com/short", "short")`,
+			},
+			config: MutationLogConfig{MaxValueLength: 10},
+			expected: []string{
+				`{"type":"annotation","key":"example.com/long","value":"xxxxxxxxxx...(truncated, 20 bytes)"}`,
+				`{"type":"annotation","key":"example.com/short","value":"short"}`,
+			},
+			unexpected: []string{longValue},
+		},
+		{
+			name: "redacts matching keys",
+			expressions: []string{
+				`annotation("secrets.example.com/token", "s3cr3t")`,
+				`annotation("example.com/password", "hunter2")`,
+				`annotation("example.com/owner", "team-a")`,
+			},
+			config: MutationLogConfig{RedactKeys: []string{"secrets.example.com/*", "example.com/password"}},
+			expected: []string{
+				`{"type":"annotation","key":"secrets.example.com/token","value":"[redacted]"}`,
+				`{"type":"annotation","key":"example.com/password","value":"[redacted]"}`,
+				`{"type":"annotation","key":"example.com/owner","value":"team-a"}`,
+			},
+			unexpected: []string{"s3cr3t", "hunter2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			var lines []string
+			mutator := NewCELMutator(programs, WithMutationLogging(newCapturingLogger(&lines), tt.config))
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: "test-pipeline-",
+					Namespace:    "test-namespace",
+				},
+			}
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+			g.Expect(lines).To(HaveLen(1))
+			for _, expected := range tt.expected {
+				g.Expect(lines[0]).To(ContainSubstring(expected))
+			}
+			for _, unexpected := range tt.unexpected {
+				g.Expect(lines[0]).NotTo(ContainSubstring(unexpected))
+			}
+		})
+	}
+}
+
+func TestCELMutator_Mutate_MutationLoggingDisabled(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`annotation("example.com/owner", "team-a")`})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mutator := NewCELMutator(programs)
+	g.Expect(mutator.Mutate(context.Background(), &tekv1.PipelineRun{})).To(Succeed())
+	g.Expect(mutator.mutationLogger).To(BeNil())
+}
+
+func TestCELMutator_Mutate_MutationLoggingAdmissionUID(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`annotation("example.com/owner", "team-a")`})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var lines []string
+	mutator := NewCELMutator(programs, WithMutationLogging(newCapturingLogger(&lines), MutationLogConfig{}))
+
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{UID: "705ab4f5-6393-11e8-b7cc-42010a800002"},
+	})
+	g.Expect(mutator.Mutate(ctx, &tekv1.PipelineRun{})).To(Succeed())
+	g.Expect(mutator.Mutate(context.Background(), &tekv1.PipelineRun{})).To(Succeed())
+
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(lines[0]).To(ContainSubstring(`"admissionUID":"705ab4f5-6393-11e8-b7cc-42010a800002"`))
+	g.Expect(lines[1]).NotTo(ContainSubstring("admissionUID"))
+}
+
+func TestCELMutator_Mutate_MutationLoggingInputSnapshot(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`annotation("example.com/owner", pipelineRun.metadata.labels["team"])`})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var lines []string
+	mutator := NewCELMutator(programs, WithMutationLogging(newCapturingLogger(&lines), MutationLogConfig{
+		RedactKeys:           []string{"secrets.example.com/*"},
+		IncludeInputSnapshot: true,
+	}))
+
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "test-pipeline-",
+			Namespace:    "test-namespace",
+			Labels:       map[string]string{"team": "a"},
+			Annotations:  map[string]string{"secrets.example.com/token": "s3cr3t"},
+		},
+	}
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+	// The snapshot is taken before the mutation
+	g.Expect(lines).To(HaveLen(1))
+	g.Expect(lines[0]).To(ContainSubstring(`"input":{"metadata":{"generateName":"test-pipeline-","namespace":"test-namespace"`))
+	g.Expect(lines[0]).To(ContainSubstring(`"annotations":{"secrets.example.com/token":"[redacted]"}`))
+	g.Expect(lines[0]).NotTo(ContainSubstring("s3cr3t"))
+	g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue("secrets.example.com/token", "s3cr3t"))
+
+	// Failures are logged with the error
+	g.Expect(mutator.Mutate(context.Background(), &tekv1.PipelineRun{})).NotTo(Succeed())
+	g.Expect(lines).To(HaveLen(2))
+	g.Expect(lines[1]).To(ContainSubstring(`"msg":"Failed to apply mutations"`))
+	g.Expect(lines[1]).To(ContainSubstring(`"error":"failed to evaluate CEL expression`))
+	g.Expect(lines[1]).To(ContainSubstring(`"input":{"metadata":{`))
+}
+
+func TestCELMutator_DryRun(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`[annotation("owner", "team-a"), resource("linux-amd64", 2)]`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace"}}
+	mutations, err := mutator.DryRun(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(Equal([]*MutationRequest{
+		{Type: MutationTypeAnnotation, Key: "owner", Value: "team-a"},
+		{Type: MutationTypeResource, Key: "kueue.konflux-ci.dev/requests-linux-amd64", Value: "2"},
+	}))
+	g.Expect(pipelineRun.Annotations).To(BeNil())
+}
@@ -1,6 +1,8 @@
 package cel
 
 import (
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -12,7 +14,9 @@ var (
 			Name: "tekton_kueue_cel_evaluations_total",
 			Help: "Total number of CEL evaluations",
 		},
-		[]string{"result"}, // result can be "success" or "failure"
+		// result can be "success", "failure" or "skipped_too_large", group is
+		// the name of the expression group
+		[]string{"result", "group"},
 	)
 
 	// celMutationsTotal tracks the total number of CEL mutation operations
@@ -23,22 +27,95 @@ var (
 		},
 		[]string{"result"}, // result: "success" or "failure"
 	)
+
+	// celExpressionResultStableFor tracks the number of consecutive
+	// admissions for which each expression returned the same result
+	celExpressionResultStableFor = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tekton_kueue_cel_expression_result_stable_for",
+			Help: "Number of consecutive admissions for which the CEL expression returned the same mutations",
+		},
+		// index is the index of the expression in its group, or its name
+		[]string{"group", "index"},
+	)
+
+	// celExpressionSkippedTotal tracks the evaluations skipped because the
+	// circuit breaker of the expression is tripped
+	celExpressionSkippedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_cel_expression_skipped_total",
+			Help: "Total number of CEL evaluations skipped because the circuit breaker of the expression is tripped",
+		},
+		// index is the index of the expression in its group, or its name
+		[]string{"group", "index"},
+	)
+
+	// celCacheLookupsTotal tracks the lookups of the evaluation cache
+	celCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_cel_cache_lookups_total",
+			Help: "Total number of lookups of the CEL evaluation cache",
+		},
+		[]string{"result"}, // result: "hit" or "miss"
+	)
+
+	// celMutationLimitExceededTotal tracks the mutations failed because
+	// they exceed a limit
+	celMutationLimitExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_cel_mutation_limit_exceeded_total",
+			Help: "Total number of CEL mutations failed because the mutations of the PipelineRun exceed a limit",
+		},
+		[]string{"limit"}, // limit: "mutations" or "annotation_bytes"
+	)
+
+	// celMutationsGeneratedTotal tracks the mutations applied to the
+	// PipelineRuns, by type
+	celMutationsGeneratedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_cel_mutations_generated_total",
+			Help: "Total number of mutations generated by the CEL expressions and applied to PipelineRuns",
+		},
+		[]string{"type"}, // type: the mutation type, e.g. "annotation", "label" or "resource"
+	)
+
+	// celMutationsPerObject tracks the number of mutations applied to each
+	// PipelineRun
+	celMutationsPerObject = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "tekton_kueue_cel_mutations_per_object",
+			Help:    "Number of mutations generated by the CEL expressions and applied to each PipelineRun",
+			Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+		},
+	)
 )
 
 func init() {
 	// Register the metrics with controller-runtime's global registry
 	metrics.Registry.MustRegister(celEvaluationsTotal)
 	metrics.Registry.MustRegister(celMutationsTotal)
+	metrics.Registry.MustRegister(celExpressionResultStableFor)
+	metrics.Registry.MustRegister(celExpressionSkippedTotal)
+	metrics.Registry.MustRegister(celCacheLookupsTotal)
+	metrics.Registry.MustRegister(celMutationLimitExceededTotal)
+	metrics.Registry.MustRegister(celMutationsGeneratedTotal)
+	metrics.Registry.MustRegister(celMutationsPerObject)
 }
 
-// RecordEvaluationFailure increments the counter for CEL evaluation failures
-func RecordEvaluationFailure() {
-	celEvaluationsTotal.WithLabelValues("failure").Inc()
+// RecordEvaluationFailure increments the counter for CEL evaluation failures of the group
+func RecordEvaluationFailure(group string) {
+	celEvaluationsTotal.WithLabelValues("failure", group).Inc()
 }
 
-// RecordEvaluationSuccess increments the counter for successful CEL evaluations
-func RecordEvaluationSuccess() {
-	celEvaluationsTotal.WithLabelValues("success").Inc()
+// RecordEvaluationSuccess increments the counter for successful CEL evaluations of the group
+func RecordEvaluationSuccess(group string) {
+	celEvaluationsTotal.WithLabelValues("success", group).Inc()
+}
+
+// RecordEvaluationSkippedTooLarge increments the counter for the CEL
+// evaluations of the group skipped because the PipelineRun is too large
+func RecordEvaluationSkippedTooLarge(group string) {
+	celEvaluationsTotal.WithLabelValues("skipped_too_large", group).Inc()
 }
 
 // RecordMutationFailure increments the counter for CEL mutation failures
@@ -50,3 +127,43 @@ func RecordMutationFailure() {
 func RecordMutationSuccess() {
 	celMutationsTotal.WithLabelValues("success").Inc()
 }
+
+// RecordMutationsGenerated increments the counters of the types of the
+// mutations applied to a PipelineRun, and observes their number
+func RecordMutationsGenerated(mutations []*MutationRequest) {
+	for _, mutation := range mutations {
+		celMutationsGeneratedTotal.WithLabelValues(mutation.Type.String()).Inc()
+	}
+	celMutationsPerObject.Observe(float64(len(mutations)))
+}
+
+// RecordExpressionSkipped increments the counter for the evaluations of the
+// expression skipped by its circuit breaker
+func RecordExpressionSkipped(group, index string) {
+	celExpressionSkippedTotal.WithLabelValues(group, index).Inc()
+}
+
+// indexLabel returns the value of the index label of the metrics of an
+// expression: its name, or its index in its group when it has no name.
+func indexLabel(index int, name string) string {
+	if name != "" {
+		return name
+	}
+	return strconv.Itoa(index)
+}
+
+// RecordCacheHit increments the counter for the evaluation cache hits
+func RecordCacheHit() {
+	celCacheLookupsTotal.WithLabelValues("hit").Inc()
+}
+
+// RecordCacheMiss increments the counter for the evaluation cache misses
+func RecordCacheMiss() {
+	celCacheLookupsTotal.WithLabelValues("miss").Inc()
+}
+
+// RecordMutationLimitExceeded increments the counter for the mutations
+// failed because they exceed the limit
+func RecordMutationLimitExceeded(limit string) {
+	celMutationLimitExceededTotal.WithLabelValues(limit).Inc()
+}
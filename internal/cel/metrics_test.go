@@ -0,0 +1,91 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluationMetrics_GroupLabel(t *testing.T) {
+	g := NewWithT(t)
+
+	defaultPrograms, err := CompileCELPrograms([]string{`priority("high")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	groupPrograms, err := CompileCELPrograms([]string{
+		`annotation("owner", "team-a")`,
+		`label("env", "prod")`,
+	}, WithGroup("metrics-test"))
+	g.Expect(err).NotTo(HaveOccurred())
+	failingPrograms, err := CompileCELPrograms([]string{
+		`annotation("owner", pipelineRun.metadata.annotations["missing"])`,
+	}, WithGroup("metrics-test-failing"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	counter := func(result, group string) float64 {
+		return testutil.ToFloat64(celEvaluationsTotal.WithLabelValues(result, group))
+	}
+	defaultSuccess := counter("success", DefaultGroup)
+	groupSuccess := counter("success", "metrics-test")
+	failures := counter("failure", "metrics-test-failing")
+
+	newPipelineRun := func() *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	}
+
+	mutator := NewCELMutator(append(defaultPrograms, groupPrograms...))
+	g.Expect(mutator.Mutate(context.Background(), newPipelineRun())).To(Succeed())
+	// Each group is counted once per evaluation
+	g.Expect(counter("success", DefaultGroup)).To(Equal(defaultSuccess + 1))
+	g.Expect(counter("success", "metrics-test")).To(Equal(groupSuccess + 1))
+
+	mutator = NewCELMutator(failingPrograms)
+	g.Expect(mutator.Mutate(context.Background(), newPipelineRun())).NotTo(Succeed())
+	g.Expect(counter("failure", "metrics-test-failing")).To(Equal(failures + 1))
+}
+
+func TestMutationMetrics_Generated(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`[annotation("owner", "team-a"), annotation("cost-center", "42")]`,
+		`label("env", "prod")`,
+		`[resource("cpu", 2), resource("memory", 1024)]`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	generated := func(mutationType MutationType) float64 {
+		return testutil.ToFloat64(celMutationsGeneratedTotal.WithLabelValues(mutationType.String()))
+	}
+	perObject := func() *dto.Histogram {
+		metric := &dto.Metric{}
+		g.Expect(celMutationsPerObject.Write(metric)).To(Succeed())
+		return metric.GetHistogram()
+	}
+	annotations := generated(MutationTypeAnnotation)
+	labels := generated(MutationTypeLabel)
+	resources := generated(MutationTypeResource)
+	histogram := perObject()
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	g.Expect(NewCELMutator(programs).Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+	// Each mutation is counted, not each call of Mutate
+	g.Expect(generated(MutationTypeAnnotation)).To(Equal(annotations + 2))
+	g.Expect(generated(MutationTypeLabel)).To(Equal(labels + 1))
+	g.Expect(generated(MutationTypeResource)).To(Equal(resources + 2))
+	g.Expect(perObject().GetSampleCount()).To(Equal(histogram.GetSampleCount() + 1))
+	g.Expect(perObject().GetSampleSum()).To(Equal(histogram.GetSampleSum() + 5))
+
+	// The failed mutations aren't counted
+	failing, err := CompileCELPrograms([]string{`annotation("owner", pipelineRun.metadata.annotations["missing"])`})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun = &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	g.Expect(NewCELMutator(failing).Mutate(context.Background(), pipelineRun)).NotTo(Succeed())
+	g.Expect(generated(MutationTypeAnnotation)).To(Equal(annotations + 2))
+	g.Expect(perObject().GetSampleCount()).To(Equal(histogram.GetSampleCount() + 1))
+}
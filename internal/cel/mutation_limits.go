@@ -0,0 +1,87 @@
+package cel
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// WithMutationLimits fails the mutation of the PipelineRuns for which the
+// programs return more than maxMutations mutations, or annotation values
+// adding up to more than maxAnnotationBytes bytes, e.g. because of an
+// expression mapping over a large list, instead of bloating the object
+// past the limits of etcd. The error names the expression contributing the
+// most, and the failures are counted in
+// tekton_kueue_cel_mutation_limit_exceeded_total. A limit of zero disables
+// it.
+func WithMutationLimits(maxMutations, maxAnnotationBytes int) MutatorOption {
+	return func(m *CELMutator) {
+		m.maxMutations = maxMutations
+		m.maxAnnotationBytes = maxAnnotationBytes
+	}
+}
+
+// checkLimits returns an error when the mutations returned by the programs,
+// by index of the program, exceed the limits set with WithMutationLimits.
+func (m *CELMutator) checkLimits(programResults map[int][]*MutationRequest) error {
+	if m.maxMutations > 0 {
+		if err := m.checkLimit(programResults, "mutations", m.maxMutations, countMutations); err != nil {
+			return err
+		}
+	}
+	if m.maxAnnotationBytes > 0 {
+		if err := m.checkLimit(programResults, "annotation_bytes", m.maxAnnotationBytes, annotationBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLimit returns an error, recording the failure under limit, when the
+// sum of the measure of the results of the programs exceeds maximum.
+func (m *CELMutator) checkLimit(
+	programResults map[int][]*MutationRequest,
+	limit string,
+	maximum int,
+	measure func([]*MutationRequest) int,
+) error {
+	total, top, topValue := 0, -1, 0
+	// The programs are visited in order, so the first of the top
+	// contributors is reported
+	for _, i := range slices.Sorted(maps.Keys(programResults)) {
+		value := measure(programResults[i])
+		total += value
+		if value > topValue {
+			top, topValue = i, value
+		}
+	}
+	if total <= maximum {
+		return nil
+	}
+	RecordMutationLimitExceeded(limit)
+	program := m.programs[top]
+	if limit == "mutations" {
+		return fmt.Errorf("the expressions return %d mutations, more than the limit of %d: "+
+			"the expression %q of the group %q returns %d of them",
+			total, maximum, program.expression, program.group, topValue)
+	}
+	return fmt.Errorf("the expressions return annotation values of %d bytes, more than the limit of %d: "+
+		"the expression %q of the group %q returns %d bytes of them",
+		total, maximum, program.expression, program.group, topValue)
+}
+
+func countMutations(mutations []*MutationRequest) int {
+	return len(mutations)
+}
+
+// annotationBytes returns the size of the values of the annotation
+// mutations.
+func annotationBytes(mutations []*MutationRequest) int {
+	size := 0
+	for _, mutation := range mutations {
+		if mutation.Type == MutationTypeAnnotation || mutation.Type == MutationTypeAppendAnnotation {
+			size += len(mutation.Value)
+		}
+	}
+	return size
+}
@@ -0,0 +1,93 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_MutationLimits(t *testing.T) {
+	const (
+		fewLabels    = `[label("team", "a"), label("app", "web")]`
+		manyLabels   = `pipelineRun.spec.params.map(p, label("param-" + p.name, "true"))`
+		largeValues  = `pipelineRun.spec.params.map(p, annotation("param-" + p.name, string(p.value)))`
+		smallComment = `annotation("comment", "small")`
+	)
+	pipelineRun := func(params int) *tekv1.PipelineRun {
+		plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+		for i := range params {
+			plr.Spec.Params = append(plr.Spec.Params, tekv1.Param{
+				Name:  string(rune('a' + i)),
+				Value: *tekv1.NewStructuredValues("0123456789"),
+			})
+		}
+		return plr
+	}
+	counter := func(limit string) float64 {
+		return testutil.ToFloat64(celMutationLimitExceededTotal.WithLabelValues(limit))
+	}
+
+	tests := []struct {
+		name        string
+		expressions []string
+		params      int
+		limit       string
+		errMsg      string
+	}{
+		{
+			name:        "within the limits",
+			expressions: []string{fewLabels, manyLabels, largeValues},
+			params:      1,
+		},
+		{
+			name:        "too many mutations",
+			expressions: []string{fewLabels, manyLabels},
+			params:      4,
+			limit:       "mutations",
+			errMsg: fmt.Sprintf(`the expressions return 6 mutations, more than the limit of 5: `+
+				`the expression %q of the group "default" returns 4 of them`, manyLabels),
+		},
+		{
+			name:        "annotation values too large",
+			expressions: []string{smallComment, largeValues},
+			params:      3,
+			limit:       "annotation_bytes",
+			errMsg: fmt.Sprintf(`the expressions return annotation values of 35 bytes, more than the limit of 32: `+
+				`the expression %q of the group "default" returns 30 bytes of them`, largeValues),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs, WithMutationLimits(5, 32))
+
+			before := counter(tt.limit)
+			err = mutator.Mutate(context.Background(), pipelineRun(tt.params))
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(tt.errMsg))
+			g.Expect(counter(tt.limit) - before).To(Equal(1.0))
+		})
+	}
+}
+
+func TestCELMutator_MutationLimits_Disabled(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`[label("a", "1"), label("b", "2"), annotation("c", "value")]`})
+	g.Expect(err).NotTo(HaveOccurred())
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+	g.Expect(NewCELMutator(programs, WithMutationLimits(0, 0)).Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(NewCELMutator(programs, WithMutationLimits(2, 0)).Mutate(context.Background(), plr)).NotTo(Succeed())
+	g.Expect(NewCELMutator(programs, WithMutationLimits(0, 4)).Mutate(context.Background(), plr)).NotTo(Succeed())
+}
@@ -0,0 +1,84 @@
+package cel
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// overwritingTypes are the mutation types whose mutations of the same key
+// overwrite each other when they're applied, the last one winning. Resource
+// mutations are summed, and the other types don't simply overwrite: the
+// timeouts are only lengthened, the service account is only set once and
+// the entries of append annotation mutations accumulate.
+var overwritingTypes = []MutationType{
+	MutationTypeAnnotation, MutationTypeLabel, MutationTypePodSet, MutationTypeNodeSelector,
+}
+
+// mutationCollision reports the mutations of the same type and key setting
+// different values, only the last one being applied.
+type mutationCollision struct {
+	mutationType MutationType
+	key          string
+	// overwritten is the number of mutations dropped in favor of the last
+	// one.
+	overwritten int
+}
+
+// orderMutations returns the mutations in a deterministic order: sorted by
+// type, in the order of ValidTypes, then by key, the mutations of the same
+// type and key keeping their evaluation order, except for the resource
+// mutations, sorted by value. The mutations overwritten by a later mutation
+// of the same type and key are dropped, so are the exact duplicates, except
+// for resource mutations, which are summed. The result of applying the
+// mutations is unchanged as long as mutations of different types don't
+// change the same annotation.
+//
+// It also returns the keys set to different values by several mutations.
+func orderMutations(mutations []*MutationRequest) ([]*MutationRequest, []mutationCollision) {
+	last := make(map[mutationKey]int)
+	for i, mutation := range mutations {
+		if slices.Contains(overwritingTypes, mutation.Type) {
+			last[mutationKey{mutationType: mutation.Type, key: mutation.Key}] = i
+		}
+	}
+
+	var collisions []mutationCollision
+	collisionIndexes := make(map[mutationKey]int)
+	ordered := make([]*MutationRequest, 0, len(mutations))
+	for i, mutation := range mutations {
+		key := mutationKey{mutationType: mutation.Type, key: mutation.Key}
+		if j, ok := last[key]; ok && j != i {
+			if mutations[j].Value == mutation.Value {
+				continue
+			}
+			index, seen := collisionIndexes[key]
+			if !seen {
+				index = len(collisions)
+				collisionIndexes[key] = index
+				collisions = append(collisions, mutationCollision{mutationType: mutation.Type, key: mutation.Key})
+			}
+			collisions[index].overwritten++
+			continue
+		}
+		if mutation.Type != MutationTypeResource &&
+			slices.ContainsFunc(ordered, func(m *MutationRequest) bool { return *m == *mutation }) {
+			continue
+		}
+		ordered = append(ordered, mutation)
+	}
+
+	types := ValidTypes()
+	slices.SortStableFunc(ordered, func(a, b *MutationRequest) int {
+		if order := cmp.Or(
+			cmp.Compare(slices.Index(types, a.Type), slices.Index(types, b.Type)),
+			strings.Compare(a.Key, b.Key),
+		); order != 0 || a.Type != MutationTypeResource {
+			return order
+		}
+		// The values of the resources are summed, so their order doesn't
+		// matter
+		return strings.Compare(a.Value, b.Value)
+	})
+	return ordered, collisions
+}
@@ -0,0 +1,105 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/tekton-queue/pkg/celtest"
+)
+
+func TestOrderMutations(t *testing.T) {
+	g := NewWithT(t)
+
+	mutations, collisions := orderMutations([]*MutationRequest{
+		{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "cpu", Value: "1"},
+		{Type: MutationTypeLabel, Key: "team", Value: "a"},
+		{Type: MutationTypeAnnotation, Key: "owner", Value: "a"},
+		{Type: MutationTypeAppendAnnotation, Key: "platforms", Value: "linux-arm64", Separator: ","},
+		{Type: MutationTypeLabel, Key: "env", Value: "prod"},
+		{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "cpu", Value: "1"},
+		{Type: MutationTypeLabel, Key: "team", Value: "b"},
+		{Type: MutationTypeAppendAnnotation, Key: "platforms", Value: "linux-amd64", Separator: ","},
+		{Type: MutationTypeAnnotation, Key: "owner", Value: "a"},
+		{Type: MutationTypeAppendAnnotation, Key: "platforms", Value: "linux-arm64", Separator: ","},
+		{Type: MutationTypeLabel, Key: "team", Value: "c"},
+	})
+	g.Expect(mutations).To(Equal([]*MutationRequest{
+		{Type: MutationTypeAnnotation, Key: "owner", Value: "a"},
+		{Type: MutationTypeLabel, Key: "env", Value: "prod"},
+		{Type: MutationTypeLabel, Key: "team", Value: "c"},
+		// Resource mutations are summed, so they're all kept
+		{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "cpu", Value: "1"},
+		{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "cpu", Value: "1"},
+		// The entries keep their order
+		{Type: MutationTypeAppendAnnotation, Key: "platforms", Value: "linux-arm64", Separator: ","},
+		{Type: MutationTypeAppendAnnotation, Key: "platforms", Value: "linux-amd64", Separator: ","},
+	}))
+	// The duplicates of the same value aren't collisions
+	g.Expect(collisions).To(Equal([]mutationCollision{
+		{mutationType: MutationTypeLabel, key: "team", overwritten: 2},
+	}))
+}
+
+func TestCELMutator_Mutate_PermutedExpressions(t *testing.T) {
+	expressions := []string{
+		`[label("team", "a"), resource("cpu", 1)]`,
+		`[annotation("owner", "team-a"), priority("high"), timeout("pipeline", "2h")]`,
+		`[resource("cpu", 2), label("env", "prod"), nodeSelector("kubernetes.io/arch", "arm64")]`,
+		`annotation("owner", "team-a")`,
+	}
+	permutations := [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}, {1, 3, 0, 2}}
+
+	var expectedMutations []*MutationRequest
+	var expected *tekv1.PipelineRun
+	for _, permutation := range permutations {
+		g := NewWithT(t)
+		var permuted []string
+		for _, i := range permutation {
+			permuted = append(permuted, expressions[i])
+		}
+		programs, err := CompileCELPrograms(permuted)
+		g.Expect(err).NotTo(HaveOccurred())
+		mutator := NewCELMutator(programs)
+
+		pipelineRun := celtest.NewPipelineRun()
+		mutations, err := mutator.DryRun(pipelineRun)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+		if expected == nil {
+			expectedMutations, expected = mutations, pipelineRun
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(ResourceAnnotationPrefix+"cpu", "3"))
+			continue
+		}
+		g.Expect(mutations).To(Equal(expectedMutations), "permutation %v", permutation)
+		g.Expect(pipelineRun).To(Equal(expected), "permutation %v", permutation)
+	}
+}
+
+func TestCELMutator_Mutate_LogsCollisions(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`label("team", "a")`,
+		`[label("team", "b"), label("env", "prod")]`,
+		`label("env", "prod")`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	var logs []string
+	logger := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{Verbosity: 1})
+	pipelineRun := celtest.NewPipelineRun()
+	g.Expect(mutator.Mutate(logr.NewContext(context.Background(), logger), pipelineRun)).To(Succeed())
+
+	g.Expect(pipelineRun.Labels).To(HaveKeyWithValue("team", "b"))
+	g.Expect(logs).To(ConsistOf(And(
+		ContainSubstring(`"msg"="Several mutations set the same key, the last one wins"`),
+		ContainSubstring(`"type"="label" "key"="team" "overwritten"=1`),
+	)))
+}
@@ -0,0 +1,47 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MutationPolicy restricts the mutations the programs may return, e.g. the
+// programs of the tenants, which may only add their own annotations.
+type MutationPolicy struct {
+	// Types are the allowed mutation types, all of them when empty.
+	Types []MutationType
+	// KeyPrefixes are the prefixes of the allowed label and annotation
+	// keys, all of them when empty.
+	KeyPrefixes []string
+}
+
+// WithMutationPolicy fails the evaluations of the programs returning a
+// mutation the policy doesn't allow.
+func WithMutationPolicy(policy MutationPolicy) CompileOption {
+	return func(o *compileOptions) {
+		o.policy = &policy
+	}
+}
+
+// check returns an error when the policy doesn't allow the mutation. Nil
+// policies allow all the mutations.
+func (p *MutationPolicy) check(mutation *MutationRequest) error {
+	if p == nil {
+		return nil
+	}
+	if len(p.Types) > 0 && !slices.Contains(p.Types, mutation.Type) {
+		return fmt.Errorf("%s mutations aren't allowed, only %v", mutation.Type, p.Types)
+	}
+	switch mutation.Type {
+	case MutationTypeLabel, MutationTypeAnnotation, MutationTypeAppendAnnotation:
+	default:
+		return nil
+	}
+	if len(p.KeyPrefixes) > 0 &&
+		!slices.ContainsFunc(p.KeyPrefixes, func(prefix string) bool { return strings.HasPrefix(mutation.Key, prefix) }) {
+		return fmt.Errorf("the %s %q isn't allowed, the keys must start with one of %v",
+			mutation.Type, mutation.Key, p.KeyPrefixes)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithMutationPolicy(t *testing.T) {
+	policy := MutationPolicy{
+		Types:       []MutationType{MutationTypeAnnotation, MutationTypeAppendAnnotation},
+		KeyPrefixes: []string{"tenant-a.tenants.acme.com/"},
+	}
+	tests := []struct {
+		name       string
+		expression string
+		errorMsg   string
+	}{
+		{
+			name:       "annotation under the prefix",
+			expression: `annotation("tenant-a.tenants.acme.com/team", "build")`,
+		},
+		{
+			name:       "appended annotation under the prefix",
+			expression: `appendAnnotation("tenant-a.tenants.acme.com/checks", "sast", ",")`,
+		},
+		{
+			name:       "annotation outside of the prefix",
+			expression: `annotation("tenant-b.tenants.acme.com/team", "build")`,
+			errorMsg:   `the annotation "tenant-b.tenants.acme.com/team" isn't allowed`,
+		},
+		{
+			name:       "priority",
+			expression: `priority("high")`,
+			errorMsg:   "label mutations aren't allowed",
+		},
+		{
+			name:       "resource",
+			expression: `[annotation("tenant-a.tenants.acme.com/team", "build"), resource("cpu", 2)]`,
+			errorMsg:   "resource mutations aren't allowed",
+		},
+	}
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant-a"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression}, WithMutationPolicy(policy))
+			g.Expect(err).NotTo(HaveOccurred())
+			_, err = programs[0].Evaluate(pipelineRun)
+			if tt.errorMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestMutationPolicy_AllowsAllWhenEmpty(t *testing.T) {
+	g := NewWithT(t)
+
+	var none *MutationPolicy
+	mutation := &MutationRequest{Type: MutationTypeResource, Key: "cpu", Value: "2"}
+	g.Expect(none.check(mutation)).To(Succeed())
+	g.Expect((&MutationPolicy{}).check(mutation)).To(Succeed())
+	g.Expect((&MutationPolicy{KeyPrefixes: []string{"acme.com/"}}).check(mutation)).To(Succeed())
+}
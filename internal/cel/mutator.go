@@ -1,10 +1,21 @@
 package cel
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
 	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
 )
 
 // CELMutator applies mutations to PipelineRun objects based on compiled CEL programs.
@@ -22,15 +33,135 @@ import (
 //	}
 //
 //	mutator := &CELMutator{programs: programs}
-//	err = mutator.Mutate(pipelineRun)
+//	err = mutator.Mutate(ctx, pipelineRun)
 type CELMutator struct {
 	programs []*CompiledProgram
+
+	// keyNormalizationRules, when not empty, are used to canonicalize the
+	// keys of resource mutations and existing resource annotations.
+	keyNormalizationRules []KeyNormalizationRule
+
+	// mutationLogger, when set, logs the applied mutations.
+	mutationLogger *mutationLogger
+
+	// results tracks how long the results of the programs haven't changed.
+	results *resultTracker
+
+	// timeoutOverride allows timeout mutations to shorten the timeouts
+	// already set on the PipelineRun.
+	timeoutOverride bool
+
+	// serviceAccountOverride allows service account mutations to replace
+	// the service account already set on the PipelineRun.
+	serviceAccountOverride bool
+
+	// overwritePolicy defines whether the label and annotation mutations
+	// replace the values already set on the PipelineRun.
+	overwritePolicy OverwritePolicy
+
+	// resourceWritePrefixes and resourceReadPrefixes, when set, replace
+	// ResourceAnnotationPrefix during the migration of the resource
+	// annotations to a new prefix.
+	resourceWritePrefixes []string
+	resourceReadPrefixes  []string
+
+	// breaker, when set, skips the programs which fail too often. It's
+	// created from breakerConfig, taking over the state of
+	// previousBreaker.
+	breaker         *circuitBreaker
+	breakerConfig   *CircuitBreakerConfig
+	breakerLog      logr.Logger
+	previousBreaker *circuitBreaker
+
+	// ownership, when set, detects the PipelineRuns mutated concurrently.
+	ownership *ownershipGuard
+
+	// maxObjectBytes, when positive, is the size of the PipelineRuns above
+	// which the programs aren't evaluated.
+	maxObjectBytes int
+	sizeGuardLog   logr.Logger
+
+	// namespaces, when set, reads the labels of the namespaces of the
+	// PipelineRuns, see WithNamespaceLabels.
+	namespaces *namespaceLister
+
+	// cache, when set, caches the results of the evaluations. It's created
+	// with cacheSize entries, see WithEvaluationCache.
+	cache     *evaluationCache
+	cacheSize int
+	cacheLog  logr.Logger
+
+	// maxMutations and maxAnnotationBytes, when positive, limit the
+	// mutations returned by the programs, see WithMutationLimits.
+	maxMutations       int
+	maxAnnotationBytes int
+
+	// pruneResourceRequests removes the resource annotations whose
+	// resource isn't produced by the programs nor in
+	// allowedResourceRequests, see WithResourceRequestPruning.
+	pruneResourceRequests   bool
+	allowedResourceRequests []string
+
+	// followUp keeps, and adds to, the resource amounts recorded by the
+	// mutator applied before, see WithFollowUp.
+	followUp bool
+}
+
+// MutatorOption configures optional behavior of a CELMutator.
+type MutatorOption func(*CELMutator)
+
+// WithResourceKeyNormalization enables the normalization of resource keys
+// using the provided rules. Before resource mutations are applied, their keys
+// and the keys of the existing resource annotations are rewritten to their
+// canonical form, and equivalent keys are folded by summing their values.
+func WithResourceKeyNormalization(rules []KeyNormalizationRule) MutatorOption {
+	return func(m *CELMutator) {
+		m.keyNormalizationRules = rules
+	}
+}
+
+// WithTimeoutOverride allows timeout mutations to shorten the timeouts
+// already set on PipelineRuns. By default, a timeout is only replaced by a
+// longer one.
+func WithTimeoutOverride() MutatorOption {
+	return func(m *CELMutator) {
+		m.timeoutOverride = true
+	}
+}
+
+// WithServiceAccountOverride allows service account mutations to replace
+// the service account already set on PipelineRuns. By default, it's kept.
+func WithServiceAccountOverride() MutatorOption {
+	return func(m *CELMutator) {
+		m.serviceAccountOverride = true
+	}
+}
+
+// WithResourceAnnotationPrefixes migrates the resource annotations to new
+// prefixes. Resource mutations are applied to the annotations of each write
+// prefix, which are first set to the value of the read prefixes, ordered by
+// decreasing precedence, so the annotations of the write prefixes stay
+// consistent.
+func WithResourceAnnotationPrefixes(write, read []string) MutatorOption {
+	return func(m *CELMutator) {
+		m.resourceWritePrefixes = write
+		m.resourceReadPrefixes = read
+	}
 }
 
 // NewCELMutator creates a new CELMutator with the provided compiled programs.
 // The programs will be evaluated in order when Mutate is called.
-func NewCELMutator(programs []*CompiledProgram) *CELMutator {
-	return &CELMutator{programs: programs}
+func NewCELMutator(programs []*CompiledProgram, opts ...MutatorOption) *CELMutator {
+	m := &CELMutator{programs: programs, results: newResultTracker(programs)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.breakerConfig != nil {
+		m.breaker = newCircuitBreaker(programs, *m.breakerConfig, m.breakerLog, m.previousBreaker)
+	}
+	m.previousBreaker = nil
+	m.cache = newEvaluationCache(programs, m.cacheSize, m.cacheLog)
+	return m
 }
 
 // Mutate applies all configured CEL mutations to the provided PipelineRun.
@@ -40,56 +171,275 @@ func NewCELMutator(programs []*CompiledProgram) *CELMutator {
 // The PipelineRun is modified in-place. If any evaluation fails, the method
 // returns an error and the PipelineRun may be partially modified.
 //
+// The caller must own the PipelineRun exclusively: mutating an object shared
+// with other goroutines, e.g. obtained from an informer cache, races on its
+// maps and may crash the process. Use MutateCopy for shared objects, and
+// WithOwnershipGuard to detect the concurrent mutations.
+//
+// Mutate is idempotent: the amounts added by resource mutations are recorded
+// in AnnotationAppliedResources and subtracted before the PipelineRun is
+// mutated again.
+//
 // Parameters:
+//   - ctx: The context of the admission request, if any
 //   - pipelineRun: The PipelineRun to mutate. Must not be nil.
 //
 // Returns:
 //   - error: Any error that occurred during evaluation or mutation
-func (m *CELMutator) Mutate(pipelineRun *tekv1.PipelineRun) error {
-	mutations, err := m.evaluate(pipelineRun)
+func (m *CELMutator) Mutate(ctx context.Context, pipelineRun *tekv1.PipelineRun) error {
+	if pipelineRun == nil {
+		return fmt.Errorf("pipelineRun cannot be nil")
+	}
+	if m.ownership != nil {
+		release, err := m.ownership.acquire(pipelineRun)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	var input *tekv1.PipelineRun
+	if m.mutationLogger != nil && m.mutationLogger.config.IncludeInputSnapshot {
+		input = pipelineRun.DeepCopy()
+	}
+
+	mutations, err := m.apply(ctx, pipelineRun, m.results, m.breaker)
 	if err != nil {
+		if m.mutationLogger != nil {
+			m.mutationLogger.logFailure(ctx, input, pipelineRun, err)
+		}
 		return err
 	}
 
+	if m.mutationLogger != nil {
+		m.mutationLogger.logMutations(ctx, input, pipelineRun, mutations)
+	}
+
+	RecordMutationSuccess()
+	RecordMutationsGenerated(mutations)
+	return nil
+}
+
+// DryRun returns the mutations Mutate would apply to the PipelineRun,
+// without modifying it, logging them or tracking their stability. The
+// circuit breaker is ignored.
+func (m *CELMutator) DryRun(pipelineRun *tekv1.PipelineRun) ([]*MutationRequest, error) {
+	if pipelineRun == nil {
+		return nil, fmt.Errorf("pipelineRun cannot be nil")
+	}
+	return m.apply(context.Background(), pipelineRun.DeepCopy(), nil, nil)
+}
+
+// apply evaluates the programs and applies the resulting mutations to the
+// PipelineRun, in the order of orderMutations, and returns them. The
+// results of the programs are recorded in results, and their failures in
+// breaker, unless they're nil. The evaluation is aborted once the context
+// is done. The keys set to different values by several mutations, and the
+// mutations skipped because of the overwrite policy, are logged to the
+// logger of the context at debug level. The unknown resource annotations
+// are pruned once the programs are evaluated, see
+// WithResourceRequestPruning.
+func (m *CELMutator) apply(
+	ctx context.Context,
+	pipelineRun *tekv1.PipelineRun,
+	results *resultTracker,
+	breaker *circuitBreaker,
+) ([]*MutationRequest, error) {
+	// The keys set before the mutations are captured first
+	guard := newOverwriteGuard(m.overwritePolicy, pipelineRun)
+
+	// Undo the resource mutations of a previous invocation, so the values
+	// aren't summed twice. A follow-up mutator keeps the ones of the
+	// mutator it follows, which already reverted the previous invocation.
+	if !m.followUp {
+		revertAppliedResources(pipelineRun)
+	}
+
+	mutations, err := m.evaluate(ctx, pipelineRun, results, breaker)
+	if err != nil {
+		return nil, err
+	}
+	if m.overwritePolicy == OverwriteNever {
+		mutations = keepFirstValues(mutations)
+	}
+	mutations, collisions := orderMutations(mutations)
+	for _, collision := range collisions {
+		logr.FromContextOrDiscard(ctx).V(1).Info("Several mutations set the same key, the last one wins",
+			"type", collision.mutationType, "key", collision.key, "overwritten", collision.overwritten)
+	}
+
+	if len(m.keyNormalizationRules) > 0 {
+		mutations, err = m.normalize(pipelineRun, mutations)
+		if err != nil {
+			RecordMutationFailure()
+			return nil, err
+		}
+	}
+
+	if len(m.resourceWritePrefixes) > 0 {
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = make(map[string]string)
+		}
+		syncResourceAnnotations(pipelineRun, m.resourceReadPrefixes, m.resourceWritePrefixes)
+		mutations = expandResourceMutations(mutations, m.resourceWritePrefixes)
+	}
+	if m.pruneResourceRequests {
+		m.pruneUnknownResourceRequests(ctx, pipelineRun, mutations)
+	}
+
+	applied := make([]*MutationRequest, 0, len(mutations))
 	for _, mutation := range mutations {
-		pipelineRun, err = mutate(pipelineRun, mutation)
+		if !guard.allows(pipelineRun, mutation) {
+			logr.FromContextOrDiscard(ctx).V(1).Info("Keeping the value already set, see the overwrite policy",
+				"type", mutation.Type, "key", mutation.Key, "policy", m.overwritePolicy)
+			continue
+		}
+		pipelineRun, err = m.mutate(pipelineRun, mutation)
 		if err != nil {
 			RecordMutationFailure()
-			return fmt.Errorf("failed to apply mutation (type: %s, key: %s): %w", mutation.Type, mutation.Key, err)
+			return nil, fmt.Errorf("failed to apply mutation (type: %s, key: %s): %w",
+				mutation.Type, mutation.Key, &MutationValidationError{Mutation: mutation, Err: err})
 		}
+		applied = append(applied, mutation)
+	}
+	mutations = applied
+	if len(m.resourceWritePrefixes) > 0 {
+		// Annotation mutations may have set a resource annotation with a
+		// single prefix
+		syncResourceAnnotations(pipelineRun, m.resourceReadPrefixes, m.resourceWritePrefixes)
 	}
 
-	RecordMutationSuccess()
-	return nil
+	if err := recordAppliedResources(pipelineRun, mutations, m.followUp); err != nil {
+		RecordMutationFailure()
+		return nil, fmt.Errorf("failed to record the applied resource mutations: %w", err)
+	}
+	return mutations, nil
 }
 
-// evaluate runs all compiled programs against the PipelineRun and collects
-// all resulting mutations. Programs are evaluated in order, and all mutations
-// are collected before any are applied.
+// evaluate evaluates the programs applying to the PipelineRun, see
+// evaluateAll, and returns all their mutations, in the order of the
+// programs, before any of them is applied. The first failure is returned,
+// unless the program has onError mutations, see CompiledProgram.fallback.
+// The PipelineRuns which are too large are skipped, see tooLarge, and the
+// results are checked with checkLimits and cached, see cacheable.
 //
 // Parameters:
+//   - ctx: Aborts the evaluation once done
 //   - pipelineRun: The PipelineRun to evaluate against
+//   - results: Records the result of each program when all of them succeed, if not nil
+//   - breaker: Skips the tripped programs and records the failures, if not nil
 //
 // Returns:
 //   - []MutationRequest: All mutations from all programs
 //   - error: Any error that occurred during evaluation
-func (m *CELMutator) evaluate(pipelineRun *tekv1.PipelineRun) ([]*MutationRequest, error) {
+func (m *CELMutator) evaluate(
+	ctx context.Context,
+	pipelineRun *tekv1.PipelineRun,
+	results *resultTracker,
+	breaker *circuitBreaker,
+) ([]*MutationRequest, error) {
+	namespaceLabels := m.namespaces.labels(ctx, pipelineRun.Namespace)
+	var cacheKey [sha256.Size]byte
+	useCache := m.cacheable(breaker)
+	if useCache {
+		var err error
+		if cacheKey, err = m.cache.key(pipelineRun, namespaceLabels); err != nil {
+			return nil, fmt.Errorf("failed to compute the evaluation cache key: %w", err)
+		}
+		if cached, ok := m.cache.get(cacheKey); ok {
+			if results != nil {
+				results.observe(cached.programResults)
+			}
+			return cached.mutations, nil
+		}
+	}
+
+	// The PipelineRun is converted once for all the programs
+	pipelineRunMap, size, err := structToCELMapWithSize(pipelineRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert PipelineRun to map: %w", err)
+	}
+	if m.tooLarge(ctx, pipelineRun, size) {
+		return nil, nil
+	}
+
+	skipped := false
+	allow := func(i int) bool {
+		if breaker != nil && !breaker.allow(i) {
+			skipped = true
+			return false
+		}
+		return true
+	}
+	var firstErr error
+	fellBack := false
 	var allMutations []*MutationRequest
-	for _, program := range m.programs {
-		mutations, err := program.Evaluate(pipelineRun)
-		if err != nil {
-			return nil, err
+	var groups []string
+	// The results of the programs of the other queues aren't tracked
+	programResults := make(map[int][]*MutationRequest, len(m.programs))
+	for _, result := range evaluateAll(ctx, m.programs, pipelineRun, pipelineRunMap, namespaceLabels, allow) {
+		if result.Err != nil {
+			if breaker != nil {
+				breaker.recordFailure(result.Index, result.Err)
+			}
+			if fallback := m.programs[result.Index].fallback(); fallback != nil {
+				logr.FromContextOrDiscard(ctx).Info("Applying the onError mutations of the failed CEL expression",
+					"group", m.programs[result.Index].group, "name", result.Name, "index", result.Index,
+					"error", result.Err.Error())
+				fellBack = true
+				allMutations = append(allMutations, fallback...)
+				programResults[result.Index] = fallback
+				continue
+			}
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			continue
+		}
+		allMutations = append(allMutations, result.Mutations...)
+		programResults[result.Index] = result.Mutations
+		if group := m.programs[result.Index].group; !slices.Contains(groups, group) {
+			groups = append(groups, group)
 		}
-		allMutations = append(allMutations, mutations...)
 	}
-	RecordEvaluationSuccess()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := m.checkLimits(programResults); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		RecordEvaluationSuccess(group)
+	}
+	// The results are only recorded when all the programs of the queue
+	// were evaluated, so they're consistent. The failures replaced by the
+	// onError mutations may be transient, so they aren't cached either.
+	if results != nil && !skipped && !fellBack {
+		results.observe(programResults)
+	}
+	if useCache && !skipped && !fellBack {
+		m.cache.add(cacheKey, &cachedEvaluation{mutations: allMutations, programResults: programResults})
+	}
 	return allMutations, nil
 }
 
-// mutate applies a single mutation to the PipelineRun's metadata.
-// It handles label, annotation, and resource mutations, creating the respective
-// maps if they don't exist. Resource mutations have special summing behavior
-// for duplicate keys.
+// normalize folds resource mutations and existing resource annotations
+// with equivalent keys, see WithResourceKeyNormalization.
+func (m *CELMutator) normalize(pipelineRun *tekv1.PipelineRun, mutations []*MutationRequest) ([]*MutationRequest, error) {
+	mutations, err := normalizeResourceMutations(mutations, m.keyNormalizationRules)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeResourceAnnotations(pipelineRun, m.resourcePrefixes(), m.keyNormalizationRules); err != nil {
+		return nil, err
+	}
+	return mutations, nil
+}
+
+// mutate applies a single mutation to the PipelineRun, creating its labels
+// and annotations if they don't exist. The resource mutations are summed
+// with the value of their annotation; the other types replace or append to
+// the field they set, see the set helpers and appendEntry.
 //
 // Parameters:
 //   - pipelineRun: The PipelineRun to mutate
@@ -97,7 +447,7 @@ func (m *CELMutator) evaluate(pipelineRun *tekv1.PipelineRun) ([]*MutationReques
 //
 // Returns:
 //   - *tekv1.PipelineRun: The modified PipelineRun (same instance)
-func mutate(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) (*tekv1.PipelineRun, error) {
+func (m *CELMutator) mutate(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) (*tekv1.PipelineRun, error) {
 	switch mutation.Type {
 	case MutationTypeLabel:
 		if pipelineRun.Labels == nil {
@@ -109,6 +459,15 @@ func mutate(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) (*tekv1.P
 			pipelineRun.Annotations = make(map[string]string)
 		}
 		pipelineRun.Annotations[mutation.Key] = mutation.Value
+	case MutationTypeAppendAnnotation:
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = make(map[string]string)
+		}
+		value := appendEntry(pipelineRun.Annotations[mutation.Key], mutation.Value, mutation.Separator)
+		if err := validateAnnotationValue(value); err != nil {
+			return nil, err
+		}
+		pipelineRun.Annotations[mutation.Key] = value
 	case MutationTypeResource:
 		if pipelineRun.Annotations == nil {
 			pipelineRun.Annotations = make(map[string]string)
@@ -133,6 +492,145 @@ func mutate(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) (*tekv1.P
 
 		// Store the summed value back as string
 		pipelineRun.Annotations[mutation.Key] = strconv.Itoa(newValue)
+	case MutationTypeTimeout:
+		if err := m.setTimeout(pipelineRun, mutation); err != nil {
+			return nil, err
+		}
+	case MutationTypePodSet:
+		if err := setPodSet(pipelineRun, mutation); err != nil {
+			return nil, err
+		}
+	case MutationTypeServiceAccount:
+		m.setServiceAccount(pipelineRun, mutation)
+	case MutationTypeNodeSelector:
+		setNodeSelector(pipelineRun, mutation)
 	}
 	return pipelineRun, nil
 }
+
+// setPodSet adds the pod set of the mutation to the pod sets annotation, or
+// replaces the pod set of the same name.
+func setPodSet(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) error {
+	podSet, err := parsePodSet(mutation.Key, mutation.Value)
+	if err != nil {
+		// This should never happen because we validate the value in the CEL function
+		return err
+	}
+
+	var podSets []common.PodSetSpec
+	if value, exists := pipelineRun.Annotations[common.PodSetsAnnotation]; exists {
+		// This can happen if the user has manually set an invalid value
+		if podSets, err = common.ParsePodSets(value); err != nil {
+			return err
+		}
+	}
+	if i := slices.IndexFunc(podSets, func(s common.PodSetSpec) bool { return s.Name == podSet.Name }); i >= 0 {
+		podSets[i] = podSet
+	} else {
+		podSets = append(podSets, podSet)
+	}
+	if len(podSets) > common.MaxExtraPodSets {
+		return fmt.Errorf("too many pod sets, at most %d are allowed", common.MaxExtraPodSets)
+	}
+
+	value, err := json.Marshal(podSets)
+	if err != nil {
+		return err
+	}
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = make(map[string]string)
+	}
+	pipelineRun.Annotations[common.PodSetsAnnotation] = string(value)
+	return nil
+}
+
+// appendEntry appends the entry to the entries of value, separated by
+// separator, unless it's already one of them. Appending is idempotent, so
+// PipelineRuns can be mutated again.
+func appendEntry(value, entry, separator string) string {
+	if value == "" {
+		return entry
+	}
+	if hasEntry(value, entry, separator) {
+		return value
+	}
+	return value + separator + entry
+}
+
+// hasEntry reports whether the entry is one of the entries of value. The
+// whole entry is matched between separators, rather than the pieces of
+// value split on separator, so an entry containing the separator is found
+// too.
+func hasEntry(value, entry, separator string) bool {
+	return value == entry ||
+		strings.HasPrefix(value, entry+separator) ||
+		strings.HasSuffix(value, separator+entry) ||
+		strings.Contains(value, separator+entry+separator)
+}
+
+// setTimeout sets the timeout of the kind given by the key of the mutation.
+// Unless timeoutOverride is set, a timeout already set on the PipelineRun
+// is only replaced by a longer one, so the timeouts chosen by users aren't
+// shortened. As in Tekton, a zero timeout means no timeout.
+func (m *CELMutator) setTimeout(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) error {
+	duration, err := time.ParseDuration(mutation.Value)
+	if err != nil {
+		// This should never happen because we validate the value in the CEL function
+		return fmt.Errorf("failed to parse timeout %q: %w", mutation.Value, err)
+	}
+
+	if pipelineRun.Spec.Timeouts == nil {
+		pipelineRun.Spec.Timeouts = &tekv1.TimeoutFields{}
+	}
+	var field **metav1.Duration
+	switch mutation.Key {
+	case TimeoutKindPipeline:
+		field = &pipelineRun.Spec.Timeouts.Pipeline
+	case TimeoutKindTasks:
+		field = &pipelineRun.Spec.Timeouts.Tasks
+	case TimeoutKindFinally:
+		field = &pipelineRun.Spec.Timeouts.Finally
+	default:
+		return fmt.Errorf("invalid timeout kind %q", mutation.Key)
+	}
+
+	if *field != nil && !m.timeoutOverride && !longerTimeout(duration, (*field).Duration) {
+		return nil
+	}
+	*field = &metav1.Duration{Duration: duration}
+	return nil
+}
+
+// longerTimeout returns whether the timeout a is longer than b, a zero
+// timeout being unlimited.
+func longerTimeout(a, b time.Duration) bool {
+	if b == 0 {
+		return false
+	}
+	return a == 0 || a > b
+}
+
+// setServiceAccount sets the service account of the TaskRuns of the
+// PipelineRun. Unless serviceAccountOverride is set, the service account
+// already set on the PipelineRun is kept, so the service accounts chosen by
+// users aren't replaced.
+func (m *CELMutator) setServiceAccount(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) {
+	template := &pipelineRun.Spec.TaskRunTemplate
+	if template.ServiceAccountName != "" && !m.serviceAccountOverride {
+		return
+	}
+	template.ServiceAccountName = mutation.Value
+}
+
+// setNodeSelector sets the entry of the node selector of the pods of the
+// PipelineRun, adding the pod template when it's missing.
+func setNodeSelector(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) {
+	template := &pipelineRun.Spec.TaskRunTemplate
+	if template.PodTemplate == nil {
+		template.PodTemplate = &pod.PodTemplate{}
+	}
+	if template.PodTemplate.NodeSelector == nil {
+		template.PodTemplate.NodeSelector = make(map[string]string)
+	}
+	template.PodTemplate.NodeSelector[mutation.Key] = mutation.Value
+}
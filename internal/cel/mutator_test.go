@@ -1,12 +1,20 @@
 package cel
 
 import (
-	"maps"
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
 	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/pkg/celtest"
+	"github.com/konflux-ci/tekton-queue/pkg/resources"
 )
 
 // Common test constants to reduce duplication
@@ -57,95 +65,6 @@ const (
 		) : []`
 )
 
-// Common test data helpers
-func getBuildPlatformsParams() []tekv1.Param {
-	return []tekv1.Param{
-		{
-			Name: "build-platforms",
-			Value: tekv1.ParamValue{
-				Type: tekv1.ParamTypeArray,
-				ArrayVal: []string{
-					"linux/arm64",
-					"linux/amd64",
-					"linux/s390x",
-					"linux/ppc64le",
-				},
-			},
-		},
-	}
-}
-
-func getBuildPlatformsParamsSmall() []tekv1.Param {
-	return []tekv1.Param{
-		{
-			Name: "build-platforms",
-			Value: tekv1.ParamValue{
-				Type: tekv1.ParamTypeArray,
-				ArrayVal: []string{
-					"linux/arm64",
-					"linux/amd64",
-				},
-			},
-		},
-	}
-}
-
-// Helper function to create pipeline tasks with PLATFORM parameters
-func getPipelineTasksWithPlatforms() []tekv1.PipelineTask {
-	return []tekv1.PipelineTask{
-		{
-			Name: "build-arm64",
-			Params: []tekv1.Param{
-				{
-					Name:  "PLATFORM",
-					Value: tekv1.ParamValue{Type: tekv1.ParamTypeString, StringVal: "linux/arm64"},
-				},
-			},
-		},
-		{
-			Name: "build-amd64",
-			Params: []tekv1.Param{
-				{
-					Name:  "PLATFORM",
-					Value: tekv1.ParamValue{Type: tekv1.ParamTypeString, StringVal: "linux/amd64"},
-				},
-			},
-		},
-		{
-			Name: "build-s390x",
-			Params: []tekv1.Param{
-				{
-					Name:  "PLATFORM",
-					Value: tekv1.ParamValue{Type: tekv1.ParamTypeString, StringVal: "linux/s390x"},
-				},
-			},
-		},
-		{
-			Name: "no-platform-task",
-			// No PLATFORM parameter
-		},
-	}
-}
-
-// Helper function to create pipeline tasks without PLATFORM parameters
-func getPipelineTasksWithoutPlatforms() []tekv1.PipelineTask {
-	return []tekv1.PipelineTask{
-		{
-			Name: "setup",
-			Params: []tekv1.Param{
-				{
-					Name:  "VERSION",
-					Value: tekv1.ParamValue{Type: tekv1.ParamTypeString, StringVal: "1.0"},
-				},
-			},
-		},
-		{
-			Name: "cleanup",
-			// No parameters
-		},
-	}
-}
-
 func TestNewCELMutator(t *testing.T) {
 	g := NewWithT(t)
 
@@ -165,11 +84,9 @@ func TestCELMutator_Mutate(t *testing.T) {
 	tests := []struct {
 		name                string
 		expressions         []string
-		namespace           string // optional, defaults to "test-namespace"
 		initialLabels       map[string]string
 		initialAnnotations  map[string]string
-		initialParams       []tekv1.Param       // optional, for testing parameter access
-		pipelineSpec        *tekv1.PipelineSpec // optional, for testing pipeline spec scenarios
+		options             []celtest.Option // optional, for testing the namespace, params and pipeline spec
 		expectedLabels      map[string]string
 		expectedAnnotations map[string]string
 		expectErr           bool
@@ -279,7 +196,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 		{
 			name: "runtime error in expression",
 			expressions: []string{
-				`annotation("", "test-value")`, // empty key should cause error
+				`annotation(plrGenerateName, "test-value")`, // empty key should cause error
 			},
 			initialLabels:      nil,
 			initialAnnotations: nil,
@@ -399,6 +316,36 @@ func TestCELMutator_Mutate(t *testing.T) {
 			expectedAnnotations: nil,
 			expectErr:           false,
 		},
+		{
+			name: "priority of the PipelineRuns retried by Pipelines as Code",
+			expressions: []string{
+				`priority(pacRetryCount > 0 ? "retry" : "default")`,
+			},
+			initialLabels: nil,
+			initialAnnotations: map[string]string{
+				"pipelinesascode.tekton.dev/retry-count": "1",
+			},
+			expectedLabels: map[string]string{
+				"kueue.x-k8s.io/priority-class": "retry",
+			},
+			expectedAnnotations: map[string]string{
+				"pipelinesascode.tekton.dev/retry-count": "1",
+			},
+			expectErr: false,
+		},
+		{
+			name: "priority of the PipelineRuns not retried by Pipelines as Code",
+			expressions: []string{
+				`priority(pacRetryCount > 0 ? "retry" : "default")`,
+			},
+			initialLabels:      nil,
+			initialAnnotations: nil,
+			expectedLabels: map[string]string{
+				"kueue.x-k8s.io/priority-class": "default",
+			},
+			expectedAnnotations: nil,
+			expectErr:           false,
+		},
 		{
 			name: "priority function combined with other mutations",
 			expressions: []string{
@@ -427,7 +374,6 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:       nil,
 			initialAnnotations:  nil,
-			initialParams:       nil, // No parameters - should return empty array
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
 			expectErr:           true,
@@ -440,13 +386,16 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:      nil,
 			initialAnnotations: nil,
-			initialParams:      getBuildPlatformsParams(),
-			expectedLabels:     nil,
+			options: []celtest.Option{
+				celtest.WithParams(celtest.ArrayParam("build-platforms",
+					"linux/arm64", "linux/amd64", "linux/s390x", "linux/ppc64le")),
+			},
+			expectedLabels: nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-linux-arm64":   "1",
-				"kueue.konflux-ci.dev/requests-linux-amd64":   "1",
-				"kueue.konflux-ci.dev/requests-linux-s390x":   "1",
-				"kueue.konflux-ci.dev/requests-linux-ppc64le": "1",
+				resources.AnnotationKeyFor("linux-arm64"):   "1",
+				resources.AnnotationKeyFor("linux-amd64"):   "1",
+				resources.AnnotationKeyFor("linux-s390x"):   "1",
+				resources.AnnotationKeyFor("linux-ppc64le"): "1",
 			},
 			expectErr: false,
 		},
@@ -457,7 +406,6 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:       nil,
 			initialAnnotations:  nil,
-			initialParams:       nil,
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
 			expectErr:           false,
@@ -469,15 +417,19 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:      nil,
 			initialAnnotations: nil,
-			initialParams:      nil,
-			pipelineSpec: &tekv1.PipelineSpec{
-				Tasks: getPipelineTasksWithPlatforms(),
+			options: []celtest.Option{
+				celtest.WithPipelineSpec(
+					celtest.PlatformTask("build-arm64", "linux/arm64"),
+					celtest.PlatformTask("build-amd64", "linux/amd64"),
+					celtest.PlatformTask("build-s390x", "linux/s390x"),
+					celtest.Task("no-platform-task"),
+				),
 			},
 			expectedLabels: nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-linux-arm64": "1",
-				"kueue.konflux-ci.dev/requests-linux-amd64": "1",
-				"kueue.konflux-ci.dev/requests-linux-s390x": "1",
+				resources.AnnotationKeyFor("linux-arm64"): "1",
+				resources.AnnotationKeyFor("linux-amd64"): "1",
+				resources.AnnotationKeyFor("linux-s390x"): "1",
 			},
 			expectErr: false,
 		},
@@ -488,8 +440,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:       nil,
 			initialAnnotations:  nil,
-			initialParams:       nil,
-			pipelineSpec:        &tekv1.PipelineSpec{},
+			options:             []celtest.Option{celtest.WithPipelineSpec()},
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
 			expectErr:           false,
@@ -501,8 +452,6 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:       nil,
 			initialAnnotations:  nil,
-			initialParams:       nil,
-			pipelineSpec:        nil,
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
 			expectErr:           false,
@@ -514,9 +463,11 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels:      nil,
 			initialAnnotations: nil,
-			initialParams:      nil,
-			pipelineSpec: &tekv1.PipelineSpec{
-				Tasks: getPipelineTasksWithoutPlatforms(),
+			options: []celtest.Option{
+				celtest.WithPipelineSpec(
+					celtest.Task("setup", celtest.StringParam("VERSION", "1.0")),
+					celtest.Task("cleanup"),
+				),
 			},
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
@@ -627,7 +578,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 			expressions: []string{
 				complexPriorityExpression,
 			},
-			namespace:          "mintmaker",
+			options:            []celtest.Option{celtest.WithNamespace("mintmaker")},
 			initialLabels:      nil,
 			initialAnnotations: nil,
 			expectedLabels: map[string]string{
@@ -678,14 +629,16 @@ func TestCELMutator_Mutate(t *testing.T) {
 				"pipelinesascode.tekton.dev/event-type": "push",
 			},
 			initialAnnotations: nil,
-			initialParams:      getBuildPlatformsParamsSmall(),
+			options: []celtest.Option{
+				celtest.WithParams(celtest.ArrayParam("build-platforms", "linux/arm64", "linux/amd64")),
+			},
 			expectedLabels: map[string]string{
 				"pipelinesascode.tekton.dev/event-type": "push",
 				"kueue.x-k8s.io/priority-class":         "konflux-post-merge-build",
 			},
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-linux-arm64": "1",
-				"kueue.konflux-ci.dev/requests-linux-amd64": "1",
+				resources.AnnotationKeyFor("linux-arm64"): "1",
+				resources.AnnotationKeyFor("linux-amd64"): "1",
 			},
 			expectErr: false,
 		},
@@ -698,7 +651,8 @@ func TestCELMutator_Mutate(t *testing.T) {
 			initialAnnotations: nil,
 			expectedLabels:     nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-aws-vm-x": "1000",
+				resources.AnnotationKeyFor("aws-vm-x"):       "1000",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":1000}`,
 			},
 			expectErr: false,
 		},
@@ -709,11 +663,12 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels: nil,
 			initialAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-aws-vm-y": "1024",
+				resources.AnnotationKeyFor("aws-vm-y"): "1024",
 			},
 			expectedLabels: nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-aws-vm-y": "3072", // 1024 + 2048
+				resources.AnnotationKeyFor("aws-vm-y"):       "3072", // 1024 + 2048
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-y":2048}`,
 			},
 			expectErr: false,
 		},
@@ -724,7 +679,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 			},
 			initialLabels: nil,
 			initialAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-ibm-vm-z": "invalid",
+				resources.AnnotationKeyFor("ibm-vm-z"): "invalid",
 			},
 			expectedLabels:      nil,
 			expectedAnnotations: nil,
@@ -741,7 +696,8 @@ func TestCELMutator_Mutate(t *testing.T) {
 			initialAnnotations: nil,
 			expectedLabels:     nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-aws-vm-x": "6", // 2 + 4
+				resources.AnnotationKeyFor("aws-vm-x"):       "6", // 2 + 4
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-x":6}`,
 			},
 			expectErr: false,
 		},
@@ -756,8 +712,9 @@ func TestCELMutator_Mutate(t *testing.T) {
 				"env": "prod",
 			},
 			expectedAnnotations: map[string]string{
-				"tekton.dev/pipeline":                    "test",
-				"kueue.konflux-ci.dev/requests-aws-vm-y": "1000",
+				"tekton.dev/pipeline":                        "test",
+				resources.AnnotationKeyFor("aws-vm-y"):       "1000",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-aws-vm-y":1000}`,
 			},
 			expectErr: false,
 		},
@@ -770,7 +727,8 @@ func TestCELMutator_Mutate(t *testing.T) {
 			initialAnnotations: nil,
 			expectedLabels:     nil,
 			expectedAnnotations: map[string]string{
-				"kueue.konflux-ci.dev/requests-ibm-vm-z": "0",
+				resources.AnnotationKeyFor("ibm-vm-z"):       "0",
+				"kueue.konflux-ci.dev/cel-resources-applied": `{"kueue.konflux-ci.dev/requests-ibm-vm-z":0}`,
 			},
 			expectErr: false,
 		},
@@ -780,33 +738,12 @@ func TestCELMutator_Mutate(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			g := NewWithT(t)
 
-			// Determine namespace to use
-			namespace := tt.namespace
-			if namespace == "" {
-				namespace = "test-namespace"
-			}
-
 			// Create PipelineRun with initial state
-			pipelineRun := &tekv1.PipelineRun{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:        "test-pipeline",
-					Namespace:   namespace,
-					Labels:      maps.Clone(tt.initialLabels),
-					Annotations: maps.Clone(tt.initialAnnotations),
-				},
-				Spec: tekv1.PipelineRunSpec{
-					PipelineRef: &tekv1.PipelineRef{
-						Name: "test-pipeline",
-					},
-					Params: tt.initialParams,
-				},
-			}
-
-			// Add pipeline spec if provided
-			if tt.pipelineSpec != nil {
-				pipelineRun.Spec.PipelineRef = nil
-				pipelineRun.Spec.PipelineSpec = tt.pipelineSpec
-			}
+			opts := append([]celtest.Option{
+				celtest.WithLabels(tt.initialLabels),
+				celtest.WithAnnotations(tt.initialAnnotations),
+			}, tt.options...)
+			pipelineRun := celtest.NewPipelineRun(opts...)
 
 			// Compile programs and create mutator
 			programs, err := CompileCELPrograms(tt.expressions)
@@ -815,7 +752,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 			mutator := NewCELMutator(programs)
 
 			// Apply mutations
-			err = mutator.Mutate(pipelineRun)
+			err = mutator.Mutate(context.Background(), pipelineRun)
 
 			// Check for expected errors
 			if tt.expectErr {
@@ -827,12 +764,7 @@ func TestCELMutator_Mutate(t *testing.T) {
 			}
 
 			g.Expect(err).NotTo(HaveOccurred())
-
-			// Verify labels
-			g.Expect(pipelineRun.Labels).To(Equal(tt.expectedLabels))
-
-			// Verify annotations
-			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+			celtest.AssertMutations(t, pipelineRun, tt.expectedLabels, tt.expectedAnnotations)
 		})
 	}
 }
@@ -846,7 +778,7 @@ func TestCELMutator_Mutate_NilPipelineRun(t *testing.T) {
 	g.Expect(err).NotTo(HaveOccurred())
 
 	mutator := NewCELMutator(programs)
-	err = mutator.Mutate(nil)
+	err = mutator.Mutate(context.Background(), nil)
 
 	g.Expect(err).To(HaveOccurred())
 }
@@ -856,17 +788,393 @@ func TestCELMutator_EmptyPrograms(t *testing.T) {
 
 	mutator := NewCELMutator([]*CompiledProgram{})
 
-	pipelineRun := &tekv1.PipelineRun{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "test-pipeline",
-			Namespace: "test-namespace",
-		},
-	}
+	pipelineRun := celtest.NewPipelineRun()
 
-	err := mutator.Mutate(pipelineRun)
+	err := mutator.Mutate(context.Background(), pipelineRun)
 	g.Expect(err).NotTo(HaveOccurred())
 
 	// Should not crash or modify the PipelineRun
 	g.Expect(pipelineRun.Labels).To(BeNil())
 	g.Expect(pipelineRun.Annotations).To(BeNil())
 }
+
+func TestCELMutator_Mutate_Timeouts(t *testing.T) {
+	duration := func(s string) *metav1.Duration {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &metav1.Duration{Duration: d}
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		override   bool
+		timeouts   *tekv1.TimeoutFields
+		expected   *tekv1.TimeoutFields
+	}{
+		{
+			name:       "pipeline timeout without timeouts",
+			expression: `timeout("pipeline", "30m")`,
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("30m")},
+		},
+		{
+			name:       "tasks timeout keeps the other timeouts",
+			expression: `timeout("tasks", "20m")`,
+			timeouts:   &tekv1.TimeoutFields{Pipeline: duration("1h")},
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("1h"), Tasks: duration("20m")},
+		},
+		{
+			name:       "finally timeout",
+			expression: `timeout("finally", "5m")`,
+			expected:   &tekv1.TimeoutFields{Finally: duration("5m")},
+		},
+		{
+			name:       "all the timeouts",
+			expression: `[timeout("pipeline", "1h"), timeout("tasks", "50m"), timeout("finally", "10m")]`,
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("1h"), Tasks: duration("50m"), Finally: duration("10m")},
+		},
+		{
+			name:       "a longer user timeout isn't shortened",
+			expression: `timeout("pipeline", "30m")`,
+			timeouts:   &tekv1.TimeoutFields{Pipeline: duration("2h")},
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("2h")},
+		},
+		{
+			name:       "a shorter user timeout is extended",
+			expression: `timeout("pipeline", "30m")`,
+			timeouts:   &tekv1.TimeoutFields{Pipeline: duration("10m")},
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("30m")},
+		},
+		{
+			name:       "an unlimited user timeout isn't shortened",
+			expression: `timeout("tasks", "30m")`,
+			timeouts:   &tekv1.TimeoutFields{Tasks: duration("0s")},
+			expected:   &tekv1.TimeoutFields{Tasks: duration("0s")},
+		},
+		{
+			name:       "the override shortens a user timeout",
+			expression: `timeout("pipeline", "30m")`,
+			override:   true,
+			timeouts:   &tekv1.TimeoutFields{Pipeline: duration("2h")},
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("30m")},
+		},
+		{
+			name:       "queue-aware timeout",
+			expression: `pipelineRun.metadata.labels["kueue.x-k8s.io/queue-name"] == "low-priority" ? [timeout("pipeline", "45m")] : []`,
+			timeouts:   &tekv1.TimeoutFields{Pipeline: duration("1h")},
+			override:   true,
+			expected:   &tekv1.TimeoutFields{Pipeline: duration("45m")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			var opts []MutatorOption
+			if tt.override {
+				opts = append(opts, WithTimeoutOverride())
+			}
+			mutator := NewCELMutator(programs, opts...)
+
+			pipelineRun := celtest.NewPipelineRun(
+				celtest.WithLabels(map[string]string{"kueue.x-k8s.io/queue-name": "low-priority"}),
+				celtest.WithTimeouts(tt.timeouts),
+			)
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Spec.Timeouts).To(Equal(tt.expected))
+
+			// Mutating again doesn't change the timeouts
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Spec.Timeouts).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_Mutate_InvalidTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`timeout("pipeline", pipelineRun.metadata.annotations["timeout"])`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	pipelineRun := celtest.NewPipelineRun(
+		celtest.WithAnnotations(map[string]string{"timeout": "one hour"}),
+	)
+	err = mutator.Mutate(context.Background(), pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("invalid pipeline timeout")))
+	g.Expect(pipelineRun.Spec.Timeouts).To(BeNil())
+}
+
+func TestCELMutator_Mutate_ServiceAccount(t *testing.T) {
+	tests := []struct {
+		name           string
+		expression     string
+		override       bool
+		serviceAccount string
+		expected       string
+	}{
+		{
+			name:       "sets the service account",
+			expression: `serviceAccount("release-pipeline")`,
+			expected:   "release-pipeline",
+		},
+		{
+			name:           "keeps the service account of the user",
+			expression:     `serviceAccount("release-pipeline")`,
+			serviceAccount: "build-pipeline",
+			expected:       "build-pipeline",
+		},
+		{
+			name:           "the override replaces the service account of the user",
+			expression:     `serviceAccount("release-pipeline")`,
+			override:       true,
+			serviceAccount: "build-pipeline",
+			expected:       "release-pipeline",
+		},
+		{
+			name:       "queue-aware service account",
+			expression: `pipelineRun.metadata.labels["kueue.x-k8s.io/queue-name"] == "release" ? [serviceAccount("release-pipeline")] : []`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			var opts []MutatorOption
+			if tt.override {
+				opts = append(opts, WithServiceAccountOverride())
+			}
+			mutator := NewCELMutator(programs, opts...)
+
+			pipelineRun := celtest.NewPipelineRun(
+				celtest.WithLabels(map[string]string{"kueue.x-k8s.io/queue-name": "build"}),
+			)
+			pipelineRun.Spec.TaskRunTemplate.ServiceAccountName = tt.serviceAccount
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Spec.TaskRunTemplate.ServiceAccountName).To(Equal(tt.expected))
+			g.Expect(pipelineRun.Spec.TaskRunTemplate.PodTemplate).To(BeNil())
+		})
+	}
+}
+
+func TestCELMutator_Mutate_NodeSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`[nodeSelector("kubernetes.io/arch", "arm64"), nodeSelector("konflux-ci.dev/pool", "release")]`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	// The pod template is added
+	pipelineRun := celtest.NewPipelineRun()
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+	g.Expect(pipelineRun.Spec.TaskRunTemplate.PodTemplate.NodeSelector).To(Equal(map[string]string{
+		"kubernetes.io/arch": "arm64", "konflux-ci.dev/pool": "release",
+	}))
+
+	// The other entries and fields of the pod template are kept
+	pipelineRun = celtest.NewPipelineRun()
+	pipelineRun.Spec.TaskRunTemplate.PodTemplate = &pod.PodTemplate{
+		NodeSelector:      map[string]string{"kubernetes.io/arch": "amd64", "kubernetes.io/os": "linux"},
+		PriorityClassName: ptr.To("high"),
+	}
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+	g.Expect(pipelineRun.Spec.TaskRunTemplate.PodTemplate.NodeSelector).To(Equal(map[string]string{
+		"kubernetes.io/arch": "arm64", "kubernetes.io/os": "linux", "konflux-ci.dev/pool": "release",
+	}))
+	g.Expect(pipelineRun.Spec.TaskRunTemplate.PodTemplate.PriorityClassName).To(HaveValue(Equal("high")))
+}
+
+func TestCELMutator_Mutate_AppendAnnotation(t *testing.T) {
+	const key = "kueue.konflux-ci.dev/platforms"
+
+	tests := []struct {
+		name        string
+		expression  string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:       "fresh key",
+			expression: `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-amd64", ",")`,
+			expected:   "linux-amd64",
+		},
+		{
+			name:        "append to the existing value",
+			expression:  `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-arm64", ",")`,
+			annotations: map[string]string{key: "linux-amd64"},
+			expected:    "linux-amd64,linux-arm64",
+		},
+		{
+			name: "several expressions accumulate",
+			expression: `[appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-amd64", ";"), ` +
+				`appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-s390x", ";")]`,
+			expected: "linux-amd64;linux-s390x",
+		},
+		{
+			name:        "identical entries are de-duplicated",
+			expression:  `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-arm64", ",")`,
+			annotations: map[string]string{key: "linux-arm64,linux-amd64"},
+			expected:    "linux-arm64,linux-amd64",
+		},
+		{
+			name:        "an entry containing the value isn't identical",
+			expression:  `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-arm", ",")`,
+			annotations: map[string]string{key: "linux-arm64"},
+			expected:    "linux-arm64,linux-arm",
+		},
+		{
+			name:       "an entry containing the separator is appended once",
+			expression: `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux,arm64", ",")`,
+			expected:   "linux,arm64",
+		},
+		{
+			name:        "an entry containing the separator is de-duplicated",
+			expression:  `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux,arm64", ",")`,
+			annotations: map[string]string{key: "linux-amd64,linux,arm64,linux-s390x"},
+			expected:    "linux-amd64,linux,arm64,linux-s390x",
+		},
+		{
+			name:        "an entry containing the separator isn't matched by its pieces",
+			expression:  `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux,arm64", ",")`,
+			annotations: map[string]string{key: "arm64,linux"},
+			expected:    "arm64,linux,linux,arm64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs)
+
+			pipelineRun := celtest.NewPipelineRun(celtest.WithAnnotations(tt.annotations))
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(key, tt.expected))
+
+			// Mutating again doesn't append the entries twice
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(key, tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_Mutate_AppendAnnotationTooLong(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-amd64", ",")`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	existing := strings.Repeat("x", maxAnnotationValueSize-len("linux-amd64"))
+	pipelineRun := celtest.NewPipelineRun(
+		celtest.WithAnnotations(map[string]string{"kueue.konflux-ci.dev/platforms": existing}),
+	)
+	err = mutator.Mutate(context.Background(), pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("annotation value is too long")))
+	g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue("kueue.konflux-ci.dev/platforms", existing))
+}
+
+func TestCELMutator_Mutate_PodSets(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:       "fresh annotation",
+			expression: `podset("arm-builders", 3, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			expected:   `[{"name":"arm-builders","count":3,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}}]`,
+		},
+		{
+			name: "several pod sets",
+			expression: `[podset("arm-builders", 3, {"kueue.konflux-ci.dev/linux-arm64": "1"}), ` +
+				`podset("gpu", 1, {"cpu": "500m", "nvidia.com/gpu": "1"})]`,
+			expected: `[{"name":"arm-builders","count":3,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}},` +
+				`{"name":"gpu","count":1,"requests":{"cpu":"500m","nvidia.com/gpu":"1"}}]`,
+		},
+		{
+			name:       "the pod set of the same name is replaced",
+			expression: `podset("arm-builders", 2, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			annotations: map[string]string{
+				common.PodSetsAnnotation: `[{"name":"gpu","count":1,"requests":{"nvidia.com/gpu":"1"}},` +
+					`{"name":"arm-builders","count":5,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}}]`,
+			},
+			expected: `[{"name":"gpu","count":1,"requests":{"nvidia.com/gpu":"1"}},` +
+				`{"name":"arm-builders","count":2,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs)
+
+			pipelineRun := celtest.NewPipelineRun(celtest.WithAnnotations(tt.annotations))
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(common.PodSetsAnnotation, tt.expected))
+
+			// Mutating again gives the same pod sets
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(common.PodSetsAnnotation, tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_Mutate_PodSetsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		errorMsg string
+	}{
+		{
+			name:     "invalid existing annotation",
+			existing: `{"name":"gpu"}`,
+			errorMsg: "invalid pod sets",
+		},
+		{
+			name: "too many pod sets",
+			existing: `[{"name":"a","count":1},{"name":"b","count":1},{"name":"c","count":1},` +
+				`{"name":"d","count":1},{"name":"e","count":1},{"name":"f","count":1},{"name":"g","count":1}]`,
+			errorMsg: "too many pod sets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{
+				`podset("arm-builders", 3, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs)
+
+			pipelineRun := celtest.NewPipelineRun(
+				celtest.WithAnnotations(map[string]string{common.PodSetsAnnotation: tt.existing}),
+			)
+			err = mutator.Mutate(context.Background(), pipelineRun)
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+			g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(common.PodSetsAnnotation, tt.existing))
+		})
+	}
+}
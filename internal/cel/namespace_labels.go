@@ -0,0 +1,54 @@
+package cel
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceLabelsVariable is the variable of the labels of the namespace of
+// the PipelineRun, see WithNamespaceLabels.
+const NamespaceLabelsVariable = "namespaceLabels"
+
+// WithNamespaceLabels exposes the labels of the namespace of the
+// PipelineRuns to the programs as the namespaceLabels variable. The
+// namespaces are read from lister, usually the cache of an informer, so the
+// evaluations don't wait for the API server: the variable is empty while
+// synced returns false, and for the namespaces which can't be read, e.g.
+// not cached yet. Without the option, the variable is always empty.
+func WithNamespaceLabels(lister client.Reader, synced func() bool) MutatorOption {
+	return func(m *CELMutator) {
+		m.namespaces = &namespaceLister{reader: lister, synced: synced}
+	}
+}
+
+// namespaceLister reads the labels of the namespaces of the PipelineRuns.
+type namespaceLister struct {
+	reader client.Reader
+	synced func() bool
+}
+
+// labels returns the labels of the namespace, empty when they're unknown,
+// or nil when the namespaces aren't exposed.
+func (l *namespaceLister) labels(ctx context.Context, namespace string) map[string]string {
+	if l == nil {
+		return nil
+	}
+	log := logr.FromContextOrDiscard(ctx)
+	if !l.synced() {
+		log.V(1).Info("The namespaces aren't synced yet, namespaceLabels is empty", "namespace", namespace)
+		return map[string]string{}
+	}
+	ns := &corev1.Namespace{}
+	if err := l.reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		log.V(1).Info("Unable to get the namespace, namespaceLabels is empty", "namespace", namespace,
+			"error", err.Error())
+		return map[string]string{}
+	}
+	if ns.Labels == nil {
+		return map[string]string{}
+	}
+	return ns.Labels
+}
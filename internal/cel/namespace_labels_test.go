@@ -0,0 +1,114 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestCELMutator_NamespaceLabels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	namespaces := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "gold-tenant", Labels: map[string]string{"tier": "gold"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unlabeled-tenant"}},
+	).Build()
+	expression := `"tier" in namespaceLabels && namespaceLabels["tier"] == "gold" ? priority("high") : priority("default")`
+
+	tests := []struct {
+		name      string
+		namespace string
+		synced    bool
+		opts      []MutatorOption
+		expected  string
+	}{
+		{
+			name:      "labeled namespace",
+			namespace: "gold-tenant",
+			synced:    true,
+			expected:  "high",
+		},
+		{
+			name:      "unlabeled namespace",
+			namespace: "unlabeled-tenant",
+			synced:    true,
+			expected:  "default",
+		},
+		{
+			name:      "namespace not cached",
+			namespace: "unknown-tenant",
+			synced:    true,
+			expected:  "default",
+		},
+		{
+			name:      "namespaces not synced",
+			namespace: "gold-tenant",
+			expected:  "default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms([]string{expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs, WithNamespaceLabels(namespaces, func() bool { return tt.synced }))
+			pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: tt.namespace}}
+
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Labels).To(HaveKeyWithValue(priorityLabel, tt.expected))
+		})
+	}
+
+	t.Run("without the option", func(t *testing.T) {
+		g := NewWithT(t)
+		programs, err := CompileCELPrograms([]string{expression})
+		g.Expect(err).NotTo(HaveOccurred())
+		pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "run", Namespace: "gold-tenant"}}
+
+		g.Expect(NewCELMutator(programs).Mutate(context.Background(), pipelineRun)).To(Succeed())
+		g.Expect(pipelineRun.Labels).To(HaveKeyWithValue(priorityLabel, "default"))
+	})
+}
+
+func TestCELMutator_NamespaceLabels_EvaluationCache(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	tiers := map[string]string{"tier": "gold"}
+	gets := 0
+	namespaces := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+	).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			obj.SetLabels(tiers)
+			return nil
+		},
+	}).Build()
+	programs, err := CompileCELPrograms([]string{`priority(namespaceLabels["tier"])`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithNamespaceLabels(namespaces, func() bool { return true }),
+		WithEvaluationCache(logr.Discard(), 10))
+	priority := func() string {
+		pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{GenerateName: "build-", Namespace: "tenant"}}
+		g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+		return pipelineRun.Labels[priorityLabel]
+	}
+
+	g.Expect(priority()).To(Equal("gold"))
+	// The cached results of the former labels of the namespace aren't
+	// reused once they change
+	tiers = map[string]string{"tier": "silver"}
+	g.Expect(priority()).To(Equal("silver"))
+	g.Expect(gets).To(Equal(2))
+}
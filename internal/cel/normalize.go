@@ -0,0 +1,175 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/tekton-queue/pkg/resources"
+)
+
+// ResourceAnnotationPrefix is the prefix of the annotations created by
+// resource mutations, see resources.AnnotationPrefix.
+const ResourceAnnotationPrefix = resources.AnnotationPrefix
+
+// KeyNormalizationRule is a canonicalization rule applied to the suffix of
+// resource annotation keys, i.e. the part following ResourceAnnotationPrefix.
+type KeyNormalizationRule string
+
+// Valid key normalization rules
+const (
+	// KeyNormalizationLowercase lowercases the key suffix.
+	KeyNormalizationLowercase KeyNormalizationRule = "lowercase"
+	// KeyNormalizationSlashToDash replaces slashes with dashes.
+	KeyNormalizationSlashToDash KeyNormalizationRule = "slashToDash"
+	// KeyNormalizationCollapseDashes replaces repeated dashes with a single one.
+	KeyNormalizationCollapseDashes KeyNormalizationRule = "collapseDashes"
+)
+
+// DefaultKeyNormalizationRules returns the rules used when resource key
+// normalization is enabled without an explicit list of rules.
+func DefaultKeyNormalizationRules() []KeyNormalizationRule {
+	return []KeyNormalizationRule{
+		KeyNormalizationLowercase,
+		KeyNormalizationSlashToDash,
+		KeyNormalizationCollapseDashes,
+	}
+}
+
+// IsValid checks if the key normalization rule is valid
+func (r KeyNormalizationRule) IsValid() bool {
+	return slices.Contains(DefaultKeyNormalizationRules(), r)
+}
+
+// ParseKeyNormalizationRules converts rule names to KeyNormalizationRules.
+// An empty list yields the default rules.
+func ParseKeyNormalizationRules(names []string) ([]KeyNormalizationRule, error) {
+	if len(names) == 0 {
+		return DefaultKeyNormalizationRules(), nil
+	}
+	rules := make([]KeyNormalizationRule, 0, len(names))
+	for _, name := range names {
+		rule := KeyNormalizationRule(name)
+		if !rule.IsValid() {
+			return nil, fmt.Errorf("invalid key normalization rule: %q, must be one of: %v", name, DefaultKeyNormalizationRules())
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r KeyNormalizationRule) apply(s string) string {
+	switch r {
+	case KeyNormalizationLowercase:
+		return strings.ToLower(s)
+	case KeyNormalizationSlashToDash:
+		return strings.ReplaceAll(s, "/", "-")
+	case KeyNormalizationCollapseDashes:
+		for strings.Contains(s, "--") {
+			s = strings.ReplaceAll(s, "--", "-")
+		}
+		return s
+	}
+	return s
+}
+
+// NormalizeResourceKey applies the rules, in order, to the suffix of a
+// resource annotation key. Keys without ResourceAnnotationPrefix are
+// returned unchanged.
+func NormalizeResourceKey(key string, rules []KeyNormalizationRule) string {
+	return normalizeResourceKey(key, []string{ResourceAnnotationPrefix}, rules)
+}
+
+// normalizeResourceKey applies the rules, in order, to the suffix of a
+// resource annotation key with one of the prefixes. Other keys are returned
+// unchanged.
+func normalizeResourceKey(key string, prefixes []string, rules []KeyNormalizationRule) string {
+	for _, prefix := range prefixes {
+		suffix, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		for _, rule := range rules {
+			suffix = rule.apply(suffix)
+		}
+		return prefix + suffix
+	}
+	return key
+}
+
+// normalizeResourceMutations rewrites the keys of resource mutations to
+// their canonical form and folds mutations with equivalent keys into a
+// single mutation by summing their values. Other mutations are returned
+// untouched and the order of the mutations is preserved.
+func normalizeResourceMutations(mutations []*MutationRequest, rules []KeyNormalizationRule) ([]*MutationRequest, error) {
+	folded := make([]*MutationRequest, 0, len(mutations))
+	byKey := map[string]*MutationRequest{}
+	for _, mutation := range mutations {
+		if mutation.Type != MutationTypeResource {
+			folded = append(folded, mutation)
+			continue
+		}
+		key := NormalizeResourceKey(mutation.Key, rules)
+		existing, ok := byKey[key]
+		if !ok {
+			normalized := &MutationRequest{Type: mutation.Type, Key: key, Value: mutation.Value}
+			byKey[key] = normalized
+			folded = append(folded, normalized)
+			continue
+		}
+		sum, err := sumResourceValues(existing.Value, mutation.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fold resource mutation %q into %q: %w", mutation.Key, key, err)
+		}
+		existing.Value = sum
+	}
+	return folded, nil
+}
+
+// normalizeResourceAnnotations rewrites the existing resource annotations of
+// the PipelineRun, with any of the prefixes, to their canonical form, summing
+// the values of annotations with equivalent keys.
+func normalizeResourceAnnotations(pipelineRun *tekv1.PipelineRun, prefixes []string, rules []KeyNormalizationRule) error {
+	keys := make([]string, 0, len(pipelineRun.Annotations))
+	for key := range pipelineRun.Annotations {
+		if slices.ContainsFunc(prefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+			keys = append(keys, key)
+		}
+	}
+	// Sort the keys so errors are deterministic
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		canonical := normalizeResourceKey(key, prefixes, rules)
+		if canonical == key {
+			continue
+		}
+		value := pipelineRun.Annotations[key]
+		if existing, ok := pipelineRun.Annotations[canonical]; ok {
+			sum, err := sumResourceValues(existing, value)
+			if err != nil {
+				return fmt.Errorf("failed to fold resource annotation %q into %q: %w", key, canonical, err)
+			}
+			value = sum
+		}
+		delete(pipelineRun.Annotations, key)
+		pipelineRun.Annotations[canonical] = value
+	}
+	return nil
+}
+
+// sumResourceValues sums two integer resource values represented as strings.
+func sumResourceValues(a, b string) (string, error) {
+	x, err := strconv.Atoi(a)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse resource value %q as integer: %w", a, err)
+	}
+	y, err := strconv.Atoi(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse resource value %q as integer: %w", b, err)
+	}
+	return strconv.Itoa(x + y), nil
+}
@@ -0,0 +1,237 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeResourceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		rules    []KeyNormalizationRule
+		expected string
+	}{
+		{
+			name:     "lowercase",
+			key:      ResourceAnnotationPrefix + "LINUX-AMD64",
+			rules:    []KeyNormalizationRule{KeyNormalizationLowercase},
+			expected: ResourceAnnotationPrefix + "linux-amd64",
+		},
+		{
+			name:     "slash to dash",
+			key:      ResourceAnnotationPrefix + "linux/amd64",
+			rules:    []KeyNormalizationRule{KeyNormalizationSlashToDash},
+			expected: ResourceAnnotationPrefix + "linux-amd64",
+		},
+		{
+			name:     "collapse dashes",
+			key:      ResourceAnnotationPrefix + "linux---amd64--x",
+			rules:    []KeyNormalizationRule{KeyNormalizationCollapseDashes},
+			expected: ResourceAnnotationPrefix + "linux-amd64-x",
+		},
+		{
+			name:     "rules are applied in order",
+			key:      ResourceAnnotationPrefix + "LINUX/-/AMD64",
+			rules:    DefaultKeyNormalizationRules(),
+			expected: ResourceAnnotationPrefix + "linux-amd64",
+		},
+		{
+			name:     "only the suffix is normalized",
+			key:      ResourceAnnotationPrefix + "Linux",
+			rules:    []KeyNormalizationRule{KeyNormalizationSlashToDash, KeyNormalizationLowercase},
+			expected: ResourceAnnotationPrefix + "linux",
+		},
+		{
+			name:     "keys without the resource prefix are untouched",
+			key:      "example.com/LINUX--AMD64",
+			rules:    DefaultKeyNormalizationRules(),
+			expected: "example.com/LINUX--AMD64",
+		},
+		{
+			name:     "no rules",
+			key:      ResourceAnnotationPrefix + "LINUX/AMD64",
+			rules:    nil,
+			expected: ResourceAnnotationPrefix + "LINUX/AMD64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(NormalizeResourceKey(tt.key, tt.rules)).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestParseKeyNormalizationRules(t *testing.T) {
+	g := NewWithT(t)
+
+	rules, err := ParseKeyNormalizationRules(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules).To(Equal(DefaultKeyNormalizationRules()))
+
+	rules, err = ParseKeyNormalizationRules([]string{"slashToDash", "lowercase"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(rules).To(Equal([]KeyNormalizationRule{KeyNormalizationSlashToDash, KeyNormalizationLowercase}))
+
+	_, err = ParseKeyNormalizationRules([]string{"uppercase"})
+	g.Expect(err).To(MatchError(ContainSubstring(`invalid key normalization rule: "uppercase"`)))
+}
+
+func TestCELMutator_Mutate_ResourceKeyNormalization(t *testing.T) {
+	tests := []struct {
+		name                string
+		expressions         []string
+		rules               []KeyNormalizationRule
+		initialLabels       map[string]string
+		initialAnnotations  map[string]string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectErr           bool
+		errMsg              string
+	}{
+		{
+			name: "folds equivalent resource mutations",
+			expressions: []string{
+				`resource("linux-amd64", 1)`,
+				`resource("LINUX-AMD64", 2)`,
+				`resource("linux--amd64", 3)`,
+				`resource("linux-arm64", 1)`,
+			},
+			rules: DefaultKeyNormalizationRules(),
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "6",
+				ResourceAnnotationPrefix + "linux-arm64": "1",
+			},
+		},
+		{
+			name: "folds into existing annotations with equivalent keys",
+			expressions: []string{
+				`resource("linux-amd64", 1)`,
+			},
+			rules: DefaultKeyNormalizationRules(),
+			initialAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "LINUX-AMD64":  "2",
+				ResourceAnnotationPrefix + "Linux--AMD64": "3",
+			},
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "6",
+			},
+		},
+		{
+			name: "rewrites existing annotations to the canonical form",
+			expressions: []string{
+				`resource("linux-arm64", 1)`,
+			},
+			rules: DefaultKeyNormalizationRules(),
+			initialAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "LINUX-AMD64": "2",
+			},
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "2",
+				ResourceAnnotationPrefix + "linux-arm64": "1",
+			},
+		},
+		{
+			name: "only the configured rules are applied",
+			expressions: []string{
+				`resource("linux-amd64", 1)`,
+				`resource("LINUX-AMD64", 2)`,
+				`resource("linux--amd64", 3)`,
+			},
+			rules: []KeyNormalizationRule{KeyNormalizationLowercase},
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64":  "3",
+				ResourceAnnotationPrefix + "linux--amd64": "3",
+			},
+		},
+		{
+			name: "non-resource mutations are untouched",
+			expressions: []string{
+				`annotation("kueue.konflux-ci.dev/requests-LINUX-AMD64", "5")`,
+				`label("Example--Label", "Value")`,
+				`annotation("example.com/Key", "Value")`,
+				`resource("linux-arm64", 1)`,
+			},
+			rules: DefaultKeyNormalizationRules(),
+			expectedLabels: map[string]string{
+				"Example--Label": "Value",
+			},
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "LINUX-AMD64": "5",
+				"example.com/Key":                        "Value",
+				ResourceAnnotationPrefix + "linux-arm64": "1",
+			},
+		},
+		{
+			name: "disabled by default",
+			expressions: []string{
+				`resource("linux-amd64", 1)`,
+				`resource("LINUX-AMD64", 2)`,
+			},
+			initialAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "Linux-AMD64": "3",
+			},
+			expectedAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "1",
+				ResourceAnnotationPrefix + "LINUX-AMD64": "2",
+				ResourceAnnotationPrefix + "Linux-AMD64": "3",
+			},
+		},
+		{
+			name: "non-integer existing value",
+			expressions: []string{
+				`resource("linux-amd64", 1)`,
+			},
+			rules: DefaultKeyNormalizationRules(),
+			initialAnnotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "1",
+				ResourceAnnotationPrefix + "LINUX-AMD64": "many",
+			},
+			expectErr: true,
+			errMsg:    `failed to parse resource value "many" as integer`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			var opts []MutatorOption
+			if tt.rules != nil {
+				opts = append(opts, WithResourceKeyNormalization(tt.rules))
+			}
+			mutator := NewCELMutator(programs, opts...)
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pipeline",
+					Namespace:   "test-namespace",
+					Labels:      tt.initialLabels,
+					Annotations: tt.initialAnnotations,
+				},
+			}
+
+			err = mutator.Mutate(context.Background(), pipelineRun)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(tt.errMsg))
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pipelineRun.Labels).To(Equal(tt.expectedLabels))
+			// The bookkeeping annotation is covered by TestCELMutator_Mutate_Idempotent
+			delete(pipelineRun.Annotations, AnnotationAppliedResources)
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/pkg/resources"
+)
+
+func TestCELMutator_OnError(t *testing.T) {
+	defaultPriority := []MutationRequest{{Type: MutationTypeLabel, Key: "kueue.x-k8s.io/priority-class", Value: "konflux-default"}}
+	failing := `label("platform", pipelineRun.metadata.labels["platform"])`
+
+	tests := []struct {
+		name           string
+		expressions    []NamedExpression
+		labels         map[string]string
+		expectedLabels map[string]string
+		expectedErr    string
+	}{
+		{
+			name: "fallback applied",
+			expressions: []NamedExpression{
+				{Name: "platform", Expression: failing, OnError: defaultPriority},
+			},
+			expectedLabels: map[string]string{"kueue.x-k8s.io/priority-class": "konflux-default"},
+		},
+		{
+			name: "fallback not applied when the expression succeeds",
+			expressions: []NamedExpression{
+				{Name: "platform", Expression: failing, OnError: defaultPriority},
+			},
+			labels:         map[string]string{"platform": "arm64"},
+			expectedLabels: map[string]string{"platform": "arm64"},
+		},
+		{
+			name: "failure ignored with empty onError mutations",
+			expressions: []NamedExpression{
+				{Expression: `label("team", "a")`},
+				{Name: "platform", Expression: failing, OnError: []MutationRequest{}},
+			},
+			expectedLabels: map[string]string{"team": "a"},
+		},
+		{
+			name: "mixed success and fallback",
+			expressions: []NamedExpression{
+				{Expression: `label("team", "a")`},
+				{Name: "platform", Expression: failing, OnError: defaultPriority},
+				{Expression: `annotation("owner", "team-a")`},
+			},
+			expectedLabels: map[string]string{"team": "a", "kueue.x-k8s.io/priority-class": "konflux-default"},
+		},
+		{
+			name: "fail fast without onError mutations",
+			expressions: []NamedExpression{
+				{Name: "platform", Expression: failing, OnError: defaultPriority},
+				{Name: "owner", Expression: `annotation("owner", pipelineRun.metadata.annotations["owner"])`},
+			},
+			expectedErr: "expression 'owner' failed to evaluate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileNamedCELPrograms(tt.expressions, WithGroup("on-error-test"))
+			g.Expect(err).NotTo(HaveOccurred())
+			pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns", Labels: tt.labels}}
+			failures := testutil.ToFloat64(celEvaluationsTotal.WithLabelValues("failure", "on-error-test"))
+
+			err = NewCELMutator(programs).Mutate(context.Background(), pipelineRun)
+
+			if tt.expectedErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedErr)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pipelineRun.Labels).To(Equal(tt.expectedLabels))
+			if tt.labels == nil {
+				// The failures replaced by the onError mutations are
+				// still counted
+				g.Expect(testutil.ToFloat64(celEvaluationsTotal.WithLabelValues("failure", "on-error-test")) - failures).
+					To(Equal(1.0))
+			}
+		})
+	}
+}
+
+func TestCELMutator_OnError_Resource(t *testing.T) {
+	g := NewWithT(t)
+	programs, err := CompileNamedCELPrograms([]NamedExpression{
+		{Expression: `resource("cpu", 1)`},
+		{
+			Name:       "platform",
+			Expression: `resource("cpu", int(pipelineRun.metadata.annotations["cpu"]))`,
+			OnError:    []MutationRequest{{Type: MutationTypeResource, Key: "cpu", Value: "2"}},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+
+	g.Expect(NewCELMutator(programs).Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+	// The fallback is written to the resource annotation, and summed with
+	// the resource() mutations
+	g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(resources.AnnotationKeyFor("cpu"), "3"))
+	g.Expect(pipelineRun.Annotations).NotTo(HaveKey("cpu"))
+}
+
+func TestCompileNamedCELPrograms_InvalidOnError(t *testing.T) {
+	tests := []struct {
+		name     string
+		onError  []MutationRequest
+		opts     []CompileOption
+		errorMsg string
+	}{
+		{
+			name:     "invalid key",
+			onError:  []MutationRequest{{Type: MutationTypeLabel, Key: "invalid key!", Value: "a"}},
+			errorMsg: "invalid onError mutation at index 0",
+		},
+		{
+			name:     "empty value",
+			onError:  []MutationRequest{{Type: MutationTypeAnnotation, Key: "owner"}},
+			errorMsg: "mutation value cannot be empty",
+		},
+		{
+			name:     "invalid type",
+			onError:  []MutationRequest{{Type: "priority", Key: "priority", Value: "high"}},
+			errorMsg: "invalid onError mutation at index 0",
+		},
+		{
+			name:     "invalid resource value",
+			onError:  []MutationRequest{{Type: MutationTypeResource, Key: "cpu", Value: "abc"}},
+			errorMsg: `"abc" isn't an integer`,
+		},
+		{
+			name:     "negative resource value",
+			onError:  []MutationRequest{{Type: MutationTypeResource, Key: "cpu", Value: "-1"}},
+			errorMsg: "value must be positive",
+		},
+		{
+			name:     "invalid resource key",
+			onError:  []MutationRequest{{Type: MutationTypeResource, Key: "invalid key!", Value: "1"}},
+			errorMsg: "invalid onError mutation at index 0",
+		},
+		{
+			name:     "protected key",
+			onError:  []MutationRequest{{Type: MutationTypeAnnotation, Key: "kubernetes.io/owner", Value: "a"}},
+			opts:     []CompileOption{WithProtectedKeys(ProtectedKeys{Denied: []string{"kubernetes.io/*"}})},
+			errorMsg: "invalid onError mutation at index 0",
+		},
+		{
+			name:     "denied by the policy",
+			onError:  []MutationRequest{{Type: MutationTypeLabel, Key: "team", Value: "a"}},
+			opts:     []CompileOption{WithMutationPolicy(MutationPolicy{Types: []MutationType{MutationTypeAnnotation}})},
+			errorMsg: "onError mutation at index 0 denied by the policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := CompileNamedCELPrograms([]NamedExpression{
+				{Name: "owner", Expression: `annotation("owner", "team-a")`, OnError: tt.onError},
+			}, tt.opts...)
+			g.Expect(err).To(MatchError(ContainSubstring("failed to compile expression 'owner'")))
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package cel
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+// orderedAdapter converts the values of the variables to CEL values like
+// types.DefaultTypeAdapter, except that the maps, including the nested
+// ones, are iterated in the order of their keys. Go randomizes the order
+// of the iteration of maps, so the comprehensions over maps, e.g.
+// pipelineRun.metadata.labels.map(k, ...), would otherwise return their
+// results in a different order at each evaluation.
+type orderedAdapter struct{}
+
+// NativeToValue implements types.Adapter.
+func (a orderedAdapter) NativeToValue(value any) ref.Val {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return orderedMap{types.NewStringInterfaceMap(a, v)}
+	case map[string]string:
+		return orderedMap{types.NewStringStringMap(a, v)}
+	case map[string]int64:
+		return orderedMap{types.NewDynamicMap(a, v)}
+	case []interface{}:
+		// The elements are converted by the adapter of the list
+		return types.NewDynamicList(a, v)
+	default:
+		return types.DefaultTypeAdapter.NativeToValue(value)
+	}
+}
+
+// orderedMap is a map iterated in the order of its keys.
+type orderedMap struct {
+	traits.Mapper
+}
+
+// Iterator returns an iterator over the keys of the map, sorted.
+func (m orderedMap) Iterator() traits.Iterator {
+	var keys []ref.Val
+	for it := m.Mapper.Iterator(); it.HasNext() == types.True; {
+		keys = append(keys, it.Next())
+	}
+	slices.SortFunc(keys, compareKeys)
+	return types.NewRefValList(types.DefaultTypeAdapter, keys).Iterator()
+}
+
+// compareKeys orders the keys of a map. The keys of the variables are
+// strings, other keys are ordered by type name first.
+func compareKeys(a, b ref.Val) int {
+	if comparer, ok := a.(traits.Comparer); ok && a.Type() == b.Type() {
+		if order, ok := comparer.Compare(b).(types.Int); ok {
+			return int(order)
+		}
+	}
+	return strings.Compare(a.Type().TypeName(), b.Type().TypeName())
+}
+
+// orderedVars returns the variables with their values converted by
+// orderedAdapter.
+func orderedVars(vars map[string]interface{}) map[string]interface{} {
+	converted := make(map[string]interface{}, len(vars))
+	for name, value := range vars {
+		converted[name] = orderedAdapter{}.NativeToValue(value)
+	}
+	return converted
+}
@@ -0,0 +1,98 @@
+package cel
+
+import (
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// OverwritePolicy defines whether the label and annotation mutations
+// replace the values already set on the PipelineRuns.
+type OverwritePolicy string
+
+const (
+	// OverwriteAlways replaces the values, the default.
+	OverwriteAlways OverwritePolicy = "always"
+	// OverwriteNeverUserSet keeps the values of the keys set on the
+	// PipelineRuns before they're mutated, while the keys set by an
+	// expression can still be replaced by the next ones.
+	OverwriteNeverUserSet OverwritePolicy = "neverUserSet"
+	// OverwriteNever never replaces a value, the first expression setting
+	// a key wins.
+	OverwriteNever OverwritePolicy = "never"
+)
+
+// WithOverwritePolicy sets whether the label and annotation mutations
+// replace the values already set on the PipelineRuns. The mutations which
+// aren't applied because of the policy aren't returned nor logged as
+// applied.
+func WithOverwritePolicy(policy OverwritePolicy) MutatorOption {
+	return func(m *CELMutator) {
+		m.overwritePolicy = policy
+	}
+}
+
+// overwriteGuard tells whether the label and annotation mutations may
+// replace the values set on a PipelineRun, according to the policy and the
+// keys of the PipelineRun before it's mutated.
+type overwriteGuard struct {
+	policy      OverwritePolicy
+	labels      sets.Set[string]
+	annotations sets.Set[string]
+}
+
+// newOverwriteGuard returns the guard of the policy for the PipelineRun,
+// capturing its keys, nil when all the values are replaced.
+func newOverwriteGuard(policy OverwritePolicy, pipelineRun *tekv1.PipelineRun) *overwriteGuard {
+	switch policy {
+	case OverwriteNeverUserSet, OverwriteNever:
+		return &overwriteGuard{
+			policy:      policy,
+			labels:      sets.KeySet(pipelineRun.Labels),
+			annotations: sets.KeySet(pipelineRun.Annotations),
+		}
+	default:
+		return nil
+	}
+}
+
+// allows returns whether the mutation may be applied to the PipelineRun.
+// Only the label and annotation mutations of the keys already set are
+// denied.
+func (g *overwriteGuard) allows(pipelineRun *tekv1.PipelineRun, mutation *MutationRequest) bool {
+	if g == nil {
+		return true
+	}
+	var current map[string]string
+	var original sets.Set[string]
+	switch mutation.Type {
+	case MutationTypeLabel:
+		current, original = pipelineRun.Labels, g.labels
+	case MutationTypeAnnotation:
+		current, original = pipelineRun.Annotations, g.annotations
+	default:
+		return true
+	}
+	if _, set := current[mutation.Key]; !set {
+		return true
+	}
+	return g.policy == OverwriteNeverUserSet && !original.Has(mutation.Key)
+}
+
+// keepFirstValues drops the label and annotation mutations of the keys set
+// by a previous mutation, so the first expression setting a key wins with
+// OverwriteNever, orderMutations keeping the last one.
+func keepFirstValues(mutations []*MutationRequest) []*MutationRequest {
+	seen := make(map[mutationKey]bool)
+	kept := make([]*MutationRequest, 0, len(mutations))
+	for _, mutation := range mutations {
+		if mutation.Type == MutationTypeLabel || mutation.Type == MutationTypeAnnotation {
+			key := mutationKey{mutationType: mutation.Type, key: mutation.Key}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		kept = append(kept, mutation)
+	}
+	return kept
+}
@@ -0,0 +1,89 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_OverwritePolicy(t *testing.T) {
+	// The environment is set by the author of the PipelineRun, the tier by
+	// two expressions
+	expressions := []string{
+		`[label("environment", "staging"), annotation("environment", "staging")]`,
+		`[label("tier", "standard"), annotation("tier", "standard")]`,
+		`[label("tier", "premium"), annotation("tier", "premium")]`,
+		`[label("team", "a"), annotation("team", "a")]`,
+	}
+	tests := []struct {
+		name     string
+		policy   OverwritePolicy
+		expected map[string]string
+	}{
+		{
+			name:     "default",
+			expected: map[string]string{"environment": "staging", "tier": "premium", "team": "a"},
+		},
+		{
+			name:     "always",
+			policy:   OverwriteAlways,
+			expected: map[string]string{"environment": "staging", "tier": "premium", "team": "a"},
+		},
+		{
+			name:     "never user set",
+			policy:   OverwriteNeverUserSet,
+			expected: map[string]string{"environment": "production", "tier": "premium", "team": "a"},
+		},
+		{
+			name:     "never",
+			policy:   OverwriteNever,
+			expected: map[string]string{"environment": "production", "tier": "standard", "team": "a"},
+		},
+	}
+
+	programs, err := CompileCELPrograms(expressions)
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "build",
+					Namespace:   "tenant",
+					Labels:      map[string]string{"environment": "production"},
+					Annotations: map[string]string{"environment": "production"},
+				},
+			}
+			var opts []MutatorOption
+			if tt.policy != "" {
+				opts = append(opts, WithOverwritePolicy(tt.policy))
+			}
+			mutator := NewCELMutator(programs, opts...)
+
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Labels).To(Equal(tt.expected))
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_OverwritePolicy_DryRun(t *testing.T) {
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{`[label("environment", "staging"), label("team", "a")]`})
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "tenant",
+			Labels:    map[string]string{"environment": "production"},
+		},
+	}
+
+	// The mutations skipped because of the policy aren't returned
+	mutations, err := NewCELMutator(programs, WithOverwritePolicy(OverwriteNeverUserSet)).DryRun(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(&MutationRequest{Type: MutationTypeLabel, Key: "team", Value: "a"}))
+}
@@ -0,0 +1,72 @@
+package cel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// ErrConcurrentMutation is returned by Mutate, with WithOwnershipGuard,
+// for a PipelineRun, or its labels or annotations, already being mutated.
+var ErrConcurrentMutation = errors.New(
+	"the PipelineRun is already being mutated: Mutate requires exclusive ownership of the PipelineRun, " +
+		"objects shared with other goroutines, e.g. from an informer cache, must be copied first, see MutateCopy")
+
+// WithOwnershipGuard makes Mutate fail with ErrConcurrentMutation, instead
+// of corrupting memory, when the PipelineRun, or its label or annotation
+// maps, are mutated concurrently by the mutator, e.g. because an object of
+// an informer cache is shared by several goroutines. The guard isn't free,
+// it's meant for debugging the embedders of the mutator.
+func WithOwnershipGuard() MutatorOption {
+	return func(m *CELMutator) {
+		m.ownership = &ownershipGuard{}
+	}
+}
+
+// ownershipGuard tracks the objects being mutated.
+type ownershipGuard struct {
+	inFlight sync.Map
+}
+
+// acquire marks the PipelineRun and its maps as being mutated, and returns
+// the function releasing them.
+func (g *ownershipGuard) acquire(pipelineRun *tekv1.PipelineRun) (func(), error) {
+	// Shallow copies of a PipelineRun share its maps
+	keys := []any{pipelineRun}
+	for _, m := range []map[string]string{pipelineRun.Labels, pipelineRun.Annotations} {
+		if m != nil {
+			keys = append(keys, reflect.ValueOf(m).UnsafePointer())
+		}
+	}
+
+	release := func(acquired []any) {
+		for _, key := range acquired {
+			g.inFlight.Delete(key)
+		}
+	}
+	for i, key := range keys {
+		if _, busy := g.inFlight.LoadOrStore(key, struct{}{}); busy {
+			release(keys[:i])
+			return nil, fmt.Errorf("mutating PipelineRun %s/%s: %w", pipelineRun.Namespace, pipelineRun.Name, ErrConcurrentMutation)
+		}
+	}
+	return func() { release(keys) }, nil
+}
+
+// MutateCopy mutates a deep copy of the PipelineRun, as Mutate, and returns
+// it. The PipelineRun isn't modified, so it can be shared, e.g. with an
+// informer cache.
+func (m *CELMutator) MutateCopy(ctx context.Context, pipelineRun *tekv1.PipelineRun) (*tekv1.PipelineRun, error) {
+	if pipelineRun == nil {
+		return nil, fmt.Errorf("pipelineRun cannot be nil")
+	}
+	mutated := pipelineRun.DeepCopy()
+	if err := m.Mutate(ctx, mutated); err != nil {
+		return nil, err
+	}
+	return mutated, nil
+}
@@ -0,0 +1,72 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newOwnershipPipelineRun() *tekv1.PipelineRun {
+	return &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Labels:      map[string]string{"team": "a"},
+			Annotations: map[string]string{"kueue.konflux-ci.dev/requests-cpu": "1"},
+		},
+	}
+}
+
+func TestCELMutator_MutateCopy(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`[priority("high"), label("tenant", plrNamespace), resource("cpu", 2)]`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	input := newOwnershipPipelineRun()
+	mutated, err := mutator.MutateCopy(context.Background(), input)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(input).To(Equal(newOwnershipPipelineRun()))
+	expected := newOwnershipPipelineRun()
+	g.Expect(mutator.Mutate(context.Background(), expected)).To(Succeed())
+	g.Expect(mutated).To(Equal(expected))
+
+	_, err = mutator.MutateCopy(context.Background(), nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCELMutator_OwnershipGuard(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`priority("high")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithOwnershipGuard())
+
+	// The PipelineRun is being mutated by another goroutine
+	shared := newOwnershipPipelineRun()
+	release, err := mutator.ownership.acquire(shared)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(mutator.Mutate(context.Background(), shared)).To(MatchError(ErrConcurrentMutation))
+	g.Expect(shared).To(Equal(newOwnershipPipelineRun()))
+
+	// Shallow copies share the maps of the PipelineRun
+	shallow := *shared
+	g.Expect(mutator.Mutate(context.Background(), &shallow)).To(MatchError(ErrConcurrentMutation))
+
+	// Copies are independent
+	mutated, err := mutator.MutateCopy(context.Background(), shared)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutated.Labels).To(HaveKeyWithValue("kueue.x-k8s.io/priority-class", "high"))
+
+	release()
+	g.Expect(mutator.Mutate(context.Background(), shared)).To(Succeed())
+	g.Expect(mutator.Mutate(context.Background(), shared)).To(Succeed())
+}
@@ -0,0 +1,108 @@
+package cel
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/parser"
+)
+
+// createParamFunction creates a CEL function returning the value of a param
+// of the evaluated PipelineRun parsed by parse, or the default when the
+// param is missing, isn't a string or can't be parsed. The functions can't
+// read the variables, so name(param, default) is a macro expanding to
+// name(pipelineRun, param, default), binding the PipelineRun of each
+// evaluation.
+func createParamFunction(name string, valueType *cel.Type, parse func(string) (ref.Val, bool)) cel.EnvOption {
+	expand := func(eh parser.ExprHelper, _ celast.Expr, args []celast.Expr) (celast.Expr, *common.Error) {
+		return eh.NewCall(name, eh.NewIdent("pipelineRun"), args[0], args[1]), nil
+	}
+	function := cel.Function(
+		name,
+		cel.Overload(
+			name+"_map_string_"+valueType.String()+"_to_"+valueType.String(),
+			[]*cel.Type{cel.MapType(cel.StringType, cel.AnyType), cel.StringType, valueType},
+			valueType,
+			cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+				param, ok := args[1].Value().(string)
+				if !ok {
+					return types.NewErr("%s function requires a string param name", name)
+				}
+				if value, ok := paramValue(args[0], param); ok {
+					if parsed, ok := parse(strings.TrimSpace(value)); ok {
+						return parsed
+					}
+				}
+				return args[2]
+			}),
+		),
+	)
+	return func(env *cel.Env) (*cel.Env, error) {
+		env, err := cel.Macros(cel.GlobalMacro(name, 2, expand))(env)
+		if err != nil {
+			return nil, err
+		}
+		return function(env)
+	}
+}
+
+// paramValue returns the string value of the param of the PipelineRun map,
+// false when it's missing or isn't a string, e.g. an array param.
+func paramValue(pipelineRun ref.Val, name string) (string, bool) {
+	params, ok := findField(pipelineRun, "spec", "params")
+	if !ok {
+		return "", false
+	}
+	list, ok := params.(traits.Lister)
+	if !ok {
+		return "", false
+	}
+	for it := list.Iterator(); it.HasNext() == types.True; {
+		param := it.Next()
+		if paramName, ok := findField(param, "name"); !ok || paramName.Value() != name {
+			continue
+		}
+		value, ok := findField(param, "value")
+		if !ok {
+			return "", false
+		}
+		s, ok := value.Value().(string)
+		return s, ok
+	}
+	return "", false
+}
+
+// findField returns the value of the nested field of the map, false when a
+// field is missing or a value isn't a map.
+func findField(value ref.Val, path ...string) (ref.Val, bool) {
+	for _, field := range path {
+		mapper, ok := value.(traits.Mapper)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = mapper.Find(types.String(field)); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// parseIntParam parses the value of an integer param, e.g. "7". "7.5" isn't
+// an integer.
+func parseIntParam(value string) (ref.Val, bool) {
+	i, err := strconv.ParseInt(value, 10, 64)
+	return types.Int(i), err == nil
+}
+
+// parseBoolParam parses the value of a boolean param, e.g. "true" or "1",
+// see strconv.ParseBool.
+func parseBoolParam(value string) (ref.Val, bool) {
+	b, err := strconv.ParseBool(value)
+	return types.Bool(b), err == nil
+}
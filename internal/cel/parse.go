@@ -0,0 +1,168 @@
+package cel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"sigs.k8s.io/yaml"
+)
+
+// maxParseBytes is the size of the largest document parsed by parseJSON
+// and parseYAML, the maximum total size of the annotations of an object.
+const maxParseBytes = 256 * 1024
+
+// maxExcerptLength is the length of the excerpts of the malformed
+// documents quoted in the errors.
+const maxExcerptLength = 40
+
+// createParseFunction creates a CEL function parsing a document into a CEL
+// value: objects into maps, iterated in the order of their keys, arrays
+// into lists, and numbers into ints when they're integers, doubles
+// otherwise. toJSON converts the document to JSON first.
+func createParseFunction(name string, toJSON func([]byte) ([]byte, error)) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_to_dyn",
+			[]*cel.Type{cel.StringType},
+			cel.DynType,
+			cel.UnaryBinding(func(val ref.Val) ref.Val {
+				value, valueOk := val.Value().(string)
+				if !valueOk {
+					return types.NewErr("%s function requires string argument", name)
+				}
+				if len(value) > maxParseBytes {
+					return types.NewErr("%s input exceeds the maximum size of %d bytes, got %d bytes",
+						name, maxParseBytes, len(value))
+				}
+
+				document, err := toJSON([]byte(value))
+				if err != nil {
+					return types.NewErr("%s failed to parse %q: %v", name, excerpt(value, 0), err)
+				}
+				parsed, err := decodeJSON(document)
+				if err != nil {
+					offset := 0
+					if syntaxErr := (*json.SyntaxError)(nil); errors.As(err, &syntaxErr) && document != nil {
+						offset = int(syntaxErr.Offset)
+					}
+					return types.NewErr("%s failed to parse %q: %v", name, excerpt(string(document), offset), err)
+				}
+
+				return orderedAdapter{}.NativeToValue(parsed)
+			}),
+		),
+	)
+}
+
+// decodeJSON decodes a single JSON document, with the integers as int64.
+func decodeJSON(document []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty document")
+		}
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the document")
+	}
+	return convertNumbers(value), nil
+}
+
+// convertNumbers replaces the numbers of the decoded value with int64, or
+// float64 when they aren't integers or overflow.
+func convertNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, element := range v {
+			v[key] = convertNumbers(element)
+		}
+	case []any:
+		for i, element := range v {
+			v[i] = convertNumbers(element)
+		}
+	}
+	return value
+}
+
+// excerpt returns at most maxExcerptLength bytes of the document, starting
+// a little before the offset, with an ellipsis where it's truncated.
+func excerpt(document string, offset int) string {
+	start := max(0, min(offset, len(document))-maxExcerptLength/2)
+	end := min(len(document), start+maxExcerptLength)
+	// Don't cut the multibyte characters
+	for start > 0 && !utf8.RuneStart(document[start]) {
+		start--
+	}
+	for end < len(document) && !utf8.RuneStart(document[end]) {
+		end++
+	}
+	result := document[start:end]
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(document) {
+		result += "…"
+	}
+	return result
+}
+
+// createBase64DecodeFunction creates a CEL function decoding a standard
+// base64 string, e.g. an annotation holding an encoded JSON document, into
+// a string.
+func createBase64DecodeFunction(name string) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_string_to_string",
+			[]*cel.Type{cel.StringType},
+			cel.StringType,
+			cel.UnaryBinding(func(val ref.Val) ref.Val {
+				value, valueOk := val.Value().(string)
+				if !valueOk {
+					return types.NewErr("%s function requires string argument", name)
+				}
+				if base64.StdEncoding.DecodedLen(len(value)) > maxParseBytes {
+					return types.NewErr("%s input exceeds the maximum size of %d bytes once decoded",
+						name, maxParseBytes)
+				}
+
+				decoded, err := base64.StdEncoding.DecodeString(value)
+				if err != nil {
+					return types.NewErr("%s failed to decode %q: %v", name, excerpt(value, 0), err)
+				}
+				if !utf8.Valid(decoded) {
+					return types.NewErr("%s decoded value isn't valid UTF-8", name)
+				}
+
+				return types.String(decoded)
+			}),
+		),
+	)
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(document []byte) ([]byte, error) {
+	return yaml.YAMLToJSON(document)
+}
+
+// jsonDocument returns the JSON document unchanged.
+func jsonDocument(document []byte) ([]byte, error) {
+	return document, nil
+}
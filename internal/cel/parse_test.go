@@ -0,0 +1,179 @@
+package cel
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseFunctions(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	tests := []struct {
+		name       string
+		expression string
+		expected   interface{}
+		errorMsg   string
+	}{
+		{
+			name:       "object",
+			expression: `parseJSON('{"name": "matrix", "enabled": true}').name`,
+			expected:   "matrix",
+		},
+		{
+			name:       "array",
+			expression: `parseJSON('["linux/amd64", "linux/arm64"]')[1]`,
+			expected:   "linux/arm64",
+		},
+		{
+			name:       "nested access",
+			expression: `parseJSON('{"matrix": {"platforms": [{"arch": "arm64", "count": 3}]}}').matrix.platforms[0].count`,
+			expected:   int64(3),
+		},
+		{
+			name:       "integers are ints",
+			expression: `parseJSON('{"count": 3}').count + 1`,
+			expected:   int64(4),
+		},
+		{
+			name:       "other numbers are doubles",
+			expression: `parseJSON('{"ratio": 0.5}').ratio * 2.0`,
+			expected:   1.0,
+		},
+		{
+			name:       "null",
+			expression: `parseJSON('{"owner": null}').owner == null`,
+			expected:   true,
+		},
+		{
+			name:       "maps are iterated in the order of their keys",
+			expression: `parseJSON('{"c": 1, "a": 2, "b": 3}').map(k, k) == ["a", "b", "c"]`,
+			expected:   true,
+		},
+		{
+			name:       "yaml",
+			expression: `parseYAML("matrix:\n  platforms:\n  - arch: arm64\n    count: 2\n").matrix.platforms[0].count`,
+			expected:   int64(2),
+		},
+		{
+			name:       "base64 encoded json",
+			expression: `parseJSON(base64Decode("` + base64.StdEncoding.EncodeToString([]byte(`{"count": 2}`)) + `")).count`,
+			expected:   int64(2),
+		},
+		{
+			name:       "malformed json",
+			expression: `parseJSON('{"platforms": ["linux/amd64" "linux/arm64"], "padding": "xxxxxxxxxxxxxxxxxxxxxxxxxx"}')`,
+			errorMsg:   `parseJSON failed to parse "…s\": [\"linux/amd64\" \"linux/arm64\"], \"padd…": invalid character '"' after array element`,
+		},
+		{
+			name:       "trailing data",
+			expression: `parseJSON('{"count": 1} {"count": 2}')`,
+			errorMsg:   `parseJSON failed to parse "{\"count\": 1} {\"count\": 2}": unexpected data after the document`,
+		},
+		{
+			name:       "empty json",
+			expression: `parseJSON('')`,
+			errorMsg:   `parseJSON failed to parse "": empty document`,
+		},
+		{
+			name:       "missing field",
+			expression: `parseJSON('{"count": 1}').platforms`,
+			errorMsg:   `no such key: platforms`,
+		},
+		{
+			name:       "malformed yaml",
+			expression: `parseYAML("platforms: [linux/amd64")`,
+			errorMsg:   `parseYAML failed to parse "platforms: [linux/amd64"`,
+		},
+		{
+			name:       "invalid base64",
+			expression: `base64Decode("not base64!")`,
+			errorMsg:   `base64Decode failed to decode "not base64!"`,
+		},
+		{
+			name:       "base64 of binary data",
+			expression: `base64Decode("/w==")`,
+			errorMsg:   `base64Decode decoded value isn't valid UTF-8`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ast, issues := env.Compile(tt.expression)
+			g.Expect(issues.Err()).NotTo(HaveOccurred(), "Expression should compile successfully")
+
+			program, err := env.Program(ast)
+			g.Expect(err).NotTo(HaveOccurred(), "Program creation should succeed")
+
+			result, _, err := program.Eval(map[string]interface{}{})
+			if tt.errorMsg != "" {
+				g.Expect(err).To(HaveOccurred(), "Expected evaluation to fail")
+				g.Expect(err.Error()).To(ContainSubstring(tt.errorMsg))
+				return
+			}
+
+			g.Expect(err).NotTo(HaveOccurred(), "Evaluation should succeed")
+			g.Expect(result.Value()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestParseFunctions_WithResource(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`parseJSON(pipelineRun.metadata.annotations["acme.io/test-matrix"]).platforms.map(p,
+			resource("linux-" + p.arch, p.count))`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	mutations, err := programs[0].Evaluate(&tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"acme.io/test-matrix": `{"platforms": [{"arch": "amd64", "count": 2}, {"arch": "arm64", "count": 1}]}`,
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(
+		&MutationRequest{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "linux-amd64", Value: "2"},
+		&MutationRequest{Type: MutationTypeResource, Key: ResourceAnnotationPrefix + "linux-arm64", Value: "1"},
+	))
+}
+
+func TestParseFunctions_SizeCap(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`annotation("json", string(parseJSON(pipelineRun.metadata.annotations["document"]).size()))`,
+		`annotation("yaml", string(parseYAML(pipelineRun.metadata.annotations["document"]).size()))`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"document": `{"padding": "` + strings.Repeat("x", maxParseBytes-16) + `"}`,
+			},
+		},
+	}
+	for _, program := range programs {
+		_, err := program.Evaluate(pipelineRun)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	pipelineRun.Annotations["document"] = `{"padding": "` + strings.Repeat("x", maxParseBytes) + `"}`
+	_, err = programs[0].Evaluate(pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("parseJSON input exceeds the maximum size of 262144 bytes")))
+	_, err = programs[1].Evaluate(pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("parseYAML input exceeds the maximum size of 262144 bytes")))
+}
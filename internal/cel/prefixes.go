@@ -0,0 +1,66 @@
+package cel
+
+import (
+	"slices"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// resourcePrefixes returns the prefixes of the resource annotations handled
+// by the mutator: ResourceAnnotationPrefix, or the prefixes of the migration
+// configured with WithResourceAnnotationPrefixes.
+func (m *CELMutator) resourcePrefixes() []string {
+	if len(m.resourceWritePrefixes) == 0 {
+		return []string{ResourceAnnotationPrefix}
+	}
+	prefixes := append([]string{}, m.resourceReadPrefixes...)
+	for _, prefix := range m.resourceWritePrefixes {
+		if !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// syncResourceAnnotations sets the resource annotations of each write
+// prefix to the value of the read prefix with the highest precedence, the
+// read prefixes being ordered by decreasing precedence. The annotations of
+// the write prefixes then agree before the resource mutations are summed
+// into them, and values only set with an old prefix are carried over to the
+// new one.
+func syncResourceAnnotations(pipelineRun *tekv1.PipelineRun, read, write []string) {
+	values := map[string]string{}
+	for i := len(read) - 1; i >= 0; i-- {
+		for key, value := range pipelineRun.Annotations {
+			if suffix, ok := strings.CutPrefix(key, read[i]); ok {
+				values[suffix] = value
+			}
+		}
+	}
+	for suffix, value := range values {
+		for _, prefix := range write {
+			pipelineRun.Annotations[prefix+suffix] = value
+		}
+	}
+}
+
+// expandResourceMutations replaces ResourceAnnotationPrefix, in the keys of
+// the resource mutations, by each of the prefixes. The resource() function
+// returns a single mutation, so it composes with the other functions, and
+// the mutator writes it to every prefix. Other mutations are returned
+// untouched and the order of the mutations is preserved.
+func expandResourceMutations(mutations []*MutationRequest, prefixes []string) []*MutationRequest {
+	expanded := make([]*MutationRequest, 0, len(mutations))
+	for _, mutation := range mutations {
+		suffix, ok := strings.CutPrefix(mutation.Key, ResourceAnnotationPrefix)
+		if mutation.Type != MutationTypeResource || !ok {
+			expanded = append(expanded, mutation)
+			continue
+		}
+		for _, prefix := range prefixes {
+			expanded = append(expanded, &MutationRequest{Type: mutation.Type, Key: prefix + suffix, Value: mutation.Value})
+		}
+	}
+	return expanded
+}
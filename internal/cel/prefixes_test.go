@@ -0,0 +1,219 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_Mutate_ResourceAnnotationPrefixMigration(t *testing.T) {
+	const (
+		oldPrefix = ResourceAnnotationPrefix
+		newPrefix = "queue.konflux.dev/requests-"
+	)
+	type mode struct {
+		write []string
+		read  []string
+	}
+	var (
+		dualWrite = mode{write: []string{newPrefix, oldPrefix}, read: []string{newPrefix, oldPrefix}}
+		readBoth  = mode{write: []string{newPrefix}, read: []string{newPrefix, oldPrefix}}
+		newOnly   = mode{write: []string{newPrefix}, read: []string{newPrefix}}
+	)
+
+	tests := []struct {
+		name                string
+		mode                mode
+		expressions         []string
+		initialAnnotations  map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:        "dual-write writes both prefixes",
+			mode:        dualWrite,
+			expressions: []string{`resource("cpu", 2)`},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "2",
+				newPrefix + "cpu": "2",
+			},
+		},
+		{
+			name:               "dual-write carries the old prefix over",
+			mode:               dualWrite,
+			expressions:        []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{oldPrefix + "cpu": "3"},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "5",
+				newPrefix + "cpu": "5",
+			},
+		},
+		{
+			name:               "dual-write carries the new prefix back",
+			mode:               dualWrite,
+			expressions:        []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{newPrefix + "cpu": "4"},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "6",
+				newPrefix + "cpu": "6",
+			},
+		},
+		{
+			name:        "dual-write resolves conflicts with the new prefix",
+			mode:        dualWrite,
+			expressions: []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "5",
+			},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "7",
+				newPrefix + "cpu": "7",
+			},
+		},
+		{
+			name:        "dual-write with mixed prefixes",
+			mode:        dualWrite,
+			expressions: []string{`resource("memory", 1)`},
+			initialAnnotations: map[string]string{
+				oldPrefix + "cpu":    "3",
+				newPrefix + "memory": "1",
+			},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu":    "3",
+				newPrefix + "cpu":    "3",
+				oldPrefix + "memory": "2",
+				newPrefix + "memory": "2",
+			},
+		},
+		{
+			name:               "read-both only writes the new prefix",
+			mode:               readBoth,
+			expressions:        []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{oldPrefix + "cpu": "3"},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "5",
+			},
+		},
+		{
+			name:        "read-both resolves conflicts with the new prefix",
+			mode:        readBoth,
+			expressions: []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "5",
+			},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "7",
+			},
+		},
+		{
+			name:        "read-both with mixed prefixes",
+			mode:        readBoth,
+			expressions: []string{`resource("memory", 1)`},
+			initialAnnotations: map[string]string{
+				oldPrefix + "cpu":    "3",
+				newPrefix + "memory": "1",
+			},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu":    "3",
+				newPrefix + "cpu":    "3",
+				newPrefix + "memory": "2",
+			},
+		},
+		{
+			name:               "new-only ignores the old prefix",
+			mode:               newOnly,
+			expressions:        []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{oldPrefix + "cpu": "3"},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "2",
+			},
+		},
+		{
+			name:        "new-only with conflicting values",
+			mode:        newOnly,
+			expressions: []string{`resource("cpu", 2)`},
+			initialAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "5",
+			},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "cpu": "3",
+				newPrefix + "cpu": "7",
+			},
+		},
+		{
+			name:        "dual-write keeps the resource annotations set by annotation() consistent",
+			mode:        dualWrite,
+			expressions: []string{`[annotation("kueue.konflux-ci.dev/requests-gpu", "1"), annotation("owner", "team-a")]`},
+			expectedAnnotations: map[string]string{
+				oldPrefix + "gpu": "1",
+				newPrefix + "gpu": "1",
+				"owner":           "team-a",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms(tt.expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+			mutator := NewCELMutator(programs, WithResourceAnnotationPrefixes(tt.mode.write, tt.mode.read))
+
+			pipelineRun := &tekv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-pipeline",
+					Namespace:   "test-namespace",
+					Annotations: tt.initialAnnotations,
+				},
+			}
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			mutated := pipelineRun.DeepCopy()
+			delete(mutated.Annotations, AnnotationAppliedResources)
+			g.Expect(mutated.Annotations).To(Equal(tt.expectedAnnotations))
+
+			// Mutating again doesn't sum the resources twice
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			delete(pipelineRun.Annotations, AnnotationAppliedResources)
+			g.Expect(pipelineRun.Annotations).To(Equal(tt.expectedAnnotations))
+		})
+	}
+}
+
+func TestCELMutator_Mutate_ResourceAnnotationPrefixMigration_Normalization(t *testing.T) {
+	g := NewWithT(t)
+	const newPrefix = "queue.konflux.dev/requests-"
+
+	programs, err := CompileCELPrograms([]string{`resource("linux/amd64", 1)`})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs,
+		WithResourceKeyNormalization(DefaultKeyNormalizationRules()),
+		WithResourceAnnotationPrefixes([]string{newPrefix, ResourceAnnotationPrefix}, []string{newPrefix, ResourceAnnotationPrefix}),
+	)
+
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-pipeline",
+			Namespace: "test-namespace",
+			Annotations: map[string]string{
+				ResourceAnnotationPrefix + "LINUX-AMD64": "2",
+				newPrefix + "Linux/AMD64":                "4",
+			},
+		},
+	}
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+	delete(pipelineRun.Annotations, AnnotationAppliedResources)
+	g.Expect(pipelineRun.Annotations).To(Equal(map[string]string{
+		ResourceAnnotationPrefix + "linux-amd64": "5",
+		newPrefix + "linux-amd64":                "5",
+	}))
+}
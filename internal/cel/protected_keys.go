@@ -0,0 +1,103 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	celast "github.com/google/cel-go/common/ast"
+)
+
+// protectedKeyFunctions are the mutation functions setting the label or
+// annotation named by their first argument, by the type of the mutations.
+var protectedKeyFunctions = map[string]MutationType{
+	"label":            MutationTypeLabel,
+	"annotation":       MutationTypeAnnotation,
+	"appendAnnotation": MutationTypeAppendAnnotation,
+}
+
+// ProtectedKeys are the label and annotation keys the expressions can't
+// set. The patterns are either exact keys, e.g.
+// pod-security.kubernetes.io/enforce, or prefixes ending with *, e.g.
+// kubernetes.io/*.
+type ProtectedKeys struct {
+	// Denied are the patterns of the protected keys.
+	Denied []string
+	// Allowed are the patterns of the keys exempted from Denied.
+	Allowed []string
+}
+
+// DefaultProtectedKeyPatterns returns the patterns of the keys protected
+// whatever the configuration: the keys of the kubernetes.io and k8s.io
+// domains, reserved for the Kubernetes components, and the Pod Security
+// Admission labels.
+func DefaultProtectedKeyPatterns() []string {
+	return []string{
+		"kubernetes.io/*",
+		"k8s.io/*",
+		"pod-security.kubernetes.io/*",
+		"node-role.kubernetes.io/*",
+		"node.kubernetes.io/*",
+	}
+}
+
+// WithProtectedKeys rejects the expressions setting a protected label or
+// annotation: the calls of label(), annotation() and appendAnnotation()
+// with a string literal key fail the compilation, and the mutations of the
+// keys computed at admission fail the evaluation.
+func WithProtectedKeys(keys ProtectedKeys) CompileOption {
+	return func(o *compileOptions) {
+		o.protectedKeys = &keys
+	}
+}
+
+// Protects returns whether the key matches a denied pattern and no
+// allowed one.
+func (p *ProtectedKeys) Protects(key string) bool {
+	return p != nil && matchesKeyPattern(p.Denied, key) && !matchesKeyPattern(p.Allowed, key)
+}
+
+// check returns an error when the mutation sets a protected label or
+// annotation.
+func (p *ProtectedKeys) check(mutationType MutationType, key string) error {
+	switch mutationType {
+	case MutationTypeLabel, MutationTypeAnnotation, MutationTypeAppendAnnotation:
+	default:
+		return nil
+	}
+	if !p.Protects(key) {
+		return nil
+	}
+	return fmt.Errorf("the %s %q is protected", mutationType, key)
+}
+
+// matchesKeyPattern returns whether the key matches one of the patterns.
+func matchesKeyPattern(patterns []string, key string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			return strings.HasPrefix(key, prefix)
+		}
+		return key == pattern
+	})
+}
+
+// protectedLiteralKey returns the first protected label or annotation set
+// by the calls of the program with a string literal key, and its type.
+func (cp *CompiledProgram) protectedLiteralKey(keys *ProtectedKeys) (MutationType, string, bool) {
+	var mutationType MutationType
+	var protected string
+	celast.PreOrderVisit(cp.ast.NativeRep().Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if protected != "" || expr.Kind() != celast.CallKind {
+			return
+		}
+		call := expr.AsCall()
+		callType, ok := protectedKeyFunctions[call.FunctionName()]
+		if !ok || len(call.Args()) == 0 {
+			return
+		}
+		if key, ok := stringLiteral(call.Args()[0]); ok && keys.Protects(key) {
+			mutationType, protected = callType, key
+		}
+	}))
+	return mutationType, protected, protected != ""
+}
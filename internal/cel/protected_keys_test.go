@@ -0,0 +1,121 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testProtectedKeys = ProtectedKeys{
+	Denied:  append(DefaultProtectedKeyPatterns(), "acme.com/owner"),
+	Allowed: []string{"kubernetes.io/metadata.name-hint"},
+}
+
+func TestProtectedKeys_Protects(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{key: "pod-security.kubernetes.io/enforce", expected: true},
+		{key: "kubernetes.io/arch", expected: true},
+		{key: "k8s.io/team", expected: true},
+		{key: "acme.com/owner", expected: true},
+		{key: "acme.com/owners"},
+		{key: "kubernetes.io/metadata.name-hint"},
+		{key: "app.kubernetes.io/name"},
+		{key: "kueue.x-k8s.io/priority-class"},
+		{key: "team"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(testProtectedKeys.Protects(tt.key)).To(Equal(tt.expected))
+		})
+	}
+
+	var none *ProtectedKeys
+	NewWithT(t).Expect(none.Protects("kubernetes.io/arch")).To(BeFalse())
+}
+
+func TestWithProtectedKeys_Compilation(t *testing.T) {
+	tests := []struct {
+		expression string
+		errorMsg   string
+	}{
+		{
+			expression: `label("pod-security.kubernetes.io/enforce", "privileged")`,
+			errorMsg:   `it sets the protected label "pod-security.kubernetes.io/enforce"`,
+		},
+		{
+			expression: `plrNamespace == "tenant" ? [annotation("acme.com/owner", "me")] : []`,
+			errorMsg:   `it sets the protected annotation "acme.com/owner"`,
+		},
+		{
+			expression: `appendAnnotation("k8s.io/checks", "sast", ",")`,
+			errorMsg:   `it sets the protected appendAnnotation "k8s.io/checks"`,
+		},
+		{expression: `label("kubernetes.io/metadata.name-hint", "build")`},
+		{expression: `[label("app.kubernetes.io/name", "build"), priority("high")]`},
+		{expression: `nodeSelector("kubernetes.io/arch", "arm64")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			g := NewWithT(t)
+
+			_, err := CompileCELPrograms([]string{tt.expression}, WithProtectedKeys(testProtectedKeys))
+			if tt.errorMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errorMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+
+	// The keys aren't protected by default
+	_, err := CompileCELPrograms([]string{`label("pod-security.kubernetes.io/enforce", "privileged")`})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+}
+
+func TestWithProtectedKeys_Evaluation(t *testing.T) {
+	g := NewWithT(t)
+
+	// The key is computed at admission, so it can't be rejected by the
+	// compilation
+	programs, err := CompileCELPrograms([]string{
+		`label(pipelineRun.metadata.annotations["domain"] + "/enforce", "privileged")`,
+	}, WithProtectedKeys(testProtectedKeys))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name:        "build",
+		Namespace:   "tenant",
+		Annotations: map[string]string{"domain": "pod-security.kubernetes.io"},
+	}}
+	_, err = programs[0].Evaluate(pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring(`the label "pod-security.kubernetes.io/enforce" is protected`)))
+
+	pipelineRun.Annotations["domain"] = "policies.acme.com"
+	mutations, err := programs[0].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(Equal([]*MutationRequest{
+		{Type: MutationTypeLabel, Key: "policies.acme.com/enforce", Value: "privileged"},
+	}))
+}
+
+func TestMutationRequest_ValidateProtected(t *testing.T) {
+	g := NewWithT(t)
+
+	mutation := &MutationRequest{Type: MutationTypeAnnotation, Key: "kubernetes.io/description", Value: "x"}
+	g.Expect(mutation.ValidateProtected(nil)).To(Succeed())
+	g.Expect(mutation.ValidateProtected(&testProtectedKeys)).To(MatchError(ContainSubstring("is protected")))
+
+	mutation = &MutationRequest{Type: MutationTypeNodeSelector, Key: "kubernetes.io/arch", Value: "arm64"}
+	g.Expect(mutation.ValidateProtected(&testProtectedKeys)).To(Succeed())
+
+	mutation = &MutationRequest{Type: MutationTypeLabel, Key: "kubernetes.io/arch"}
+	g.Expect(mutation.ValidateProtected(&testProtectedKeys)).To(MatchError(ContainSubstring("value cannot be empty")))
+}
@@ -0,0 +1,178 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// newPerQueueMutator returns a mutator with global programs followed by the
+// programs of the build and release queues.
+func newPerQueueMutator(g Gomega) *CELMutator {
+	global, err := CompileCELPrograms([]string{
+		`label("policy", "global")`,
+		`resource("cpu", 1)`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	build, err := CompileCELPrograms([]string{
+		`[label("policy", "build"), resource("cpu", 2)]`,
+	}, WithGroup(QueueGroup("build")), WithQueue("build"))
+	g.Expect(err).NotTo(HaveOccurred())
+	release, err := CompileCELPrograms([]string{
+		`[label("policy", "release"), priority("critical")]`,
+	}, WithGroup(QueueGroup("release")), WithQueue("release"))
+	g.Expect(err).NotTo(HaveOccurred())
+	return NewCELMutator(append(append(global, build...), release...))
+}
+
+func newQueuedPipelineRun(queue string) *tekv1.PipelineRun {
+	return &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "test-ns",
+			Labels:    map[string]string{common.QueueLabel: queue},
+		},
+	}
+}
+
+func TestCELMutator_Mutate_PerQueue(t *testing.T) {
+	tests := []struct {
+		name                string
+		queue               string
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:  "the expressions of the queue are evaluated after the global ones",
+			queue: "build",
+			expectedLabels: map[string]string{
+				common.QueueLabel: "build",
+				"policy":          "build",
+			},
+			expectedAnnotations: map[string]string{ResourceAnnotationPrefix + "cpu": "3"},
+		},
+		{
+			name:  "the expressions of the other queues are skipped",
+			queue: "release",
+			expectedLabels: map[string]string{
+				common.QueueLabel:               "release",
+				"policy":                        "release",
+				"kueue.x-k8s.io/priority-class": "critical",
+			},
+			expectedAnnotations: map[string]string{ResourceAnnotationPrefix + "cpu": "1"},
+		},
+		{
+			name:  "queue without expressions",
+			queue: "other",
+			expectedLabels: map[string]string{
+				common.QueueLabel: "other",
+				"policy":          "global",
+			},
+			expectedAnnotations: map[string]string{ResourceAnnotationPrefix + "cpu": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mutator := newPerQueueMutator(g)
+
+			pipelineRun := newQueuedPipelineRun(tt.queue)
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			g.Expect(pipelineRun.Labels).To(Equal(tt.expectedLabels))
+			for key, value := range tt.expectedAnnotations {
+				g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(key, value))
+			}
+
+			// The resource mutations of the queue are reverted like the
+			// global ones when the PipelineRun is mutated again
+			g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+			for key, value := range tt.expectedAnnotations {
+				g.Expect(pipelineRun.Annotations).To(HaveKeyWithValue(key, value))
+			}
+		})
+	}
+}
+
+func TestCELMutator_Mutate_PerQueueSelectedBeforeMutations(t *testing.T) {
+	g := NewWithT(t)
+
+	global, err := CompileCELPrograms([]string{`label("kueue.x-k8s.io/queue-name", "release")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	release, err := CompileCELPrograms([]string{`label("policy", "release")`},
+		WithGroup(QueueGroup("release")), WithQueue("release"))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(append(global, release...))
+
+	// The expressions are selected with the queue of the PipelineRun before
+	// any mutation is applied
+	pipelineRun := newQueuedPipelineRun("build")
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+	g.Expect(pipelineRun.Labels).To(Equal(map[string]string{common.QueueLabel: "release"}))
+}
+
+func TestCELMutator_ResultStability_PerQueue(t *testing.T) {
+	g := NewWithT(t)
+	mutator := newPerQueueMutator(g)
+	evaluations := func(group string) float64 {
+		return testutil.ToFloat64(celEvaluationsTotal.WithLabelValues("success", group))
+	}
+	buildBefore, releaseBefore := evaluations("queue:build"), evaluations("queue:release")
+
+	for _, queue := range []string{"build", "release", "build"} {
+		g.Expect(mutator.Mutate(context.Background(), newQueuedPipelineRun(queue))).To(Succeed())
+	}
+	g.Expect(mutator.ResultStability()).To(Equal([]ResultStability{
+		{Group: DefaultGroup, Index: 0, Expression: `label("policy", "global")`, Admissions: 3, StableFor: 3},
+		{Group: DefaultGroup, Index: 1, Expression: `resource("cpu", 1)`, Admissions: 3, StableFor: 3},
+		{Group: "queue:build", Index: 0, Expression: `[label("policy", "build"), resource("cpu", 2)]`,
+			Admissions: 2, StableFor: 2},
+		{Group: "queue:release", Index: 0, Expression: `[label("policy", "release"), priority("critical")]`,
+			Admissions: 1, StableFor: 1},
+	}))
+	// The evaluations of each set are counted with its group
+	g.Expect(evaluations("queue:build") - buildBefore).To(Equal(2.0))
+	g.Expect(evaluations("queue:release") - releaseBefore).To(Equal(1.0))
+}
+
+func TestCELMutator_Explain_PerQueue(t *testing.T) {
+	g := NewWithT(t)
+	mutator := newPerQueueMutator(g)
+
+	explanation := mutator.Explain(newQueuedPipelineRun("release"))
+	g.Expect(explanation.Error).To(BeEmpty())
+	g.Expect(explanation.Programs).To(HaveLen(4))
+	g.Expect(explanation.Programs[2].Queue).To(Equal("build"))
+	g.Expect(explanation.Programs[2].Mutations).To(BeEmpty())
+	g.Expect(explanation.Programs[3].Queue).To(Equal("release"))
+	g.Expect(explanation.Programs[3].Mutations).To(HaveLen(2))
+	g.Expect(explanation.Result.Labels).To(HaveKeyWithValue("policy", "release"))
+}
+
+func TestFindDuplicateKeys_PerQueue(t *testing.T) {
+	g := NewWithT(t)
+
+	global, err := CompileCELPrograms([]string{`annotation("owner", "platform")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	build, err := CompileCELPrograms([]string{`label("policy", "build")`, `annotation("owner", "build")`},
+		WithQueue("build"))
+	g.Expect(err).NotTo(HaveOccurred())
+	release, err := CompileCELPrograms([]string{`label("policy", "release")`}, WithQueue("release"))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The expressions of different queues never mutate the same
+	// PipelineRuns
+	g.Expect(FindDuplicateKeys(append(append(global, build...), release...))).To(Equal([]DuplicateKey{
+		{
+			Type:        MutationTypeAnnotation,
+			Key:         "owner",
+			Expressions: []string{`annotation("owner", "platform")`, `annotation("owner", "build")`},
+		},
+	}))
+}
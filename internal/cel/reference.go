@@ -0,0 +1,508 @@
+package cel
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// VariableReference documents a variable of the CEL environment.
+type VariableReference struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// Since is the release introducing the variable, empty for the
+	// variables predating the reference.
+	Since   string `json:"since,omitempty"`
+	Example string `json:"example"`
+}
+
+// FunctionReference documents a function of the CEL environment.
+type FunctionReference struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+	// Since is the release introducing the function, empty for the
+	// functions predating the reference.
+	Since   string `json:"since,omitempty"`
+	Example string `json:"example"`
+}
+
+// Reference documents the variables and functions the expressions can use,
+// on top of the CEL standard library.
+type Reference struct {
+	Variables []VariableReference `json:"variables"`
+	Functions []FunctionReference `json:"functions"`
+}
+
+// variableDeclaration is a variable of the environment, with its reference.
+// The value of each variable is set by buildVars.
+type variableDeclaration struct {
+	VariableReference
+	celType *cel.Type
+}
+
+// functionDeclaration is a function of the environment, with its reference.
+type functionDeclaration struct {
+	FunctionReference
+	option cel.EnvOption
+	// bindsPipelineRun tells that the function is called by a macro
+	// passing the pipelineRun variable as its first argument, which isn't
+	// part of the signature, see createParamFunction.
+	bindsPipelineRun bool
+}
+
+// variableDeclarations returns the variables of the environment.
+func variableDeclarations() []variableDeclaration {
+	return []variableDeclaration{
+		{
+			VariableReference: VariableReference{
+				Name: "pipelineRun",
+				Type: "map<string, any>",
+				Description: "The PipelineRun as a map. Its empty fields are left out, except for " +
+					"pipelineRun.status, pipelineRun.metadata.labels and pipelineRun.metadata.annotations, " +
+					"which are always present, as empty maps when unset, so their entries can be checked " +
+					"without has() guards. The status is empty when PipelineRuns are admitted, and removed " +
+					"when excludeStatus is set.",
+				Example: `"env" in pipelineRun.metadata.labels ? [label("env", pipelineRun.metadata.labels["env"])] : []`,
+			},
+			celType: cel.MapType(cel.StringType, cel.AnyType),
+		},
+		{
+			VariableReference: VariableReference{
+				Name:        "plrNamespace",
+				Type:        "string",
+				Description: "The namespace of the PipelineRun.",
+				Example:     `plrNamespace == "production" ? priority("high") : priority("default")`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "pacEventType",
+				Type: "string",
+				Description: "The value of the pipelinesascode.tekton.dev/event-type label, " +
+					"empty when the label is missing.",
+				Example: `pacEventType == "push" ? priority("push") : priority("default")`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "pacTestEventType",
+				Type: "string",
+				Description: "The value of the pac.test.appstudio.openshift.io/event-type label, " +
+					"empty when the label is missing.",
+				Example: `pacTestEventType == "push" ? [priority("post-merge-test")] : []`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "pacRetryCount",
+				Type: "int",
+				Description: "The number of times Pipelines as Code retried the PipelineRun, read from the " +
+					"pipelinesascode.tekton.dev/retry-count annotation, 0 when the annotation is missing or " +
+					"isn't a non-negative integer.",
+				Example: `pacRetryCount > 0 ? priority("retry") : priority("default")`,
+			},
+			celType: cel.IntType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "workspaceStorage",
+				Type: "map<string, int>",
+				Description: "The storage requested by the volumeClaimTemplate of each workspace of the " +
+					"PipelineRun, in bytes, rounded up, by workspace name. The workspaces bound otherwise, " +
+					"e.g. to an emptyDir, or without storage request, are mapped to 0.",
+				Example: `sum(workspaceStorage.map(w, workspaceStorage[w])) > quantityToBytes("10Gi") ? ` +
+					`[resource("large-storage", 1)] : []`,
+			},
+			celType: cel.MapType(cel.StringType, cel.IntType),
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "plrPipelineName",
+				Type: "string",
+				Description: "The logical name of the pipeline of the PipelineRun: the name of its " +
+					"pipelineRef, else the value of its tekton.dev/pipeline label, else its generateName " +
+					"without the trailing dash, e.g. build for build-. Empty when they're all missing.",
+				Example: `plrPipelineName == "release" ? [priority("high")] : []`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name:        "plrGenerateName",
+				Type:        "string",
+				Description: "The generateName of the PipelineRun, empty when it's missing.",
+				Example:     `plrGenerateName.startsWith("nightly-") ? [label("schedule", "nightly")] : []`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: NamespaceLabelsVariable,
+				Type: "map<string, string>",
+				Description: "The labels of the namespace of the PipelineRun, read from the cache of the " +
+					"webhook when cel.exposeNamespaceLabels is set, empty otherwise or while the " +
+					"namespace isn't cached.",
+				Example: `"tier" in namespaceLabels && namespaceLabels["tier"] == "gold" ? [priority("high")] : []`,
+			},
+			celType: cel.MapType(cel.StringType, cel.StringType),
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "nowEpochSeconds",
+				Type: "int",
+				Description: "The time of the evaluation, in seconds since the Unix epoch. Reading a time " +
+					"variable disables the evaluation cache.",
+				Example: `nowEpochSeconds >= 1767225600 ? [priority("low")] : []`,
+			},
+			celType: cel.IntType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "nowWeekday",
+				Type: "string",
+				Description: "The day of the week of the evaluation, e.g. Saturday, in the time zone of the " +
+					"configuration, UTC by default. Reading a time variable disables the evaluation cache.",
+				Example: `nowWeekday in ["Saturday", "Sunday"] ? [priority("low")] : []`,
+			},
+			celType: cel.StringType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "nowHour",
+				Type: "int",
+				Description: "The hour of the evaluation, from 0 to 23, in the time zone of the " +
+					"configuration, UTC by default. Reading a time variable disables the evaluation cache.",
+				Example: `nowHour < 8 || nowHour >= 18 ? [priority("low")] : []`,
+			},
+			celType: cel.IntType,
+		},
+		{
+			VariableReference: VariableReference{
+				Name: "nowHourUTC",
+				Type: "int",
+				Description: "The hour of the evaluation, from 0 to 23, in UTC whatever the time zone of " +
+					"the configuration. Reading a time variable disables the evaluation cache.",
+				Example: `nowHourUTC < 6 ? [label("window", "nightly")] : []`,
+			},
+			celType: cel.IntType,
+		},
+	}
+}
+
+// functionDeclarations returns the functions of the environment, whose
+// label and annotation keys are validated by keys.
+func functionDeclarations(keys keyValidator) []functionDeclaration {
+	// Define the MutationRequest type structure for return type validation
+	mutationRequestType := cel.MapType(cel.StringType, cel.AnyType)
+
+	return []functionDeclaration{
+		{
+			FunctionReference: FunctionReference{
+				Name:      "annotation",
+				Signature: "annotation(key: string, value: string | int | bool) -> MutationRequest",
+				Description: "Sets the annotation of the PipelineRun. An int or bool value is converted to its " +
+					"string form, e.g. 5 to \"5\" and true to \"true\".",
+				Example: `annotation("owner", "team-a")`,
+			},
+			option: createMutationFunction("annotation", MutationTypeAnnotation, mutationRequestType, keys),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "annotations",
+				Signature: "annotations(entries: map<string, string>) -> list<MutationRequest>",
+				Description: "Sets the annotations of the PipelineRun, one mutation per entry, sorted by key. " +
+					"The entries are validated like the ones of annotation(), the errors naming the offending key.",
+				Example: `annotations({"owner": "team-a", "cost-center": "1234"}) + [priority("high")]`,
+			},
+			option: createBulkMutationFunction("annotations", MutationTypeAnnotation, mutationRequestType, keys),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "appendAnnotation",
+				Signature: "appendAnnotation(key: string, value: string, separator: string) -> MutationRequest",
+				Description: "Appends the value to the annotation of the PipelineRun, separated from its other " +
+					"entries by the separator, unless it's already one of them. The value is set when the " +
+					"annotation is missing.",
+				Example: `appendAnnotation("kueue.konflux-ci.dev/platforms", "linux-amd64", ",")`,
+			},
+			option: createAppendAnnotationMutationFunction("appendAnnotation", mutationRequestType, keys),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "label",
+				Signature: "label(key: string, value: string | int | bool) -> MutationRequest",
+				Description: "Sets the label of the PipelineRun. An int or bool value is converted to its " +
+					"string form, e.g. 5 to \"5\" and true to \"true\".",
+				Example: `label("env", "production")`,
+			},
+			option: createMutationFunction("label", MutationTypeLabel, mutationRequestType, keys),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "labels",
+				Signature: "labels(entries: map<string, string>) -> list<MutationRequest>",
+				Description: "Sets the labels of the PipelineRun, one mutation per entry, sorted by key. " +
+					"The entries are validated like the ones of label(), the errors naming the offending key.",
+				Example: `labels({"app": "tekton-pipeline", "env": plrNamespace})`,
+			},
+			option: createBulkMutationFunction("labels", MutationTypeLabel, mutationRequestType, keys),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "resource",
+				Signature: "resource(key: string, value: int) -> MutationRequest",
+				Description: "Adds the value to the request of the resource, set by the " +
+					ResourceAnnotationPrefix + "<key> annotation. The values of the same resource are summed.",
+				Example: `resource("linux-amd64", 1)`,
+			},
+			option: createResourceMutationFunction("resource", MutationTypeResource, mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "priority",
+				Signature: "priority(value: string) -> MutationRequest",
+				Description: "Sets the kueue.x-k8s.io/priority-class label of the PipelineRun. " +
+					"Passing user-controlled values is reported, see GetTaintWarnings.",
+				Example: `priority("high")`,
+			},
+			option: createPriorityMutationFunction("priority", mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "timeout",
+				Signature: "timeout(kind: string, duration: string) -> MutationRequest",
+				Description: "Sets the pipeline, tasks or finally timeout of the PipelineRun to the Go duration. " +
+					"The timeouts already set are only replaced by longer ones, unless timeoutOverride is set.",
+				Example: `timeout("pipeline", "2h")`,
+			},
+			option: createTimeoutMutationFunction("timeout", mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "serviceAccount",
+				Signature: "serviceAccount(name: string) -> MutationRequest",
+				Description: "Sets the service account of the TaskRuns of the PipelineRun, " +
+					"spec.taskRunTemplate.serviceAccountName. The service account already set is kept, unless " +
+					"overrideServiceAccount is set. Passing user-controlled values is reported, see GetTaintWarnings.",
+				Example: `serviceAccount("release-pipeline")`,
+			},
+			option: createServiceAccountMutationFunction("serviceAccount", mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "nodeSelector",
+				Signature: "nodeSelector(key: string, value: string) -> MutationRequest",
+				Description: "Sets the entry of the node selector of the pods of the PipelineRun, " +
+					"spec.taskRunTemplate.podTemplate.nodeSelector. The key and the value follow the rules of " +
+					"the labels. Passing user-controlled values is reported, see GetTaintWarnings.",
+				Example: `nodeSelector("kubernetes.io/arch", "arm64")`,
+			},
+			option: createNodeSelectorMutationFunction("nodeSelector", mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "podset",
+				Signature: "podset(name: string, count: int, requests: map<string, string>) -> MutationRequest",
+				Description: "Adds a pod set of count pods with the requests to the Workload of the PipelineRun, " +
+					"through the " + common.PodSetsAnnotation + " annotation, so Kueue can assign it its own " +
+					"resource flavor. A pod set of the same name is replaced. The name can't be " +
+					common.DefaultPodSetName + ", the pod set of the resource annotations.",
+				Example: `podset("arm-builders", 3, {"kueue.konflux-ci.dev/linux-arm64": "1"})`,
+			},
+			option: createPodSetMutationFunction("podset", mutationRequestType),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "replace",
+				Signature: "replace(source: string, search: string, replacement: string[, n: int]) -> string",
+				Description: "Replaces the occurrences of search in source, only the first n ones when n is set " +
+					"and isn't negative.",
+				Example: `replace("linux/amd64/v2", "/", "-", 1)`,
+			},
+			option: createReplaceFunction("replace"),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "split",
+				Signature: "split(source: string, separator: string) -> list<string>",
+				Description: "Splits source around the occurrences of the separator, which can't be empty. " +
+					"The list has at least one element, source itself when the separator isn't found.",
+				Example: `split("quay.io/konflux-ci/build:latest", "/")[0]`,
+			},
+			option: createSplitFunction("split"),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "quantityToMilli",
+				Signature:   "quantityToMilli(value: string) -> int",
+				Description: "Parses a Kubernetes resource quantity and returns its value in thousandths, rounded up.",
+				Example:     `quantityToMilli("1500m")`,
+			},
+			option: createQuantityFunction("quantityToMilli", func(q resource.Quantity) int64 { return q.MilliValue() }),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "quantityToBytes",
+				Signature:   "quantityToBytes(value: string) -> int",
+				Description: "Parses a Kubernetes resource quantity and returns its value, rounded up.",
+				Example:     `quantityToBytes("4Gi")`,
+			},
+			option: createQuantityFunction("quantityToBytes", func(q resource.Quantity) int64 { return q.Value() }),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "sum",
+				Signature:   "sum(values: list<int>) -> int",
+				Description: "Returns the sum of the values, 0 for an empty list. An overflow fails the evaluation.",
+				Example:     `resource("build-vms", sum(pipelineRun.spec.pipelineSpec.tasks.map(t, 1)))`,
+			},
+			option: createIntListFunction("sum", sumInts),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "max",
+				Signature:   "max(values: list<int>) -> int",
+				Description: "Returns the greatest of the values. An empty list fails the evaluation.",
+				Example:     `resource("parallel-builds", max([paramInt("parallelism", 1), 2]))`,
+			},
+			option: createIntListFunction("max", maxInt),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "min",
+				Signature:   "min(values: list<int>) -> int",
+				Description: "Returns the least of the values. An empty list fails the evaluation.",
+				Example:     `resource("parallel-builds", min([paramInt("parallelism", 1), 8]))`,
+			},
+			option: createIntListFunction("min", minInt),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "parseJSON",
+				Signature: "parseJSON(value: string) -> dyn",
+				Description: "Parses a JSON document, e.g. an annotation, into maps, lists, strings, ints, doubles, " +
+					"bools and nulls. The integers are ints, the other numbers doubles. The documents larger " +
+					"than 256KiB and the malformed ones are errors.",
+				Example: `parseJSON(pipelineRun.metadata.annotations["acme.io/test-matrix"]).platforms.size()`,
+			},
+			option: createParseFunction("parseJSON", jsonDocument),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "parseYAML",
+				Signature: "parseYAML(value: string) -> dyn",
+				Description: "Parses a YAML document like parseJSON. The documents larger than 256KiB and the " +
+					"malformed ones are errors.",
+				Example: `parseYAML("platforms: [linux/amd64, linux/arm64]").platforms[1]`,
+			},
+			option: createParseFunction("parseYAML", yamlToJSON),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:        "base64Decode",
+				Signature:   "base64Decode(value: string) -> string",
+				Description: "Decodes a standard base64 string, whose decoded value must be valid UTF-8.",
+				Example:     `parseJSON(base64Decode("eyJjb3VudCI6IDJ9")).count`,
+			},
+			option: createBase64DecodeFunction("base64Decode"),
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "paramInt",
+				Signature: "paramInt(name: string, default: int) -> int",
+				Description: "Returns the value of the param of the PipelineRun parsed as an integer, or the default " +
+					"when the param is missing, isn't a string or isn't an integer, e.g. \"7.5\". The params are " +
+					"chosen by the author of the PipelineRun.",
+				Example: `resource("parallel-builds", paramInt("parallelism", 1))`,
+			},
+			option:           createParamFunction("paramInt", cel.IntType, parseIntParam),
+			bindsPipelineRun: true,
+		},
+		{
+			FunctionReference: FunctionReference{
+				Name:      "paramBool",
+				Signature: "paramBool(name: string, default: bool) -> bool",
+				Description: "Returns the value of the param of the PipelineRun parsed as a bool, e.g. \"true\" or " +
+					"\"false\", or the default when the param is missing, isn't a string or isn't a bool.",
+				Example: `paramBool("skip-tests", false) ? [priority("low")] : []`,
+			},
+			option:           createParamFunction("paramBool", cel.BoolType, parseBoolParam),
+			bindsPipelineRun: true,
+		},
+	}
+}
+
+// GetReference returns the reference of the variables and functions of the
+// environment of the expressions. The functions are those of the
+// environment beyond the standard library, so functions registered without
+// reference are listed with an empty description.
+func GetReference() (Reference, error) {
+	env, err := createCELEnvironment()
+	if err != nil {
+		return Reference{}, err
+	}
+	stdEnv, err := cel.NewEnv()
+	if err != nil {
+		return Reference{}, err
+	}
+
+	reference := Reference{}
+	for _, variable := range variableDeclarations() {
+		reference.Variables = append(reference.Variables, variable.VariableReference)
+	}
+	declared := map[string]FunctionReference{}
+	for _, function := range functionDeclarations(keyValidator{}) {
+		declared[function.Name] = function.FunctionReference
+	}
+	for name := range env.Functions() {
+		if stdEnv.HasFunction(name) {
+			continue
+		}
+		function, ok := declared[name]
+		if !ok {
+			function = FunctionReference{Name: name}
+		}
+		reference.Functions = append(reference.Functions, function)
+	}
+	slices.SortFunc(reference.Functions, func(a, b FunctionReference) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return reference, nil
+}
+
+// WriteMarkdown writes the reference as Markdown.
+func (r Reference) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# CEL Reference\n\n## Variables\n")
+	for _, v := range r.Variables {
+		fmt.Fprintf(&b, "\n### `%s`\n\n", v.Name)
+		fmt.Fprintf(&b, "Type: `%s`%s\n\n%s\n\n", v.Type, since(v.Since), v.Description)
+		fmt.Fprintf(&b, "```cel\n%s\n```\n", v.Example)
+	}
+	b.WriteString("\n## Functions\n")
+	for _, f := range r.Functions {
+		fmt.Fprintf(&b, "\n### `%s`\n\n", f.Name)
+		fmt.Fprintf(&b, "`%s`%s\n\n%s\n\n", f.Signature, since(f.Since), f.Description)
+		fmt.Fprintf(&b, "```cel\n%s\n```\n", f.Example)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func since(version string) string {
+	if version == "" {
+		return ""
+	}
+	return " (since " + version + ")"
+}
@@ -0,0 +1,134 @@
+package cel
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildVars_MatchesDeclaredVariables(t *testing.T) {
+	g := NewWithT(t)
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	pipelineRunMap, err := structToCELMap(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var declared []string
+	for _, variable := range variableDeclarations() {
+		declared = append(declared, variable.Name)
+	}
+	vars := buildVars(pipelineRun, pipelineRunMap, nil, time.Now(), time.UTC)
+	g.Expect(vars).To(HaveLen(len(declared)))
+	for _, name := range declared {
+		g.Expect(vars).To(HaveKey(name))
+	}
+}
+
+func TestGetReference_DocumentsEveryFunction(t *testing.T) {
+	g := NewWithT(t)
+
+	reference, err := GetReference()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+	stdEnv, err := cel.NewEnv()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var registered []string
+	for name := range env.Functions() {
+		if !stdEnv.HasFunction(name) {
+			registered = append(registered, name)
+		}
+	}
+	var documented []string
+	for _, function := range reference.Functions {
+		documented = append(documented, function.Name)
+		g.Expect(function.Signature).To(HavePrefix(function.Name+"("), function.Name)
+		g.Expect(function.Description).NotTo(BeEmpty(), function.Name)
+		g.Expect(function.Example).NotTo(BeEmpty(), function.Name)
+	}
+	g.Expect(documented).To(ConsistOf(registered))
+	g.Expect(slices.IsSorted(documented)).To(BeTrue())
+
+	g.Expect(reference.Variables).To(HaveLen(len(variableDeclarations())))
+	for _, variable := range reference.Variables {
+		g.Expect(variable.Type).NotTo(BeEmpty(), variable.Name)
+		g.Expect(variable.Description).NotTo(BeEmpty(), variable.Name)
+		g.Expect(variable.Example).NotTo(BeEmpty(), variable.Name)
+	}
+}
+
+func TestFunctionDeclarations_MatchTheirReference(t *testing.T) {
+	for _, function := range functionDeclarations(keyValidator{}) {
+		t.Run(function.Name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			// The option registers the function of the reference, with the
+			// arguments of the signature, the optional ones being listed
+			// between brackets
+			env, err := cel.NewEnv(function.option)
+			g.Expect(err).NotTo(HaveOccurred())
+			decl, ok := env.Functions()[function.Name]
+			g.Expect(ok).To(BeTrue())
+			arguments := strings.Count(function.Signature, ":")
+			required, _, _ := strings.Cut(function.Signature, "[")
+			implicit := 0
+			if function.bindsPipelineRun {
+				implicit = 1
+			}
+			for _, overload := range decl.OverloadDecls() {
+				g.Expect(len(overload.ArgTypes())).To(BeNumerically(">=", strings.Count(required, ":")+implicit))
+				g.Expect(len(overload.ArgTypes())).To(BeNumerically("<=", arguments+implicit))
+			}
+		})
+	}
+}
+
+func TestReference_ExamplesCompile(t *testing.T) {
+	g := NewWithT(t)
+
+	env, err := createCELEnvironment()
+	g.Expect(err).NotTo(HaveOccurred())
+	reference, err := GetReference()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var examples []string
+	for _, variable := range reference.Variables {
+		examples = append(examples, variable.Example)
+	}
+	for _, function := range reference.Functions {
+		examples = append(examples, function.Example)
+	}
+	for _, example := range examples {
+		_, issues := env.Compile(example)
+		g.Expect(issues.Err()).NotTo(HaveOccurred(), example)
+	}
+}
+
+func TestReference_WriteMarkdown(t *testing.T) {
+	g := NewWithT(t)
+
+	reference := Reference{
+		Variables: []VariableReference{
+			{Name: "plrNamespace", Type: "string", Description: "The namespace.", Example: `plrNamespace == "a"`},
+		},
+		Functions: []FunctionReference{
+			{Name: "label", Signature: "label(key: string, value: string) -> MutationRequest",
+				Description: "Sets the label.", Since: "v0.2.0", Example: `label("a", "b")`},
+		},
+	}
+	var b strings.Builder
+	g.Expect(reference.WriteMarkdown(&b)).To(Succeed())
+	g.Expect(b.String()).To(Equal("# CEL Reference\n\n## Variables\n" +
+		"\n### `plrNamespace`\n\nType: `string`\n\nThe namespace.\n\n```cel\nplrNamespace == \"a\"\n```\n" +
+		"\n## Functions\n" +
+		"\n### `label`\n\n`label(key: string, value: string) -> MutationRequest` (since v0.2.0)\n\nSets the label.\n\n" +
+		"```cel\nlabel(\"a\", \"b\")\n```\n"))
+}
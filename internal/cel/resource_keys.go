@@ -0,0 +1,112 @@
+package cel
+
+import (
+	"slices"
+	"strings"
+
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/parser"
+)
+
+// ProducedResources lists the resources an expression can request, found
+// by a static analysis of its resource() and annotation() calls.
+type ProducedResources struct {
+	// Names are the names of the resources requested with a constant key,
+	// i.e. the keys of the resource annotations without their prefix.
+	Names []string
+	// Dynamic lists the calls whose resource name is only known at
+	// admission, e.g. resource(arch, 1).
+	Dynamic []string
+}
+
+// GetProducedResources returns the resources the expression can request.
+// Calls of resource() are considered, and calls of annotation() whose key
+// has one of the resource annotation prefixes, either as a constant or as
+// the leftmost operand of a concatenation. No prefixes stand for
+// ResourceAnnotationPrefix.
+func (cp *CompiledProgram) GetProducedResources(prefixes ...string) ProducedResources {
+	if len(prefixes) == 0 {
+		prefixes = []string{ResourceAnnotationPrefix}
+	}
+	native := cp.ast.NativeRep()
+	var produced ProducedResources
+	celast.PreOrderVisit(native.Expr(), celast.NewExprVisitor(func(expr celast.Expr) {
+		if expr.Kind() != celast.CallKind {
+			return
+		}
+		call := expr.AsCall()
+		if len(call.Args()) == 0 {
+			return
+		}
+		key := call.Args()[0]
+		switch call.FunctionName() {
+		case "resource":
+			if name, ok := stringLiteral(key); ok {
+				produced.addName(name)
+				return
+			}
+		case "annotation":
+			if name, ok := stringLiteral(key); ok {
+				if suffix, ok := cutAnyPrefix(name, prefixes); ok {
+					produced.addName(suffix)
+				}
+				return
+			}
+			if head, ok := stringLiteral(leftmostOperand(key)); !ok || !hasAnyPrefix(head, prefixes) {
+				return
+			}
+		default:
+			return
+		}
+		rendered, err := parser.Unparse(expr, native.SourceInfo())
+		if err != nil {
+			rendered = call.FunctionName() + "(...)"
+		}
+		if !slices.Contains(produced.Dynamic, rendered) {
+			produced.Dynamic = append(produced.Dynamic, rendered)
+		}
+	}))
+	return produced
+}
+
+func (p *ProducedResources) addName(name string) {
+	if !slices.Contains(p.Names, name) {
+		p.Names = append(p.Names, name)
+	}
+}
+
+// stringLiteral returns the value of expr if it's a string literal.
+func stringLiteral(expr celast.Expr) (string, bool) {
+	if expr.Kind() != celast.LiteralKind {
+		return "", false
+	}
+	s, ok := expr.AsLiteral().Value().(string)
+	return s, ok
+}
+
+// leftmostOperand returns the leftmost operand of a chain of additions,
+// e.g. "a" for "a" + b + c, or expr itself.
+func leftmostOperand(expr celast.Expr) celast.Expr {
+	for expr.Kind() == celast.CallKind && expr.AsCall().FunctionName() == operators.Add && len(expr.AsCall().Args()) == 2 {
+		expr = expr.AsCall().Args()[0]
+	}
+	return expr
+}
+
+// cutAnyPrefix returns s without the first of the prefixes it has.
+func cutAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if suffix, ok := strings.CutPrefix(s, prefix); ok {
+			return suffix, true
+		}
+	}
+	return s, false
+}
+
+// hasAnyPrefix reports whether s has one of the prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	return slices.ContainsFunc(prefixes, func(prefix string) bool {
+		return strings.HasPrefix(s, prefix)
+	})
+}
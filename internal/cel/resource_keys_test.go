@@ -0,0 +1,67 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestGetProducedResources(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		prefixes   []string
+		expected   ProducedResources
+	}{
+		{
+			name:       "constant resource",
+			expression: `resource("cpu", 1)`,
+			expected:   ProducedResources{Names: []string{"cpu"}},
+		},
+		{
+			name:       "resources in branches and lists",
+			expression: `plrNamespace == "x" ? [resource("linux-amd64", 2), resource("cpu", 1)] : [resource("cpu", 2)]`,
+			expected:   ProducedResources{Names: []string{"linux-amd64", "cpu"}},
+		},
+		{
+			name:       "constant resource annotation",
+			expression: `[annotation("kueue.konflux-ci.dev/requests-memory", "1Gi"), annotation("owner", "team-a")]`,
+			expected:   ProducedResources{Names: []string{"memory"}},
+		},
+		{
+			name:       "dynamic resource",
+			expression: `[resource("cpu", 1), resource(plrNamespace, 1)]`,
+			expected: ProducedResources{
+				Names:   []string{"cpu"},
+				Dynamic: []string{"resource(plrNamespace, 1)"},
+			},
+		},
+		{
+			name:       "dynamic resource annotation",
+			expression: `annotation("kueue.konflux-ci.dev/requests-" + plrNamespace + "-builds", "1")`,
+			expected: ProducedResources{
+				Dynamic: []string{`annotation("kueue.konflux-ci.dev/requests-" + plrNamespace + "-builds", "1")`},
+			},
+		},
+		{
+			name:       "dynamic annotation without resource prefix",
+			expression: `annotation("team-" + plrNamespace, "1")`,
+		},
+		{
+			name:       "configured prefixes",
+			expression: `[annotation("example.com/requests-gpu", "1"), annotation("kueue.konflux-ci.dev/requests-cpu", "1")]`,
+			prefixes:   []string{"example.com/requests-"},
+			expected:   ProducedResources{Names: []string{"gpu"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs[0].GetProducedResources(tt.prefixes...)).To(Equal(tt.expected))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package cel
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// WithResourceRequestPruning removes the resource annotations of the
+// PipelineRuns whose resource, the part of the key following the prefix,
+// is neither requested by the mutations returned by the programs nor
+// listed in allowed, e.g. the annotations of a retired platform copied
+// from a former PipelineRun by a re-run. The annotations are removed once
+// the programs are evaluated, so the keys they compute are kept.
+func WithResourceRequestPruning(allowed []string) MutatorOption {
+	return func(m *CELMutator) {
+		m.pruneResourceRequests = true
+		m.allowedResourceRequests = allowed
+	}
+}
+
+// pruneUnknownResourceRequests removes the resource annotations of the
+// PipelineRun, with any of the prefixes of the mutator, whose resource is
+// neither set by the mutations nor allowed. The removed keys are logged to
+// the logger of the context at debug level.
+func (m *CELMutator) pruneUnknownResourceRequests(
+	ctx context.Context,
+	pipelineRun *tekv1.PipelineRun,
+	mutations []*MutationRequest,
+) {
+	prefixes := m.resourcePrefixes()
+	known := map[string]bool{}
+	for _, name := range m.allowedResourceRequests {
+		known[name] = true
+	}
+	for _, mutation := range mutations {
+		if mutation.Type == MutationTypeLabel {
+			continue
+		}
+		if name, ok := cutResourcePrefix(mutation.Key, prefixes); ok {
+			known[name] = true
+		}
+	}
+
+	for key := range pipelineRun.Annotations {
+		if name, ok := cutResourcePrefix(key, prefixes); ok && !known[name] {
+			logr.FromContextOrDiscard(ctx).V(1).Info("Removing the request of a resource the expressions don't produce",
+				"key", key)
+			delete(pipelineRun.Annotations, key)
+		}
+	}
+}
+
+// cutResourcePrefix returns the resource of the key, i.e. the part
+// following the first of the prefixes it starts with.
+func cutResourcePrefix(key string, prefixes []string) (string, bool) {
+	i := slices.IndexFunc(prefixes, func(prefix string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+	if i < 0 {
+		return "", false
+	}
+	return strings.TrimPrefix(key, prefixes[i]), true
+}
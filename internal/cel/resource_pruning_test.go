@@ -0,0 +1,94 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_Mutate_ResourceRequestPruning(t *testing.T) {
+	const platforms = `pipelineRun.spec.params.exists(p, p.name == "build-platforms") ?
+		pipelineRun.spec.params.filter(p, p.name == "build-platforms")[0].value.map(
+			p, resource(replace(p, "/", "-"), 1)) : []`
+
+	g := NewWithT(t)
+	programs, err := CompileCELPrograms([]string{
+		platforms,
+		`annotation("kueue.konflux-ci.dev/requests-" + plrNamespace + "-quota", "1")`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithResourceRequestPruning([]string{"cpu"}))
+
+	plr := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "tenant",
+			Annotations: map[string]string{
+				ResourceAnnotationPrefix + "linux-amd64": "1",
+				ResourceAnnotationPrefix + "linux-s390x": "1",
+				ResourceAnnotationPrefix + "cpu":         "2",
+				"owner":                                  "team-a",
+			},
+		},
+		Spec: tekv1.PipelineRunSpec{Params: tekv1.Params{{
+			Name:  "build-platforms",
+			Value: *tekv1.NewStructuredValues("linux/amd64", "linux/arm64"),
+		}}},
+	}
+	g.Expect(mutator.Mutate(context.Background(), plr)).To(Succeed())
+
+	g.Expect(plr.Annotations).To(Equal(map[string]string{
+		// Produced by the expressions
+		ResourceAnnotationPrefix + "linux-amd64":  "2",
+		ResourceAnnotationPrefix + "linux-arm64":  "1",
+		ResourceAnnotationPrefix + "tenant-quota": "1",
+		// Allowed
+		ResourceAnnotationPrefix + "cpu": "2",
+		// Not a resource annotation
+		"owner":                    "team-a",
+		AnnotationAppliedResources: `{"kueue.konflux-ci.dev/requests-linux-amd64":1,"kueue.konflux-ci.dev/requests-linux-arm64":1}`,
+	}))
+}
+
+func TestCELMutator_Mutate_ResourceRequestPruning_Prefixes(t *testing.T) {
+	const newPrefix = "queue.konflux.dev/requests-"
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`resource("linux-amd64", 1)`})
+	g.Expect(err).NotTo(HaveOccurred())
+	prefixes := []string{newPrefix, ResourceAnnotationPrefix}
+	mutator := NewCELMutator(programs,
+		WithResourceAnnotationPrefixes(prefixes, prefixes), WithResourceRequestPruning(nil))
+
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name:      "build",
+		Namespace: "tenant",
+		Annotations: map[string]string{
+			ResourceAnnotationPrefix + "linux-s390x": "1",
+			newPrefix + "linux-ppc64le":              "1",
+		},
+	}}
+	g.Expect(mutator.Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Annotations).To(HaveKeyWithValue(newPrefix+"linux-amd64", "1"))
+	g.Expect(plr.Annotations).To(HaveKeyWithValue(ResourceAnnotationPrefix+"linux-amd64", "1"))
+	g.Expect(plr.Annotations).NotTo(HaveKey(ResourceAnnotationPrefix + "linux-s390x"))
+	g.Expect(plr.Annotations).NotTo(HaveKey(newPrefix + "linux-s390x"))
+	g.Expect(plr.Annotations).NotTo(HaveKey(newPrefix + "linux-ppc64le"))
+}
+
+func TestCELMutator_Mutate_ResourceRequestPruning_Disabled(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{`resource("linux-amd64", 1)`})
+	g.Expect(err).NotTo(HaveOccurred())
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name:        "build",
+		Namespace:   "tenant",
+		Annotations: map[string]string{ResourceAnnotationPrefix + "linux-s390x": "1"},
+	}}
+	g.Expect(NewCELMutator(programs).Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Annotations).To(HaveKeyWithValue(ResourceAnnotationPrefix+"linux-s390x", "1"))
+}
@@ -0,0 +1,112 @@
+package cel
+
+import (
+	"fmt"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// selfCheckAnnotation is the annotation set by the expressions of the self
+// checks.
+const selfCheckAnnotation = "self-check"
+
+// selfChecks pin the results of the evaluations which could differ between
+// platforms or between evaluations: the formatting of doubles, the
+// conversions of quantities and the order of the iteration of maps. The
+// values are those of the linux/amd64 build.
+var selfChecks = []struct {
+	expression string
+	expected   string
+}{
+	{`annotation("self-check", string(2.0 / 3.0))`, "0.6666666666666666"},
+	{`annotation("self-check", string(0.1 + 0.2))`, "0.30000000000000004"},
+	{`annotation("self-check", string(1e21))`, "1e+21"},
+	{`annotation("self-check", string(double(quantityToMilli("1500m")) / 7.0))`, "214.28571428571428"},
+	{`annotation("self-check", string(int(2.9)))`, "2"},
+	{`annotation("self-check", string(quantityToMilli("0.0001")))`, "1"},
+	{`annotation("self-check", string(quantityToBytes("1.5Gi")))`, "1610612736"},
+	{`annotation("self-check", string(workspaceStorage["cache"] / 3))`, "536870912"},
+	{
+		`pipelineRun.metadata.labels.map(k, appendAnnotation("self-check", k, ","))`,
+		"a,b,c,d,e,f,g,h",
+	},
+	{
+		`pipelineRun.metadata.labels.filter(k, int(pipelineRun.metadata.labels[k]) % 2 == 0)` +
+			`.map(k, appendAnnotation("self-check", pipelineRun.metadata.labels[k], ";"))`,
+		"2;4;6;8",
+	},
+	{
+		`workspaceStorage.map(w, appendAnnotation("self-check", w, "+"))`,
+		"cache+output+source",
+	},
+}
+
+// selfCheckPipelineRun returns the PipelineRun the self checks are
+// evaluated against.
+func selfCheckPipelineRun() *tekv1.PipelineRun {
+	claim := func(storage string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(storage)},
+			},
+		}}
+	}
+	return &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "self-check",
+			Namespace: "self-check",
+			Labels: map[string]string{
+				"h": "8", "g": "7", "f": "6", "e": "5", "d": "4", "c": "3", "b": "2", "a": "1",
+			},
+		},
+		Spec: tekv1.PipelineRunSpec{
+			Workspaces: []tekv1.WorkspaceBinding{
+				{Name: "source", VolumeClaimTemplate: claim("1Gi")},
+				{Name: "output", EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				{Name: "cache", VolumeClaimTemplate: claim("1.5Gi")},
+			},
+		},
+	}
+}
+
+// SelfCheck evaluates expressions whose results could differ between
+// platforms, and fails when a result differs from its pinned value. The
+// webhook runs it when it starts, so a replica mutating the PipelineRuns
+// differently from the others doesn't start.
+func SelfCheck() error {
+	for _, check := range selfChecks {
+		actual, err := evaluateSelfCheck(check.expression)
+		if err != nil {
+			return fmt.Errorf("self check %q failed: %w", check.expression, err)
+		}
+		if actual != check.expected {
+			return fmt.Errorf("self check %q returned %q, expected %q", check.expression, actual, check.expected)
+		}
+	}
+	return nil
+}
+
+// evaluateSelfCheck applies the mutations of the expression to the self
+// check PipelineRun, and returns the value of the self check annotation.
+// Unlike CELMutator.Mutate, it doesn't record metrics on success.
+func evaluateSelfCheck(expression string) (string, error) {
+	programs, err := CompileCELPrograms([]string{expression})
+	if err != nil {
+		return "", err
+	}
+	pipelineRun := selfCheckPipelineRun()
+	mutations, err := programs[0].Evaluate(pipelineRun)
+	if err != nil {
+		return "", err
+	}
+	mutator := &CELMutator{}
+	for _, mutation := range mutations {
+		if _, err := mutator.mutate(pipelineRun, mutation); err != nil {
+			return "", err
+		}
+	}
+	return pipelineRun.Annotations[selfCheckAnnotation], nil
+}
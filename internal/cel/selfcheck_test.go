@@ -0,0 +1,25 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSelfCheck(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(SelfCheck()).To(Succeed())
+}
+
+func TestSelfCheck_Deterministic(t *testing.T) {
+	for _, check := range selfChecks {
+		t.Run(check.expression, func(t *testing.T) {
+			g := NewWithT(t)
+			for range 20 {
+				actual, err := evaluateSelfCheck(check.expression)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(actual).To(Equal(check.expected))
+			}
+		})
+	}
+}
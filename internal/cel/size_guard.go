@@ -0,0 +1,59 @@
+package cel
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-logr/logr"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// WithMaxObjectBytes skips the evaluation of the programs for the
+// PipelineRuns whose JSON exceeds maxBytes, e.g. PipelineRuns embedding
+// hundreds of task specs, bounding the memory of the evaluations. The
+// PipelineRuns are left unmutated, the skips are logged with log and
+// counted in tekton_kueue_cel_evaluations_total with the
+// skipped_too_large result. A maxBytes of zero disables the guard.
+func WithMaxObjectBytes(log logr.Logger, maxBytes int) MutatorOption {
+	return func(m *CELMutator) {
+		m.maxObjectBytes = maxBytes
+		m.sizeGuardLog = log
+	}
+}
+
+// sizeGuardReportKey is the context key of the report of the skips of the
+// size guard, see WithSizeGuardReport.
+type sizeGuardReportKey struct{}
+
+// WithSizeGuardReport returns a context reporting the evaluations skipped
+// by the size guard, and the function returning whether one of the
+// evaluations run with the context was skipped, so the callers of the
+// mutators can tell the PipelineRuns which weren't mutated because of
+// their size.
+func WithSizeGuardReport(ctx context.Context) (context.Context, func() bool) {
+	skipped := new(bool)
+	return context.WithValue(ctx, sizeGuardReportKey{}, skipped), func() bool { return *skipped }
+}
+
+// tooLarge returns whether the size of the JSON of the PipelineRun exceeds
+// the limit set with WithMaxObjectBytes, recording the skip when it does.
+// None of the programs is evaluated for these PipelineRuns.
+func (m *CELMutator) tooLarge(ctx context.Context, pipelineRun *tekv1.PipelineRun, size int) bool {
+	if m.maxObjectBytes <= 0 || size <= m.maxObjectBytes {
+		return false
+	}
+	if skipped, ok := ctx.Value(sizeGuardReportKey{}).(*bool); ok {
+		*skipped = true
+	}
+	m.sizeGuardLog.Info("WARNING: skipping the CEL evaluation of a PipelineRun exceeding the size limit",
+		"namespace", pipelineRun.Namespace, "name", pipelineRun.Name, "generateName", pipelineRun.GenerateName,
+		"size", size, "maxObjectBytes", m.maxObjectBytes)
+	var groups []string
+	for _, program := range m.programs {
+		if !slices.Contains(groups, program.group) {
+			groups = append(groups, program.group)
+			RecordEvaluationSkippedTooLarge(program.group)
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newGeneratedPipelineRun returns a PipelineRun embedding the specs of
+// tasks tasks, as generated by some pipelines.
+func newGeneratedPipelineRun(tasks int) *tekv1.PipelineRun {
+	plr := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "generated", Namespace: "default"},
+		Spec:       tekv1.PipelineRunSpec{PipelineSpec: &tekv1.PipelineSpec{}},
+	}
+	for i := range tasks {
+		plr.Spec.PipelineSpec.Tasks = append(plr.Spec.PipelineSpec.Tasks, tekv1.PipelineTask{
+			Name: fmt.Sprintf("task-%d", i),
+			TaskSpec: &tekv1.EmbeddedTask{TaskSpec: tekv1.TaskSpec{
+				Steps: []tekv1.Step{{Name: "build", Image: "quay.io/konflux-ci/build:latest", Script: "make build"}},
+			}},
+		})
+	}
+	return plr
+}
+
+func TestCELMutator_MaxObjectBytes(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	programs, err := CompileCELPrograms([]string{`[label("team", "a"), resource("linux-amd64", 1)]`},
+		WithGroup("size-test"))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs, WithMaxObjectBytes(logr.Discard(), 64*1024))
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(celEvaluationsTotal.WithLabelValues(result, "size-test"))
+	}
+	skippedBefore, successBefore := counter("skipped_too_large"), counter("success")
+
+	// The expressions aren't evaluated for the oversized PipelineRuns
+	oversized := newGeneratedPipelineRun(500)
+	_, size, err := structToCELMapWithSize(oversized)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(size).To(BeNumerically(">", 64*1024))
+	g.Expect(mutator.Mutate(ctx, oversized)).To(Succeed())
+	g.Expect(oversized.Labels).NotTo(HaveKey("team"))
+	g.Expect(oversized.Annotations).NotTo(HaveKey(ResourceAnnotationPrefix + "linux-amd64"))
+	g.Expect(counter("skipped_too_large") - skippedBefore).To(Equal(1.0))
+	g.Expect(counter("success") - successBefore).To(Equal(0.0))
+
+	small := newGeneratedPipelineRun(5)
+	g.Expect(mutator.Mutate(ctx, small)).To(Succeed())
+	g.Expect(small.Labels).To(HaveKeyWithValue("team", "a"))
+	g.Expect(small.Annotations).To(HaveKeyWithValue(ResourceAnnotationPrefix+"linux-amd64", "1"))
+	g.Expect(counter("skipped_too_large") - skippedBefore).To(Equal(1.0))
+	g.Expect(counter("success") - successBefore).To(Equal(1.0))
+
+	// The skips are reported to the callers asking for it
+	reportCtx, skipped := WithSizeGuardReport(ctx)
+	g.Expect(mutator.Mutate(reportCtx, newGeneratedPipelineRun(5))).To(Succeed())
+	g.Expect(skipped()).To(BeFalse())
+	g.Expect(mutator.Mutate(reportCtx, newGeneratedPipelineRun(500))).To(Succeed())
+	g.Expect(skipped()).To(BeTrue())
+
+	// Without the option, the PipelineRuns of any size are mutated
+	oversized = newGeneratedPipelineRun(500)
+	g.Expect(NewCELMutator(programs).Mutate(ctx, oversized)).To(Succeed())
+	g.Expect(oversized.Labels).To(HaveKeyWithValue("team", "a"))
+}
+
+func TestCompiledProgram_Evaluate_SharedMap(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`has(pipelineRun.status) ? [label("status", "true")] : [label("status", "false")]`,
+	}, WithExcludeStatus())
+	g.Expect(err).NotTo(HaveOccurred())
+	plr := &tekv1.PipelineRun{}
+	pipelineRunMap, err := structToCELMap(plr)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Excluding the status doesn't remove it from the map shared with the
+	// other programs
+	mutations, err := programs[0].evaluate(context.Background(), plr, pipelineRunMap, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(&MutationRequest{Type: MutationTypeLabel, Key: "status", Value: "false"}))
+	g.Expect(pipelineRunMap).To(HaveKey("status"))
+}
@@ -0,0 +1,110 @@
+package cel
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// ResultStability describes how long the result of an expression hasn't
+// changed. Expressions whose result is constant across many admissions are
+// candidates for removal.
+type ResultStability struct {
+	// Group is the name of the expression group.
+	Group string `json:"group"`
+	// Index is the index of the expression in its group.
+	Index int `json:"index"`
+	// Name is the name of the expression, if any.
+	Name string `json:"name,omitempty"`
+	// Expression is the CEL expression.
+	Expression string `json:"expression"`
+	// Admissions is the number of admissions the expression was evaluated
+	// for.
+	Admissions uint64 `json:"admissions"`
+	// StableFor is the number of consecutive admissions, up to the last
+	// one, for which the expression returned the same mutations.
+	StableFor uint64 `json:"stableFor"`
+}
+
+// programResults tracks the results of a program: the fingerprint of its
+// last result and two counters.
+type programResults struct {
+	group       string
+	index       int
+	name        string
+	fingerprint uint64
+	admissions  uint64
+	stableFor   uint64
+}
+
+// resultTracker tracks the stability of the results of the programs of a
+// CELMutator. Its state lives as long as the mutator, so it's reset when the
+// mutator is rebuilt from a new configuration.
+type resultTracker struct {
+	mu       sync.Mutex
+	programs []programResults
+}
+
+// newResultTracker creates the tracker of the programs, and resets the
+// result stability metric, so it only reports the programs of the new
+// configuration.
+func newResultTracker(programs []*CompiledProgram) *resultTracker {
+	t := &resultTracker{programs: make([]programResults, len(programs))}
+	indexes := map[string]int{}
+	for i, program := range programs {
+		t.programs[i] = programResults{group: program.group, index: indexes[program.group], name: program.name}
+		indexes[program.group]++
+	}
+	celExpressionResultStableFor.Reset()
+	return t
+}
+
+// observe records the results of an admission, by index of the program.
+// The programs which weren't evaluated, e.g. the ones of other queues, have
+// no result.
+func (t *resultTracker) observe(results map[int][]*MutationRequest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, result := range results {
+		p := &t.programs[i]
+		fingerprint := fingerprintResult(result)
+		if p.admissions == 0 || fingerprint != p.fingerprint {
+			p.fingerprint = fingerprint
+			p.stableFor = 0
+		}
+		p.admissions++
+		p.stableFor++
+		celExpressionResultStableFor.WithLabelValues(p.group, indexLabel(p.index, p.name)).Set(float64(p.stableFor))
+	}
+}
+
+// fingerprintResult hashes the mutations returned by a program.
+func fingerprintResult(mutations []*MutationRequest) uint64 {
+	h := fnv.New64a()
+	for _, m := range mutations {
+		for _, s := range []string{string(m.Type), m.Key, m.Value} {
+			_, _ = h.Write([]byte(s))
+			_, _ = h.Write([]byte{0})
+		}
+	}
+	return h.Sum64()
+}
+
+// ResultStability returns the result stability of each program, in the
+// order they're evaluated.
+func (m *CELMutator) ResultStability() []ResultStability {
+	m.results.mu.Lock()
+	defer m.results.mu.Unlock()
+	stability := make([]ResultStability, len(m.programs))
+	for i, program := range m.programs {
+		p := m.results.programs[i]
+		stability[i] = ResultStability{
+			Group:      p.group,
+			Index:      p.index,
+			Name:       p.name,
+			Expression: program.expression,
+			Admissions: p.admissions,
+			StableFor:  p.stableFor,
+		}
+	}
+	return stability
+}
@@ -0,0 +1,107 @@
+package cel
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCELMutator_ResultStability(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`priority("high")`,
+		`label("tenant", plrNamespace)`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	newPipelineRun := func(namespace string) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace}}
+	}
+	stableFor := func(index string) float64 {
+		return testutil.ToFloat64(celExpressionResultStableFor.WithLabelValues(DefaultGroup, index))
+	}
+
+	for _, namespace := range []string{"ns-a", "ns-b", "ns-a", "ns-a"} {
+		g.Expect(mutator.Mutate(context.Background(), newPipelineRun(namespace))).To(Succeed())
+	}
+	g.Expect(mutator.ResultStability()).To(Equal([]ResultStability{
+		{Group: DefaultGroup, Index: 0, Expression: `priority("high")`, Admissions: 4, StableFor: 4},
+		{Group: DefaultGroup, Index: 1, Expression: `label("tenant", plrNamespace)`, Admissions: 4, StableFor: 2},
+	}))
+	g.Expect(stableFor("0")).To(Equal(4.0))
+	g.Expect(stableFor("1")).To(Equal(2.0))
+
+	// Dry runs aren't admissions
+	_, err = mutator.DryRun(newPipelineRun("ns-c"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutator.ResultStability()[1].StableFor).To(Equal(uint64(2)))
+
+	g.Expect(mutator.Mutate(context.Background(), newPipelineRun("ns-c"))).To(Succeed())
+	g.Expect(mutator.ResultStability()[0].StableFor).To(Equal(uint64(5)))
+	g.Expect(mutator.ResultStability()[1].StableFor).To(Equal(uint64(1)))
+	g.Expect(stableFor("1")).To(Equal(1.0))
+
+	// A new mutator, built from a new configuration, starts over
+	mutator = NewCELMutator(programs)
+	g.Expect(testutil.CollectAndCount(celExpressionResultStableFor)).To(Equal(0))
+	g.Expect(mutator.ResultStability()).To(HaveEach(HaveField("Admissions", uint64(0))))
+}
+
+func TestCELMutator_ResultStability_Groups(t *testing.T) {
+	g := NewWithT(t)
+
+	defaultPrograms, err := CompileCELPrograms([]string{`priority("high")`})
+	g.Expect(err).NotTo(HaveOccurred())
+	groupPrograms, err := CompileCELPrograms([]string{
+		`pacEventType == "push" ? [label("event", "push")] : []`,
+		`annotation("owner", "team-a")`,
+	}, WithGroup("stability-test"))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(append(defaultPrograms, groupPrograms...))
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	for range 3 {
+		g.Expect(mutator.Mutate(context.Background(), pipelineRun.DeepCopy())).To(Succeed())
+	}
+	pipelineRun.Labels = map[string]string{"pipelinesascode.tekton.dev/event-type": "push"}
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+	stability := mutator.ResultStability()
+	g.Expect(stability).To(HaveLen(3))
+	g.Expect(stability[1]).To(Equal(ResultStability{
+		Group:      "stability-test",
+		Index:      0,
+		Expression: `pacEventType == "push" ? [label("event", "push")] : []`,
+		Admissions: 4,
+		StableFor:  1,
+	}))
+	g.Expect(stability[2].Index).To(Equal(1))
+	g.Expect(stability[2].StableFor).To(Equal(uint64(4)))
+	g.Expect(testutil.ToFloat64(celExpressionResultStableFor.WithLabelValues("stability-test", "1"))).To(Equal(4.0))
+}
+
+func TestCELMutator_ResultStability_Named(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileNamedCELPrograms([]NamedExpression{
+		{Expression: `priority("high")`},
+		{Name: "owner", Expression: `annotation("owner", "team-a")`},
+	}, WithGroup("stability-named-test"))
+	g.Expect(err).NotTo(HaveOccurred())
+	mutator := NewCELMutator(programs)
+
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test-ns"}}
+	g.Expect(mutator.Mutate(context.Background(), pipelineRun)).To(Succeed())
+
+	stability := mutator.ResultStability()
+	g.Expect(stability[1].Name).To(Equal("owner"))
+	// The named expressions are labeled with their name instead of their index
+	g.Expect(testutil.ToFloat64(celExpressionResultStableFor.WithLabelValues("stability-named-test", "0"))).To(Equal(1.0))
+	g.Expect(testutil.ToFloat64(celExpressionResultStableFor.WithLabelValues("stability-named-test", "owner"))).To(Equal(1.0))
+}
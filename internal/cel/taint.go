@@ -0,0 +1,293 @@
+package cel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+)
+
+// userControlledVariables are the CEL variables whose values can be chosen by
+// the author of the PipelineRun. pacEventType and pacTestEventType are read
+// from PipelineRun labels, pacRetryCount from an annotation, workspaceStorage
+// from its workspaces, and plrPipelineName and plrGenerateName from its spec
+// and metadata.
+var userControlledVariables = map[string]bool{
+	"pipelineRun":      true,
+	"pacEventType":     true,
+	"pacTestEventType": true,
+	"pacRetryCount":    true,
+	"workspaceStorage": true,
+	"plrPipelineName":  true,
+	"plrGenerateName":  true,
+}
+
+// trustedPaths are paths of user-controlled variables whose values can't be
+// chosen freely by the author of the PipelineRun.
+var trustedPaths = map[string]bool{
+	"pipelineRun.metadata.namespace": true,
+}
+
+// statusRoots are the paths of the PipelineRun status, which is empty when
+// PipelineRuns are admitted.
+var statusRoots = []string{"pipelineRun.status", `pipelineRun["status"]`}
+
+// policySensitiveFunctions are the functions whose arguments must not be
+// controlled by the author of the PipelineRun, as they would let users bypass
+// the scheduling policy.
+var policySensitiveFunctions = map[string]bool{
+	"priority":       true,
+	"serviceAccount": true,
+	"nodeSelector":   true,
+}
+
+// TaintWarning reports a user-controlled value flowing into the argument of a
+// policy-sensitive function.
+type TaintWarning struct {
+	// Function is the name of the policy-sensitive function.
+	Function string
+	// Path is the user-controlled value the argument is derived from, e.g.
+	// pipelineRun.metadata.annotations["x"].
+	Path string
+}
+
+// String returns a human-readable description of the warning.
+func (w TaintWarning) String() string {
+	return fmt.Sprintf("the argument of %s() is derived from the user-controlled value %s", w.Function, w.Path)
+}
+
+// analyzeExpression walks the checked AST and reports:
+//   - the user-controlled values flowing into the arguments of
+//     policy-sensitive functions, directly or through concatenations,
+//     function calls and the branches of conditional expressions. Conditions
+//     and other boolean values aren't considered, so
+//     priority(plrNamespace == "x" ? "high" : "low") isn't reported.
+//   - the references to pipelineRun.status, including through aliases such
+//     as (cond ? pipelineRun : x).status. has(pipelineRun.status) isn't
+//     reported, as it's the way to check whether the status is available.
+func analyzeExpression(ast *cel.Ast) ([]TaintWarning, []string) {
+	a := &taintAnalyzer{ast: ast.NativeRep(), seen: map[TaintWarning]bool{}}
+	a.visit(a.ast.Expr(), nil)
+	return a.warnings, a.statusReferences()
+}
+
+type taintAnalyzer struct {
+	ast      *celast.AST
+	warnings []TaintWarning
+	seen     map[TaintWarning]bool
+	// statusPaths holds the paths rooted at pipelineRun.status
+	statusPaths []string
+}
+
+// visit visits expr and its sub-expressions, and returns the path of the
+// user-controlled value expr is derived from, or "" if it isn't derived from
+// one. scope holds the paths of the tainted comprehension variables.
+func (a *taintAnalyzer) visit(expr celast.Expr, scope map[string]string) string {
+	taint := a.taintOf(expr, scope)
+	if t := a.ast.GetType(expr.ID()); t != nil && t.Kind() == types.BoolKind {
+		return ""
+	}
+	return taint
+}
+
+func (a *taintAnalyzer) taintOf(expr celast.Expr, scope map[string]string) string {
+	switch expr.Kind() {
+	case celast.IdentKind:
+		name := expr.AsIdent()
+		if path, ok := scope[name]; ok {
+			return path
+		}
+		if userControlledVariables[name] {
+			return name
+		}
+		return ""
+
+	case celast.SelectKind:
+		sel := expr.AsSelect()
+		taint := a.visit(sel.Operand(), scope)
+		if taint == "" || sel.IsTestOnly() {
+			return ""
+		}
+		return a.trusted(a.recordStatusPath(taint + "." + sel.FieldName()))
+
+	case celast.CallKind:
+		return a.visitCall(expr.AsCall(), scope)
+
+	case celast.ListKind:
+		taint := ""
+		for _, elem := range expr.AsList().Elements() {
+			taint = firstTaint(taint, a.visit(elem, scope))
+		}
+		return taint
+
+	case celast.MapKind:
+		taint := ""
+		for _, entry := range expr.AsMap().Entries() {
+			e := entry.AsMapEntry()
+			a.visit(e.Key(), scope)
+			taint = firstTaint(taint, a.visit(e.Value(), scope))
+		}
+		return taint
+
+	case celast.StructKind:
+		taint := ""
+		for _, field := range expr.AsStruct().Fields() {
+			taint = firstTaint(taint, a.visit(field.AsStructField().Value(), scope))
+		}
+		return taint
+
+	case celast.ComprehensionKind:
+		return a.visitComprehension(expr.AsComprehension(), scope)
+
+	default:
+		return ""
+	}
+}
+
+func (a *taintAnalyzer) visitCall(call celast.CallExpr, scope map[string]string) string {
+	args := call.Args()
+
+	switch call.FunctionName() {
+	case operators.Conditional:
+		// Only the branches are returned, the condition doesn't taint the result
+		a.visit(args[0], scope)
+		return firstTaint(a.visit(args[1], scope), a.visit(args[2], scope))
+
+	case operators.Index:
+		taint := a.visit(args[0], scope)
+		a.visit(args[1], scope)
+		if taint == "" {
+			return ""
+		}
+		return a.trusted(a.recordStatusPath(taint + indexPath(args[1])))
+	}
+
+	taint := ""
+	if call.IsMemberFunction() {
+		taint = a.visit(call.Target(), scope)
+	}
+	for _, arg := range args {
+		taint = firstTaint(taint, a.visit(arg, scope))
+	}
+
+	if policySensitiveFunctions[call.FunctionName()] {
+		if taint != "" {
+			a.report(TaintWarning{Function: call.FunctionName(), Path: taint})
+		}
+		// The mutation itself doesn't carry the taint any further
+		return ""
+	}
+	return taint
+}
+
+func (a *taintAnalyzer) visitComprehension(comp celast.ComprehensionExpr, scope map[string]string) string {
+	inner := make(map[string]string, len(scope)+3)
+	for k, v := range scope {
+		inner[k] = v
+	}
+
+	rangeTaint := a.visit(comp.IterRange(), scope)
+	if rangeTaint != "" {
+		inner[comp.IterVar()] = rangeTaint + "[*]"
+		if comp.HasIterVar2() {
+			inner[comp.IterVar2()] = rangeTaint + "[*]"
+		}
+	} else {
+		delete(inner, comp.IterVar())
+		if comp.HasIterVar2() {
+			delete(inner, comp.IterVar2())
+		}
+	}
+
+	// The accumulator is tainted if any of its updates is, so the loop step
+	// is visited once with the initial value and once more if the step
+	// taints it.
+	accuTaint := a.visit(comp.AccuInit(), scope)
+	setScope(inner, comp.AccuVar(), accuTaint)
+	a.visit(comp.LoopCondition(), inner)
+	if stepTaint := a.visit(comp.LoopStep(), inner); stepTaint != "" && accuTaint == "" {
+		setScope(inner, comp.AccuVar(), stepTaint)
+		a.visit(comp.LoopStep(), inner)
+	}
+	return a.visit(comp.Result(), inner)
+}
+
+// trusted returns "" if path is one of the trustedPaths, and path otherwise.
+func (a *taintAnalyzer) trusted(path string) string {
+	if trustedPaths[path] {
+		return ""
+	}
+	return path
+}
+
+// recordStatusPath records path if it's rooted at pipelineRun.status, and
+// returns it.
+func (a *taintAnalyzer) recordStatusPath(path string) string {
+	for _, root := range statusRoots {
+		if path == root || strings.HasPrefix(path, root+".") || strings.HasPrefix(path, root+"[") {
+			if !slices.Contains(a.statusPaths, path) {
+				a.statusPaths = append(a.statusPaths, path)
+			}
+			break
+		}
+	}
+	return path
+}
+
+// statusReferences returns the recorded status paths, omitting the paths
+// which are only the operand of a longer one, e.g. pipelineRun.status for
+// pipelineRun.status.startTime.
+func (a *taintAnalyzer) statusReferences() []string {
+	var refs []string
+	for _, path := range a.statusPaths {
+		isOperand := slices.ContainsFunc(a.statusPaths, func(other string) bool {
+			return strings.HasPrefix(other, path+".") || strings.HasPrefix(other, path+"[")
+		})
+		if !isOperand {
+			refs = append(refs, path)
+		}
+	}
+	return refs
+}
+
+func (a *taintAnalyzer) report(w TaintWarning) {
+	if a.seen[w] {
+		return
+	}
+	a.seen[w] = true
+	a.warnings = append(a.warnings, w)
+}
+
+// indexPath renders an index expression, e.g. ["x"] for a constant key or
+// [...] for a computed one.
+func indexPath(index celast.Expr) string {
+	if index.Kind() == celast.LiteralKind {
+		switch v := index.AsLiteral().Value().(type) {
+		case string:
+			return "[" + strconv.Quote(v) + "]"
+		case int64:
+			return "[" + strconv.FormatInt(v, 10) + "]"
+		}
+	}
+	return "[...]"
+}
+
+func setScope(scope map[string]string, name, taint string) {
+	if taint == "" {
+		delete(scope, name)
+		return
+	}
+	scope[name] = taint
+}
+
+func firstTaint(taint, other string) string {
+	if taint != "" {
+		return taint
+	}
+	return other
+}
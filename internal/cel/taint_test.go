@@ -0,0 +1,197 @@
+package cel
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFindTaintWarnings(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   []TaintWarning
+	}{
+		{
+			name:       "annotation passed directly",
+			expression: `priority(pipelineRun.metadata.annotations["x"])`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "label passed using field selection",
+			expression: `priority(pipelineRun.metadata.labels.tier)`,
+			expected:   []TaintWarning{{Function: "priority", Path: "pipelineRun.metadata.labels.tier"}},
+		},
+		{
+			name:       "service account from a label",
+			expression: `serviceAccount(pipelineRun.metadata.labels["sa"])`,
+			expected:   []TaintWarning{{Function: "serviceAccount", Path: `pipelineRun.metadata.labels["sa"]`}},
+		},
+		{
+			name:       "node selector from an annotation",
+			expression: `nodeSelector("kubernetes.io/arch", pipelineRun.metadata.annotations["arch"])`,
+			expected:   []TaintWarning{{Function: "nodeSelector", Path: `pipelineRun.metadata.annotations["arch"]`}},
+		},
+		{
+			name:       "annotation in a ternary branch",
+			expression: `plrNamespace == "production" ? priority("high") : priority(pipelineRun.metadata.annotations["x"])`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "annotation in a ternary branch of the argument",
+			expression: `priority(plrNamespace == "production" ? "high" : pipelineRun.metadata.annotations["x"])`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "annotation passed through replace",
+			expression: `priority(replace(pipelineRun.metadata.annotations["x"], "-", ""))`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "annotation passed through concatenation",
+			expression: `priority("konflux-" + pipelineRun.metadata.annotations["x"])`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "annotation passed through a conversion",
+			expression: `priority(string(pipelineRun.metadata.annotations["x"]))`,
+			expected:   []TaintWarning{{Function: "priority", Path: `pipelineRun.metadata.annotations["x"]`}},
+		},
+		{
+			name:       "parameter passed through a comprehension",
+			expression: `[priority(pipelineRun.spec.params.filter(p, p.name == "priority")[0].value)]`,
+			expected:   []TaintWarning{{Function: "priority", Path: "pipelineRun.spec.params[*][0].value"}},
+		},
+		{
+			name:       "label derived variable passed directly",
+			expression: `priority(pacEventType)`,
+			expected:   []TaintWarning{{Function: "priority", Path: "pacEventType"}},
+		},
+		{
+			name:       "workspace storage passed through a conversion",
+			expression: `priority(string(workspaceStorage["source"]))`,
+			expected:   []TaintWarning{{Function: "priority", Path: `workspaceStorage["source"]`}},
+		},
+		{
+			name:       "pipeline name passed through a concatenation",
+			expression: `priority(plrPipelineName + "-priority")`,
+			expected:   []TaintWarning{{Function: "priority", Path: "plrPipelineName"}},
+		},
+		{
+			name:       "constant argument",
+			expression: `priority("high")`,
+		},
+		{
+			name:       "user-controlled condition with constant branches",
+			expression: `priority(pipelineRun.metadata.annotations["x"] == "urgent" ? "high" : "low")`,
+		},
+		{
+			name:       "user-controlled value checked with a function",
+			expression: `priority(pacEventType.startsWith("push") ? "high" : "low")`,
+		},
+		{
+			name:       "namespace",
+			expression: `[priority(pipelineRun.metadata.namespace), priority(plrNamespace)]`,
+		},
+		{
+			name:       "user-controlled value passed to other functions",
+			expression: `[annotation("x", pipelineRun.metadata.annotations["x"]), priority("high")]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs[0].GetTaintWarnings()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCompileCELPrograms_EnforcedChecks(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := CompileCELPrograms([]string{
+		`priority("high")`,
+		`priority(pipelineRun.metadata.annotations["x"])`,
+	}, WithEnforcedChecks())
+	g.Expect(err).To(MatchError(ContainSubstring(
+		`expression 1 ("priority(pipelineRun.metadata.annotations[\"x\"])") is rejected: ` +
+			`the argument of priority() is derived from the user-controlled value pipelineRun.metadata.annotations["x"]`,
+	)))
+
+	programs, err := CompileCELPrograms([]string{`priority("high")`}, WithEnforcedChecks())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(programs).To(HaveLen(1))
+}
+
+func TestStatusReferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		expected   []string
+	}{
+		{
+			name:       "field of the status",
+			expression: `annotation("start", string(pipelineRun.status.startTime))`,
+			expected:   []string{"pipelineRun.status.startTime"},
+		},
+		{
+			name:       "status accessed with an index",
+			expression: `annotation("start", string(pipelineRun["status"]["startTime"]))`,
+			expected:   []string{`pipelineRun["status"]["startTime"]`},
+		},
+		{
+			name:       "status aliased through a ternary",
+			expression: `annotation("start", string((plrNamespace == "a" ? pipelineRun : {"status": {}}).status.startTime))`,
+			expected:   []string{"pipelineRun.status.startTime"},
+		},
+		{
+			name:       "status in a condition",
+			expression: `size(pipelineRun.status.conditions) > 0 ? priority("high") : priority("low")`,
+			expected:   []string{"pipelineRun.status.conditions"},
+		},
+		{
+			name:       "status in a comprehension",
+			expression: `pipelineRun.status.conditions.exists(c, c.type == "Succeeded") ? priority("high") : priority("low")`,
+			expected:   []string{"pipelineRun.status.conditions[*].type"},
+		},
+		{
+			name: "multiple references",
+			expression: `[annotation("start", string(pipelineRun.status.startTime)),
+				annotation("end", string(pipelineRun.status.completionTime))]`,
+			expected: []string{"pipelineRun.status.startTime", "pipelineRun.status.completionTime"},
+		},
+		{
+			name:       "presence test of the status",
+			expression: `has(pipelineRun.status) ? priority("high") : priority("low")`,
+		},
+		{
+			name:       "spec and metadata",
+			expression: `annotation("pipeline", pipelineRun.spec.pipelineRef.name + pipelineRun.metadata.name)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs[0].GetStatusReferences()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCompileCELPrograms_EnforcedChecksStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := CompileCELPrograms([]string{
+		`annotation("start", string(pipelineRun.status.startTime))`,
+	}, WithEnforcedChecks())
+	g.Expect(err).To(MatchError(ContainSubstring(
+		"it references pipelineRun.status.startTime, but the status is empty when PipelineRuns are admitted",
+	)))
+}
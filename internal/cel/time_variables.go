@@ -0,0 +1,75 @@
+package cel
+
+import (
+	"time"
+
+	celast "github.com/google/cel-go/common/ast"
+)
+
+// timeVariables are the variables derived from the time of the evaluation,
+// which make the results of the expressions reading them change over time.
+var timeVariables = map[string]bool{
+	"nowEpochSeconds": true,
+	"nowWeekday":      true,
+	"nowHour":         true,
+	"nowHourUTC":      true,
+}
+
+// WithClock sets the function returning the time of the evaluations, read
+// by the nowEpochSeconds, nowWeekday, nowHour and nowHourUTC variables, so
+// the evaluations can be reproduced, e.g. by the dry-runs. Defaults to
+// time.Now.
+func WithClock(now func() time.Time) CompileOption {
+	return func(o *compileOptions) {
+		o.now = now
+	}
+}
+
+// WithTimeZone sets the time zone of the nowWeekday and nowHour variables.
+// Defaults to UTC.
+func WithTimeZone(location *time.Location) CompileOption {
+	return func(o *compileOptions) {
+		o.location = location
+	}
+}
+
+// timeVars returns the values of the time variables at now, the weekday and
+// the hour of nowWeekday and nowHour being those of now in location.
+func timeVars(now time.Time, location *time.Location) map[string]interface{} {
+	local := now.In(location)
+	return map[string]interface{}{
+		"nowEpochSeconds": now.Unix(),
+		"nowWeekday":      local.Weekday().String(),
+		"nowHour":         int64(local.Hour()),
+		"nowHourUTC":      int64(now.UTC().Hour()),
+	}
+}
+
+// currentTime returns the time of the evaluation, according to the clock
+// of the program.
+func (cp *CompiledProgram) currentTime() time.Time {
+	if cp.now == nil {
+		return time.Now()
+	}
+	return cp.now()
+}
+
+// timeZone returns the time zone of the program, UTC by default.
+func (cp *CompiledProgram) timeZone() *time.Location {
+	if cp.location == nil {
+		return time.UTC
+	}
+	return cp.location
+}
+
+// referencesTime returns whether the checked AST reads one of the
+// timeVariables, whose value differs between the evaluations of the same
+// PipelineRun.
+func referencesTime(ast *celast.AST) bool {
+	for _, reference := range ast.ReferenceMap() {
+		if timeVariables[reference.Name] {
+			return true
+		}
+	}
+	return false
+}
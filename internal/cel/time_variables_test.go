@@ -0,0 +1,107 @@
+package cel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// saturdayNight is a Saturday at 23:30 UTC, already Sunday in Prague.
+var saturdayNight = time.Date(2025, time.June, 7, 23, 30, 0, 0, time.UTC)
+
+func TestTimeVariables(t *testing.T) {
+	g := NewWithT(t)
+	prague, err := time.LoadLocation("Europe/Prague")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	expressions := []string{`[
+		label("epoch", nowEpochSeconds),
+		label("weekday", nowWeekday),
+		label("hour", nowHour),
+		label("hour-utc", nowHourUTC),
+	]`}
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+	evaluate := func(opts ...CompileOption) map[string]string {
+		programs, err := CompileCELPrograms(expressions, append(opts, WithClock(func() time.Time { return saturdayNight }))...)
+		g.Expect(err).NotTo(HaveOccurred())
+		mutations, err := programs[0].Evaluate(pipelineRun)
+		g.Expect(err).NotTo(HaveOccurred())
+		labels := map[string]string{}
+		for _, mutation := range mutations {
+			labels[mutation.Key] = mutation.Value
+		}
+		return labels
+	}
+
+	g.Expect(evaluate()).To(Equal(map[string]string{
+		"epoch":    "1749339000",
+		"weekday":  "Saturday",
+		"hour":     "23",
+		"hour-utc": "23",
+	}))
+	g.Expect(evaluate(WithTimeZone(prague))).To(Equal(map[string]string{
+		"epoch":    "1749339000",
+		"weekday":  "Sunday",
+		"hour":     "1",
+		"hour-utc": "23",
+	}))
+}
+
+func TestTimeVariables_BusinessHours(t *testing.T) {
+	g := NewWithT(t)
+
+	now := saturdayNight
+	programs, err := CompileCELPrograms([]string{
+		`nowWeekday in ["Saturday", "Sunday"] || nowHour < 8 || nowHour >= 18 ? priority("low") : priority("high")`,
+	}, WithClock(func() time.Time { return now }))
+	g.Expect(err).NotTo(HaveOccurred())
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+
+	mutations, err := programs[0].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(HaveField("Value", "low")))
+
+	now = time.Date(2025, time.June, 9, 10, 0, 0, 0, time.UTC)
+	mutations, err = programs[0].Evaluate(pipelineRun)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(mutations).To(ConsistOf(HaveField("Value", "high")))
+}
+
+func TestReferencesTime(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{expression: `label("team", "a")`},
+		{expression: `label("namespace", plrNamespace)`},
+		{expression: `nowWeekday == "Sunday" ? [priority("low")] : []`, expected: true},
+		{expression: `label("hour", nowHour)`, expected: true},
+		{expression: `[label("hour", nowHourUTC)]`, expected: true},
+		{expression: `nowEpochSeconds > 0 ? [label("x", "y")] : []`, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileCELPrograms([]string{tt.expression})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs[0].referencesTime).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestCELMutator_EvaluationCacheDisabledByTime(t *testing.T) {
+	g := NewWithT(t)
+
+	programs, err := CompileCELPrograms([]string{
+		`label("team", "a")`,
+		`nowHour < 8 ? [priority("low")] : []`,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(NewCELMutator(programs[:1], WithEvaluationCache(logr.Discard(), 16)).cache).NotTo(BeNil())
+	g.Expect(NewCELMutator(programs, WithEvaluationCache(logr.Discard(), 16)).cache).To(BeNil())
+}
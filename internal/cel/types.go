@@ -4,6 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
 )
 
 // MutationType represents the type of mutation to perform
@@ -11,14 +17,70 @@ type MutationType string
 
 // Valid mutation types
 const (
-	MutationTypeAnnotation MutationType = "annotation"
-	MutationTypeLabel      MutationType = "label"
-	MutationTypeResource   MutationType = "resource"
+	MutationTypeAnnotation       MutationType = "annotation"
+	MutationTypeLabel            MutationType = "label"
+	MutationTypeResource         MutationType = "resource"
+	MutationTypeTimeout          MutationType = "timeout"
+	MutationTypeAppendAnnotation MutationType = "appendAnnotation"
+	MutationTypePodSet           MutationType = "podSet"
+	MutationTypeServiceAccount   MutationType = "serviceAccount"
+	MutationTypeNodeSelector     MutationType = "nodeSelector"
+)
+
+// registeredMutationType is a mutation type with the JSON pointers of the
+// fields of the PipelineRuns it may change.
+type registeredMutationType struct {
+	mutationType MutationType
+	paths        []string
+}
+
+// mutationTypes are the registered mutation types, in the order the
+// mutations are applied. Every mutation type must be registered: the
+// unregistered ones are rejected by IsValid, the JSON unmarshalling and the
+// evaluations.
+var mutationTypes = []registeredMutationType{
+	{MutationTypeAnnotation, []string{"/metadata/annotations"}},
+	{MutationTypeLabel, []string{"/metadata/labels"}},
+	{MutationTypeResource, []string{"/metadata/annotations"}},
+	{MutationTypeTimeout, []string{"/spec/timeouts"}},
+	{MutationTypeAppendAnnotation, []string{"/metadata/annotations"}},
+	{MutationTypePodSet, []string{"/metadata/annotations"}},
+	{MutationTypeServiceAccount, []string{"/spec/taskRunTemplate/serviceAccountName"}},
+	// The pod template is added when the PipelineRun has none
+	{MutationTypeNodeSelector, []string{"/spec/taskRunTemplate/podTemplate"}},
+}
+
+// ServiceAccountKey is the key of service account mutations, which only
+// set the service account name of the TaskRuns.
+const ServiceAccountKey = "serviceAccountName"
+
+// Timeout kinds, the keys of timeout mutations. They match the fields of
+// the timeouts of the PipelineRun spec.
+const (
+	TimeoutKindPipeline = "pipeline"
+	TimeoutKindTasks    = "tasks"
+	TimeoutKindFinally  = "finally"
 )
 
-// IsValid checks if the mutation type is valid
+// TimeoutKinds returns all valid timeout kinds
+func TimeoutKinds() []string {
+	return []string{TimeoutKindPipeline, TimeoutKindTasks, TimeoutKindFinally}
+}
+
+// IsValid checks if the mutation type is registered
 func (mt MutationType) IsValid() bool {
-	return slices.Contains(ValidTypes(), mt)
+	return mt.registration() != nil
+}
+
+// registration returns the registration of the mutation type, nil when it
+// isn't registered.
+func (mt MutationType) registration() *registeredMutationType {
+	for i := range mutationTypes {
+		if mutationTypes[i].mutationType == mt {
+			return &mutationTypes[i]
+		}
+	}
+	return nil
 }
 
 // String returns the string representation of the mutation type
@@ -26,9 +88,29 @@ func (mt MutationType) String() string {
 	return string(mt)
 }
 
-// ValidTypes returns all valid mutation types
+// ValidTypes returns all the registered mutation types, in the order the
+// mutations are applied
 func ValidTypes() []MutationType {
-	return []MutationType{MutationTypeAnnotation, MutationTypeLabel, MutationTypeResource}
+	types := make([]MutationType, 0, len(mutationTypes))
+	for _, registered := range mutationTypes {
+		types = append(types, registered.mutationType)
+	}
+	return types
+}
+
+// invalidMutationTypeError returns the error of the mutation types which
+// aren't registered.
+func invalidMutationTypeError(mt MutationType) error {
+	return fmt.Errorf("invalid mutation type: %q, must be one of: %v", string(mt), ValidTypes())
+}
+
+// Paths returns the JSON pointers of the fields of the PipelineRuns the
+// mutation type may change.
+func (mt MutationType) Paths() []string {
+	if registered := mt.registration(); registered != nil {
+		return registered.paths
+	}
+	return nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface with validation
@@ -40,7 +122,7 @@ func (mt *MutationType) UnmarshalJSON(data []byte) error {
 
 	mutationType := MutationType(s)
 	if !mutationType.IsValid() {
-		return fmt.Errorf("invalid mutation type: %q, must be one of: %v", s, ValidTypes())
+		return invalidMutationTypeError(mutationType)
 	}
 
 	*mt = mutationType
@@ -57,12 +139,15 @@ type MutationRequest struct {
 	Type  MutationType `json:"type"`
 	Key   string       `json:"key"`
 	Value string       `json:"value"`
+	// Separator separates the entries of the annotation of append
+	// annotation mutations.
+	Separator string `json:"separator,omitempty"`
 }
 
 // Validate ensures the MutationRequest is valid
 func (mr *MutationRequest) Validate() error {
 	if !mr.Type.IsValid() {
-		return fmt.Errorf("invalid mutation type: %v", mr.Type)
+		return invalidMutationTypeError(mr.Type)
 	}
 	if mr.Key == "" {
 		return fmt.Errorf("mutation key cannot be empty")
@@ -70,5 +155,79 @@ func (mr *MutationRequest) Validate() error {
 	if mr.Value == "" {
 		return fmt.Errorf("mutation value cannot be empty")
 	}
+	switch mr.Type {
+	case MutationTypeTimeout:
+		return validateTimeout(mr.Key, mr.Value)
+	case MutationTypeAppendAnnotation:
+		if mr.Separator == "" {
+			return fmt.Errorf("mutation separator cannot be empty")
+		}
+	case MutationTypePodSet:
+		_, err := parsePodSet(mr.Key, mr.Value)
+		return err
+	case MutationTypeServiceAccount:
+		if mr.Key != ServiceAccountKey {
+			return fmt.Errorf("invalid service account key %q, must be %s", mr.Key, ServiceAccountKey)
+		}
+		return validateServiceAccount(mr.Value)
+	case MutationTypeNodeSelector:
+		return validateNodeSelector(mr.Key, mr.Value)
+	}
 	return nil
 }
+
+// ValidateProtected validates the MutationRequest, like Validate, and
+// checks that it doesn't set one of the protected labels or annotations.
+// Nil keys protect no key.
+func (mr *MutationRequest) ValidateProtected(keys *ProtectedKeys) error {
+	if err := mr.Validate(); err != nil {
+		return err
+	}
+	return keys.check(mr.Type, mr.Key)
+}
+
+// parsePodSet parses the value of a pod set mutation, the JSON of the
+// PodSetSpec named by the key, and validates it.
+func parsePodSet(name, value string) (common.PodSetSpec, error) {
+	var podSet common.PodSetSpec
+	if err := json.Unmarshal([]byte(value), &podSet); err != nil {
+		return podSet, fmt.Errorf("invalid pod set %q: %w", name, err)
+	}
+	if podSet.Name != name {
+		return podSet, fmt.Errorf("the pod set %q is named %q", name, podSet.Name)
+	}
+	return podSet, podSet.Validate()
+}
+
+// validateTimeout checks that kind is a timeout kind and that value is a
+// non-negative duration.
+func validateTimeout(kind, value string) error {
+	if !slices.Contains(TimeoutKinds(), kind) {
+		return fmt.Errorf("invalid timeout kind %q, must be one of: %v", kind, TimeoutKinds())
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s timeout: %w", kind, err)
+	}
+	if duration < 0 {
+		return fmt.Errorf("invalid %s timeout: %s is negative", kind, value)
+	}
+	return nil
+}
+
+// validateServiceAccount checks that name is a valid service account name.
+func validateServiceAccount(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid service account name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validateNodeSelector checks that the key and the value of a node
+// selector entry follow the rules of the labels.
+func validateNodeSelector(key, value string) error {
+	if err := validateKey(key, "node selector"); err != nil {
+		return err
+	}
+	return validateLabelValue(value)
+}
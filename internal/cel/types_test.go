@@ -2,6 +2,12 @@ package cel
 
 import (
 	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -16,6 +22,10 @@ func TestMutationType_IsValid(t *testing.T) {
 		{"valid annotation", MutationTypeAnnotation, true},
 		{"valid label", MutationTypeLabel, true},
 		{"valid resource", MutationTypeResource, true},
+		{"valid timeout", MutationTypeTimeout, true},
+		{"valid append annotation", MutationTypeAppendAnnotation, true},
+		{"valid service account", MutationTypeServiceAccount, true},
+		{"valid node selector", MutationTypeNodeSelector, true},
 		{"invalid type", MutationType("invalid"), false},
 		{"empty type", MutationType(""), false},
 	}
@@ -29,6 +39,49 @@ func TestMutationType_IsValid(t *testing.T) {
 	}
 }
 
+// TestMutationType_Registered checks that every MutationType constant
+// declared in the package is registered, so new mutation types aren't
+// rejected by the validation.
+func TestMutationType_Registered(t *testing.T) {
+	g := NewWithT(t)
+	packages, err := parser.ParseDir(token.NewFileSet(), ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var declared []MutationType
+	for _, file := range packages["cel"].Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			spec, ok := node.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			if ident, ok := spec.Type.(*ast.Ident); !ok || ident.Name != "MutationType" {
+				return true
+			}
+			for _, value := range spec.Values {
+				literal, ok := value.(*ast.BasicLit)
+				g.Expect(ok).To(BeTrue(), "the MutationType constants must be string literals")
+				unquoted, err := strconv.Unquote(literal.Value)
+				g.Expect(err).NotTo(HaveOccurred())
+				declared = append(declared, MutationType(unquoted))
+			}
+			return true
+		})
+	}
+
+	g.Expect(declared).To(ContainElements(MutationTypeAnnotation, MutationTypeNodeSelector))
+	g.Expect(ValidTypes()).To(ConsistOf(declared))
+}
+
+func TestMutationType_Paths(t *testing.T) {
+	g := NewWithT(t)
+	for _, mt := range ValidTypes() {
+		g.Expect(mt.Paths()).NotTo(BeEmpty(), mt.String())
+	}
+	g.Expect(MutationType("invalid").Paths()).To(BeEmpty())
+}
+
 func TestMutationType_JSON(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -94,6 +147,26 @@ func TestMutationRequest_Validate(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "valid append annotation",
+			request: MutationRequest{
+				Type:      MutationTypeAppendAnnotation,
+				Key:       "platforms",
+				Value:     "linux-amd64",
+				Separator: ",",
+			},
+			expectErr: false,
+		},
+		{
+			name: "append annotation without separator",
+			request: MutationRequest{
+				Type:  MutationTypeAppendAnnotation,
+				Key:   "platforms",
+				Value: "linux-amd64",
+			},
+			expectErr: true,
+			errMsg:    "mutation separator cannot be empty",
+		},
 		{
 			name: "valid label",
 			request: MutationRequest{
@@ -120,7 +193,7 @@ func TestMutationRequest_Validate(t *testing.T) {
 				Value: "test-value",
 			},
 			expectErr: true,
-			errMsg:    "invalid mutation type: invalid",
+			errMsg:    `invalid mutation type: "invalid"`,
 		},
 		{
 			name: "empty key",
@@ -162,6 +235,67 @@ func TestMutationRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestMutationRequest_Validate_Timeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		value  string
+		errMsg string
+	}{
+		{name: "pipeline timeout", key: TimeoutKindPipeline, value: "1h30m"},
+		{name: "tasks timeout", key: TimeoutKindTasks, value: "45m"},
+		{name: "finally timeout", key: TimeoutKindFinally, value: "0s"},
+		{name: "invalid kind", key: "task", value: "1h", errMsg: `invalid timeout kind "task"`},
+		{name: "invalid duration", key: TimeoutKindPipeline, value: "1 hour", errMsg: "invalid pipeline timeout"},
+		{name: "negative duration", key: TimeoutKindTasks, value: "-5m", errMsg: "-5m is negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := (&MutationRequest{Type: MutationTypeTimeout, Key: tt.key, Value: tt.value}).Validate()
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
+func TestMutationRequest_Validate_TaskRunTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mt     MutationType
+		key    string
+		value  string
+		errMsg string
+	}{
+		{name: "service account", mt: MutationTypeServiceAccount, key: ServiceAccountKey, value: "release-pipeline"},
+		{name: "service account with another key", mt: MutationTypeServiceAccount, key: "serviceAccount",
+			value: "release-pipeline", errMsg: `invalid service account key "serviceAccount"`},
+		{name: "invalid service account", mt: MutationTypeServiceAccount, key: ServiceAccountKey,
+			value: "release/pipeline", errMsg: `invalid service account name "release/pipeline"`},
+		{name: "node selector", mt: MutationTypeNodeSelector, key: "kubernetes.io/arch", value: "arm64"},
+		{name: "invalid node selector key", mt: MutationTypeNodeSelector, key: "-arch", value: "arm64",
+			errMsg: "node selector key '-arch' is invalid"},
+		{name: "invalid node selector value", mt: MutationTypeNodeSelector, key: "kubernetes.io/arch",
+			value: "arm64/v8", errMsg: "label value 'arm64/v8' is invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := (&MutationRequest{Type: tt.mt, Key: tt.key, Value: tt.value}).Validate()
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
 func TestMutationRequest_Usage(t *testing.T) {
 	g := NewWithT(t)
 
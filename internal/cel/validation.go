@@ -0,0 +1,207 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// OldPipelineRunVariable is the variable holding the PipelineRun before the
+// update in the validation expressions.
+const OldPipelineRunVariable = "oldPipelineRun"
+
+// ValidationProgram is a compiled validation expression, denying the
+// updates of the PipelineRuns for which it returns false.
+type ValidationProgram struct {
+	program    cel.Program
+	expression string
+}
+
+// ValidationResult is the result of a validation expression.
+type ValidationResult struct {
+	// Expression is the validation expression.
+	Expression string
+	// Allowed is whether the update is allowed by the expression.
+	Allowed bool
+	// Message explains why the update is denied, empty when it's allowed.
+	Message string
+}
+
+// CompileValidationPrograms compiles the validation expressions. They're
+// evaluated with the variables of the mutation expressions, pipelineRun
+// being the updated PipelineRun, and oldPipelineRun, the PipelineRun before
+// the update. They can use the functions of the mutation expressions which
+// don't mutate the PipelineRun, and must return a bool, or the result of
+// check(condition, message) to explain the denials. It fails at the first
+// expression which doesn't compile, without returning any program.
+func CompileValidationPrograms(expressions []string) ([]*ValidationProgram, error) {
+	if len(expressions) == 0 {
+		return nil, fmt.Errorf("validation expressions list cannot be empty")
+	}
+	env, err := newValidationEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL validation environment: %w", err)
+	}
+
+	programs := make([]*ValidationProgram, 0, len(expressions))
+	for i, expr := range expressions {
+		if expr == "" {
+			return nil, fmt.Errorf("validation expression %d cannot be empty", i)
+		}
+		program, err := compileValidationExpression(env, expr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile validation expression %d (%q): %w", i, expr, err)
+		}
+		programs = append(programs, program)
+	}
+	return programs, nil
+}
+
+// newValidationEnvironment returns the environment of the validation
+// expressions: the one of the mutation expressions without the mutation
+// functions, with the oldPipelineRun variable and the check() function.
+func newValidationEnvironment() (*cel.Env, error) {
+	var options []cel.EnvOption
+	for _, variable := range variableDeclarations() {
+		options = append(options, cel.Variable(variable.Name, variable.celType))
+	}
+	options = append(options, cel.Variable(OldPipelineRunVariable, cel.MapType(cel.StringType, cel.AnyType)))
+	for _, function := range functionDeclarations(keyValidator{}) {
+		if !function.mutates() {
+			options = append(options, function.option)
+		}
+	}
+	options = append(options, createCheckFunction("check"), cel.StdLib())
+	return cel.NewEnv(options...)
+}
+
+// mutates returns whether the function returns a MutationRequest.
+func (f functionDeclaration) mutates() bool {
+	return strings.HasSuffix(f.Signature, "-> MutationRequest")
+}
+
+// validationResultType is the type returned by check().
+var validationResultType = cel.MapType(cel.StringType, cel.AnyType)
+
+// createCheckFunction creates the CEL function returning the result of a
+// validation: check(condition, message) allows the update when condition
+// is true, and denies it with message otherwise.
+func createCheckFunction(name string) cel.EnvOption {
+	return cel.Function(
+		name,
+		cel.Overload(
+			name+"_bool_string",
+			[]*cel.Type{cel.BoolType, cel.StringType},
+			validationResultType,
+			cel.BinaryBinding(func(condition, message ref.Val) ref.Val {
+				return types.NewStringInterfaceMap(types.DefaultTypeAdapter, map[string]interface{}{
+					"allowed": condition.Value(),
+					"message": message.Value(),
+				})
+			}),
+		),
+	)
+}
+
+// compileValidationExpression compiles a validation expression, which must
+// return a bool or the result of check().
+func compileValidationExpression(env *cel.Env, expression string) (*ValidationProgram, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("type checking failed: %w", issues.Err())
+	}
+	if outputType := ast.OutputType(); !outputType.IsExactType(cel.BoolType) && !outputType.IsExactType(validationResultType) {
+		return nil, fmt.Errorf("invalid return type: expression must return bool or check(condition, message), got %v", outputType)
+	}
+	program, err := env.Program(ast, cel.InterruptCheckFrequency(interruptCheckFrequency))
+	if err != nil {
+		return nil, fmt.Errorf("program creation failed: %w", err)
+	}
+	return &ValidationProgram{program: program, expression: expression}, nil
+}
+
+// GetExpression returns the original CEL expression for debugging
+func (vp *ValidationProgram) GetExpression() string {
+	return vp.expression
+}
+
+// EvaluateValidations evaluates the validation programs for the update of
+// oldPipelineRun to pipelineRun, until the context is done, and returns
+// their results, in order. The expressions returning false, without
+// message, are explained by a message quoting them. The time variables are
+// those of the current time, in UTC.
+func EvaluateValidations(
+	ctx context.Context,
+	programs []*ValidationProgram,
+	pipelineRun, oldPipelineRun *tekv1.PipelineRun,
+) ([]ValidationResult, error) {
+	if pipelineRun == nil || oldPipelineRun == nil {
+		return nil, fmt.Errorf("pipelineRun and oldPipelineRun cannot be nil")
+	}
+	pipelineRunMap, err := structToCELMap(pipelineRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert PipelineRun to map: %w", err)
+	}
+	oldPipelineRunMap, err := structToCELMap(oldPipelineRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert the old PipelineRun to map: %w", err)
+	}
+	vars := buildVars(pipelineRun, pipelineRunMap, nil, time.Now(), time.UTC)
+	vars[OldPipelineRunVariable] = oldPipelineRunMap
+	vars = orderedVars(vars)
+
+	results := make([]ValidationResult, 0, len(programs))
+	for _, program := range programs {
+		result, err := program.evaluate(ctx, vars)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// evaluate executes the program with the variables until the context is
+// done.
+func (vp *ValidationProgram) evaluate(ctx context.Context, vars map[string]interface{}) (ValidationResult, error) {
+	result := ValidationResult{Expression: vp.expression}
+	out, _, err := vp.program.ContextEval(ctx, vars)
+	if err == nil {
+		// The functions aren't interrupted, only the comprehensions are
+		err = ctx.Err()
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, fmt.Errorf("evaluation of CEL validation expression %q aborted: %w", vp.expression, ctxErr)
+		}
+		return result, fmt.Errorf("failed to evaluate CEL validation expression %q: %w", vp.expression, err)
+	}
+
+	switch v := out.(type) {
+	case types.Bool:
+		result.Allowed = bool(v)
+	case traits.Mapper:
+		allowed, allowedOk := v.Get(types.String("allowed")).(types.Bool)
+		message, messageOk := v.Get(types.String("message")).(types.String)
+		if !allowedOk || !messageOk {
+			return result, fmt.Errorf("CEL validation expression %q must return bool or check(condition, message)", vp.expression)
+		}
+		result.Allowed = bool(allowed)
+		result.Message = string(message)
+	default:
+		return result, fmt.Errorf("CEL validation expression %q returned %s, want bool", vp.expression, out.Type().TypeName())
+	}
+	if result.Allowed {
+		result.Message = ""
+	} else if result.Message == "" {
+		result.Message = fmt.Sprintf("the update is denied by the validation expression %q", vp.expression)
+	}
+	return result, nil
+}
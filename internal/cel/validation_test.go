@@ -0,0 +1,156 @@
+package cel
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// keepQueueLabel denies the removal of the queue label.
+const keepQueueLabel = `!("kueue.x-k8s.io/queue-name" in oldPipelineRun.metadata.labels) ||
+	"kueue.x-k8s.io/queue-name" in pipelineRun.metadata.labels`
+
+func TestCompileValidationPrograms(t *testing.T) {
+	tests := []struct {
+		name        string
+		expressions []string
+		errMsg      string
+	}{
+		{
+			name:        "bool",
+			expressions: []string{keepQueueLabel},
+		},
+		{
+			name:        "check",
+			expressions: []string{`check(pipelineRun.metadata.name == oldPipelineRun.metadata.name, "renamed")`},
+		},
+		{
+			name:        "non-mutating functions",
+			expressions: []string{`split(plrNamespace, "-")[0] != "system"`},
+		},
+		{
+			name:        "empty list",
+			expressions: nil,
+			errMsg:      "validation expressions list cannot be empty",
+		},
+		{
+			name:        "empty expression",
+			expressions: []string{"true", ""},
+			errMsg:      "validation expression 1 cannot be empty",
+		},
+		{
+			name:        "mutation",
+			expressions: []string{`label("env", "prod")`},
+			errMsg:      "undeclared reference to 'label'",
+		},
+		{
+			name:        "string",
+			expressions: []string{`"denied"`},
+			errMsg:      "expression must return bool or check(condition, message), got string",
+		},
+		{
+			name:        "map",
+			expressions: []string{`{"allowed": true}`},
+			errMsg:      "expression must return bool or check(condition, message)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			programs, err := CompileValidationPrograms(tt.expressions)
+			if tt.errMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(programs).To(HaveLen(len(tt.expressions)))
+		})
+	}
+}
+
+func TestEvaluateValidations(t *testing.T) {
+	oldPipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "build",
+			Namespace: "tenant",
+			Labels:    map[string]string{common.QueueLabel: "pipelines-queue", "app": "web"},
+		},
+	}
+	const renamedQueue = `check(!("kueue.x-k8s.io/queue-name" in pipelineRun.metadata.labels) ||
+		pipelineRun.metadata.labels["kueue.x-k8s.io/queue-name"] == oldPipelineRun.metadata.labels["kueue.x-k8s.io/queue-name"],
+		"the queue of " + pipelineRun.metadata.name + " can't be changed")`
+
+	tests := []struct {
+		name     string
+		update   func(*tekv1.PipelineRun)
+		expected []ValidationResult
+	}{
+		{
+			name:   "unrelated edit",
+			update: func(plr *tekv1.PipelineRun) { plr.Labels["app"] = "api" },
+			expected: []ValidationResult{
+				{Expression: keepQueueLabel, Allowed: true},
+				{Expression: renamedQueue, Allowed: true},
+			},
+		},
+		{
+			name:   "removed queue label",
+			update: func(plr *tekv1.PipelineRun) { delete(plr.Labels, common.QueueLabel) },
+			expected: []ValidationResult{
+				{
+					Expression: keepQueueLabel,
+					Message:    fmt.Sprintf("the update is denied by the validation expression %q", keepQueueLabel),
+				},
+				{Expression: renamedQueue, Allowed: true},
+			},
+		},
+		{
+			name:   "changed queue label",
+			update: func(plr *tekv1.PipelineRun) { plr.Labels[common.QueueLabel] = "other-queue" },
+			expected: []ValidationResult{
+				{Expression: keepQueueLabel, Allowed: true},
+				{Expression: renamedQueue, Message: "the queue of build can't be changed"},
+			},
+		},
+	}
+
+	programs, err := CompileValidationPrograms([]string{keepQueueLabel, renamedQueue})
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pipelineRun := oldPipelineRun.DeepCopy()
+			tt.update(pipelineRun)
+			results, err := EvaluateValidations(context.Background(), programs, pipelineRun, oldPipelineRun)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(results).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestEvaluateValidations_Errors(t *testing.T) {
+	g := NewWithT(t)
+	pipelineRun := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+
+	programs, err := CompileValidationPrograms([]string{`pipelineRun.metadata.labels["missing"] == "x"`})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = EvaluateValidations(context.Background(), programs, pipelineRun, pipelineRun)
+	g.Expect(err).To(MatchError(ContainSubstring("failed to evaluate CEL validation expression")))
+
+	_, err = EvaluateValidations(context.Background(), programs, pipelineRun, nil)
+	g.Expect(err).To(MatchError("pipelineRun and oldPipelineRun cannot be nil"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	programs, err = CompileValidationPrograms([]string{"true"})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = EvaluateValidations(ctx, programs, pipelineRun, pipelineRun)
+	g.Expect(err).To(MatchError(context.Canceled))
+}
@@ -19,4 +19,36 @@ package common
 const (
 	ManagedByMultiKueueLabel = "kueue.x-k8s.io/multikueue"
 	QueueLabel               = "kueue.x-k8s.io/queue-name"
+	// AdmissionUIDAnnotation holds the UID of the admission request which
+	// created the PipelineRun.
+	AdmissionUIDAnnotation = "kueue.konflux-ci.dev/admission-uid"
+	// ConfigAnnotation holds the configuration, stable or canary, which
+	// mutated the PipelineRun, when a canary configuration is deployed.
+	ConfigAnnotation = "kueue.konflux-ci.dev/config"
+	// ConfigHashAnnotation holds the hash of the configurations loaded by
+	// the webhook which admitted the PipelineRun.
+	ConfigHashAnnotation = "kueue.konflux-ci.dev/config-hash"
+	// AppliedMutationsAnnotation lists the labels, annotations and spec
+	// fields changed by the admission of the PipelineRun, when the audit
+	// annotation is enabled.
+	AppliedMutationsAnnotation = "kueue.konflux-ci.dev/applied-mutations"
+	// DefaultedQueueAnnotation holds the default queue the PipelineRun was
+	// queued to by the webhook, when requeueOnQueueChange is enabled.
+	DefaultedQueueAnnotation = "kueue.konflux-ci.dev/defaulted-queue"
+	// AdmissionDecisionAnnotation holds the reason of the decision of the
+	// webhook which queued the PipelineRun, when annotateDecision is
+	// enabled.
+	AdmissionDecisionAnnotation = "kueue.konflux-ci.dev/admission-decision"
+	// ForceDeleteAnnotation, set to "true", allows deleting the pending
+	// PipelineRun when the deletion protection denies it.
+	ForceDeleteAnnotation = "kueue.konflux-ci.dev/force-delete"
+	// CleanupAnnotation marks the Workloads of the pending PipelineRuns
+	// deleted while the deletion protection annotates them, which the
+	// controller deletes once the PipelineRun is gone. It holds the time of
+	// the deletion.
+	CleanupAnnotation = "kueue.konflux-ci.dev/cleanup"
+	// ManagedLabel, set to "true", marks the PipelineRuns queued by the
+	// webhook when it only manages the PipelineRuns matching the manage
+	// selector. The controller ignores the PipelineRuns without it.
+	ManagedLabel = "kueue.konflux-ci.dev/managed"
 )
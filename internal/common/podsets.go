@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// PodSetsAnnotation holds, as a JSON array of PodSetSpecs, the PodSets
+	// of the Workload of the PipelineRun beyond the default one.
+	PodSetsAnnotation = "kueue.konflux-ci.dev/podsets"
+	// DefaultPodSetName is the name of the PodSet every Workload of a
+	// PipelineRun has, requesting the resources of the resource
+	// annotations.
+	DefaultPodSetName = "pod-set-1"
+	// MaxExtraPodSets is the number of PodSets a Workload can have beyond
+	// the default one, Kueue accepting at most 8 PodSets.
+	MaxExtraPodSets = 7
+)
+
+// PodSetSpec is an additional PodSet of the Workload of a PipelineRun, so
+// Kueue can assign it a different ResourceFlavor.
+type PodSetSpec struct {
+	Name  string `json:"name"`
+	Count int32  `json:"count"`
+	// Requests are the requests of each pod of the PodSet, by resource
+	// name.
+	Requests map[string]string `json:"requests"`
+}
+
+// Validate checks that the name is a DNS label other than the default
+// PodSet's, that the count is positive and that the requests are
+// non-negative quantities.
+func (s PodSetSpec) Validate() error {
+	if errs := validation.IsDNS1123Label(s.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid pod set name %q: %s", s.Name, strings.Join(errs, "; "))
+	}
+	if s.Name == DefaultPodSetName {
+		return fmt.Errorf("the pod set name %q is reserved", s.Name)
+	}
+	if s.Count < 1 {
+		return fmt.Errorf("the count of the pod set %q must be positive, got %d", s.Name, s.Count)
+	}
+	for name, value := range s.Requests {
+		if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+			return fmt.Errorf("invalid resource name %q in the pod set %q: %s", name, s.Name, strings.Join(errs, "; "))
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid request of %s in the pod set %q: %w", name, s.Name, err)
+		}
+		if quantity.Sign() < 0 {
+			return fmt.Errorf("the request of %s in the pod set %q must not be negative, got %q", name, s.Name, value)
+		}
+	}
+	return nil
+}
+
+// ParsePodSets parses and validates the value of PodSetsAnnotation. The
+// names of the PodSets must be distinct.
+func ParsePodSets(value string) ([]PodSetSpec, error) {
+	var podSets []PodSetSpec
+	if err := json.Unmarshal([]byte(value), &podSets); err != nil {
+		return nil, fmt.Errorf("invalid pod sets: %w", err)
+	}
+	if len(podSets) > MaxExtraPodSets {
+		return nil, fmt.Errorf("too many pod sets, got %d, at most %d are allowed", len(podSets), MaxExtraPodSets)
+	}
+	var names []string
+	for _, podSet := range podSets {
+		if err := podSet.Validate(); err != nil {
+			return nil, err
+		}
+		if slices.Contains(names, podSet.Name) {
+			return nil, fmt.Errorf("duplicate pod set %q", podSet.Name)
+		}
+		names = append(names, podSet.Name)
+	}
+	return podSets, nil
+}
@@ -16,12 +16,1187 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
 type Config struct {
-	QueueName          string `json:"queueName,omitempty"`
-	MultiKueueOverride bool   `json:"multiKueueOverride,omitempty"`
-	CEL                CEL    `json:"cel,omitempty"`
+	QueueName          string     `json:"queueName,omitempty"`
+	MultiKueueOverride bool       `json:"multiKueueOverride,omitempty"`
+	CEL                CEL        `json:"cel,omitempty"`
+	Controller         Controller `json:"controller,omitempty"`
+	Logging            Logging    `json:"logging,omitempty"`
+	Webhook            Webhook    `json:"webhook,omitempty"`
+	// ResourceAnnotationPrefixMigration, when set, renames the prefix of
+	// the resource annotations without breaking the PipelineRuns created
+	// before the rename.
+	ResourceAnnotationPrefixMigration *ResourceAnnotationPrefixMigration `json:"resourceAnnotationPrefixMigration,omitempty"`
+	// ManagedNamespaces, when set, selects the namespaces whose PipelineRuns
+	// are queued. The webhook admits the PipelineRuns of the other
+	// namespaces untouched and the controller ignores them, so they run
+	// immediately. All the namespaces are managed by default.
+	ManagedNamespaces *metav1.LabelSelector `json:"managedNamespaces,omitempty"`
+	// CanaryPercent is the percentage of the admissions served by the CEL
+	// configuration of the canary file, when there's one. The admissions
+	// are assigned by namespace and generateName.
+	CanaryPercent int `json:"canaryPercent,omitempty"`
+	// RequeueOnQueueChange moves the pending PipelineRuns queued to the
+	// former queueName to the new one when it changes. The webhook records
+	// the queue it defaults the PipelineRuns to, so the PipelineRuns whose
+	// queue was chosen by their author aren't moved.
+	RequeueOnQueueChange bool `json:"requeueOnQueueChange,omitempty"`
+	// DefaultResources lists the resources requested by default by the
+	// PipelineRuns of each priority class.
+	DefaultResources DefaultResources `json:"defaultResources,omitempty"`
+	// AnnotationMigrations rename the prefixes of the annotations of the
+	// PipelineRuns created with a former convention, before the CEL
+	// expressions are evaluated.
+	AnnotationMigrations AnnotationMigrations `json:"annotationMigrations,omitempty"`
+	// Manage, when set, restricts the PipelineRuns queued by tekton-kueue.
+	Manage *Manage `json:"manage,omitempty"`
+}
+
+// Manage selects the PipelineRuns queued by tekton-kueue.
+type Manage struct {
+	// Selector selects the PipelineRuns queued by the webhook by their
+	// labels, e.g. the ones created by Pipelines-as-Code. The webhook
+	// admits the other PipelineRuns untouched, and marks the ones it queues
+	// with the managed label, so the controller ignores the others. The
+	// webhook reloads it without restarting.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// AnnotationMigration renames the annotations starting with the prefix From
+// so they start with the prefix To, e.g. from
+// "konflux-ci.dev/build-requests-" to "kueue.konflux-ci.dev/requests-".
+type AnnotationMigration struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AnnotationMigrations are the annotation migrations, applied in order.
+type AnnotationMigrations []AnnotationMigration
+
+// Validate checks that the prefixes of each migration are distinct valid
+// annotation key prefixes.
+func (a AnnotationMigrations) Validate() error {
+	for i, migration := range a {
+		for _, prefix := range []string{migration.From, migration.To} {
+			if prefix == "" {
+				return fmt.Errorf("annotationMigrations[%d] requires both the from and to prefixes", i)
+			}
+			// The prefix must form a valid annotation key with any suffix
+			if errs := validation.IsQualifiedName(prefix + "x"); len(errs) > 0 {
+				return fmt.Errorf("annotationMigrations[%d]: invalid annotation prefix %q: %s",
+					i, prefix, strings.Join(errs, "; "))
+			}
+		}
+		if strings.HasPrefix(migration.From, migration.To) || strings.HasPrefix(migration.To, migration.From) {
+			return fmt.Errorf("annotationMigrations[%d]: prefixes %q and %q must not overlap",
+				i, migration.From, migration.To)
+		}
+	}
+	return nil
+}
+
+// DefaultResources maps the priority classes to the quantities of the
+// resources, by resource name, requested by their PipelineRuns unless they
+// already request them, e.g. {"konflux-release": {"cpu": "2"}}. The priority
+// class is the one of the kueue.x-k8s.io/priority-class label once the
+// PipelineRuns are mutated.
+type DefaultResources map[string]map[string]string
+
+// Validate checks that the priority classes and the resource names aren't
+// empty, and that the quantities are valid.
+func (d DefaultResources) Validate() error {
+	for _, priorityClass := range slices.Sorted(maps.Keys(d)) {
+		if priorityClass == "" {
+			return fmt.Errorf("defaultResources: the priority class must not be empty")
+		}
+		resources := d[priorityClass]
+		for _, name := range slices.Sorted(maps.Keys(resources)) {
+			if name == "" {
+				return fmt.Errorf("defaultResources %s: the resource name must not be empty", priorityClass)
+			}
+			if _, err := resource.ParseQuantity(resources[name]); err != nil {
+				return fmt.Errorf("defaultResources %s: invalid quantity %q of %s: %w",
+					priorityClass, resources[name], name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateCanaryPercent checks that the canary percentage is between 0 and
+// 100.
+func (c *Config) ValidateCanaryPercent() error {
+	if c.CanaryPercent < 0 || c.CanaryPercent > 100 {
+		return fmt.Errorf("invalid canaryPercent %d, must be between 0 and 100", c.CanaryPercent)
+	}
+	return nil
+}
+
+// ManagedNamespacesSelector returns the selector of the managed namespaces,
+// or nil when all the namespaces are managed.
+func (c *Config) ManagedNamespacesSelector() (labels.Selector, error) {
+	if c.ManagedNamespaces == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(c.ManagedNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid managedNamespaces: %w", err)
+	}
+	return selector, nil
+}
+
+// ManageSelector returns the selector of the PipelineRuns queued by
+// tekton-kueue, or nil when all of them are queued.
+func (c *Config) ManageSelector() (labels.Selector, error) {
+	if c.Manage == nil || c.Manage.Selector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(c.Manage.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manage selector: %w", err)
+	}
+	return selector, nil
+}
+
+// ResourceAnnotationPrefixMode is a step of the migration of the resource
+// annotation prefix.
+type ResourceAnnotationPrefixMode string
+
+const (
+	// ResourceAnnotationPrefixDualWrite writes both prefixes and reads
+	// both of them, the new one winning on conflicts.
+	ResourceAnnotationPrefixDualWrite ResourceAnnotationPrefixMode = "dual-write"
+	// ResourceAnnotationPrefixReadBoth only writes the new prefix, but
+	// still reads the old one.
+	ResourceAnnotationPrefixReadBoth ResourceAnnotationPrefixMode = "read-both"
+	// ResourceAnnotationPrefixNewOnly only reads and writes the new prefix,
+	// completing the migration.
+	ResourceAnnotationPrefixNewOnly ResourceAnnotationPrefixMode = "new-only"
+)
+
+// ResourceAnnotationPrefixMigration configures the migration of the
+// resource annotations from the prefix From to the prefix To. The modes are
+// meant to be rolled out in order: dual-write, read-both once the PipelineRuns
+// only carrying the old prefix have completed on all the clusters, and
+// new-only once nothing reads the old prefix anymore.
+type ResourceAnnotationPrefixMigration struct {
+	From string                       `json:"from"`
+	To   string                       `json:"to"`
+	Mode ResourceAnnotationPrefixMode `json:"mode"`
+}
+
+// Validate checks that the prefixes are distinct valid annotation key
+// prefixes, and that the mode is known.
+func (m *ResourceAnnotationPrefixMigration) Validate() error {
+	for _, prefix := range []string{m.From, m.To} {
+		if prefix == "" {
+			return fmt.Errorf("resourceAnnotationPrefixMigration requires both the from and to prefixes")
+		}
+		// The prefix must form a valid annotation key with any suffix
+		if errs := validation.IsQualifiedName(prefix + "x"); len(errs) > 0 {
+			return fmt.Errorf("invalid resource annotation prefix %q: %s", prefix, strings.Join(errs, "; "))
+		}
+	}
+	if strings.HasPrefix(m.From, m.To) || strings.HasPrefix(m.To, m.From) {
+		return fmt.Errorf("resource annotation prefixes %q and %q must not overlap", m.From, m.To)
+	}
+	switch m.Mode {
+	case ResourceAnnotationPrefixDualWrite, ResourceAnnotationPrefixReadBoth, ResourceAnnotationPrefixNewOnly:
+	default:
+		return fmt.Errorf("invalid resourceAnnotationPrefixMigration mode %q, must be one of: %s, %s, %s", m.Mode,
+			ResourceAnnotationPrefixDualWrite, ResourceAnnotationPrefixReadBoth, ResourceAnnotationPrefixNewOnly)
+	}
+	return nil
+}
+
+// WritePrefixes returns the prefixes of the resource annotations written by
+// the webhook.
+func (m *ResourceAnnotationPrefixMigration) WritePrefixes() []string {
+	if m.Mode == ResourceAnnotationPrefixDualWrite {
+		return []string{m.To, m.From}
+	}
+	return []string{m.To}
+}
+
+// ReadPrefixes returns the prefixes of the resource annotations honored by
+// the webhook and the controller, by decreasing precedence.
+func (m *ResourceAnnotationPrefixMigration) ReadPrefixes() []string {
+	if m.Mode == ResourceAnnotationPrefixNewOnly {
+		return []string{m.To}
+	}
+	return []string{m.To, m.From}
+}
+
+// DefaultWebhookConfigurationName is the name of the
+// MutatingWebhookConfiguration of the default deployment.
+const DefaultWebhookConfigurationName = "tekton-kueue-mutating-webhook-configuration"
+
+// Webhook configures the admission webhook.
+type Webhook struct {
+	// NamespaceSelector, when set, is kept in sync by the webhook with the
+	// namespaceSelector of its MutatingWebhookConfiguration, so the
+	// kube-apiserver only sends the PipelineRuns of the selected
+	// namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// ConfigurationName is the name of the MutatingWebhookConfiguration of
+	// the webhook. Defaults to DefaultWebhookConfigurationName.
+	ConfigurationName string `json:"configurationName,omitempty"`
+	// DecisionHistory keeps the last admission decisions of each namespace
+	// in memory.
+	DecisionHistory DecisionHistory `json:"decisionHistory,omitempty"`
+	// ValidateQueueName rejects the PipelineRuns whose queue, once
+	// defaulted and mutated, isn't a LocalQueue of their namespace. The
+	// admissions aren't validated until the LocalQueues are cached.
+	ValidateQueueName bool `json:"validateQueueName,omitempty"`
+	// CheckMutationInvariant is a debug option checking that the admissions
+	// only change the fields of the PipelineRuns the defaulter and the
+	// mutation types are allowed to change. The violations are logged and
+	// counted, the admissions aren't rejected.
+	CheckMutationInvariant bool `json:"checkMutationInvariant,omitempty"`
+	// AuditAnnotation records the labels, annotations and spec fields
+	// changed by the admission of each PipelineRun, without their values,
+	// in the kueue.konflux-ci.dev/applied-mutations annotation.
+	AuditAnnotation bool `json:"auditAnnotation,omitempty"`
+	// RateLimit rejects the PipelineRuns of the namespaces creating them
+	// faster than the limit.
+	RateLimit RateLimit `json:"rateLimit,omitempty"`
+	// DeletionProtection handles the deletion of the pending PipelineRuns
+	// which have a Workload. The deletions aren't checked by default.
+	DeletionProtection DeletionProtectionPolicy `json:"deletionProtection,omitempty"`
+	// AnnotateConfigHash records the hash of the configurations loaded by
+	// the webhook in the kueue.konflux-ci.dev/config-hash annotation of
+	// the mutated PipelineRuns, so the PipelineRuns mutated by replicas
+	// serving different configurations are told apart.
+	AnnotateConfigHash bool `json:"annotateConfigHash,omitempty"`
+	// TenantOverrides adds the expressions of the TenantOverridesConfigMap
+	// of each namespace, restricted by a policy, to the expressions
+	// evaluated for the PipelineRuns of the namespace.
+	TenantOverrides TenantOverrides `json:"tenantOverrides,omitempty"`
+	// AnnotateDecision records the reason of the decision of the webhook,
+	// mutated or too-large, in the kueue.konflux-ci.dev/admission-decision
+	// annotation of the PipelineRuns it queues. The reasons of all the
+	// decisions are counted by the tekton_kueue_webhook_decisions_total
+	// metric whatever the setting.
+	AnnotateDecision bool `json:"annotateDecision,omitempty"`
+}
+
+// TenantOverridesConfigMap is the name of the ConfigMaps whose expressions
+// are added to those of the PipelineRuns of their namespace.
+const TenantOverridesConfigMap = "tekton-kueue-overrides"
+
+// TenantOverridesNamespacePlaceholder is replaced by the namespace of the
+// ConfigMap in the allowed key prefixes of the tenant expressions.
+const TenantOverridesNamespacePlaceholder = "{namespace}"
+
+// TenantOverrides configures the expressions the tenants add to those of
+// the PipelineRuns of their namespace, in a TenantOverridesConfigMap.
+type TenantOverrides struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// AllowedTypes lists the types of the mutations the tenant expressions
+	// may return. Defaults to annotation.
+	AllowedTypes []string `json:"allowedTypes,omitempty"`
+	// AllowedKeyPrefixes lists the prefixes of the labels and annotations
+	// the tenant expressions may set, {namespace} being replaced by the
+	// namespace of the ConfigMap, e.g. {namespace}.tenants.acme.com/. All
+	// the keys are allowed when empty.
+	AllowedKeyPrefixes []string `json:"allowedKeyPrefixes,omitempty"`
+}
+
+// GetAllowedTypes returns the configured mutation types or their default.
+func (t *TenantOverrides) GetAllowedTypes() []string {
+	if len(t.AllowedTypes) == 0 {
+		return []string{"annotation"}
+	}
+	return t.AllowedTypes
+}
+
+// KeyPrefixes returns the allowed key prefixes of the tenant expressions
+// of the namespace.
+func (t *TenantOverrides) KeyPrefixes(namespace string) []string {
+	prefixes := make([]string, 0, len(t.AllowedKeyPrefixes))
+	for _, prefix := range t.AllowedKeyPrefixes {
+		prefixes = append(prefixes, strings.ReplaceAll(prefix, TenantOverridesNamespacePlaceholder, namespace))
+	}
+	return prefixes
+}
+
+// Validate checks that the allowed key prefixes aren't empty.
+func (t *TenantOverrides) Validate() error {
+	if slices.Contains(t.AllowedKeyPrefixes, "") {
+		return fmt.Errorf("tenantOverrides allowedKeyPrefixes must not contain empty prefixes")
+	}
+	return nil
+}
+
+// DeletionProtectionPolicy defines how the deletions of the pending
+// PipelineRuns which have a Workload are handled.
+type DeletionProtectionPolicy string
+
+const (
+	// DeletionProtectionAnnotate allows the deletion, once the Workloads of
+	// the PipelineRun are annotated for cleanup by the controller.
+	DeletionProtectionAnnotate DeletionProtectionPolicy = "Annotate"
+	// DeletionProtectionDeny denies the deletion, unless the PipelineRun
+	// has the force-delete annotation.
+	DeletionProtectionDeny DeletionProtectionPolicy = "Deny"
+)
+
+// Validate checks that the policy is known.
+func (p DeletionProtectionPolicy) Validate() error {
+	switch p {
+	case "", DeletionProtectionAnnotate, DeletionProtectionDeny:
+		return nil
+	}
+	return fmt.Errorf("invalid deletionProtection %q, must be one of: %s, %s",
+		p, DeletionProtectionAnnotate, DeletionProtectionDeny)
+}
+
+const (
+	DefaultDecisionHistoryPerNamespace = 20
+	DefaultDecisionHistoryMaxDecisions = 5000
+)
+
+// DecisionHistory configures the in-memory history of the admission
+// decisions of the webhook, served by the metrics server at
+// /debug/decisions. The history isn't persisted.
+type DecisionHistory struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// PerNamespace is the number of decisions kept for each namespace.
+	PerNamespace int `json:"perNamespace,omitempty"`
+	// MaxDecisions caps the number of decisions kept across all the
+	// namespaces. The oldest decisions are evicted first.
+	MaxDecisions int `json:"maxDecisions,omitempty"`
+}
+
+// GetPerNamespace returns the configured capacity per namespace or its
+// default.
+func (d *DecisionHistory) GetPerNamespace() int {
+	if d.PerNamespace <= 0 {
+		return DefaultDecisionHistoryPerNamespace
+	}
+	return d.PerNamespace
+}
+
+// GetMaxDecisions returns the configured global capacity or its default.
+func (d *DecisionHistory) GetMaxDecisions() int {
+	if d.MaxDecisions <= 0 {
+		return DefaultDecisionHistoryMaxDecisions
+	}
+	return d.MaxDecisions
+}
+
+const (
+	DefaultRateLimitQPS   = 5
+	DefaultRateLimitBurst = 20
+)
+
+// RateLimit configures the token buckets limiting the rate at which the
+// webhook admits the PipelineRuns of each namespace. The buckets are kept
+// in memory by each replica of the webhook.
+type RateLimit struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// QPS is the number of PipelineRuns a namespace can create per second
+	// once its burst is exhausted.
+	QPS float64 `json:"qps,omitempty"`
+	// Burst is the number of PipelineRuns a namespace can create at once.
+	Burst int `json:"burst,omitempty"`
+	// ExemptNamespaces selects the namespaces whose PipelineRuns aren't
+	// limited.
+	ExemptNamespaces *metav1.LabelSelector `json:"exemptNamespaces,omitempty"`
+}
+
+// GetQPS returns the configured rate or its default.
+func (r *RateLimit) GetQPS() float64 {
+	if r.QPS == 0 {
+		return DefaultRateLimitQPS
+	}
+	return r.QPS
+}
+
+// GetBurst returns the configured burst or its default.
+func (r *RateLimit) GetBurst() int {
+	if r.Burst == 0 {
+		return DefaultRateLimitBurst
+	}
+	return r.Burst
+}
+
+// Validate checks that the rate and the burst aren't negative.
+func (r *RateLimit) Validate() error {
+	if r.QPS < 0 {
+		return fmt.Errorf("rateLimit qps must not be negative, got %g", r.QPS)
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("rateLimit burst must not be negative, got %d", r.Burst)
+	}
+	return nil
+}
+
+// ExemptNamespacesSelector returns the selector of the namespaces which
+// aren't limited, or nil when all the namespaces are limited.
+func (r *RateLimit) ExemptNamespacesSelector() (labels.Selector, error) {
+	if r.ExemptNamespaces == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.ExemptNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rateLimit exemptNamespaces: %w", err)
+	}
+	return selector, nil
+}
+
+// GetConfigurationName returns the name of the MutatingWebhookConfiguration
+// of the webhook.
+func (w *Webhook) GetConfigurationName() string {
+	if w.ConfigurationName == "" {
+		return DefaultWebhookConfigurationName
+	}
+	return w.ConfigurationName
+}
+
+// Validate checks that the namespace selector is valid.
+func (w *Webhook) Validate() error {
+	if w.NamespaceSelector == nil {
+		return nil
+	}
+	if _, err := metav1.LabelSelectorAsSelector(w.NamespaceSelector); err != nil {
+		return fmt.Errorf("invalid webhook namespaceSelector: %w", err)
+	}
+	return nil
+}
+
+// Logging configures the audit logs of the webhook.
+type Logging struct {
+	// LogMutations enables logging the labels and annotations added to
+	// each PipelineRun.
+	LogMutations bool `json:"logMutations,omitempty"`
+	// MaxValueLength is the number of bytes above which logged values are
+	// truncated. Defaults to 256.
+	MaxValueLength int `json:"maxValueLength,omitempty"`
+	// RedactKeys lists the keys, or glob patterns of keys, whose values
+	// are logged as "[redacted]".
+	RedactKeys []string `json:"redactKeys,omitempty"`
+	// RecordAdmissionUID enables annotating each PipelineRun with the UID
+	// of the admission request which created it, so it can be correlated
+	// with the logs of the webhook.
+	RecordAdmissionUID bool `json:"recordAdmissionUID,omitempty"`
+	// IncludeInputSnapshot adds the PipelineRun, as received by the CEL
+	// mutator, to the logs, so the admissions can be replayed with the
+	// replay-audit subcommand. The values of the redacted labels and
+	// annotations are redacted.
+	IncludeInputSnapshot bool `json:"includeInputSnapshot,omitempty"`
 }
 
 type CEL struct {
-	Expressions []string `json:"expressions,omitempty"`
+	// Expressions are the CEL expressions, given as a string or as an
+	// object naming the expression, see CELExpression.
+	Expressions []CELExpression `json:"expressions,omitempty"`
+	// Groups organizes expressions into named groups which can be disabled.
+	// They are evaluated after Expressions.
+	Groups []CELGroup `json:"groups,omitempty"`
+	// PerQueue lists the expressions of the PipelineRuns of each queue, by
+	// queue name. They're evaluated after Expressions and Groups, for the
+	// PipelineRuns of the queue only, once the queue label is set.
+	PerQueue map[string][]string `json:"perQueue,omitempty"`
+	// Definitions are the sub-expressions, by name, which the expressions
+	// of Expressions, Groups and PerQueue reference as ${name}. The
+	// references are replaced by the sub-expressions before the
+	// compilation, and definitions can reference other definitions.
+	Definitions map[string]string `json:"definitions,omitempty"`
+	// Validation relaxes the validation of the mutations returned by the
+	// expressions.
+	Validation CELValidation `json:"validation,omitempty"`
+	// Validations lists the expressions validating the updates of the
+	// PipelineRuns, evaluated with the pipelineRun and oldPipelineRun
+	// variables. They return a bool, or check(condition, message), and the
+	// updates for which any of them returns false are denied.
+	Validations []string `json:"validations,omitempty"`
+	// ResourceKeyNormalization enables folding resource annotations whose
+	// keys are equivalent once canonicalized, e.g. requests-linux-amd64 and
+	// requests-LINUX-AMD64.
+	ResourceKeyNormalization bool `json:"resourceKeyNormalization,omitempty"`
+	// ResourceKeyNormalizationRules lists the canonicalization rules to
+	// apply, in order. All rules are applied when empty.
+	ResourceKeyNormalizationRules []string `json:"resourceKeyNormalizationRules,omitempty"`
+	// Strictness defines what happens when an expression passes a value
+	// controlled by the author of the PipelineRun, e.g. an annotation, to
+	// priority(), or references pipelineRun.status. Defaults to Warn.
+	Strictness Strictness `json:"strictness,omitempty"`
+	// ExcludeStatus removes the status from the pipelineRun variable, so
+	// has(pipelineRun.status) is false. The status is empty when
+	// PipelineRuns are admitted.
+	ExcludeStatus bool `json:"excludeStatus,omitempty"`
+	// TimeoutOverride allows the timeout() mutations to shorten the
+	// timeouts set by the authors of the PipelineRuns. By default, they're
+	// only replaced by longer timeouts.
+	TimeoutOverride bool `json:"timeoutOverride,omitempty"`
+	// OverrideServiceAccount allows the serviceAccount() mutations to
+	// replace the service account set by the authors of the PipelineRuns.
+	// By default, it's kept.
+	OverrideServiceAccount bool `json:"overrideServiceAccount,omitempty"`
+	// OverwritePolicy defines whether the label() and annotation()
+	// mutations replace the values already set on the PipelineRuns.
+	// Defaults to always.
+	OverwritePolicy OverwritePolicy `json:"overwritePolicy,omitempty"`
+	// CircuitBreaker skips the expressions which fail too often, instead
+	// of failing the admissions.
+	CircuitBreaker CircuitBreaker `json:"circuitBreaker,omitempty"`
+	// MaxObjectBytes is the size of the JSON of the PipelineRuns above
+	// which the expressions aren't evaluated. The PipelineRuns are still
+	// queued. Defaults to DefaultMaxObjectBytes.
+	MaxObjectBytes int `json:"maxObjectBytes,omitempty"`
+	// StrictKeys fails the compilation when the same label or annotation
+	// is set by several expressions, instead of only logging a warning.
+	// Only string literal keys are detected.
+	StrictKeys bool `json:"strictKeys,omitempty"`
+	// EvaluationCache caches the mutations returned by the expressions for
+	// the PipelineRuns created from the same template.
+	EvaluationCache EvaluationCache `json:"evaluationCache,omitempty"`
+	// MaxMutationsPerObject is the number of mutations returned by the
+	// expressions above which the mutation of a PipelineRun fails.
+	// Defaults to DefaultMaxMutationsPerObject.
+	MaxMutationsPerObject int `json:"maxMutationsPerObject,omitempty"`
+	// MaxAnnotationBytesPerObject is the size of the annotation values
+	// returned by the expressions above which the mutation of a
+	// PipelineRun fails. Defaults to DefaultMaxAnnotationBytesPerObject.
+	MaxAnnotationBytesPerObject int `json:"maxAnnotationBytesPerObject,omitempty"`
+	// PruneUnknownResourceRequests removes the resource annotations of the
+	// PipelineRuns, e.g. copied from a former PipelineRun by a re-run,
+	// whose resource is neither produced by the expressions nor listed in
+	// AllowedResourceRequests.
+	PruneUnknownResourceRequests bool `json:"pruneUnknownResourceRequests,omitempty"`
+	// AllowedResourceRequests lists the resources, e.g. linux-amd64, whose
+	// annotations are kept by PruneUnknownResourceRequests.
+	AllowedResourceRequests []string `json:"allowedResourceRequests,omitempty"`
+	// TimeZone is the IANA name of the time zone, e.g. Europe/Prague, of
+	// the nowWeekday and nowHour variables of the expressions. Defaults to
+	// UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+	// ExposeNamespaceLabels exposes the labels of the namespaces of the
+	// PipelineRuns to the expressions of the webhook as the namespaceLabels
+	// variable, read from the cache of the namespaces. It's empty
+	// otherwise.
+	ExposeNamespaceLabels bool `json:"exposeNamespaceLabels,omitempty"`
+}
+
+// GetTimeZone returns the location of the configured time zone, UTC by
+// default.
+func (c *CEL) GetTimeZone() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEL timeZone %q: %w", c.TimeZone, err)
+	}
+	return loc, nil
+}
+
+const (
+	// DefaultMaxMutationsPerObject is the default number of mutations of a
+	// PipelineRun above which its mutation fails.
+	DefaultMaxMutationsPerObject = 100
+	// DefaultMaxAnnotationBytesPerObject is the default size of the
+	// annotation values added to a PipelineRun above which its mutation
+	// fails, 128KiB, half of the size Kubernetes allows for all the
+	// annotations of an object.
+	DefaultMaxAnnotationBytesPerObject = 128 * 1024
+)
+
+// definitionName matches the names of the CEL definitions, referenced as
+// ${name} by the expressions.
+var definitionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// GetMaxMutationsPerObject returns the configured maximum number of
+// mutations of a PipelineRun or its default.
+func (c *CEL) GetMaxMutationsPerObject() int {
+	if c.MaxMutationsPerObject <= 0 {
+		return DefaultMaxMutationsPerObject
+	}
+	return c.MaxMutationsPerObject
+}
+
+// GetMaxAnnotationBytesPerObject returns the configured maximum size of the
+// annotation values added to a PipelineRun or its default.
+func (c *CEL) GetMaxAnnotationBytesPerObject() int {
+	if c.MaxAnnotationBytesPerObject <= 0 {
+		return DefaultMaxAnnotationBytesPerObject
+	}
+	return c.MaxAnnotationBytesPerObject
+}
+
+// DefaultEvaluationCacheSize is the default number of PipelineRun templates
+// whose mutations are cached.
+const DefaultEvaluationCacheSize = 1024
+
+// EvaluationCache configures the cache of the results of the CEL
+// expressions, by PipelineRun without its name. It's disabled when an
+// expression reads the name of the PipelineRuns.
+type EvaluationCache struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Size is the number of PipelineRun templates whose mutations are
+	// cached, the least recently used ones being evicted first.
+	Size int `json:"size,omitempty"`
+}
+
+// GetSize returns the configured size or its default.
+func (c *EvaluationCache) GetSize() int {
+	if c.Size <= 0 {
+		return DefaultEvaluationCacheSize
+	}
+	return c.Size
+}
+
+// DefaultMaxObjectBytes is the default size of the PipelineRuns above which
+// the CEL expressions aren't evaluated, 1.5MiB, the default request size
+// limit of etcd.
+const DefaultMaxObjectBytes = 1536 * 1024
+
+// GetMaxObjectBytes returns the configured maximum size of the PipelineRuns
+// or its default.
+func (c *CEL) GetMaxObjectBytes() int {
+	if c.MaxObjectBytes <= 0 {
+		return DefaultMaxObjectBytes
+	}
+	return c.MaxObjectBytes
+}
+
+const (
+	DefaultCircuitBreakerFailureThreshold = 20
+	DefaultCircuitBreakerWindow           = 5 * time.Minute
+)
+
+// CircuitBreaker configures the circuit breaker of the CEL expressions. An
+// expression failing FailureThreshold times within Window is skipped for
+// the next Window, then evaluated again.
+type CircuitBreaker struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// FailureThreshold is the number of failures tripping the circuit
+	// breaker of an expression.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// Window is the period over which the failures are counted, and for
+	// which a tripped expression is skipped.
+	Window *metav1.Duration `json:"window,omitempty"`
+}
+
+// GetFailureThreshold returns the configured failure threshold or its
+// default.
+func (c *CircuitBreaker) GetFailureThreshold() int {
+	if c.FailureThreshold <= 0 {
+		return DefaultCircuitBreakerFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+// GetWindow returns the configured window or its default.
+func (c *CircuitBreaker) GetWindow() time.Duration {
+	if c.Window == nil || c.Window.Duration <= 0 {
+		return DefaultCircuitBreakerWindow
+	}
+	return c.Window.Duration
+}
+
+// CELGroup is a named group of CEL expressions.
+type CELGroup struct {
+	Name string `json:"name"`
+	// Enabled defaults to true.
+	Enabled     *bool           `json:"enabled,omitempty"`
+	Expressions []CELExpression `json:"expressions,omitempty"`
+}
+
+// CELExpression is a CEL expression, with an optional name identifying it
+// in the metrics, the error messages and the logs instead of its index. It's
+// given either as the expression string, or as an object:
+//
+//	expressions:
+//	  - 'priority("default")'
+//	  - name: priority-routing
+//	    expression: 'priority(pacEventType == "push" ? "high" : "low")'
+type CELExpression struct {
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+	// OnError are the mutations applied instead of failing the admission
+	// when the evaluation of the expression fails, an empty list ignoring
+	// the failure. They're validated like the mutations returned by the
+	// expressions when the configuration is loaded.
+	OnError []CELMutation `json:"onError,omitempty"`
+}
+
+// CELMutation is a static mutation, e.g. of the onError mutations of an
+// expression.
+type CELMutation struct {
+	// Type is the type of the mutation, e.g. label or annotation
+	Type  string `json:"type"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// UnnamedExpressions returns the expressions without names.
+func UnnamedExpressions(expressions ...string) []CELExpression {
+	named := make([]CELExpression, 0, len(expressions))
+	for _, expression := range expressions {
+		named = append(named, CELExpression{Expression: expression})
+	}
+	return named
+}
+
+// UnmarshalJSON accepts the expression string or the object.
+func (e *CELExpression) UnmarshalJSON(data []byte) error {
+	var expression string
+	if err := json.Unmarshal(data, &expression); err == nil {
+		*e = CELExpression{Expression: expression}
+		return nil
+	}
+	// The alias drops the method, so the object is decoded as a struct
+	type celExpression CELExpression
+	var object celExpression
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("CEL expression must be a string or an object with a name and an expression: %w", err)
+	}
+	*e = CELExpression(object)
+	return nil
+}
+
+// MarshalJSON returns the expression string for the expressions without name
+// nor onError mutations, so they're written back as they were given.
+func (e CELExpression) MarshalJSON() ([]byte, error) {
+	if e.Name == "" && e.OnError == nil {
+		return json.Marshal(e.Expression)
+	}
+	type celExpression CELExpression
+	return json.Marshal(celExpression(e))
+}
+
+// IsEnabled returns whether the expressions of the group are evaluated.
+func (g *CELGroup) IsEnabled() bool {
+	return g.Enabled == nil || *g.Enabled
+}
+
+// Validate checks that the groups have unique, non-empty names, that the
+// names of the expressions are unique, that the allowed key prefixes and the queue names of the perQueue expressions are
+// valid, and that the circuit breaker settings, the maximum object size,
+// the size of the evaluation cache and the mutation limits aren't negative.
+func (c *CEL) Validate() error {
+	if c.CircuitBreaker.FailureThreshold < 0 {
+		return fmt.Errorf("circuitBreaker failureThreshold must not be negative, got %d", c.CircuitBreaker.FailureThreshold)
+	}
+	if c.CircuitBreaker.Window != nil && c.CircuitBreaker.Window.Duration < 0 {
+		return fmt.Errorf("circuitBreaker window must not be negative, got %s", c.CircuitBreaker.Window.Duration)
+	}
+	if c.MaxObjectBytes < 0 {
+		return fmt.Errorf("maxObjectBytes must not be negative, got %d", c.MaxObjectBytes)
+	}
+	if c.EvaluationCache.Size < 0 {
+		return fmt.Errorf("evaluationCache size must not be negative, got %d", c.EvaluationCache.Size)
+	}
+	if c.MaxMutationsPerObject < 0 {
+		return fmt.Errorf("maxMutationsPerObject must not be negative, got %d", c.MaxMutationsPerObject)
+	}
+	if c.MaxAnnotationBytesPerObject < 0 {
+		return fmt.Errorf("maxAnnotationBytesPerObject must not be negative, got %d", c.MaxAnnotationBytesPerObject)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Definitions)) {
+		if !definitionName.MatchString(name) {
+			return fmt.Errorf("invalid CEL definition name %q, must be letters, digits and underscores, "+
+				"not starting with a digit", name)
+		}
+		if strings.TrimSpace(c.Definitions[name]) == "" {
+			return fmt.Errorf("CEL definition %q is empty", name)
+		}
+	}
+	if _, err := c.GetTimeZone(); err != nil {
+		return err
+	}
+	if slices.Contains(c.AllowedResourceRequests, "") {
+		return fmt.Errorf("allowedResourceRequests must not contain empty resource names")
+	}
+	names := map[string]bool{}
+	for i, group := range c.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("CEL group %d has no name", i)
+		}
+		if names[group.Name] {
+			return fmt.Errorf("duplicate CEL group %q", group.Name)
+		}
+		if strings.HasPrefix(group.Name, perQueueGroupPrefix) {
+			return fmt.Errorf("CEL group %q: the %q prefix is reserved for the perQueue expressions",
+				group.Name, perQueueGroupPrefix)
+		}
+		names[group.Name] = true
+	}
+	if err := c.validateExpressionNames(); err != nil {
+		return err
+	}
+	switch c.OverwritePolicy {
+	case "", OverwritePolicyAlways, OverwritePolicyNeverUserSet, OverwritePolicyNever:
+	default:
+		return fmt.Errorf("invalid overwritePolicy %q, expected %s, %s or %s", c.OverwritePolicy,
+			OverwritePolicyAlways, OverwritePolicyNeverUserSet, OverwritePolicyNever)
+	}
+	if err := c.Validation.Validate(); err != nil {
+		return err
+	}
+	for _, queue := range slices.Sorted(maps.Keys(c.PerQueue)) {
+		if errs := validation.IsDNS1123Subdomain(queue); len(errs) > 0 {
+			return fmt.Errorf("invalid perQueue queue name %q: %s", queue, strings.Join(errs, "; "))
+		}
+		if len(c.PerQueue[queue]) == 0 {
+			return fmt.Errorf("perQueue queue %q has no expressions", queue)
+		}
+	}
+	return nil
+}
+
+// validateExpressionNames checks that the names of the expressions of
+// Expressions and Groups are unique.
+func (c *CEL) validateExpressionNames() error {
+	names := map[string]bool{}
+	check := func(expressions []CELExpression) error {
+		for _, expression := range expressions {
+			if expression.Name == "" {
+				continue
+			}
+			if names[expression.Name] {
+				return fmt.Errorf("duplicate CEL expression name %q", expression.Name)
+			}
+			names[expression.Name] = true
+		}
+		return nil
+	}
+	if err := check(c.Expressions); err != nil {
+		return err
+	}
+	for _, group := range c.Groups {
+		if err := check(group.Expressions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CELValidation configures the validation of the mutations returned by the
+// CEL expressions.
+type CELValidation struct {
+	// AllowedKeyPrefixes lists the prefixes of the annotation keys which
+	// aren't required to be qualified names, e.g. "policies.acme.com/" for
+	// policies.acme.com/build/verify. Their size is still validated. The
+	// label keys are always validated, like the API server does.
+	AllowedKeyPrefixes []string `json:"allowedKeyPrefixes,omitempty"`
+	// ProtectedKeys lists the label and annotation keys the expressions
+	// can't set, in addition to the default ones, see
+	// cel.DefaultProtectedKeyPatterns.
+	ProtectedKeys ProtectedKeys `json:"protectedKeys,omitempty"`
+}
+
+// ProtectedKeys configures the label and annotation keys the expressions
+// can't set. The patterns are either exact keys, e.g.
+// pod-security.kubernetes.io/enforce, or prefixes ending with *, e.g.
+// kubernetes.io/*.
+type ProtectedKeys struct {
+	// Deny lists the patterns of the protected keys.
+	Deny []string `json:"deny,omitempty"`
+	// Allow lists the patterns of the keys exempted from Deny and from the
+	// default protected keys.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// Validate checks that the patterns are keys or prefixes ending with a
+// single *.
+func (p *ProtectedKeys) Validate() error {
+	for _, pattern := range slices.Concat(p.Deny, p.Allow) {
+		key := strings.TrimSuffix(pattern, "*")
+		if key == "" {
+			return fmt.Errorf("invalid validation protectedKeys pattern %q: must not be empty", pattern)
+		}
+		if strings.Contains(key, "*") {
+			return fmt.Errorf("invalid validation protectedKeys pattern %q: * is only allowed at the end", pattern)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the allowed key prefixes are DNS subdomains,
+// followed by a slash and, optionally, the first segments of the names, and
+// the patterns of the protected keys.
+func (v *CELValidation) Validate() error {
+	for _, prefix := range v.AllowedKeyPrefixes {
+		domain, _, found := strings.Cut(prefix, "/")
+		if !found || !strings.HasSuffix(prefix, "/") {
+			return fmt.Errorf("invalid validation allowedKeyPrefixes prefix %q: must end with a slash", prefix)
+		}
+		if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+			return fmt.Errorf("invalid validation allowedKeyPrefixes prefix %q: %s", prefix, strings.Join(errs, "; "))
+		}
+	}
+	return v.ProtectedKeys.Validate()
+}
+
+// perQueueGroupPrefix is the prefix of the groups labeling the metrics of
+// the perQueue expressions, see cel.QueueGroup.
+const perQueueGroupPrefix = "queue:"
+
+// Strictness defines how findings of the CEL expression checks are handled.
+type Strictness string
+
+const (
+	// StrictnessWarn logs the findings.
+	StrictnessWarn Strictness = "Warn"
+	// StrictnessEnforce rejects the configuration.
+	StrictnessEnforce Strictness = "Enforce"
+)
+
+// OverwritePolicy defines whether the CEL mutations replace the labels and
+// annotations already set on the PipelineRuns.
+type OverwritePolicy string
+
+const (
+	// OverwritePolicyAlways replaces the values.
+	OverwritePolicyAlways OverwritePolicy = "always"
+	// OverwritePolicyNeverUserSet keeps the values set on the PipelineRuns
+	// before they're mutated, the values set by an expression can still be
+	// replaced by the next ones.
+	OverwritePolicyNeverUserSet OverwritePolicy = "neverUserSet"
+	// OverwritePolicyNever keeps all the values already set, including the
+	// ones set by the previous expressions.
+	OverwritePolicyNever OverwritePolicy = "never"
+)
+
+// Controller holds the configuration of the optional components of the
+// controller subcommand.
+type Controller struct {
+	QueuePosition QueuePosition `json:"queuePosition,omitempty"`
+	// OrphanedWorkloadPolicy defines what happens to a pending PipelineRun
+	// whose Workload is deleted. Defaults to Recreate.
+	OrphanedWorkloadPolicy OrphanedWorkloadPolicy `json:"orphanedWorkloadPolicy,omitempty"`
+	ResolvedRequests       ResolvedRequests       `json:"resolvedRequests,omitempty"`
+	WaitForPodsReady       WaitForPodsReady       `json:"waitForPodsReady,omitempty"`
+	PendingAdmissionChecks PendingAdmissionChecks `json:"pendingAdmissionChecks,omitempty"`
+	// AllowPriorityBump copies the changes of the priority class label of
+	// pending PipelineRuns onto their Workloads, as long as they haven't
+	// reserved quota.
+	AllowPriorityBump bool `json:"allowPriorityBump,omitempty"`
+	// PropagateToTaskRuns lists the label and annotation keys copied from
+	// the PipelineRuns managed by Kueue onto their TaskRuns when they're
+	// created.
+	PropagateToTaskRuns PropagationKeys `json:"propagateToTaskRuns,omitempty"`
+	Metrics             Metrics         `json:"metrics,omitempty"`
+	// Drain stops the admission of new PipelineRuns, e.g. during upgrades,
+	// while the admitted ones run to completion. It's read again at
+	// regular intervals, so the drain is lifted without restarting the
+	// controller.
+	Drain bool `json:"drain,omitempty"`
+	// AdoptRunningPipelineRuns lets the Workload reconciler manage the
+	// PipelineRuns which were already running without the queue label when
+	// the controller started managing them, e.g. when tekton-kueue is rolled
+	// out to a cluster. By default they're skipped: they're never given
+	// Workloads nor stopped.
+	AdoptRunningPipelineRuns bool       `json:"adoptRunningPipelineRuns,omitempty"`
+	Preemption               Preemption `json:"preemption,omitempty"`
+	// StopGracePeriod, when set, keeps running the PipelineRuns which
+	// started without being admitted, e.g. created while the webhook was
+	// down, once they're older than the grace period: they're given a
+	// Workload but aren't stopped. The younger ones are still stopped.
+	StopGracePeriod *metav1.Duration `json:"stopGracePeriod,omitempty"`
+}
+
+// GetStopGracePeriod returns the configured stop grace period, or zero when
+// the started PipelineRuns are always stopped.
+func (c *Controller) GetStopGracePeriod() time.Duration {
+	if c.StopGracePeriod == nil {
+		return 0
+	}
+	return c.StopGracePeriod.Duration
+}
+
+// ValidateStopGracePeriod checks that the stop grace period isn't negative.
+func (c *Controller) ValidateStopGracePeriod() error {
+	if c.GetStopGracePeriod() < 0 {
+		return fmt.Errorf("stopGracePeriod must not be negative, got %s", c.StopGracePeriod.Duration)
+	}
+	return nil
+}
+
+// DefaultPreemptionMaxRequeues is the number of times a PipelineRun is
+// requeued after being preempted, by default.
+const DefaultPreemptionMaxRequeues = 3
+
+// Preemption configures what happens to the PipelineRuns whose Workload is
+// preempted. By default they're stopped.
+type Preemption struct {
+	// Requeue creates a pending copy of the preempted PipelineRuns before
+	// they're stopped, so the copy re-enters the queue.
+	Requeue bool `json:"requeue,omitempty"`
+	// MaxRequeues is the number of copies made from an original
+	// PipelineRun, through its successive preemptions, after which the
+	// preempted copies are only stopped.
+	MaxRequeues int `json:"maxRequeues,omitempty"`
+}
+
+// GetMaxRequeues returns the configured requeue limit or its default.
+func (p *Preemption) GetMaxRequeues() int {
+	if p.MaxRequeues <= 0 {
+		return DefaultPreemptionMaxRequeues
+	}
+	return p.MaxRequeues
+}
+
+// Metrics configures the metrics of the controller.
+type Metrics struct {
+	// DisableNamespaceLabel leaves the namespace label of the
+	// tekton_kueue_pipelinerun_queue_duration_seconds histogram empty, on
+	// clusters with too many namespaces to keep a series per namespace.
+	DisableNamespaceLabel bool `json:"disableNamespaceLabel,omitempty"`
+}
+
+// PropagationKeys are the keys of the labels and annotations propagated
+// from the PipelineRuns to their TaskRuns. The labels are propagated as
+// labels and the annotations as annotations, the values already set on the
+// TaskRuns are never overridden.
+type PropagationKeys []string
+
+// Validate checks that the keys are valid, distinct, label and annotation
+// keys.
+func (k PropagationKeys) Validate() error {
+	seen := map[string]bool{}
+	for _, key := range k {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid propagateToTaskRuns key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate propagateToTaskRuns key %q", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// PendingAdmissionChecks configures the component annotating PipelineRuns
+// with the states of the AdmissionChecks of their Workload.
+type PendingAdmissionChecks struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// WaitForPodsReady configures the PodsReady condition of the Workloads of
+// PipelineRuns, which is set once the PipelineRun has started. Disabled by
+// default.
+//
+// The timeouts are enforced by Kueue, according to its own configuration,
+// they should be kept in sync with it.
+type WaitForPodsReady struct {
+	Enable bool `json:"enable,omitempty"`
+	// Timeout is the time an admitted Workload has to become ready before
+	// being evicted.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// RecoveryTimeout is the time a Workload has to become ready again
+	// after it stopped being ready.
+	RecoveryTimeout *metav1.Duration `json:"recoveryTimeout,omitempty"`
+}
+
+// Validate checks that the timeouts aren't negative.
+func (w *WaitForPodsReady) Validate() error {
+	if w.Timeout != nil && w.Timeout.Duration < 0 {
+		return fmt.Errorf("waitForPodsReady timeout must not be negative, got %s", w.Timeout.Duration)
+	}
+	if w.RecoveryTimeout != nil && w.RecoveryTimeout.Duration < 0 {
+		return fmt.Errorf("waitForPodsReady recoveryTimeout must not be negative, got %s", w.RecoveryTimeout.Duration)
+	}
+	return nil
+}
+
+// OrphanedWorkloadPolicy defines how pending PipelineRuns whose Workload was
+// deleted are handled.
+type OrphanedWorkloadPolicy string
+
+const (
+	// OrphanedWorkloadPolicyRecreate leaves it to Kueue to create a new
+	// Workload for the PipelineRun.
+	OrphanedWorkloadPolicyRecreate OrphanedWorkloadPolicy = "Recreate"
+	// OrphanedWorkloadPolicyAnnotate marks the PipelineRun as orphaned and
+	// emits an Event, no new Workload is created.
+	OrphanedWorkloadPolicyAnnotate OrphanedWorkloadPolicy = "Annotate"
+)
+
+const (
+	DefaultQueuePositionInterval          = 30 * time.Second
+	DefaultQueuePositionMaxWritesPerCycle = 50
+	DefaultQueuePositionMaxQueueSize      = 1000
+)
+
+// QueuePosition configures the component annotating gated PipelineRuns
+// with an estimate of their position in the queue.
+type QueuePosition struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Interval is the minimum time between two estimations for the same
+	// ClusterQueue.
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// MaxWritesPerCycle caps the number of PipelineRuns patched in a
+	// single estimation cycle.
+	MaxWritesPerCycle int `json:"maxWritesPerCycle,omitempty"`
+	// MaxQueueSize is the number of pending Workloads above which a
+	// ClusterQueue is skipped.
+	MaxQueueSize int `json:"maxQueueSize,omitempty"`
+	// UseClusterQueueStatus reads the head of the pending Workloads from
+	// the status of the ClusterQueues, when Kueue exposes it, so the
+	// positions of the Workloads at the head are exact. It requires reading
+	// the ClusterQueues.
+	UseClusterQueueStatus bool `json:"useClusterQueueStatus,omitempty"`
+}
+
+// GetInterval returns the configured interval or its default.
+func (q *QueuePosition) GetInterval() time.Duration {
+	if q.Interval == nil || q.Interval.Duration <= 0 {
+		return DefaultQueuePositionInterval
+	}
+	return q.Interval.Duration
+}
+
+// GetMaxWritesPerCycle returns the configured write cap or its default.
+func (q *QueuePosition) GetMaxWritesPerCycle() int {
+	if q.MaxWritesPerCycle <= 0 {
+		return DefaultQueuePositionMaxWritesPerCycle
+	}
+	return q.MaxWritesPerCycle
+}
+
+// GetMaxQueueSize returns the configured queue size limit or its default.
+func (q *QueuePosition) GetMaxQueueSize() int {
+	if q.MaxQueueSize <= 0 {
+		return DefaultQueuePositionMaxQueueSize
+	}
+	return q.MaxQueueSize
+}
+
+const DefaultResolvedRequestsMaxSize = 4096
+
+// ResolvedRequests configures the component annotating pending Workloads
+// with the resources requested by their PipelineRun.
+type ResolvedRequests struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxSize is the maximum size, in bytes, of the annotation value.
+	// Requests which don't fit are dropped and a truncation marker is
+	// added.
+	MaxSize int `json:"maxSize,omitempty"`
+}
+
+// GetMaxSize returns the configured maximum size or its default.
+func (r *ResolvedRequests) GetMaxSize() int {
+	if r.MaxSize <= 0 {
+		return DefaultResolvedRequestsMaxSize
+	}
+	return r.MaxSize
 }
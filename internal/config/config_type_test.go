@@ -0,0 +1,501 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	oldResourcePrefix = "kueue.konflux-ci.dev/requests-"
+	newResourcePrefix = "queue.konflux.dev/requests-"
+)
+
+func TestResourceAnnotationPrefixMigration_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration ResourceAnnotationPrefixMigration
+		errMsg    string
+	}{
+		{
+			name:      "dual-write",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: newResourcePrefix, Mode: ResourceAnnotationPrefixDualWrite},
+		},
+		{
+			name:      "read-both",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: newResourcePrefix, Mode: ResourceAnnotationPrefixReadBoth},
+		},
+		{
+			name:      "new-only",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: newResourcePrefix, Mode: ResourceAnnotationPrefixNewOnly},
+		},
+		{
+			name:      "missing prefix",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, Mode: ResourceAnnotationPrefixDualWrite},
+			errMsg:    "requires both the from and to prefixes",
+		},
+		{
+			name:      "invalid prefix",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: "queue.konflux.dev/requests/", Mode: ResourceAnnotationPrefixDualWrite},
+			errMsg:    "invalid resource annotation prefix",
+		},
+		{
+			name:      "overlapping prefixes",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: oldResourcePrefix + "new-", Mode: ResourceAnnotationPrefixDualWrite},
+			errMsg:    "must not overlap",
+		},
+		{
+			name:      "invalid mode",
+			migration: ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: newResourcePrefix, Mode: "write-both"},
+			errMsg:    "invalid resourceAnnotationPrefixMigration mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := tt.migration.Validate()
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
+func TestResourceAnnotationPrefixMigration_Prefixes(t *testing.T) {
+	tests := []struct {
+		mode  ResourceAnnotationPrefixMode
+		write []string
+		read  []string
+	}{
+		{
+			mode:  ResourceAnnotationPrefixDualWrite,
+			write: []string{newResourcePrefix, oldResourcePrefix},
+			read:  []string{newResourcePrefix, oldResourcePrefix},
+		},
+		{
+			mode:  ResourceAnnotationPrefixReadBoth,
+			write: []string{newResourcePrefix},
+			read:  []string{newResourcePrefix, oldResourcePrefix},
+		},
+		{
+			mode:  ResourceAnnotationPrefixNewOnly,
+			write: []string{newResourcePrefix},
+			read:  []string{newResourcePrefix},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			g := NewWithT(t)
+			migration := ResourceAnnotationPrefixMigration{From: oldResourcePrefix, To: newResourcePrefix, Mode: tt.mode}
+			g.Expect(migration.WritePrefixes()).To(Equal(tt.write))
+			g.Expect(migration.ReadPrefixes()).To(Equal(tt.read))
+		})
+	}
+}
+
+func TestCEL_Validate_CircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker CircuitBreaker
+		errMsg  string
+	}{
+		{
+			name:    "defaults",
+			breaker: CircuitBreaker{Enabled: true},
+		},
+		{
+			name:    "negative threshold",
+			breaker: CircuitBreaker{Enabled: true, FailureThreshold: -1},
+			errMsg:  "failureThreshold must not be negative",
+		},
+		{
+			name:    "negative window",
+			breaker: CircuitBreaker{Enabled: true, Window: &metav1.Duration{Duration: -time.Minute}},
+			errMsg:  "window must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cel := CEL{CircuitBreaker: tt.breaker}
+			err := cel.Validate()
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(tt.breaker.GetFailureThreshold()).To(Equal(DefaultCircuitBreakerFailureThreshold))
+				g.Expect(tt.breaker.GetWindow()).To(Equal(DefaultCircuitBreakerWindow))
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
+func TestCEL_MaxObjectBytes(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{}
+	g.Expect(cel.Validate()).To(Succeed())
+	g.Expect(cel.GetMaxObjectBytes()).To(Equal(DefaultMaxObjectBytes))
+
+	cel.MaxObjectBytes = 512 * 1024
+	g.Expect(cel.Validate()).To(Succeed())
+	g.Expect(cel.GetMaxObjectBytes()).To(Equal(512 * 1024))
+
+	cel.MaxObjectBytes = -1
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("maxObjectBytes must not be negative")))
+}
+
+func TestCEL_MutationLimits(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{}
+	g.Expect(cel.GetMaxMutationsPerObject()).To(Equal(DefaultMaxMutationsPerObject))
+	g.Expect(cel.GetMaxAnnotationBytesPerObject()).To(Equal(DefaultMaxAnnotationBytesPerObject))
+
+	cel = CEL{MaxMutationsPerObject: 500, MaxAnnotationBytesPerObject: 4096}
+	g.Expect(cel.Validate()).To(Succeed())
+	g.Expect(cel.GetMaxMutationsPerObject()).To(Equal(500))
+	g.Expect(cel.GetMaxAnnotationBytesPerObject()).To(Equal(4096))
+
+	cel.MaxMutationsPerObject = -1
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("maxMutationsPerObject must not be negative")))
+	cel = CEL{MaxAnnotationBytesPerObject: -1}
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("maxAnnotationBytesPerObject must not be negative")))
+}
+
+func TestCEL_AllowedResourceRequests(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{PruneUnknownResourceRequests: true, AllowedResourceRequests: []string{"linux-amd64"}}
+	g.Expect(cel.Validate()).To(Succeed())
+
+	cel.AllowedResourceRequests = append(cel.AllowedResourceRequests, "")
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("allowedResourceRequests must not contain empty")))
+}
+
+func TestCEL_Definitions(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{Definitions: map[string]string{"isRelease": `plrPipelineName.startsWith("release-")`, "_v2": "true"}}
+	g.Expect(cel.Validate()).To(Succeed())
+
+	cel.Definitions["is-release"] = "true"
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring(`invalid CEL definition name "is-release"`)))
+	cel.Definitions = map[string]string{"isRelease": " "}
+	g.Expect(cel.Validate()).To(MatchError(`CEL definition "isRelease" is empty`))
+}
+
+func TestCEL_NamedExpressions(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := Config{}
+	g.Expect(yaml.Unmarshal([]byte(`
+cel:
+  expressions:
+    - 'priority("default")'
+    - name: priority-routing
+      expression: 'priority("high")'
+  groups:
+    - name: tenants
+      expressions:
+        - name: team-label
+          expression: 'label("team", "a")'
+`), &cfg)).To(Succeed())
+	g.Expect(cfg.CEL.Expressions).To(Equal([]CELExpression{
+		{Expression: `priority("default")`},
+		{Name: "priority-routing", Expression: `priority("high")`},
+	}))
+	g.Expect(cfg.CEL.Groups[0].Expressions).To(Equal([]CELExpression{{Name: "team-label", Expression: `label("team", "a")`}}))
+	g.Expect(cfg.CEL.Validate()).To(Succeed())
+
+	// The unnamed expressions are written back as strings
+	data, err := yaml.Marshal(cfg.CEL.Expressions)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(MatchYAML(`
+- 'priority("default")'
+- name: priority-routing
+  expression: 'priority("high")'
+`))
+
+	cfg.CEL.Groups[0].Expressions[0].Name = "priority-routing"
+	g.Expect(cfg.CEL.Validate()).To(MatchError(`duplicate CEL expression name "priority-routing"`))
+
+	g.Expect(yaml.Unmarshal([]byte("cel:\n  expressions: [1]\n"), &Config{})).To(
+		MatchError(ContainSubstring("CEL expression must be a string or an object")))
+}
+
+func TestCEL_EvaluationCache(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{EvaluationCache: EvaluationCache{Enabled: true}}
+	g.Expect(cel.Validate()).To(Succeed())
+	g.Expect(cel.EvaluationCache.GetSize()).To(Equal(DefaultEvaluationCacheSize))
+
+	cel.EvaluationCache.Size = 64
+	g.Expect(cel.EvaluationCache.GetSize()).To(Equal(64))
+
+	cel.EvaluationCache.Size = -1
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("evaluationCache size must not be negative")))
+}
+
+func TestCEL_Validate_OverwritePolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, policy := range []OverwritePolicy{"", OverwritePolicyAlways, OverwritePolicyNeverUserSet, OverwritePolicyNever} {
+		cel := CEL{OverwritePolicy: policy}
+		g.Expect(cel.Validate()).To(Succeed())
+	}
+	cel := CEL{OverwritePolicy: "sometimes"}
+	g.Expect(cel.Validate()).To(MatchError(`invalid overwritePolicy "sometimes", expected always, neverUserSet or never`))
+}
+
+func TestPropagationKeys_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   PropagationKeys
+		errMsg string
+	}{
+		{
+			name: "valid keys",
+			keys: PropagationKeys{"kueue.x-k8s.io/priority-class", "acme.io/cost-center", "team"},
+		},
+		{
+			name: "no keys",
+		},
+		{
+			name:   "invalid key",
+			keys:   PropagationKeys{"acme.io/cost center"},
+			errMsg: `invalid propagateToTaskRuns key "acme.io/cost center"`,
+		},
+		{
+			name:   "duplicate key",
+			keys:   PropagationKeys{"team", "team"},
+			errMsg: `duplicate propagateToTaskRuns key "team"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := tt.keys.Validate()
+			if tt.errMsg == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+		})
+	}
+}
+
+func TestConfig_ManagedNamespacesSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := &Config{}
+	selector, err := cfg.ManagedNamespacesSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector).To(BeNil())
+
+	cfg.ManagedNamespaces = &metav1.LabelSelector{MatchLabels: map[string]string{"konflux.ci/type": "user"}}
+	selector, err = cfg.ManagedNamespacesSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector.String()).To(Equal("konflux.ci/type=user"))
+
+	cfg.ManagedNamespaces = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "konflux.ci/type", Operator: "Is"},
+	}}
+	_, err = cfg.ManagedNamespacesSelector()
+	g.Expect(err).To(MatchError(ContainSubstring("invalid managedNamespaces")))
+}
+
+func TestConfig_ManageSelector(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := &Config{}
+	selector, err := cfg.ManageSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector).To(BeNil())
+
+	cfg.Manage = &Manage{Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "pipelinesascode.tekton.dev/event-type", Operator: metav1.LabelSelectorOpExists},
+	}}}
+	selector, err = cfg.ManageSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector.String()).To(Equal("pipelinesascode.tekton.dev/event-type"))
+
+	cfg.Manage.Selector.MatchExpressions[0].Operator = "Is"
+	_, err = cfg.ManageSelector()
+	g.Expect(err).To(MatchError(ContainSubstring("invalid manage selector")))
+}
+
+func TestConfig_ValidateCanaryPercent(t *testing.T) {
+	g := NewWithT(t)
+	for _, percent := range []int{0, 10, 100} {
+		g.Expect((&Config{CanaryPercent: percent}).ValidateCanaryPercent()).To(Succeed())
+	}
+	for _, percent := range []int{-1, 101} {
+		g.Expect((&Config{CanaryPercent: percent}).ValidateCanaryPercent()).
+			To(MatchError(ContainSubstring("invalid canaryPercent")))
+	}
+}
+
+func TestCEL_PerQueue(t *testing.T) {
+	g := NewWithT(t)
+
+	cel := CEL{PerQueue: map[string][]string{
+		"build-queue":   {`label("policy", "build")`},
+		"release-queue": {`label("policy", "release")`},
+	}}
+	g.Expect(cel.Validate()).To(Succeed())
+
+	cel.PerQueue["Release_Queue"] = []string{`label("policy", "release")`}
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring(`invalid perQueue queue name "Release_Queue"`)))
+
+	delete(cel.PerQueue, "Release_Queue")
+	cel.PerQueue["empty-queue"] = nil
+	g.Expect(cel.Validate()).To(MatchError(`perQueue queue "empty-queue" has no expressions`))
+
+	delete(cel.PerQueue, "empty-queue")
+	cel.Groups = []CELGroup{{Name: "queue:build-queue", Expressions: UnnamedExpressions(`priority("high")`)}}
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring(`the "queue:" prefix is reserved`)))
+}
+
+func TestCELValidation_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	validation := CELValidation{}
+	g.Expect(validation.Validate()).To(Succeed())
+
+	validation.AllowedKeyPrefixes = []string{"policies.acme.com/", "tools.acme.com/build/"}
+	g.Expect(validation.Validate()).To(Succeed())
+
+	validation.AllowedKeyPrefixes = []string{"policies.acme.com"}
+	g.Expect(validation.Validate()).To(MatchError(ContainSubstring("must end with a slash")))
+
+	validation.AllowedKeyPrefixes = []string{"Policies_Acme/"}
+	g.Expect(validation.Validate()).To(MatchError(ContainSubstring(`invalid validation allowedKeyPrefixes prefix "Policies_Acme/"`)))
+
+	cel := CEL{Validation: validation}
+	g.Expect(cel.Validate()).To(MatchError(ContainSubstring("allowedKeyPrefixes")))
+}
+
+func TestProtectedKeys_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	keys := ProtectedKeys{
+		Deny:  []string{"pod-security.kubernetes.io/enforce", "acme.com/*"},
+		Allow: []string{"acme.com/team"},
+	}
+	g.Expect(keys.Validate()).To(Succeed())
+
+	keys.Deny = []string{"*"}
+	g.Expect(keys.Validate()).To(MatchError(ContainSubstring("must not be empty")))
+
+	keys.Deny = nil
+	keys.Allow = []string{"acme.com/*/team"}
+	g.Expect(keys.Validate()).To(MatchError(ContainSubstring("* is only allowed at the end")))
+
+	validation := CELValidation{ProtectedKeys: keys}
+	g.Expect(validation.Validate()).To(MatchError(ContainSubstring("protectedKeys")))
+}
+
+func TestDefaultResources_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(DefaultResources(nil).Validate()).To(Succeed())
+	g.Expect(DefaultResources{"konflux-release": {"cpu": "500m", "memory": "2Gi"}}.Validate()).To(Succeed())
+
+	g.Expect(DefaultResources{"": {"cpu": "1"}}.Validate()).
+		To(MatchError("defaultResources: the priority class must not be empty"))
+	g.Expect(DefaultResources{"konflux-release": {"": "1"}}.Validate()).
+		To(MatchError("defaultResources konflux-release: the resource name must not be empty"))
+	g.Expect(DefaultResources{"konflux-release": {"cpu": "two"}}.Validate()).
+		To(MatchError(ContainSubstring(`defaultResources konflux-release: invalid quantity "two" of cpu`)))
+}
+
+func TestRateLimit(t *testing.T) {
+	g := NewWithT(t)
+
+	rateLimit := RateLimit{Enabled: true}
+	g.Expect(rateLimit.Validate()).To(Succeed())
+	g.Expect(rateLimit.GetQPS()).To(BeEquivalentTo(DefaultRateLimitQPS))
+	g.Expect(rateLimit.GetBurst()).To(Equal(DefaultRateLimitBurst))
+	selector, err := rateLimit.ExemptNamespacesSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector).To(BeNil())
+
+	rateLimit.QPS, rateLimit.Burst = 0.5, 3
+	rateLimit.ExemptNamespaces = &metav1.LabelSelector{MatchLabels: map[string]string{"konflux-ci.dev/type": "system"}}
+	g.Expect(rateLimit.GetQPS()).To(Equal(0.5))
+	g.Expect(rateLimit.GetBurst()).To(Equal(3))
+	selector, err = rateLimit.ExemptNamespacesSelector()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(selector.String()).To(Equal("konflux-ci.dev/type=system"))
+
+	g.Expect((&RateLimit{QPS: -1}).Validate()).To(MatchError("rateLimit qps must not be negative, got -1"))
+	g.Expect((&RateLimit{Burst: -1}).Validate()).To(MatchError("rateLimit burst must not be negative, got -1"))
+	rateLimit.ExemptNamespaces = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "konflux-ci.dev/type", Operator: "Is"},
+	}}
+	_, err = rateLimit.ExemptNamespacesSelector()
+	g.Expect(err).To(MatchError(ContainSubstring("invalid rateLimit exemptNamespaces")))
+}
+
+func TestAnnotationMigrations_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(AnnotationMigrations(nil).Validate()).To(Succeed())
+	g.Expect(AnnotationMigrations{
+		{From: "konflux-ci.dev/build-requests-", To: "kueue.konflux-ci.dev/requests-"},
+	}.Validate()).To(Succeed())
+
+	g.Expect(AnnotationMigrations{{From: "konflux-ci.dev/build-requests-"}}.Validate()).
+		To(MatchError("annotationMigrations[0] requires both the from and to prefixes"))
+	g.Expect(AnnotationMigrations{{From: "konflux ci/", To: "kueue.konflux-ci.dev/requests-"}}.Validate()).
+		To(MatchError(ContainSubstring(`annotationMigrations[0]: invalid annotation prefix "konflux ci/"`)))
+	g.Expect(AnnotationMigrations{{From: "acme.io/requests-", To: "acme.io/requests-v2-"}}.Validate()).
+		To(MatchError(`annotationMigrations[0]: prefixes "acme.io/requests-" and "acme.io/requests-v2-" must not overlap`))
+}
+
+func TestDeletionProtectionPolicy_Validate(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, policy := range []DeletionProtectionPolicy{"", DeletionProtectionAnnotate, DeletionProtectionDeny} {
+		g.Expect(policy.Validate()).To(Succeed())
+	}
+	g.Expect(DeletionProtectionPolicy("Block").Validate()).
+		To(MatchError(`invalid deletionProtection "Block", must be one of: Annotate, Deny`))
+}
+
+func TestPreemption_MaxRequeues(t *testing.T) {
+	g := NewWithT(t)
+
+	preemption := &Preemption{Requeue: true}
+	g.Expect(preemption.GetMaxRequeues()).To(Equal(DefaultPreemptionMaxRequeues))
+
+	preemption.MaxRequeues = 1
+	g.Expect(preemption.GetMaxRequeues()).To(Equal(1))
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+	"sigs.k8s.io/kueue/pkg/workload"
+)
+
+// AnnotationPendingAdmissionChecks lists the AdmissionChecks of the
+// Workload of a PipelineRun with their state, sorted by name, e.g.
+// "budget-check=Pending,security-scan=Ready". It's removed once all the
+// checks are Ready.
+const AnnotationPendingAdmissionChecks = annotationDomain + "pending-admission-checks"
+
+// AdmissionChecksReconciler reflects the states of the AdmissionChecks of
+// Workloads onto their PipelineRuns, so users can tell why a PipelineRun
+// whose Workload reserved quota isn't running yet.
+type AdmissionChecksReconciler struct {
+	client client.Client
+}
+
+// NewAdmissionChecksReconciler creates an AdmissionChecksReconciler.
+func NewAdmissionChecksReconciler(c client.Client) *AdmissionChecksReconciler {
+	return &AdmissionChecksReconciler{client: c}
+}
+
+// SetupWithManager registers the reconciler, triggered by the changes of
+// the Workloads owned by PipelineRuns.
+func (r *AdmissionChecksReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("AdmissionChecks").
+		Watches(&kueue.Workload{}, handler.EnqueueRequestsFromMapFunc(enqueueOwningPipelineRun)).
+		Complete(r)
+}
+
+// enqueueOwningPipelineRun maps a Workload to the PipelineRun owning it.
+func enqueueOwningPipelineRun(_ context.Context, obj client.Object) []reconcile.Request {
+	wl, ok := obj.(*kueue.Workload)
+	if !ok {
+		return nil
+	}
+	ref := pipelineRunOwnerRef(wl)
+	if ref == nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: wl.Namespace, Name: ref.Name}}}
+}
+
+// Reconcile updates the annotation of the PipelineRun from the admission
+// checks of its Workload.
+func (r *AdmissionChecksReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	plr := &tekv1.PipelineRun{}
+	if err := r.client.Get(ctx, req.NamespacedName, plr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !plr.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	wl, err := activeWorkloadOf(ctx, r.client, plr)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	value := pendingAdmissionChecks(wl)
+	current, annotated := plr.Annotations[AnnotationPendingAdmissionChecks]
+	if value == current && (value != "") == annotated {
+		return ctrl.Result{}, nil
+	}
+
+	// Fail on conflicts, rather than overwriting the changes made since
+	// the PipelineRun was read, e.g. by the Workload reconciler
+	patch := client.MergeFromWithOptions(plr.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if value == "" {
+		delete(plr.Annotations, AnnotationPendingAdmissionChecks)
+	} else {
+		if plr.Annotations == nil {
+			plr.Annotations = map[string]string{}
+		}
+		plr.Annotations[AnnotationPendingAdmissionChecks] = value
+	}
+	if err := r.client.Patch(ctx, plr, patch); err != nil {
+		if apierrors.IsConflict(err) {
+			// The cache will deliver the newer PipelineRun
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// activeWorkloadOf returns the active Workload owned by the PipelineRun, or
+// nil, using the owner index of the Workloads.
+func activeWorkloadOf(ctx context.Context, c client.Reader, plr *tekv1.PipelineRun) (*kueue.Workload, error) {
+	wls := &kueue.WorkloadList{}
+	if err := c.List(ctx, wls,
+		client.InNamespace(plr.Namespace),
+		client.MatchingFields{jobframework.GetOwnerKey(PLRGVK): plr.Name},
+	); err != nil {
+		return nil, fmt.Errorf("listing workloads: %w", err)
+	}
+	for i := range wls.Items {
+		wl := &wls.Items[i]
+		if pipelineRunOwner(wl) == plr.UID && wl.DeletionTimestamp.IsZero() && !workload.IsFinished(wl) {
+			return wl, nil
+		}
+	}
+	return nil, nil
+}
+
+// pendingAdmissionChecks returns the value of
+// AnnotationPendingAdmissionChecks for the Workload, or an empty string
+// when the Workload is nil or all its admission checks are Ready.
+func pendingAdmissionChecks(wl *kueue.Workload) string {
+	if wl == nil {
+		return ""
+	}
+	checks := make([]string, 0, len(wl.Status.AdmissionChecks))
+	pending := false
+	for _, check := range wl.Status.AdmissionChecks {
+		checks = append(checks, check.Name+"="+string(check.State))
+		pending = pending || check.State != kueue.CheckStateReady
+	}
+	if !pending {
+		return ""
+	}
+	sort.Strings(checks)
+	return strings.Join(checks, ",")
+}
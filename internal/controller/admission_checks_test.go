@@ -0,0 +1,172 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+)
+
+// newIndexedClientBuilder returns a fake client builder with the owner
+// index of the Workloads, as set up by SetupIndexer.
+func newIndexedClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithIndex(&kueue.Workload{}, jobframework.GetOwnerKey(PLRGVK), func(obj client.Object) []string {
+			if ref := pipelineRunOwnerRef(obj.(*kueue.Workload)); ref != nil {
+				return []string{ref.Name}
+			}
+			return nil
+		})
+}
+
+func setAdmissionChecks(wl *kueue.Workload, states map[string]kueue.CheckState) {
+	wl.Status.AdmissionChecks = nil
+	for name, state := range states {
+		wl.Status.AdmissionChecks = append(wl.Status.AdmissionChecks, kueue.AdmissionCheckState{Name: name, State: state})
+	}
+}
+
+func TestAdmissionChecksReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newPendingPipelineRun("plr")
+	wl := admit(newWorkloadFor(plr, "lq", 0, time.Now()))
+	setAdmissionChecks(wl, map[string]kueue.CheckState{
+		"security-scan": kueue.CheckStateReady,
+		"budget-check":  kueue.CheckStatePending,
+	})
+	// A Workload owned by another PipelineRun with the same name
+	other := newWorkloadFor(plr, "lq", 0, time.Now())
+	other.Name = "pipelinerun-plr-previous"
+	other.OwnerReferences[0].UID = "previous-uid"
+	setAdmissionChecks(other, map[string]kueue.CheckState{"other-check": kueue.CheckStateRejected})
+
+	cl := newIndexedClientBuilder().WithObjects(plr, wl, other).Build()
+	r := NewAdmissionChecksReconciler(cl)
+	reconcileAndGet := func() map[string]string {
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(result).To(Equal(ctrl.Result{}))
+		updated := &tekv1.PipelineRun{}
+		g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(plr), updated)).To(Succeed())
+		return updated.Annotations
+	}
+
+	g.Expect(reconcileAndGet()).To(HaveKeyWithValue(AnnotationPendingAdmissionChecks, "budget-check=Pending,security-scan=Ready"))
+
+	// The states change
+	g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(wl), wl)).To(Succeed())
+	setAdmissionChecks(wl, map[string]kueue.CheckState{
+		"security-scan": kueue.CheckStateReady,
+		"budget-check":  kueue.CheckStateRetry,
+	})
+	g.Expect(cl.Update(ctx, wl)).To(Succeed())
+	g.Expect(reconcileAndGet()).To(HaveKeyWithValue(AnnotationPendingAdmissionChecks, "budget-check=Retry,security-scan=Ready"))
+
+	// All the checks are Ready
+	setAdmissionChecks(wl, map[string]kueue.CheckState{
+		"security-scan": kueue.CheckStateReady,
+		"budget-check":  kueue.CheckStateReady,
+	})
+	g.Expect(cl.Update(ctx, wl)).To(Succeed())
+	g.Expect(reconcileAndGet()).NotTo(HaveKey(AnnotationPendingAdmissionChecks))
+
+	// The annotation is removed when the Workload is deleted
+	setAdmissionChecks(wl, map[string]kueue.CheckState{"budget-check": kueue.CheckStatePending})
+	g.Expect(cl.Update(ctx, wl)).To(Succeed())
+	g.Expect(reconcileAndGet()).To(HaveKey(AnnotationPendingAdmissionChecks))
+	g.Expect(cl.Delete(ctx, wl)).To(Succeed())
+	g.Expect(reconcileAndGet()).NotTo(HaveKey(AnnotationPendingAdmissionChecks))
+}
+
+func TestAdmissionChecksReconciler_NoAdmissionChecks(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newPendingPipelineRun("plr")
+	wl := newWorkloadFor(plr, "lq", 0, time.Now())
+	patched := false
+	cl := newIndexedClientBuilder().
+		WithObjects(plr, wl).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patched = true
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	_, err := NewAdmissionChecksReconciler(cl).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(patched).To(BeFalse())
+}
+
+func TestAdmissionChecksReconciler_Conflict(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newPendingPipelineRun("plr")
+	wl := newWorkloadFor(plr, "lq", 0, time.Now())
+	setAdmissionChecks(wl, map[string]kueue.CheckState{"budget-check": kueue.CheckStatePending})
+	var patch client.Patch
+	cl := newIndexedClientBuilder().
+		WithObjects(plr, wl).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, obj client.Object, p client.Patch, _ ...client.PatchOption) error {
+				patch = p
+				return apierrors.NewConflict(tekv1.Resource("pipelineruns"), obj.GetName(), nil)
+			},
+		}).
+		Build()
+
+	result, err := NewAdmissionChecksReconciler(cl).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.Requeue).To(BeTrue())
+
+	// The patch carries the resourceVersion the PipelineRun was read with
+	data, err := patch.Data(plr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).To(ContainSubstring(`"resourceVersion"`))
+}
+
+func TestEnqueueOwningPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+
+	plr := newPendingPipelineRun("plr")
+	g.Expect(enqueueOwningPipelineRun(context.Background(), newWorkloadFor(plr, "lq", 0, time.Now()))).To(Equal([]reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: testNamespace, Name: "plr"}},
+	}))
+
+	wl := newWorkloadFor(plr, "lq", 0, time.Now())
+	wl.OwnerReferences = nil
+	g.Expect(enqueueOwningPipelineRun(context.Background(), wl)).To(BeEmpty())
+}
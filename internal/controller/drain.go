@@ -0,0 +1,211 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/workload"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// AnnotationDrained marks the Workloads deactivated by the drain, which are
+// reactivated when the drain is lifted. Workloads deactivated otherwise,
+// e.g. by Kueue, are left alone.
+const AnnotationDrained = annotationDomain + "drained"
+
+// DefaultDrainReloadInterval is the interval at which the drain setting is
+// read from the configuration file.
+const DefaultDrainReloadInterval = 10 * time.Second
+
+// DrainReconciler stops the admission of new PipelineRuns while the
+// controller drains, e.g. during upgrades. The Workloads of the PipelineRuns
+// which haven't reserved quota are deactivated, so Kueue doesn't admit them,
+// while the PipelineRuns already admitted run to completion. The webhook
+// keeps queuing the new PipelineRuns, and their Workloads are deactivated
+// as they're created. When the drain is lifted, the deactivated Workloads
+// are reactivated and queued again.
+//
+// A Workload admitted by Kueue before it's deactivated runs.
+type DrainReconciler struct {
+	client   client.Client
+	draining atomic.Bool
+}
+
+// NewDrainReconciler creates a DrainReconciler, draining when drain is set.
+func NewDrainReconciler(c client.Client, drain bool) *DrainReconciler {
+	r := &DrainReconciler{client: c}
+	r.SetDraining(drain)
+	return r
+}
+
+// Draining returns whether the controller drains.
+func (r *DrainReconciler) Draining() bool {
+	return r.draining.Load()
+}
+
+// SetDraining starts or lifts the drain, and returns whether it changed.
+// The Workloads deactivated or reactivated as a result are updated by
+// Resync.
+func (r *DrainReconciler) SetDraining(drain bool) bool {
+	if drain {
+		drainActive.Set(1)
+	} else {
+		drainActive.Set(0)
+	}
+	return r.draining.Swap(drain) != drain
+}
+
+// SetupWithManager registers the reconciler, triggered by the changes of
+// the Workloads owned by PipelineRuns.
+func (r *DrainReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ownedByPipelineRun := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		wl, ok := obj.(*kueue.Workload)
+		return ok && pipelineRunOwnerRef(wl) != nil
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("Drain").
+		For(&kueue.Workload{}, builder.WithPredicates(ownedByPipelineRun)).
+		Complete(r)
+}
+
+// Reconcile deactivates the Workload while draining, unless it has
+// reserved quota, and reactivates it once the drain is lifted.
+func (r *DrainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	wl := &kueue.Workload{}
+	if err := r.client.Get(ctx, req.NamespacedName, wl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, client.IgnoreNotFound(r.reconcileWorkload(ctx, wl))
+}
+
+// reconcileWorkload updates the Workload according to the drain.
+func (r *DrainReconciler) reconcileWorkload(ctx context.Context, wl *kueue.Workload) error {
+	if !wl.DeletionTimestamp.IsZero() || workload.IsFinished(wl) {
+		return nil
+	}
+	_, drained := wl.Annotations[AnnotationDrained]
+
+	patch := client.MergeFrom(wl.DeepCopy())
+	switch {
+	case r.Draining() && !drained && workload.IsActive(wl) && !workload.HasQuotaReservation(wl):
+		if wl.Annotations == nil {
+			wl.Annotations = map[string]string{}
+		}
+		wl.Annotations[AnnotationDrained] = "true"
+		wl.Spec.Active = ptr.To(false)
+		ctrl.LoggerFrom(ctx).Info("Deactivating the Workload while draining", "workload", client.ObjectKeyFromObject(wl))
+	case !r.Draining() && drained:
+		delete(wl.Annotations, AnnotationDrained)
+		wl.Spec.Active = ptr.To(true)
+		ctrl.LoggerFrom(ctx).Info("Reactivating the Workload after the drain", "workload", client.ObjectKeyFromObject(wl))
+	default:
+		return nil
+	}
+	return r.client.Patch(ctx, wl, patch)
+}
+
+// Resync updates all the Workloads owned by PipelineRuns according to the
+// drain, after it's started or lifted. The Workloads which can't be updated
+// are reconciled again when they change.
+func (r *DrainReconciler) Resync(ctx context.Context) error {
+	workloads := &kueue.WorkloadList{}
+	if err := r.client.List(ctx, workloads); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range workloads.Items {
+		wl := &workloads.Items[i]
+		if pipelineRunOwnerRef(wl) == nil {
+			continue
+		}
+		if err := r.reconcileWorkload(ctx, wl); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DrainReloader reads the drain setting from the configuration file at
+// regular intervals, so the controller can be drained, and the drain
+// lifted, without restarting it. The rest of the configuration isn't
+// reloaded.
+type DrainReloader struct {
+	path       string
+	interval   time.Duration
+	reconciler *DrainReconciler
+}
+
+// NewDrainReloader returns a DrainReloader updating the drain of the
+// reconciler from the configuration file at path.
+func NewDrainReloader(path string, interval time.Duration, reconciler *DrainReconciler) *DrainReloader {
+	return &DrainReloader{path: path, interval: interval, reconciler: reconciler}
+}
+
+// Start reloads the drain setting until the context is cancelled.
+func (r *DrainReloader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// Reload reads the drain setting from the configuration file, and updates
+// the Workloads when it changed. The current setting is kept when the file
+// can't be read or parsed.
+func (r *DrainReloader) Reload(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx).WithValues("path", r.path)
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "Unable to read the configuration, keeping the drain setting", "drain", r.reconciler.Draining())
+		return
+	}
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		log.Error(err, "Unable to parse the configuration, keeping the drain setting", "drain", r.reconciler.Draining())
+		return
+	}
+	if !r.reconciler.SetDraining(cfg.Controller.Drain) {
+		return
+	}
+	if cfg.Controller.Drain {
+		log.Info("Draining, the new PipelineRuns aren't admitted")
+	} else {
+		log.Info("Lifted the drain, the pending PipelineRuns are queued again")
+	}
+	if err := r.reconciler.Resync(ctx); err != nil {
+		log.Error(err, "Unable to update some Workloads after the drain setting changed")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+)
+
+// getWorkload returns the current state of the Workload.
+func getWorkload(g Gomega, c client.Client, wl *kueue.Workload) *kueue.Workload {
+	updated := &kueue.Workload{}
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(wl), updated)).To(Succeed())
+	return updated
+}
+
+func TestDrainReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	pending := newWorkloadFor(newPendingPipelineRun("pending"), "lq", 0, time.Now())
+	admitted := admit(newWorkloadFor(newPendingPipelineRun("admitted"), "lq", 0, time.Now()))
+	// Deactivated by Kueue, e.g. after too many evictions
+	deactivated := newWorkloadFor(newPendingPipelineRun("deactivated"), "lq", 0, time.Now())
+	deactivated.Spec.Active = ptr.To(false)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(pending, admitted, deactivated).Build()
+
+	r := NewDrainReconciler(cl, true)
+	g.Expect(testutil.ToFloat64(drainActive)).To(Equal(1.0))
+	reconcile := func(wl *kueue.Workload) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	// The pending Workload is deactivated, the admitted one runs
+	for _, wl := range []*kueue.Workload{pending, admitted, deactivated} {
+		reconcile(wl)
+	}
+	g.Expect(getWorkload(g, cl, pending).Spec.Active).To(Equal(ptr.To(false)))
+	g.Expect(getWorkload(g, cl, pending).Annotations).To(HaveKeyWithValue(AnnotationDrained, "true"))
+	g.Expect(getWorkload(g, cl, admitted).Spec.Active).To(BeNil())
+	g.Expect(getWorkload(g, cl, admitted).Annotations).NotTo(HaveKey(AnnotationDrained))
+	g.Expect(getWorkload(g, cl, deactivated).Annotations).NotTo(HaveKey(AnnotationDrained))
+
+	// Lifting the drain only reactivates the Workloads it deactivated
+	g.Expect(r.SetDraining(false)).To(BeTrue())
+	g.Expect(r.SetDraining(false)).To(BeFalse())
+	g.Expect(testutil.ToFloat64(drainActive)).To(Equal(0.0))
+	g.Expect(r.Resync(ctx)).To(Succeed())
+	g.Expect(getWorkload(g, cl, pending).Spec.Active).To(Equal(ptr.To(true)))
+	g.Expect(getWorkload(g, cl, pending).Annotations).NotTo(HaveKey(AnnotationDrained))
+	g.Expect(getWorkload(g, cl, deactivated).Spec.Active).To(Equal(ptr.To(false)))
+}
+
+func TestDrainReconciler_ReconcileMissingWorkload(t *testing.T) {
+	g := NewWithT(t)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).Build()
+
+	r := NewDrainReconciler(cl, true)
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: testNamespace, Name: "missing"}})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestDrainReloader_Reload(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	wl := newWorkloadFor(newPendingPipelineRun("plr"), "lq", 0, time.Now())
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(wl).Build()
+	r := NewDrainReconciler(cl, false)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	reloader := NewDrainReloader(path, time.Second, r)
+	writeConfig := func(content string) {
+		g.Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	// A PipelineRun created during the drain isn't admitted
+	writeConfig("controller:\n  drain: true\n")
+	reloader.Reload(ctx)
+	g.Expect(r.Draining()).To(BeTrue())
+	g.Expect(getWorkload(g, cl, wl).Spec.Active).To(Equal(ptr.To(false)))
+
+	// The drain is kept while the configuration is invalid
+	writeConfig("controller: [")
+	reloader.Reload(ctx)
+	g.Expect(r.Draining()).To(BeTrue())
+	g.Expect(os.Remove(path)).To(Succeed())
+	reloader.Reload(ctx)
+	g.Expect(r.Draining()).To(BeTrue())
+
+	// It's queued again once the drain is lifted
+	writeConfig("queueName: pipelines-queue\n")
+	reloader.Reload(ctx)
+	g.Expect(r.Draining()).To(BeFalse())
+	g.Expect(getWorkload(g, cl, wl).Spec.Active).To(Equal(ptr.To(true)))
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	kapi "knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+	"sigs.k8s.io/kueue/pkg/workload"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+const (
+	conformanceNamespace    = "conformance"
+	conformanceFlavor       = "default"
+	conformanceClusterQueue = "cq"
+)
+
+// The GenericJob contract of the PipelineRun adapter, exercised by the
+// jobframework reconciler of Kueue. There's no Kueue scheduler nor Tekton
+// controller: the Workloads are admitted and evicted, and the PipelineRuns
+// are finished, by the tests.
+var _ = Describe("GenericJob conformance", Ordered, func() {
+	var mgrCancel context.CancelFunc
+
+	BeforeAll(func() {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:  scheme.Scheme,
+			Metrics: metricsserver.Options{BindAddress: "0"},
+			// SetupWithManager is also called by the unit tests
+			Controller: ctrlconfig.Controller{SkipNameValidation: ptr.To(true)},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(SetupIndexer(ctx, mgr.GetFieldIndexer())).To(Succeed())
+		Expect(SetupWithManager(mgr, config.Controller{})).To(Succeed())
+
+		var mgrCtx context.Context
+		mgrCtx, mgrCancel = context.WithCancel(ctx)
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(mgrCtx)).To(Succeed())
+		}()
+
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: conformanceNamespace}})).
+			To(Succeed())
+		Expect(k8sClient.Create(ctx, &kueue.ResourceFlavor{ObjectMeta: metav1.ObjectMeta{Name: conformanceFlavor}})).
+			To(Succeed())
+	})
+
+	AfterAll(func() {
+		mgrCancel()
+	})
+
+	It("creates a Workload for a pending PipelineRun", func() {
+		plr := createQueuedPipelineRun("created")
+
+		wl := eventuallyWorkloadOf(plr)
+		Expect(wl.Spec.QueueName).To(Equal("lq"))
+		Expect(wl.Spec.PodSets).To(HaveLen(1))
+		Expect(wl.Spec.PodSets[0].Name).To(Equal(kueue.PodSetReference(common.DefaultPodSetName)))
+		Expect(wl.Spec.PodSets[0].Template.Spec.Containers).NotTo(BeEmpty())
+		Expect(wl.Spec.PodSets[0].Template.Spec.Containers[0].Resources.Requests).
+			To(HaveKeyWithValue(corev1.ResourceName(ResourcePipelineRunCount), resource.MustParse("1")))
+		Expect(workload.HasQuotaReservation(wl)).To(BeFalse())
+
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+			g.Expect(plr.Spec.Status).To(Equal(tekv1.PipelineRunSpecStatusPending))
+		}, time.Second).Should(Succeed())
+	})
+
+	It("clears the pending status of the PipelineRun when its Workload is admitted", func() {
+		plr := createQueuedPipelineRun("admitted")
+
+		admitWorkload(eventuallyWorkloadOf(plr))
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+			g.Expect(plr.Spec.Status).To(BeEmpty())
+		}).Should(Succeed())
+	})
+
+	It("stops the PipelineRun when its Workload is evicted", func() {
+		plr := createQueuedPipelineRun("evicted")
+		admitWorkload(eventuallyWorkloadOf(plr))
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+			g.Expect(plr.Spec.Status).To(BeEmpty())
+		}).Should(Succeed())
+
+		wl := eventuallyWorkloadOf(plr)
+		workload.SetEvictedCondition(wl, kueue.WorkloadEvictedByPreemption, "Preempted to accommodate a higher priority Workload")
+		Expect(workload.ApplyAdmissionStatus(ctx, k8sClient, wl, false, clock.RealClock{})).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+			g.Expect(plr.Spec.Status).To(Equal(tekv1.PipelineRunSpecStatusStoppedRunFinally))
+		}).Should(Succeed())
+		// The PipelineRun isn't active since it never started, so the quota
+		// reservation of its Workload is released
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(wl), wl)).To(Succeed())
+			g.Expect(workload.HasQuotaReservation(wl)).To(BeFalse())
+		}).Should(Succeed())
+	})
+
+	DescribeTable("finishes the Workload when the PipelineRun is done",
+		func(name string, status corev1.ConditionStatus, reason tekv1.PipelineRunReason, message, finishedReason, finishedMessage string) {
+			plr := createQueuedPipelineRun(name)
+			admitWorkload(eventuallyWorkloadOf(plr))
+
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+				g.Expect(plr.Spec.Status).To(BeEmpty())
+				now := metav1.Now()
+				plr.Status.StartTime = &now
+				plr.Status.CompletionTime = &now
+				plr.Status.Conditions = duckv1.Conditions{{
+					Type:    kapi.ConditionSucceeded,
+					Status:  status,
+					Reason:  reason.String(),
+					Message: message,
+				}}
+				g.Expect(k8sClient.Update(ctx, plr)).To(Succeed())
+			}).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				wl := &kueue.Workload{}
+				g.Expect(k8sClient.Get(ctx, workloadKeyOf(plr), wl)).To(Succeed())
+				finished := apimeta.FindStatusCondition(wl.Status.Conditions, kueue.WorkloadFinished)
+				g.Expect(finished).NotTo(BeNil())
+				g.Expect(finished.Status).To(Equal(metav1.ConditionTrue))
+				g.Expect(finished.Reason).To(Equal(finishedReason))
+				g.Expect(finished.Message).To(Equal(finishedMessage))
+			}).Should(Succeed())
+		},
+		Entry("on success", "succeeded", corev1.ConditionTrue, tekv1.PipelineRunReasonSuccessful,
+			"Tasks Completed: 1 (Failed: 0, Cancelled 0), Skipped: 0",
+			kueue.WorkloadFinishedReasonSucceeded, "Tasks Completed: 1 (Failed: 0, Cancelled 0), Skipped: 0"),
+		Entry("on failure", "failed", corev1.ConditionFalse, tekv1.PipelineRunReasonFailed,
+			"Tasks Completed: 1 (Failed: 1, Cancelled 0), Skipped: 0",
+			kueue.WorkloadFinishedReasonFailed, "Tasks Completed: 1 (Failed: 1, Cancelled 0), Skipped: 0"),
+		Entry("on cancellation", "cancelled", corev1.ConditionFalse, tekv1.PipelineRunReasonCancelled, "",
+			kueue.WorkloadFinishedReasonFailed, tekv1.PipelineRunReasonCancelled.String()),
+		Entry("on timeout", "timed-out", corev1.ConditionFalse, tekv1.PipelineRunReasonTimedOut, "",
+			kueue.WorkloadFinishedReasonFailed, tekv1.PipelineRunReasonTimedOut.String()),
+	)
+})
+
+// createQueuedPipelineRun creates a pending PipelineRun of the conformance
+// namespace, queued to the lq LocalQueue, as the webhook would.
+func createQueuedPipelineRun(name string) *tekv1.PipelineRun {
+	plr := newPendingPipelineRun(name)
+	plr.Namespace = conformanceNamespace
+	plr.UID = ""
+	plr.Labels = map[string]string{common.QueueLabel: "lq"}
+	plr.Spec.PipelineRef = &tekv1.PipelineRef{Name: "pipeline"}
+	Expect(k8sClient.Create(ctx, plr)).To(Succeed())
+	return plr
+}
+
+// workloadKeyOf returns the key of the Workload created by the jobframework
+// reconciler for the PipelineRun.
+func workloadKeyOf(plr *tekv1.PipelineRun) client.ObjectKey {
+	return client.ObjectKey{
+		Namespace: plr.Namespace,
+		Name:      jobframework.GetWorkloadNameForOwnerWithGVK(plr.Name, plr.UID, PLRGVK),
+	}
+}
+
+// eventuallyWorkloadOf waits for the Workload of the PipelineRun and
+// returns it.
+func eventuallyWorkloadOf(plr *tekv1.PipelineRun) *kueue.Workload {
+	GinkgoHelper()
+	wl := &kueue.Workload{}
+	Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(ctx, workloadKeyOf(plr), wl)).To(Succeed())
+		g.Expect(pipelineRunOwner(wl)).To(Equal(plr.UID))
+	}).Should(Succeed())
+	return wl
+}
+
+// admitWorkload reserves quota for the Workload in the conformance
+// ClusterQueue and admits it, as the Kueue scheduler would.
+func admitWorkload(wl *kueue.Workload) {
+	GinkgoHelper()
+	admission := &kueue.Admission{ClusterQueue: conformanceClusterQueue}
+	for _, ps := range wl.Spec.PodSets {
+		flavors := map[corev1.ResourceName]kueue.ResourceFlavorReference{}
+		for name := range ps.Template.Spec.Containers[0].Resources.Requests {
+			flavors[name] = conformanceFlavor
+		}
+		admission.PodSetAssignments = append(admission.PodSetAssignments, kueue.PodSetAssignment{
+			Name:    ps.Name,
+			Flavors: flavors,
+			Count:   ptr.To(ps.Count),
+		})
+	}
+	workload.SetQuotaReservation(wl, admission, clock.RealClock{})
+	workload.SyncAdmittedCondition(wl, time.Now())
+	Expect(workload.ApplyAdmissionStatus(ctx, k8sClient, wl, false, clock.RealClock{})).To(Succeed())
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// jobConfig is the configuration of the PipelineRun jobs and of their
+// Workload reconciler. It's built by SetupWithManager and captured by the
+// job constructor of the reconciler, which sets it on every PipelineRun.
+type jobConfig struct {
+	// resourceAnnotationPrefixes are the prefixes of the annotations
+	// holding the resource requests, by decreasing precedence.
+	resourceAnnotationPrefixes []string
+	// recorder records the events of the PipelineRuns whose resource or pod
+	// sets annotations are invalid, nil meaning they're only logged.
+	recorder record.EventRecorder
+	// clock measures the queue duration and the stop grace period.
+	clock clock.PassiveClock
+	// queueDurationNamespaceLabel labels the queue duration with the
+	// namespace of the PipelineRuns.
+	queueDurationNamespaceLabel bool
+	// adoptRunning lets the Workload reconciler manage the PipelineRuns
+	// which started without the queue label, see startedUnqueued.
+	adoptRunning bool
+	// preemptionRequeue makes the PipelineRuns whose Workload is preempted
+	// be copied before they're stopped, up to preemptionMaxRequeues copies
+	// per original PipelineRun, see requeueIfPreempted.
+	preemptionRequeue     bool
+	preemptionMaxRequeues int
+	// stopGracePeriod is the age past which the PipelineRuns which started
+	// without being admitted aren't stopped, zero disabling it, see
+	// missedAdmission.
+	stopGracePeriod time.Duration
+	// managedNamespaces selects the namespaces whose PipelineRuns are
+	// queued, nil meaning all of them.
+	managedNamespaces labels.Selector
+	// managedLabelRequired restricts the PipelineRuns to the ones the
+	// webhook marked with the managed label.
+	managedLabelRequired bool
+}
+
+// SetupOption configures the PipelineRun reconciler set up by
+// SetupWithManager.
+type SetupOption func(*jobConfig)
+
+// WithResourceAnnotationPrefixes replaces the prefixes of the annotations
+// holding the resource requests, by decreasing precedence, while the
+// annotations are migrated to a new prefix.
+func WithResourceAnnotationPrefixes(prefixes []string) SetupOption {
+	return func(c *jobConfig) {
+		c.resourceAnnotationPrefixes = prefixes
+	}
+}
+
+// WithManagedNamespaces restricts the PipelineRuns managed by the
+// controller to the namespaces matching the selector, the ones the webhook
+// queues.
+func WithManagedNamespaces(selector labels.Selector) SetupOption {
+	return func(c *jobConfig) {
+		c.managedNamespaces = selector
+	}
+}
+
+// WithManagedLabelRequired restricts the PipelineRuns managed by the
+// controller to the ones marked with the managed label. It's set when the
+// webhook only queues the PipelineRuns matching the manage selector.
+func WithManagedLabelRequired(required bool) SetupOption {
+	return func(c *jobConfig) {
+		c.managedLabelRequired = required
+	}
+}
+
+// defaultJobConfig returns the configuration of the PipelineRuns built
+// without SetupWithManager, with the optional behaviors disabled.
+func defaultJobConfig() *jobConfig {
+	return &jobConfig{
+		resourceAnnotationPrefixes:  []string{annotationResourcesRequests},
+		clock:                       clock.RealClock{},
+		queueDurationNamespaceLabel: true,
+	}
+}
+
+// newJobConfig returns the configuration of the PipelineRuns for the
+// controller configuration and the options.
+func newJobConfig(cfg config.Controller, opts ...SetupOption) (*jobConfig, error) {
+	if err := cfg.ValidateStopGracePeriod(); err != nil {
+		return nil, err
+	}
+	c := defaultJobConfig()
+	c.queueDurationNamespaceLabel = !cfg.Metrics.DisableNamespaceLabel
+	c.adoptRunning = cfg.AdoptRunningPipelineRuns
+	c.preemptionRequeue = cfg.Preemption.Requeue
+	c.preemptionMaxRequeues = cfg.Preemption.GetMaxRequeues()
+	c.stopGracePeriod = cfg.GetStopGracePeriod()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// hasManagedLabel filters out the events of the PipelineRuns without the
+// managed label, which the webhook admitted untouched, so the controller
+// and the webhook agree on the PipelineRuns they manage even when the
+// manage selector is reloaded. The other objects are kept, and so are the
+// PipelineRuns which are done, so their Workloads are finished.
+func hasManagedLabel() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		plr, ok := obj.(*tekv1.PipelineRun)
+		if !ok || plr.IsDone() {
+			return true
+		}
+		return plr.Labels[common.ManagedLabel] == "true"
+	})
+}
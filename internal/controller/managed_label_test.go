@@ -0,0 +1,55 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kapi "knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+func TestHasManagedLabel(t *testing.T) {
+	g := NewWithT(t)
+	p := hasManagedLabel()
+
+	withLabels := func(labels map[string]string) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "plr", Namespace: "tenant", Labels: labels}}
+	}
+	managed := withLabels(map[string]string{common.ManagedLabel: "true"})
+	unmanaged := withLabels(map[string]string{common.QueueLabel: "pipelines-queue"})
+	g.Expect(p.Create(event.CreateEvent{Object: managed})).To(BeTrue())
+	g.Expect(p.Create(event.CreateEvent{Object: unmanaged})).To(BeFalse())
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: unmanaged, ObjectNew: unmanaged})).To(BeFalse())
+	g.Expect(p.Delete(event.DeleteEvent{
+		Object: &kueue.Workload{ObjectMeta: metav1.ObjectMeta{Name: "wl", Namespace: "tenant"}},
+	})).To(BeTrue())
+
+	// The PipelineRuns which are done are reconciled, so their Workload is
+	// finished
+	done := unmanaged.DeepCopy()
+	done.Status.Conditions = duckv1.Conditions{{Type: kapi.ConditionSucceeded, Status: corev1.ConditionTrue}}
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: unmanaged, ObjectNew: done})).To(BeTrue())
+}
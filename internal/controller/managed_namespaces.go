@@ -0,0 +1,49 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// inManagedNamespaces filters out the events of the objects of the
+// namespaces not matching the selector, so the controller doesn't create
+// Workloads for the PipelineRuns the webhook didn't queue, and doesn't stop
+// them. The objects whose namespace can't be read are kept, and so are the
+// PipelineRuns which are done, so the Workloads of the PipelineRuns queued
+// before their namespace stopped matching are finished and release their
+// quota.
+func inManagedNamespaces(reader client.Reader, selector labels.Selector) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		if plr, ok := obj.(*tekv1.PipelineRun); ok && plr.IsDone() {
+			return true
+		}
+		ns := &corev1.Namespace{}
+		if err := reader.Get(context.Background(), client.ObjectKey{Name: obj.GetNamespace()}, ns); err != nil {
+			PLRLog.Error(err, "Unable to get the namespace, the object is reconciled",
+				"namespace", obj.GetNamespace(), "name", obj.GetName())
+			return true
+		}
+		return selector.Matches(labels.Set(ns.Labels))
+	})
+}
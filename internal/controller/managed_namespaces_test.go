@@ -0,0 +1,59 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kapi "knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+)
+
+func TestInManagedNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Labels: map[string]string{"konflux.ci/type": "user"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "infra"}},
+	).Build()
+	p := inManagedNamespaces(cl, labels.SelectorFromSet(labels.Set{"konflux.ci/type": "user"}))
+
+	inNamespace := func(namespace string) *tekv1.PipelineRun {
+		return &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "plr", Namespace: namespace}}
+	}
+	g.Expect(p.Create(event.CreateEvent{Object: inNamespace("tenant")})).To(BeTrue())
+	g.Expect(p.Create(event.CreateEvent{Object: inNamespace("infra")})).To(BeFalse())
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: inNamespace("infra"), ObjectNew: inNamespace("infra")})).To(BeFalse())
+	g.Expect(p.Delete(event.DeleteEvent{
+		Object: &kueue.Workload{ObjectMeta: metav1.ObjectMeta{Name: "wl", Namespace: "infra"}},
+	})).To(BeFalse())
+	// The objects whose namespace can't be read are reconciled
+	g.Expect(p.Create(event.CreateEvent{Object: inNamespace("missing")})).To(BeTrue())
+
+	// The PipelineRuns which are done are reconciled, so their Workload is
+	// finished
+	done := inNamespace("infra")
+	done.Status.Conditions = duckv1.Conditions{{Type: kapi.ConditionSucceeded, Status: corev1.ConditionFalse}}
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: inNamespace("infra"), ObjectNew: done})).To(BeTrue())
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+)
+
+const (
+	propagationResultSuccess  = "success"
+	propagationResultConflict = "conflict"
+	propagationResultFailure  = "failure"
+)
+
+var (
+	// taskRunPropagationWritesTotal tracks the patches of the TaskRuns
+	// propagating the labels and annotations of their PipelineRun
+	taskRunPropagationWritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_taskrun_propagation_writes_total",
+			Help: "Total number of TaskRun patches propagating the labels and annotations of their PipelineRun",
+		},
+		// result can be "success", "conflict" or "failure"
+		[]string{"result"},
+	)
+
+	// pipelineRunQueueDuration tracks the time the PipelineRuns waited
+	// between their creation and their admission by Kueue
+	pipelineRunQueueDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "tekton_kueue_pipelinerun_queue_duration_seconds",
+			Help: "Time between the creation of PipelineRuns and their admission by Kueue, in seconds",
+			// From 1s to ~4.5h
+			Buckets: prometheus.ExponentialBuckets(1, 2, 15),
+		},
+		// namespace is empty when the label is disabled
+		[]string{"namespace", "priority_class"},
+	)
+
+	// missedAdmissionsTotal tracks the PipelineRuns which weren't stopped
+	// since they started without being admitted, past the stop grace period
+	missedAdmissionsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_missed_admissions_total",
+			Help: "Total number of refused stops of PipelineRuns which started without being admitted, past the stop grace period",
+		},
+	)
+
+	// drainActive is 1 while the controller drains, see DrainReconciler
+	drainActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tekton_kueue_drain_active",
+			Help: "Whether the controller drains, not admitting new PipelineRuns (1) or not (0)",
+		},
+	)
+)
+
+func init() {
+	// Register the metrics with controller-runtime's global registry
+	metrics.Registry.MustRegister(taskRunPropagationWritesTotal, pipelineRunQueueDuration, missedAdmissionsTotal, drainActive)
+}
+
+// recordPropagationWrite increments the counter of the TaskRun patches with
+// the result.
+func recordPropagationWrite(result string) {
+	taskRunPropagationWritesTotal.WithLabelValues(result).Inc()
+}
+
+// recordMissedAdmission increments the counter of the refused stops of the
+// PipelineRuns which started without being admitted.
+func recordMissedAdmission() {
+	missedAdmissionsTotal.Inc()
+}
+
+// observeQueueDuration records the time the PipelineRun waited since its
+// creation, when it's admitted. PipelineRuns without creation timestamp
+// aren't recorded.
+func observeQueueDuration(p *PipelineRun) {
+	if p.CreationTimestamp.IsZero() {
+		return
+	}
+	namespace := ""
+	if p.config.queueDurationNamespaceLabel {
+		namespace = p.Namespace
+	}
+	waited := p.config.clock.Since(p.CreationTimestamp.Time)
+	pipelineRunQueueDuration.
+		WithLabelValues(namespace, p.Labels[kueueconstants.WorkloadPriorityClassLabel]).
+		Observe(max(waited, 0).Seconds())
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+)
+
+// missedAdmission returns whether the PipelineRun started without being
+// admitted more than the stop grace period after its creation, and so
+// mustn't be stopped for the reason.
+//
+// They're the PipelineRuns created without the Pending status, e.g. while
+// the webhook was down, which Tekton started before the jobframework got a
+// chance to stop them. Stopping them now would cancel live builds, so
+// they're left running while their Workload is created and admitted
+// afterwards. The preempted PipelineRuns and the ones whose Workload is
+// deleted are still stopped.
+func (p *PipelineRun) missedAdmission(stopReason jobframework.StopReason) bool {
+	if p.config.stopGracePeriod <= 0 {
+		return false
+	}
+	if stopReason != jobframework.StopReasonNotAdmitted && stopReason != jobframework.StopReasonNoMatchingWorkload {
+		return false
+	}
+	if !p.HasStarted() || p.CreationTimestamp.IsZero() {
+		return false
+	}
+	return p.config.clock.Since(p.CreationTimestamp.Time) > p.config.stopGracePeriod
+}
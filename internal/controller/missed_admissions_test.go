@@ -0,0 +1,132 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+)
+
+// stopGracePeriodConfig returns the configuration with the stop grace
+// period, whose clock is at now.
+func stopGracePeriodConfig(gracePeriod time.Duration, now time.Time) *jobConfig {
+	cfg := defaultJobConfig()
+	cfg.stopGracePeriod = gracePeriod
+	cfg.clock = clocktesting.NewFakePassiveClock(now)
+	return cfg
+}
+
+func TestStop_MissedAdmission(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		gracePeriod     time.Duration
+		age             time.Duration
+		pending         bool
+		stopReason      jobframework.StopReason
+		expectedStopped bool
+	}{
+		{
+			name:            "inside the grace period",
+			gracePeriod:     30 * time.Second,
+			age:             10 * time.Second,
+			stopReason:      jobframework.StopReasonNotAdmitted,
+			expectedStopped: true,
+		},
+		{
+			name:        "outside the grace period",
+			gracePeriod: 30 * time.Second,
+			age:         time.Minute,
+			stopReason:  jobframework.StopReasonNotAdmitted,
+		},
+		{
+			name:        "outside the grace period without Workload",
+			gracePeriod: 30 * time.Second,
+			age:         time.Minute,
+			stopReason:  jobframework.StopReasonNoMatchingWorkload,
+		},
+		{
+			name:            "grace period disabled",
+			age:             time.Hour,
+			stopReason:      jobframework.StopReasonNotAdmitted,
+			expectedStopped: true,
+		},
+		{
+			name:            "not started",
+			gracePeriod:     30 * time.Second,
+			age:             time.Minute,
+			pending:         true,
+			stopReason:      jobframework.StopReasonNotAdmitted,
+			expectedStopped: true,
+		},
+		{
+			name:            "evicted",
+			gracePeriod:     30 * time.Second,
+			age:             time.Minute,
+			stopReason:      jobframework.StopReasonWorkloadEvicted,
+			expectedStopped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cfg := stopGracePeriodConfig(tt.gracePeriod, created.Add(tt.age))
+
+			plr := newRunningPipelineRun("missed-admission")
+			plr.CreationTimestamp = metav1.NewTime(created)
+			if tt.pending {
+				plr = newPendingPipelineRun("missed-admission")
+				plr.CreationTimestamp = metav1.NewTime(created)
+			}
+			var patched bool
+			cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(plr).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						patched = obj.(*tekv1.PipelineRun).Spec.Status == tekv1.PipelineRunSpecStatusStoppedRunFinally
+						return nil
+					},
+				}).
+				Build()
+			before := testutil.ToFloat64(missedAdmissionsTotal)
+
+			stopped, err := newPipelineRunJob(plr, cfg).Stop(context.Background(), cl, nil, tt.stopReason, "Not admitted by cluster queue")
+
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(stopped).To(Equal(tt.expectedStopped))
+			g.Expect(patched).To(Equal(tt.expectedStopped))
+			missed := testutil.ToFloat64(missedAdmissionsTotal) - before
+			if tt.expectedStopped {
+				g.Expect(missed).To(BeZero())
+			} else {
+				g.Expect(missed).To(Equal(1.0))
+			}
+		})
+	}
+}
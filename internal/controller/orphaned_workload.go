@@ -0,0 +1,177 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+const (
+	// AnnotationOrphaned is set to "true" on pending PipelineRuns whose
+	// Workload was deleted when the Annotate orphaned workload policy is
+	// used. Kueue ignores such PipelineRuns; removing the annotation lets
+	// Kueue create a new Workload for them.
+	AnnotationOrphaned = annotationDomain + "orphaned"
+
+	// ReasonWorkloadDeleted is the reason of the Event emitted for orphaned
+	// PipelineRuns.
+	ReasonWorkloadDeleted = "WorkloadDeleted"
+
+	// orphanGracePeriod is the time to wait after a Workload is deleted
+	// before checking whether it was replaced.
+	orphanGracePeriod = 5 * time.Second
+)
+
+// OrphanedWorkloadReconciler marks pending PipelineRuns whose Workload was
+// deleted, e.g. by an admin, so users know why they aren't admitted.
+//
+// It is only used with the Annotate policy, in which case the Workload
+// reconciler ignores Workload deletions (see ignoreWorkloadDeletions) so that
+// Kueue doesn't create a new Workload right away.
+type OrphanedWorkloadReconciler struct {
+	client client.Client
+	// reader reads directly from the API server, so Workloads created
+	// after the deletion are seen even if the cache is stale.
+	reader      client.Reader
+	recorder    record.EventRecorder
+	policy      config.OrphanedWorkloadPolicy
+	gracePeriod time.Duration
+}
+
+// NewOrphanedWorkloadReconciler creates an OrphanedWorkloadReconciler.
+func NewOrphanedWorkloadReconciler(
+	c client.Client,
+	reader client.Reader,
+	recorder record.EventRecorder,
+	policy config.OrphanedWorkloadPolicy,
+) *OrphanedWorkloadReconciler {
+	return &OrphanedWorkloadReconciler{
+		client:      c,
+		reader:      reader,
+		recorder:    recorder,
+		policy:      policy,
+		gracePeriod: orphanGracePeriod,
+	}
+}
+
+// SetupWithManager registers the reconciler, triggered by the deletion of
+// Workloads owned by PipelineRuns.
+func (r *OrphanedWorkloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("OrphanedWorkloads").
+		Watches(&kueue.Workload{}, handler.Funcs{DeleteFunc: r.enqueueOwner}).
+		Complete(r)
+}
+
+// enqueueOwner enqueues the PipelineRun owning the deleted Workload after the
+// grace period.
+func (r *OrphanedWorkloadReconciler) enqueueOwner(
+	_ context.Context,
+	e event.TypedDeleteEvent[client.Object],
+	q workqueue.TypedRateLimitingInterface[reconcile.Request],
+) {
+	wl, ok := e.Object.(*kueue.Workload)
+	if !ok {
+		return
+	}
+	if ref := pipelineRunOwnerRef(wl); ref != nil {
+		q.AddAfter(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: wl.Namespace, Name: ref.Name}}, r.gracePeriod)
+	}
+}
+
+// Reconcile marks the PipelineRun as orphaned if it's still pending and none
+// of its Workloads exist.
+func (r *OrphanedWorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if r.policy != config.OrphanedWorkloadPolicyAnnotate {
+		return ctrl.Result{}, nil
+	}
+
+	plr := &tekv1.PipelineRun{}
+	if err := r.client.Get(ctx, req.NamespacedName, plr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if plr.Spec.Status != tekv1.PipelineRunSpecStatusPending || plr.HasStarted() || plr.IsDone() {
+		return ctrl.Result{}, nil
+	}
+	if plr.Annotations[AnnotationOrphaned] == "true" {
+		return ctrl.Result{}, nil
+	}
+
+	hasWorkload, err := r.hasWorkload(ctx, plr)
+	if err != nil || hasWorkload {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Marking PipelineRun as orphaned")
+	patch := client.MergeFrom(plr.DeepCopy())
+	if plr.Annotations == nil {
+		plr.Annotations = map[string]string{}
+	}
+	plr.Annotations[AnnotationOrphaned] = "true"
+	if err := r.client.Patch(ctx, plr, patch); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	r.recorder.Eventf(plr, corev1.EventTypeWarning, ReasonWorkloadDeleted,
+		"The Workload of the PipelineRun was deleted, the PipelineRun won't be admitted. "+
+			"Remove the %s annotation to queue it again", AnnotationOrphaned)
+	return ctrl.Result{}, nil
+}
+
+// hasWorkload checks if a Workload owned by the PipelineRun exists.
+func (r *OrphanedWorkloadReconciler) hasWorkload(ctx context.Context, plr *tekv1.PipelineRun) (bool, error) {
+	wls := &kueue.WorkloadList{}
+	if err := r.reader.List(ctx, wls, client.InNamespace(plr.Namespace)); err != nil {
+		return false, fmt.Errorf("listing workloads: %w", err)
+	}
+	for i := range wls.Items {
+		if pipelineRunOwner(&wls.Items[i]) == plr.UID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ignoreWorkloadDeletions filters out Workload deletion events, so the
+// Workload reconciler doesn't replace deleted Workloads before the
+// OrphanedWorkloadReconciler marks their PipelineRun.
+func ignoreWorkloadDeletions() predicate.Predicate {
+	return predicate.Funcs{
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			_, isWorkload := e.Object.(*kueue.Workload)
+			return !isWorkload
+		},
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+func TestOrphanedWorkloadReconciler_Reconcile(t *testing.T) {
+	now := metav1.Now()
+
+	tests := []struct {
+		name           string
+		policy         config.OrphanedWorkloadPolicy
+		plr            func() *tekv1.PipelineRun
+		withWorkload   bool
+		expectOrphaned bool
+	}{
+		{
+			name:           "annotate policy marks the pending PipelineRun",
+			policy:         config.OrphanedWorkloadPolicyAnnotate,
+			plr:            func() *tekv1.PipelineRun { return newPendingPipelineRun("plr") },
+			expectOrphaned: true,
+		},
+		{
+			name:           "recreate policy leaves the PipelineRun untouched",
+			policy:         config.OrphanedWorkloadPolicyRecreate,
+			plr:            func() *tekv1.PipelineRun { return newPendingPipelineRun("plr") },
+			expectOrphaned: false,
+		},
+		{
+			name:           "the Workload was replaced",
+			policy:         config.OrphanedWorkloadPolicyAnnotate,
+			plr:            func() *tekv1.PipelineRun { return newPendingPipelineRun("plr") },
+			withWorkload:   true,
+			expectOrphaned: false,
+		},
+		{
+			name:   "the PipelineRun already started",
+			policy: config.OrphanedWorkloadPolicyAnnotate,
+			plr: func() *tekv1.PipelineRun {
+				plr := newPendingPipelineRun("plr")
+				plr.Spec.Status = ""
+				plr.Status.StartTime = &now
+				return plr
+			},
+			expectOrphaned: false,
+		},
+		{
+			name:   "the PipelineRun was stopped",
+			policy: config.OrphanedWorkloadPolicyAnnotate,
+			plr: func() *tekv1.PipelineRun {
+				plr := newPendingPipelineRun("plr")
+				plr.Spec.Status = tekv1.PipelineRunSpecStatusStoppedRunFinally
+				return plr
+			},
+			expectOrphaned: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			plr := tt.plr()
+			objs := []client.Object{plr}
+			if tt.withWorkload {
+				objs = append(objs, newWorkloadFor(plr, "lq", 0, time.Now()))
+			}
+			cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(objs...).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			r := NewOrphanedWorkloadReconciler(cl, cl, recorder, tt.policy)
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updated := &tekv1.PipelineRun{}
+			g.Expect(cl.Get(context.Background(), client.ObjectKeyFromObject(plr), updated)).To(Succeed())
+			if tt.expectOrphaned {
+				g.Expect(updated.Annotations).To(HaveKeyWithValue(AnnotationOrphaned, "true"))
+				g.Expect(recorder.Events).To(Receive(HavePrefix(corev1.EventTypeWarning + " " + ReasonWorkloadDeleted)))
+			} else {
+				g.Expect(updated.Annotations).NotTo(HaveKey(AnnotationOrphaned))
+				g.Expect(recorder.Events).To(BeEmpty())
+			}
+			g.Expect(updated.Spec.Status).To(Equal(plr.Spec.Status))
+		})
+	}
+}
+
+func TestOrphanedWorkloadReconciler_ReconcileMissingPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).Build()
+
+	r := NewOrphanedWorkloadReconciler(cl, cl, record.NewFakeRecorder(10), config.OrphanedWorkloadPolicyAnnotate)
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: testNamespace, Name: "missing"}})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestIgnoreWorkloadDeletions(t *testing.T) {
+	g := NewWithT(t)
+	p := ignoreWorkloadDeletions()
+
+	g.Expect(p.Delete(event.DeleteEvent{Object: &kueue.Workload{}})).To(BeFalse())
+	g.Expect(p.Delete(event.DeleteEvent{Object: &tekv1.PipelineRun{}})).To(BeTrue())
+	g.Expect(p.Update(event.UpdateEvent{ObjectOld: &kueue.Workload{}, ObjectNew: &kueue.Workload{}})).To(BeTrue())
+}
+
+func TestPipelineRun_Skip(t *testing.T) {
+	g := NewWithT(t)
+
+	plr := newPipelineRun(nil, "", "")
+	g.Expect(plr.Skip()).To(BeFalse())
+
+	plr.Annotations = map[string]string{AnnotationOrphaned: "true"}
+	g.Expect(plr.Skip()).To(BeTrue())
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+const (
+	pipelineRunStateQueued   = "queued"
+	pipelineRunStateRunning  = "running"
+	pipelineRunStateFinished = "finished"
+)
+
+// pipelineRunStates lists the states reported for each queue.
+var pipelineRunStates = []string{pipelineRunStateQueued, pipelineRunStateRunning, pipelineRunStateFinished}
+
+// pipelineRunCollectTimeout bounds the listing of the cached objects on
+// scrape.
+const pipelineRunCollectTimeout = 10 * time.Second
+
+// pipelineRunsDesc describes the number of PipelineRuns by state and queue
+var pipelineRunsDesc = prometheus.NewDesc(
+	"tekton_kueue_pipelineruns",
+	"Current number of PipelineRuns by state and queue",
+	// queue is empty for the PipelineRuns whose queue isn't a LocalQueue
+	// of their namespace
+	[]string{"state", "queue"},
+	nil,
+)
+
+// PipelineRunCollector reports the number of queued, running and finished
+// PipelineRuns of each queue, computed from the cache on scrape. The
+// PipelineRuns without queue label aren't reported. The queue label is
+// limited to the names of the LocalQueues, so the PipelineRuns queued to
+// missing queues don't add series.
+type PipelineRunCollector struct {
+	reader client.Reader
+}
+
+// NewPipelineRunCollector returns a collector listing the PipelineRuns and
+// the LocalQueues from reader, usually the cache of the manager.
+func NewPipelineRunCollector(reader client.Reader) *PipelineRunCollector {
+	return &PipelineRunCollector{reader: reader}
+}
+
+// RegisterPipelineRunCollector registers a PipelineRunCollector reading
+// from reader with controller-runtime's global registry.
+func RegisterPipelineRunCollector(reader client.Reader) error {
+	return metrics.Registry.Register(NewPipelineRunCollector(reader))
+}
+
+// Describe implements prometheus.Collector.
+func (c *PipelineRunCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pipelineRunsDesc
+}
+
+// Collect implements prometheus.Collector. Every known queue is reported
+// in all the states, so the series drop to 0 instead of disappearing.
+func (c *PipelineRunCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), pipelineRunCollectTimeout)
+	defer cancel()
+
+	localQueues := &kueue.LocalQueueList{}
+	if err := c.reader.List(ctx, localQueues); err != nil {
+		ch <- prometheus.NewInvalidMetric(pipelineRunsDesc, err)
+		return
+	}
+	pipelineRuns := &tekv1.PipelineRunList{}
+	if err := c.reader.List(ctx, pipelineRuns); err != nil {
+		ch <- prometheus.NewInvalidMetric(pipelineRunsDesc, err)
+		return
+	}
+
+	type key struct{ state, queue string }
+	counts := map[key]int{}
+	known := make(map[types.NamespacedName]bool, len(localQueues.Items))
+	for _, lq := range localQueues.Items {
+		known[types.NamespacedName{Namespace: lq.Namespace, Name: lq.Name}] = true
+		for _, state := range pipelineRunStates {
+			counts[key{state, lq.Name}] = 0
+		}
+	}
+	for i := range pipelineRuns.Items {
+		plr := &pipelineRuns.Items[i]
+		queue, ok := plr.Labels[common.QueueLabel]
+		if !ok {
+			continue
+		}
+		if !known[types.NamespacedName{Namespace: plr.Namespace, Name: queue}] {
+			queue = ""
+		}
+		counts[key{pipelineRunState(plr), queue}]++
+	}
+
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(pipelineRunsDesc, prometheus.GaugeValue, float64(count), k.state, k.queue)
+	}
+}
+
+// pipelineRunState returns the state of the PipelineRun: queued while it's
+// pending, finished once it's done, and running in between, including
+// while its pods are starting.
+func pipelineRunState(plr *tekv1.PipelineRun) string {
+	switch {
+	case plr.IsDone():
+		return pipelineRunStateFinished
+	case plr.IsPending() && !plr.HasStarted():
+		return pipelineRunStateQueued
+	default:
+		return pipelineRunStateRunning
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+func TestPipelineRunCollector(t *testing.T) {
+	g := NewWithT(t)
+
+	queued := func(name, queue string) *tekv1.PipelineRun {
+		plr := newPendingPipelineRun(name)
+		plr.Labels = map[string]string{common.QueueLabel: queue}
+		return plr
+	}
+	running := func(name, queue string) *tekv1.PipelineRun {
+		plr := queued(name, queue)
+		plr.Spec.Status = ""
+		plr.Status.StartTime = &metav1.Time{Time: time.Now()}
+		return plr
+	}
+	finished := func(name, queue string) *tekv1.PipelineRun {
+		plr := running(name, queue)
+		plr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+		return plr
+	}
+	// Admitted, its pods are starting
+	starting := queued("starting", "build")
+	starting.Spec.Status = ""
+	// Cancelled while it was queued
+	cancelled := queued("cancelled", "build")
+	cancelled.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse})
+	// In another namespace, which has no queue of that name
+	elsewhere := queued("elsewhere", "release")
+	elsewhere.Namespace = "other-ns"
+	unqueued := newPendingPipelineRun("unqueued")
+	otherBuild := newLocalQueue("build", "cq")
+	otherBuild.Namespace = "other-ns"
+
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(
+		newLocalQueue("build", "cq"),
+		newLocalQueue("release", "cq"),
+		otherBuild,
+		queued("queued-1", "build"),
+		queued("queued-2", "build"),
+		starting,
+		running("running", "build"),
+		finished("finished", "build"),
+		cancelled,
+		queued("typo", "buidl"),
+		elsewhere,
+		unqueued,
+	).Build()
+
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(NewPipelineRunCollector(cl))).To(Succeed())
+
+	expected := `
+# HELP tekton_kueue_pipelineruns Current number of PipelineRuns by state and queue
+# TYPE tekton_kueue_pipelineruns gauge
+tekton_kueue_pipelineruns{queue="",state="queued"} 2
+tekton_kueue_pipelineruns{queue="build",state="finished"} 2
+tekton_kueue_pipelineruns{queue="build",state="queued"} 2
+tekton_kueue_pipelineruns{queue="build",state="running"} 2
+tekton_kueue_pipelineruns{queue="release",state="finished"} 0
+tekton_kueue_pipelineruns{queue="release",state="queued"} 0
+tekton_kueue_pipelineruns{queue="release",state="running"} 0
+`
+	g.Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected), "tekton_kueue_pipelineruns")).To(Succeed())
+}
+
+func TestPipelineRunCollector_ListError(t *testing.T) {
+	g := NewWithT(t)
+
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				return errors.New("cache not synced")
+			},
+		}).
+		Build()
+
+	registry := prometheus.NewRegistry()
+	g.Expect(registry.Register(NewPipelineRunCollector(cl))).To(Succeed())
+	_, err := registry.Gather()
+	g.Expect(err).To(MatchError(ContainSubstring("cache not synced")))
+}
@@ -2,7 +2,8 @@ package controller
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -18,9 +19,14 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/clock"
 	kapi "knative.dev/pkg/apis"
 
 	kueueconfig "sigs.k8s.io/kueue/apis/config/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/pkg/resources"
 )
 
 // +kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=list;get;watch
@@ -34,7 +40,12 @@ import (
 // +kubebuilder:rbac:groups="tekton.dev",resources=pipelineruns/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=list;watch
 
-type PipelineRun tekv1.PipelineRun
+// PipelineRun is the jobframework.GenericJob of the PipelineRuns, configured
+// by the jobConfig of the Workload reconciler.
+type PipelineRun struct {
+	*tekv1.PipelineRun
+	config *jobConfig
+}
 
 const (
 	ConditionTypeTerminationTarget = "TerminationTarget"
@@ -47,29 +58,197 @@ const (
 
 const (
 	annotationDomain            = "kueue.konflux-ci.dev/"
-	annotationResourcesRequests = annotationDomain + "requests-"
+	annotationResourcesRequests = resources.AnnotationPrefix
 )
 
+// ReasonInvalidResourceRequests is the reason of the events of the
+// PipelineRuns whose resource annotations can't be parsed.
+const ReasonInvalidResourceRequests = "InvalidResourceRequests"
+
 var (
 	_      jobframework.GenericJob        = &PipelineRun{}
 	_      jobframework.JobWithCustomStop = &PipelineRun{}
+	_      jobframework.JobWithSkip       = &PipelineRun{}
 	PLRGVK                                = tekv1.SchemeGroupVersion.WithKind("PipelineRun")
 	PLRLog                                = ctrl.Log.WithName(ControllerName)
 )
 
-func SetupWithManager(mgr ctrl.Manager) error {
+// SetupWithManager sets up the PipelineRun reconciler and the optional
+// components enabled in cfg and opts.
+func SetupWithManager(mgr ctrl.Manager, cfg config.Controller, opts ...SetupOption) error {
+	annotateOrphans := false
+	switch cfg.OrphanedWorkloadPolicy {
+	case "", config.OrphanedWorkloadPolicyRecreate:
+	case config.OrphanedWorkloadPolicyAnnotate:
+		annotateOrphans = true
+	default:
+		return fmt.Errorf("invalid orphaned workload policy %q", cfg.OrphanedWorkloadPolicy)
+	}
+
+	jobCfg, err := newJobConfig(cfg, opts...)
+	if err != nil {
+		return err
+	}
+	jobCfg.recorder = mgr.GetEventRecorderFor("kueue-plr")
+	if jobCfg.adoptRunning {
+		PLRLog.Info("Adopting the PipelineRuns already running without the queue label")
+	}
+	if jobCfg.preemptionRequeue {
+		PLRLog.Info("Requeueing the preempted PipelineRuns", "maxRequeues", jobCfg.preemptionMaxRequeues)
+	}
+	if jobCfg.stopGracePeriod > 0 {
+		PLRLog.Info("Not stopping the PipelineRuns which started without being admitted past the grace period",
+			"stopGracePeriod", jobCfg.stopGracePeriod)
+	}
+
+	reconcilerOpts, err := workloadReconcilerOptions(cfg)
+	if err != nil {
+		return err
+	}
+
+	if jobCfg.managedNamespaces != nil {
+		PLRLog.Info("Only managing the PipelineRuns of the selected namespaces", "selector", jobCfg.managedNamespaces.String())
+		// Register the informer of the namespaces before the manager starts
+		if _, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Namespace{}); err != nil {
+			return err
+		}
+	}
+	if jobCfg.managedLabelRequired {
+		PLRLog.Info("Only managing the PipelineRuns with the managed label", "label", common.ManagedLabel)
+	}
+
 	workloadReconciler := jobframework.NewGenericReconcilerFactory(
-		func() jobframework.GenericJob { return &PipelineRun{} },
+		func() jobframework.GenericJob { return newPipelineRunJob(&tekv1.PipelineRun{}, jobCfg) },
 		func(b *builder.Builder, c client.Client) *builder.Builder {
-			return b.Named("PipelineRunWorkloads")
+			b = b.Named("PipelineRunWorkloads")
+			if annotateOrphans {
+				b = b.WithEventFilter(ignoreWorkloadDeletions())
+			}
+			if jobCfg.managedNamespaces != nil {
+				b = b.WithEventFilter(inManagedNamespaces(c, jobCfg.managedNamespaces))
+			}
+			if jobCfg.managedLabelRequired {
+				b = b.WithEventFilter(hasManagedLabel())
+			}
+			return b
 		},
 	)
 
-	return workloadReconciler(
+	err = workloadReconciler(
 		mgr.GetClient(),
 		mgr.GetEventRecorderFor("kueue-plr"),
-		jobframework.WithWaitForPodsReady(&kueueconfig.WaitForPodsReady{}),
+		reconcilerOpts...,
 	).SetupWithManager(mgr)
+	if err != nil {
+		return err
+	}
+
+	if annotateOrphans {
+		PLRLog.Info("Enabling the orphaned workload reconciler")
+		err := NewOrphanedWorkloadReconciler(
+			mgr.GetClient(),
+			mgr.GetAPIReader(),
+			mgr.GetEventRecorderFor("kueue-plr"),
+			cfg.OrphanedWorkloadPolicy,
+		).SetupWithManager(mgr)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The Workloads are only annotated for cleanup by the Annotate deletion
+	// protection of the webhook
+	if err := NewWorkloadCleanupReconciler(mgr.GetClient(), clock.RealClock{}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+
+	if cfg.ResolvedRequests.Enabled {
+		PLRLog.Info("Enabling the resolved requests reconciler")
+		err := NewResolvedRequestsReconciler(mgr.GetClient(), cfg.ResolvedRequests).SetupWithManager(mgr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.PendingAdmissionChecks.Enabled {
+		PLRLog.Info("Enabling the pending admission checks reconciler")
+		if err := NewAdmissionChecksReconciler(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+			return err
+		}
+	}
+
+	if cfg.AllowPriorityBump {
+		PLRLog.Info("Enabling the priority bump reconciler")
+		if err := NewPriorityBumpReconciler(mgr.GetClient()).SetupWithManager(mgr); err != nil {
+			return err
+		}
+	}
+
+	if len(cfg.PropagateToTaskRuns) > 0 {
+		if err := cfg.PropagateToTaskRuns.Validate(); err != nil {
+			return err
+		}
+		PLRLog.Info("Enabling the TaskRun propagation reconciler", "keys", cfg.PropagateToTaskRuns)
+		err := NewTaskRunPropagationReconciler(mgr.GetClient(), cfg.PropagateToTaskRuns).SetupWithManager(mgr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.QueuePosition.Enabled {
+		PLRLog.Info("Enabling the queue position reporter")
+		if err := SetupPendingIndex(context.Background(), mgr.GetFieldIndexer()); err != nil {
+			return err
+		}
+		reporter := NewQueuePositionReporter(mgr.GetClient(), cfg.QueuePosition, clock.RealClock{})
+		if err := mgr.Add(reporter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// workloadReconcilerOptions returns the options of the Workload reconciler.
+// The WaitForPodsReady option is only set when it's enabled.
+func workloadReconcilerOptions(cfg config.Controller) ([]jobframework.Option, error) {
+	var opts []jobframework.Option
+	waitForPodsReady, err := waitForPodsReadyConfig(cfg.WaitForPodsReady)
+	if err != nil {
+		return nil, err
+	}
+	if waitForPodsReady == nil {
+		PLRLog.Info("WaitForPodsReady is disabled, the PodsReady condition of the Workloads isn't set")
+		return opts, nil
+	}
+	PLRLog.Info("Enabling WaitForPodsReady",
+		"timeout", waitForPodsReady.Timeout, "recoveryTimeout", waitForPodsReady.RecoveryTimeout)
+	return append(opts, jobframework.WithWaitForPodsReady(waitForPodsReady)), nil
+}
+
+// waitForPodsReadyConfig maps the WaitForPodsReady configuration onto the
+// Kueue one, and returns nil when it's disabled.
+func waitForPodsReadyConfig(cfg config.WaitForPodsReady) (*kueueconfig.WaitForPodsReady, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.Enable {
+		return nil, nil
+	}
+	return &kueueconfig.WaitForPodsReady{
+		Enable:          true,
+		Timeout:         cfg.Timeout,
+		RecoveryTimeout: cfg.RecoveryTimeout,
+	}, nil
+}
+
+// newPipelineRunJob returns the job of the PipelineRun, configured by cfg,
+// or by the default configuration when it's nil.
+func newPipelineRunJob(plr *tekv1.PipelineRun, cfg *jobConfig) *PipelineRun {
+	if cfg == nil {
+		cfg = defaultJobConfig()
+	}
+	return &PipelineRun{PipelineRun: plr, config: cfg}
 }
 
 func SetupIndexer(ctx context.Context, fieldIndexer client.FieldIndexer) error {
@@ -77,14 +256,31 @@ func SetupIndexer(ctx context.Context, fieldIndexer client.FieldIndexer) error {
 }
 
 // Stop implements jobframework.JobWithCustomStop.
+//
+// When the preempted PipelineRuns are requeued, a pending copy of the
+// PipelineRun is created before it's stopped, see requeueIfPreempted.
 func (p *PipelineRun) Stop(ctx context.Context, c client.Client, _ []podset.PodSetInfo, stopReason jobframework.StopReason, eventMsg string) (bool, error) {
-	plr := (*tekv1.PipelineRun)(p)
+	plr := p.PipelineRun
 	plrPendingOrRunning := (plr.Spec.Status == "") || (plr.Spec.Status == tekv1.PipelineRunSpecStatusPending)
 
 	if plr.IsDone() || !plrPendingOrRunning {
 		return false, nil
 	}
 
+	if p.missedAdmission(stopReason) {
+		PLRLog.Info("Not stopping the PipelineRun which started without being admitted, past the stop grace period",
+			"pipelineRun", client.ObjectKeyFromObject(plr), "reason", stopReason, "created", plr.CreationTimestamp,
+			"stopGracePeriod", p.config.stopGracePeriod)
+		recordMissedAdmission()
+		return false, nil
+	}
+
+	if stopReason == jobframework.StopReasonWorkloadEvicted && p.config.preemptionRequeue {
+		if err := p.requeueIfPreempted(ctx, c); err != nil {
+			return false, err
+		}
+	}
+
 	plrCopy := plr.DeepCopy()
 	plrCopy.SetManagedFields(nil)
 	// should we wait for the pipeline to stop?
@@ -98,8 +294,15 @@ func (p *PipelineRun) Stop(ctx context.Context, c client.Client, _ []podset.PodS
 }
 
 // Finished implements jobframework.GenericJob.
+//
+// A PipelineRun is finished once its Succeeded condition is True or False,
+// whatever the reason, e.g. Cancelled or PipelineRunTimeout, and succeeded
+// when it's True. The PipelineRuns being cancelled or stopped while their
+// finally tasks run have an Unknown condition, e.g. with the
+// CancelledRunningFinally reason, and keep their quota until the tasks are
+// done.
 func (p *PipelineRun) Finished() (message string, success bool, finished bool) {
-	plr := (*tekv1.PipelineRun)(p)
+	plr := p.PipelineRun
 	condition := plr.Status.GetCondition(kapi.ConditionSucceeded)
 
 	if condition == nil {
@@ -107,8 +310,11 @@ func (p *PipelineRun) Finished() (message string, success bool, finished bool) {
 	}
 
 	message = condition.Message
-	success = (condition.Reason == tekv1.PipelineRunReasonSuccessful.String()) ||
-		(condition.Reason == tekv1.PipelineRunReasonCompleted.String())
+	if message == "" {
+		// Report the reason on the Workload rather than an empty message
+		message = condition.Reason
+	}
+	success = condition.IsTrue()
 	finished = plr.IsDone()
 
 	return
@@ -120,8 +326,13 @@ func (p *PipelineRun) GVK() schema.GroupVersionKind {
 }
 
 // IsActive implements jobframework.GenericJob.
+//
+// A PipelineRun is active while it's running: once it's done (e.g. after
+// being stopped because its Workload was preempted) the quota reserved by
+// its Workload can be released.
 func (p *PipelineRun) IsActive() bool {
-	return (*tekv1.PipelineRun)(p).HasStarted()
+	plr := p.PipelineRun
+	return plr.HasStarted() && !plr.IsDone()
 }
 
 // IsSuspended implements jobframework.GenericJob.
@@ -131,80 +342,106 @@ func (p *PipelineRun) IsSuspended() bool {
 
 // Object implements jobframework.GenericJob.
 func (p *PipelineRun) Object() client.Object {
-	return (*tekv1.PipelineRun)(p)
+	return p.PipelineRun
 }
 
 // PodSets implements jobframework.GenericJob.
+//
+// The Workload has the default PodSet requesting the resources of the
+// resource annotations, followed by the PodSets of the pod sets annotation,
+// if any, see extraPodSets.
 func (p *PipelineRun) PodSets() ([]kueue.PodSet, error) {
-	requests := p.resourcesRequests()
-
-	return []kueue.PodSet{
-		{
-			Name: "pod-set-1",
-			Template: corev1.PodTemplateSpec{
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "dummy",
-							Image: "dummy",
-							Resources: corev1.ResourceRequirements{
-								Requests: requests,
-							},
-						},
-					},
-				},
-			},
-			Count: 1,
-		},
-	}, nil
+	podSets := []kueue.PodSet{newPodSet(common.DefaultPodSetName, 1, p.resourcesRequests())}
+	return append(podSets, p.extraPodSets()...), nil
 }
 
 // resourcesRequests will match all annotations starting with
-// `kueue.konflux-ci.dev/requests-`. Valid annotations to set
+// resources.AnnotationPrefix. Valid annotations to set
 // the requested resources are then:
 // * `kueue.konflux-ci.dev/requests-cpu`
 // * `kueue.konflux-ci.dev/requests-memory`
 // * `kueue.konflux-ci.dev/requests-storage`
 // * `kueue.konflux-ci.dev/requests-ephemeral-storage`
 //
+// While the annotations are migrated to a new prefix, the annotations of
+// all the prefixes set with WithResourceAnnotationPrefixes are matched, and
+// the prefix with the highest precedence wins when several of them request
+// the same resource.
+//
 // By default, a resource which indicates that the workload requires 1
 // PipelineRun will be added. This is useful for controlling the number
 // of PipelineRuns that can be executed concurrently.
 //
-// WARNING: Annotations are not validated and a panic will
-// happen if they can not be parsed as `resource.Quantity`.
+// The annotations which can't be parsed as `resource.Quantity` are
+// ignored, and reported by a warning event.
 func (p *PipelineRun) resourcesRequests() corev1.ResourceList {
-	requests := corev1.ResourceList{
-		ResourcePipelineRunCount: resource.MustParse("1"),
-	}
-
-	for k, v := range p.GetAnnotations() {
-		if t := strings.TrimPrefix(k, annotationResourcesRequests); t != k {
-			// TODO(@filariow): how to properly validate this?
-			requests[corev1.ResourceName(t)] = resource.MustParse(v)
+	requests, errs := resources.ParsePrefixedResourceAnnotations(p.GetAnnotations(), p.config.resourceAnnotationPrefixes...)
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		PLRLog.Error(err, "Ignoring the invalid resource annotations", "pipelineRun", p.Namespace+"/"+p.Name)
+		if p.config.recorder != nil {
+			p.config.recorder.Event(p.Object(), corev1.EventTypeWarning, ReasonInvalidResourceRequests,
+				fmt.Sprintf("Ignoring the invalid resource annotations: %v", err))
 		}
 	}
-
+	requests[ResourcePipelineRunCount] = resource.MustParse("1")
 	return requests
 }
 
 // PodsReady implements jobframework.GenericJob.
+//
+// Tekton has no notion of pod readiness at the PipelineRun level, so the
+// PipelineRun is considered ready as soon as it has started.
 func (p *PipelineRun) PodsReady() bool {
-	panic("pods ready shouldn't be called")
+	return p.HasStarted()
 }
 
 // RestorePodSetsInfo implements jobframework.GenericJob.
-func (p *PipelineRun) RestorePodSetsInfo(podSetsInfo []podset.PodSetInfo) bool {
+//
+// RunWithPodSetsInfo doesn't inject any PodSet information (node selectors,
+// tolerations or counts) into the PipelineRun, so there is never anything
+// to restore and no change is reported.
+func (p *PipelineRun) RestorePodSetsInfo(_ []podset.PodSetInfo) bool {
 	return false
 }
 
 // RunWithPodSetsInfo implements jobframework.GenericJob.
+//
+// It's called when the Workload of the PipelineRun is admitted, so the time
+// the PipelineRun waited in the queue is observed here.
 func (p *PipelineRun) RunWithPodSetsInfo(podSetsInfo []podset.PodSetInfo) error {
+	observeQueueDuration(p)
 	p.Spec.Status = ""
 	return nil
 }
 
 // Suspend implements jobframework.GenericJob.
+//
+// The reconciler calls Stop rather than Suspend, see JobWithCustomStop,
+// but Suspend is still called when the job is defaulted, e.g. by
+// jobframework.ApplyDefaultForSuspend, and for the jobs whose owner is
+// managed by Kueue and not admitted. A PipelineRun which hasn't started is
+// made pending; a running one can't be made pending again, so it's stopped
+// gracefully, like in Stop. The PipelineRuns already cancelled or stopped
+// are left untouched.
 func (p *PipelineRun) Suspend() {
-	// Not implemented because this is not called when JobWithCustomStop is implemented.
+	plr := p.PipelineRun
+	if plr.Spec.Status != "" || plr.IsDone() {
+		return
+	}
+	if plr.HasStarted() {
+		plr.Spec.Status = tekv1.PipelineRunSpecStatusStoppedRunFinally
+		return
+	}
+	plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
+}
+
+// Skip implements jobframework.JobWithSkip.
+//
+// PipelineRuns marked as orphaned by the OrphanedWorkloadReconciler are
+// skipped, so no new Workload is created for them, and so are the
+// PipelineRuns which started without going through the webhook, see
+// startedUnqueued.
+func (p *PipelineRun) Skip() bool {
+	return p.Annotations[AnnotationOrphaned] == "true" || p.startedUnqueued()
 }
@@ -17,7 +17,27 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"testing"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	kapi "knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+	"sigs.k8s.io/kueue/pkg/podset"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
 )
 
 var _ = Describe("PipelineRun Controller", func() {
@@ -30,3 +50,418 @@ var _ = Describe("PipelineRun Controller", func() {
 		})
 	})
 })
+
+// newPipelineRun returns a PipelineRun adapter with the given start time
+// and Succeeded condition status. A nil startTime means the PipelineRun
+// hasn't started; an empty status means no Succeeded condition is set.
+func newPipelineRun(startTime *metav1.Time, status corev1.ConditionStatus, reason string) *PipelineRun {
+	plr := newPipelineRunJob(&tekv1.PipelineRun{}, nil)
+	plr.Status.StartTime = startTime
+	if status != "" {
+		plr.Status.Conditions = duckv1.Conditions{
+			{Type: kapi.ConditionSucceeded, Status: status, Reason: reason},
+		}
+	}
+	return plr
+}
+
+func TestPipelineRun_PodsReady(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name     string
+		plr      *PipelineRun
+		expected bool
+	}{
+		{
+			name:     "not started",
+			plr:      newPipelineRun(nil, "", ""),
+			expected: false,
+		},
+		{
+			name:     "started",
+			plr:      newPipelineRun(&now, corev1.ConditionUnknown, tekv1.PipelineRunReasonRunning.String()),
+			expected: true,
+		},
+		{
+			name:     "finished",
+			plr:      newPipelineRun(&now, corev1.ConditionTrue, tekv1.PipelineRunReasonSuccessful.String()),
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(func() { tt.plr.PodsReady() }).NotTo(Panic())
+			g.Expect(tt.plr.PodsReady()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestPipelineRun_IsActive(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name     string
+		plr      *PipelineRun
+		expected bool
+	}{
+		{
+			name:     "not started",
+			plr:      newPipelineRun(nil, "", ""),
+			expected: false,
+		},
+		{
+			name:     "running",
+			plr:      newPipelineRun(&now, corev1.ConditionUnknown, tekv1.PipelineRunReasonRunning.String()),
+			expected: true,
+		},
+		{
+			name:     "stopping",
+			plr:      newPipelineRun(&now, corev1.ConditionUnknown, tekv1.PipelineRunReasonStoppedRunningFinally.String()),
+			expected: true,
+		},
+		{
+			name:     "cancelled",
+			plr:      newPipelineRun(&now, corev1.ConditionFalse, tekv1.PipelineRunReasonCancelled.String()),
+			expected: false,
+		},
+		{
+			name:     "succeeded",
+			plr:      newPipelineRun(&now, corev1.ConditionTrue, tekv1.PipelineRunReasonSuccessful.String()),
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(tt.plr.IsActive()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestPipelineRun_Finished(t *testing.T) {
+	now := metav1.Now()
+	// Every reason of the Succeeded condition of the PipelineRuns, with the
+	// status Tekton sets it with
+	terminal := map[tekv1.PipelineRunReason]corev1.ConditionStatus{
+		tekv1.PipelineRunReasonSuccessful:                      corev1.ConditionTrue,
+		tekv1.PipelineRunReasonCompleted:                       corev1.ConditionTrue,
+		tekv1.PipelineRunReasonFailed:                          corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCancelled:                       corev1.ConditionFalse,
+		tekv1.PipelineRunReasonTimedOut:                        corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCouldntGetPipeline:              corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidBindings:                 corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidWorkspaceBinding:         corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidTaskRunSpec:              corev1.ConditionFalse,
+		tekv1.PipelineRunReasonParameterTypeMismatch:           corev1.ConditionFalse,
+		tekv1.PipelineRunReasonObjectParameterMissKeys:         corev1.ConditionFalse,
+		tekv1.PipelineRunReasonParamArrayIndexingInvalid:       corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCouldntGetTask:                  corev1.ConditionFalse,
+		tekv1.PipelineRunReasonParameterMissing:                corev1.ConditionFalse,
+		tekv1.PipelineRunReasonFailedValidation:                corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCouldntGetPipelineResult:        corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidGraph:                    corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCouldntCancel:                   corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCouldntTimeOut:                  corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidMatrixParameterTypes:     corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidTaskResultReference:      corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidPipelineResultReference:  corev1.ConditionFalse,
+		tekv1.PipelineRunReasonRequiredWorkspaceMarkedOptional: corev1.ConditionFalse,
+		tekv1.PipelineRunReasonResourceVerificationFailed:      corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCreateRunFailed:                 corev1.ConditionFalse,
+		tekv1.PipelineRunReasonCELEvaluationFailed:             corev1.ConditionFalse,
+		tekv1.PipelineRunReasonInvalidParamValue:               corev1.ConditionFalse,
+	}
+	nonTerminal := []tekv1.PipelineRunReason{
+		tekv1.PipelineRunReasonStarted,
+		tekv1.PipelineRunReasonRunning,
+		tekv1.PipelineRunReasonPending,
+		tekv1.PipelineRunReasonStopping,
+		tekv1.PipelineRunReasonCancelledRunningFinally,
+		tekv1.PipelineRunReasonStoppedRunningFinally,
+		tekv1.PipelineRunReasonResolvingPipelineRef,
+	}
+
+	for reason, status := range terminal {
+		t.Run(reason.String(), func(t *testing.T) {
+			g := NewWithT(t)
+			message, success, finished := newPipelineRun(&now, status, reason.String()).Finished()
+			g.Expect(finished).To(BeTrue())
+			g.Expect(success).To(Equal(status == corev1.ConditionTrue))
+			g.Expect(message).To(Equal(reason.String()))
+		})
+	}
+	for _, reason := range nonTerminal {
+		t.Run(reason.String(), func(t *testing.T) {
+			g := NewWithT(t)
+			_, success, finished := newPipelineRun(&now, corev1.ConditionUnknown, reason.String()).Finished()
+			g.Expect(finished).To(BeFalse())
+			g.Expect(success).To(BeFalse())
+		})
+	}
+
+	t.Run("not started", func(t *testing.T) {
+		g := NewWithT(t)
+		message, success, finished := newPipelineRun(nil, "", "").Finished()
+		g.Expect([]any{message, success, finished}).To(Equal([]any{"", false, false}))
+	})
+	t.Run("message", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPipelineRun(&now, corev1.ConditionFalse, tekv1.PipelineRunReasonCancelled.String())
+		plr.Status.Conditions[0].Message = `PipelineRun "build" was cancelled`
+		message, _, _ := plr.Finished()
+		g.Expect(message).To(Equal(`PipelineRun "build" was cancelled`))
+	})
+}
+
+func TestPipelineRun_RestorePodSetsInfo(t *testing.T) {
+	g := NewWithT(t)
+	plr := newPipelineRun(nil, "", "")
+	plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
+	before := plr.DeepCopy()
+
+	changed := plr.RestorePodSetsInfo([]podset.PodSetInfo{
+		{Name: "pod-set-1", Count: 1, NodeSelector: map[string]string{"foo": "bar"}},
+	})
+
+	g.Expect(changed).To(BeFalse())
+	g.Expect(plr.PipelineRun).To(Equal(before))
+}
+
+func TestPipelineRun_Suspend(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name     string
+		plr      *PipelineRun
+		status   tekv1.PipelineRunSpecStatus
+		expected tekv1.PipelineRunSpecStatus
+	}{
+		{
+			name:     "not started",
+			plr:      newPipelineRun(nil, "", ""),
+			expected: tekv1.PipelineRunSpecStatusPending,
+		},
+		{
+			name:     "pending",
+			plr:      newPipelineRun(nil, "", ""),
+			status:   tekv1.PipelineRunSpecStatusPending,
+			expected: tekv1.PipelineRunSpecStatusPending,
+		},
+		{
+			name:     "running",
+			plr:      newPipelineRun(&now, corev1.ConditionUnknown, tekv1.PipelineRunReasonRunning.String()),
+			expected: tekv1.PipelineRunSpecStatusStoppedRunFinally,
+		},
+		{
+			name:     "cancelled",
+			plr:      newPipelineRun(&now, corev1.ConditionUnknown, tekv1.PipelineRunReasonCancelledRunningFinally.String()),
+			status:   tekv1.PipelineRunSpecStatusCancelledRunFinally,
+			expected: tekv1.PipelineRunSpecStatusCancelledRunFinally,
+		},
+		{
+			name: "succeeded",
+			plr:  newPipelineRun(&now, corev1.ConditionTrue, tekv1.PipelineRunReasonSuccessful.String()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			tt.plr.Spec.Status = tt.status
+			tt.plr.Suspend()
+			g.Expect(tt.plr.Spec.Status).To(Equal(tt.expected))
+			if tt.expected == tekv1.PipelineRunSpecStatusPending {
+				g.Expect(tt.plr.IsSuspended()).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestPipelineRun_ApplyDefaultForSuspend(t *testing.T) {
+	g := NewWithT(t)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).Build()
+
+	queued := newPipelineRunJob(&tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Name: "queued", Namespace: testNamespace, Labels: map[string]string{common.QueueLabel: "lq"},
+	}}, nil)
+	g.Expect(jobframework.ApplyDefaultForSuspend(context.Background(), queued, cl, false, nil)).To(Succeed())
+	g.Expect(queued.IsSuspended()).To(BeTrue())
+
+	unqueued := newPipelineRunJob(&tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "unqueued", Namespace: testNamespace}}, nil)
+	g.Expect(jobframework.ApplyDefaultForSuspend(context.Background(), unqueued, cl, false, nil)).To(Succeed())
+	g.Expect(unqueued.IsSuspended()).To(BeFalse())
+}
+
+// queueDurationBuckets returns the cumulative counts of the queue duration
+// histogram of the labels, by upper bound, and its sample count.
+func queueDurationBuckets(g Gomega, namespace, priorityClass string) (map[float64]uint64, uint64) {
+	m := &dto.Metric{}
+	observer := pipelineRunQueueDuration.WithLabelValues(namespace, priorityClass)
+	g.Expect(observer.(prometheus.Metric).Write(m)).To(Succeed())
+	buckets := map[float64]uint64{}
+	for _, b := range m.GetHistogram().GetBucket() {
+		buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+	}
+	return buckets, m.GetHistogram().GetSampleCount()
+}
+
+func TestPipelineRun_RunWithPodSetsInfo_QueueDuration(t *testing.T) {
+	created := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		namespace        string
+		disableNamespace bool
+		waited           time.Duration
+		expectedLabel    string
+		// expectedBucket is the smallest bucket counting the observation
+		expectedBucket float64
+		expectedCount  uint64
+	}{
+		{
+			name:           "short wait",
+			namespace:      "queue-duration-short",
+			waited:         3 * time.Second,
+			expectedLabel:  "queue-duration-short",
+			expectedBucket: 4,
+			expectedCount:  1,
+		},
+		{
+			name:           "long wait",
+			namespace:      "queue-duration-long",
+			waited:         time.Hour,
+			expectedLabel:  "queue-duration-long",
+			expectedBucket: 4096,
+			expectedCount:  1,
+		},
+		{
+			name:             "namespace label disabled",
+			namespace:        "queue-duration-disabled",
+			disableNamespace: true,
+			waited:           500 * time.Millisecond,
+			expectedLabel:    "",
+			expectedBucket:   1,
+			expectedCount:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			plr := newPipelineRun(nil, "", "")
+			plr.config.clock = clocktesting.NewFakePassiveClock(created.Add(tt.waited))
+			plr.config.queueDurationNamespaceLabel = !tt.disableNamespace
+			plr.Namespace = tt.namespace
+			plr.CreationTimestamp = metav1.NewTime(created)
+			plr.Labels = map[string]string{"kueue.x-k8s.io/priority-class": "high"}
+			plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
+			_, before := queueDurationBuckets(g, tt.expectedLabel, "high")
+
+			g.Expect(plr.RunWithPodSetsInfo(nil)).To(Succeed())
+
+			g.Expect(plr.Spec.Status).To(BeEmpty())
+			buckets, count := queueDurationBuckets(g, tt.expectedLabel, "high")
+			g.Expect(count - before).To(Equal(tt.expectedCount))
+			for bound, cumulative := range buckets {
+				if bound < tt.expectedBucket {
+					g.Expect(cumulative).To(BeZero(), "bucket %v", bound)
+				} else {
+					g.Expect(cumulative).To(Equal(count), "bucket %v", bound)
+				}
+			}
+		})
+	}
+}
+
+func TestPipelineRun_RunWithPodSetsInfo_WithoutCreationTimestamp(t *testing.T) {
+	g := NewWithT(t)
+	plr := newPipelineRun(nil, "", "")
+	plr.Namespace = "queue-duration-unset"
+
+	g.Expect(plr.RunWithPodSetsInfo(nil)).To(Succeed())
+
+	_, count := queueDurationBuckets(g, "queue-duration-unset", "")
+	g.Expect(count).To(BeZero())
+}
+
+func TestPipelineRun_ResourcesRequests_PrefixMigration(t *testing.T) {
+	const (
+		oldPrefix = annotationResourcesRequests
+		newPrefix = "queue.konflux.dev/requests-"
+	)
+	annotations := map[string]string{
+		oldPrefix + "cpu":    "1",
+		newPrefix + "cpu":    "2",
+		oldPrefix + "memory": "1Gi",
+		newPrefix + "gpu":    "1",
+	}
+
+	tests := []struct {
+		name     string
+		prefixes []string
+		expected corev1.ResourceList
+	}{
+		{
+			name:     "default prefix",
+			prefixes: []string{annotationResourcesRequests},
+			expected: corev1.ResourceList{
+				ResourcePipelineRunCount: resource.MustParse("1"),
+				"cpu":                    resource.MustParse("1"),
+				"memory":                 resource.MustParse("1Gi"),
+			},
+		},
+		{
+			// dual-write and read-both read the same prefixes
+			name:     "both prefixes, the new one winning",
+			prefixes: []string{newPrefix, oldPrefix},
+			expected: corev1.ResourceList{
+				ResourcePipelineRunCount: resource.MustParse("1"),
+				"cpu":                    resource.MustParse("2"),
+				"memory":                 resource.MustParse("1Gi"),
+				"gpu":                    resource.MustParse("1"),
+			},
+		},
+		{
+			name:     "new prefix only",
+			prefixes: []string{newPrefix},
+			expected: corev1.ResourceList{
+				ResourcePipelineRunCount: resource.MustParse("1"),
+				"cpu":                    resource.MustParse("2"),
+				"gpu":                    resource.MustParse("1"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			plr := newPipelineRun(nil, "", "")
+			plr.config.resourceAnnotationPrefixes = tt.prefixes
+			plr.Annotations = annotations
+			g.Expect(plr.resourcesRequests()).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestPipelineRun_ResourcesRequests_InvalidAnnotation(t *testing.T) {
+	g := NewWithT(t)
+	recorder := record.NewFakeRecorder(10)
+
+	plr := newPipelineRun(nil, "", "")
+	plr.config.recorder = recorder
+	plr.Annotations = map[string]string{
+		annotationResourcesRequests + "cpu":    "500m",
+		annotationResourcesRequests + "memory": "lots",
+	}
+	g.Expect(plr.resourcesRequests()).To(Equal(corev1.ResourceList{
+		ResourcePipelineRunCount: resource.MustParse("1"),
+		corev1.ResourceCPU:       resource.MustParse("500m"),
+	}))
+	g.Expect(recorder.Events).To(Receive(And(
+		HavePrefix("Warning "+ReasonInvalidResourceRequests),
+		ContainSubstring(`kueue.konflux-ci.dev/requests-memory="lots"`),
+	)))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// ReasonInvalidPodSets is the reason of the events of the PipelineRuns
+// whose pod sets annotation is invalid.
+const ReasonInvalidPodSets = "InvalidPodSets"
+
+// extraPodSets returns the PodSets of the pod sets annotation of the
+// PipelineRun, set by the podset CEL function. When the annotation is
+// invalid, no PodSet is returned, so the Workload only has the default
+// PodSet, and a warning event is recorded.
+func (p *PipelineRun) extraPodSets() []kueue.PodSet {
+	value, ok := p.Annotations[common.PodSetsAnnotation]
+	if !ok {
+		return nil
+	}
+	specs, err := common.ParsePodSets(value)
+	if err != nil {
+		PLRLog.Error(err, "Ignoring the invalid pod sets annotation",
+			"pipelineRun", p.Namespace+"/"+p.Name)
+		if p.config.recorder != nil {
+			p.config.recorder.Event(p.Object(), corev1.EventTypeWarning, ReasonInvalidPodSets,
+				fmt.Sprintf("Ignoring the %s annotation: %v", common.PodSetsAnnotation, err))
+		}
+		return nil
+	}
+
+	podSets := make([]kueue.PodSet, 0, len(specs))
+	for _, spec := range specs {
+		requests := corev1.ResourceList{}
+		for name, value := range spec.Requests {
+			// The quantities were validated by ParsePodSets
+			requests[corev1.ResourceName(name)] = resource.MustParse(value)
+		}
+		podSets = append(podSets, newPodSet(spec.Name, spec.Count, requests))
+	}
+	return podSets
+}
+
+// newPodSet returns a PodSet of count pods, each running a dummy container
+// with the requests.
+func newPodSet(name string, count int32, requests corev1.ResourceList) kueue.PodSet {
+	return kueue.PodSet{
+		Name: kueue.PodSetReference(name),
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "dummy",
+						Image: "dummy",
+						Resources: corev1.ResourceRequirements{
+							Requests: requests,
+						},
+					},
+				},
+			},
+		},
+		Count: count,
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/tools/record"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// newRecordedJob returns the job of the PipelineRun, recording its events
+// with a fake recorder.
+func newRecordedJob(plr *tekv1.PipelineRun) (*PipelineRun, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(10)
+	job := newPipelineRunJob(plr, nil)
+	job.config.recorder = recorder
+	return job, recorder
+}
+
+func TestPipelineRun_PodSets(t *testing.T) {
+	g := NewWithT(t)
+
+	plr := newPendingPipelineRun("plr")
+	plr.Annotations = map[string]string{
+		annotationResourcesRequests + "cpu": "500m",
+		common.PodSetsAnnotation: `[{"name":"arm-builders","count":3,"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}},` +
+			`{"name":"gpu","count":1,"requests":{"nvidia.com/gpu":"1","memory":"1Gi"}}]`,
+	}
+	job, recorder := newRecordedJob(plr)
+	podSets, err := job.PodSets()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(podSets).To(HaveLen(3))
+
+	g.Expect(podSets[0].Name).To(Equal(kueue.PodSetReference(common.DefaultPodSetName)))
+	g.Expect(podSets[0].Count).To(Equal(int32(1)))
+	g.Expect(podSets[0].Template.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+		ResourcePipelineRunCount: resource.MustParse("1"),
+		corev1.ResourceCPU:       resource.MustParse("500m"),
+	}))
+
+	g.Expect(podSets[1].Name).To(Equal(kueue.PodSetReference("arm-builders")))
+	g.Expect(podSets[1].Count).To(Equal(int32(3)))
+	g.Expect(podSets[1].Template.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+		"kueue.konflux-ci.dev/linux-arm64": resource.MustParse("1"),
+	}))
+
+	g.Expect(podSets[2].Name).To(Equal(kueue.PodSetReference("gpu")))
+	g.Expect(podSets[2].Count).To(Equal(int32(1)))
+	g.Expect(podSets[2].Template.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+		"nvidia.com/gpu":      resource.MustParse("1"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}))
+	g.Expect(recorder.Events).To(BeEmpty())
+}
+
+func TestPipelineRun_PodSetsDefault(t *testing.T) {
+	g := NewWithT(t)
+	job, recorder := newRecordedJob(newPendingPipelineRun("plr"))
+	podSets, err := job.PodSets()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(podSets).To(HaveLen(1))
+	g.Expect(podSets[0].Name).To(Equal(kueue.PodSetReference(common.DefaultPodSetName)))
+	g.Expect(podSets[0].Template.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+		ResourcePipelineRunCount: resource.MustParse("1"),
+	}))
+	g.Expect(recorder.Events).To(BeEmpty())
+}
+
+func TestPipelineRun_PodSetsInvalidAnnotation(t *testing.T) {
+	cases := map[string]string{
+		"not JSON":         `arm-builders`,
+		"reserved name":    `[{"name":"pod-set-1","count":1}]`,
+		"zero count":       `[{"name":"arm-builders","count":0}]`,
+		"invalid quantity": `[{"name":"arm-builders","count":1,"requests":{"cpu":"lots"}}]`,
+		"duplicate names":  `[{"name":"arm-builders","count":1},{"name":"arm-builders","count":2}]`,
+	}
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			plr := newPendingPipelineRun("plr")
+			plr.Annotations = map[string]string{common.PodSetsAnnotation: value}
+			job, recorder := newRecordedJob(plr)
+			podSets, err := job.PodSets()
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(podSets).To(HaveLen(1))
+			g.Expect(podSets[0].Name).To(Equal(kueue.PodSetReference(common.DefaultPodSetName)))
+			g.Expect(recorder.Events).To(Receive(HavePrefix("Warning " + ReasonInvalidPodSets + " ")))
+		})
+	}
+}
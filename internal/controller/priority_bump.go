@@ -0,0 +1,138 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+	"sigs.k8s.io/kueue/pkg/workload"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// priorityBumpRetryInterval is the interval at which the Workload of a
+// PipelineRun whose priority changed is looked up again, when it isn't
+// created yet.
+const priorityBumpRetryInterval = 5 * time.Second
+
+// PriorityBumpReconciler copies the changes of the priority class label of
+// pending PipelineRuns onto their Workload, raising or lowering its
+// priority. Kueue queues the Workload again with its new priority. The
+// Workloads which have reserved quota keep their priority.
+type PriorityBumpReconciler struct {
+	client client.Client
+}
+
+// NewPriorityBumpReconciler creates a PriorityBumpReconciler.
+func NewPriorityBumpReconciler(c client.Client) *PriorityBumpReconciler {
+	return &PriorityBumpReconciler{client: c}
+}
+
+// SetupWithManager registers the reconciler, triggered by the changes of
+// the priority class label of the queued PipelineRuns.
+func (r *PriorityBumpReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("PriorityBump").
+		For(&tekv1.PipelineRun{}, builder.WithPredicates(priorityClassChanged())).
+		Complete(r)
+}
+
+// priorityClassChanged filters the updates of queued, pending PipelineRuns
+// changing their priority class label.
+func priorityClassChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			plr, ok := e.ObjectNew.(*tekv1.PipelineRun)
+			if !ok || !isQueued(plr) {
+				return false
+			}
+			return e.ObjectOld.GetLabels()[kueueconstants.WorkloadPriorityClassLabel] !=
+				plr.Labels[kueueconstants.WorkloadPriorityClassLabel]
+		},
+	}
+}
+
+// isQueued returns whether the PipelineRun waits for the admission of its
+// Workload.
+func isQueued(plr *tekv1.PipelineRun) bool {
+	_, queued := plr.Labels[common.QueueLabel]
+	return queued && plr.Spec.Status == tekv1.PipelineRunSpecStatusPending
+}
+
+// Reconcile updates the priority of the Workload of the PipelineRun from
+// its priority class label, unless the Workload has reserved quota. When
+// the Workload isn't created yet, the PipelineRun is reconciled again
+// later.
+func (r *PriorityBumpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	plr := &tekv1.PipelineRun{}
+	if err := r.client.Get(ctx, req.NamespacedName, plr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !plr.DeletionTimestamp.IsZero() || !isQueued(plr) {
+		return ctrl.Result{}, nil
+	}
+
+	wl, err := activeWorkloadOf(ctx, r.client, plr)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if wl == nil {
+		return ctrl.Result{RequeueAfter: priorityBumpRetryInterval}, nil
+	}
+	if workload.HasQuotaReservation(wl) {
+		return ctrl.Result{}, nil
+	}
+
+	name, source, value, err := jobframework.ExtractPriority(ctx, r.client, plr, wl.Spec.PodSets, nil)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if wl.Spec.PriorityClassName == name && wl.Spec.PriorityClassSource == source && priority(wl) == value {
+		return ctrl.Result{}, nil
+	}
+
+	// Fail on conflicts, so the priority isn't changed once Kueue reserved
+	// quota for the Workload
+	patch := client.MergeFromWithOptions(wl.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	ctrl.LoggerFrom(ctx).Info("Updating the priority of the Workload",
+		"workload", client.ObjectKeyFromObject(wl),
+		"from", wl.Spec.PriorityClassName, "to", name, "priority", value)
+	wl.Spec.PriorityClassName = name
+	wl.Spec.PriorityClassSource = source
+	wl.Spec.Priority = ptr.To(value)
+	if err := r.client.Patch(ctx, wl, patch); err != nil {
+		if apierrors.IsConflict(err) {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
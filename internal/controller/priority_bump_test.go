@@ -0,0 +1,162 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	kueueconstants "sigs.k8s.io/kueue/pkg/constants"
+	kueuecontrollerconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// newPrioritizedPipelineRun returns a queued PipelineRun of the priority
+// class.
+func newPrioritizedPipelineRun(name, priorityClass string) *tekv1.PipelineRun {
+	plr := newPendingPipelineRun(name)
+	plr.Labels = map[string]string{
+		common.QueueLabel: "lq",
+		kueuecontrollerconstants.WorkloadPriorityClassLabel: priorityClass,
+	}
+	return plr
+}
+
+// withPriorityClass sets the WorkloadPriorityClass of the Workload.
+func withPriorityClass(wl *kueue.Workload, name string, value int32) *kueue.Workload {
+	wl.Spec.PriorityClassName = name
+	wl.Spec.PriorityClassSource = kueueconstants.WorkloadPriorityClassSource
+	wl.Spec.Priority = ptr.To(value)
+	return wl
+}
+
+func newWorkloadPriorityClass(name string, value int32) *kueue.WorkloadPriorityClass {
+	return &kueue.WorkloadPriorityClass{ObjectMeta: metav1.ObjectMeta{Name: name}, Value: value}
+}
+
+func TestPriorityBumpReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		label        string
+		workload     func(*tekv1.PipelineRun) *kueue.Workload
+		wantClass    string
+		wantPriority int32
+	}{
+		"bump": {
+			label: "high",
+			workload: func(plr *tekv1.PipelineRun) *kueue.Workload {
+				return withPriorityClass(newWorkloadFor(plr, "lq", 0, time.Now()), "low", 10)
+			},
+			wantClass:    "high",
+			wantPriority: 1000,
+		},
+		"downgrade": {
+			label: "low",
+			workload: func(plr *tekv1.PipelineRun) *kueue.Workload {
+				return withPriorityClass(newWorkloadFor(plr, "lq", 0, time.Now()), "high", 1000)
+			},
+			wantClass:    "low",
+			wantPriority: 10,
+		},
+		"already admitted": {
+			label: "high",
+			workload: func(plr *tekv1.PipelineRun) *kueue.Workload {
+				return admit(withPriorityClass(newWorkloadFor(plr, "lq", 0, time.Now()), "low", 10))
+			},
+			wantClass:    "low",
+			wantPriority: 10,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			plr := newPrioritizedPipelineRun("plr", tc.label)
+			wl := tc.workload(plr)
+			cl := newIndexedClientBuilder().WithObjects(
+				plr, wl,
+				newWorkloadPriorityClass("low", 10),
+				newWorkloadPriorityClass("high", 1000),
+			).Build()
+
+			result, err := NewPriorityBumpReconciler(cl).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{}))
+
+			updated := &kueue.Workload{}
+			g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(wl), updated)).To(Succeed())
+			g.Expect(updated.Spec.PriorityClassName).To(Equal(tc.wantClass))
+			g.Expect(updated.Spec.PriorityClassSource).To(Equal(kueueconstants.WorkloadPriorityClassSource))
+			g.Expect(updated.Spec.Priority).To(HaveValue(Equal(tc.wantPriority)))
+		})
+	}
+}
+
+func TestPriorityBumpReconciler_WorkloadNotCreated(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newPrioritizedPipelineRun("plr", "high")
+	cl := newIndexedClientBuilder().WithObjects(plr, newWorkloadPriorityClass("high", 1000)).Build()
+	r := NewPriorityBumpReconciler(cl)
+
+	// The PipelineRun is reconciled again until its Workload is created
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(priorityBumpRetryInterval))
+
+	wl := withPriorityClass(newWorkloadFor(plr, "lq", 0, time.Now()), "low", 10)
+	g.Expect(cl.Create(ctx, wl)).To(Succeed())
+	result, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(wl), wl)).To(Succeed())
+	g.Expect(wl.Spec.PriorityClassName).To(Equal("high"))
+
+	// The PipelineRuns which aren't pending anymore are ignored
+	plr.Spec.Status = ""
+	g.Expect(cl.Update(ctx, plr)).To(Succeed())
+	g.Expect(cl.Delete(ctx, wl)).To(Succeed())
+	result, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+}
+
+func TestPriorityClassChanged(t *testing.T) {
+	g := NewWithT(t)
+	pred := priorityClassChanged()
+
+	old := newPrioritizedPipelineRun("plr", "low")
+	bumped := newPrioritizedPipelineRun("plr", "high")
+	g.Expect(pred.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: bumped})).To(BeTrue())
+	g.Expect(pred.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: old.DeepCopy()})).To(BeFalse())
+
+	started := bumped.DeepCopy()
+	started.Spec.Status = ""
+	g.Expect(pred.Update(event.UpdateEvent{ObjectOld: old, ObjectNew: started})).To(BeFalse())
+	g.Expect(pred.Create(event.CreateEvent{Object: bumped})).To(BeFalse())
+}
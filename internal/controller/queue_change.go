@@ -0,0 +1,210 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// QueueNameIndexKey indexes the PipelineRuns by the value of their queue
+// label.
+const QueueNameIndexKey = "metadata.labels.queueName"
+
+// DefaultQueueChangeReloadInterval is the interval at which the default
+// queue is read from the configuration file.
+const DefaultQueueChangeReloadInterval = 10 * time.Second
+
+// SetupQueueNameIndex indexes the PipelineRuns by their queue label.
+func SetupQueueNameIndex(ctx context.Context, fieldIndexer client.FieldIndexer) error {
+	return fieldIndexer.IndexField(ctx, &tekv1.PipelineRun{}, QueueNameIndexKey, indexQueueName)
+}
+
+// indexQueueName returns the queue of the PipelineRun, if any.
+func indexQueueName(obj client.Object) []string {
+	if queue, ok := obj.GetLabels()[common.QueueLabel]; ok {
+		return []string{queue}
+	}
+	return nil
+}
+
+// QueueChangeReconciler moves the pending PipelineRuns queued to a former
+// default queue to the current one, so they aren't stuck in a queue which
+// may not have quota anymore. Kueue moves their Workloads as long as they
+// haven't reserved quota.
+//
+// Only the PipelineRuns queued by the webhook are moved: they carry the
+// common.DefaultedQueueAnnotation, set to the queue they were defaulted
+// to. The PipelineRuns whose queue label was set by their author, or
+// changed since the admission, are left alone.
+type QueueChangeReconciler struct {
+	client client.Client
+
+	mu        sync.RWMutex
+	queueName string
+}
+
+// NewQueueChangeReconciler creates a QueueChangeReconciler moving the
+// PipelineRuns to queueName.
+func NewQueueChangeReconciler(c client.Client, queueName string) *QueueChangeReconciler {
+	return &QueueChangeReconciler{client: c, queueName: queueName}
+}
+
+// QueueName returns the current default queue.
+func (r *QueueChangeReconciler) QueueName() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.queueName
+}
+
+// SetQueueName changes the default queue and returns the former one. The
+// PipelineRuns queued to the former queue are moved by Resync.
+func (r *QueueChangeReconciler) SetQueueName(queueName string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := r.queueName
+	r.queueName = queueName
+	return previous
+}
+
+// SetupWithManager registers the reconciler, triggered by the changes of
+// the PipelineRuns queued by the webhook. It catches the PipelineRuns
+// admitted by a webhook which hasn't loaded the new default queue yet.
+func (r *QueueChangeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	defaulted := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetAnnotations()[common.DefaultedQueueAnnotation]
+		return ok
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("QueueChange").
+		For(&tekv1.PipelineRun{}, builder.WithPredicates(defaulted)).
+		Complete(r)
+}
+
+// Reconcile moves the PipelineRun to the current default queue when it's
+// still pending in the queue it was defaulted to.
+func (r *QueueChangeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	plr := &tekv1.PipelineRun{}
+	if err := r.client.Get(ctx, req.NamespacedName, plr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, client.IgnoreNotFound(r.reconcilePipelineRun(ctx, plr))
+}
+
+// reconcilePipelineRun relabels the PipelineRun when it's pending, and
+// still queued to a default queue which isn't the current one.
+func (r *QueueChangeReconciler) reconcilePipelineRun(ctx context.Context, plr *tekv1.PipelineRun) error {
+	queueName := r.QueueName()
+	defaulted, ok := plr.Annotations[common.DefaultedQueueAnnotation]
+	if !ok || !plr.DeletionTimestamp.IsZero() || !plr.IsPending() {
+		return nil
+	}
+	if current := plr.Labels[common.QueueLabel]; current != defaulted || current == queueName {
+		return nil
+	}
+
+	patch := client.MergeFrom(plr.DeepCopy())
+	plr.Labels[common.QueueLabel] = queueName
+	plr.Annotations[common.DefaultedQueueAnnotation] = queueName
+	ctrl.LoggerFrom(ctx).Info("Moving the PipelineRun to the new default queue",
+		"pipelineRun", client.ObjectKeyFromObject(plr), "from", defaulted, "to", queueName)
+	return r.client.Patch(ctx, plr, patch)
+}
+
+// Resync moves the pending PipelineRuns queued by the webhook to previous
+// to the current default queue. The PipelineRuns which can't be updated
+// are reconciled again when they change.
+func (r *QueueChangeReconciler) Resync(ctx context.Context, previous string) error {
+	pipelineRuns := &tekv1.PipelineRunList{}
+	if err := r.client.List(ctx, pipelineRuns, client.MatchingFields{QueueNameIndexKey: previous}); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range pipelineRuns.Items {
+		if err := r.reconcilePipelineRun(ctx, &pipelineRuns.Items[i]); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// QueueChangeReloader reads the default queue from the configuration file
+// at regular intervals, and moves the pending PipelineRuns when it changes.
+// The rest of the configuration isn't reloaded.
+type QueueChangeReloader struct {
+	path       string
+	interval   time.Duration
+	reconciler *QueueChangeReconciler
+}
+
+// NewQueueChangeReloader returns a QueueChangeReloader updating the
+// default queue of the reconciler from the configuration file at path.
+func NewQueueChangeReloader(path string, interval time.Duration, reconciler *QueueChangeReconciler) *QueueChangeReloader {
+	return &QueueChangeReloader{path: path, interval: interval, reconciler: reconciler}
+}
+
+// Start reloads the default queue until the context is cancelled.
+func (r *QueueChangeReloader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// Reload reads the default queue from the configuration file, and moves
+// the pending PipelineRuns when it changed. The current queue is kept when
+// the file can't be read or parsed, or doesn't set the queue.
+func (r *QueueChangeReloader) Reload(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx).WithValues("path", r.path)
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "Unable to read the configuration, keeping the default queue", "queueName", r.reconciler.QueueName())
+		return
+	}
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		log.Error(err, "Unable to parse the configuration, keeping the default queue", "queueName", r.reconciler.QueueName())
+		return
+	}
+	if cfg.QueueName == "" || cfg.QueueName == r.reconciler.QueueName() {
+		return
+	}
+	previous := r.reconciler.SetQueueName(cfg.QueueName)
+	log.Info("The default queue changed, moving the pending PipelineRuns", "from", previous, "to", cfg.QueueName)
+	if err := r.reconciler.Resync(ctx, previous); err != nil {
+		log.Error(err, "Unable to move some PipelineRuns to the new default queue")
+	}
+}
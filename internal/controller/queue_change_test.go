@@ -0,0 +1,189 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// newQueuedPipelineRun returns a pending PipelineRun queued to queue, and
+// marked as defaulted to the queue when defaulted isn't empty.
+func newQueuedPipelineRun(name, queue, defaulted string) *tekv1.PipelineRun {
+	plr := newPendingPipelineRun(name)
+	plr.Labels = map[string]string{common.QueueLabel: queue}
+	if defaulted != "" {
+		plr.Annotations = map[string]string{common.DefaultedQueueAnnotation: defaulted}
+	}
+	return plr
+}
+
+// newQueueChangeClient returns a client indexing the PipelineRuns by queue,
+// recording the names of the patched PipelineRuns.
+func newQueueChangeClient(patched *[]string, objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme()).
+		WithObjects(objs...).
+		WithIndex(&tekv1.PipelineRun{}, QueueNameIndexKey, indexQueueName).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				*patched = append(*patched, obj.GetName())
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+}
+
+func TestQueueChangeReconciler_Reconcile(t *testing.T) {
+	started := newQueuedPipelineRun("started", "old-queue", "old-queue")
+	started.Spec.Status = ""
+	deleted := newQueuedPipelineRun("deleted", "old-queue", "old-queue")
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleted.Finalizers = []string{"test"}
+
+	tests := []struct {
+		name          string
+		plr           *tekv1.PipelineRun
+		expectedQueue string
+	}{
+		{
+			name:          "defaulted to the former queue",
+			plr:           newQueuedPipelineRun("defaulted", "old-queue", "old-queue"),
+			expectedQueue: "new-queue",
+		},
+		{
+			name:          "queue chosen by the author",
+			plr:           newQueuedPipelineRun("chosen", "old-queue", ""),
+			expectedQueue: "old-queue",
+		},
+		{
+			name:          "queue changed since the admission",
+			plr:           newQueuedPipelineRun("changed", "other-queue", "old-queue"),
+			expectedQueue: "other-queue",
+		},
+		{
+			name:          "already in the current queue",
+			plr:           newQueuedPipelineRun("current", "new-queue", "new-queue"),
+			expectedQueue: "new-queue",
+		},
+		{
+			name:          "started",
+			plr:           started,
+			expectedQueue: "old-queue",
+		},
+		{
+			name:          "deleted",
+			plr:           deleted,
+			expectedQueue: "old-queue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			var patched []string
+			cl := newQueueChangeClient(&patched, tt.plr)
+			r := NewQueueChangeReconciler(cl, "new-queue")
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.plr)})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			updated := &tekv1.PipelineRun{}
+			g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(tt.plr), updated)).To(Succeed())
+			g.Expect(updated.Labels).To(HaveKeyWithValue(common.QueueLabel, tt.expectedQueue))
+			if tt.expectedQueue == "new-queue" && tt.plr.Labels[common.QueueLabel] != "new-queue" {
+				g.Expect(patched).To(ConsistOf(tt.plr.Name))
+				g.Expect(updated.Annotations).To(HaveKeyWithValue(common.DefaultedQueueAnnotation, "new-queue"))
+			} else {
+				g.Expect(patched).To(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestQueueChangeReconciler_ReconcileMissingPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+
+	var patched []string
+	r := NewQueueChangeReconciler(newQueueChangeClient(&patched), "new-queue")
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: testNamespace, Name: "missing"}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(patched).To(BeEmpty())
+}
+
+func TestQueueChangeReloader_Reload(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	var patched []string
+	cl := newQueueChangeClient(&patched,
+		newQueuedPipelineRun("defaulted", "old-queue", "old-queue"),
+		newQueuedPipelineRun("chosen", "old-queue", ""),
+		newQueuedPipelineRun("elsewhere", "other-queue", "other-queue"),
+	)
+	r := NewQueueChangeReconciler(cl, "old-queue")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	reloader := NewQueueChangeReloader(path, time.Second, r)
+	writeConfig := func(content string) {
+		g.Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	// Nothing is moved while the queue doesn't change
+	writeConfig("queueName: old-queue\n")
+	reloader.Reload(ctx)
+	g.Expect(patched).To(BeEmpty())
+
+	// The queue is kept while the configuration is invalid
+	writeConfig("queueName: [")
+	reloader.Reload(ctx)
+	g.Expect(r.QueueName()).To(Equal("old-queue"))
+	writeConfig("controller:\n  drain: true\n")
+	reloader.Reload(ctx)
+	g.Expect(r.QueueName()).To(Equal("old-queue"))
+
+	// Only the PipelineRuns defaulted to the former queue are moved
+	writeConfig("queueName: new-queue\nrequeueOnQueueChange: true\n")
+	reloader.Reload(ctx)
+	g.Expect(r.QueueName()).To(Equal("new-queue"))
+	g.Expect(patched).To(ConsistOf("defaulted"))
+
+	queues := map[string]string{}
+	pipelineRuns := &tekv1.PipelineRunList{}
+	g.Expect(cl.List(ctx, pipelineRuns)).To(Succeed())
+	for _, plr := range pipelineRuns.Items {
+		queues[plr.Name] = plr.Labels[common.QueueLabel]
+	}
+	g.Expect(queues).To(Equal(map[string]string{
+		"defaulted": "new-queue",
+		"chosen":    "old-queue",
+		"elsewhere": "other-queue",
+	}))
+}
@@ -0,0 +1,371 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/workload"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// +kubebuilder:rbac:groups=kueue.x-k8s.io,resources=localqueues,verbs=get;list;watch
+// +kubebuilder:rbac:groups=kueue.x-k8s.io,resources=clusterqueues,verbs=get;list;watch
+
+const (
+	// AnnotationQueuePosition holds the position of a gated PipelineRun in
+	// its ClusterQueue, e.g. "~14" when it's estimated, or "3" when it's
+	// read from the status of the ClusterQueue.
+	AnnotationQueuePosition = annotationDomain + "queue-position"
+	// AnnotationQueuedSince holds the time the Workload of a gated
+	// PipelineRun was created, in RFC 3339 format.
+	AnnotationQueuedSince = annotationDomain + "queued-since"
+)
+
+// PendingIndexKey indexes the PipelineRuns the QueuePositionReporter
+// handles: the gated ones, and the ones still carrying its annotations.
+const PendingIndexKey = "spec.status.pending"
+
+// SetupPendingIndex indexes the PipelineRuns handled by the
+// QueuePositionReporter, so it doesn't list all the PipelineRuns.
+func SetupPendingIndex(ctx context.Context, fieldIndexer client.FieldIndexer) error {
+	return fieldIndexer.IndexField(ctx, &tekv1.PipelineRun{}, PendingIndexKey, indexPending)
+}
+
+// indexPending returns "true" for the gated PipelineRuns and the ones
+// carrying the annotations of the QueuePositionReporter.
+func indexPending(obj client.Object) []string {
+	plr, ok := obj.(*tekv1.PipelineRun)
+	if !ok {
+		return nil
+	}
+	_, hasPosition := plr.Annotations[AnnotationQueuePosition]
+	_, hasQueuedSince := plr.Annotations[AnnotationQueuedSince]
+	if plr.Spec.Status == tekv1.PipelineRunSpecStatusPending || hasPosition || hasQueuedSince {
+		return []string{"true"}
+	}
+	return nil
+}
+
+// QueuePositionReporter periodically estimates the position of gated
+// PipelineRuns in their ClusterQueue and exposes it with the
+// AnnotationQueuePosition annotation, along with the creation time of their
+// Workload with the AnnotationQueuedSince annotation. The annotations are
+// removed once the PipelineRun's Workload is admitted. The PipelineRuns are
+// listed with the PendingIndexKey index.
+//
+// The estimate is the number of pending Workloads in the same ClusterQueue
+// which either have a higher priority, or the same priority and were created
+// earlier. It ignores preemption, borrowing and flavor fungibility, so it's
+// only an approximation. With UseClusterQueueStatus, the positions of the
+// Workloads at the head of the ClusterQueue are read from its status, see
+// ClusterQueuePositions.
+type QueuePositionReporter struct {
+	client client.Client
+	config config.QueuePosition
+	clock  clock.WithTicker
+	log    logr.Logger
+
+	// lastEstimation holds, per ClusterQueue, the time of the last estimation.
+	lastEstimation map[string]time.Time
+}
+
+// NewQueuePositionReporter creates a QueuePositionReporter.
+func NewQueuePositionReporter(c client.Client, cfg config.QueuePosition, clk clock.WithTicker) *QueuePositionReporter {
+	return &QueuePositionReporter{
+		client:         c,
+		config:         cfg,
+		clock:          clk,
+		log:            ctrl.Log.WithName("QueuePositionReporter"),
+		lastEstimation: map[string]time.Time{},
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (r *QueuePositionReporter) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable.
+func (r *QueuePositionReporter) Start(ctx context.Context) error {
+	interval := r.config.GetInterval()
+	r.log.Info("Starting queue position reporter", "interval", interval)
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := r.RunCycle(ctx); err != nil {
+				r.log.Error(err, "Failed to report queue positions")
+			}
+		}
+	}
+}
+
+// RunCycle estimates the queue positions of gated PipelineRuns and updates
+// their annotations. ClusterQueues estimated less than an interval ago and
+// ClusterQueues with more pending Workloads than the configured maximum are
+// skipped, and at most MaxWritesPerCycle PipelineRuns are patched.
+func (r *QueuePositionReporter) RunCycle(ctx context.Context) error {
+	now := r.clock.Now()
+
+	clusterQueues, err := r.localQueuesToClusterQueues(ctx)
+	if err != nil {
+		return err
+	}
+
+	wls := &kueue.WorkloadList{}
+	if err := r.client.List(ctx, wls); err != nil {
+		return fmt.Errorf("listing workloads: %w", err)
+	}
+
+	pending := map[string][]*kueue.Workload{}
+	admitted := map[types.UID]bool{}
+	for i := range wls.Items {
+		wl := &wls.Items[i]
+		owner := pipelineRunOwner(wl)
+		if owner == "" {
+			continue
+		}
+		if workload.HasQuotaReservation(wl) || workload.IsFinished(wl) {
+			admitted[owner] = true
+			continue
+		}
+		cq, ok := clusterQueues[types.NamespacedName{Namespace: wl.Namespace, Name: string(wl.Spec.QueueName)}]
+		if !ok {
+			continue
+		}
+		pending[cq] = append(pending[cq], wl)
+	}
+
+	positions := map[types.UID]string{}
+	queuedSince := map[types.UID]string{}
+	for cq, wls := range pending {
+		if last, ok := r.lastEstimation[cq]; ok && now.Sub(last) < r.config.GetInterval() {
+			continue
+		}
+		if len(wls) > r.config.GetMaxQueueSize() {
+			r.log.V(1).Info("Skipping ClusterQueue above the maximum size", "clusterQueue", cq, "pending", len(wls))
+			continue
+		}
+		r.lastEstimation[cq] = now
+		for owner, position := range ClusterQueuePositions(r.pendingHead(ctx, cq), wls) {
+			positions[owner] = position
+		}
+		for _, wl := range wls {
+			queuedSince[pipelineRunOwner(wl)] = wl.CreationTimestamp.UTC().Format(time.RFC3339)
+		}
+	}
+
+	plrs := &tekv1.PipelineRunList{}
+	if err := r.client.List(ctx, plrs, client.MatchingFields{PendingIndexKey: "true"}); err != nil {
+		return fmt.Errorf("listing pipelineruns: %w", err)
+	}
+
+	writes := 0
+	maxWrites := r.config.GetMaxWritesPerCycle()
+	for i := range plrs.Items {
+		if writes >= maxWrites {
+			r.log.V(1).Info("Reached the maximum number of writes for this cycle", "writes", writes)
+			break
+		}
+		plr := &plrs.Items[i]
+		currentPosition, hasPosition := plr.Annotations[AnnotationQueuePosition]
+		currentSince, hasSince := plr.Annotations[AnnotationQueuedSince]
+
+		var position, since string
+		estimated, ok := positions[plr.UID]
+		switch {
+		case admitted[plr.UID] || plr.Spec.Status != tekv1.PipelineRunSpecStatusPending:
+			if !hasPosition && !hasSince {
+				continue
+			}
+		case ok:
+			position, since = estimated, queuedSince[plr.UID]
+			if currentPosition == position && currentSince == since {
+				continue
+			}
+		default:
+			// Not estimated in this cycle, keep the current annotations.
+			continue
+		}
+
+		if err := r.patchPosition(ctx, plr, position, since); err != nil {
+			r.log.Error(err, "Failed to update the queue position", "pipelineRun", client.ObjectKeyFromObject(plr))
+			continue
+		}
+		writes++
+	}
+
+	return nil
+}
+
+// pendingHead returns the head of the pending Workloads of the ClusterQueue
+// from its status, when UseClusterQueueStatus is set and Kueue exposes it.
+// The errors are logged, falling back to the estimation.
+func (r *QueuePositionReporter) pendingHead(ctx context.Context, name string) []kueue.ClusterQueuePendingWorkload {
+	if !r.config.UseClusterQueueStatus {
+		return nil
+	}
+	cq := &kueue.ClusterQueue{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: name}, cq); err != nil {
+		r.log.V(1).Info("Unable to read the ClusterQueue, estimating the positions", "clusterQueue", name, "error", err.Error())
+		return nil
+	}
+	if cq.Status.PendingWorkloadsStatus == nil {
+		return nil
+	}
+	return cq.Status.PendingWorkloadsStatus.Head
+}
+
+// patchPosition sets the queue position and queued since annotations on
+// the PipelineRun, or removes them when position is empty.
+func (r *QueuePositionReporter) patchPosition(ctx context.Context, plr *tekv1.PipelineRun, position, since string) error {
+	patch := client.MergeFromWithOptions(plr.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if position == "" {
+		delete(plr.Annotations, AnnotationQueuePosition)
+		delete(plr.Annotations, AnnotationQueuedSince)
+	} else {
+		if plr.Annotations == nil {
+			plr.Annotations = map[string]string{}
+		}
+		plr.Annotations[AnnotationQueuePosition] = position
+		plr.Annotations[AnnotationQueuedSince] = since
+	}
+	err := r.client.Patch(ctx, plr, patch)
+	if k8serrors.IsConflict(err) || k8serrors.IsNotFound(err) {
+		// The PipelineRun will be handled in the next cycle.
+		return nil
+	}
+	return err
+}
+
+// localQueuesToClusterQueues maps every LocalQueue to its ClusterQueue.
+func (r *QueuePositionReporter) localQueuesToClusterQueues(ctx context.Context) (map[types.NamespacedName]string, error) {
+	lqs := &kueue.LocalQueueList{}
+	if err := r.client.List(ctx, lqs); err != nil {
+		return nil, fmt.Errorf("listing localqueues: %w", err)
+	}
+	clusterQueues := make(map[types.NamespacedName]string, len(lqs.Items))
+	for _, lq := range lqs.Items {
+		clusterQueues[client.ObjectKeyFromObject(&lq)] = string(lq.Spec.ClusterQueue)
+	}
+	return clusterQueues, nil
+}
+
+// EstimateQueuePositions returns, for each of the given pending Workloads of
+// a ClusterQueue, the number of Workloads ahead of it: the Workloads with a
+// higher priority, plus the Workloads with the same priority created
+// earlier. The result is keyed by the UID of the owning PipelineRun.
+func EstimateQueuePositions(pending []*kueue.Workload) map[types.UID]int {
+	ordered := make([]*kueue.Workload, len(pending))
+	copy(ordered, pending)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		pi, pj := priority(ordered[i]), priority(ordered[j])
+		if pi != pj {
+			return pi > pj
+		}
+		ti, tj := ordered[i].CreationTimestamp, ordered[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return ordered[i].Name < ordered[j].Name
+	})
+
+	positions := make(map[types.UID]int, len(ordered))
+	for i, wl := range ordered {
+		if owner := pipelineRunOwner(wl); owner != "" {
+			positions[owner] = i
+		}
+	}
+	return positions
+}
+
+// ClusterQueuePositions returns the value of the queue position annotation
+// of each of the given pending Workloads of a ClusterQueue, keyed by the UID
+// of the owning PipelineRun. The positions of the Workloads listed in head,
+// the head of the pending Workloads from the status of the ClusterQueue, are
+// exact, e.g. "3". The other Workloads come after them, with the positions
+// estimated by EstimateQueuePositions, e.g. "~14".
+func ClusterQueuePositions(head []kueue.ClusterQueuePendingWorkload, pending []*kueue.Workload) map[types.UID]string {
+	exact := make(map[types.NamespacedName]int, len(head))
+	for i, wl := range head {
+		exact[types.NamespacedName{Namespace: wl.Namespace, Name: wl.Name}] = i
+	}
+
+	positions := make(map[types.UID]string, len(pending))
+	var rest []*kueue.Workload
+	for _, wl := range pending {
+		if i, ok := exact[client.ObjectKeyFromObject(wl)]; ok {
+			if owner := pipelineRunOwner(wl); owner != "" {
+				positions[owner] = strconv.Itoa(i)
+			}
+			continue
+		}
+		rest = append(rest, wl)
+	}
+	for owner, position := range EstimateQueuePositions(rest) {
+		positions[owner] = fmt.Sprintf("~%d", len(head)+position)
+	}
+	return positions
+}
+
+func priority(wl *kueue.Workload) int32 {
+	if wl.Spec.Priority == nil {
+		return 0
+	}
+	return *wl.Spec.Priority
+}
+
+// pipelineRunOwner returns the UID of the PipelineRun owning the Workload,
+// or an empty string if the Workload isn't owned by a PipelineRun.
+func pipelineRunOwner(wl *kueue.Workload) types.UID {
+	if ref := pipelineRunOwnerRef(wl); ref != nil {
+		return ref.UID
+	}
+	return ""
+}
+
+// pipelineRunOwnerRef returns the reference to the PipelineRun owning the
+// object, e.g. a Workload or a TaskRun, or nil if the object isn't owned by
+// a PipelineRun.
+func pipelineRunOwnerRef(obj metav1.Object) *metav1.OwnerReference {
+	refs := obj.GetOwnerReferences()
+	for i := range refs {
+		ref := &refs[i]
+		if ref.Kind == PLRGVK.Kind && ref.APIVersion == PLRGVK.GroupVersion().String() {
+			return ref
+		}
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+const testNamespace = "test-ns"
+
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(kueue.AddToScheme(scheme))
+	utilruntime.Must(tekv1.AddToScheme(scheme))
+	return scheme
+}
+
+// newPendingPipelineRun returns a gated PipelineRun.
+func newPendingPipelineRun(name string) *tekv1.PipelineRun {
+	return &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			UID:       types.UID(name + "-uid"),
+		},
+		Spec: tekv1.PipelineRunSpec{
+			Status: tekv1.PipelineRunSpecStatusPending,
+		},
+	}
+}
+
+// newWorkloadFor returns a pending Workload owned by the PipelineRun.
+func newWorkloadFor(plr *tekv1.PipelineRun, queue string, priority int32, created time.Time) *kueue.Workload {
+	return &kueue.Workload{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pipelinerun-" + plr.Name,
+			Namespace:         plr.Namespace,
+			CreationTimestamp: metav1.NewTime(created),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: PLRGVK.GroupVersion().String(),
+					Kind:       PLRGVK.Kind,
+					Name:       plr.Name,
+					UID:        plr.UID,
+					Controller: ptr.To(true),
+				},
+			},
+		},
+		Spec: kueue.WorkloadSpec{
+			QueueName: queue,
+			Priority:  ptr.To(priority),
+		},
+	}
+}
+
+func newLocalQueue(name, clusterQueue string) *kueue.LocalQueue {
+	return &kueue.LocalQueue{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       kueue.LocalQueueSpec{ClusterQueue: kueue.ClusterQueueReference(clusterQueue)},
+	}
+}
+
+func admit(wl *kueue.Workload) *kueue.Workload {
+	wl.Status.Admission = &kueue.Admission{ClusterQueue: "cq"}
+	wl.Status.Conditions = []metav1.Condition{
+		{Type: kueue.WorkloadQuotaReserved, Status: metav1.ConditionTrue, Reason: "QuotaReserved"},
+		{Type: kueue.WorkloadAdmitted, Status: metav1.ConditionTrue, Reason: "Admitted"},
+	}
+	return wl
+}
+
+// newQueuePositionClientBuilder returns the builder of a fake client
+// indexing the PipelineRuns like the manager of the QueuePositionReporter.
+func newQueuePositionClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithScheme(newTestScheme()).
+		WithIndex(&tekv1.PipelineRun{}, PendingIndexKey, indexPending)
+}
+
+func getQueuePosition(g Gomega, c client.Client, name string) (string, bool) {
+	plr := &tekv1.PipelineRun{}
+	g.Expect(c.Get(context.Background(), types.NamespacedName{Namespace: testNamespace, Name: name}, plr)).To(Succeed())
+	position, ok := plr.Annotations[AnnotationQueuePosition]
+	return position, ok
+}
+
+func TestEstimateQueuePositions(t *testing.T) {
+	g := NewWithT(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	plrs := map[string]*tekv1.PipelineRun{}
+	for _, name := range []string{"low-old", "low-new", "mid-old", "mid-new", "high"} {
+		plrs[name] = newPendingPipelineRun(name)
+	}
+	wls := []*kueue.Workload{
+		newWorkloadFor(plrs["low-new"], "lq", 10, base.Add(4*time.Minute)),
+		newWorkloadFor(plrs["mid-new"], "lq", 100, base.Add(3*time.Minute)),
+		newWorkloadFor(plrs["high"], "lq", 1000, base.Add(5*time.Minute)),
+		newWorkloadFor(plrs["low-old"], "lq", 10, base),
+		newWorkloadFor(plrs["mid-old"], "lq", 100, base.Add(time.Minute)),
+	}
+
+	positions := EstimateQueuePositions(wls)
+
+	g.Expect(positions).To(Equal(map[types.UID]int{
+		plrs["high"].UID:    0,
+		plrs["mid-old"].UID: 1,
+		plrs["mid-new"].UID: 2,
+		plrs["low-old"].UID: 3,
+		plrs["low-new"].UID: 4,
+	}))
+}
+
+func TestQueuePositionReporter_RunCycle(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("annotates gated PipelineRuns per ClusterQueue", func(t *testing.T) {
+		g := NewWithT(t)
+		a, b, c, other := newPendingPipelineRun("a"), newPendingPipelineRun("b"),
+			newPendingPipelineRun("c"), newPendingPipelineRun("other")
+		cl := newQueuePositionClientBuilder().WithObjects(
+			newLocalQueue("lq-1", "cq"),
+			newLocalQueue("lq-2", "cq"),
+			newLocalQueue("lq-other", "cq-other"),
+			a, b, c, other,
+			newWorkloadFor(a, "lq-1", 0, base.Add(time.Minute)),
+			newWorkloadFor(b, "lq-2", 0, base),
+			newWorkloadFor(c, "lq-1", 10, base.Add(2*time.Minute)),
+			newWorkloadFor(other, "lq-other", 0, base.Add(3*time.Minute)),
+		).Build()
+
+		reporter := NewQueuePositionReporter(cl, config.QueuePosition{Enabled: true}, clocktesting.NewFakeClock(base))
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		for name, expected := range map[string]string{"c": "~0", "b": "~1", "a": "~2", "other": "~0"} {
+			position, ok := getQueuePosition(g, cl, name)
+			g.Expect(ok).To(BeTrue(), "missing annotation on %s", name)
+			g.Expect(position).To(Equal(expected), "unexpected position for %s", name)
+		}
+	})
+
+	t.Run("annotates the time the PipelineRuns were queued", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPendingPipelineRun("queued")
+		cl := newQueuePositionClientBuilder().WithObjects(
+			newLocalQueue("lq", "cq"),
+			plr,
+			newWorkloadFor(plr, "lq", 0, base.Add(90*time.Second)),
+		).Build()
+
+		reporter := NewQueuePositionReporter(cl, config.QueuePosition{Enabled: true}, clocktesting.NewFakeClock(base))
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		g.Expect(cl.Get(context.Background(), client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+		g.Expect(plr.Annotations).To(HaveKeyWithValue(AnnotationQueuePosition, "~0"))
+		g.Expect(plr.Annotations).To(HaveKeyWithValue(AnnotationQueuedSince, "2025-01-01T00:01:30Z"))
+	})
+
+	t.Run("removes the annotations on admission", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPendingPipelineRun("admitted")
+		plr.Spec.Status = ""
+		plr.Annotations = map[string]string{
+			AnnotationQueuePosition: "~3",
+			AnnotationQueuedSince:   "2025-01-01T00:00:00Z",
+		}
+		cl := newQueuePositionClientBuilder().WithObjects(
+			newLocalQueue("lq", "cq"),
+			plr,
+			admit(newWorkloadFor(plr, "lq", 0, base)),
+		).Build()
+
+		reporter := NewQueuePositionReporter(cl, config.QueuePosition{Enabled: true}, clocktesting.NewFakeClock(base))
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		g.Expect(cl.Get(context.Background(), client.ObjectKeyFromObject(plr), plr)).To(Succeed())
+		g.Expect(plr.Annotations).NotTo(HaveKey(AnnotationQueuePosition))
+		g.Expect(plr.Annotations).NotTo(HaveKey(AnnotationQueuedSince))
+	})
+
+	t.Run("reads the head of the ClusterQueue from its status", func(t *testing.T) {
+		g := NewWithT(t)
+		a, b, c := newPendingPipelineRun("a"), newPendingPipelineRun("b"), newPendingPipelineRun("c")
+		wlA, wlB, wlC := newWorkloadFor(a, "lq", 100, base), newWorkloadFor(b, "lq", 0, base),
+			newWorkloadFor(c, "lq", 0, base.Add(time.Minute))
+		cq := &kueue.ClusterQueue{
+			ObjectMeta: metav1.ObjectMeta{Name: "cq"},
+			Status: kueue.ClusterQueueStatus{PendingWorkloadsStatus: &kueue.ClusterQueuePendingWorkloadsStatus{
+				// Kueue may order the Workloads differently than the estimation
+				Head: []kueue.ClusterQueuePendingWorkload{
+					{Namespace: "other", Name: "job"},
+					{Namespace: testNamespace, Name: wlB.Name},
+				},
+			}},
+		}
+		cl := newQueuePositionClientBuilder().WithObjects(
+			newLocalQueue("lq", "cq"), cq, a, b, c, wlA, wlB, wlC,
+		).Build()
+
+		reporter := NewQueuePositionReporter(cl,
+			config.QueuePosition{Enabled: true, UseClusterQueueStatus: true}, clocktesting.NewFakeClock(base))
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		for name, expected := range map[string]string{"b": "1", "a": "~2", "c": "~3"} {
+			position, ok := getQueuePosition(g, cl, name)
+			g.Expect(ok).To(BeTrue(), "missing annotation on %s", name)
+			g.Expect(position).To(Equal(expected), "unexpected position for %s", name)
+		}
+	})
+
+	t.Run("estimates the positions without the ClusterQueue", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPendingPipelineRun("a")
+		cl := newQueuePositionClientBuilder().WithObjects(
+			newLocalQueue("lq", "cq"), plr, newWorkloadFor(plr, "lq", 0, base),
+		).Build()
+
+		reporter := NewQueuePositionReporter(cl,
+			config.QueuePosition{Enabled: true, UseClusterQueueStatus: true}, clocktesting.NewFakeClock(base))
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		position, ok := getQueuePosition(g, cl, "a")
+		g.Expect(ok).To(BeTrue())
+		g.Expect(position).To(Equal("~0"))
+	})
+
+	t.Run("caps the number of writes per cycle", func(t *testing.T) {
+		g := NewWithT(t)
+		objs := []client.Object{newLocalQueue("lq", "cq")}
+		for i := range 5 {
+			plr := newPendingPipelineRun(fmt.Sprintf("plr-%d", i))
+			objs = append(objs, plr, newWorkloadFor(plr, "lq", 0, base.Add(time.Duration(i)*time.Minute)))
+		}
+		cl := newQueuePositionClientBuilder().WithObjects(objs...).Build()
+
+		clk := clocktesting.NewFakeClock(base)
+		reporter := NewQueuePositionReporter(cl, config.QueuePosition{Enabled: true, MaxWritesPerCycle: 2}, clk)
+
+		countAnnotated := func() int {
+			annotated := 0
+			for i := range 5 {
+				if _, ok := getQueuePosition(g, cl, fmt.Sprintf("plr-%d", i)); ok {
+					annotated++
+				}
+			}
+			return annotated
+		}
+
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+		g.Expect(countAnnotated()).To(Equal(2))
+
+		// The ClusterQueue was estimated less than an interval ago.
+		clk.Step(config.DefaultQueuePositionInterval / 2)
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+		g.Expect(countAnnotated()).To(Equal(2))
+
+		clk.Step(config.DefaultQueuePositionInterval)
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+		g.Expect(countAnnotated()).To(Equal(4))
+	})
+
+	t.Run("skips ClusterQueues above the maximum size", func(t *testing.T) {
+		g := NewWithT(t)
+		objs := []client.Object{newLocalQueue("lq", "cq")}
+		for i := range 3 {
+			plr := newPendingPipelineRun(fmt.Sprintf("plr-%d", i))
+			objs = append(objs, plr, newWorkloadFor(plr, "lq", 0, base))
+		}
+		cl := newQueuePositionClientBuilder().WithObjects(objs...).Build()
+
+		reporter := NewQueuePositionReporter(
+			cl,
+			config.QueuePosition{Enabled: true, MaxQueueSize: 2},
+			clocktesting.NewFakeClock(base),
+		)
+		g.Expect(reporter.RunCycle(context.Background())).To(Succeed())
+
+		for i := range 3 {
+			_, ok := getQueuePosition(g, cl, fmt.Sprintf("plr-%d", i))
+			g.Expect(ok).To(BeFalse())
+		}
+	})
+}
+
+func TestIndexPending(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(indexPending(newPendingPipelineRun("pending"))).To(Equal([]string{"true"}))
+
+	started := newPendingPipelineRun("started")
+	started.Spec.Status = ""
+	g.Expect(indexPending(started)).To(BeEmpty())
+
+	started.Annotations = map[string]string{AnnotationQueuedSince: "2025-01-01T00:00:00Z"}
+	g.Expect(indexPending(started)).To(Equal([]string{"true"}))
+}
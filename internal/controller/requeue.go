@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// +kubebuilder:rbac:groups="tekton.dev",resources=pipelineruns,verbs=create
+
+const (
+	// AnnotationRequeuedFrom is set on the copies of the preempted
+	// PipelineRuns to the name of the PipelineRun they were copied from.
+	AnnotationRequeuedFrom = annotationDomain + "requeued-from"
+	// AnnotationRequeueCount is set on the copies of the preempted
+	// PipelineRuns to the number of copies made since the original
+	// PipelineRun, the copy included.
+	AnnotationRequeueCount = annotationDomain + "requeue-count"
+	// LabelRequeuedFromUID is set on the copies of the preempted
+	// PipelineRuns to the UID of the PipelineRun they were copied from, so
+	// it's copied once even when stopping it is retried.
+	LabelRequeuedFromUID = annotationDomain + "requeued-from-uid"
+)
+
+// requeueDroppedAnnotations are the annotations of the preempted
+// PipelineRuns which aren't copied, since they describe the state of the
+// original in the queue.
+var requeueDroppedAnnotations = []string{
+	AnnotationOrphaned,
+	AnnotationPendingAdmissionChecks,
+	AnnotationQueuePosition,
+	AnnotationQueuedSince,
+	AnnotationResolvedRequests,
+	AnnotationDrained,
+	common.AdmissionUIDAnnotation,
+}
+
+// requeueIfPreempted creates a pending copy of the PipelineRun when its
+// active Workload was evicted by preemption, so the copy re-enters the
+// queue while the PipelineRun is stopped. Nothing is copied once the
+// PipelineRun was requeued as many times as the preemptionMaxRequeues of
+// its configuration, or when a copy
+// already exists.
+func (p *PipelineRun) requeueIfPreempted(ctx context.Context, c client.Client) error {
+	plr := p.PipelineRun
+	log := ctrl.LoggerFrom(ctx).WithValues("pipelineRun", plr.Namespace+"/"+plr.Name)
+
+	wl, err := activeWorkloadOf(ctx, c, plr)
+	if err != nil {
+		return err
+	}
+	if wl == nil {
+		return nil
+	}
+	evicted := apimeta.FindStatusCondition(wl.Status.Conditions, kueue.WorkloadEvicted)
+	if evicted == nil || evicted.Status != metav1.ConditionTrue || evicted.Reason != kueue.WorkloadEvictedByPreemption {
+		return nil
+	}
+
+	count := requeueCount(plr) + 1
+	if count > p.config.preemptionMaxRequeues {
+		log.Info("Not requeueing the preempted PipelineRun, the requeue limit is reached",
+			"limit", p.config.preemptionMaxRequeues)
+		return nil
+	}
+
+	copies := &tekv1.PipelineRunList{}
+	if err := c.List(ctx, copies,
+		client.InNamespace(plr.Namespace),
+		client.MatchingLabels{LabelRequeuedFromUID: string(plr.UID)},
+	); err != nil {
+		return fmt.Errorf("listing the copies of the PipelineRun: %w", err)
+	}
+	if len(copies.Items) > 0 {
+		return nil
+	}
+
+	requeued := newRequeuedPipelineRun(plr, count)
+	if err := c.Create(ctx, requeued); err != nil {
+		return fmt.Errorf("creating the copy of the preempted PipelineRun: %w", err)
+	}
+	log.Info("Requeued the preempted PipelineRun", "copy", requeued.Name, "requeueCount", count)
+	return nil
+}
+
+// requeueCount returns the number of copies made before the PipelineRun,
+// 0 for an original PipelineRun or an invalid annotation.
+func requeueCount(plr *tekv1.PipelineRun) int {
+	count, err := strconv.Atoi(plr.Annotations[AnnotationRequeueCount])
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// newRequeuedPipelineRun returns the pending copy of the preempted
+// PipelineRun: it has the same spec, labels and annotations, without the
+// status and the metadata set by the API server, and is named after the
+// generateName of the original, or its name when it has none.
+func newRequeuedPipelineRun(plr *tekv1.PipelineRun, count int) *tekv1.PipelineRun {
+	generateName := plr.GenerateName
+	if generateName == "" {
+		generateName = plr.Name + "-"
+	}
+
+	labels := maps.Clone(plr.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[LabelRequeuedFromUID] = string(plr.UID)
+
+	annotations := maps.Clone(plr.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	for _, key := range requeueDroppedAnnotations {
+		delete(annotations, key)
+	}
+	annotations[AnnotationRequeuedFrom] = plr.Name
+	annotations[AnnotationRequeueCount] = strconv.Itoa(count)
+
+	requeued := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName,
+			Namespace:    plr.Namespace,
+			Labels:       labels,
+			Annotations:  annotations,
+		},
+		Spec: *plr.Spec.DeepCopy(),
+	}
+	requeued.Spec.Status = tekv1.PipelineRunSpecStatusPending
+	return requeued
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// requeueConfig returns the configuration requeueing the preempted
+// PipelineRuns up to maxRequeues times.
+func requeueConfig(maxRequeues int) *jobConfig {
+	cfg := defaultJobConfig()
+	cfg.preemptionRequeue = true
+	cfg.preemptionMaxRequeues = maxRequeues
+	return cfg
+}
+
+// newPreemptedPipelineRun returns a running PipelineRun and its Workload,
+// evicted with the reason.
+func newPreemptedPipelineRun(name, reason string) (*tekv1.PipelineRun, *kueue.Workload) {
+	plr := newPendingPipelineRun(name)
+	plr.Spec.Status = ""
+	plr.GenerateName = "build-"
+	plr.ResourceVersion = "42"
+	plr.Labels = map[string]string{
+		common.QueueLabel:               "pipelines-queue",
+		"kueue.x-k8s.io/priority-class": "tekton-kueue-default",
+	}
+	plr.Annotations = map[string]string{
+		"kueue.konflux-ci.dev/requests-cpu": "2",
+		AnnotationQueuedSince:               "2025-01-01T00:00:00Z",
+		common.AdmissionUIDAnnotation:       "admission-uid",
+	}
+	plr.Spec.PipelineRef = &tekv1.PipelineRef{Name: "build"}
+	plr.Spec.Params = tekv1.Params{{Name: "revision", Value: *tekv1.NewStructuredValues("main")}}
+	plr.Status.StartTime = &metav1.Time{Time: time.Now()}
+
+	wl := admit(newWorkloadFor(plr, "pipelines-queue", 0, time.Now()))
+	wl.Status.Conditions = append(wl.Status.Conditions, metav1.Condition{
+		Type: kueue.WorkloadEvicted, Status: metav1.ConditionTrue, Reason: reason,
+	})
+	return plr, wl
+}
+
+// stopPipelineRun stops the PipelineRun, configured by cfg, after the eviction of its Workload,
+// and returns the PipelineRuns of the namespace. The apply patch stopping
+// the PipelineRun is recorded rather than sent, the fake client not
+// supporting it.
+func stopPipelineRun(g Gomega, cfg *jobConfig, plr *tekv1.PipelineRun, objs ...client.Object) []tekv1.PipelineRun {
+	var stopped bool
+	cl := newIndexedClientBuilder().WithObjects(append(objs, plr)...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				stopped = obj.(*tekv1.PipelineRun).Spec.Status == tekv1.PipelineRunSpecStatusStoppedRunFinally
+				return nil
+			},
+		}).
+		Build()
+
+	stoppedNow, err := newPipelineRunJob(plr.DeepCopy(), cfg).Stop(context.Background(), cl, nil,
+		jobframework.StopReasonWorkloadEvicted, "Preempted to accommodate a workload")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(stoppedNow).To(BeTrue())
+	g.Expect(stopped).To(BeTrue())
+
+	plrs := &tekv1.PipelineRunList{}
+	g.Expect(cl.List(context.Background(), plrs, client.InNamespace(plr.Namespace))).To(Succeed())
+	return plrs.Items
+}
+
+// requeuedCopies returns the PipelineRuns copied from the original.
+func requeuedCopies(plrs []tekv1.PipelineRun, original *tekv1.PipelineRun) []tekv1.PipelineRun {
+	var copies []tekv1.PipelineRun
+	for _, plr := range plrs {
+		if plr.Labels[LabelRequeuedFromUID] == string(original.UID) {
+			copies = append(copies, plr)
+		}
+	}
+	return copies
+}
+
+func TestStop_RequeuesPreemptedPipelineRun(t *testing.T) {
+	g := NewWithT(t)
+	cfg := requeueConfig(3)
+
+	plr, wl := newPreemptedPipelineRun("build-abc12", kueue.WorkloadEvictedByPreemption)
+	copies := requeuedCopies(stopPipelineRun(g, cfg, plr, wl), plr)
+	g.Expect(copies).To(HaveLen(1))
+
+	requeued := copies[0]
+	g.Expect(requeued.Name).To(HavePrefix("build-"))
+	g.Expect(requeued.Name).NotTo(Equal(plr.Name))
+	g.Expect(requeued.UID).NotTo(Equal(plr.UID))
+	g.Expect(requeued.OwnerReferences).To(BeEmpty())
+	g.Expect(requeued.Status).To(Equal(tekv1.PipelineRunStatus{}))
+	g.Expect(requeued.Labels).To(Equal(map[string]string{
+		common.QueueLabel:               "pipelines-queue",
+		"kueue.x-k8s.io/priority-class": "tekton-kueue-default",
+		LabelRequeuedFromUID:            string(plr.UID),
+	}))
+	g.Expect(requeued.Annotations).To(Equal(map[string]string{
+		"kueue.konflux-ci.dev/requests-cpu": "2",
+		AnnotationRequeuedFrom:              plr.Name,
+		AnnotationRequeueCount:              "1",
+	}))
+
+	expectedSpec := plr.Spec.DeepCopy()
+	expectedSpec.Status = tekv1.PipelineRunSpecStatusPending
+	g.Expect(requeued.Spec).To(Equal(*expectedSpec))
+}
+
+func TestStop_RequeueLimit(t *testing.T) {
+	g := NewWithT(t)
+	cfg := requeueConfig(2)
+
+	plr, wl := newPreemptedPipelineRun("build-abc12", kueue.WorkloadEvictedByPreemption)
+	plr.Annotations[AnnotationRequeueCount] = "1"
+	copies := requeuedCopies(stopPipelineRun(g, cfg, plr, wl), plr)
+	g.Expect(copies).To(HaveLen(1))
+	g.Expect(copies[0].Annotations).To(HaveKeyWithValue(AnnotationRequeueCount, "2"))
+
+	plr, wl = newPreemptedPipelineRun("build-def34", kueue.WorkloadEvictedByPreemption)
+	plr.Annotations[AnnotationRequeueCount] = "2"
+	g.Expect(requeuedCopies(stopPipelineRun(g, cfg, plr, wl), plr)).To(BeEmpty())
+}
+
+func TestStop_NotRequeued(t *testing.T) {
+	tests := []struct {
+		name    string
+		requeue bool
+		reason  string
+		objs    func(plr *tekv1.PipelineRun) []client.Object
+	}{
+		{
+			name:   "disabled by default",
+			reason: kueue.WorkloadEvictedByPreemption,
+		},
+		{
+			name:    "evicted by deactivation",
+			requeue: true,
+			reason:  kueue.WorkloadEvictedByDeactivation,
+		},
+		{
+			name:    "already copied",
+			requeue: true,
+			reason:  kueue.WorkloadEvictedByPreemption,
+			objs: func(plr *tekv1.PipelineRun) []client.Object {
+				return []client.Object{newRequeuedPipelineRun(plr, 1)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cfg := defaultJobConfig()
+			if tt.requeue {
+				cfg = requeueConfig(3)
+			}
+
+			plr, wl := newPreemptedPipelineRun("build-abc12", tt.reason)
+			objs := []client.Object{wl}
+			existing := 0
+			if tt.objs != nil {
+				extra := tt.objs(plr)
+				existing = len(extra)
+				for _, obj := range extra {
+					obj.SetName(obj.GetGenerateName() + "previous")
+				}
+				objs = append(objs, extra...)
+			}
+			g.Expect(requeuedCopies(stopPipelineRun(g, cfg, plr, objs...), plr)).To(HaveLen(existing))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/workload"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+const (
+	// AnnotationResolvedRequests holds, as a JSON object, the resources
+	// requested by the PipelineRun owning a Workload, e.g.
+	// {"cpu":"500m","linux-arm64":"1"}.
+	AnnotationResolvedRequests = annotationDomain + "resolved-requests"
+
+	// resolvedRequestsTruncatedKey is added to the annotation when requests
+	// were dropped to respect the size limit. Resource names can't start
+	// with an underscore, so it can't clash with a request.
+	resolvedRequestsTruncatedKey = "_truncated"
+)
+
+// ResolvedRequestsReconciler annotates the Workloads of PipelineRuns with
+// the resources they request, so they can be consumed without joining
+// Workloads with PipelineRuns. The annotation is kept up to date until the
+// Workload is admitted.
+type ResolvedRequestsReconciler struct {
+	client client.Client
+	config config.ResolvedRequests
+}
+
+// NewResolvedRequestsReconciler creates a ResolvedRequestsReconciler.
+func NewResolvedRequestsReconciler(c client.Client, cfg config.ResolvedRequests) *ResolvedRequestsReconciler {
+	return &ResolvedRequestsReconciler{client: c, config: cfg}
+}
+
+// SetupWithManager registers the reconciler, triggered by changes of
+// Workloads owned by PipelineRuns.
+func (r *ResolvedRequestsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ownedByPipelineRun := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		wl, ok := obj.(*kueue.Workload)
+		return ok && pipelineRunOwnerRef(wl) != nil
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("ResolvedRequests").
+		For(&kueue.Workload{}, builder.WithPredicates(ownedByPipelineRun)).
+		Complete(r)
+}
+
+// Reconcile updates the annotation of the Workload, unless it's admitted.
+func (r *ResolvedRequestsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	wl := &kueue.Workload{}
+	if err := r.client.Get(ctx, req.NamespacedName, wl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !wl.DeletionTimestamp.IsZero() || workload.HasQuotaReservation(wl) || workload.IsFinished(wl) {
+		return ctrl.Result{}, nil
+	}
+
+	value, err := resolvedRequests(wl, r.config.GetMaxSize())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if current, ok := wl.Annotations[AnnotationResolvedRequests]; ok && current == value {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(wl.DeepCopy())
+	if wl.Annotations == nil {
+		wl.Annotations = map[string]string{}
+	}
+	wl.Annotations[AnnotationResolvedRequests] = value
+	return ctrl.Result{}, client.IgnoreNotFound(r.client.Patch(ctx, wl, patch))
+}
+
+// resolvedRequests serializes the requests of the Workload's PodSets. When
+// the result exceeds maxSize bytes, the requests are dropped in reverse
+// alphabetical order until it fits, and resolvedRequestsTruncatedKey is
+// added.
+func resolvedRequests(wl *kueue.Workload, maxSize int) (string, error) {
+	requests := map[string]string{}
+	for _, ps := range wl.Spec.PodSets {
+		for _, c := range ps.Template.Spec.Containers {
+			for name, q := range c.Resources.Requests {
+				requests[string(name)] = q.String()
+			}
+		}
+	}
+
+	data, err := json.Marshal(requests)
+	if err != nil {
+		return "", fmt.Errorf("serializing resource requests: %w", err)
+	}
+	if len(data) <= maxSize {
+		return string(data), nil
+	}
+
+	names := make([]string, 0, len(requests))
+	for name := range requests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	truncated := map[string]string{resolvedRequestsTruncatedKey: "true"}
+	result, err := json.Marshal(truncated)
+	if err != nil {
+		return "", fmt.Errorf("serializing resource requests: %w", err)
+	}
+	for _, name := range names {
+		truncated[name] = requests[name]
+		data, err := json.Marshal(truncated)
+		if err != nil {
+			return "", fmt.Errorf("serializing resource requests: %w", err)
+		}
+		if len(data) > maxSize {
+			break
+		}
+		result = data
+	}
+	return string(result), nil
+}
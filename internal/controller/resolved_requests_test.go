@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// podSetRequests returns the requests of the Workload's PodSets in the
+// format of AnnotationResolvedRequests.
+func podSetRequests(g Gomega, wl *kueue.Workload) map[string]string {
+	requests := map[string]string{}
+	for name, q := range wl.Spec.PodSets[0].Template.Spec.Containers[0].Resources.Requests {
+		requests[string(name)] = q.String()
+	}
+	g.Expect(requests).NotTo(BeEmpty())
+	return requests
+}
+
+func getResolvedRequests(g Gomega, c client.Client, wl *kueue.Workload) (map[string]string, bool) {
+	updated := &kueue.Workload{}
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(wl), updated)).To(Succeed())
+	value, ok := updated.Annotations[AnnotationResolvedRequests]
+	if !ok {
+		return nil, false
+	}
+	requests := map[string]string{}
+	g.Expect(json.Unmarshal([]byte(value), &requests)).To(Succeed())
+	return requests, true
+}
+
+// syncPodSets updates the PodSets of the Workload from the PipelineRun, as
+// the Workload reconciler does for pending Workloads.
+func syncPodSets(g Gomega, c client.Client, plr *tekv1.PipelineRun, wl *kueue.Workload) {
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(wl), wl)).To(Succeed())
+	podSets, err := newPipelineRunJob(plr, nil).PodSets()
+	g.Expect(err).NotTo(HaveOccurred())
+	wl.Spec.PodSets = podSets
+	g.Expect(c.Update(context.Background(), wl)).To(Succeed())
+}
+
+func TestResolvedRequestsReconciler_Reconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newPendingPipelineRun("plr")
+	plr.Annotations = map[string]string{
+		annotationResourcesRequests + "cpu":         "500m",
+		annotationResourcesRequests + "linux-arm64": "1",
+	}
+	wl := newWorkloadFor(plr, "lq", 0, time.Now())
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(plr, wl).Build()
+	syncPodSets(g, cl, plr, wl)
+
+	r := NewResolvedRequestsReconciler(cl, config.ResolvedRequests{Enabled: true})
+	reconcile := func() {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+
+	reconcile()
+	requests, ok := getResolvedRequests(g, cl, wl)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(requests).To(Equal(podSetRequests(g, wl)))
+	g.Expect(requests).To(Equal(map[string]string{
+		"cpu":                    "500m",
+		"linux-arm64":            "1",
+		ResourcePipelineRunCount: "1",
+	}))
+
+	// The requests change before the Workload is admitted
+	plr.Annotations[annotationResourcesRequests+"linux-arm64"] = "2"
+	plr.Annotations[annotationResourcesRequests+"memory"] = "1Gi"
+	syncPodSets(g, cl, plr, wl)
+	reconcile()
+	requests, _ = getResolvedRequests(g, cl, wl)
+	g.Expect(requests).To(Equal(podSetRequests(g, wl)))
+	g.Expect(requests).To(HaveKeyWithValue("linux-arm64", "2"))
+	g.Expect(requests).To(HaveKeyWithValue("memory", "1Gi"))
+
+	// The annotation isn't updated once the Workload is admitted
+	g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(wl), wl)).To(Succeed())
+	g.Expect(cl.Update(ctx, admit(wl))).To(Succeed())
+	delete(plr.Annotations, annotationResourcesRequests+"memory")
+	syncPodSets(g, cl, plr, wl)
+	reconcile()
+	requests, _ = getResolvedRequests(g, cl, wl)
+	g.Expect(requests).To(HaveKeyWithValue("memory", "1Gi"))
+}
+
+func TestResolvedRequestsReconciler_ReconcileMissingWorkload(t *testing.T) {
+	g := NewWithT(t)
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).Build()
+
+	r := NewResolvedRequestsReconciler(cl, config.ResolvedRequests{Enabled: true})
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: testNamespace, Name: "missing"}})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestResolvedRequests_Truncation(t *testing.T) {
+	plr := newPendingPipelineRun("plr")
+	plr.Annotations = map[string]string{
+		annotationResourcesRequests + "a": "1",
+		annotationResourcesRequests + "b": "2",
+		annotationResourcesRequests + "c": "3",
+	}
+	podSets, err := newPipelineRunJob(plr, nil).PodSets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wl := newWorkloadFor(plr, "lq", 0, time.Now())
+	wl.Spec.PodSets = podSets
+
+	tests := []struct {
+		name     string
+		maxSize  int
+		expected string
+	}{
+		{
+			name:     "fits",
+			maxSize:  config.DefaultResolvedRequestsMaxSize,
+			expected: `{"a":"1","b":"2","c":"3","tekton.dev/pipelineruns":"1"}`,
+		},
+		{
+			name:     "truncated",
+			maxSize:  len(`{"_truncated":"true","a":"1","b":"2"}`),
+			expected: `{"_truncated":"true","a":"1","b":"2"}`,
+		},
+		{
+			name:     "only the marker fits",
+			maxSize:  1,
+			expected: `{"_truncated":"true"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			value, err := resolvedRequests(wl, tt.maxSize)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(value).To(Equal(tt.expected))
+		})
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// startedUnqueued returns whether the PipelineRun has started without the
+// queue label, and adopting such PipelineRuns is disabled.
+//
+// They're the PipelineRuns which were already running when tekton-kueue
+// was rolled out, or when their namespace started being managed, and so
+// never went through the webhook. Without a Workload, the jobframework
+// would see them as unsuspended jobs and stop them, cancelling live builds,
+// so they're left alone until they're done.
+func (p *PipelineRun) startedUnqueued() bool {
+	if p.config.adoptRunning {
+		return false
+	}
+	return p.HasStarted() && p.Labels[common.QueueLabel] == ""
+}
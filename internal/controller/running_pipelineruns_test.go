@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// newRunningPipelineRun returns a PipelineRun which started without going
+// through the webhook, like the ones in flight when tekton-kueue is rolled
+// out.
+func newRunningPipelineRun(name string) *tekv1.PipelineRun {
+	plr := newPendingPipelineRun(name)
+	plr.Spec.Status = ""
+	plr.Status.StartTime = &metav1.Time{Time: metav1.Now().Time}
+	return plr
+}
+
+func TestPipelineRun_SkipRunning(t *testing.T) {
+	g := NewWithT(t)
+
+	running := newPipelineRunJob(newRunningPipelineRun("running"), nil)
+	g.Expect(running.Skip()).To(BeTrue())
+
+	queued := newPipelineRunJob(newRunningPipelineRun("queued"), nil)
+	queued.Labels = map[string]string{common.QueueLabel: "pipelines-queue"}
+	g.Expect(queued.Skip()).To(BeFalse())
+
+	pending := newPipelineRunJob(newPendingPipelineRun("pending"), nil)
+	g.Expect(pending.Skip()).To(BeFalse())
+
+	running.config.adoptRunning = true
+	g.Expect(running.Skip()).To(BeFalse())
+}
+
+// TestWorkloadReconciler_RunningPipelineRun reconciles a PipelineRun which
+// was already running when the controller started managing it, with the
+// Workload reconciler managing the jobs without queue name, the rollout
+// scenario cancelling live builds.
+func TestWorkloadReconciler_RunningPipelineRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		adopt           bool
+		expectedStopped bool
+	}{
+		{name: "skipped by default"},
+		{name: "adopted", adopt: true, expectedStopped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cfg := defaultJobConfig()
+			cfg.adoptRunning = tt.adopt
+
+			plr := newRunningPipelineRun("in-flight")
+			var patches []client.Object
+			cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(plr, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}).
+				WithIndex(&kueue.Workload{}, jobframework.GetOwnerKey(PLRGVK), func(o client.Object) []string {
+					var owners []string
+					for _, owner := range o.GetOwnerReferences() {
+						owners = append(owners, owner.Name)
+					}
+					return owners
+				}).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+						patches = append(patches, obj)
+						return nil
+					},
+				}).
+				Build()
+			reconciler := jobframework.NewReconciler(cl, record.NewFakeRecorder(10),
+				jobframework.WithManageJobsWithoutQueueName(true),
+				jobframework.WithManagedJobsNamespaceSelector(labels.Everything()))
+
+			_, err := reconciler.ReconcileGenericJob(context.Background(),
+				ctrl.Request{NamespacedName: client.ObjectKeyFromObject(plr)}, newPipelineRunJob(&tekv1.PipelineRun{}, cfg))
+			g.Expect(err).NotTo(HaveOccurred())
+
+			if !tt.expectedStopped {
+				g.Expect(patches).To(BeEmpty())
+				workloads := &kueue.WorkloadList{}
+				g.Expect(cl.List(context.Background(), workloads)).To(Succeed())
+				g.Expect(workloads.Items).To(BeEmpty())
+				return
+			}
+			g.Expect(patches).To(ContainElement(WithTransform(func(obj client.Object) tekv1.PipelineRunSpecStatus {
+				if plr, ok := obj.(*tekv1.PipelineRun); ok {
+					return plr.Spec.Status
+				}
+				return ""
+			}, Equal(tekv1.PipelineRunSpecStatus(tekv1.PipelineRunSpecStatusStoppedRunFinally)))))
+		})
+	}
+}
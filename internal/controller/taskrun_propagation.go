@@ -0,0 +1,164 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/flowcontrol"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// +kubebuilder:rbac:groups="tekton.dev",resources=taskruns,verbs=list;watch;patch
+
+const (
+	// taskRunPropagationQPS and taskRunPropagationBurst limit the rate of
+	// the TaskRun patches, so large PipelineRuns don't flood the
+	// kube-apiserver.
+	taskRunPropagationQPS   = 20
+	taskRunPropagationBurst = 100
+)
+
+// TaskRunPropagationReconciler copies the configured labels and annotations
+// of the PipelineRuns managed by Kueue onto their TaskRuns when they're
+// created, for the systems which only look at TaskRuns. The values already
+// set on the TaskRuns are never overridden.
+type TaskRunPropagationReconciler struct {
+	client  client.Client
+	keys    config.PropagationKeys
+	limiter flowcontrol.RateLimiter
+}
+
+// NewTaskRunPropagationReconciler creates a TaskRunPropagationReconciler
+// propagating the keys.
+func NewTaskRunPropagationReconciler(c client.Client, keys config.PropagationKeys) *TaskRunPropagationReconciler {
+	return &TaskRunPropagationReconciler{
+		client:  c,
+		keys:    keys,
+		limiter: flowcontrol.NewTokenBucketRateLimiter(taskRunPropagationQPS, taskRunPropagationBurst),
+	}
+}
+
+// SetupWithManager registers the reconciler, triggered by the creation of
+// the TaskRuns owned by PipelineRuns.
+func (r *TaskRunPropagationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	createdByPipelineRun := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return pipelineRunOwnerRef(e.Object) != nil
+		},
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("TaskRunPropagation").
+		For(&tekv1.TaskRun{}, builder.WithPredicates(createdByPipelineRun)).
+		Complete(r)
+}
+
+// Reconcile patches the labels and annotations of the PipelineRun missing
+// from the TaskRun onto it.
+func (r *TaskRunPropagationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	tr := &tekv1.TaskRun{}
+	if err := r.client.Get(ctx, req.NamespacedName, tr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// The TaskRuns which completed before the controller started are left
+	// alone
+	if !tr.DeletionTimestamp.IsZero() || tr.IsDone() {
+		return ctrl.Result{}, nil
+	}
+	ref := pipelineRunOwnerRef(tr)
+	if ref == nil {
+		return ctrl.Result{}, nil
+	}
+	plr := &tekv1.PipelineRun{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: tr.Namespace, Name: ref.Name}, plr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if plr.UID != ref.UID {
+		return ctrl.Result{}, nil
+	}
+	if _, managed := plr.Labels[common.QueueLabel]; !managed {
+		return ctrl.Result{}, nil
+	}
+
+	// Fail on conflicts, rather than overwriting the values set on the
+	// TaskRun since it was read
+	patch := client.MergeFromWithOptions(tr.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	labels := propagate(r.keys, plr.Labels, tr.Labels)
+	annotations := propagate(r.keys, plr.Annotations, tr.Annotations)
+	if labels == nil && annotations == nil {
+		return ctrl.Result{}, nil
+	}
+	if labels != nil {
+		tr.Labels = labels
+	}
+	if annotations != nil {
+		tr.Annotations = annotations
+	}
+
+	if err := r.limiter.Wait(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Patch(ctx, tr, patch); err != nil {
+		if apierrors.IsConflict(err) {
+			recordPropagationWrite(propagationResultConflict)
+			// The cache will deliver the newer TaskRun
+			return ctrl.Result{Requeue: true}, nil
+		}
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		recordPropagationWrite(propagationResultFailure)
+		return ctrl.Result{}, err
+	}
+	recordPropagationWrite(propagationResultSuccess)
+	return ctrl.Result{}, nil
+}
+
+// propagate returns a copy of dst with the keys set in src and missing from
+// dst, or nil if dst isn't missing any.
+func propagate(keys []string, src, dst map[string]string) map[string]string {
+	var merged map[string]string
+	for _, key := range keys {
+		value, ok := src[key]
+		if !ok {
+			continue
+		}
+		if _, set := dst[key]; set {
+			continue
+		}
+		if merged == nil {
+			merged = make(map[string]string, len(dst)+len(keys))
+			for k, v := range dst {
+				merged[k] = v
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	kapi "knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var propagatedKeys = config.PropagationKeys{"kueue.x-k8s.io/priority-class", "acme.io/cost-center"}
+
+// newManagedPipelineRun returns a PipelineRun managed by Kueue, with the
+// propagated keys set as labels and annotations.
+func newManagedPipelineRun(name string) *tekv1.PipelineRun {
+	plr := newPendingPipelineRun(name)
+	plr.Labels = map[string]string{
+		common.QueueLabel:               "lq",
+		"kueue.x-k8s.io/priority-class": "high",
+		"unrelated":                     "label",
+	}
+	plr.Annotations = map[string]string{"acme.io/cost-center": "cc-42"}
+	return plr
+}
+
+// newTaskRunOf returns a TaskRun owned by the PipelineRun.
+func newTaskRunOf(plr *tekv1.PipelineRun, name string) *tekv1.TaskRun {
+	return &tekv1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: plr.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(plr, PLRGVK),
+			},
+		},
+	}
+}
+
+func TestTaskRunPropagationReconciler_Reconcile(t *testing.T) {
+	plr := newManagedPipelineRun("plr")
+
+	tests := []struct {
+		name                string
+		pipelineRun         *tekv1.PipelineRun
+		taskRun             func() *tekv1.TaskRun
+		expectedLabels      map[string]string
+		expectedAnnotations map[string]string
+		expectedWrites      float64
+	}{
+		{
+			name:        "missing keys are propagated",
+			pipelineRun: plr,
+			taskRun:     func() *tekv1.TaskRun { return newTaskRunOf(plr, "tr") },
+			expectedLabels: map[string]string{
+				"kueue.x-k8s.io/priority-class": "high",
+			},
+			expectedAnnotations: map[string]string{"acme.io/cost-center": "cc-42"},
+			expectedWrites:      1,
+		},
+		{
+			name:        "values of the TaskRun aren't overridden",
+			pipelineRun: plr,
+			taskRun: func() *tekv1.TaskRun {
+				tr := newTaskRunOf(plr, "tr")
+				tr.Labels = map[string]string{"kueue.x-k8s.io/priority-class": "low", "tekton.dev/task": "build"}
+				return tr
+			},
+			expectedLabels: map[string]string{
+				"kueue.x-k8s.io/priority-class": "low",
+				"tekton.dev/task":               "build",
+			},
+			expectedAnnotations: map[string]string{"acme.io/cost-center": "cc-42"},
+			expectedWrites:      1,
+		},
+		{
+			name:        "nothing to propagate",
+			pipelineRun: plr,
+			taskRun: func() *tekv1.TaskRun {
+				tr := newTaskRunOf(plr, "tr")
+				tr.Labels = map[string]string{"kueue.x-k8s.io/priority-class": "low"}
+				tr.Annotations = map[string]string{"acme.io/cost-center": "cc-1"}
+				return tr
+			},
+			expectedLabels:      map[string]string{"kueue.x-k8s.io/priority-class": "low"},
+			expectedAnnotations: map[string]string{"acme.io/cost-center": "cc-1"},
+		},
+		{
+			name: "PipelineRun not managed by Kueue",
+			pipelineRun: func() *tekv1.PipelineRun {
+				unmanaged := newManagedPipelineRun("plr")
+				delete(unmanaged.Labels, common.QueueLabel)
+				return unmanaged
+			}(),
+			taskRun: func() *tekv1.TaskRun { return newTaskRunOf(plr, "tr") },
+		},
+		{
+			name:        "TaskRun owned by a previous PipelineRun with the same name",
+			pipelineRun: plr,
+			taskRun: func() *tekv1.TaskRun {
+				tr := newTaskRunOf(plr, "tr")
+				tr.OwnerReferences[0].UID = "previous-uid"
+				return tr
+			},
+		},
+		{
+			name:        "completed TaskRun",
+			pipelineRun: plr,
+			taskRun: func() *tekv1.TaskRun {
+				tr := newTaskRunOf(plr, "tr")
+				tr.Status.Status = duckv1.Status{Conditions: duckv1.Conditions{{
+					Type:   kapi.ConditionSucceeded,
+					Status: corev1.ConditionTrue,
+				}}}
+				return tr
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			tr := tt.taskRun()
+			cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(tt.pipelineRun, tr).Build()
+			r := NewTaskRunPropagationReconciler(cl, propagatedKeys)
+			writes := testutil.ToFloat64(taskRunPropagationWritesTotal.WithLabelValues(propagationResultSuccess))
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tr)})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{}))
+
+			updated := &tekv1.TaskRun{}
+			g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(tr), updated)).To(Succeed())
+			if tt.expectedLabels == nil {
+				g.Expect(updated.Labels).To(Equal(tr.Labels))
+				g.Expect(updated.Annotations).To(Equal(tr.Annotations))
+			} else {
+				g.Expect(updated.Labels).To(Equal(tt.expectedLabels))
+				g.Expect(updated.Annotations).To(Equal(tt.expectedAnnotations))
+			}
+			g.Expect(testutil.ToFloat64(taskRunPropagationWritesTotal.WithLabelValues(propagationResultSuccess)) - writes).
+				To(Equal(tt.expectedWrites))
+		})
+	}
+}
+
+func TestTaskRunPropagationReconciler_Conflict(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	plr := newManagedPipelineRun("plr")
+	tr := newTaskRunOf(plr, "tr")
+	conflicts := 0
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(plr, tr).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if conflicts == 0 {
+					conflicts++
+					return apierrors.NewConflict(schema.GroupResource{Group: "tekton.dev", Resource: "taskruns"}, obj.GetName(),
+						errors.New("the object has been modified"))
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+	r := NewTaskRunPropagationReconciler(cl, propagatedKeys)
+	conflictWrites := testutil.ToFloat64(taskRunPropagationWritesTotal.WithLabelValues(propagationResultConflict))
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{Requeue: true}))
+	g.Expect(testutil.ToFloat64(taskRunPropagationWritesTotal.WithLabelValues(propagationResultConflict)) - conflictWrites).
+		To(Equal(1.0))
+
+	result, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tr)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	updated := &tekv1.TaskRun{}
+	g.Expect(cl.Get(ctx, client.ObjectKeyFromObject(tr), updated)).To(Succeed())
+	g.Expect(updated.Labels).To(HaveKeyWithValue("kueue.x-k8s.io/priority-class", "high"))
+}
+
+var _ = Describe("TaskRun propagation", Ordered, func() {
+	var mgrCancel context.CancelFunc
+
+	BeforeAll(func() {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:  scheme.Scheme,
+			Metrics: metricsserver.Options{BindAddress: "0"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(NewTaskRunPropagationReconciler(mgr.GetClient(), propagatedKeys).SetupWithManager(mgr)).To(Succeed())
+
+		var mgrCtx context.Context
+		mgrCtx, mgrCancel = context.WithCancel(ctx)
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(mgrCtx)).To(Succeed())
+		}()
+
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "propagation"}})).To(Succeed())
+	})
+
+	AfterAll(func() {
+		mgrCancel()
+	})
+
+	It("propagates the keys of the PipelineRun to its TaskRuns without overriding them", func() {
+		plr := newManagedPipelineRun("plr")
+		plr.Namespace = "propagation"
+		plr.UID = ""
+		plr.Spec = tekv1.PipelineRunSpec{PipelineRef: &tekv1.PipelineRef{Name: "pipeline"}}
+		Expect(k8sClient.Create(ctx, plr)).To(Succeed())
+
+		spawned := newTaskRunOf(plr, "plr-build")
+		overridden := newTaskRunOf(plr, "plr-test")
+		overridden.Labels = map[string]string{"kueue.x-k8s.io/priority-class": "low"}
+		Expect(k8sClient.Create(ctx, spawned)).To(Succeed())
+		Expect(k8sClient.Create(ctx, overridden)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			tr := &tekv1.TaskRun{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(spawned), tr)).To(Succeed())
+			g.Expect(tr.Labels).To(HaveKeyWithValue("kueue.x-k8s.io/priority-class", "high"))
+			g.Expect(tr.Labels).NotTo(HaveKey("unrelated"))
+			g.Expect(tr.Annotations).To(HaveKeyWithValue("acme.io/cost-center", "cc-42"))
+		}).Should(Succeed())
+		Eventually(func(g Gomega) {
+			tr := &tekv1.TaskRun{}
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(overridden), tr)).To(Succeed())
+			g.Expect(tr.Annotations).To(HaveKeyWithValue("acme.io/cost-center", "cc-42"))
+			g.Expect(tr.Labels).To(HaveKeyWithValue("kueue.x-k8s.io/priority-class", "low"))
+		}).Should(Succeed())
+	})
+})
@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	kueueconfig "sigs.k8s.io/kueue/apis/config/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+func TestWaitForPodsReadyConfig(t *testing.T) {
+	timeout := &metav1.Duration{Duration: 5 * time.Minute}
+	recoveryTimeout := &metav1.Duration{Duration: time.Minute}
+
+	tests := []struct {
+		name     string
+		cfg      config.WaitForPodsReady
+		expected *kueueconfig.WaitForPodsReady
+		errMsg   string
+	}{
+		{
+			name: "absent",
+		},
+		{
+			name: "disabled with timeouts",
+			cfg:  config.WaitForPodsReady{Timeout: timeout},
+		},
+		{
+			name:     "enabled",
+			cfg:      config.WaitForPodsReady{Enable: true},
+			expected: &kueueconfig.WaitForPodsReady{Enable: true},
+		},
+		{
+			name:     "enabled with timeouts",
+			cfg:      config.WaitForPodsReady{Enable: true, Timeout: timeout, RecoveryTimeout: recoveryTimeout},
+			expected: &kueueconfig.WaitForPodsReady{Enable: true, Timeout: timeout, RecoveryTimeout: recoveryTimeout},
+		},
+		{
+			name:   "negative timeout",
+			cfg:    config.WaitForPodsReady{Enable: true, Timeout: &metav1.Duration{Duration: -time.Second}},
+			errMsg: "waitForPodsReady timeout must not be negative",
+		},
+		{
+			name:   "negative recovery timeout",
+			cfg:    config.WaitForPodsReady{Enable: true, RecoveryTimeout: &metav1.Duration{Duration: -time.Second}},
+			errMsg: "waitForPodsReady recoveryTimeout must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			result, err := waitForPodsReadyConfig(tt.cfg)
+			if tt.errMsg != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.errMsg)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestWorkloadReconcilerOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	applied := func(opts []jobframework.Option) jobframework.Options {
+		var options jobframework.Options
+		for _, opt := range opts {
+			opt(&options)
+		}
+		return options
+	}
+
+	opts, err := workloadReconcilerOptions(config.Controller{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(opts).To(BeEmpty())
+	g.Expect(applied(opts).WaitForPodsReady).To(BeFalse())
+
+	opts, err = workloadReconcilerOptions(config.Controller{WaitForPodsReady: config.WaitForPodsReady{Enable: true}})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(opts).To(HaveLen(1))
+	g.Expect(applied(opts).WaitForPodsReady).To(BeTrue())
+}
+
+func TestSetupWithManager_WithoutWaitForPodsReady(t *testing.T) {
+	g := NewWithT(t)
+
+	// The manager isn't started, the API server isn't contacted
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "https://127.0.0.1:1"}, ctrl.Options{
+		Scheme:  newTestScheme(),
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	cfg := &config.Config{}
+	g.Expect(SetupWithManager(mgr, cfg.Controller)).To(Succeed())
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// cleanupGracePeriod is the time given to the deletion of a PipelineRun,
+// once its Workloads are annotated for cleanup, before the annotation is
+// considered stale, e.g. because the deletion was denied by another
+// webhook.
+const cleanupGracePeriod = time.Minute
+
+// WorkloadCleanupReconciler deletes the Workloads annotated with
+// common.CleanupAnnotation by the deletion protection of the webhook, once
+// their PipelineRun is deleted, rather than leaving them to the garbage
+// collector. The Workloads of the PipelineRuns which are still there past
+// the grace period are left alone.
+type WorkloadCleanupReconciler struct {
+	client      client.Client
+	clock       clock.PassiveClock
+	gracePeriod time.Duration
+}
+
+// NewWorkloadCleanupReconciler creates a WorkloadCleanupReconciler.
+func NewWorkloadCleanupReconciler(c client.Client, clk clock.PassiveClock) *WorkloadCleanupReconciler {
+	return &WorkloadCleanupReconciler{client: c, clock: clk, gracePeriod: cleanupGracePeriod}
+}
+
+// SetupWithManager registers the reconciler, triggered by the Workloads
+// annotated for cleanup.
+func (r *WorkloadCleanupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("WorkloadCleanup").
+		For(&kueue.Workload{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			_, ok := obj.GetAnnotations()[common.CleanupAnnotation]
+			return ok
+		}))).
+		Complete(r)
+}
+
+// Reconcile deletes the Workload annotated for cleanup once its PipelineRun
+// is gone or being deleted. The PipelineRun may still be there when the
+// annotation is seen, since the webhook annotates the Workloads before the
+// deletion is persisted, so it's checked again until the grace period
+// ends.
+func (r *WorkloadCleanupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	wl := &kueue.Workload{}
+	if err := r.client.Get(ctx, req.NamespacedName, wl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	annotated, ok := wl.Annotations[common.CleanupAnnotation]
+	if !ok || !wl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	ref := pipelineRunOwnerRef(wl)
+	if ref == nil {
+		return ctrl.Result{}, nil
+	}
+
+	plr := &tekv1.PipelineRun{}
+	err := r.client.Get(ctx, client.ObjectKey{Namespace: wl.Namespace, Name: ref.Name}, plr)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if err == nil && plr.UID == ref.UID && plr.DeletionTimestamp.IsZero() {
+		// An invalid time is stale
+		at, err := time.Parse(time.RFC3339, annotated)
+		if remaining := at.Add(r.gracePeriod).Sub(r.clock.Now()); err == nil && remaining > 0 {
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+		log.V(1).Info("Keeping the Workload annotated for cleanup, its PipelineRun wasn't deleted",
+			"pipelineRun", ref.Name)
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Deleting the Workload of the deleted PipelineRun", "pipelineRun", ref.Name)
+	if err := r.client.Delete(ctx, wl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
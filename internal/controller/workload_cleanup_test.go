@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+func TestWorkloadCleanupReconciler_Reconcile(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		plr           func() *tekv1.PipelineRun
+		annotated     string
+		expectDeleted bool
+		expectRequeue time.Duration
+	}{
+		{
+			name:          "the PipelineRun is deleted",
+			annotated:     now.Format(time.RFC3339),
+			expectDeleted: true,
+		},
+		{
+			name: "the PipelineRun is being deleted",
+			plr: func() *tekv1.PipelineRun {
+				plr := newPendingPipelineRun("plr")
+				plr.Finalizers = []string{"chains.tekton.dev/pipelinerun"}
+				plr.DeletionTimestamp = &metav1.Time{Time: now}
+				return plr
+			},
+			annotated:     now.Format(time.RFC3339),
+			expectDeleted: true,
+		},
+		{
+			name: "the PipelineRun was replaced",
+			plr: func() *tekv1.PipelineRun {
+				plr := newPendingPipelineRun("plr")
+				plr.UID = "other-uid"
+				return plr
+			},
+			annotated:     now.Format(time.RFC3339),
+			expectDeleted: true,
+		},
+		{
+			name:          "the deletion of the PipelineRun isn't persisted yet",
+			plr:           func() *tekv1.PipelineRun { return newPendingPipelineRun("plr") },
+			annotated:     now.Add(-20 * time.Second).Format(time.RFC3339),
+			expectRequeue: 40 * time.Second,
+		},
+		{
+			name:      "the PipelineRun wasn't deleted past the grace period",
+			plr:       func() *tekv1.PipelineRun { return newPendingPipelineRun("plr") },
+			annotated: now.Add(-2 * time.Minute).Format(time.RFC3339),
+		},
+		{
+			name:      "the Workload isn't annotated",
+			annotated: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			wl := newWorkloadFor(newPendingPipelineRun("plr"), "lq", 0, now)
+			if tt.annotated != "" {
+				metav1.SetMetaDataAnnotation(&wl.ObjectMeta, common.CleanupAnnotation, tt.annotated)
+			}
+			objs := []client.Object{wl}
+			if tt.plr != nil {
+				objs = append(objs, tt.plr())
+			}
+			cl := fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(objs...).Build()
+
+			r := NewWorkloadCleanupReconciler(cl, clocktesting.NewFakePassiveClock(now))
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(wl)})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(Equal(tt.expectRequeue))
+
+			err = cl.Get(context.Background(), client.ObjectKeyFromObject(wl), &kueue.Workload{})
+			if tt.expectDeleted {
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
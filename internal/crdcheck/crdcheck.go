@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package crdcheck waits for the CRDs the controllers and the webhooks
+// depend on to be served by the API server, so they don't fail to start
+// with cryptic errors when the CRDs aren't installed yet.
+package crdcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+)
+
+// DefaultInterval is the interval between the checks of the CRDs.
+const DefaultInterval = 5 * time.Second
+
+// Waiter waits for the kinds of a set of CRDs to be served.
+type Waiter struct {
+	discovery discovery.DiscoveryInterface
+	kinds     []schema.GroupVersionKind
+	interval  time.Duration
+
+	mu sync.Mutex
+	// missing are the kinds missing at the last check, all of them before
+	// the first check
+	missing []schema.GroupVersionKind
+}
+
+// NewWaiter returns a waiter for the kinds, checked through the discovery
+// client every interval.
+func NewWaiter(client discovery.DiscoveryInterface, interval time.Duration, kinds ...schema.GroupVersionKind) *Waiter {
+	return &Waiter{discovery: client, kinds: kinds, interval: interval, missing: kinds}
+}
+
+// Wait checks the kinds until they're all served, the context is done or
+// the timeout expires. A zero timeout checks them once. The failures of the
+// discovery are retried. The error lists the missing kinds, or reports the
+// last failure of the discovery.
+func (w *Waiter) Wait(ctx context.Context, timeout time.Duration) error {
+	if timeout <= 0 {
+		missing, err := w.check()
+		if err != nil {
+			return err
+		}
+		return w.missingError(missing, 0)
+	}
+	var lastErr error
+	err := wait.PollUntilContextTimeout(ctx, w.interval, timeout, true, func(context.Context) (bool, error) {
+		missing, err := w.check()
+		lastErr = err
+		return err == nil && len(missing) == 0, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to check the CRDs for %s: %w", timeout, lastErr)
+	}
+	return w.missingError(w.Missing(), timeout)
+}
+
+// Missing returns the kinds missing at the last check.
+func (w *Waiter) Missing() []schema.GroupVersionKind {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.missing
+}
+
+// Check is the readiness check of the waiter, failing until the kinds are
+// all served.
+func (w *Waiter) Check(*http.Request) error {
+	if missing := w.Missing(); len(missing) > 0 {
+		return fmt.Errorf("waiting for the CRDs of %s", kindList(missing))
+	}
+	return nil
+}
+
+// check returns the kinds which aren't served, and records them.
+func (w *Waiter) check() ([]schema.GroupVersionKind, error) {
+	var missing []schema.GroupVersionKind
+	served := map[schema.GroupVersion]map[string]bool{}
+	for _, kind := range w.kinds {
+		gv := kind.GroupVersion()
+		kinds, ok := served[gv]
+		if !ok {
+			var err error
+			if kinds, err = w.servedKinds(gv); err != nil {
+				return nil, err
+			}
+			served[gv] = kinds
+		}
+		if !kinds[kind.Kind] {
+			missing = append(missing, kind)
+		}
+	}
+	w.mu.Lock()
+	w.missing = missing
+	w.mu.Unlock()
+	return missing, nil
+}
+
+// servedKinds returns the kinds served for the group version, none when
+// the group version isn't served.
+func (w *Waiter) servedKinds(gv schema.GroupVersion) (map[string]bool, error) {
+	resources, err := w.discovery.ServerResourcesForGroupVersion(gv.String())
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the resources of %s: %w", gv, err)
+	}
+	kinds := map[string]bool{}
+	for _, resource := range resources.APIResources {
+		kinds[resource.Kind] = true
+	}
+	return kinds, nil
+}
+
+// missingError returns the error reporting the kinds still missing after
+// the timeout, or nil.
+func (w *Waiter) missingError(missing []schema.GroupVersionKind, timeout time.Duration) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the CRDs of %s are still missing after %s: install them before starting, "+
+		"or wait longer for them", kindList(missing), timeout)
+}
+
+// kindList returns the comma-separated list of the kinds, e.g.
+// Workload.kueue.x-k8s.io/v1beta1.
+func kindList(kinds []schema.GroupVersionKind) string {
+	names := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		names = append(names, fmt.Sprintf("%s.%s/%s", kind.Kind, kind.Group, kind.Version))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crdcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	workload       = schema.GroupVersionKind{Group: "kueue.x-k8s.io", Version: "v1beta1", Kind: "Workload"}
+	resourceFlavor = schema.GroupVersionKind{Group: "kueue.x-k8s.io", Version: "v1beta1", Kind: "ResourceFlavor"}
+)
+
+// kueueResources is the discovery of the Kueue group version, serving the
+// kinds.
+func kueueResources(kinds ...string) *metav1.APIResourceList {
+	resources := &metav1.APIResourceList{GroupVersion: "kueue.x-k8s.io/v1beta1"}
+	for _, kind := range kinds {
+		resources.APIResources = append(resources.APIResources, metav1.APIResource{Kind: kind})
+	}
+	return resources
+}
+
+func newFakeDiscovery(resources ...*metav1.APIResourceList) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: resources}}
+}
+
+func TestWaiter_Present(t *testing.T) {
+	g := NewWithT(t)
+
+	waiter := NewWaiter(newFakeDiscovery(kueueResources("ClusterQueue", "ResourceFlavor", "Workload")),
+		time.Millisecond, workload, resourceFlavor)
+	g.Expect(waiter.Check(nil)).To(MatchError(ContainSubstring("waiting for the CRDs")))
+
+	g.Expect(waiter.Wait(context.Background(), time.Second)).To(Succeed())
+	g.Expect(waiter.Missing()).To(BeEmpty())
+	g.Expect(waiter.Check(nil)).To(Succeed())
+}
+
+func TestWaiter_MissingThenAppearing(t *testing.T) {
+	g := NewWithT(t)
+
+	client := newFakeDiscovery()
+	calls := 0
+	client.PrependReactor("get", "resource", func(clienttesting.Action) (bool, runtime.Object, error) {
+		calls++
+		switch calls {
+		case 2:
+			// Kueue is being installed, the ResourceFlavor CRD first
+			client.Resources = []*metav1.APIResourceList{kueueResources("ResourceFlavor")}
+		case 3:
+			client.Resources = []*metav1.APIResourceList{kueueResources("ResourceFlavor", "Workload")}
+		}
+		return false, nil, nil
+	})
+
+	waiter := NewWaiter(client, time.Millisecond, workload, resourceFlavor)
+	g.Expect(waiter.Wait(context.Background(), time.Minute)).To(Succeed())
+	g.Expect(calls).To(Equal(3))
+	g.Expect(waiter.Check(nil)).To(Succeed())
+}
+
+func TestWaiter_Timeout(t *testing.T) {
+	g := NewWithT(t)
+
+	waiter := NewWaiter(newFakeDiscovery(kueueResources("ResourceFlavor")), time.Millisecond, workload, resourceFlavor)
+	err := waiter.Wait(context.Background(), 20*time.Millisecond)
+	g.Expect(err).To(MatchError(ContainSubstring(
+		"the CRDs of Workload.kueue.x-k8s.io/v1beta1 are still missing after 20ms")))
+	g.Expect(waiter.Missing()).To(Equal([]schema.GroupVersionKind{workload}))
+	g.Expect(waiter.Check(nil)).To(MatchError(ContainSubstring("Workload.kueue.x-k8s.io/v1beta1")))
+
+	// Without a timeout, the CRDs are checked once
+	waiter = NewWaiter(newFakeDiscovery(), time.Millisecond, workload, resourceFlavor)
+	g.Expect(waiter.Wait(context.Background(), 0)).To(MatchError(ContainSubstring(
+		"Workload.kueue.x-k8s.io/v1beta1, ResourceFlavor.kueue.x-k8s.io/v1beta1 are still missing")))
+}
+
+func TestWaiter_DiscoveryError(t *testing.T) {
+	g := NewWithT(t)
+
+	client := newFakeDiscovery()
+	client.PrependReactor("get", "resource", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+	waiter := NewWaiter(client, time.Millisecond, workload)
+	g.Expect(waiter.Wait(context.Background(), 20*time.Millisecond)).To(MatchError(ContainSubstring("connection refused")))
+	g.Expect(waiter.Check(nil)).To(HaveOccurred())
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package features lists the optional features of tekton-kueue, which are
+// enabled through the configuration file, and the RBAC rules they require.
+package features
+
+import (
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// Component is a tekton-kueue deployment, running under its own
+// ServiceAccount.
+type Component string
+
+const (
+	ComponentController Component = "controller"
+	ComponentWebhook    Component = "webhook"
+)
+
+// Components lists the components in the order they're rendered.
+var Components = []Component{ComponentController, ComponentWebhook}
+
+// ServiceAccount returns the name of the ServiceAccount of the component,
+// without the kustomize name prefix.
+func (c Component) ServiceAccount() string {
+	if c == ComponentController {
+		return "controller-manager"
+	}
+	return string(c)
+}
+
+// Feature is an optional feature of tekton-kueue.
+type Feature struct {
+	// Name identifies the feature. It's the key of its RBAC rules in
+	// featureRules.
+	Name string
+	// Component is the deployment running the feature.
+	Component Component
+	// Enabled returns whether the configuration enables the feature.
+	Enabled func(cfg *config.Config) bool
+}
+
+// Registry lists the optional features. Adding a feature requires adding
+// its RBAC rules to featureRules, even if it requires none.
+var Registry = []Feature{
+	{
+		Name:      "queue-position",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.QueuePosition.Enabled
+		},
+	},
+	{
+		Name:      "queue-position-cluster-queue-status",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.QueuePosition.Enabled && cfg.Controller.QueuePosition.UseClusterQueueStatus
+		},
+	},
+	{
+		Name:      "orphaned-workload-annotate",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.OrphanedWorkloadPolicy == config.OrphanedWorkloadPolicyAnnotate
+		},
+	},
+	{
+		Name:      "resolved-requests",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.ResolvedRequests.Enabled
+		},
+	},
+	{
+		Name:      "wait-for-pods-ready",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.WaitForPodsReady.Enable
+		},
+	},
+	{
+		Name:      "pending-admission-checks",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.PendingAdmissionChecks.Enabled
+		},
+	},
+	{
+		Name:      "priority-bump",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.AllowPriorityBump
+		},
+	},
+	{
+		Name:      "taskrun-propagation",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return len(cfg.Controller.PropagateToTaskRuns) > 0
+		},
+	},
+	{
+		Name:      "preemption-requeue",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Controller.Preemption.Requeue
+		},
+	},
+	{
+		Name:      "controller-managed-namespaces",
+		Component: ComponentController,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.ManagedNamespaces != nil
+		},
+	},
+	{
+		Name:      "admission-uid",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Logging.RecordAdmissionUID
+		},
+	},
+	{
+		Name:      "webhook-namespace-selector",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.NamespaceSelector != nil
+		},
+	},
+	{
+		Name:      "webhook-managed-namespaces",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.ManagedNamespaces != nil
+		},
+	},
+	{
+		Name:      "webhook-rate-limit-exemptions",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.RateLimit.Enabled && cfg.Webhook.RateLimit.ExemptNamespaces != nil
+		},
+	},
+	{
+		Name:      "namespace-labels",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.CEL.ExposeNamespaceLabels
+		},
+	},
+	{
+		Name:      "deletion-protection",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.DeletionProtection != ""
+		},
+	},
+	{
+		Name:      "audit-annotation",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.AuditAnnotation
+		},
+	},
+	{
+		Name:      "decision-history",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.DecisionHistory.Enabled
+		},
+	},
+	{
+		Name:      "queue-name-validation",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.ValidateQueueName
+		},
+	},
+	{
+		Name:      "tenant-overrides",
+		Component: ComponentWebhook,
+		Enabled: func(cfg *config.Config) bool {
+			return cfg.Webhook.TenantOverrides.Enabled
+		},
+	},
+}
+
+// Enabled returns the features of the component enabled by cfg.
+func Enabled(cfg *config.Config, component Component) []Feature {
+	var enabled []Feature
+	for _, f := range Registry {
+		if f.Component == component && f.Enabled(cfg) {
+			enabled = append(enabled, f)
+		}
+	}
+	return enabled
+}
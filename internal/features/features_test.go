@@ -0,0 +1,62 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+func TestRegistry_RBACRules(t *testing.T) {
+	g := NewWithT(t)
+
+	registered := map[string]bool{}
+	for _, f := range Registry {
+		g.Expect(registered).NotTo(HaveKey(f.Name), "duplicate feature %q", f.Name)
+		registered[f.Name] = true
+		g.Expect(featureRules).To(HaveKey(f.Name), "feature %q has no RBAC entry in featureRules", f.Name)
+		g.Expect(Components).To(ContainElement(f.Component), "feature %q has an unknown component", f.Name)
+	}
+	for name := range featureRules {
+		g.Expect(registered).To(HaveKey(name), "featureRules has an entry for the unregistered feature %q", name)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	g := NewWithT(t)
+
+	names := func(features []Feature) []string {
+		var names []string
+		for _, f := range features {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+
+	cfg := &config.Config{}
+	g.Expect(Enabled(cfg, ComponentController)).To(BeEmpty())
+	g.Expect(Enabled(cfg, ComponentWebhook)).To(BeEmpty())
+
+	cfg.Controller.QueuePosition.Enabled = true
+	cfg.Controller.OrphanedWorkloadPolicy = config.OrphanedWorkloadPolicyAnnotate
+	cfg.Logging.RecordAdmissionUID = true
+	g.Expect(names(Enabled(cfg, ComponentController))).To(Equal([]string{"queue-position", "orphaned-workload-annotate"}))
+	g.Expect(names(Enabled(cfg, ComponentWebhook))).To(Equal([]string{"admission-uid"}))
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+const (
+	kueueGroup  = "kueue.x-k8s.io"
+	tektonGroup = "tekton.dev"
+)
+
+var metricsAuthRules = []rbacv1.PolicyRule{
+	rule("authentication.k8s.io", "tokenreviews", "create"),
+	rule("authorization.k8s.io", "subjectaccessreviews", "create"),
+}
+
+// BaseRules are the cluster-wide rules each component requires regardless
+// of the configuration. They match config/rbac, excluding the rules of the
+// optional features.
+var BaseRules = map[Component][]rbacv1.PolicyRule{
+	ComponentController: append([]rbacv1.PolicyRule{
+		rule("", "events", "create", "patch", "update", "watch"),
+		rule("", "namespaces", "list", "watch"),
+		rule(kueueGroup, "resourceflavors", "get", "list", "watch"),
+		rule(kueueGroup, "workloadpriorityclasses", "get", "list", "watch"),
+		rule(kueueGroup, "workloads", "create", "delete", "get", "list", "patch", "update", "watch"),
+		rule(kueueGroup, "workloads/finalizers", "update"),
+		rule(kueueGroup, "workloads/status", "get", "patch", "update"),
+		rule("scheduling.k8s.io", "priorityclasses", "get", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "list", "patch", "update", "watch"),
+		rule(tektonGroup, "pipelineruns/finalizers", "update"),
+	}, metricsAuthRules...),
+	ComponentWebhook: metricsAuthRules,
+}
+
+// featureRules holds the rules required by each feature of the Registry,
+// including the ones already granted by BaseRules.
+var featureRules = map[string][]rbacv1.PolicyRule{
+	"queue-position": {
+		rule(kueueGroup, "localqueues", "get", "list", "watch"),
+		rule(kueueGroup, "workloads", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "list", "patch", "watch"),
+	},
+	"queue-position-cluster-queue-status": {
+		rule(kueueGroup, "clusterqueues", "get", "list", "watch"),
+	},
+	"orphaned-workload-annotate": {
+		rule("", "events", "create", "patch"),
+		rule(kueueGroup, "workloads", "list"),
+		rule(tektonGroup, "pipelineruns", "list", "patch", "watch"),
+	},
+	"resolved-requests": {
+		rule(kueueGroup, "workloads", "get", "list", "patch", "watch"),
+	},
+	"wait-for-pods-ready": {
+		rule(kueueGroup, "workloads/status", "get", "patch", "update"),
+	},
+	"pending-admission-checks": {
+		rule(kueueGroup, "workloads", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "list", "patch", "watch"),
+	},
+	"priority-bump": {
+		rule(kueueGroup, "workloadpriorityclasses", "get", "list", "watch"),
+		rule(kueueGroup, "workloads", "list", "patch", "watch"),
+		rule("scheduling.k8s.io", "priorityclasses", "get", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "list", "watch"),
+	},
+	"taskrun-propagation": {
+		rule(tektonGroup, "pipelineruns", "list", "watch"),
+		rule(tektonGroup, "taskruns", "list", "patch", "watch"),
+	},
+	"preemption-requeue": {
+		rule(kueueGroup, "workloads", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "create", "list", "patch", "watch"),
+	},
+	"controller-managed-namespaces": {
+		rule("", "namespaces", "list", "watch"),
+	},
+	"admission-uid": {},
+	"webhook-namespace-selector": {
+		rule("", "namespaces", "list"),
+		rule("admissionregistration.k8s.io", "mutatingwebhookconfigurations", "get", "list", "patch", "watch"),
+	},
+	"webhook-managed-namespaces": {
+		rule("", "namespaces", "list", "watch"),
+	},
+	"webhook-rate-limit-exemptions": {
+		rule("", "namespaces", "list", "watch"),
+	},
+	"namespace-labels": {
+		rule("", "namespaces", "list", "watch"),
+	},
+	"deletion-protection": {
+		rule(kueueGroup, "workloads", "list", "patch", "watch"),
+	},
+	"audit-annotation": {},
+	"decision-history": {},
+	"queue-name-validation": {
+		rule(kueueGroup, "localqueues", "get", "list", "watch"),
+	},
+	"tenant-overrides": {
+		rule("", "configmaps", "get", "list", "watch"),
+	},
+}
+
+func rule(group, resource string, verbs ...string) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{APIGroups: []string{group}, Resources: []string{resource}, Verbs: verbs}
+}
+
+// Permission is a verb allowed on a resource.
+type Permission struct {
+	Group    string
+	Resource string
+	Verb     string
+}
+
+// String returns the permission in the format "verb group/resource", e.g.
+// "list kueue.x-k8s.io/localqueues".
+func (p Permission) String() string {
+	if p.Group == "" {
+		return p.Verb + " " + p.Resource
+	}
+	return p.Verb + " " + p.Group + "/" + p.Resource
+}
+
+// permissions expands the rules into the permissions they list. Rules on
+// non-resource URLs are ignored.
+func permissions(rules []rbacv1.PolicyRule) []Permission {
+	var perms []Permission
+	for _, r := range rules {
+		for _, group := range r.APIGroups {
+			for _, resource := range r.Resources {
+				for _, verb := range r.Verbs {
+					p := Permission{Group: group, Resource: resource, Verb: verb}
+					if !slices.Contains(perms, p) {
+						perms = append(perms, p)
+					}
+				}
+			}
+		}
+	}
+	return perms
+}
+
+// grants returns whether the rules allow p. Rules restricted to some
+// resource names don't grant permissions on the whole resource.
+func grants(rules []rbacv1.PolicyRule, p Permission) bool {
+	matches := func(values []string, value string) bool {
+		return slices.Contains(values, rbacv1.ResourceAll) || slices.Contains(values, value)
+	}
+	for _, r := range rules {
+		if len(r.ResourceNames) == 0 &&
+			matches(r.APIGroups, p.Group) &&
+			matches(r.Resources, p.Resource) &&
+			matches(r.Verbs, p.Verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtraRules returns the rules required by the features of the component
+// enabled by cfg, which aren't granted by the BaseRules.
+func ExtraRules(cfg *config.Config, component Component) []rbacv1.PolicyRule {
+	var perms []Permission
+	for _, f := range Enabled(cfg, component) {
+		for _, p := range permissions(featureRules[f.Name]) {
+			if !grants(BaseRules[component], p) {
+				perms = append(perms, p)
+			}
+		}
+	}
+	return compact(perms)
+}
+
+// RequiredRules returns all the cluster-wide rules required by the
+// component with the configuration cfg.
+func RequiredRules(cfg *config.Config, component Component) []rbacv1.PolicyRule {
+	return append(slices.Clone(BaseRules[component]), ExtraRules(cfg, component)...)
+}
+
+// compact builds the rules allowing perms, with one rule per group and set
+// of verbs, as generated by controller-gen.
+func compact(perms []Permission) []rbacv1.PolicyRule {
+	type groupResource struct{ group, resource string }
+	verbs := map[groupResource][]string{}
+	var keys []groupResource
+	for _, p := range perms {
+		key := groupResource{p.Group, p.Resource}
+		if _, ok := verbs[key]; !ok {
+			keys = append(keys, key)
+		}
+		if !slices.Contains(verbs[key], p.Verb) {
+			verbs[key] = append(verbs[key], p.Verb)
+		}
+	}
+	slices.SortFunc(keys, func(a, b groupResource) int {
+		if c := strings.Compare(a.group, b.group); c != 0 {
+			return c
+		}
+		return strings.Compare(a.resource, b.resource)
+	})
+
+	var rules []rbacv1.PolicyRule
+	for _, key := range keys {
+		slices.Sort(verbs[key])
+		i := slices.IndexFunc(rules, func(r rbacv1.PolicyRule) bool {
+			return r.APIGroups[0] == key.group && slices.Equal(r.Verbs, verbs[key])
+		})
+		if i >= 0 {
+			rules[i].Resources = append(rules[i].Resources, key.resource)
+			continue
+		}
+		rules = append(rules, rule(key.group, key.resource, verbs[key]...))
+	}
+	return rules
+}
+
+// Diff compares the required rules with the live ones, and returns the
+// required permissions which aren't granted, and the granted permissions
+// which aren't required.
+func Diff(required, live []rbacv1.PolicyRule) (missing, excess []Permission) {
+	for _, p := range permissions(required) {
+		if !grants(live, p) {
+			missing = append(missing, p)
+		}
+	}
+	for _, p := range permissions(live) {
+		if !grants(required, p) {
+			excess = append(excess, p)
+		}
+	}
+	return missing, excess
+}
+
+// RenderOptions configures the names of the rendered objects.
+type RenderOptions struct {
+	// NamePrefix is the kustomize name prefix of the deployment, e.g.
+	// "tekton-kueue-".
+	NamePrefix string
+	// Namespace is the namespace of the ServiceAccounts.
+	Namespace string
+}
+
+// ClusterRoleName returns the name of the ClusterRole holding the extra
+// rules of the component.
+func (o RenderOptions) ClusterRoleName(component Component) string {
+	return o.NamePrefix + string(component) + "-optional-features"
+}
+
+// Render writes, for each component, a ClusterRole with the ExtraRules of
+// the features enabled by cfg and a ClusterRoleBinding to the component's
+// ServiceAccount.
+func Render(w io.Writer, cfg *config.Config, opts RenderOptions) error {
+	rendered := false
+	for _, component := range Components {
+		enabled := Enabled(cfg, component)
+		if len(enabled) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(enabled))
+		for _, f := range enabled {
+			names = append(names, f.Name)
+		}
+		if _, err := fmt.Fprintf(w, "# Enabled %s features: %s\n", component, strings.Join(names, ", ")); err != nil {
+			return err
+		}
+
+		rules := ExtraRules(cfg, component)
+		if len(rules) == 0 {
+			continue
+		}
+		role := &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.ClusterRoleName(component)},
+			Rules:      rules,
+		}
+		binding := &rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: opts.ClusterRoleName(component)},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     role.Name,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      opts.NamePrefix + component.ServiceAccount(),
+				Namespace: opts.Namespace,
+			}},
+		}
+		for _, obj := range []runtime.Object{role, binding} {
+			if err := writeObject(w, obj); err != nil {
+				return err
+			}
+		}
+		rendered = true
+	}
+	if !rendered {
+		_, err := fmt.Fprintln(w, "# No rules are required beyond the base set")
+		return err
+	}
+	return nil
+}
+
+// writeObject writes obj as a YAML document, omitting the empty
+// creationTimestamp.
+func writeObject(w io.Writer, obj runtime.Object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", data)
+	return err
+}
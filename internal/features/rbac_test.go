@@ -0,0 +1,166 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestRender_Golden(t *testing.T) {
+	configs, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) == 0 {
+		t.Fatal("no test configurations found")
+	}
+
+	for _, path := range configs {
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			data, err := os.ReadFile(path)
+			g.Expect(err).NotTo(HaveOccurred())
+			cfg := &config.Config{}
+			g.Expect(yaml.Unmarshal(data, cfg)).To(Succeed())
+
+			var out bytes.Buffer
+			g.Expect(Render(&out, cfg, RenderOptions{NamePrefix: "tekton-kueue-", Namespace: "tekton-kueue"})).To(Succeed())
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				g.Expect(os.WriteFile(golden, out.Bytes(), 0o644)).To(Succeed())
+			}
+			expected, err := os.ReadFile(golden)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(out.String()).To(Equal(string(expected)))
+		})
+	}
+}
+
+func TestExtraRules(t *testing.T) {
+	g := NewWithT(t)
+
+	cfg := &config.Config{}
+	cfg.Controller.QueuePosition.Enabled = true
+	g.Expect(ExtraRules(cfg, ComponentController)).To(Equal([]rbacv1.PolicyRule{
+		rule(kueueGroup, "localqueues", "get", "list", "watch"),
+	}))
+	g.Expect(ExtraRules(cfg, ComponentWebhook)).To(BeEmpty())
+	g.Expect(RequiredRules(cfg, ComponentController)).To(HaveLen(len(BaseRules[ComponentController]) + 1))
+}
+
+func TestCompact(t *testing.T) {
+	g := NewWithT(t)
+
+	rules := compact([]Permission{
+		{Group: tektonGroup, Resource: "pipelineruns", Verb: "watch"},
+		{Group: kueueGroup, Resource: "workloads", Verb: "list"},
+		{Group: tektonGroup, Resource: "pipelineruns", Verb: "list"},
+		{Group: kueueGroup, Resource: "localqueues", Verb: "list"},
+		{Group: kueueGroup, Resource: "workloads", Verb: "list"},
+		{Group: "", Resource: "events", Verb: "create"},
+	})
+	g.Expect(rules).To(Equal([]rbacv1.PolicyRule{
+		rule("", "events", "create"),
+		{APIGroups: []string{kueueGroup}, Resources: []string{"localqueues", "workloads"}, Verbs: []string{"list"}},
+		rule(tektonGroup, "pipelineruns", "list", "watch"),
+	}))
+}
+
+func TestDiff(t *testing.T) {
+	required := []rbacv1.PolicyRule{
+		rule(kueueGroup, "localqueues", "get", "list", "watch"),
+		rule(tektonGroup, "pipelineruns", "list", "patch"),
+	}
+
+	tests := []struct {
+		name            string
+		live            []rbacv1.PolicyRule
+		expectedMissing []Permission
+		expectedExcess  []Permission
+	}{
+		{
+			name: "identical",
+			live: required,
+		},
+		{
+			name: "missing and excess permissions",
+			live: []rbacv1.PolicyRule{
+				rule(kueueGroup, "localqueues", "get", "list"),
+				rule(tektonGroup, "pipelineruns", "delete", "list", "patch"),
+			},
+			expectedMissing: []Permission{{Group: kueueGroup, Resource: "localqueues", Verb: "watch"}},
+			expectedExcess:  []Permission{{Group: tektonGroup, Resource: "pipelineruns", Verb: "delete"}},
+		},
+		{
+			name: "wildcards grant the permissions and are excess",
+			live: []rbacv1.PolicyRule{
+				{APIGroups: []string{kueueGroup}, Resources: []string{"*"}, Verbs: []string{"get", "list", "watch"}},
+				{APIGroups: []string{tektonGroup}, Resources: []string{"pipelineruns"}, Verbs: []string{"*"}},
+			},
+			expectedExcess: []Permission{
+				{Group: kueueGroup, Resource: "*", Verb: "get"},
+				{Group: kueueGroup, Resource: "*", Verb: "list"},
+				{Group: kueueGroup, Resource: "*", Verb: "watch"},
+				{Group: tektonGroup, Resource: "pipelineruns", Verb: "*"},
+			},
+		},
+		{
+			name: "rules restricted to resource names don't grant permissions",
+			live: []rbacv1.PolicyRule{
+				required[0],
+				{APIGroups: []string{tektonGroup}, Resources: []string{"pipelineruns"}, ResourceNames: []string{"x"}, Verbs: []string{"list", "patch"}},
+			},
+			expectedMissing: []Permission{
+				{Group: tektonGroup, Resource: "pipelineruns", Verb: "list"},
+				{Group: tektonGroup, Resource: "pipelineruns", Verb: "patch"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			missing, excess := Diff(required, tt.live)
+			g.Expect(missing).To(Equal(tt.expectedMissing))
+			g.Expect(excess).To(Equal(tt.expectedExcess))
+		})
+	}
+}
+
+func TestPermission_String(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Permission{Group: kueueGroup, Resource: "localqueues", Verb: "list"}.String()).To(Equal("list kueue.x-k8s.io/localqueues"))
+	g.Expect(Permission{Resource: "events", Verb: "create"}.String()).To(Equal("create events"))
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsondiff compares objects by their JSON representation, and
+// reports the changes as JSON pointers (RFC 6901).
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Diff returns the sorted JSON pointers of the values which differ between
+// the JSON representations of before and after. The values added or
+// removed are reported by their own pointer, not by the pointers of their
+// fields, and so are the values whose type changed.
+func Diff(before, after any) ([]string, error) {
+	b, err := toJSONValue(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toJSONValue(after)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	diff("", b, a, &paths)
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// Outside returns the paths which are neither one of the allowed paths nor
+// under one of them.
+func Outside(paths, allowed []string) []string {
+	var outside []string
+	for _, path := range paths {
+		if !slices.ContainsFunc(allowed, func(prefix string) bool { return isUnder(path, prefix) }) {
+			outside = append(outside, path)
+		}
+	}
+	return outside
+}
+
+// Escape escapes a key into a JSON pointer reference token.
+func Escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func isUnder(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func toJSONValue(obj any) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func diff(path string, before, after any, paths *[]string) {
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			*paths = append(*paths, path)
+			return
+		}
+		for key, value := range b {
+			if other, exists := a[key]; exists {
+				diff(path+"/"+Escape(key), value, other, paths)
+			} else {
+				*paths = append(*paths, path+"/"+Escape(key))
+			}
+		}
+		for key := range a {
+			if _, exists := b[key]; !exists {
+				*paths = append(*paths, path+"/"+Escape(key))
+			}
+		}
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			*paths = append(*paths, path)
+			return
+		}
+		for i := range max(len(b), len(a)) {
+			itemPath := path + "/" + strconv.Itoa(i)
+			if i >= len(b) || i >= len(a) {
+				*paths = append(*paths, itemPath)
+				continue
+			}
+			diff(itemPath, b[i], a[i], paths)
+		}
+	default:
+		if !reflect.DeepEqual(before, after) {
+			*paths = append(*paths, path)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondiff
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   any
+		after    any
+		expected []string
+	}{
+		{
+			name:   "equal",
+			before: map[string]any{"a": []int{1, 2}, "b": "c"},
+			after:  map[string]any{"a": []int{1, 2}, "b": "c"},
+		},
+		{
+			name:     "changed, added and removed fields",
+			before:   map[string]any{"spec": map[string]any{"status": "", "removed": true}},
+			after:    map[string]any{"spec": map[string]any{"status": "PipelineRunPending", "added": 1}},
+			expected: []string{"/spec/added", "/spec/removed", "/spec/status"},
+		},
+		{
+			name:     "keys are escaped",
+			before:   map[string]any{"labels": map[string]string{}},
+			after:    map[string]any{"labels": map[string]string{"kueue.x-k8s.io/queue-name": "q", "a~b": "c"}},
+			expected: []string{"/labels/a~0b", "/labels/kueue.x-k8s.io~1queue-name"},
+		},
+		{
+			name:     "list items",
+			before:   map[string]any{"params": []string{"a", "b"}},
+			after:    map[string]any{"params": []string{"a", "c", "d"}},
+			expected: []string{"/params/1", "/params/2"},
+		},
+		{
+			name:     "changed type",
+			before:   map[string]any{"a": map[string]any{"b": 1}},
+			after:    map[string]any{"a": []int{1}},
+			expected: []string{"/a"},
+		},
+		{
+			name:     "whole document",
+			before:   "a",
+			after:    "b",
+			expected: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			paths, err := Diff(tt.before, tt.after)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(paths).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestDiff_UnmarshallableValue(t *testing.T) {
+	g := NewWithT(t)
+	_, err := Diff(map[string]any{"a": make(chan int)}, nil)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestOutside(t *testing.T) {
+	g := NewWithT(t)
+	paths := []string{
+		"/metadata/labels/team",
+		"/metadata/labelsExtra",
+		"/spec/status",
+		"/spec/timeouts/pipeline",
+		"/spec/taskRunTemplate/serviceAccountName",
+	}
+	allowed := []string{"/metadata/labels", "/spec/status", "/spec/timeouts"}
+	g.Expect(Outside(paths, allowed)).To(Equal([]string{
+		"/metadata/labelsExtra",
+		"/spec/taskRunTemplate/serviceAccountName",
+	}))
+	g.Expect(Outside(nil, allowed)).To(BeEmpty())
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mutators defines the mutators of the PipelineRuns applied by the
+// webhook, and the helpers composing them and faking them in tests.
+package mutators
+
+import (
+	"context"
+	"sync"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// PipelineRunMutator mutates the PipelineRuns admitted by the webhook.
+type PipelineRunMutator interface {
+	Mutate(context.Context, *tekv1.PipelineRun) error
+}
+
+// MutatorFunc adapts a function to the PipelineRunMutator interface.
+type MutatorFunc func(context.Context, *tekv1.PipelineRun) error
+
+// Mutate calls the function.
+func (f MutatorFunc) Mutate(ctx context.Context, plr *tekv1.PipelineRun) error {
+	return f(ctx, plr)
+}
+
+// chain applies its mutators in order.
+type chain []PipelineRunMutator
+
+// ChainMutators returns the mutator applying the mutators in order. It
+// stops at the first error, which is returned as is, so the next mutators
+// aren't applied.
+func ChainMutators(mutators ...PipelineRunMutator) PipelineRunMutator {
+	return chain(mutators)
+}
+
+func (c chain) Mutate(ctx context.Context, plr *tekv1.PipelineRun) error {
+	for _, mutator := range c {
+		if err := mutator.Mutate(ctx, plr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordingMutator records a copy of the PipelineRuns it's called with,
+// before they're mutated, for the assertions of the tests. It then applies
+// Mutator, when set. It's safe for concurrent use.
+type RecordingMutator struct {
+	// Mutator, when set, is applied once the PipelineRun is recorded.
+	Mutator PipelineRunMutator
+
+	mu    sync.Mutex
+	calls []*tekv1.PipelineRun
+}
+
+// Mutate records a copy of the PipelineRun and applies the mutator.
+func (m *RecordingMutator) Mutate(ctx context.Context, plr *tekv1.PipelineRun) error {
+	m.mu.Lock()
+	m.calls = append(m.calls, plr.DeepCopy())
+	m.mu.Unlock()
+	if m.Mutator == nil {
+		return nil
+	}
+	return m.Mutator.Mutate(ctx, plr)
+}
+
+// Calls returns the recorded PipelineRuns, in call order.
+func (m *RecordingMutator) Calls() []*tekv1.PipelineRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*tekv1.PipelineRun(nil), m.calls...)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mutators
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// appendLabel appends the value to the order label.
+func appendLabel(value string) MutatorFunc {
+	return func(_ context.Context, plr *tekv1.PipelineRun) error {
+		if plr.Labels == nil {
+			plr.Labels = map[string]string{}
+		}
+		plr.Labels["order"] += value
+		return nil
+	}
+}
+
+func TestChainMutators(t *testing.T) {
+	g := NewWithT(t)
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+
+	g.Expect(ChainMutators(appendLabel("a"), appendLabel("b"), appendLabel("c")).Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("order", "abc"))
+
+	g.Expect(ChainMutators().Mutate(context.Background(), plr)).To(Succeed())
+	g.Expect(plr.Labels).To(HaveKeyWithValue("order", "abc"))
+}
+
+func TestChainMutators_FailFast(t *testing.T) {
+	g := NewWithT(t)
+	plr := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+	errFailed := errors.New("failed")
+	failing := MutatorFunc(func(context.Context, *tekv1.PipelineRun) error { return errFailed })
+	next := &RecordingMutator{}
+
+	err := ChainMutators(appendLabel("a"), failing, next).Mutate(context.Background(), plr)
+	g.Expect(err).To(BeIdenticalTo(errFailed))
+	g.Expect(plr.Labels).To(HaveKeyWithValue("order", "a"))
+	g.Expect(next.Calls()).To(BeEmpty())
+}
+
+func TestRecordingMutator(t *testing.T) {
+	g := NewWithT(t)
+	recorder := &RecordingMutator{Mutator: appendLabel("a")}
+	first := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "tenant"}}
+	second := &tekv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "tenant"}}
+
+	g.Expect(recorder.Mutate(context.Background(), first)).To(Succeed())
+	g.Expect(recorder.Mutate(context.Background(), second)).To(Succeed())
+	g.Expect(first.Labels).To(HaveKeyWithValue("order", "a"))
+
+	// The PipelineRuns are recorded before they're mutated
+	calls := recorder.Calls()
+	g.Expect(calls).To(HaveLen(2))
+	g.Expect(calls[0].Name).To(Equal("first"))
+	g.Expect(calls[0].Labels).To(BeEmpty())
+	g.Expect(calls[1].Name).To(Equal("second"))
+}
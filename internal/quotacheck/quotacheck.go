@@ -0,0 +1,230 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quotacheck compares the resources a configuration can request
+// with the resources for which Kueue manifests declare quotas.
+package quotacheck
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+// Quotas maps the names of the resources with a declared quota to the
+// names of the ClusterQueues declaring them.
+type Quotas map[string][]string
+
+// LoadQuotas reads the ClusterQueues of the YAML files of dir, which may
+// hold several documents. ResourceFlavors are accepted, they don't declare
+// quotas; documents of other kinds are ignored.
+func LoadQuotas(dir string) (Quotas, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no YAML files found in %s", dir)
+	}
+
+	quotas := Quotas{}
+	clusterQueues := 0
+	for _, path := range paths {
+		n, err := quotas.addFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		clusterQueues += n
+	}
+	if clusterQueues == 0 {
+		return nil, fmt.Errorf("no ClusterQueues found in %s", dir)
+	}
+	return quotas, nil
+}
+
+// addFile adds the quotas of the ClusterQueues of the file and returns
+// the number of ClusterQueues.
+func (q Quotas) addFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	clusterQueues := 0
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return clusterQueues, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		meta := metav1.TypeMeta{}
+		if err := yaml.Unmarshal(doc, &meta); err != nil {
+			return 0, err
+		}
+		if meta.Kind != "ClusterQueue" || !strings.HasPrefix(meta.APIVersion, kueue.GroupVersion.Group+"/") {
+			continue
+		}
+		cq := &kueue.ClusterQueue{}
+		if err := yaml.Unmarshal(doc, cq); err != nil {
+			return 0, fmt.Errorf("parsing ClusterQueue: %w", err)
+		}
+		q.addClusterQueue(cq)
+		clusterQueues++
+	}
+}
+
+func (q Quotas) addClusterQueue(cq *kueue.ClusterQueue) {
+	add := func(name string) {
+		if !slices.Contains(q[name], cq.Name) {
+			q[name] = append(q[name], cq.Name)
+		}
+	}
+	for _, group := range cq.Spec.ResourceGroups {
+		for _, name := range group.CoveredResources {
+			add(string(name))
+		}
+		for _, flavor := range group.Flavors {
+			for _, resource := range flavor.Resources {
+				add(string(resource.Name))
+			}
+		}
+	}
+}
+
+// DynamicKey is a call whose resource name can't be known before
+// admission.
+type DynamicKey struct {
+	// Group is the group of the expression of the call.
+	Group string
+	Call  string
+}
+
+// Producible lists the resources a configuration can request.
+type Producible struct {
+	// Names are the resources requested with a constant key.
+	Names []string
+	// Dynamic are the calls whose resource can't be verified.
+	Dynamic []DynamicKey
+}
+
+// ProducibleResources returns the resources the programs can request, and
+// countResource, which the controller adds to all the Workloads. prefixes
+// are the prefixes of the resource annotations the programs may set, and
+// rules the key normalization rules applied by the mutator, if any.
+func ProducibleResources(
+	programs []*cel.CompiledProgram,
+	countResource string,
+	prefixes []string,
+	rules []cel.KeyNormalizationRule,
+) Producible {
+	producible := Producible{Names: []string{countResource}}
+	for _, program := range programs {
+		produced := program.GetProducedResources(prefixes...)
+		for _, name := range produced.Names {
+			if len(rules) > 0 {
+				name = strings.TrimPrefix(cel.NormalizeResourceKey(cel.ResourceAnnotationPrefix+name, rules), cel.ResourceAnnotationPrefix)
+			}
+			if !slices.Contains(producible.Names, name) {
+				producible.Names = append(producible.Names, name)
+			}
+		}
+		for _, call := range produced.Dynamic {
+			producible.Dynamic = append(producible.Dynamic, DynamicKey{Group: program.GetGroup(), Call: call})
+		}
+	}
+	slices.Sort(producible.Names)
+	return producible
+}
+
+// Report is the result of the comparison of the producible resources with
+// the quotas.
+type Report struct {
+	// Uncovered are the resources the configuration can request which no
+	// ClusterQueue has a quota for. Workloads requesting them can't be
+	// admitted.
+	Uncovered []string
+	// Unused maps the resources with a quota the configuration never
+	// requests to the ClusterQueues declaring them.
+	Unused map[string][]string
+	// Dynamic are the calls whose resource can't be verified.
+	Dynamic []DynamicKey
+}
+
+// Check compares the producible resources with the quotas.
+func Check(producible Producible, quotas Quotas) *Report {
+	report := &Report{Unused: map[string][]string{}, Dynamic: producible.Dynamic}
+	for _, name := range producible.Names {
+		if _, ok := quotas[name]; !ok {
+			report.Uncovered = append(report.Uncovered, name)
+		}
+	}
+	for name, clusterQueues := range quotas {
+		if !slices.Contains(producible.Names, name) {
+			report.Unused[name] = slices.Sorted(slices.Values(clusterQueues))
+		}
+	}
+	return report
+}
+
+// HasErrors reports whether the configuration can request resources no
+// ClusterQueue covers.
+func (r *Report) HasErrors() bool {
+	return len(r.Uncovered) > 0
+}
+
+// Write writes the report in a human-readable format.
+func (r *Report) Write(w io.Writer) error {
+	var b strings.Builder
+	for _, name := range r.Uncovered {
+		fmt.Fprintf(&b, "error: resource %q can be requested but no ClusterQueue declares a quota for it\n", name)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Unused)) {
+		fmt.Fprintf(&b, "warning: resource %q has a quota in ClusterQueues %s but is never requested\n",
+			name, strings.Join(r.Unused[name], ", "))
+	}
+	for _, key := range r.Dynamic {
+		group := ""
+		if key.Group != cel.DefaultGroup {
+			group = fmt.Sprintf(" in group %q", key.Group)
+		}
+		fmt.Fprintf(&b, "unverifiable: %s%s requests a resource known only at admission\n", key.Call, group)
+	}
+	fmt.Fprintf(&b, "%d error(s), %d warning(s), %d unverifiable\n", len(r.Uncovered), len(r.Unused), len(r.Dynamic))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
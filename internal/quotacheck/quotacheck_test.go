@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quotacheck
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const countResource = "tekton.dev/pipelineruns"
+
+func TestCheck_Golden(t *testing.T) {
+	for _, name := range []string{"matched", "mismatched", "partially-dynamic"} {
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			dir := filepath.Join("testdata", name)
+
+			data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+			g.Expect(err).NotTo(HaveOccurred())
+			cfg := &config.Config{}
+			g.Expect(yaml.Unmarshal(data, cfg)).To(Succeed())
+			var expressions []string
+			for _, expression := range cfg.CEL.Expressions {
+				expressions = append(expressions, expression.Expression)
+			}
+			programs, err := cel.CompileCELPrograms(expressions)
+			g.Expect(err).NotTo(HaveOccurred())
+			var prefixes []string
+			if cfg.ResourceAnnotationPrefixMigration != nil {
+				prefixes = cfg.ResourceAnnotationPrefixMigration.ReadPrefixes()
+			}
+			var rules []cel.KeyNormalizationRule
+			if cfg.CEL.ResourceKeyNormalization {
+				rules, err = cel.ParseKeyNormalizationRules(cfg.CEL.ResourceKeyNormalizationRules)
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			quotas, err := LoadQuotas(filepath.Join(dir, "kueue"))
+			g.Expect(err).NotTo(HaveOccurred())
+			report := Check(ProducibleResources(programs, countResource, prefixes, rules), quotas)
+
+			var out bytes.Buffer
+			g.Expect(report.Write(&out)).To(Succeed())
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				g.Expect(os.WriteFile(golden, out.Bytes(), 0o644)).To(Succeed())
+			}
+			expected, err := os.ReadFile(golden)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(out.String()).To(Equal(string(expected)))
+		})
+	}
+}
+
+func TestLoadQuotas_NoClusterQueues(t *testing.T) {
+	g := NewWithT(t)
+
+	dir := t.TempDir()
+	g.Expect(os.WriteFile(filepath.Join(dir, "flavor.yaml"), []byte(
+		"apiVersion: kueue.x-k8s.io/v1beta1\nkind: ResourceFlavor\nmetadata:\n  name: default-flavor\n",
+	), 0o644)).To(Succeed())
+
+	_, err := LoadQuotas(dir)
+	g.Expect(err).To(MatchError(ContainSubstring("no ClusterQueues found")))
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// migrateAnnotations renames the annotations of the PipelineRun starting
+// with the From prefix of each migration, in order, so they start with its
+// To prefix. When the PipelineRun already has the renamed annotation, both
+// values must be integers and are summed. It returns the changes, sorted,
+// as annotations[key]=value for the renamed annotations and
+// annotations[key]- for the removed ones, or a BadRequest error when the
+// values can't be summed, leaving the PipelineRun unchanged.
+func migrateAnnotations(plr *tekv1.PipelineRun, migrations config.AnnotationMigrations) ([]string, error) {
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+	annotations := maps.Clone(plr.Annotations)
+	var changes []string
+	for _, migration := range migrations {
+		for _, key := range slices.Sorted(maps.Keys(annotations)) {
+			suffix, ok := strings.CutPrefix(key, migration.From)
+			if !ok {
+				continue
+			}
+			newKey := migration.To + suffix
+			value := annotations[key]
+			if existing, exists := annotations[newKey]; exists {
+				sum, err := sumIntegers(existing, value)
+				if err != nil {
+					return nil, k8serrors.NewBadRequest(fmt.Sprintf(
+						"unable to migrate the annotation %s to %s, which is already set: %s", key, newKey, err))
+				}
+				value = sum
+			}
+			delete(annotations, key)
+			annotations[newKey] = value
+			changes = append(changes, "annotations["+key+"]-", fmt.Sprintf("annotations[%s]=%s", newKey, value))
+		}
+	}
+	plr.Annotations = annotations
+	slices.Sort(changes)
+	return changes, nil
+}
+
+// sumIntegers returns the sum of the integer values a and b.
+func sumIntegers(a, b string) (string, error) {
+	x, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("the values %q and %q can't be summed, %q isn't an integer", a, b, a)
+	}
+	y, err := strconv.ParseInt(b, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("the values %q and %q can't be summed, %q isn't an integer", a, b, b)
+	}
+	return strconv.FormatInt(x+y, 10), nil
+}
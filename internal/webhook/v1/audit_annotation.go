@@ -0,0 +1,109 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/jsondiff"
+)
+
+// The types of the entries of the audit annotation.
+const (
+	AppliedMutationLabel      = "label"
+	AppliedMutationAnnotation = "annotation"
+	// AppliedMutationSpec is the type of the changes of the spec, whose key
+	// is the JSON pointer of the field under the spec, without the leading
+	// slash, e.g. "status" or "timeouts/pipeline".
+	AppliedMutationSpec = "spec"
+)
+
+// AppliedMutation is an entry of the audit annotation: a label, an
+// annotation or a field of the spec changed by the admission. The values
+// are left out to bound the size of the annotation.
+type AppliedMutation struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+}
+
+// appliedMutations returns the labels, annotations and spec fields which
+// differ between before and after, sorted by type and key. The audit
+// annotation itself is left out.
+func appliedMutations(before, after *tekv1.PipelineRun) ([]AppliedMutation, error) {
+	var mutations []AppliedMutation
+	for _, key := range changedKeys(before.Labels, after.Labels) {
+		mutations = append(mutations, AppliedMutation{Type: AppliedMutationLabel, Key: key})
+	}
+	for _, key := range changedKeys(before.Annotations, after.Annotations) {
+		if key != common.AppliedMutationsAnnotation {
+			mutations = append(mutations, AppliedMutation{Type: AppliedMutationAnnotation, Key: key})
+		}
+	}
+	paths, err := jsondiff.Diff(before.Spec, after.Spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		mutations = append(mutations, AppliedMutation{Type: AppliedMutationSpec, Key: strings.TrimPrefix(path, "/")})
+	}
+	return mutations, nil
+}
+
+// changedKeys returns the sorted keys added, changed or removed between
+// before and after.
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || previous != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// annotateAppliedMutations sets the audit annotation of after from its
+// changes since before, replacing the value set by the author of the
+// PipelineRun, if any.
+func annotateAppliedMutations(before, after *tekv1.PipelineRun) error {
+	mutations, err := appliedMutations(before, after)
+	if err != nil {
+		return err
+	}
+	if mutations == nil {
+		mutations = []AppliedMutation{}
+	}
+	value, err := json.Marshal(mutations)
+	if err != nil {
+		return err
+	}
+	if after.Annotations == nil {
+		after.Annotations = make(map[string]string)
+	}
+	after.Annotations[common.AppliedMutationsAnnotation] = string(value)
+	return nil
+}
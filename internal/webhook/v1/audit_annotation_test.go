@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Audit annotation", func() {
+	var (
+		cfg         *config.Config
+		celMutators []PipelineRunMutator
+		plr         *tektondevv1.PipelineRun
+	)
+
+	BeforeEach(func() {
+		cfg = &config.Config{QueueName: "pipelines-queue", Webhook: config.Webhook{AuditAnnotation: true}}
+		programs, err := cel.CompileCELPrograms([]string{
+			`[label("team", "a"), resource("linux-amd64", 1), timeout("pipeline", "2h")]`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		celMutators = []PipelineRunMutator{cel.NewCELMutator(programs)}
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{"team": "a"},
+				Annotations: map[string]string{
+					cel.ResourceAnnotationPrefix + "linux-amd64": "2",
+				},
+			},
+			Spec: tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+	})
+
+	appliedMutations := func(plr *tektondevv1.PipelineRun) []AppliedMutation {
+		GinkgoHelper()
+		Expect(plr.Annotations).To(HaveKey(common.AppliedMutationsAnnotation))
+		var mutations []AppliedMutation
+		Expect(json.Unmarshal([]byte(plr.Annotations[common.AppliedMutationsAnnotation]), &mutations)).To(Succeed())
+		return mutations
+	}
+
+	It("records the keys of the changes of the admission", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(cfg, celMutators)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+
+		// The label already set isn't recorded, and the values are left out
+		Expect(appliedMutations(plr)).To(Equal([]AppliedMutation{
+			{Type: AppliedMutationLabel, Key: common.QueueLabel},
+			{Type: AppliedMutationAnnotation, Key: cel.AnnotationAppliedResources},
+			{Type: AppliedMutationAnnotation, Key: cel.ResourceAnnotationPrefix + "linux-amd64"},
+			{Type: AppliedMutationSpec, Key: "status"},
+			{Type: AppliedMutationSpec, Key: "timeouts"},
+		}))
+		Expect(plr.Annotations[common.AppliedMutationsAnnotation]).To(Equal(
+			`[{"type":"label","key":"kueue.x-k8s.io/queue-name"},` +
+				`{"type":"annotation","key":"kueue.konflux-ci.dev/cel-resources-applied"},` +
+				`{"type":"annotation","key":"kueue.konflux-ci.dev/requests-linux-amd64"},` +
+				`{"type":"spec","key":"status"},{"type":"spec","key":"timeouts"}]`))
+		Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"linux-amd64", "3"))
+	})
+
+	It("replaces the value set by the author of the PipelineRun", func(ctx context.Context) {
+		plr.Labels[common.QueueLabel] = "pipelines-queue"
+		plr.Annotations[common.AppliedMutationsAnnotation] = `[{"type":"label","key":"forged"}]`
+		mutator := cel.NewCELMutator(mustCompile(`[resource("linux-amd64", 1), timeout("pipeline", "2h")]`))
+		recorder := &mutators.RecordingMutator{Mutator: setMetadata(nil, map[string]string{"seen": "true"})}
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{mutator, recorder})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+
+		// The mutators don't see the previous value, and the resource
+		// requests are only summed with the resource annotations
+		Expect(recorder.Calls()).To(HaveLen(1))
+		Expect(recorder.Calls()[0].Annotations).NotTo(HaveKey(common.AppliedMutationsAnnotation))
+		Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"linux-amd64", "3"))
+		Expect(appliedMutations(plr)).NotTo(ContainElement(HaveField("Key", "forged")))
+		Expect(appliedMutations(plr)).NotTo(ContainElement(HaveField("Key", common.AppliedMutationsAnnotation)))
+		Expect(appliedMutations(plr)).To(ContainElement(AppliedMutation{Type: AppliedMutationAnnotation, Key: "seen"}))
+	})
+
+	It("doesn't record the mutations by default", func(ctx context.Context) {
+		cfg.Webhook.AuditAnnotation = false
+		defaulter, err := NewCustomDefaulter(cfg, celMutators)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Annotations).NotTo(HaveKey(common.AppliedMutationsAnnotation))
+	})
+
+	It("explains the recorded mutations", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(cfg, celMutators)
+		Expect(err).NotTo(HaveOccurred())
+		explanation := defaulter.(Explainer).Explain(ctx, "tenant", plr)
+		audit := explanation.Steps[len(explanation.Steps)-1]
+		Expect(audit.Name).To(Equal(StepAuditAnnotation))
+		Expect(audit.Changes).To(ConsistOf(ContainSubstring(`{"type":"spec","key":"timeouts"}`)))
+		Expect(plr.Annotations).NotTo(HaveKey(common.AppliedMutationsAnnotation))
+	})
+})
+
+func mustCompile(expressions ...string) []*cel.CompiledProgram {
+	GinkgoHelper()
+	programs, err := cel.CompileCELPrograms(expressions)
+	Expect(err).NotTo(HaveOccurred())
+	return programs
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"sync/atomic"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// The configurations serving the admissions.
+const (
+	ConfigStable = "stable"
+	ConfigCanary = "canary"
+)
+
+// DefaultCanaryReloadInterval is the interval at which the canary
+// percentage is read again from the configuration file.
+const DefaultCanaryReloadInterval = 10 * time.Second
+
+// Bucket assigns the key to one of the buckets, from 0 to buckets-1. The
+// same key is always assigned to the same bucket.
+func Bucket(key string, buckets int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(buckets))
+}
+
+// CanaryMutator serves a percentage of the admissions with the mutator of
+// the canary configuration, and the others with the stable one. The
+// admissions are assigned by namespace and generateName, so the
+// PipelineRuns of a pipeline are consistently mutated by the same
+// configuration. Each PipelineRun is annotated with the configuration which
+// mutated it.
+type CanaryMutator struct {
+	stable  PipelineRunMutator
+	canary  PipelineRunMutator
+	percent atomic.Int32
+}
+
+// NewCanaryMutator returns a CanaryMutator serving percent percent of the
+// admissions with canary.
+func NewCanaryMutator(stable, canary PipelineRunMutator, percent int) *CanaryMutator {
+	m := &CanaryMutator{stable: stable, canary: canary}
+	m.SetPercent(percent)
+	return m
+}
+
+// SetPercent changes the percentage of the admissions served by the canary,
+// 0 serving all the admissions with the stable configuration.
+func (m *CanaryMutator) SetPercent(percent int) {
+	m.percent.Store(int32(percent))
+}
+
+// Percent returns the percentage of the admissions served by the canary.
+func (m *CanaryMutator) Percent() int {
+	return int(m.percent.Load())
+}
+
+// Select returns the configuration serving the admission of the PipelineRun
+// in the namespace.
+func (m *CanaryMutator) Select(namespace string, plr *tekv1.PipelineRun) string {
+	name := plr.GenerateName
+	if name == "" {
+		name = plr.Name
+	}
+	if Bucket(namespace+"/"+name, 100) < m.Percent() {
+		return ConfigCanary
+	}
+	return ConfigStable
+}
+
+// Mutate mutates the PipelineRun with the mutator of the selected
+// configuration, and counts the admission by configuration.
+func (m *CanaryMutator) Mutate(ctx context.Context, plr *tekv1.PipelineRun) error {
+	namespace := plr.Namespace
+	if req, err := admission.RequestFromContext(ctx); err == nil && namespace == "" {
+		namespace = req.Namespace
+	}
+	selected := m.Select(namespace, plr)
+	mutator := m.stable
+	if selected == ConfigCanary {
+		mutator = m.canary
+	}
+
+	if err := mutator.Mutate(ctx, plr); err != nil {
+		admissionsByConfigTotal.WithLabelValues(selected, "failure").Inc()
+		return err
+	}
+	admissionsByConfigTotal.WithLabelValues(selected, "success").Inc()
+	if plr.Annotations == nil {
+		plr.Annotations = make(map[string]string)
+	}
+	plr.Annotations[common.ConfigAnnotation] = selected
+	return nil
+}
+
+// CanaryPercentReloader reads the canary percentage from the configuration
+// file at regular intervals, so the canary can be aborted, or extended,
+// without restarting the webhook. The rest of the configuration isn't
+// reloaded.
+type CanaryPercentReloader struct {
+	path     string
+	interval time.Duration
+	mutator  *CanaryMutator
+}
+
+// NewCanaryPercentReloader returns a CanaryPercentReloader updating the
+// percentage of the mutator from the configuration file at path.
+func NewCanaryPercentReloader(path string, interval time.Duration, mutator *CanaryMutator) *CanaryPercentReloader {
+	return &CanaryPercentReloader{path: path, interval: interval, mutator: mutator}
+}
+
+// Start reloads the percentage until the context is cancelled.
+func (r *CanaryPercentReloader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// Reload reads the percentage from the configuration file. The current
+// percentage is kept when the file can't be read or is invalid.
+func (r *CanaryPercentReloader) Reload(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx).WithValues("path", r.path)
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "Unable to read the configuration, keeping the canary percentage",
+			"canaryPercent", r.mutator.Percent())
+		return
+	}
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		log.Error(err, "Unable to parse the configuration, keeping the canary percentage",
+			"canaryPercent", r.mutator.Percent())
+		return
+	}
+	if err := cfg.ValidateCanaryPercent(); err != nil {
+		log.Error(err, "Keeping the canary percentage", "canaryPercent", r.mutator.Percent())
+		return
+	}
+	if previous := r.mutator.Percent(); previous != cfg.CanaryPercent {
+		r.mutator.SetPercent(cfg.CanaryPercent)
+		log.Info("Changed the canary percentage", "previous", previous, "canaryPercent", cfg.CanaryPercent)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Canary configuration", func() {
+	// labelMutator sets the mutated-by label to value
+	labelMutator := func(value string) PipelineRunMutator {
+		return mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+			if plr.Labels == nil {
+				plr.Labels = map[string]string{}
+			}
+			plr.Labels["mutated-by"] = value
+			return nil
+		})
+	}
+	newPipelineRun := func(namespace, generateName string) *tektondevv1.PipelineRun {
+		return &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, GenerateName: generateName}}
+	}
+
+	It("assigns the keys to stable buckets", func() {
+		Expect(Bucket("tenant/build-", 100)).To(Equal(Bucket("tenant/build-", 100)))
+		seen := map[int]bool{}
+		for i := range 1000 {
+			bucket := Bucket(fmt.Sprintf("tenant-%d/build-", i), 100)
+			Expect(bucket).To(BeNumerically(">=", 0))
+			Expect(bucket).To(BeNumerically("<", 100))
+			seen[bucket] = true
+		}
+		// The keys are spread across the buckets
+		Expect(len(seen)).To(BeNumerically(">", 90))
+	})
+
+	It("assigns the keys to the same buckets on every platform", func() {
+		// The replicas of the webhook must assign the PipelineRuns to the
+		// same configuration, whatever their architecture
+		Expect(Bucket("tenant/build-", 100)).To(Equal(1))
+		Expect(Bucket("tenant/test-", 100)).To(Equal(33))
+		Expect(Bucket("production/release-", 100)).To(Equal(3))
+		Expect(Bucket("", 100)).To(Equal(61))
+	})
+
+	It("serves the percentage of the admissions with the canary, deterministically", func(ctx context.Context) {
+		m := NewCanaryMutator(labelMutator(ConfigStable), labelMutator(ConfigCanary), 10)
+		canaries := 0
+		for i := range 1000 {
+			plr := newPipelineRun("tenant", fmt.Sprintf("pipeline-%d-", i))
+			selected := m.Select("tenant", plr)
+			Expect(m.Select("tenant", plr.DeepCopy())).To(Equal(selected))
+
+			Expect(m.Mutate(ctx, plr)).To(Succeed())
+			Expect(plr.Labels).To(HaveKeyWithValue("mutated-by", selected))
+			Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigAnnotation, selected))
+			if selected == ConfigCanary {
+				canaries++
+			}
+		}
+		Expect(canaries).To(BeNumerically("~", 100, 40))
+
+		m.SetPercent(0)
+		Expect(m.Select("tenant", newPipelineRun("tenant", "pipeline-1-"))).To(Equal(ConfigStable))
+		m.SetPercent(100)
+		Expect(m.Select("tenant", newPipelineRun("tenant", "pipeline-1-"))).To(Equal(ConfigCanary))
+	})
+
+	It("selects by the namespace of the admission request and the name", func(ctx context.Context) {
+		m := NewCanaryMutator(labelMutator(ConfigStable), labelMutator(ConfigCanary), 50)
+		plr := &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "nightly"}}
+		expected := m.Select("tenant", plr)
+
+		ctx = admission.NewContextWithRequest(ctx, admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{Namespace: "tenant"},
+		})
+		Expect(m.Mutate(ctx, plr)).To(Succeed())
+		Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigAnnotation, expected))
+	})
+
+	It("counts the admissions by configuration and result", func(ctx context.Context) {
+		failing := mutators.MutatorFunc(func(context.Context, *tektondevv1.PipelineRun) error {
+			return errors.New("evaluation failed")
+		})
+		m := NewCanaryMutator(labelMutator(ConfigStable), failing, 100)
+		canaryFailures := testutil.ToFloat64(admissionsByConfigTotal.WithLabelValues(ConfigCanary, "failure"))
+		stableSuccesses := testutil.ToFloat64(admissionsByConfigTotal.WithLabelValues(ConfigStable, "success"))
+
+		plr := newPipelineRun("tenant", "build-")
+		Expect(m.Mutate(ctx, plr)).To(MatchError("evaluation failed"))
+		Expect(plr.Annotations).NotTo(HaveKey(common.ConfigAnnotation))
+		m.SetPercent(0)
+		Expect(m.Mutate(ctx, plr)).To(Succeed())
+
+		Expect(testutil.ToFloat64(admissionsByConfigTotal.WithLabelValues(ConfigCanary, "failure"))).
+			To(Equal(canaryFailures + 1))
+		Expect(testutil.ToFloat64(admissionsByConfigTotal.WithLabelValues(ConfigStable, "success"))).
+			To(Equal(stableSuccesses + 1))
+	})
+
+	Describe("CanaryPercentReloader", func() {
+		var (
+			path     string
+			m        *CanaryMutator
+			reloader *CanaryPercentReloader
+		)
+
+		write := func(content string) {
+			Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+			m = NewCanaryMutator(labelMutator(ConfigStable), labelMutator(ConfigCanary), 10)
+			reloader = NewCanaryPercentReloader(path, DefaultCanaryReloadInterval, m)
+		})
+
+		It("reloads the percentage", func(ctx context.Context) {
+			write("queueName: pipelines-queue\ncanaryPercent: 0\n")
+			reloader.Reload(ctx)
+			Expect(m.Percent()).To(Equal(0))
+
+			write("queueName: pipelines-queue\ncanaryPercent: 50\n")
+			reloader.Reload(ctx)
+			Expect(m.Percent()).To(Equal(50))
+		})
+
+		It("keeps the percentage when the configuration is invalid", func(ctx context.Context) {
+			reloader.Reload(ctx)
+			Expect(m.Percent()).To(Equal(10))
+
+			write("canaryPercent: [")
+			reloader.Reload(ctx)
+			Expect(m.Percent()).To(Equal(10))
+
+			write("canaryPercent: 150\n")
+			reloader.Reload(ctx)
+			Expect(m.Percent()).To(Equal(10))
+		})
+	})
+
+	Describe("ConfigHandler", func() {
+		serve := func(h *ConfigHandler, method string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(method, ConfigPath, nil))
+			return recorder
+		}
+
+		It("serves both configurations and the current percentage", func() {
+			stable := &config.Config{QueueName: "pipelines-queue", CanaryPercent: 10,
+				CEL: config.CEL{Expressions: config.UnnamedExpressions(`priority("stable")`)}}
+			canary := &config.Config{CEL: config.CEL{Expressions: config.UnnamedExpressions(`priority("canary")`)}}
+			m := NewCanaryMutator(labelMutator(ConfigStable), labelMutator(ConfigCanary), stable.CanaryPercent)
+			m.SetPercent(0)
+
+			recorder := serve(NewConfigHandler(stable, canary, m), http.MethodGet)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var response ConfigResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+			Expect(response).To(Equal(ConfigResponse{Stable: stable, Canary: canary, CanaryPercent: 0}))
+		})
+
+		It("serves the stable configuration alone without canary", func() {
+			stable := &config.Config{QueueName: "pipelines-queue"}
+			recorder := serve(NewConfigHandler(stable, nil, nil), http.MethodGet)
+			Expect(recorder.Body.String()).NotTo(ContainSubstring(`"canary"`))
+			Expect(serve(NewConfigHandler(stable, nil, nil), http.MethodPost).Code).
+				To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
@@ -0,0 +1,59 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+// CELReferencePath is the path of the endpoint serving the reference of the
+// functions and variables of the CEL expressions.
+const CELReferencePath = "/debug/cel-reference"
+
+// CELReferenceHandler serves the reference of the functions and variables of
+// the CEL expressions as JSON, or as Markdown when the format query
+// parameter is markdown.
+type CELReferenceHandler struct{}
+
+// ServeHTTP serves the reference in the format given by the format query
+// parameter.
+func (CELReferenceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "markdown" {
+		http.Error(w, "the format query parameter must be json or markdown", http.StatusBadRequest)
+		return
+	}
+	reference, err := cel.GetReference()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_ = reference.WriteMarkdown(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reference)
+}
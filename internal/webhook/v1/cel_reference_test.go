@@ -0,0 +1,60 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+var _ = Describe("CELReferenceHandler", func() {
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		CELReferenceHandler{}.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		return recorder
+	}
+
+	It("serves the reference as JSON by default", func() {
+		recorder := serve(http.MethodGet, CELReferencePath)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var reference cel.Reference
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &reference)).To(Succeed())
+		expected, err := cel.GetReference()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reference).To(Equal(expected))
+	})
+
+	It("serves the reference as Markdown", func() {
+		recorder := serve(http.MethodGet, CELReferencePath+"?format=markdown")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(HavePrefix("# CEL Reference"))
+		Expect(recorder.Body.String()).To(ContainSubstring("### `priority`"))
+	})
+
+	It("rejects unknown formats and methods", func() {
+		Expect(serve(http.MethodGet, CELReferencePath+"?format=html").Code).To(Equal(http.StatusBadRequest))
+		Expect(serve(http.MethodPost, CELReferencePath).Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
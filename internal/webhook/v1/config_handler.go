@@ -0,0 +1,67 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// ConfigPath is the path of the endpoint serving the configurations of the
+// webhook.
+const ConfigPath = "/debug/config"
+
+// ConfigResponse is the response of the configuration endpoint.
+type ConfigResponse struct {
+	Stable *config.Config `json:"stable"`
+	// Canary is the configuration of the canary file, only set when the
+	// file exists.
+	Canary *config.Config `json:"canary,omitempty"`
+	// CanaryPercent is the current percentage of the admissions served by
+	// the canary, which may have been reloaded since the webhook started.
+	CanaryPercent int `json:"canaryPercent"`
+}
+
+// ConfigHandler serves the configurations loaded by the webhook as JSON.
+type ConfigHandler struct {
+	stable *config.Config
+	canary *config.Config
+	// mutator, when set, holds the current canary percentage.
+	mutator *CanaryMutator
+}
+
+// NewConfigHandler returns a ConfigHandler serving the stable and canary
+// configurations. canary and mutator are nil when there's no canary.
+func NewConfigHandler(stable, canary *config.Config, mutator *CanaryMutator) *ConfigHandler {
+	return &ConfigHandler{stable: stable, canary: canary, mutator: mutator}
+}
+
+// ServeHTTP serves the configurations.
+func (h *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	response := ConfigResponse{Stable: h.stable, Canary: h.canary}
+	if h.mutator != nil {
+		response.CanaryPercent = h.mutator.Percent()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// configHashLength is the number of hexadecimal digits of the hashes of the
+// configurations.
+const configHashLength = 16
+
+// ConfigHash returns the hash of the stable and canary configurations,
+// canary being nil when there's none. The replicas of the webhook loading
+// the same configurations have the same hash, whatever the formatting of
+// the files, so the replicas serving stale configurations are told apart.
+func ConfigHash(stable, canary *config.Config) (string, error) {
+	data, err := json.Marshal(ConfigResponse{Stable: stable, Canary: canary})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:configHashLength], nil
+}
+
+// WithConfigHash records the hash of the configurations loaded by the
+// webhook, see ConfigHash, in the ConfigHashAnnotation of the mutated
+// PipelineRuns.
+func WithConfigHash(hash string) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.configHash = func() string { return hash }
+	}
+}
+
+// WithReloadableConfigHash is like WithConfigHash, the hash being the one
+// of the configuration of the mutator serving the admissions, which
+// changes once the CEL expressions are reloaded.
+func WithReloadableConfigHash(mutator *ReloadableMutator) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.configHash = mutator.ConfigHash
+	}
+}
+
+// RecordConfigHash sets the hash of the configurations loaded by the
+// webhook, exposed by the tekton_kueue_webhook_config_info metric.
+func RecordConfigHash(hash string) {
+	configInfo.Reset()
+	configInfo.WithLabelValues(hash).Set(1)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Config hash", func() {
+	parse := func(data string) *config.Config {
+		GinkgoHelper()
+		cfg := &config.Config{}
+		Expect(yaml.Unmarshal([]byte(data), cfg)).To(Succeed())
+		return cfg
+	}
+	hash := func(stable, canary *config.Config) string {
+		GinkgoHelper()
+		h, err := ConfigHash(stable, canary)
+		Expect(err).NotTo(HaveOccurred())
+		return h
+	}
+
+	It("only depends on the content of the configurations", func() {
+		stable := parse("queueName: pipelines-queue\ncel:\n  expressions:\n    - 'priority(\"high\")'\n")
+		reformatted := parse("cel: {expressions: ['priority(\"high\")']}\nqueueName: pipelines-queue\n")
+		Expect(hash(stable, nil)).To(HaveLen(16))
+		Expect(hash(stable, nil)).To(Equal(hash(reformatted, nil)))
+
+		changed := parse("queueName: pipelines-queue\ncel:\n  expressions:\n    - 'priority(\"low\")'\n")
+		Expect(hash(changed, nil)).NotTo(Equal(hash(stable, nil)))
+		Expect(hash(stable, changed)).NotTo(Equal(hash(stable, nil)))
+	})
+
+	It("is exposed by a metric", func() {
+		RecordConfigHash("0123456789abcdef")
+		RecordConfigHash("fedcba9876543210")
+		Expect(testutil.CollectAndCount(configInfo)).To(Equal(1))
+		Expect(testutil.ToFloat64(configInfo.WithLabelValues("fedcba9876543210"))).To(Equal(1.0))
+	})
+
+	Context("on the mutated PipelineRuns", func() {
+		var (
+			cfg *config.Config
+			plr *tektondevv1.PipelineRun
+		)
+
+		BeforeEach(func() {
+			cfg = &config.Config{QueueName: "pipelines-queue", Webhook: config.Webhook{AnnotateConfigHash: true}}
+			plr = &tektondevv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "default"},
+				Spec:       tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+			}
+		})
+
+		It("records the hash of the configuration", func(ctx context.Context) {
+			defaulter, err := NewCustomDefaulter(cfg, nil, WithConfigHash(hash(cfg, nil)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigHashAnnotation, hash(cfg, nil)))
+		})
+
+		It("replaces the value set by the author of the PipelineRun", func(ctx context.Context) {
+			plr.Annotations = map[string]string{common.ConfigHashAnnotation: "forged"}
+			defaulter, err := NewCustomDefaulter(cfg, nil, WithConfigHash("0123456789abcdef"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigHashAnnotation, "0123456789abcdef"))
+		})
+
+		It("doesn't record the hash by default", func(ctx context.Context) {
+			defaulter, err := NewCustomDefaulter(cfg, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).NotTo(HaveKey(common.ConfigHashAnnotation))
+		})
+
+		It("explains the recorded hash", func(ctx context.Context) {
+			defaulter, err := NewCustomDefaulter(cfg, nil, WithConfigHash("0123456789abcdef"))
+			Expect(err).NotTo(HaveOccurred())
+			explanation := defaulter.(Explainer).Explain(ctx, "default", plr)
+			Expect(explanation.Steps).To(ContainElement(SatisfyAll(
+				HaveField("Name", StepConfigHash),
+				HaveField("Applies", true),
+				HaveField("Changes", ConsistOf("annotations["+common.ConfigHashAnnotation+"]=0123456789abcdef")),
+			)))
+		})
+	})
+})
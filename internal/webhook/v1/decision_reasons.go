@@ -0,0 +1,94 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// DecisionReason explains the outcome of the admission of a PipelineRun,
+// e.g. why it wasn't queued. Each admission records one reason in the
+// tekton_kueue_webhook_decisions_total metric.
+type DecisionReason string
+
+const (
+	// DecisionReasonMutated is recorded for the PipelineRuns queued and
+	// mutated.
+	DecisionReasonMutated DecisionReason = "mutated"
+	// DecisionReasonBypassedLabel is recorded for the PipelineRuns admitted
+	// untouched because their labels don't match the manage selector.
+	DecisionReasonBypassedLabel DecisionReason = "bypassed-label"
+	// DecisionReasonUnmanagedNamespace is recorded for the PipelineRuns
+	// admitted untouched because their namespace isn't managed.
+	DecisionReasonUnmanagedNamespace DecisionReason = "unmanaged-namespace"
+	// DecisionReasonTooLarge is recorded for the PipelineRuns queued without
+	// evaluating the CEL expressions because of their size.
+	DecisionReasonTooLarge DecisionReason = "too-large"
+	// DecisionReasonRateLimited is recorded for the PipelineRuns rejected
+	// because their namespace exceeded its rate limit.
+	DecisionReasonRateLimited DecisionReason = "rate-limited"
+	// DecisionReasonError is recorded for the other rejected PipelineRuns.
+	DecisionReasonError DecisionReason = "error"
+)
+
+// WithDecisionAnnotation records the reason of the decision in the
+// AdmissionDecisionAnnotation of the PipelineRuns the webhook queues. The
+// PipelineRuns admitted untouched or rejected aren't annotated.
+func WithDecisionAnnotation() DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.annotateDecision = true
+	}
+}
+
+// decide returns the reason of the decision of an admission, from the
+// reason returned by defaultPipelineRun, whether the CEL evaluation was
+// skipped because of the size of the PipelineRun and the error of the
+// admission.
+func decide(reason DecisionReason, tooLarge bool, err error) DecisionReason {
+	switch {
+	case k8serrors.IsTooManyRequests(err):
+		return DecisionReasonRateLimited
+	case err != nil:
+		return DecisionReasonError
+	case reason == DecisionReasonMutated && tooLarge:
+		return DecisionReasonTooLarge
+	default:
+		return reason
+	}
+}
+
+// recordDecision counts the reason of the decision and, when enabled,
+// records it in the annotation of the queued PipelineRun.
+func (d *pipelineRunCustomDefaulter) recordDecision(plr *tekv1.PipelineRun, reason DecisionReason) {
+	recordDecisionReason(reason)
+	if !d.annotateDecision || (reason != DecisionReasonMutated && reason != DecisionReasonTooLarge) {
+		return
+	}
+	if plr.Annotations == nil {
+		plr.Annotations = make(map[string]string)
+	}
+	plr.Annotations[common.AdmissionDecisionAnnotation] = string(reason)
+}
+
+// recordDecisionReason increments the counter of the decisions with the
+// reason.
+func recordDecisionReason(reason DecisionReason) {
+	webhookDecisionsTotal.WithLabelValues(string(reason)).Inc()
+}
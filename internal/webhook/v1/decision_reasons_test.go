@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Decision reasons", func() {
+	newPipelineRun := func(tasks int) *tektondevv1.PipelineRun {
+		plr := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "build-", Namespace: "tenant"},
+			Spec:       tektondevv1.PipelineRunSpec{PipelineSpec: &tektondevv1.PipelineSpec{}},
+		}
+		for i := range tasks {
+			plr.Spec.PipelineSpec.Tasks = append(plr.Spec.PipelineSpec.Tasks, tektondevv1.PipelineTask{
+				Name:    fmt.Sprintf("task-%d", i),
+				TaskRef: &tektondevv1.TaskRef{Name: "build"},
+			})
+		}
+		return plr
+	}
+	labelMutator := cel.NewCELMutator(mustCompile(`label("team", "a")`), cel.WithMaxObjectBytes(logr.Discard(), 8*1024))
+	namespaces := func() *fake.ClientBuilder {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Labels: map[string]string{"konflux.ci/type": "user"}}},
+		)
+	}
+	synced := func() bool { return true }
+
+	DescribeTable("records the decision of each admission path",
+		func(ctx context.Context, plr *tektondevv1.PipelineRun, mutator PipelineRunMutator, opts []DefaulterOption,
+			expected DecisionReason, expectErr bool) {
+			cfg := &config.Config{QueueName: "pipelines-queue"}
+			defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{mutator},
+				append(opts, WithDecisionAnnotation())...)
+			Expect(err).NotTo(HaveOccurred())
+			counter := func(reason DecisionReason) float64 {
+				return testutil.ToFloat64(webhookDecisionsTotal.WithLabelValues(string(reason)))
+			}
+			before := map[DecisionReason]float64{}
+			for _, reason := range []DecisionReason{
+				DecisionReasonMutated, DecisionReasonBypassedLabel, DecisionReasonUnmanagedNamespace,
+				DecisionReasonTooLarge, DecisionReasonRateLimited, DecisionReasonError,
+			} {
+				before[reason] = counter(reason)
+			}
+
+			err = defaulter.Default(ctx, plr)
+			if expectErr {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			for reason, count := range before {
+				if reason == expected {
+					Expect(counter(reason)-count).To(Equal(1.0), string(reason))
+				} else {
+					Expect(counter(reason)-count).To(BeZero(), string(reason))
+				}
+			}
+			if expected == DecisionReasonMutated || expected == DecisionReasonTooLarge {
+				Expect(plr.Annotations).To(HaveKeyWithValue(common.AdmissionDecisionAnnotation, string(expected)))
+			} else {
+				Expect(plr.Annotations).NotTo(HaveKey(common.AdmissionDecisionAnnotation))
+			}
+		},
+		Entry("mutated", newPipelineRun(1), labelMutator, nil, DecisionReasonMutated, false),
+		Entry("too large", newPipelineRun(200), labelMutator, nil, DecisionReasonTooLarge, false),
+		Entry("bypassed by its labels", newPipelineRun(1), labelMutator,
+			[]DefaulterOption{WithManageSelector(NewManageSelector(labels.SelectorFromSet(labels.Set{"managed": "true"})))},
+			DecisionReasonBypassedLabel, false),
+		Entry("unmanaged namespace", newPipelineRun(1), labelMutator,
+			[]DefaulterOption{WithManagedNamespaces(namespaces().Build(),
+				labels.SelectorFromSet(labels.Set{"konflux.ci/type": "system"}), synced)},
+			DecisionReasonUnmanagedNamespace, false),
+		Entry("rate limited", newPipelineRun(1), labelMutator,
+			[]DefaulterOption{WithRateLimit(NewRateLimiter(1, 0, clocktesting.NewFakeClock(time.Now())),
+				namespaces().Build(), nil, synced)},
+			DecisionReasonRateLimited, true),
+		Entry("failed mutation", newPipelineRun(1),
+			mutators.MutatorFunc(func(context.Context, *tektondevv1.PipelineRun) error { return errors.New("evaluation failed") }),
+			nil, DecisionReasonError, true),
+		Entry("invalid spec", &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "tenant"}},
+			labelMutator, nil, DecisionReasonError, true),
+	)
+
+	It("doesn't annotate the PipelineRuns by default", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "pipelines-queue"}, []PipelineRunMutator{labelMutator})
+		Expect(err).NotTo(HaveOccurred())
+		plr := newPipelineRun(1)
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Labels).To(HaveKeyWithValue("team", "a"))
+		Expect(plr.Annotations).NotTo(HaveKey(common.AdmissionDecisionAnnotation))
+	})
+})
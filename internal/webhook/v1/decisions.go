@@ -0,0 +1,206 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// DecisionsPath is the path of the endpoint serving the decision history.
+const DecisionsPath = "/debug/decisions"
+
+// Error kinds of the admissions failing without an API status.
+const (
+	// ErrorKindEvaluationFailed is the error kind of the admissions failing
+	// because an expression failed, e.g. reading a missing field.
+	ErrorKindEvaluationFailed = "EvaluationFailed"
+	// ErrorKindInvalidMutation is the error kind of the admissions failing
+	// because an expression returned an invalid mutation, a bug of the
+	// configuration.
+	ErrorKindInvalidMutation = "InvalidMutation"
+	// ErrorKindMutationFailed is the error kind of the other failures, e.g.
+	// because a mutator failed.
+	ErrorKindMutationFailed = "MutationFailed"
+)
+
+// Decision is a compact record of an admission of a PipelineRun.
+type Decision struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Name         string    `json:"name,omitempty"`
+	GenerateName string    `json:"generateName,omitempty"`
+	// Priority is the priority class assigned to the PipelineRun.
+	Priority string `json:"priority,omitempty"`
+	// Queue is the LocalQueue assigned to the PipelineRun.
+	Queue string `json:"queue,omitempty"`
+	// Config is the configuration, stable or canary, which mutated the
+	// PipelineRun, when a canary configuration is deployed.
+	Config string `json:"config,omitempty"`
+	// Mutations is the number of labels and annotations added or changed
+	// by the webhook.
+	Mutations int `json:"mutations"`
+	// ErrorKind is set when the admission failed: the reason of the API
+	// status of the error, or one of the error kinds, e.g.
+	// ErrorKindMutationFailed.
+	ErrorKind string `json:"errorKind,omitempty"`
+}
+
+// DecisionsResponse is the response of the decision history endpoint.
+type DecisionsResponse struct {
+	Namespace string `json:"namespace"`
+	// Decisions are ordered from the oldest to the most recent.
+	Decisions []Decision `json:"decisions"`
+}
+
+// DecisionHistory keeps the last admission decisions of each namespace in
+// memory. It keeps at most perNamespace decisions for each namespace, and
+// maxDecisions across all the namespaces, evicting the oldest decisions
+// first.
+type DecisionHistory struct {
+	mu           sync.Mutex
+	perNamespace int
+	maxDecisions int
+	// namespaces holds the decisions of each namespace, from the oldest to
+	// the most recent. Namespaces without decisions are removed.
+	namespaces map[string][]Decision
+	size       int
+	now        func() time.Time
+}
+
+// NewDecisionHistory creates an empty DecisionHistory.
+func NewDecisionHistory(perNamespace, maxDecisions int) *DecisionHistory {
+	return &DecisionHistory{
+		perNamespace: perNamespace,
+		maxDecisions: maxDecisions,
+		namespaces:   map[string][]Decision{},
+		now:          time.Now,
+	}
+}
+
+// Record appends the decision made for the admission of the PipelineRun,
+// before is the PipelineRun as received by the webhook and err the error
+// of the admission, if any.
+func (h *DecisionHistory) Record(namespace string, before, after *tekv1.PipelineRun, err error) {
+	decision := Decision{
+		Timestamp:    h.now(),
+		Name:         after.Name,
+		GenerateName: after.GenerateName,
+		Priority:     after.Labels[kueueconstants.WorkloadPriorityClassLabel],
+		Queue:        after.Labels[common.QueueLabel],
+		Config:       after.Annotations[common.ConfigAnnotation],
+		Mutations:    countChanges(before.Labels, after.Labels) + countChanges(before.Annotations, after.Annotations),
+	}
+	if err != nil {
+		decision.ErrorKind = errorKind(err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	decisions := h.namespaces[namespace]
+	if len(decisions) >= h.perNamespace {
+		decisions = decisions[len(decisions)-h.perNamespace+1:]
+		h.size -= len(h.namespaces[namespace]) - len(decisions)
+	}
+	// Copy the decisions so the evicted ones can be garbage collected
+	h.namespaces[namespace] = append(append(make([]Decision, 0, len(decisions)+1), decisions...), decision)
+	h.size++
+	for h.size > h.maxDecisions {
+		h.evictOldest()
+	}
+}
+
+// evictOldest removes the oldest decision across all the namespaces.
+func (h *DecisionHistory) evictOldest() {
+	oldest := ""
+	for namespace, decisions := range h.namespaces {
+		if oldest == "" || decisions[0].Timestamp.Before(h.namespaces[oldest][0].Timestamp) {
+			oldest = namespace
+		}
+	}
+	if len(h.namespaces[oldest]) == 1 {
+		delete(h.namespaces, oldest)
+	} else {
+		h.namespaces[oldest] = h.namespaces[oldest][1:]
+	}
+	h.size--
+}
+
+// Decisions returns the decisions of the namespace, from the oldest to the
+// most recent.
+func (h *DecisionHistory) Decisions(namespace string) []Decision {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Decision{}, h.namespaces[namespace]...)
+}
+
+// ServeHTTP serves the decisions of the namespace given by the namespace
+// query parameter as JSON.
+func (h *DecisionHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		http.Error(w, "the namespace query parameter is required", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(DecisionsResponse{
+		Namespace: namespace,
+		Decisions: h.Decisions(namespace),
+	})
+}
+
+// countChanges returns the number of keys added to or changed in after.
+func countChanges(before, after map[string]string) int {
+	changes := 0
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || previous != value {
+			changes++
+		}
+	}
+	return changes
+}
+
+// errorKind returns the reason of the API status of err or, for the errors
+// without one, the error kind of the CEL failures, or
+// ErrorKindMutationFailed.
+func errorKind(err error) string {
+	if reason := k8serrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	var invalidMutation *cel.MutationValidationError
+	var evaluation *cel.EvaluationError
+	switch {
+	case errors.As(err, &invalidMutation):
+		return ErrorKindInvalidMutation
+	case errors.As(err, &evaluation):
+		return ErrorKindEvaluationFailed
+	}
+	return ErrorKindMutationFailed
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("DecisionHistory", func() {
+	var (
+		history *DecisionHistory
+		clock   time.Time
+	)
+
+	newHistory := func(perNamespace, maxDecisions int) *DecisionHistory {
+		h := NewDecisionHistory(perNamespace, maxDecisions)
+		h.now = func() time.Time {
+			clock = clock.Add(time.Second)
+			return clock
+		}
+		return h
+	}
+	record := func(namespace, name string) {
+		plr := &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		history.Record(namespace, plr, plr, nil)
+	}
+	names := func(namespace string) []string {
+		var names []string
+		for _, decision := range history.Decisions(namespace) {
+			names = append(names, decision.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		clock = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	It("evicts the oldest decisions of a namespace", func() {
+		history = newHistory(3, 100)
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			record("tenant-1", name)
+		}
+		record("tenant-2", "f")
+
+		Expect(names("tenant-1")).To(Equal([]string{"c", "d", "e"}))
+		Expect(names("tenant-2")).To(Equal([]string{"f"}))
+		Expect(history.size).To(Equal(4))
+	})
+
+	It("evicts the oldest decisions across namespaces above the global cap", func() {
+		history = newHistory(3, 4)
+		record("tenant-1", "a")
+		record("tenant-2", "b")
+		record("tenant-1", "c")
+		record("tenant-3", "d")
+		record("tenant-3", "e")
+		record("tenant-3", "f")
+
+		Expect(names("tenant-1")).To(Equal([]string{"c"}))
+		Expect(names("tenant-2")).To(BeEmpty())
+		Expect(names("tenant-3")).To(Equal([]string{"d", "e", "f"}))
+		Expect(history.namespaces).NotTo(HaveKey("tenant-2"))
+		Expect(history.size).To(Equal(4))
+	})
+
+	Describe("recorded by the defaulter", func() {
+		var (
+			mutatorErr error
+			handler    http.Handler
+		)
+
+		admit := func(ctx context.Context) error {
+			defaulter, err := NewCustomDefaulter(
+				&config.Config{QueueName: "test-queue"},
+				[]PipelineRunMutator{mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+					plr.Labels["kueue.x-k8s.io/priority-class"] = "tekton-kueue-default"
+					plr.Annotations = map[string]string{"owner": "team-a"}
+					return mutatorErr
+				})},
+				WithDecisionHistory(history),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			ctx = admission.NewContextWithRequest(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Namespace: "tenant-1"},
+			})
+			return defaulter.Default(ctx, &tektondevv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "build-"},
+				Spec: tektondevv1.PipelineRunSpec{
+					PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+				},
+			})
+		}
+		get := func(target string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			return rec
+		}
+
+		BeforeEach(func() {
+			history = newHistory(20, 100)
+			handler = history
+			mutatorErr = nil
+		})
+
+		It("serves the successful and failed admissions", func(ctx context.Context) {
+			Expect(admit(ctx)).To(Succeed())
+			mutatorErr = errors.New("evaluation failed")
+			Expect(admit(ctx)).NotTo(Succeed())
+
+			rec := get(DecisionsPath + "?namespace=tenant-1")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			response := DecisionsResponse{}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+			Expect(response.Namespace).To(Equal("tenant-1"))
+			Expect(response.Decisions).To(Equal([]Decision{
+				{
+					Timestamp:    time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC),
+					GenerateName: "build-",
+					Priority:     "tekton-kueue-default",
+					Queue:        "test-queue",
+					Mutations:    3,
+				},
+				{
+					Timestamp:    time.Date(2025, 1, 1, 0, 0, 2, 0, time.UTC),
+					GenerateName: "build-",
+					Priority:     "tekton-kueue-default",
+					Queue:        "test-queue",
+					Mutations:    3,
+					ErrorKind:    ErrorKindMutationFailed,
+				},
+			}))
+		})
+
+		It("records the invalid PipelineRuns", func(ctx context.Context) {
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "test-queue"}, nil, WithDecisionHistory(history))
+			Expect(err).NotTo(HaveOccurred())
+			plr := &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "tenant-1"}}
+			Expect(defaulter.Default(ctx, plr)).NotTo(Succeed())
+
+			Expect(history.Decisions("tenant-1")).To(ConsistOf(HaveField("ErrorKind", string(metav1.StatusReasonBadRequest))))
+		})
+
+		DescribeTable("records the kind of the CEL failures",
+			func(ctx context.Context, expression, kind string) {
+				programs, err := cel.CompileCELPrograms([]string{expression})
+				Expect(err).NotTo(HaveOccurred())
+				mutatorErr = cel.NewCELMutator(programs).Mutate(ctx, &tektondevv1.PipelineRun{
+					ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant-1"},
+				})
+				Expect(mutatorErr).To(HaveOccurred())
+				Expect(admit(ctx)).NotTo(Succeed())
+
+				Expect(history.Decisions("tenant-1")).To(ConsistOf(HaveField("ErrorKind", kind)))
+			},
+			Entry("evaluation failure",
+				`annotation("owner", pipelineRun.metadata.annotations["missing"])`, ErrorKindEvaluationFailed),
+			Entry("invalid mutation",
+				`{"type": "annotation", "key": "owner", "value": plrGenerateName}`, ErrorKindInvalidMutation),
+		)
+
+		It("serves an empty list for unknown namespaces", func() {
+			rec := get(DecisionsPath + "?namespace=unknown")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchJSON(`{"namespace": "unknown", "decisions": []}`))
+		})
+
+		It("requires the namespace", func() {
+			Expect(get(DecisionsPath).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,107 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// defaultResources adds the default resource requests of the priority class
+// of the PipelineRuns, see config.DefaultResources.
+type defaultResources struct {
+	resources config.DefaultResources
+	// readPrefixes are the prefixes of the resource annotations already
+	// requesting a resource, and writePrefixes the prefixes of the added
+	// annotations.
+	readPrefixes  []string
+	writePrefixes []string
+}
+
+// newDefaultResources returns the default resources of the configuration,
+// or nil when there are none, after checking that the resource names form
+// valid annotation keys with the resource annotation prefixes.
+func newDefaultResources(cfg *config.Config) (*defaultResources, error) {
+	if len(cfg.DefaultResources) == 0 {
+		return nil, nil
+	}
+	if err := cfg.DefaultResources.Validate(); err != nil {
+		return nil, err
+	}
+	d := &defaultResources{
+		resources:     cfg.DefaultResources,
+		readPrefixes:  []string{cel.ResourceAnnotationPrefix},
+		writePrefixes: []string{cel.ResourceAnnotationPrefix},
+	}
+	if migration := cfg.ResourceAnnotationPrefixMigration; migration != nil {
+		d.readPrefixes = migration.ReadPrefixes()
+		d.writePrefixes = migration.WritePrefixes()
+	}
+	for _, priorityClass := range slices.Sorted(maps.Keys(d.resources)) {
+		for name := range d.resources[priorityClass] {
+			for _, prefix := range d.writePrefixes {
+				if errs := validation.IsQualifiedName(prefix + name); len(errs) > 0 {
+					return nil, fmt.Errorf("defaultResources %s: the resource %q can't be requested with an annotation: %s",
+						priorityClass, name, strings.Join(errs, "; "))
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// apply adds the resource annotations of the default resources of the
+// priority class of the PipelineRun, unless it already requests the
+// resources with any of the read prefixes. It returns the added
+// annotations, sorted by key, as annotations[key]=value.
+func (d *defaultResources) apply(plr *tekv1.PipelineRun) []string {
+	resources := d.resources[plr.Labels[kueueconstants.WorkloadPriorityClassLabel]]
+	var added []string
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		if d.requests(plr, name) {
+			continue
+		}
+		if plr.Annotations == nil {
+			plr.Annotations = make(map[string]string)
+		}
+		for _, prefix := range d.writePrefixes {
+			plr.Annotations[prefix+name] = resources[name]
+			added = append(added, fmt.Sprintf("annotations[%s]=%s", prefix+name, resources[name]))
+		}
+	}
+	slices.Sort(added)
+	return added
+}
+
+// requests returns whether the PipelineRun requests the resource.
+func (d *defaultResources) requests(plr *tekv1.PipelineRun, name string) bool {
+	for _, prefix := range d.readPrefixes {
+		if _, ok := plr.Annotations[prefix+name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// +kubebuilder:webhook:path=/validate-tekton-dev-v1-pipelinerun,mutating=false,failurePolicy=ignore,sideEffects=NoneOnDryRun,groups=tekton.dev,resources=pipelineruns,verbs=delete,versions=v1,name=pipelinerun-deletion-protection.tekton-kueue.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups=kueue.x-k8s.io,resources=workloads,verbs=list;watch;patch
+
+// DeletionProtector handles the deletions of the pending PipelineRuns which
+// have a Workload, see config.DeletionProtectionPolicy. The deletions of
+// the other PipelineRuns are allowed.
+type DeletionProtector struct {
+	// client reads the Workloads, indexed by owner, and annotates them.
+	client client.Client
+	policy config.DeletionProtectionPolicy
+	clock  clock.PassiveClock
+}
+
+var _ webhook.CustomValidator = &DeletionProtector{}
+
+// NewDeletionProtector returns the validator applying the policy. The
+// Workloads are listed from client using the owner index of the Workloads
+// of the PipelineRuns, see jobframework.SetupWorkloadOwnerIndex. All the
+// deletions are allowed when the policy is empty.
+func NewDeletionProtector(c client.Client, policy config.DeletionProtectionPolicy, clk clock.PassiveClock) (*DeletionProtector, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &DeletionProtector{client: c, policy: policy, clock: clk}, nil
+}
+
+// ValidateCreate implements webhook.CustomValidator, the creations aren't
+// validated.
+func (p *DeletionProtector) ValidateCreate(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator, the updates aren't
+// validated.
+func (p *DeletionProtector) ValidateUpdate(context.Context, runtime.Object, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator. When the PipelineRun
+// is pending and has Workloads, it either denies the deletion, unless the
+// PipelineRun has the force-delete annotation, or annotates the Workloads
+// for cleanup before allowing it. The Workloads aren't annotated by the
+// dry-run deletions.
+func (p *DeletionProtector) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	plr, ok := obj.(*tekv1.PipelineRun)
+	if !ok {
+		return nil, k8serrors.NewBadRequest(fmt.Sprintf("expected a PipelineRun object but got %T", obj))
+	}
+	if p.policy == "" || plr.Spec.Status != tekv1.PipelineRunSpecStatusPending || plr.IsDone() {
+		return nil, nil
+	}
+	log := ctrl.LoggerFrom(ctx)
+
+	workloads, err := p.workloadsOf(ctx, plr)
+	if err != nil {
+		// The deletion isn't blocked by the failures of the webhook
+		log.Error(err, "Allowing the deletion, unable to list the Workloads of the PipelineRun")
+		return nil, nil
+	}
+	if len(workloads) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(workloads))
+	for _, wl := range workloads {
+		names = append(names, wl.Name)
+	}
+
+	switch p.policy {
+	case config.DeletionProtectionDeny:
+		if plr.Annotations[common.ForceDeleteAnnotation] == "true" {
+			log.Info("Allowing the forced deletion of the pending PipelineRun", "workloads", names)
+			return nil, nil
+		}
+		return nil, k8serrors.NewForbidden(tekv1.Resource("pipelineruns"), plr.Name, fmt.Errorf(
+			"the PipelineRun is still queued by the Workloads %s, cancel it with spec.status=Cancelled, "+
+				"or set the %s annotation to \"true\" to delete it anyway",
+			strings.Join(names, ", "), common.ForceDeleteAnnotation))
+	case config.DeletionProtectionAnnotate:
+		if req, err := admission.RequestFromContext(ctx); err == nil && req.DryRun != nil && *req.DryRun {
+			return nil, nil
+		}
+		now := p.clock.Now().UTC().Format(time.RFC3339)
+		for _, wl := range workloads {
+			patch := client.MergeFrom(wl.DeepCopy())
+			metav1.SetMetaDataAnnotation(&wl.ObjectMeta, common.CleanupAnnotation, now)
+			if err := p.client.Patch(ctx, wl, patch); err != nil && !k8serrors.IsNotFound(err) {
+				log.Error(err, "Unable to annotate the Workload of the deleted PipelineRun", "workload", wl.Name)
+			}
+		}
+		log.Info("Annotated the Workloads of the deleted pending PipelineRun for cleanup", "workloads", names)
+	}
+	return nil, nil
+}
+
+// workloadsOf returns the Workloads owned by the PipelineRun which aren't
+// being deleted.
+func (p *DeletionProtector) workloadsOf(ctx context.Context, plr *tekv1.PipelineRun) ([]*kueue.Workload, error) {
+	wls := &kueue.WorkloadList{}
+	if err := p.client.List(ctx, wls,
+		client.InNamespace(plr.Namespace),
+		client.MatchingFields{jobframework.GetOwnerKey(tekv1.SchemeGroupVersion.WithKind("PipelineRun")): plr.Name},
+	); err != nil {
+		return nil, fmt.Errorf("listing workloads: %w", err)
+	}
+	var workloads []*kueue.Workload
+	for i := range wls.Items {
+		wl := &wls.Items[i]
+		if !wl.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, ref := range wl.OwnerReferences {
+			if ref.UID == plr.UID {
+				workloads = append(workloads, wl)
+				break
+			}
+		}
+	}
+	return workloads, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Deletion protection", func() {
+	var (
+		cl    client.Client
+		plr   *tektondevv1.PipelineRun
+		wl    *kueue.Workload
+		clock *clocktesting.FakePassiveClock
+	)
+
+	plrGVK := tektondevv1.SchemeGroupVersion.WithKind("PipelineRun")
+	newClient := func(objs ...client.Object) client.Client {
+		scheme := runtime.NewScheme()
+		Expect(kueue.AddToScheme(scheme)).To(Succeed())
+		Expect(tektondevv1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithIndex(&kueue.Workload{}, jobframework.GetOwnerKey(plrGVK), func(obj client.Object) []string {
+				var owners []string
+				for _, ref := range obj.GetOwnerReferences() {
+					if ref.Kind == plrGVK.Kind && ref.APIVersion == plrGVK.GroupVersion().String() {
+						owners = append(owners, ref.Name)
+					}
+				}
+				return owners
+			}).
+			Build()
+	}
+	validateDelete := func(ctx context.Context, policy config.DeletionProtectionPolicy) error {
+		protector, err := NewDeletionProtector(cl, policy, clock)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = protector.ValidateDelete(ctx, plr)
+		return err
+	}
+	cleanupAnnotation := func(ctx context.Context) string {
+		updated := &kueue.Workload{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(wl), updated)).To(Succeed())
+		return updated.Annotations[common.CleanupAnnotation]
+	}
+
+	BeforeEach(func() {
+		clock = clocktesting.NewFakePassiveClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant", UID: types.UID("build-uid")},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "build"},
+				Status:      tektondevv1.PipelineRunSpecStatusPending,
+			},
+		}
+		wl = &kueue.Workload{ObjectMeta: metav1.ObjectMeta{
+			Name: "pipelinerun-build-1a2b3", Namespace: "tenant",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: plrGVK.GroupVersion().String(), Kind: plrGVK.Kind, Name: plr.Name, UID: plr.UID,
+				Controller: ptr.To(true),
+			}},
+		}}
+		// A Workload owned by a former PipelineRun with the same name
+		former := wl.DeepCopy()
+		former.Name = "pipelinerun-build-previous"
+		former.OwnerReferences[0].UID = "former-uid"
+		cl = newClient(wl, former)
+	})
+
+	It("allows all the deletions without policy", func(ctx context.Context) {
+		Expect(validateDelete(ctx, "")).To(Succeed())
+		Expect(cleanupAnnotation(ctx)).To(BeEmpty())
+	})
+
+	It("rejects the unknown policies", func() {
+		_, err := NewDeletionProtector(cl, "Block", clock)
+		Expect(err).To(MatchError(ContainSubstring(`invalid deletionProtection "Block"`)))
+	})
+
+	Context("denying the deletions", func() {
+		It("denies the deletion of the pending PipelineRuns with a Workload", func(ctx context.Context) {
+			err := validateDelete(ctx, config.DeletionProtectionDeny)
+			Expect(k8serrors.IsForbidden(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("queued by the Workloads pipelinerun-build-1a2b3,")))
+			Expect(err).To(MatchError(ContainSubstring(common.ForceDeleteAnnotation)))
+		})
+
+		It("allows the forced deletions", func(ctx context.Context) {
+			plr.Annotations = map[string]string{common.ForceDeleteAnnotation: "true"}
+			Expect(validateDelete(ctx, config.DeletionProtectionDeny)).To(Succeed())
+		})
+
+		It("allows the deletion of the running PipelineRuns", func(ctx context.Context) {
+			plr.Spec.Status = ""
+			Expect(validateDelete(ctx, config.DeletionProtectionDeny)).To(Succeed())
+		})
+
+		It("allows the deletion of the PipelineRuns without Workload", func(ctx context.Context) {
+			plr.UID = "new-uid"
+			Expect(validateDelete(ctx, config.DeletionProtectionDeny)).To(Succeed())
+		})
+	})
+
+	Context("annotating the Workloads", func() {
+		It("annotates the Workloads before allowing the deletion", func(ctx context.Context) {
+			Expect(validateDelete(ctx, config.DeletionProtectionAnnotate)).To(Succeed())
+			Expect(cleanupAnnotation(ctx)).To(Equal("2025-06-01T12:00:00Z"))
+
+			former := &kueue.Workload{}
+			Expect(cl.Get(ctx, client.ObjectKey{Namespace: "tenant", Name: "pipelinerun-build-previous"}, former)).To(Succeed())
+			Expect(former.Annotations).NotTo(HaveKey(common.CleanupAnnotation))
+		})
+
+		It("doesn't annotate the Workloads on dry-run", func(ctx context.Context) {
+			ctx = admission.NewContextWithRequest(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{DryRun: ptr.To(true)},
+			})
+			Expect(validateDelete(ctx, config.DeletionProtectionAnnotate)).To(Succeed())
+			Expect(cleanupAnnotation(ctx)).To(BeEmpty())
+		})
+
+		It("doesn't annotate the Workloads of the finished PipelineRuns", func(ctx context.Context) {
+			plr.Spec.Status = tektondevv1.PipelineRunSpecStatusCancelled
+			Expect(validateDelete(ctx, config.DeletionProtectionAnnotate)).To(Succeed())
+			Expect(cleanupAnnotation(ctx)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,587 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// ExplainPath is the path of the endpoint explaining the admission of the
+// PipelineRuns of a namespace.
+const ExplainPath = "/debug/explain"
+
+// The steps of the admission of a PipelineRun, in execution order.
+const (
+	StepManagedNamespaces   = "managedNamespaces"
+	StepManageSelector      = "manageSelector"
+	StepRateLimit           = "rateLimit"
+	StepValidation          = "validation"
+	StepPending             = "pending"
+	StepQueueName           = "queueName"
+	StepMultiKueueOverride  = "multiKueueOverride"
+	StepAdmissionUID        = "recordAdmissionUID"
+	StepAnnotationMigration = "annotationMigrations"
+	StepCanary              = "canary"
+	StepCEL                 = "cel"
+	StepDefaultResources    = "defaultResources"
+	StepQueueNameValidation = "validateQueueName"
+	StepConfigHash          = "annotateConfigHash"
+	StepAuditAnnotation     = "auditAnnotation"
+)
+
+// Explanation describes how the PipelineRuns of a namespace are admitted:
+// the steps of the admission, in execution order, and, when a sample
+// PipelineRun is given, the changes each step makes to it.
+type Explanation struct {
+	Namespace string `json:"namespace"`
+	// Managed tells whether the PipelineRuns of the namespace are queued,
+	// the other steps don't apply otherwise.
+	Managed bool          `json:"managed"`
+	Steps   []ExplainStep `json:"steps"`
+}
+
+// ExplainStep is a step of the admission of the PipelineRuns.
+type ExplainStep struct {
+	Name string `json:"name"`
+	// Config is the configuration of the CEL step, stable or canary, when
+	// a canary configuration is loaded.
+	Config string `json:"config,omitempty"`
+	// Applies tells whether the step applies to the PipelineRuns of the
+	// namespace, or to the sample PipelineRun.
+	Applies bool   `json:"applies"`
+	Reason  string `json:"reason"`
+	// Source is the setting, or the object of the cluster with its
+	// resourceVersion, the step depends on.
+	Source string `json:"source,omitempty"`
+	// Changes are the changes made to the sample PipelineRun by the steps
+	// other than the CEL ones.
+	Changes []string `json:"changes,omitempty"`
+	// CEL describes the expressions of the CEL step, and their mutations of
+	// the sample PipelineRun.
+	CEL *cel.MutatorExplanation `json:"cel,omitempty"`
+}
+
+// Explainer explains the admission of the PipelineRuns of a namespace.
+type Explainer interface {
+	// Explain describes the admission of the PipelineRuns of the namespace
+	// and, when sample isn't nil, its changes to sample, which isn't
+	// modified.
+	Explain(ctx context.Context, namespace string, sample *tekv1.PipelineRun) *Explanation
+}
+
+// Explain implements Explainer. It follows the steps of defaultPipelineRun
+// without recording the decisions or checking the invariants, and the
+// mutators are only evaluated when a sample is given.
+func (d *pipelineRunCustomDefaulter) Explain(ctx context.Context, namespace string, sample *tekv1.PipelineRun) *Explanation {
+	explanation := &Explanation{Namespace: namespace}
+	var plr *tekv1.PipelineRun
+	if sample != nil {
+		plr = sample.DeepCopy()
+		plr.Namespace = namespace
+	}
+	add := func(step ExplainStep) {
+		if !explanation.Managed {
+			step.Applies = false
+			step.Reason = "the namespace isn't managed"
+			step.Changes = nil
+		}
+		explanation.Steps = append(explanation.Steps, step)
+	}
+
+	managed := d.explainManagedNamespaces(ctx, namespace)
+	explanation.Managed = managed.Applies
+	explanation.Steps = append(explanation.Steps, managed)
+	if !explanation.Managed {
+		// The PipelineRun is admitted untouched
+		plr = nil
+	}
+
+	manage := d.explainManageSelector(plr)
+	add(manage)
+	if d.manageSelector != nil && plr != nil && !manage.Applies {
+		return explanation
+	}
+
+	rateLimit := d.explainRateLimit(ctx, namespace)
+	add(rateLimit)
+	if rateLimit.Applies && plr != nil && d.rateLimit.limiter.Tokens(namespace) < 1 {
+		return explanation
+	}
+
+	validation := ExplainStep{Name: StepValidation, Applies: true, Reason: "the spec is validated"}
+	if plr != nil {
+		if err := plr.Spec.Validate(ctx); err != nil {
+			validation.Reason = "the spec is invalid, the admission is rejected: " + err.Error()
+			add(validation)
+			return explanation
+		}
+		validation.Reason = "the spec is valid"
+		if d.config.Webhook.AuditAnnotation {
+			delete(plr.Annotations, common.AppliedMutationsAnnotation)
+		}
+	}
+	add(validation)
+
+	pending := ExplainStep{Name: StepPending, Applies: true, Reason: "the PipelineRuns are created pending"}
+	if plr != nil {
+		plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
+		pending.Changes = []string{"spec.status=" + string(tekv1.PipelineRunSpecStatusPending)}
+	}
+	add(pending)
+
+	queueName := ExplainStep{
+		Name: StepQueueName, Applies: true, Source: "queueName",
+		Reason: fmt.Sprintf("the PipelineRuns without the %s label are queued to %s", common.QueueLabel, d.config.QueueName),
+	}
+	if plr != nil {
+		if queue, exists := plr.Labels[common.QueueLabel]; exists {
+			queueName.Applies = false
+			queueName.Reason = "the PipelineRun is already queued to " + queue
+		} else {
+			if plr.Labels == nil {
+				plr.Labels = make(map[string]string)
+			}
+			plr.Labels[common.QueueLabel] = d.config.QueueName
+			queueName.Changes = []string{fmt.Sprintf("labels[%s]=%s", common.QueueLabel, d.config.QueueName)}
+		}
+	}
+	add(queueName)
+
+	multiKueue := ExplainStep{Name: StepMultiKueueOverride, Source: "multiKueueOverride", Reason: "disabled"}
+	if d.config.MultiKueueOverride {
+		multiKueue.Applies = true
+		multiKueue.Reason = "the PipelineRuns are dispatched by MultiKueue"
+		if plr != nil {
+			plr.Spec.ManagedBy = ptr.To(common.ManagedByMultiKueueLabel)
+			multiKueue.Changes = []string{"spec.managedBy=" + common.ManagedByMultiKueueLabel}
+		}
+	}
+	add(multiKueue)
+
+	admissionUID := ExplainStep{Name: StepAdmissionUID, Source: "logging.recordAdmissionUID", Reason: "disabled"}
+	if d.config.Logging.RecordAdmissionUID {
+		admissionUID.Applies = true
+		admissionUID.Reason = fmt.Sprintf("the UID of the admission request is recorded in the %s annotation",
+			common.AdmissionUIDAnnotation)
+	}
+	add(admissionUID)
+
+	migration := ExplainStep{Name: StepAnnotationMigration, Source: "annotationMigrations", Reason: "disabled"}
+	if migrations := d.config.AnnotationMigrations; len(migrations) > 0 {
+		migration.Applies = true
+		var renames []string
+		for _, m := range migrations {
+			renames = append(renames, m.From+"* to "+m.To+"*")
+		}
+		migration.Reason = "the annotations are renamed from " + strings.Join(renames, ", then from ")
+		if plr != nil {
+			changes, err := migrateAnnotations(plr, migrations)
+			if err != nil {
+				migration.Reason = "the admission is rejected: " + err.Error()
+				add(migration)
+				return explanation
+			}
+			migration.Changes = changes
+			if len(changes) == 0 {
+				migration.Applies = false
+				migration.Reason = "the PipelineRun has no annotation to rename"
+			}
+		}
+	}
+	add(migration)
+
+	for _, mutator := range d.mutators {
+		rejected := false
+		for _, step := range explainMutator(mutator, namespace, plr) {
+			add(step)
+			rejected = rejected || (step.Applies && step.CEL != nil && step.CEL.Error != "")
+		}
+		if rejected {
+			return explanation
+		}
+	}
+
+	defaults := ExplainStep{Name: StepDefaultResources, Source: "defaultResources", Reason: "disabled"}
+	if d.defaultResources != nil {
+		defaults.Applies = true
+		defaults.Reason = fmt.Sprintf("the PipelineRuns request the default resources of their %s label",
+			kueueconstants.WorkloadPriorityClassLabel)
+		if plr != nil {
+			defaults.Changes = d.defaultResources.apply(plr)
+			if len(defaults.Changes) == 0 {
+				defaults.Applies = false
+				defaults.Reason = fmt.Sprintf("the PipelineRun already requests the default resources of the priority class %q, if any",
+					plr.Labels[kueueconstants.WorkloadPriorityClassLabel])
+			}
+		}
+	}
+	add(defaults)
+
+	validateQueue := ExplainStep{Name: StepQueueNameValidation, Source: "webhook.validateQueueName", Reason: "disabled"}
+	if d.queueValidator != nil {
+		validateQueue.Applies = true
+		validateQueue.Reason = "the PipelineRuns whose queue isn't a LocalQueue of the namespace are rejected"
+		if plr != nil {
+			if err := d.queueValidator.validate(ctx, plr, namespace); err != nil {
+				validateQueue.Reason = "the admission is rejected: " + err.Error()
+				add(validateQueue)
+				return explanation
+			}
+			validateQueue.Reason = "the queue " + plr.Labels[common.QueueLabel] + " is accepted"
+		}
+	} else if d.config.Webhook.ValidateQueueName {
+		validateQueue.Reason = "enabled, but not evaluated without access to the cluster"
+	}
+	add(validateQueue)
+
+	configHash := ExplainStep{Name: StepConfigHash, Source: "webhook.annotateConfigHash", Reason: "disabled"}
+	if d.configHash != nil {
+		configHash.Applies = true
+		configHash.Reason = fmt.Sprintf("the hash of the configuration is recorded in the %s annotation",
+			common.ConfigHashAnnotation)
+		if plr != nil {
+			if plr.Annotations == nil {
+				plr.Annotations = make(map[string]string)
+			}
+			hash := d.configHash()
+			plr.Annotations[common.ConfigHashAnnotation] = hash
+			configHash.Changes = []string{fmt.Sprintf("annotations[%s]=%s", common.ConfigHashAnnotation, hash)}
+		}
+	}
+	add(configHash)
+
+	audit := ExplainStep{Name: StepAuditAnnotation, Source: "webhook.auditAnnotation", Reason: "disabled"}
+	if d.config.Webhook.AuditAnnotation {
+		audit.Applies = true
+		audit.Reason = fmt.Sprintf("the applied mutations are recorded in the %s annotation", common.AppliedMutationsAnnotation)
+		if plr != nil {
+			if err := annotateAppliedMutations(sample, plr); err != nil {
+				audit.Reason = "unable to record the applied mutations: " + err.Error()
+			} else {
+				audit.Changes = []string{fmt.Sprintf("annotations[%s]=%s",
+					common.AppliedMutationsAnnotation, plr.Annotations[common.AppliedMutationsAnnotation])}
+			}
+		}
+	}
+	add(audit)
+	return explanation
+}
+
+// explainManagedNamespaces explains whether the PipelineRuns of the
+// namespace are queued, like managedNamespaces.manages.
+func (d *pipelineRunCustomDefaulter) explainManagedNamespaces(ctx context.Context, namespace string) ExplainStep {
+	step := ExplainStep{Name: StepManagedNamespaces, Applies: true, Source: "managedNamespaces"}
+	m := d.managedNamespaces
+	switch {
+	case m == nil && d.config.ManagedNamespaces == nil:
+		step.Reason = "all the namespaces are managed"
+		step.Source = ""
+	case m == nil:
+		step.Reason = "the selector isn't evaluated without access to the cluster, the namespace is assumed to be managed"
+	case !m.synced():
+		step.Reason = "the namespaces aren't synced yet, the namespace is managed"
+	default:
+		ns := &corev1.Namespace{}
+		if err := m.reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+			step.Reason = "unable to get the namespace, it's managed: " + err.Error()
+			break
+		}
+		step.Source = fmt.Sprintf("managedNamespaces, namespace %s (resourceVersion %s)", namespace, ns.ResourceVersion)
+		step.Applies = m.selector.Matches(labels.Set(ns.Labels))
+		if step.Applies {
+			step.Reason = fmt.Sprintf("the namespace matches the selector %q", m.selector.String())
+		} else {
+			step.Reason = fmt.Sprintf("the namespace doesn't match the selector %q, its PipelineRuns are admitted untouched",
+				m.selector.String())
+		}
+	}
+	return step
+}
+
+// explainManageSelector explains whether the PipelineRuns are queued by
+// their labels, and marks the sample when it matches the manage selector.
+func (d *pipelineRunCustomDefaulter) explainManageSelector(plr *tekv1.PipelineRun) ExplainStep {
+	step := ExplainStep{Name: StepManageSelector, Source: "manage.selector", Reason: "disabled"}
+	if d.manageSelector == nil {
+		return step
+	}
+	selector := d.manageSelector.Get()
+	step.Applies = true
+	switch {
+	case plr == nil:
+		step.Reason = fmt.Sprintf("the PipelineRuns matching the selector %q are queued and marked with the %s label, "+
+			"the others are admitted untouched", selector.String(), common.ManagedLabel)
+	case !selector.Matches(labels.Set(plr.Labels)):
+		step.Applies = false
+		step.Reason = fmt.Sprintf("the PipelineRun doesn't match the selector %q, it's admitted untouched", selector.String())
+	default:
+		step.Reason = fmt.Sprintf("the PipelineRun matches the selector %q", selector.String())
+		if plr.Labels == nil {
+			plr.Labels = make(map[string]string)
+		}
+		plr.Labels[common.ManagedLabel] = "true"
+		step.Changes = []string{"labels[" + common.ManagedLabel + "]=true"}
+	}
+	return step
+}
+
+// explainRateLimit explains whether the PipelineRuns of the namespace are
+// rate limited, like rateLimit.check, without taking a token.
+func (d *pipelineRunCustomDefaulter) explainRateLimit(ctx context.Context, namespace string) ExplainStep {
+	step := ExplainStep{Name: StepRateLimit, Source: "webhook.rateLimit", Reason: "disabled"}
+	r := d.rateLimit
+	switch {
+	case r == nil && d.config.Webhook.RateLimit.Enabled:
+		step.Reason = "enabled, but not evaluated without access to the cluster"
+	case r == nil:
+	case r.exempted(ctx, namespace):
+		step.Reason = fmt.Sprintf("the namespace matches the exemption selector %q", r.exempt.String())
+	default:
+		step.Applies = true
+		tokens := r.limiter.Tokens(namespace)
+		step.Reason = fmt.Sprintf("%d of the %d PipelineRuns of the burst are left, refilled at %g per second",
+			int(max(0, tokens)), r.limiter.burst, float64(r.limiter.qps))
+		if tokens < 1 {
+			step.Reason += ", the admission would be rejected"
+		}
+	}
+	return step
+}
+
+// explainMutator explains the mutations of the mutator. The PipelineRun,
+// when not nil, is mutated like by the mutator.
+func explainMutator(mutator PipelineRunMutator, namespace string, plr *tekv1.PipelineRun) []ExplainStep {
+	switch m := mutator.(type) {
+	case *cel.CELMutator:
+		return []ExplainStep{explainCEL(m, "", plr)}
+	case *CanaryMutator:
+		return m.explain(namespace, plr)
+	case *ReloadableMutator:
+		return explainMutator(m.Current(), namespace, plr)
+	default:
+		return []ExplainStep{{
+			Name: fmt.Sprintf("%T", mutator), Applies: true, Reason: "the mutator can't be explained",
+		}}
+	}
+}
+
+// explainCEL explains the CEL mutator of the configuration, and applies
+// its mutations to the PipelineRun when not nil.
+func explainCEL(m *cel.CELMutator, config string, plr *tekv1.PipelineRun) ExplainStep {
+	step := ExplainStep{Name: StepCEL, Config: config, Applies: true, Source: "cel"}
+	step.CEL = m.Explain(plr)
+	switch {
+	case plr == nil:
+		step.Reason = "the expressions are evaluated in order, their mutations are applied once all of them succeed"
+	case step.CEL.Error != "":
+		step.Reason = "the admission is rejected, the mutation failed"
+	default:
+		step.Reason = fmt.Sprintf("the mutations of the expressions are applied, %d in total", len(step.CEL.Mutations))
+		// The next steps see the mutations
+		*plr = *step.CEL.Result
+	}
+	return step
+}
+
+// explain explains the selection of the configuration by the canary, and
+// the CEL mutators of both configurations.
+func (m *CanaryMutator) explain(namespace string, plr *tekv1.PipelineRun) []ExplainStep {
+	step := ExplainStep{
+		Name: StepCanary, Applies: true, Source: "canaryPercent",
+		Reason: fmt.Sprintf("%d%% of the pipelines are mutated with the canary configuration, by namespace and generateName",
+			m.Percent()),
+	}
+	selected := ""
+	if plr != nil {
+		selected = m.Select(namespace, plr)
+		step.Reason = fmt.Sprintf("the PipelineRun is mutated with the %s configuration, %d%% of the pipelines being mutated with the canary one",
+			selected, m.Percent())
+		step.Changes = []string{fmt.Sprintf("annotations[%s]=%s", common.ConfigAnnotation, selected)}
+	}
+	steps := []ExplainStep{step}
+	for _, config := range []string{ConfigStable, ConfigCanary} {
+		mutator := m.stable
+		if config == ConfigCanary {
+			mutator = m.canary
+		}
+		var celStep ExplainStep
+		if c, ok := mutator.(*cel.CELMutator); ok {
+			if selected != "" && selected != config {
+				celStep = explainCEL(c, config, nil)
+				celStep.Applies = false
+				celStep.Reason = "the PipelineRun is mutated with the " + selected + " configuration"
+			} else {
+				celStep = explainCEL(c, config, plr)
+			}
+		} else {
+			celStep = ExplainStep{Name: fmt.Sprintf("%T", mutator), Config: config, Applies: true, Reason: "the mutator can't be explained"}
+		}
+		if config == ConfigCanary {
+			celStep.Source = "cel of the canary configuration"
+		}
+		steps = append(steps, celStep)
+	}
+	if plr != nil {
+		if plr.Annotations == nil {
+			plr.Annotations = make(map[string]string)
+		}
+		plr.Annotations[common.ConfigAnnotation] = selected
+	}
+	return steps
+}
+
+// ExplainHandler serves the explanation of the admission of the
+// PipelineRuns of the namespace of the namespace query parameter as JSON.
+// The mutators aren't evaluated, no sample PipelineRun being given.
+type ExplainHandler struct {
+	explainer Explainer
+}
+
+// NewExplainHandler returns an ExplainHandler explaining the admissions of
+// the defaulter, which must be created by NewCustomDefaulter.
+func NewExplainHandler(defaulter webhook.CustomDefaulter) (*ExplainHandler, error) {
+	explainer, ok := defaulter.(Explainer)
+	if !ok {
+		return nil, fmt.Errorf("the defaulter %T can't explain the admissions", defaulter)
+	}
+	return &ExplainHandler{explainer: explainer}, nil
+}
+
+// ServeHTTP serves the explanation.
+func (h *ExplainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace := r.URL.Query().Get("namespace")
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		http.Error(w, fmt.Sprintf("invalid namespace %q: %s", namespace, strings.Join(errs, ", ")), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.explainer.Explain(r.Context(), namespace, nil))
+}
+
+// WriteText writes the explanation in a human-readable form.
+func (e *Explanation) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if e.Managed {
+		fmt.Fprintf(&b, "Namespace %s is managed\n", e.Namespace)
+	} else {
+		fmt.Fprintf(&b, "Namespace %s isn't managed\n", e.Namespace)
+	}
+	for i, step := range e.Steps {
+		name := step.Name
+		if step.Config != "" {
+			name += " (" + step.Config + ")"
+		}
+		applies := "applies"
+		if !step.Applies {
+			applies = "doesn't apply"
+		}
+		fmt.Fprintf(&b, "\n%d. %s: %s\n   %s\n", i+1, name, applies, step.Reason)
+		if step.Source != "" {
+			fmt.Fprintf(&b, "   source: %s\n", step.Source)
+		}
+		for _, change := range step.Changes {
+			fmt.Fprintf(&b, "   change: %s\n", change)
+		}
+		if step.CEL != nil {
+			writeCELText(&b, step.CEL)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCELText writes the explanation of a CEL mutator.
+func writeCELText(b *strings.Builder, explanation *cel.MutatorExplanation) {
+	for _, program := range explanation.Programs {
+		group := program.Group
+		if group == "" {
+			group = "expressions"
+		}
+		fmt.Fprintf(b, "   - %s[%d]: %s\n", group, program.Index, program.Expression)
+		if program.SkippedUntil != nil {
+			fmt.Fprintf(b, "     skipped until %s, its circuit breaker is open\n", program.SkippedUntil.Format(time.RFC3339))
+		}
+		for _, warning := range program.Warnings {
+			fmt.Fprintf(b, "     warning: %s\n", warning)
+		}
+		for _, mutation := range program.Mutations {
+			fmt.Fprintf(b, "     mutation: %s\n", formatMutation(mutation))
+		}
+		if program.Error != "" {
+			fmt.Fprintf(b, "     error: %s\n", program.Error)
+		}
+	}
+	var options []string
+	if explanation.TimeoutOverride {
+		options = append(options, "timeoutOverride")
+	}
+	if explanation.ServiceAccountOverride {
+		options = append(options, "overrideServiceAccount")
+	}
+	if explanation.OverwritePolicy != "" {
+		options = append(options, fmt.Sprintf("overwritePolicy (%s)", explanation.OverwritePolicy))
+	}
+	if explanation.ResourceKeyNormalization {
+		options = append(options, "resourceKeyNormalization")
+	}
+	if len(explanation.ResourceWritePrefixes) > 0 {
+		options = append(options, fmt.Sprintf("resourceAnnotationPrefixes (write %s, read %s)",
+			strings.Join(explanation.ResourceWritePrefixes, ", "), strings.Join(explanation.ResourceReadPrefixes, ", ")))
+	}
+	if explanation.PruneUnknownResourceRequests {
+		options = append(options, "pruneUnknownResourceRequests")
+	}
+	if len(options) > 0 {
+		fmt.Fprintf(b, "   options: %s\n", strings.Join(options, ", "))
+	}
+	for _, mutation := range explanation.Mutations {
+		fmt.Fprintf(b, "   applied: %s\n", formatMutation(mutation))
+	}
+	if explanation.Error != "" {
+		fmt.Fprintf(b, "   error: %s\n", explanation.Error)
+	}
+}
+
+// formatMutation formats a mutation as its type, key and value.
+func formatMutation(mutation *cel.MutationRequest) string {
+	if mutation.Separator != "" {
+		return fmt.Sprintf("%s %s=%s (separator %q)", mutation.Type, mutation.Key, mutation.Value, mutation.Separator)
+	}
+	return fmt.Sprintf("%s %s=%s", mutation.Type, mutation.Key, mutation.Value)
+}
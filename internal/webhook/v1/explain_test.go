@@ -0,0 +1,234 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Explain", func() {
+	var (
+		reader client.Reader
+		cfg    *config.Config
+		canary *CanaryMutator
+	)
+
+	newCELMutator := func(opts []cel.MutatorOption, groups map[string][]string) *cel.CELMutator {
+		var programs []*cel.CompiledProgram
+		for _, group := range []string{"default", "resources"} {
+			if len(groups[group]) == 0 {
+				continue
+			}
+			compiled, err := cel.CompileCELPrograms(groups[group], cel.WithGroup(group))
+			Expect(err).NotTo(HaveOccurred())
+			programs = append(programs, compiled...)
+		}
+		return cel.NewCELMutator(programs, opts...)
+	}
+	// newDefaulter returns a defaulter combining the managed namespaces,
+	// the canary configuration, the expression groups, the MultiKueue
+	// override and the validation of the queue names.
+	newDefaulter := func() Explainer {
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{canary},
+			WithManagedNamespaces(reader, labels.SelectorFromSet(labels.Set{"konflux.ci/type": "user"}),
+				func() bool { return true }),
+			WithQueueNameValidation(reader, func() bool { return true }))
+		Expect(err).NotTo(HaveOccurred())
+		return defaulter.(Explainer)
+	}
+	steps := func(explanation *Explanation) []string {
+		var names []string
+		for _, step := range explanation.Steps {
+			if step.Applies {
+				names = append(names, step.Name+"/"+step.Config)
+			}
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(kueue.AddToScheme(scheme)).To(Succeed())
+		reader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant", Labels: map[string]string{"konflux.ci/type": "user"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "infra"}},
+			&kueue.LocalQueue{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "pipelines-queue"}},
+		).Build()
+		cfg = &config.Config{QueueName: "pipelines-queue", MultiKueueOverride: true}
+		stable := newCELMutator([]cel.MutatorOption{cel.WithTimeoutOverride()}, map[string][]string{
+			"default":   {`priority("stable")`},
+			"resources": {`resource("tekton.dev/pipelineruns", 1)`},
+		})
+		canaryMutator := newCELMutator(nil, map[string][]string{
+			"default":   {`priority("canary")`},
+			"resources": {`label("team", pipelineRun.metadata.labels["team"])`},
+		})
+		canary = NewCanaryMutator(stable, canaryMutator, 100)
+	})
+
+	It("lists the steps of the admissions of a namespace in execution order", func(ctx context.Context) {
+		explanation := newDefaulter().Explain(ctx, "tenant", nil)
+
+		Expect(explanation.Managed).To(BeTrue())
+		Expect(explanation.Steps[0].Source).To(MatchRegexp(`namespace tenant \(resourceVersion \d+\)`))
+		Expect(steps(explanation)).To(Equal([]string{
+			"managedNamespaces/", "validation/", "pending/", "queueName/", "multiKueueOverride/",
+			"canary/", "cel/stable", "cel/canary", "validateQueueName/",
+		}))
+		stable := explanation.Steps[10].CEL
+		Expect(stable.TimeoutOverride).To(BeTrue())
+		Expect(stable.Programs).To(HaveLen(2))
+		Expect(stable.Programs[1].Group).To(Equal("resources"))
+		Expect(stable.Programs[1].Mutations).To(BeEmpty())
+	})
+
+	It("explains the mutations of a sample PipelineRun", func(ctx context.Context) {
+		sample := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "build-", Labels: map[string]string{"team": "a"}},
+			Spec:       tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+		explanation := newDefaulter().Explain(ctx, "tenant", sample)
+
+		Expect(sample.Labels).NotTo(HaveKey(common.QueueLabel))
+		Expect(explanation.Steps[5].Changes).To(ConsistOf("labels[" + common.QueueLabel + "]=pipelines-queue"))
+		Expect(explanation.Steps[6].Changes).To(ConsistOf("spec.managedBy=" + common.ManagedByMultiKueueLabel))
+		Expect(explanation.Steps[9].Changes).To(ConsistOf("annotations[" + common.ConfigAnnotation + "]=canary"))
+		// Only the selected configuration is evaluated
+		Expect(explanation.Steps[10].Applies).To(BeFalse())
+		Expect(explanation.Steps[10].CEL.Mutations).To(BeEmpty())
+		Expect(explanation.Steps[11].Applies).To(BeTrue())
+		Expect(explanation.Steps[11].CEL.Programs[1].Mutations).To(ConsistOf(
+			&cel.MutationRequest{Type: cel.MutationTypeLabel, Key: "team", Value: "a"}))
+		Expect(explanation.Steps[11].CEL.Mutations).To(HaveLen(2))
+		Expect(explanation.Steps[13].Reason).To(Equal("the queue pipelines-queue is accepted"))
+
+		var text bytes.Buffer
+		Expect(explanation.WriteText(&text)).To(Succeed())
+		Expect(text.String()).To(ContainSubstring("12. cel (canary): applies"))
+		Expect(text.String()).To(ContainSubstring("applied: label team=a"))
+	})
+
+	It("stops at the steps rejecting the sample", func(ctx context.Context) {
+		sample := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Labels: map[string]string{common.QueueLabel: "missing", "team": "a"}},
+			Spec:       tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+		explanation := newDefaulter().Explain(ctx, "tenant", sample)
+		Expect(explanation.Steps[5].Applies).To(BeFalse())
+		Expect(explanation.Steps[13].Reason).To(ContainSubstring("the admission is rejected"))
+
+		// The label of the team is missing
+		sample.Labels = nil
+		explanation = newDefaulter().Explain(ctx, "tenant", sample)
+		Expect(explanation.Steps[11].CEL.Error).NotTo(BeEmpty())
+		Expect(explanation.Steps).To(HaveLen(12))
+
+		sample.Spec.PipelineRef = nil
+		explanation = newDefaulter().Explain(ctx, "tenant", sample)
+		Expect(explanation.Steps).To(HaveLen(4))
+		Expect(explanation.Steps[3].Reason).To(ContainSubstring("the spec is invalid"))
+	})
+
+	It("explains the default resources of the priority class of the sample", func(ctx context.Context) {
+		cfg.DefaultResources = config.DefaultResources{"stable": {"cpu": "2"}}
+		sample := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Labels: map[string]string{"team": "a"}},
+			Spec:       tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{newCELMutator(nil, map[string][]string{
+			"default": {`priority("stable")`},
+		})})
+		Expect(err).NotTo(HaveOccurred())
+		explanation := defaulter.(Explainer).Explain(ctx, "tenant", sample)
+		Expect(explanation.Steps).To(ContainElement(And(
+			HaveField("Name", StepDefaultResources),
+			HaveField("Applies", BeTrue()),
+			HaveField("Changes", ConsistOf("annotations["+cel.ResourceAnnotationPrefix+"cpu]=2")),
+		)))
+	})
+
+	It("explains the annotation migrations of the sample", func(ctx context.Context) {
+		cfg.AnnotationMigrations = config.AnnotationMigrations{
+			{From: "konflux-ci.dev/build-requests-", To: cel.ResourceAnnotationPrefix},
+		}
+		sample := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Annotations: map[string]string{
+				"konflux-ci.dev/build-requests-linux-arm64": "2",
+			}},
+			Spec: tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+		explanation := newDefaulter().Explain(ctx, "tenant", sample)
+		Expect(explanation.Steps).To(ContainElement(And(
+			HaveField("Name", StepAnnotationMigration),
+			HaveField("Applies", BeTrue()),
+			HaveField("Changes", ConsistOf(
+				"annotations[konflux-ci.dev/build-requests-linux-arm64]-",
+				"annotations["+cel.ResourceAnnotationPrefix+"linux-arm64]=2",
+			)),
+		)))
+	})
+
+	It("doesn't apply the steps to the namespaces which aren't managed", func(ctx context.Context) {
+		explanation := newDefaulter().Explain(ctx, "infra", nil)
+		Expect(explanation.Managed).To(BeFalse())
+		Expect(explanation.Steps[0].Reason).To(ContainSubstring("doesn't match the selector"))
+		Expect(steps(explanation)).To(BeEmpty())
+	})
+
+	It("serves the explanation of the namespace", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{canary})
+		Expect(err).NotTo(HaveOccurred())
+		handler, err := NewExplainHandler(defaulter)
+		Expect(err).NotTo(HaveOccurred())
+		serve := func(method, target string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+			return recorder
+		}
+
+		recorder := serve(http.MethodGet, ExplainPath+"?namespace=tenant")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var explanation Explanation
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &explanation)).To(Succeed())
+		Expect(explanation.Namespace).To(Equal("tenant"))
+		Expect(explanation.Steps[0].Reason).To(Equal("all the namespaces are managed"))
+
+		Expect(serve(http.MethodGet, ExplainPath).Code).To(Equal(http.StatusBadRequest))
+		Expect(serve(http.MethodGet, ExplainPath+"?namespace=Not_Valid").Code).To(Equal(http.StatusBadRequest))
+		Expect(serve(http.MethodPost, ExplainPath+"?namespace=tenant").Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,209 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// DefaultExpressionsReloadInterval is the interval at which the CEL
+// expressions are read again from the configuration file.
+const DefaultExpressionsReloadInterval = 10 * time.Second
+
+// ReloadableMutator mutates the PipelineRuns with a mutator which can be
+// replaced while it serves the admissions, e.g. once the CEL expressions are
+// reloaded. Each mutator has a generation, starting at 1, exposed by the
+// tekton_kueue_webhook_config_generation metric, and the hash of the
+// configuration it was built from, see ConfigHash.
+type ReloadableMutator struct {
+	current atomic.Pointer[generationMutator]
+}
+
+// generationMutator is a mutator of a ReloadableMutator, its generation
+// and the hash of its configuration.
+type generationMutator struct {
+	mutator    PipelineRunMutator
+	generation int64
+	configHash string
+}
+
+// NewReloadableMutator returns a ReloadableMutator serving the mutator,
+// built from the configuration of the hash, as its first generation.
+func NewReloadableMutator(mutator PipelineRunMutator, configHash string) *ReloadableMutator {
+	m := &ReloadableMutator{}
+	m.current.Store(&generationMutator{mutator: mutator, generation: 1, configHash: configHash})
+	configGeneration.Set(1)
+	return m
+}
+
+// Mutate mutates the PipelineRun with the current mutator.
+func (m *ReloadableMutator) Mutate(ctx context.Context, plr *tekv1.PipelineRun) error {
+	return m.current.Load().mutator.Mutate(ctx, plr)
+}
+
+// Current returns the mutator serving the admissions.
+func (m *ReloadableMutator) Current() PipelineRunMutator {
+	return m.current.Load().mutator
+}
+
+// Generation returns the generation of the mutator serving the admissions.
+func (m *ReloadableMutator) Generation() int64 {
+	return m.current.Load().generation
+}
+
+// ConfigHash returns the hash of the configuration of the mutator serving
+// the admissions.
+func (m *ReloadableMutator) ConfigHash() string {
+	return m.current.Load().configHash
+}
+
+// Replace serves the admissions with the mutator, built from the
+// configuration of the hash, as the next generation, and returns the
+// generation. The hash is recorded by the tekton_kueue_webhook_config_info
+// metric.
+func (m *ReloadableMutator) Replace(mutator PipelineRunMutator, configHash string) int64 {
+	generation := m.Generation() + 1
+	m.current.Store(&generationMutator{mutator: mutator, generation: generation, configHash: configHash})
+	configGeneration.Set(float64(generation))
+	RecordConfigHash(configHash)
+	return generation
+}
+
+// ExpressionsReloader reads the CEL configuration from the configuration
+// file at regular intervals, and replaces the mutator once it changes, so
+// the expressions can be changed without restarting the webhook. The
+// current mutator is kept when the new configuration is invalid. The rest
+// of the configuration isn't reloaded, so the hash of the served
+// configuration is the one of the initial configuration with the reloaded
+// CEL configuration.
+type ExpressionsReloader struct {
+	path     string
+	interval time.Duration
+	mutator  *ReloadableMutator
+	// initial is the configuration the webhook was started with
+	initial *config.Config
+	load    func() (*config.Config, error)
+	build   func(*config.Config) (PipelineRunMutator, error)
+	// loaded is the CEL configuration of the current mutator, as JSON
+	loaded []byte
+	// read is the content of the file when it was last read, so the
+	// invalid configurations are only reported once
+	read []byte
+}
+
+// NewExpressionsReloader returns an ExpressionsReloader replacing the
+// mutator, built by build from cfg, with the one build returns for the
+// configuration load returns once the configuration file at path changes.
+// load validates and defaults the configuration as when the webhook
+// starts.
+func NewExpressionsReloader(
+	path string,
+	interval time.Duration,
+	mutator *ReloadableMutator,
+	cfg *config.Config,
+	load func() (*config.Config, error),
+	build func(*config.Config) (PipelineRunMutator, error),
+) (*ExpressionsReloader, error) {
+	loaded, err := json.Marshal(cfg.CEL)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionsReloader{
+		path:     path,
+		interval: interval,
+		mutator:  mutator,
+		initial:  cfg,
+		load:     load,
+		build:    build,
+		loaded:   loaded,
+	}, nil
+}
+
+// Start reloads the expressions until the context is cancelled.
+func (r *ExpressionsReloader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// Reload loads the CEL configuration once the configuration file changed,
+// and replaces the mutator and the hash of the configuration when it
+// changed. The failures are counted by the
+// tekton_kueue_webhook_config_reloads_total metric once per content of the
+// file.
+func (r *ExpressionsReloader) Reload(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx).WithValues("path", r.path, "generation", r.mutator.Generation())
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "Unable to read the configuration, keeping the CEL expressions")
+		return
+	}
+	if bytes.Equal(data, r.read) {
+		return
+	}
+	r.read = data
+	cfg, err := r.load()
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		log.Error(err, "Invalid configuration, keeping the CEL expressions")
+		return
+	}
+	loaded, err := json.Marshal(cfg.CEL)
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		log.Error(err, "Unable to read the CEL configuration, keeping the CEL expressions")
+		return
+	}
+	if bytes.Equal(loaded, r.loaded) {
+		return
+	}
+	served := *r.initial
+	served.CEL = cfg.CEL
+	hash, err := ConfigHash(&served, nil)
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		log.Error(err, "Unable to hash the configuration, keeping the CEL expressions")
+		return
+	}
+	mutator, err := r.build(cfg)
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		log.Error(err, "Invalid CEL configuration, keeping the CEL expressions")
+		return
+	}
+	r.loaded = loaded
+	generation := r.mutator.Replace(mutator, hash)
+	configReloadsTotal.WithLabelValues("success").Inc()
+	log.Info("Reloaded the CEL expressions", "generation", generation, "hash", hash)
+}
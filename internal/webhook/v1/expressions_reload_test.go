@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("ExpressionsReloader", func() {
+	var (
+		path     string
+		mutator  *ReloadableMutator
+		reloader *ExpressionsReloader
+		cfg      *config.Config
+		builds   int
+	)
+
+	build := func(cfg *config.Config) (PipelineRunMutator, error) {
+		builds++
+		var expressions []string
+		for _, expression := range cfg.CEL.Expressions {
+			expressions = append(expressions, expression.Expression)
+		}
+		programs, err := cel.CompileCELPrograms(expressions)
+		if err != nil {
+			return nil, err
+		}
+		return cel.NewCELMutator(programs), nil
+	}
+	load := func() (*config.Config, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &config.Config{}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+		return cfg, cfg.Webhook.RateLimit.Validate()
+	}
+	hash := func(cfg *config.Config) string {
+		GinkgoHelper()
+		h, err := ConfigHash(cfg, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return h
+	}
+	write := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+	priority := func(ctx context.Context) string {
+		plr := &tektondevv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"}}
+		Expect(mutator.Mutate(ctx, plr)).To(Succeed())
+		return plr.Labels["kueue.x-k8s.io/priority-class"]
+	}
+	reloads := func(result string) float64 {
+		return testutil.ToFloat64(configReloadsTotal.WithLabelValues(result))
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		builds = 0
+		cfg = &config.Config{QueueName: "pipelines-queue",
+			CEL: config.CEL{Expressions: config.UnnamedExpressions(`priority("initial")`)}}
+		initial, err := build(cfg)
+		Expect(err).NotTo(HaveOccurred())
+		mutator = NewReloadableMutator(initial, hash(cfg))
+		reloader, err = NewExpressionsReloader(path, DefaultExpressionsReloadInterval, mutator, cfg, load, build)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("replaces the mutator when the expressions change", func(ctx context.Context) {
+		successes := reloads("success")
+		Expect(priority(ctx)).To(Equal("initial"))
+		Expect(mutator.Generation()).To(Equal(int64(1)))
+		Expect(testutil.ToFloat64(configGeneration)).To(Equal(1.0))
+
+		write("queueName: pipelines-queue\ncel:\n  expressions:\n  - 'priority(\"reloaded\")'\n")
+		reloader.Reload(ctx)
+
+		Expect(priority(ctx)).To(Equal("reloaded"))
+		Expect(mutator.Generation()).To(Equal(int64(2)))
+		Expect(testutil.ToFloat64(configGeneration)).To(Equal(2.0))
+		Expect(reloads("success")).To(Equal(successes + 1))
+	})
+
+	It("replaces the hash of the configuration with the mutator", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{mutator}, WithReloadableConfigHash(mutator))
+		Expect(err).NotTo(HaveOccurred())
+		plr := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant"},
+			Spec:       tektondevv1.PipelineRunSpec{PipelineRef: &tektondevv1.PipelineRef{Name: "build"}},
+		}
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigHashAnnotation, hash(cfg)))
+
+		// The other settings aren't reloaded, so they aren't part of the
+		// hash of the served configuration
+		write("queueName: other-queue\ncel:\n  expressions:\n  - 'priority(\"reloaded\")'\n")
+		reloader.Reload(ctx)
+		served := &config.Config{QueueName: "pipelines-queue",
+			CEL: config.CEL{Expressions: config.UnnamedExpressions(`priority("reloaded")`)}}
+		Expect(mutator.ConfigHash()).To(Equal(hash(served)))
+		Expect(testutil.ToFloat64(configInfo.WithLabelValues(hash(served)))).To(Equal(1.0))
+
+		plr.Annotations = nil
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Annotations).To(HaveKeyWithValue(common.ConfigHashAnnotation, hash(served)))
+	})
+
+	It("ignores the changes outside of the expressions", func(ctx context.Context) {
+		write("queueName: other-queue\ncel:\n  expressions:\n  - 'priority(\"initial\")'\n")
+		reloader.Reload(ctx)
+		reloader.Reload(ctx)
+
+		Expect(builds).To(Equal(1))
+		Expect(mutator.Generation()).To(Equal(int64(1)))
+	})
+
+	It("keeps the last-known-good expressions when the configuration is invalid", func(ctx context.Context) {
+		failures := reloads("failure")
+		reloader.Reload(ctx)
+		Expect(priority(ctx)).To(Equal("initial"))
+
+		write("cel: [")
+		reloader.Reload(ctx)
+		Expect(reloads("failure")).To(Equal(failures + 1))
+
+		// The configuration is validated as when the webhook starts
+		write("webhook:\n  rateLimit:\n    qps: -1\ncel:\n  expressions:\n  - 'priority(\"reloaded\")'\n")
+		reloader.Reload(ctx)
+		Expect(reloads("failure")).To(Equal(failures + 2))
+
+		write("queueName: pipelines-queue\ncel:\n  expressions:\n  - 'priority('\n")
+		reloader.Reload(ctx)
+		// The same invalid configuration is only reported once
+		reloader.Reload(ctx)
+		Expect(reloads("failure")).To(Equal(failures + 3))
+
+		Expect(priority(ctx)).To(Equal("initial"))
+		Expect(mutator.Generation()).To(Equal(int64(1)))
+	})
+})
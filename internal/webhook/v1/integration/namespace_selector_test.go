@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	v1 "github.com/konflux-ci/tekton-queue/internal/webhook/v1"
+)
+
+var _ = Describe("Webhook namespace selector", Ordered, func() {
+	// configurationName is the name of the MutatingWebhookConfiguration
+	// installed by envtest from config/webhook.
+	const configurationName = "mutating-webhook-configuration"
+	const managedLabel = "tekton-kueue.io/test-managed"
+
+	var (
+		request          = ctrl.Request{NamespacedName: types.NamespacedName{Name: configurationName}}
+		managedSelector  = &metav1.LabelSelector{MatchLabels: map[string]string{managedLabel: "true"}}
+		managedNamespace = "selector-managed"
+		otherNamespace   = "selector-other"
+	)
+
+	reconcile := func(ctx context.Context, selector *metav1.LabelSelector) ctrl.Result {
+		r := v1.NewNamespaceSelectorReconciler(k8sClient, k8sClient, configurationName, selector)
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+	webhookSelector := func(ctx context.Context) *metav1.LabelSelector {
+		webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, webhookConfig)).To(Succeed())
+		for _, wh := range webhookConfig.Webhooks {
+			if wh.Name == v1.WebhookName {
+				return wh.NamespaceSelector
+			}
+		}
+		Fail("webhook not found")
+		return nil
+	}
+	// createPipelineRun creates a PipelineRun in the namespace and returns
+	// whether it was mutated by the webhook.
+	createPipelineRun := func(ctx context.Context, namespace string) bool {
+		plr := &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "selector-", Namespace: namespace},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, plr)).To(Succeed())
+		_, mutated := plr.Labels[common.QueueLabel]
+		return mutated
+	}
+
+	BeforeAll(func(ctx context.Context) {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: managedNamespace, Labels: map[string]string{managedLabel: "true"}},
+		})).To(Succeed())
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: otherNamespace},
+		})).To(Succeed())
+	})
+
+	AfterAll(func(ctx context.Context) {
+		// Select all the namespaces again for the other tests
+		reconcile(ctx, &metav1.LabelSelector{})
+	})
+
+	It("refuses a selector matching no namespace", func(ctx context.Context) {
+		before := webhookSelector(ctx)
+		result := reconcile(ctx, &metav1.LabelSelector{MatchLabels: map[string]string{managedLabel: "missing"}})
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(webhookSelector(ctx)).To(Equal(before))
+	})
+
+	It("applies the configured selector", func(ctx context.Context) {
+		Expect(reconcile(ctx, managedSelector)).To(Equal(ctrl.Result{}))
+		Expect(webhookSelector(ctx)).To(Equal(managedSelector))
+
+		webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, webhookConfig)).To(Succeed())
+		Expect(webhookConfig.ManagedFields).To(ContainElement(HaveField("Manager", v1.NamespaceSelectorFieldManager)))
+	})
+
+	It("only sends the PipelineRuns of the selected namespaces to the webhook", func(ctx context.Context) {
+		// The kube-apiserver reloads the webhook configurations asynchronously
+		Eventually(func(ctx context.Context) bool {
+			return createPipelineRun(ctx, otherNamespace)
+		}).WithContext(ctx).Should(BeFalse())
+		Expect(createPipelineRun(ctx, managedNamespace)).To(BeTrue())
+	})
+
+	It("follows a change of the configured selector", func(ctx context.Context) {
+		otherSelector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      managedLabel,
+			Operator: metav1.LabelSelectorOpDoesNotExist,
+		}}}
+		Expect(reconcile(ctx, otherSelector)).To(Equal(ctrl.Result{}))
+		Expect(webhookSelector(ctx)).To(Equal(otherSelector))
+
+		Eventually(func(ctx context.Context) bool {
+			return createPipelineRun(ctx, managedNamespace)
+		}).WithContext(ctx).Should(BeFalse())
+		Expect(createPipelineRun(ctx, otherNamespace)).To(BeTrue())
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/jsondiff"
+)
+
+// defaulterPaths are the JSON pointers of the fields of the PipelineRuns the
+// defaulter changes itself: the queue label, the admission UID and defaulted
+// queue annotations, the pending status and the MultiKueue manager.
+var defaulterPaths = []string{
+	"/metadata/labels",
+	"/metadata/annotations",
+	"/spec/status",
+	"/spec/managedBy",
+}
+
+// MutationInvariantChecker checks that the admissions only change the
+// fields of the PipelineRuns the defaulter and the enabled mutation types
+// are allowed to change.
+type MutationInvariantChecker struct {
+	allowed []string
+}
+
+// NewMutationInvariantChecker returns a checker allowing the fields changed
+// by the defaulter and by the mutation types.
+func NewMutationInvariantChecker(types ...cel.MutationType) *MutationInvariantChecker {
+	allowed := append([]string{}, defaulterPaths...)
+	for _, mt := range types {
+		allowed = append(allowed, mt.Paths()...)
+	}
+	return &MutationInvariantChecker{allowed: allowed}
+}
+
+// Check returns the JSON pointers of the fields which changed between
+// before and after outside of the allowed fields.
+func (c *MutationInvariantChecker) Check(before, after *tekv1.PipelineRun) ([]string, error) {
+	paths, err := jsondiff.Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+	return jsondiff.Outside(paths, c.allowed), nil
+}
+
+// WithMutationInvariantCheck checks the PipelineRuns after their successful
+// admissions. The violations are logged and counted by the
+// tekton_kueue_mutation_invariant_violations_total metric, the admissions
+// aren't rejected.
+func WithMutationInvariantCheck(checker *MutationInvariantChecker) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.invariantChecker = checker
+	}
+}
+
+// reportViolations logs and counts the changes of the admission outside of
+// the allowed fields.
+func (c *MutationInvariantChecker) reportViolations(ctx context.Context, before, after *tekv1.PipelineRun) {
+	log := ctrl.LoggerFrom(ctx)
+	violations, err := c.Check(before, after)
+	if err != nil {
+		log.Error(err, "Unable to check the fields changed by the admission")
+		return
+	}
+	if len(violations) == 0 {
+		return
+	}
+	mutationInvariantViolationsTotal.Inc()
+	log.Error(nil, "The admission changed fields of the PipelineRun it isn't allowed to change",
+		"paths", violations)
+}
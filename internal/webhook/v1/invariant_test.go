@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Mutation invariant", func() {
+	var (
+		cfg     *config.Config
+		checker *MutationInvariantChecker
+		plr     *tektondevv1.PipelineRun
+	)
+
+	// admit runs the admission and returns the fields it changed outside of
+	// the allowed ones.
+	admit := func(ctx context.Context, mutators ...PipelineRunMutator) []string {
+		defaulter, err := NewCustomDefaulter(cfg, mutators, WithMutationInvariantCheck(checker))
+		Expect(err).NotTo(HaveOccurred())
+		before := plr.DeepCopy()
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		violations, err := checker.Check(before, plr)
+		Expect(err).NotTo(HaveOccurred())
+		return violations
+	}
+
+	BeforeEach(func() {
+		cfg = &config.Config{
+			QueueName:          "pipelines-queue",
+			MultiKueueOverride: true,
+			Logging:            config.Logging{RecordAdmissionUID: true},
+		}
+		checker = NewMutationInvariantChecker(cel.ValidTypes()...)
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "tenant"},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+				Params: tektondevv1.Params{
+					{Name: "build-platforms", Value: *tektondevv1.NewStructuredValues("linux/amd64", "linux/arm64")},
+				},
+				Timeouts: &tektondevv1.TimeoutFields{Pipeline: &metav1.Duration{Duration: time.Hour}},
+			},
+		}
+	})
+
+	It("holds for the defaulter and all the mutation types", func(ctx context.Context) {
+		programs, err := cel.CompileCELPrograms([]string{
+			`[annotation("owner", "team-a"), label("env", "production")]`,
+			`pipelineRun.spec.params.filter(p, p.name == "build-platforms")[0].value.map(
+				p, resource(replace(p, "/", "-"), 1))`,
+			`priority("high")`,
+			`[timeout("pipeline", "2h"), timeout("tasks", "90m")]`,
+			`[serviceAccount("release-pipeline"), nodeSelector("kubernetes.io/arch", "arm64")]`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		ctx = admission.NewContextWithRequest(ctx, admission.Request{})
+
+		before := testutil.ToFloat64(mutationInvariantViolationsTotal)
+		Expect(admit(ctx, cel.NewCELMutator(programs))).To(BeEmpty())
+		Expect(plr.Spec.Timeouts.Pipeline.Duration).To(Equal(2 * time.Hour))
+		Expect(plr.Spec.TaskRunTemplate.PodTemplate.NodeSelector).To(HaveKeyWithValue("kubernetes.io/arch", "arm64"))
+		Expect(plr.Annotations).To(HaveKey(common.AdmissionUIDAnnotation))
+		Expect(testutil.ToFloat64(mutationInvariantViolationsTotal)).To(Equal(before))
+	})
+
+	It("reports the fields changed by a misbehaving mutator without rejecting the admission", func(ctx context.Context) {
+		mutator := mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+			plr.Spec.PipelineRef.Name = "privileged-pipeline"
+			plr.Spec.Params = append(plr.Spec.Params, tektondevv1.Param{
+				Name: "injected", Value: *tektondevv1.NewStructuredValues("value"),
+			})
+			plr.Labels["team"] = "a"
+			return nil
+		})
+
+		before := testutil.ToFloat64(mutationInvariantViolationsTotal)
+		Expect(admit(ctx, mutator)).To(Equal([]string{
+			"/spec/params/1",
+			"/spec/pipelineRef/name",
+		}))
+		Expect(testutil.ToFloat64(mutationInvariantViolationsTotal)).To(Equal(before + 1))
+	})
+
+	It("only allows the fields of the enabled mutation types", func(ctx context.Context) {
+		checker = NewMutationInvariantChecker(cel.MutationTypeLabel)
+		programs, err := cel.CompileCELPrograms([]string{`timeout("pipeline", "2h")`})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(admit(ctx, cel.NewCELMutator(programs))).To(Equal([]string{"/spec/timeouts/pipeline"}))
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// DefaultManageSelectorReloadInterval is the interval at which the manage
+// selector is read again from the configuration file.
+const DefaultManageSelectorReloadInterval = 10 * time.Second
+
+// ManageSelector holds the selector of the PipelineRuns queued by the
+// webhook, which can be changed while the webhook runs.
+type ManageSelector struct {
+	selector atomic.Pointer[labels.Selector]
+}
+
+// NewManageSelector returns a ManageSelector holding the selector, nil
+// selecting all the PipelineRuns.
+func NewManageSelector(selector labels.Selector) *ManageSelector {
+	s := &ManageSelector{}
+	s.Set(selector)
+	return s
+}
+
+// Set changes the selector, nil selecting all the PipelineRuns.
+func (s *ManageSelector) Set(selector labels.Selector) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	s.selector.Store(&selector)
+}
+
+// Get returns the current selector.
+func (s *ManageSelector) Get() labels.Selector {
+	return *s.selector.Load()
+}
+
+// WithManageSelector only queues the PipelineRuns whose labels match the
+// selector, and marks them with the managed label, so the controller
+// ignores the others. The other PipelineRuns are admitted untouched: they
+// aren't pending, queued nor mutated.
+func WithManageSelector(selector *ManageSelector) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.manageSelector = selector
+	}
+}
+
+// ManageSelectorReloader reads the manage selector from the configuration
+// file at regular intervals, so the PipelineRuns queued by the webhook can
+// be changed without restarting it. The rest of the configuration isn't
+// reloaded.
+type ManageSelectorReloader struct {
+	path     string
+	interval time.Duration
+	selector *ManageSelector
+}
+
+// NewManageSelectorReloader returns a ManageSelectorReloader updating the
+// selector from the configuration file at path.
+func NewManageSelectorReloader(path string, interval time.Duration, selector *ManageSelector) *ManageSelectorReloader {
+	return &ManageSelectorReloader{path: path, interval: interval, selector: selector}
+}
+
+// Start reloads the selector until the context is cancelled.
+func (r *ManageSelectorReloader) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.Reload(ctx)
+		}
+	}
+}
+
+// Reload reads the selector from the configuration file. The current
+// selector is kept when the file can't be read or is invalid. Removing the
+// selector from the file makes the webhook queue all the PipelineRuns, still
+// marking them with the managed label.
+func (r *ManageSelectorReloader) Reload(ctx context.Context) {
+	current := r.selector.Get()
+	log := ctrl.LoggerFrom(ctx).WithValues("path", r.path)
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "Unable to read the configuration, keeping the manage selector", "selector", current.String())
+		return
+	}
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		log.Error(err, "Unable to parse the configuration, keeping the manage selector", "selector", current.String())
+		return
+	}
+	selector, err := cfg.ManageSelector()
+	if err != nil {
+		log.Error(err, "Keeping the manage selector", "selector", current.String())
+		return
+	}
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	if selector.String() != current.String() {
+		r.selector.Set(selector)
+		log.Info("Changed the manage selector", "previous", current.String(), "selector", selector.String())
+	}
+}
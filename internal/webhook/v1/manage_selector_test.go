@@ -0,0 +1,157 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Manage selector", func() {
+	const eventTypeLabel = "pipelinesascode.tekton.dev/event-type"
+
+	var selector *ManageSelector
+
+	newPipelineRun := func(labels map[string]string) *tektondevv1.PipelineRun {
+		return &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "tenant", Labels: labels},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "build"},
+			},
+		}
+	}
+	// admit admits the PipelineRun, and returns the mutator recording the
+	// PipelineRuns it mutated
+	admit := func(ctx context.Context, plr *tektondevv1.PipelineRun) *mutators.RecordingMutator {
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		mutator := &mutators.RecordingMutator{
+			Mutator: mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+				plr.Labels["team"] = "a"
+				return nil
+			}),
+		}
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{mutator}, WithManageSelector(selector))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		return mutator
+	}
+
+	BeforeEach(func() {
+		requirement, err := labels.NewRequirement(eventTypeLabel, selection.Exists, nil)
+		Expect(err).NotTo(HaveOccurred())
+		selector = NewManageSelector(labels.NewSelector().Add(*requirement))
+	})
+
+	It("queues and marks the PipelineRuns matching the selector", func(ctx context.Context) {
+		plr := newPipelineRun(map[string]string{eventTypeLabel: "pull_request"})
+		admit(ctx, plr)
+		Expect(plr.Spec.Status).To(BeEquivalentTo(tektondevv1.PipelineRunSpecStatusPending))
+		Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "pipelines-queue"))
+		Expect(plr.Labels).To(HaveKeyWithValue(common.ManagedLabel, "true"))
+		Expect(plr.Labels).To(HaveKeyWithValue("team", "a"))
+	})
+
+	It("admits the other PipelineRuns untouched", func(ctx context.Context) {
+		plr := newPipelineRun(map[string]string{"app": "web"})
+		before := plr.DeepCopy()
+		Expect(admit(ctx, plr).Calls()).To(BeEmpty())
+		Expect(plr).To(Equal(before))
+	})
+
+	It("doesn't mark the PipelineRuns without selector", func(ctx context.Context) {
+		plr := newPipelineRun(nil)
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		defaulter, err := NewCustomDefaulter(cfg, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Labels).NotTo(HaveKey(common.ManagedLabel))
+	})
+
+	It("explains whether the sample matches the selector", func(ctx context.Context) {
+		defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "pipelines-queue"}, nil,
+			WithManageSelector(selector))
+		Expect(err).NotTo(HaveOccurred())
+		explainer := defaulter.(Explainer)
+
+		explanation := explainer.Explain(ctx, "tenant", newPipelineRun(map[string]string{eventTypeLabel: "push"}))
+		Expect(explanation.Steps[1].Name).To(Equal(StepManageSelector))
+		Expect(explanation.Steps[1].Changes).To(ConsistOf("labels[" + common.ManagedLabel + "]=true"))
+
+		explanation = explainer.Explain(ctx, "tenant", newPipelineRun(nil))
+		Expect(explanation.Steps).To(HaveLen(2))
+		Expect(explanation.Steps[1].Applies).To(BeFalse())
+		Expect(explanation.Steps[1].Reason).To(ContainSubstring("it's admitted untouched"))
+	})
+
+	Describe("ManageSelectorReloader", func() {
+		var (
+			path     string
+			reloader *ManageSelectorReloader
+		)
+
+		write := func(content string) {
+			Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+			reloader = NewManageSelectorReloader(path, DefaultManageSelectorReloadInterval, selector)
+		})
+
+		It("changes the PipelineRuns queued by the webhook live", func(ctx context.Context) {
+			plr := newPipelineRun(map[string]string{"app": "web"})
+			untouched := plr.DeepCopy()
+			admit(ctx, untouched)
+			Expect(untouched.Labels).NotTo(HaveKey(common.ManagedLabel))
+
+			write("queueName: pipelines-queue\nmanage:\n  selector:\n    matchLabels:\n      app: web\n")
+			reloader.Reload(ctx)
+			Expect(selector.Get().String()).To(Equal("app=web"))
+			admit(ctx, plr)
+			Expect(plr.Labels).To(HaveKeyWithValue(common.ManagedLabel, "true"))
+
+			write("queueName: pipelines-queue\n")
+			reloader.Reload(ctx)
+			Expect(selector.Get().Empty()).To(BeTrue())
+		})
+
+		It("keeps the selector when the configuration is invalid", func(ctx context.Context) {
+			reloader.Reload(ctx)
+			Expect(selector.Get().String()).To(Equal(eventTypeLabel))
+
+			write("manage: [")
+			reloader.Reload(ctx)
+			Expect(selector.Get().String()).To(Equal(eventTypeLabel))
+
+			write("manage:\n  selector:\n    matchExpressions:\n    - key: app\n      operator: Is\n")
+			reloader.Reload(ctx)
+			Expect(selector.Get().String()).To(Equal(eventTypeLabel))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WithManagedNamespaces only queues the PipelineRuns of the namespaces
+// matching the selector, the PipelineRuns of the other namespaces are
+// admitted untouched, since the controller ignores them and they would
+// stay Pending forever. The namespaces are read from reader, usually the
+// cache of the manager, and all the namespaces are managed while synced
+// returns false.
+func WithManagedNamespaces(reader client.Reader, selector labels.Selector, synced func() bool) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.managedNamespaces = &managedNamespaces{reader: reader, selector: selector, synced: synced}
+	}
+}
+
+// managedNamespaces tells whether the PipelineRuns of a namespace are
+// queued.
+type managedNamespaces struct {
+	reader   client.Reader
+	selector labels.Selector
+	synced   func() bool
+}
+
+// manages returns whether the labels of the namespace match the selector.
+// The namespaces which can't be read are managed, as when no selector is
+// configured.
+func (m *managedNamespaces) manages(ctx context.Context, namespace string) bool {
+	log := ctrl.LoggerFrom(ctx)
+	if !m.synced() {
+		log.Info("Queuing the PipelineRun, the namespaces aren't synced yet")
+		return true
+	}
+	ns := &corev1.Namespace{}
+	if err := m.reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		log.Error(err, "Queuing the PipelineRun, unable to get its namespace")
+		return true
+	}
+	return m.selector.Matches(labels.Set(ns.Labels))
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Managed namespaces", func() {
+	var (
+		reader client.Reader
+		synced bool
+		plr    *tektondevv1.PipelineRun
+	)
+
+	newNamespace := func(name string, nsLabels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nsLabels}}
+	}
+	newReader := func(funcs interceptor.Funcs, objs ...client.Object) client.Reader {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithInterceptorFuncs(funcs).
+			Build()
+	}
+	admit := func(ctx context.Context) error {
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		mutator := mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+			plr.Labels["mutated"] = "true"
+			return nil
+		})
+		selector := labels.SelectorFromSet(labels.Set{"konflux.ci/type": "user"})
+		defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{mutator},
+			WithManagedNamespaces(reader, selector, func() bool { return synced }))
+		Expect(err).NotTo(HaveOccurred())
+		return defaulter.Default(ctx, plr)
+	}
+	expectQueued := func() {
+		Expect(plr.Spec.Status).To(Equal(tektondevv1.PipelineRunSpecStatus(tektondevv1.PipelineRunSpecStatusPending)))
+		Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "pipelines-queue"))
+		Expect(plr.Labels).To(HaveKeyWithValue("mutated", "true"))
+	}
+
+	BeforeEach(func() {
+		synced = true
+		reader = newReader(interceptor.Funcs{},
+			newNamespace("tenant", map[string]string{"konflux.ci/type": "user"}),
+			newNamespace("infra", nil),
+		)
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "tenant"},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+			},
+		}
+	})
+
+	It("queues the PipelineRuns of the managed namespaces", func(ctx context.Context) {
+		Expect(admit(ctx)).To(Succeed())
+		expectQueued()
+	})
+
+	It("leaves the PipelineRuns of the other namespaces untouched", func(ctx context.Context) {
+		plr.Namespace = "infra"
+		before := plr.DeepCopy()
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr).To(Equal(before))
+	})
+
+	It("queues the PipelineRuns while the namespaces aren't synced", func(ctx context.Context) {
+		synced = false
+		plr.Namespace = "infra"
+		Expect(admit(ctx)).To(Succeed())
+		expectQueued()
+	})
+
+	It("queues the PipelineRuns whose namespace can't be read", func(ctx context.Context) {
+		reader = newReader(interceptor.Funcs{
+			Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+				return errors.New("the cache is stopped")
+			},
+		})
+		Expect(admit(ctx)).To(Succeed())
+		expectQueued()
+	})
+})
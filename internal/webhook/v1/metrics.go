@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// mutationInvariantViolationsTotal tracks the admissions changing
+	// fields of the PipelineRuns outside of the allowed paths
+	mutationInvariantViolationsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_mutation_invariant_violations_total",
+			Help: "Total number of admissions changing fields of the PipelineRuns outside of the allowed paths",
+		},
+	)
+
+	// admissionsByConfigTotal tracks the admissions served by the stable
+	// and canary configurations
+	admissionsByConfigTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_admissions_by_config_total",
+			Help: "Total number of admissions mutated by the stable or canary configuration",
+		},
+		// config can be "stable" or "canary", result "success" or "failure"
+		[]string{"config", "result"},
+	)
+
+	// configInfo exposes the hash of the configurations loaded by the
+	// webhook, so the replicas serving different configurations are
+	// detected
+	configInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tekton_kueue_webhook_config_info",
+			Help: "Hash of the configurations loaded by the webhook, the value is always 1",
+		},
+		[]string{"hash"},
+	)
+
+	// configGeneration exposes the generation of the CEL expressions
+	// serving the admissions, incremented every time they're reloaded, see
+	// ReloadableMutator
+	configGeneration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tekton_kueue_webhook_config_generation",
+			Help: "Generation of the CEL expressions serving the admissions, incremented when they're reloaded",
+		},
+	)
+
+	// configReloadsTotal tracks the reloads of the CEL expressions
+	configReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_webhook_config_reloads_total",
+			Help: "Total number of reloads of the CEL expressions of the configuration",
+		},
+		// result can be "success" or "failure"
+		[]string{"result"},
+	)
+
+	// webhookDecisionsTotal tracks the admissions by the reason of their
+	// decision, see DecisionReason
+	webhookDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_webhook_decisions_total",
+			Help: "Total number of PipelineRun admissions by the reason of their decision",
+		},
+		// decision can be "mutated", "bypassed-label", "unmanaged-namespace",
+		// "too-large", "rate-limited" or "error"
+		[]string{"decision"},
+	)
+
+	// rateLimitedTotal tracks the admissions rejected because their
+	// namespace exceeded its rate limit
+	rateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tekton_kueue_webhook_rate_limited_total",
+			Help: "Total number of PipelineRuns rejected because their namespace exceeded its rate limit",
+		},
+		[]string{"namespace"},
+	)
+)
+
+func init() {
+	// Register the metrics with controller-runtime's global registry
+	metrics.Registry.MustRegister(mutationInvariantViolationsTotal, admissionsByConfigTotal, rateLimitedTotal, webhookDecisionsTotal, configInfo,
+		configGeneration, configReloadsTotal)
+}
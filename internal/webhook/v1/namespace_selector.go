@@ -0,0 +1,182 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// WebhookName is the name of the PipelineRun webhook in the
+	// MutatingWebhookConfiguration.
+	WebhookName = "pipelinerun-kueue-defaulter.tekton-kueue.io"
+
+	// NamespaceSelectorFieldManager is the field manager owning the
+	// namespaceSelector of the webhook.
+	NamespaceSelectorFieldManager = "tekton-kueue-namespace-selector"
+
+	// emptySelectionRetryInterval is the interval at which a selector
+	// matching no namespace is checked again.
+	emptySelectionRetryInterval = time.Minute
+)
+
+// NamespaceSelectorReconciler keeps the namespaceSelector of the webhook in
+// the MutatingWebhookConfiguration in sync with the configured one, so the
+// kube-apiserver doesn't send the PipelineRuns of the other namespaces.
+//
+// The selector is applied with server-side apply, and the field manager only
+// owns the namespaceSelector of the webhook.
+type NamespaceSelectorReconciler struct {
+	client client.Client
+	// reader lists the namespaces directly from the API server, so they
+	// don't need to be cached.
+	reader            client.Reader
+	configurationName string
+	selector          *metav1.LabelSelector
+}
+
+// NewNamespaceSelectorReconciler creates a NamespaceSelectorReconciler
+// applying the selector to the MutatingWebhookConfiguration
+// configurationName.
+func NewNamespaceSelectorReconciler(
+	c client.Client,
+	reader client.Reader,
+	configurationName string,
+	selector *metav1.LabelSelector,
+) *NamespaceSelectorReconciler {
+	return &NamespaceSelectorReconciler{
+		client:            c,
+		reader:            reader,
+		configurationName: configurationName,
+		selector:          selector,
+	}
+}
+
+// SetupWithManager registers the reconciler, triggered by the changes of
+// the MutatingWebhookConfiguration, including its creation when the manager
+// starts.
+func (r *NamespaceSelectorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("WebhookNamespaceSelector").
+		For(&admissionregistrationv1.MutatingWebhookConfiguration{}, builder.WithPredicates(
+			predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetName() == r.configurationName
+			}),
+		)).
+		Complete(r)
+}
+
+// Reconcile applies the selector to the webhook if it differs from the
+// current one. Selectors matching no namespace are refused, since they
+// would disable the webhook.
+func (r *NamespaceSelectorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if req.Name != r.configurationName {
+		return ctrl.Result{}, nil
+	}
+	webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := r.client.Get(ctx, req.NamespacedName, webhookConfig); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var current *metav1.LabelSelector
+	found := false
+	for _, wh := range webhookConfig.Webhooks {
+		if wh.Name == WebhookName {
+			current, found = wh.NamespaceSelector, true
+			break
+		}
+	}
+	if !found {
+		return ctrl.Result{}, fmt.Errorf("webhook %q not found in MutatingWebhookConfiguration %q", WebhookName, r.configurationName)
+	}
+	if equality.Semantic.DeepEqual(current, r.selector) {
+		return ctrl.Result{}, nil
+	}
+
+	matches, err := r.matchesNamespaces(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !matches {
+		log.Error(nil, "Refusing to apply a namespace selector matching no namespace, PipelineRuns wouldn't be admitted",
+			"selector", metav1.FormatLabelSelector(r.selector))
+		return ctrl.Result{RequeueAfter: emptySelectionRetryInterval}, nil
+	}
+
+	log.Info("Applying the namespace selector to the webhook",
+		"selector", metav1.FormatLabelSelector(r.selector))
+	patch, err := r.applyConfiguration()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.client.Patch(ctx, patch, client.Apply,
+		client.FieldOwner(NamespaceSelectorFieldManager), client.ForceOwnership); err != nil {
+		return ctrl.Result{}, fmt.Errorf("applying the namespace selector: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// matchesNamespaces returns whether the selector matches at least one
+// namespace.
+func (r *NamespaceSelectorReconciler) matchesNamespaces(ctx context.Context) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(r.selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	namespaces := &metav1.PartialObjectMetadataList{}
+	namespaces.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NamespaceList"))
+	if err := r.reader.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}, client.Limit(1)); err != nil {
+		return false, fmt.Errorf("listing namespaces: %w", err)
+	}
+	return len(namespaces.Items) > 0, nil
+}
+
+// applyConfiguration returns the server-side apply configuration of the
+// MutatingWebhookConfiguration, which only sets the namespaceSelector of the
+// webhook.
+func (r *NamespaceSelectorReconciler) applyConfiguration() (*unstructured.Unstructured, error) {
+	selector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.selector)
+	if err != nil {
+		return nil, fmt.Errorf("converting the namespace selector: %w", err)
+	}
+	patch := &unstructured.Unstructured{Object: map[string]interface{}{
+		"webhooks": []interface{}{
+			map[string]interface{}{
+				"name":              WebhookName,
+				"namespaceSelector": selector,
+			},
+		},
+	}}
+	patch.SetGroupVersionKind(admissionregistrationv1.SchemeGroupVersion.WithKind("MutatingWebhookConfiguration"))
+	patch.SetName(r.configurationName)
+	return patch, nil
+}
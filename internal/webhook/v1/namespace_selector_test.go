@@ -0,0 +1,179 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("NamespaceSelectorReconciler", func() {
+	const configurationName = "tekton-kueue-mutating-webhook-configuration"
+
+	var (
+		scheme   *runtime.Scheme
+		selector *metav1.LabelSelector
+		request  ctrl.Request
+	)
+
+	newWebhookConfiguration := func(webhookName string, selector *metav1.LabelSelector) *admissionregistrationv1.MutatingWebhookConfiguration {
+		return &admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: configurationName},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{{
+				Name:              webhookName,
+				NamespaceSelector: selector,
+			}},
+		}
+	}
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	// newClient returns a client failing on any patch, so the tests check
+	// that the selector isn't applied.
+	newClient := func(objs ...client.Object) client.Client {
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithInterceptorFuncs(interceptorRejectingPatches()).
+			Build()
+	}
+	getSelector := func(ctx context.Context, c client.Client) *metav1.LabelSelector {
+		webhookConfig := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: configurationName}, webhookConfig)).To(Succeed())
+		return webhookConfig.Webhooks[0].NamespaceSelector
+	}
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		selector = &metav1.LabelSelector{MatchLabels: map[string]string{"konflux-ci.dev/type": "tenant"}}
+		request = ctrl.Request{NamespacedName: types.NamespacedName{Name: configurationName}}
+	})
+
+	It("ignores a missing MutatingWebhookConfiguration", func(ctx context.Context) {
+		c := newClient(newNamespace("tenant", selector.MatchLabels))
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("ignores other MutatingWebhookConfigurations", func(ctx context.Context) {
+		c := newClient(newWebhookConfiguration(WebhookName, nil))
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "other"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("fails when the webhook is missing", func(ctx context.Context) {
+		c := newClient(newWebhookConfiguration("other.tekton-kueue.io", nil), newNamespace("tenant", selector.MatchLabels))
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).To(MatchError(ContainSubstring(WebhookName)))
+	})
+
+	It("doesn't patch a selector already in sync", func(ctx context.Context) {
+		c := newClient(newWebhookConfiguration(WebhookName, selector.DeepCopy()), newNamespace("tenant", selector.MatchLabels))
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("refuses a selector matching no namespace", func(ctx context.Context) {
+		c := newClient(newWebhookConfiguration(WebhookName, &metav1.LabelSelector{}), newNamespace("other", nil))
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(emptySelectionRetryInterval))
+		Expect(getSelector(ctx, c)).To(Equal(&metav1.LabelSelector{}))
+	})
+
+	It("applies the selector with its own field manager", func(ctx context.Context) {
+		var applied *client.PatchOptions
+		var patched client.Object
+		c := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(newWebhookConfiguration(WebhookName, nil), newNamespace("tenant", selector.MatchLabels)).
+			WithInterceptorFuncs(interceptorRecordingPatches(&patched, &applied)).
+			Build()
+		r := NewNamespaceSelectorReconciler(c, c, configurationName, selector)
+
+		result, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+
+		Expect(applied.FieldManager).To(Equal(NamespaceSelectorFieldManager))
+		Expect(*applied.Force).To(BeTrue())
+		Expect(patched.GetName()).To(Equal(configurationName))
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(patched)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(HaveKeyWithValue("webhooks", ConsistOf(map[string]interface{}{
+			"name": WebhookName,
+			"namespaceSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{"konflux-ci.dev/type": "tenant"},
+			},
+		})))
+		// Only the namespaceSelector of the webhook is applied
+		Expect(content).To(HaveLen(4))
+		Expect(content).To(HaveKeyWithValue("metadata", map[string]interface{}{"name": configurationName}))
+	})
+})
+
+// interceptorRejectingPatches makes the patches of the client fail.
+func interceptorRejectingPatches() interceptor.Funcs {
+	return interceptor.Funcs{
+		Patch: func(context.Context, client.WithWatch, client.Object, client.Patch, ...client.PatchOption) error {
+			return errors.New("unexpected patch")
+		},
+	}
+}
+
+// interceptorRecordingPatches records the server-side apply patches of the
+// client, which aren't supported by the fake client, instead of sending them.
+func interceptorRecordingPatches(obj *client.Object, opts **client.PatchOptions) interceptor.Funcs {
+	return interceptor.Funcs{
+		Patch: func(_ context.Context, _ client.WithWatch, o client.Object, patch client.Patch, patchOpts ...client.PatchOption) error {
+			if patch.Type() != types.ApplyPatchType {
+				return fmt.Errorf("unexpected patch type %s", patch.Type())
+			}
+			*obj = o
+			*opts = (&client.PatchOptions{}).ApplyOptions(patchOpts)
+			return nil
+		},
+	}
+}
@@ -22,11 +22,14 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+	"github.com/konflux-ci/tekton-queue/internal/cel"
 	"github.com/konflux-ci/tekton-queue/internal/common"
 	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
 	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/ptr"
@@ -73,11 +76,9 @@ func logConstructor(base logr.Logger, req *admission.Request) logr.Logger {
 	return log
 }
 
-type PipelineRunMutator interface {
-	Mutate(*tekv1.PipelineRun) error
-}
-
-// TODO(user): EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// PipelineRunMutator mutates the PipelineRuns admitted by the webhook, see
+// mutators.PipelineRunMutator.
+type PipelineRunMutator = mutators.PipelineRunMutator
 
 // +kubebuilder:webhook:path=/mutate-tekton-dev-v1-pipelinerun,mutating=true,failurePolicy=fail,sideEffects=None,groups=tekton.dev,resources=pipelineruns,verbs=create,versions=v1,name=pipelinerun-kueue-defaulter.tekton-kueue.io,admissionReviewVersions=v1
 
@@ -87,61 +88,218 @@ type PipelineRunMutator interface {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as it is used only for temporary operations and does not need to be deeply copied.
 type pipelineRunCustomDefaulter struct {
-	config   *config.Config
+	config *config.Config
+	// mutators are explained one by one, and applied in order by mutator.
 	mutators []PipelineRunMutator
+	mutator  PipelineRunMutator
+	// history, when set, records the admission decisions.
+	history *DecisionHistory
+	// queueValidator, when set, rejects the PipelineRuns whose queue
+	// doesn't exist.
+	queueValidator *queueNameValidator
+	// managedNamespaces, when set, selects the namespaces whose
+	// PipelineRuns are queued.
+	managedNamespaces *managedNamespaces
+	// manageSelector, when set, selects the PipelineRuns which are queued
+	// by their labels.
+	manageSelector *ManageSelector
+	// rateLimit, when set, rejects the PipelineRuns of the namespaces
+	// exceeding their rate.
+	rateLimit *rateLimit
+	// invariantChecker, when set, reports the admissions changing fields
+	// they aren't allowed to change.
+	invariantChecker *MutationInvariantChecker
+	// defaultResources, when set, adds the default resource requests of the
+	// priority classes once the mutators are applied.
+	defaultResources *defaultResources
+	// configHash, when set, returns the hash recorded in the
+	// ConfigHashAnnotation of the PipelineRuns.
+	configHash func() string
+	// tenantOverrides, when set, applies the expressions of the tenants
+	// once the mutators are applied.
+	tenantOverrides *TenantOverrides
+	// annotateDecision records the reason of the decision in the
+	// AdmissionDecisionAnnotation of the queued PipelineRuns.
+	annotateDecision bool
+}
+
+// DefaulterOption configures optional behavior of the defaulter.
+type DefaulterOption func(*pipelineRunCustomDefaulter)
+
+// WithDecisionHistory records the decisions of the admissions, successful
+// or not, in the history.
+func WithDecisionHistory(history *DecisionHistory) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.history = history
+	}
 }
 
-func NewCustomDefaulter(cfg *config.Config, mutators []PipelineRunMutator) (webhook.CustomDefaulter, error) {
+func NewCustomDefaulter(cfg *config.Config, plrMutators []PipelineRunMutator, opts ...DefaulterOption) (webhook.CustomDefaulter, error) {
 
 	defaulter := &pipelineRunCustomDefaulter{
 		config:   cfg,
-		mutators: mutators,
+		mutators: plrMutators,
+		mutator:  mutators.ChainMutators(plrMutators...),
+	}
+	for _, opt := range opts {
+		opt(defaulter)
 	}
 	if err := defaulter.Validate(); err != nil {
 		return nil, err
 	}
+	var err error
+	if defaulter.defaultResources, err = newDefaultResources(cfg); err != nil {
+		return nil, err
+	}
 	return defaulter, nil
 }
 
 // Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind PipelineRun.
+// Every admission ends with the recording of its decision, see DecisionReason.
 func (d *pipelineRunCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
 	plr, ok := obj.(*tekv1.PipelineRun)
 
 	if !ok {
+		recordDecisionReason(DecisionReasonError)
 		return k8serrors.NewBadRequest(fmt.Sprintf("expected an PipelineRun object but got %T", obj))
 	}
 
+	namespace := plr.Namespace
+	if req, reqErr := admission.RequestFromContext(ctx); reqErr == nil && namespace == "" {
+		namespace = req.Namespace
+	}
+	var before *tekv1.PipelineRun
+	if d.history != nil || d.invariantChecker != nil {
+		before = plr.DeepCopy()
+	}
+	ctx, skippedTooLarge := cel.WithSizeGuardReport(ctx)
+	reason, err := d.defaultPipelineRun(ctx, plr, namespace)
+	reason = decide(reason, skippedTooLarge(), err)
+	d.recordDecision(plr, reason)
+	if d.history != nil {
+		d.history.Record(namespace, before, plr, err)
+	}
+	if d.invariantChecker != nil && err == nil {
+		d.invariantChecker.reportViolations(ctx, before, plr)
+	}
+	return err
+}
+
+// defaultPipelineRun queues the PipelineRun and applies the mutators to it.
+// The PipelineRuns of the namespaces which aren't managed, and those not
+// matching the manage selector, are left untouched. It returns the reason
+// of the decision of the successful admissions, see decide, or the error
+// rejecting the PipelineRun.
+func (d *pipelineRunCustomDefaulter) defaultPipelineRun(ctx context.Context, plr *tekv1.PipelineRun, namespace string) (DecisionReason, error) {
+	if d.managedNamespaces != nil && !d.managedNamespaces.manages(ctx, namespace) {
+		ctrl.LoggerFrom(ctx).V(1).Info("Skipping the PipelineRun, its namespace isn't managed")
+		return DecisionReasonUnmanagedNamespace, nil
+	}
+	if d.manageSelector != nil && !d.manageSelector.Get().Matches(labels.Set(plr.Labels)) {
+		ctrl.LoggerFrom(ctx).V(1).Info("Skipping the PipelineRun, it doesn't match the manage selector")
+		return DecisionReasonBypassedLabel, nil
+	}
+	if d.rateLimit != nil {
+		if err := d.rateLimit.check(ctx, namespace); err != nil {
+			return "", err
+		}
+	}
+
 	// Attempt to catch bad pipelineruns prior to processing so we can catch
 	// errors ourselves and handle them appropriately.  Only validate the spec
 	// field, since we might be getting a pipelinerun with a generated name, which
 	// the top-level Validate() method will reject
 	err := plr.Spec.Validate(ctx)
 	if err != nil {
-		return k8serrors.NewBadRequest(err.Error())
+		return "", k8serrors.NewBadRequest(err.Error())
 	}
 
+	var before *tekv1.PipelineRun
+	debug := ctrl.LoggerFrom(ctx).V(1)
+	if d.config.Webhook.AuditAnnotation || debug.Enabled() {
+		before = plr.DeepCopy()
+	}
+	if d.config.Webhook.AuditAnnotation {
+		// The value set by the author of the PipelineRun isn't seen by the
+		// mutators, it's replaced once they're applied
+		delete(plr.Annotations, common.AppliedMutationsAnnotation)
+	}
+	if d.config.RequeueOnQueueChange {
+		// Only the webhook marks the PipelineRuns it queues
+		delete(plr.Annotations, common.DefaultedQueueAnnotation)
+	}
 	plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
 	if plr.Labels == nil {
 		plr.Labels = make(map[string]string)
 	}
-	if _, exists := plr.Labels[common.QueueLabel]; !exists {
+	_, queued := plr.Labels[common.QueueLabel]
+	if !queued {
 		plr.Labels[common.QueueLabel] = d.config.QueueName
 	}
+	if d.manageSelector != nil {
+		plr.Labels[common.ManagedLabel] = "true"
+	}
 	if d.config.MultiKueueOverride {
 		plr.Spec.ManagedBy = ptr.To(common.ManagedByMultiKueueLabel)
 	}
-	for _, mutator := range d.mutators {
-		if err := mutator.Mutate(plr); err != nil {
-			return err
+	if d.config.Logging.RecordAdmissionUID {
+		// The request is missing when the defaulter isn't invoked by the webhook
+		if req, err := admission.RequestFromContext(ctx); err == nil {
+			if plr.Annotations == nil {
+				plr.Annotations = make(map[string]string)
+			}
+			plr.Annotations[common.AdmissionUIDAnnotation] = string(req.UID)
+		}
+	}
+	if _, err := migrateAnnotations(plr, d.config.AnnotationMigrations); err != nil {
+		return "", err
+	}
+	if err := d.mutator.Mutate(ctx, plr); err != nil {
+		return "", err
+	}
+	if d.tenantOverrides != nil {
+		d.tenantOverrides.apply(ctx, plr, namespace)
+	}
+	if d.defaultResources != nil {
+		d.defaultResources.apply(plr)
+	}
+	// The queue changed by the mutators is left alone, like the one chosen
+	// by the author of the PipelineRun
+	if d.config.RequeueOnQueueChange && !queued && plr.Labels[common.QueueLabel] == d.config.QueueName {
+		if plr.Annotations == nil {
+			plr.Annotations = make(map[string]string)
+		}
+		plr.Annotations[common.DefaultedQueueAnnotation] = d.config.QueueName
+	}
+	if d.queueValidator != nil {
+		if err := d.queueValidator.validate(ctx, plr, namespace); err != nil {
+			return "", err
+		}
+	}
+	if d.configHash != nil {
+		if plr.Annotations == nil {
+			plr.Annotations = make(map[string]string)
 		}
+		plr.Annotations[common.ConfigHashAnnotation] = d.configHash()
+	}
+	if d.config.Webhook.AuditAnnotation {
+		if err := annotateAppliedMutations(before, plr); err != nil {
+			return "", fmt.Errorf("recording the applied mutations: %w", err)
+		}
+	}
+	if debug.Enabled() {
+		debug.Info("Mutated the PipelineRun", "diff", cel.DiffMutations(before, plr))
 	}
 
-	return nil
+	return DecisionReasonMutated, nil
 }
 
 func (d *pipelineRunCustomDefaulter) Validate() error {
 	if d.config.QueueName == "" {
 		return errors.New("queue name is not set in the PipelineRunCustomDefaulter")
 	}
+	if err := d.config.AnnotationMigrations.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
@@ -18,14 +18,23 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/konflux-ci/tekton-queue/internal/cel"
 	"github.com/konflux-ci/tekton-queue/internal/common"
 	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
 )
 
 func TestV1Webhook(t *testing.T) {
@@ -92,5 +101,349 @@ var _ = Describe("PipelineRun Webhook", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(plr.Labels[common.QueueLabel]).To(Equal("test-queue"))
 		})
+
+		It("should log the diff of the labels and annotations at debug level", func(ctx context.Context) {
+			var logs []string
+			logger := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{Verbosity: 1})
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "test-queue"}, []PipelineRunMutator{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(logr.NewContext(ctx, logger), plr)).To(Succeed())
+			Expect(logs).To(ContainElement(And(
+				ContainSubstring(`"msg"="Mutated the PipelineRun"`),
+				ContainSubstring(`+ kueue.x-k8s.io/queue-name: \"test-queue\"`),
+			)))
+		})
+
+		Context("when RecordAdmissionUID is true", func() {
+			BeforeEach(func() {
+				cfg := &config.Config{
+					QueueName: "test-queue",
+					Logging:   config.Logging{RecordAdmissionUID: true},
+				}
+				var err error
+				defaulter, err = NewCustomDefaulter(cfg, []PipelineRunMutator{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should set the admission UID", func(ctx context.Context) {
+				ctx = admission.NewContextWithRequest(ctx, admission.Request{
+					AdmissionRequest: admissionv1.AdmissionRequest{UID: "705ab4f5-6393-11e8-b7cc-42010a800002"},
+				})
+				err := defaulter.Default(ctx, plr)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plr.Annotations).To(HaveKeyWithValue(common.AdmissionUIDAnnotation, "705ab4f5-6393-11e8-b7cc-42010a800002"))
+			})
+
+			It("should not set the admission UID without an admission request", func(ctx context.Context) {
+				err := defaulter.Default(ctx, plr)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plr.Annotations).NotTo(HaveKey(common.AdmissionUIDAnnotation))
+			})
+		})
+
+		Context("with per-queue CEL expressions", func() {
+			BeforeEach(func() {
+				global := mustCompile(`label("policy", "global")`, `resource("cpu", 1)`)
+				build, err := cel.CompileCELPrograms([]string{`[label("policy", "build"), resource("cpu", 2)]`},
+					cel.WithGroup(cel.QueueGroup("build-queue")), cel.WithQueue("build-queue"))
+				Expect(err).NotTo(HaveOccurred())
+				release, err := cel.CompileCELPrograms([]string{`label("policy", "release")`},
+					cel.WithGroup(cel.QueueGroup("release-queue")), cel.WithQueue("release-queue"))
+				Expect(err).NotTo(HaveOccurred())
+				mutator := cel.NewCELMutator(append(append(global, build...), release...))
+				defaulter, err = NewCustomDefaulter(&config.Config{QueueName: "build-queue"}, []PipelineRunMutator{mutator})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should evaluate the expressions of the default queue after the global ones", func(ctx context.Context) {
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "build-queue"))
+				Expect(plr.Labels).To(HaveKeyWithValue("policy", "build"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "3"))
+			})
+
+			It("should evaluate the expressions of the queue set by the author", func(ctx context.Context) {
+				plr.Labels = map[string]string{common.QueueLabel: "release-queue"}
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Labels).To(HaveKeyWithValue("policy", "release"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "1"))
+			})
+
+			It("should only evaluate the global expressions for the other queues", func(ctx context.Context) {
+				plr.Labels = map[string]string{common.QueueLabel: "other-queue"}
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Labels).To(HaveKeyWithValue("policy", "global"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "1"))
+			})
+		})
+
+		Context("with TaskRun template expressions", func() {
+			BeforeEach(func() {
+				mutator := cel.NewCELMutator(mustCompile(
+					`pipelineRun.metadata.labels["kueue.x-k8s.io/queue-name"] == "release-queue" ?
+					[serviceAccount("release-pipeline"), nodeSelector("konflux-ci.dev/pool", "release")] : []`))
+				var err error
+				defaulter, err = NewCustomDefaulter(&config.Config{QueueName: "release-queue"}, []PipelineRunMutator{mutator})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should set the service account and the node selector of the queue", func(ctx context.Context) {
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Spec.TaskRunTemplate.ServiceAccountName).To(Equal("release-pipeline"))
+				Expect(plr.Spec.TaskRunTemplate.PodTemplate.NodeSelector).To(HaveKeyWithValue("konflux-ci.dev/pool", "release"))
+			})
+
+			It("should keep the service account set by the author", func(ctx context.Context) {
+				plr.Spec.TaskRunTemplate.ServiceAccountName = "build-pipeline"
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Spec.TaskRunTemplate.ServiceAccountName).To(Equal("build-pipeline"))
+				Expect(plr.Spec.TaskRunTemplate.PodTemplate.NodeSelector).To(HaveKeyWithValue("konflux-ci.dev/pool", "release"))
+			})
+
+			It("should not change the TaskRun template of the other queues", func(ctx context.Context) {
+				plr.Labels = map[string]string{common.QueueLabel: "build-queue"}
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Spec.TaskRunTemplate.ServiceAccountName).To(BeEmpty())
+				Expect(plr.Spec.TaskRunTemplate.PodTemplate).To(BeNil())
+			})
+		})
+
+		Context("when RequeueOnQueueChange is true", func() {
+			var cfg *config.Config
+
+			BeforeEach(func() {
+				cfg = &config.Config{QueueName: "test-queue", RequeueOnQueueChange: true}
+				var err error
+				defaulter, err = NewCustomDefaulter(cfg, []PipelineRunMutator{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should record the default queue", func(ctx context.Context) {
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "test-queue"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(common.DefaultedQueueAnnotation, "test-queue"))
+			})
+
+			It("should not mark the queue chosen by the author", func(ctx context.Context) {
+				plr.Labels = map[string]string{common.QueueLabel: "test-queue"}
+				plr.Annotations = map[string]string{common.DefaultedQueueAnnotation: "test-queue"}
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).NotTo(HaveKey(common.DefaultedQueueAnnotation))
+			})
+
+			It("should not mark the queue changed by the mutators", func(ctx context.Context) {
+				mutator := setMetadata(map[string]string{common.QueueLabel: "other-queue"}, nil)
+				var err error
+				defaulter, err = NewCustomDefaulter(cfg, []PipelineRunMutator{mutator})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(defaulter.Default(ctx, plr)).To(Succeed())
+				Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "other-queue"))
+				Expect(plr.Annotations).NotTo(HaveKey(common.DefaultedQueueAnnotation))
+			})
+		})
+
+		Context("with default resources", func() {
+			var cfg *config.Config
+
+			BeforeEach(func() {
+				cfg = &config.Config{
+					QueueName:        "test-queue",
+					DefaultResources: config.DefaultResources{"konflux-release": {"cpu": "2", "memory": "1Gi"}},
+				}
+			})
+
+			// newDefaulter returns the defaulter whose mutator sets the
+			// priority class and the annotations, like the CEL expressions
+			newDefaulter := func(priorityClass string, annotations map[string]string) webhook.CustomDefaulter {
+				labels := map[string]string{}
+				if priorityClass != "" {
+					labels[kueueconstants.WorkloadPriorityClassLabel] = priorityClass
+				}
+				defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{setMetadata(labels, annotations)})
+				Expect(err).NotTo(HaveOccurred())
+				return defaulter
+			}
+
+			It("should request the resources of the priority class selected by the mutators", func(ctx context.Context) {
+				Expect(newDefaulter("konflux-release", nil).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "2"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"memory", "1Gi"))
+			})
+
+			It("should keep the resources requested by the author", func(ctx context.Context) {
+				plr.Annotations = map[string]string{cel.ResourceAnnotationPrefix + "cpu": "4"}
+				Expect(newDefaulter("konflux-release", nil).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "4"))
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"memory", "1Gi"))
+			})
+
+			It("should keep the resources requested by the mutators", func(ctx context.Context) {
+				mutated := map[string]string{cel.ResourceAnnotationPrefix + "cpu": "1"}
+				Expect(newDefaulter("konflux-release", mutated).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"cpu", "1"))
+			})
+
+			It("should not request resources for the other priority classes", func(ctx context.Context) {
+				Expect(newDefaulter("konflux-build", nil).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).NotTo(HaveKey(HavePrefix(cel.ResourceAnnotationPrefix)))
+			})
+
+			It("should not request resources without priority class", func(ctx context.Context) {
+				Expect(newDefaulter("", nil).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).NotTo(HaveKey(HavePrefix(cel.ResourceAnnotationPrefix)))
+			})
+
+			It("should reject the resources which can't be requested with an annotation", func() {
+				cfg.DefaultResources = config.DefaultResources{"konflux-release": {"tekton.dev/pipelineruns": "2"}}
+				_, err := NewCustomDefaulter(cfg, []PipelineRunMutator{})
+				Expect(err).To(MatchError(ContainSubstring(`the resource "tekton.dev/pipelineruns" can't be requested with an annotation`)))
+			})
+
+			It("should reject the invalid quantities", func() {
+				cfg.DefaultResources = config.DefaultResources{"konflux-release": {"cpu": "two"}}
+				_, err := NewCustomDefaulter(cfg, []PipelineRunMutator{})
+				Expect(err).To(MatchError(ContainSubstring(`invalid quantity "two" of cpu`)))
+			})
+		})
+
+		Context("with annotation migrations", func() {
+			const legacyPrefix = "konflux-ci.dev/build-requests-"
+			var cfg *config.Config
+
+			BeforeEach(func() {
+				cfg = &config.Config{
+					QueueName: "test-queue",
+					AnnotationMigrations: config.AnnotationMigrations{
+						{From: legacyPrefix, To: cel.ResourceAnnotationPrefix},
+					},
+				}
+			})
+
+			newDefaulter := func(expressions ...string) webhook.CustomDefaulter {
+				defaulter, err := NewCustomDefaulter(cfg, []PipelineRunMutator{cel.NewCELMutator(mustCompile(expressions...))})
+				Expect(err).NotTo(HaveOccurred())
+				return defaulter
+			}
+
+			It("should rename the legacy annotations before the CEL expressions", func(ctx context.Context) {
+				plr.Annotations = map[string]string{legacyPrefix + "linux-arm64": "2", "team": "a"}
+				Expect(newDefaulter(`resource("linux-arm64", 1)`).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).NotTo(HaveKey(legacyPrefix + "linux-arm64"))
+				// The resource function adds to the migrated request
+				Expect(plr.Annotations).To(HaveKeyWithValue(cel.ResourceAnnotationPrefix+"linux-arm64", "3"))
+				Expect(plr.Annotations).To(HaveKeyWithValue("team", "a"))
+			})
+
+			It("should sum the integer values of both annotations", func(ctx context.Context) {
+				plr.Annotations = map[string]string{
+					legacyPrefix + "linux-arm64":                 "2",
+					cel.ResourceAnnotationPrefix + "linux-arm64": "3",
+				}
+				Expect(newDefaulter(`label("team", "a")`).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).To(Equal(map[string]string{cel.ResourceAnnotationPrefix + "linux-arm64": "5"}))
+			})
+
+			It("should reject the values which can't be summed", func(ctx context.Context) {
+				plr.Annotations = map[string]string{
+					legacyPrefix + "memory":                 "1Gi",
+					cel.ResourceAnnotationPrefix + "memory": "2",
+				}
+				err := newDefaulter(`label("team", "a")`).Default(ctx, plr)
+				Expect(k8serrors.IsBadRequest(err)).To(BeTrue())
+				Expect(err).To(MatchError(ContainSubstring(`the values "2" and "1Gi" can't be summed, "1Gi" isn't an integer`)))
+			})
+
+			It("should not change the annotations without migrations", func(ctx context.Context) {
+				cfg.AnnotationMigrations = nil
+				plr.Annotations = map[string]string{legacyPrefix + "linux-arm64": "2"}
+				Expect(newDefaulter(`label("team", "a")`).Default(ctx, plr)).To(Succeed())
+				Expect(plr.Annotations).To(Equal(map[string]string{legacyPrefix + "linux-arm64": "2"}))
+			})
+
+			It("should reject the overlapping prefixes", func() {
+				cfg.AnnotationMigrations = config.AnnotationMigrations{{From: "acme.io/requests-", To: "acme.io/requests-v2-"}}
+				_, err := NewCustomDefaulter(cfg, []PipelineRunMutator{})
+				Expect(err).To(MatchError(ContainSubstring("must not overlap")))
+			})
+		})
+
+		It("should apply the mutators in order once the PipelineRun is queued", func(ctx context.Context) {
+			first := &mutators.RecordingMutator{Mutator: setMetadata(map[string]string{"team": "a"}, nil)}
+			second := &mutators.RecordingMutator{}
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "test-queue"}, []PipelineRunMutator{first, second})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+
+			Expect(first.Calls()).To(HaveLen(1))
+			Expect(first.Calls()[0].Spec.Status).To(BeEquivalentTo(tektondevv1.PipelineRunSpecStatusPending))
+			Expect(first.Calls()[0].Labels).To(HaveKeyWithValue(common.QueueLabel, "test-queue"))
+			Expect(second.Calls()).To(HaveLen(1))
+			Expect(second.Calls()[0].Labels).To(HaveKeyWithValue("team", "a"))
+		})
+
+		It("should reject the admission once a mutator fails", func(ctx context.Context) {
+			failing := mutators.MutatorFunc(func(context.Context, *tektondevv1.PipelineRun) error {
+				return errors.New("mutation failed")
+			})
+			next := &mutators.RecordingMutator{}
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "test-queue"}, []PipelineRunMutator{failing, next})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(MatchError("mutation failed"))
+			Expect(next.Calls()).To(BeEmpty())
+		})
+
+		It("should abort the CEL evaluation once the admission request is cancelled", func(ctx context.Context) {
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "test-queue"},
+				[]PipelineRunMutator{cel.NewCELMutator(mustCompile(`label("team", "a")`))})
+			Expect(err).NotTo(HaveOccurred())
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			Expect(defaulter.Default(cancelled, plr)).To(MatchError(context.Canceled))
+			Expect(plr.Labels).NotTo(HaveKey("team"))
+		})
+
+		It("should not record the default queue by default", func(ctx context.Context) {
+			var err error
+			defaulter, err = NewCustomDefaulter(&config.Config{QueueName: "test-queue"}, []PipelineRunMutator{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).NotTo(HaveKey(common.DefaultedQueueAnnotation))
+		})
+
+		It("should not set the admission UID by default", func(ctx context.Context) {
+			cfg := &config.Config{
+				QueueName: "test-queue",
+			}
+			var err error
+			defaulter, err = NewCustomDefaulter(cfg, []PipelineRunMutator{})
+			Expect(err).NotTo(HaveOccurred())
+			ctx = admission.NewContextWithRequest(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{UID: "705ab4f5-6393-11e8-b7cc-42010a800002"},
+			})
+			err = defaulter.Default(ctx, plr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plr.Annotations).NotTo(HaveKey(common.AdmissionUIDAnnotation))
+		})
 	})
 })
+
+// setMetadata returns the mutator setting the labels and the annotations,
+// standing in for the CEL expressions in the tests which don't depend on
+// them.
+func setMetadata(labels, annotations map[string]string) PipelineRunMutator {
+	return mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+		for key, value := range labels {
+			if plr.Labels == nil {
+				plr.Labels = map[string]string{}
+			}
+			plr.Labels[key] = value
+		}
+		for key, value := range annotations {
+			if plr.Annotations == nil {
+				plr.Annotations = map[string]string{}
+			}
+			plr.Annotations[key] = value
+		}
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+// WithQueueNameValidation rejects the PipelineRuns whose queue, once
+// defaulted and mutated, isn't a LocalQueue of their namespace, instead of
+// letting them wait for a queue which doesn't exist. The LocalQueues are
+// read from reader, usually the cache of the manager, and the admissions
+// aren't validated while synced returns false.
+func WithQueueNameValidation(reader client.Reader, synced func() bool) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.queueValidator = &queueNameValidator{reader: reader, synced: synced}
+	}
+}
+
+// queueNameValidator checks that the queues of the PipelineRuns exist.
+type queueNameValidator struct {
+	reader client.Reader
+	synced func() bool
+}
+
+// validate returns a BadRequest error listing the LocalQueues of the
+// namespace when the queue of the PipelineRun doesn't exist. It fails open
+// when the LocalQueues can't be read.
+func (v *queueNameValidator) validate(ctx context.Context, plr *tekv1.PipelineRun, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	queueName := plr.Labels[common.QueueLabel]
+	if !v.synced() {
+		log.Info("Skipping the validation of the queue name, the LocalQueues aren't synced yet",
+			"queueName", queueName)
+		return nil
+	}
+
+	err := v.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: queueName}, &kueue.LocalQueue{})
+	if err == nil {
+		return nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		log.Error(err, "Skipping the validation of the queue name, unable to get the LocalQueue",
+			"queueName", queueName)
+		return nil
+	}
+
+	queues := &kueue.LocalQueueList{}
+	if err := v.reader.List(ctx, queues, client.InNamespace(namespace)); err != nil {
+		log.Error(err, "Unable to list the LocalQueues", "namespace", namespace)
+		return k8serrors.NewBadRequest(fmt.Sprintf("LocalQueue %q set by the %s label doesn't exist in namespace %q",
+			queueName, common.QueueLabel, namespace))
+	}
+	var names []string
+	for _, queue := range queues.Items {
+		names = append(names, queue.Name)
+	}
+	slices.Sort(names)
+	available := "there are no LocalQueues in the namespace"
+	if len(names) > 0 {
+		available = "the available LocalQueues are: " + strings.Join(names, ", ")
+	}
+	return k8serrors.NewBadRequest(fmt.Sprintf("LocalQueue %q set by the %s label doesn't exist in namespace %q, %s",
+		queueName, common.QueueLabel, namespace, available))
+}
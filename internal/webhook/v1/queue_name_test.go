@@ -0,0 +1,124 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/internal/mutators"
+)
+
+var _ = Describe("Queue name validation", func() {
+	var (
+		reader client.Reader
+		synced bool
+		plr    *tektondevv1.PipelineRun
+	)
+
+	newLocalQueue := func(namespace, name string) *kueue.LocalQueue {
+		return &kueue.LocalQueue{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	newReader := func(funcs interceptor.Funcs, objs ...client.Object) client.Reader {
+		scheme := runtime.NewScheme()
+		Expect(kueue.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithInterceptorFuncs(funcs).
+			Build()
+	}
+	admit := func(ctx context.Context, mutators ...PipelineRunMutator) error {
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		defaulter, err := NewCustomDefaulter(cfg, mutators,
+			WithQueueNameValidation(reader, func() bool { return synced }))
+		Expect(err).NotTo(HaveOccurred())
+		return defaulter.Default(ctx, plr)
+	}
+
+	BeforeEach(func() {
+		synced = true
+		reader = newReader(interceptor.Funcs{},
+			newLocalQueue("tenant", "pipelines-queue"),
+			newLocalQueue("tenant", "build-queue"),
+			newLocalQueue("other", "other-queue"),
+		)
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "tenant"},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+			},
+		}
+	})
+
+	It("admits the PipelineRuns of an existing queue", func(ctx context.Context) {
+		Expect(admit(ctx)).To(Succeed())
+
+		plr.Labels = map[string]string{common.QueueLabel: "build-queue"}
+		Expect(admit(ctx)).To(Succeed())
+	})
+
+	It("rejects the PipelineRuns of a missing queue, listing the queues of the namespace", func(ctx context.Context) {
+		plr.Labels = map[string]string{common.QueueLabel: "bulid-queue"}
+		err := admit(ctx)
+		Expect(k8serrors.IsBadRequest(err)).To(BeTrue())
+		Expect(err).To(MatchError(`LocalQueue "bulid-queue" set by the kueue.x-k8s.io/queue-name label doesn't ` +
+			`exist in namespace "tenant", the available LocalQueues are: build-queue, pipelines-queue`))
+	})
+
+	It("validates the queue set by the mutators", func(ctx context.Context) {
+		mutator := mutators.MutatorFunc(func(_ context.Context, plr *tektondevv1.PipelineRun) error {
+			plr.Labels[common.QueueLabel] = "other-queue"
+			return nil
+		})
+		err := admit(ctx, mutator)
+		Expect(err).To(MatchError(ContainSubstring(`LocalQueue "other-queue"`)))
+	})
+
+	It("reports namespaces without LocalQueues", func(ctx context.Context) {
+		plr.Namespace = "empty"
+		Expect(admit(ctx)).To(MatchError(ContainSubstring("there are no LocalQueues in the namespace")))
+	})
+
+	It("fails open while the LocalQueues aren't synced", func(ctx context.Context) {
+		synced = false
+		plr.Labels = map[string]string{common.QueueLabel: "missing-queue"}
+		Expect(admit(ctx)).To(Succeed())
+	})
+
+	It("fails open when the LocalQueue can't be read", func(ctx context.Context) {
+		reader = newReader(interceptor.Funcs{
+			Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+				return errors.New("the cache is stopped")
+			},
+		})
+		Expect(admit(ctx)).To(Succeed())
+	})
+})
@@ -0,0 +1,166 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// rateLimiterPruneInterval is the interval between the removals of the
+// buckets of the namespaces which stopped creating PipelineRuns.
+const rateLimiterPruneInterval = 10 * time.Minute
+
+// RateLimiter is a token bucket per namespace limiting the rate at which
+// the PipelineRuns of the namespace are admitted. The buckets are kept in
+// memory, so each replica of the webhook limits the namespaces on its own.
+type RateLimiter struct {
+	qps   rate.Limit
+	burst int
+	clock clock.PassiveClock
+
+	mu         sync.Mutex
+	limiters   map[string]*rate.Limiter
+	lastPruned time.Time
+}
+
+// NewRateLimiter returns a limiter allowing burst PipelineRuns at once per
+// namespace, refilled at qps PipelineRuns per second.
+func NewRateLimiter(qps float64, burst int, clk clock.PassiveClock) *RateLimiter {
+	return &RateLimiter{
+		qps:        rate.Limit(qps),
+		burst:      burst,
+		clock:      clk,
+		limiters:   make(map[string]*rate.Limiter),
+		lastPruned: clk.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of the namespace, and returns whether
+// there was one.
+func (r *RateLimiter) Allow(namespace string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	r.prune(now)
+	limiter, ok := r.limiters[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(r.qps, r.burst)
+		r.limiters[namespace] = limiter
+	}
+	return limiter.AllowN(now, 1)
+}
+
+// Tokens returns the number of tokens left in the bucket of the namespace,
+// without taking any.
+func (r *RateLimiter) Tokens(namespace string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limiter, ok := r.limiters[namespace]
+	if !ok {
+		return float64(r.burst)
+	}
+	return limiter.TokensAt(r.clock.Now())
+}
+
+// RetryAfter returns the number of seconds until a token is added to a
+// bucket.
+func (r *RateLimiter) RetryAfter() int {
+	if r.qps <= 0 {
+		return 0
+	}
+	return max(1, int(math.Ceil(1/float64(r.qps))))
+}
+
+// prune removes the full buckets, which behave like the new ones, once per
+// rateLimiterPruneInterval.
+func (r *RateLimiter) prune(now time.Time) {
+	if now.Sub(r.lastPruned) < rateLimiterPruneInterval {
+		return
+	}
+	r.lastPruned = now
+	for namespace, limiter := range r.limiters {
+		if limiter.TokensAt(now) >= float64(r.burst) {
+			delete(r.limiters, namespace)
+		}
+	}
+}
+
+// WithRateLimit rejects the PipelineRuns of the namespaces creating them
+// faster than the limiter allows, except those of the namespaces matching
+// exempt, when not nil. The namespaces are read from reader, usually the
+// cache of the manager, and no namespace is exempted while synced returns
+// false.
+func WithRateLimit(limiter *RateLimiter, reader client.Reader, exempt labels.Selector, synced func() bool) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.rateLimit = &rateLimit{limiter: limiter, reader: reader, exempt: exempt, synced: synced}
+	}
+}
+
+// rateLimit rejects the PipelineRuns once their namespace exceeds its rate.
+type rateLimit struct {
+	limiter *RateLimiter
+	reader  client.Reader
+	exempt  labels.Selector
+	synced  func() bool
+}
+
+// check returns a TooManyRequests error when the namespace isn't exempted
+// and its bucket is empty.
+func (r *rateLimit) check(ctx context.Context, namespace string) error {
+	if r.exempted(ctx, namespace) {
+		return nil
+	}
+	if r.limiter.Allow(namespace) {
+		return nil
+	}
+	rateLimitedTotal.WithLabelValues(namespace).Inc()
+	return k8serrors.NewTooManyRequests(
+		fmt.Sprintf("namespace %q exceeded its rate limit of %g PipelineRuns per second with a burst of %d, "+
+			"retry later", namespace, float64(r.limiter.qps), r.limiter.burst),
+		r.limiter.RetryAfter())
+}
+
+// exempted returns whether the labels of the namespace match the exempt
+// selector. The namespaces which can't be read are limited.
+func (r *rateLimit) exempted(ctx context.Context, namespace string) bool {
+	if r.exempt == nil {
+		return false
+	}
+	log := ctrl.LoggerFrom(ctx)
+	if !r.synced() {
+		log.V(1).Info("Rate limiting the PipelineRun, the namespaces aren't synced yet")
+		return false
+	}
+	ns := &corev1.Namespace{}
+	if err := r.reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		log.Error(err, "Rate limiting the PipelineRun, unable to get its namespace")
+		return false
+	}
+	return r.exempt.Matches(labels.Set(ns.Labels))
+}
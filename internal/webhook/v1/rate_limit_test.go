@@ -0,0 +1,176 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Rate limit", func() {
+	var (
+		clock   *clocktesting.FakePassiveClock
+		limiter *RateLimiter
+		reader  client.Reader
+		synced  bool
+	)
+
+	newReader := func(funcs interceptor.Funcs) client.Reader {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name: "build-service", Labels: map[string]string{"konflux-ci.dev/type": "system"},
+				}},
+			).
+			WithInterceptorFuncs(funcs).
+			Build()
+	}
+	admit := func(ctx context.Context, namespace string) error {
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		exempt := labels.SelectorFromSet(labels.Set{"konflux-ci.dev/type": "system"})
+		defaulter, err := NewCustomDefaulter(cfg, nil,
+			WithRateLimit(limiter, reader, exempt, func() bool { return synced }))
+		Expect(err).NotTo(HaveOccurred())
+		return defaulter.Default(ctx, &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+			},
+		})
+	}
+	admitN := func(ctx context.Context, namespace string, n int) int {
+		admitted := 0
+		for range n {
+			if admit(ctx, namespace) == nil {
+				admitted++
+			}
+		}
+		return admitted
+	}
+
+	BeforeEach(func() {
+		clock = clocktesting.NewFakePassiveClock(time.Now())
+		// A burst of 3, refilled with a token every 2 seconds
+		limiter = NewRateLimiter(0.5, 3, clock)
+		reader = newReader(interceptor.Funcs{})
+		synced = true
+	})
+
+	It("admits the burst, then the refilled tokens", func(ctx context.Context) {
+		Expect(admitN(ctx, "tenant", 5)).To(Equal(3))
+
+		clock.SetTime(clock.Now().Add(time.Second))
+		Expect(admitN(ctx, "tenant", 1)).To(Equal(0))
+		clock.SetTime(clock.Now().Add(time.Second))
+		Expect(admitN(ctx, "tenant", 2)).To(Equal(1))
+
+		// The bucket doesn't fill beyond the burst
+		clock.SetTime(clock.Now().Add(time.Hour))
+		Expect(admitN(ctx, "tenant", 5)).To(Equal(3))
+	})
+
+	It("rejects the PipelineRuns with a TooManyRequests error", func(ctx context.Context) {
+		before := testutil.ToFloat64(rateLimitedTotal.WithLabelValues("tenant"))
+		Expect(admitN(ctx, "tenant", 3)).To(Equal(3))
+
+		err := admit(ctx, "tenant")
+		Expect(k8serrors.IsTooManyRequests(err)).To(BeTrue())
+		var status k8serrors.APIStatus
+		Expect(errors.As(err, &status)).To(BeTrue())
+		Expect(status.Status().Code).To(BeEquivalentTo(http.StatusTooManyRequests))
+		Expect(status.Status().Details.RetryAfterSeconds).To(BeEquivalentTo(2))
+		Expect(err).To(MatchError(ContainSubstring(
+			`namespace "tenant" exceeded its rate limit of 0.5 PipelineRuns per second with a burst of 3`)))
+		Expect(testutil.ToFloat64(rateLimitedTotal.WithLabelValues("tenant"))).To(Equal(before + 1))
+	})
+
+	It("limits each namespace on its own", func(ctx context.Context) {
+		Expect(admitN(ctx, "tenant", 4)).To(Equal(3))
+		Expect(admitN(ctx, "other-tenant", 4)).To(Equal(3))
+	})
+
+	It("doesn't limit the exempted namespaces", func(ctx context.Context) {
+		Expect(admitN(ctx, "build-service", 10)).To(Equal(10))
+	})
+
+	It("limits the exempted namespaces while the namespaces aren't synced", func(ctx context.Context) {
+		synced = false
+		Expect(admitN(ctx, "build-service", 4)).To(Equal(3))
+	})
+
+	It("limits the namespaces which can't be read", func(ctx context.Context) {
+		reader = newReader(interceptor.Funcs{
+			Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+				return errors.New("the cache is stopped")
+			},
+		})
+		Expect(admitN(ctx, "build-service", 4)).To(Equal(3))
+	})
+
+	It("prunes the full buckets", func() {
+		Expect(limiter.Allow("tenant")).To(BeTrue())
+		Expect(limiter.Allow("other-tenant")).To(BeTrue())
+		Expect(limiter.limiters).To(HaveLen(2))
+
+		clock.SetTime(clock.Now().Add(rateLimiterPruneInterval))
+		Expect(limiter.Allow("tenant")).To(BeTrue())
+		Expect(limiter.limiters).To(HaveLen(1))
+		Expect(limiter.Tokens("other-tenant")).To(BeEquivalentTo(3))
+	})
+
+	It("explains the rate limit without taking a token", func(ctx context.Context) {
+		cfg := &config.Config{QueueName: "pipelines-queue"}
+		defaulter, err := NewCustomDefaulter(cfg, nil, WithRateLimit(limiter, reader,
+			labels.SelectorFromSet(labels.Set{"konflux-ci.dev/type": "system"}), func() bool { return synced }))
+		Expect(err).NotTo(HaveOccurred())
+		explainer := defaulter.(Explainer)
+
+		Expect(limiter.Allow("tenant")).To(BeTrue())
+		for range 2 {
+			step := explainer.Explain(ctx, "tenant", nil).Steps[2]
+			Expect(step.Name).To(Equal(StepRateLimit))
+			Expect(step.Applies).To(BeTrue())
+			Expect(step.Reason).To(Equal("2 of the 3 PipelineRuns of the burst are left, refilled at 0.5 per second"))
+		}
+
+		step := explainer.Explain(ctx, "build-service", nil).Steps[2]
+		Expect(step.Applies).To(BeFalse())
+		Expect(step.Reason).To(Equal(`the namespace matches the exemption selector "konflux-ci.dev/type=system"`))
+	})
+})
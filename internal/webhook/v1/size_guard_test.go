@@ -0,0 +1,53 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Oversized PipelineRuns", func() {
+	It("queues them without evaluating the CEL expressions", func(ctx context.Context) {
+		mutator := cel.NewCELMutator(mustCompile(`label("team", "a")`), cel.WithMaxObjectBytes(logr.Discard(), 8*1024))
+		defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "pipelines-queue"}, []PipelineRunMutator{mutator})
+		Expect(err).NotTo(HaveOccurred())
+
+		plr := &tektondevv1.PipelineRun{
+			Spec: tektondevv1.PipelineRunSpec{PipelineSpec: &tektondevv1.PipelineSpec{}},
+		}
+		for i := range 200 {
+			plr.Spec.PipelineSpec.Tasks = append(plr.Spec.PipelineSpec.Tasks, tektondevv1.PipelineTask{
+				Name:    fmt.Sprintf("task-%d", i),
+				TaskRef: &tektondevv1.TaskRef{Name: "build"},
+			})
+		}
+		Expect(defaulter.Default(ctx, plr)).To(Succeed())
+		Expect(plr.Labels).To(HaveKeyWithValue(common.QueueLabel, "pipelines-queue"))
+		Expect(plr.Labels).NotTo(HaveKey("team"))
+		Expect(plr.Spec.Status).To(Equal(tektondevv1.PipelineRunSpecStatus(tektondevv1.PipelineRunSpecStatusPending)))
+	})
+})
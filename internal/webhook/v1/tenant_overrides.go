@@ -0,0 +1,187 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+// TenantOverridesKey is the key of the configuration in the data of the
+// config.TenantOverridesConfigMap.
+const TenantOverridesKey = "config.yaml"
+
+// TenantOverridesGroup is the group of the tenant expressions, labeling
+// their metrics.
+const TenantOverridesGroup = "tenant-overrides"
+
+// tenantConfig is the configuration of a config.TenantOverridesConfigMap.
+// The other fields of the configuration aren't allowed.
+type tenantConfig struct {
+	CEL struct {
+		Expressions []string `json:"expressions,omitempty"`
+	} `json:"cel,omitempty"`
+}
+
+// WithTenantOverrides applies the expressions of the tenants, once the
+// mutators are applied.
+func WithTenantOverrides(overrides *TenantOverrides) DefaulterOption {
+	return func(d *pipelineRunCustomDefaulter) {
+		d.tenantOverrides = overrides
+	}
+}
+
+// TenantOverrides applies the expressions of the
+// config.TenantOverridesConfigMap of the namespaces to their PipelineRuns.
+// The expressions of each namespace are compiled when they're first
+// needed, and compiled again once the ConfigMap changes. The tenant
+// expressions fail open: the PipelineRuns of the namespaces whose
+// ConfigMap is invalid, or whose expressions fail or return mutations the
+// policy doesn't allow, are admitted without them.
+type TenantOverrides struct {
+	reader      client.Reader
+	policy      config.TenantOverrides
+	compileOpts []cel.CompileOption
+
+	mu sync.Mutex
+	// sets are the compiled expressions of the ConfigMaps, by namespace.
+	sets map[string]*tenantOverrideSet
+}
+
+// tenantOverrideSet is the compilation of the expressions of a ConfigMap.
+type tenantOverrideSet struct {
+	// resourceVersion is the version of the ConfigMap which was compiled.
+	resourceVersion string
+	// mutator applies the expressions, nil when the ConfigMap is invalid.
+	mutator *cel.CELMutator
+	// err is the error of the compilation.
+	err error
+}
+
+// NewTenantOverrides returns the tenant overrides reading the ConfigMaps
+// from reader, usually the cache of the manager, restricted by the policy,
+// and compiled with the options.
+func NewTenantOverrides(
+	reader client.Reader,
+	policy config.TenantOverrides,
+	opts ...cel.CompileOption,
+) (*TenantOverrides, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	for _, mutationType := range policy.GetAllowedTypes() {
+		if !cel.MutationType(mutationType).IsValid() {
+			return nil, fmt.Errorf("invalid tenantOverrides allowedTypes type %q, must be one of: %v",
+				mutationType, cel.ValidTypes())
+		}
+	}
+	return &TenantOverrides{
+		reader:      reader,
+		policy:      policy,
+		compileOpts: opts,
+		sets:        map[string]*tenantOverrideSet{},
+	}, nil
+}
+
+// apply applies the expressions of the namespace to the PipelineRun. The
+// failures are logged, and the PipelineRun is then left unchanged.
+func (t *TenantOverrides) apply(ctx context.Context, plr *tekv1.PipelineRun, namespace string) {
+	log := ctrl.LoggerFrom(ctx)
+	mutator, err := t.mutator(ctx, namespace)
+	if err != nil {
+		log.Error(err, "Skipping the tenant overrides", "configMap", config.TenantOverridesConfigMap)
+		return
+	}
+	if mutator == nil {
+		return
+	}
+	mutated, err := mutator.MutateCopy(ctx, plr)
+	if err != nil {
+		log.Error(err, "Skipping the tenant overrides", "configMap", config.TenantOverridesConfigMap)
+		return
+	}
+	*plr = *mutated
+}
+
+// mutator returns the mutator of the expressions of the namespace, nil
+// when the namespace has no ConfigMap, compiling them again when the
+// ConfigMap changed.
+func (t *TenantOverrides) mutator(ctx context.Context, namespace string) (*cel.CELMutator, error) {
+	configMap := &corev1.ConfigMap{}
+	err := t.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: config.TenantOverridesConfigMap}, configMap)
+	if k8serrors.IsNotFound(err) {
+		t.invalidate(namespace)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the ConfigMap %s/%s: %w", namespace, config.TenantOverridesConfigMap, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	set, ok := t.sets[namespace]
+	if !ok || set.resourceVersion != configMap.ResourceVersion {
+		set = &tenantOverrideSet{resourceVersion: configMap.ResourceVersion}
+		set.mutator, set.err = t.compile(namespace, configMap)
+		t.sets[namespace] = set
+	}
+	return set.mutator, set.err
+}
+
+// invalidate forgets the compiled expressions of the namespace.
+func (t *TenantOverrides) invalidate(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sets, namespace)
+}
+
+// compile compiles the expressions of the ConfigMap of the namespace,
+// restricted by the policy. The ConfigMaps without expressions have no
+// mutator.
+func (t *TenantOverrides) compile(namespace string, configMap *corev1.ConfigMap) (*cel.CELMutator, error) {
+	var cfg tenantConfig
+	if err := yaml.UnmarshalStrict([]byte(configMap.Data[TenantOverridesKey]), &cfg); err != nil {
+		return nil, fmt.Errorf("invalid ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+	}
+	if len(cfg.CEL.Expressions) == 0 {
+		return nil, nil
+	}
+	policy := cel.MutationPolicy{KeyPrefixes: t.policy.KeyPrefixes(namespace)}
+	for _, mutationType := range t.policy.GetAllowedTypes() {
+		policy.Types = append(policy.Types, cel.MutationType(mutationType))
+	}
+	opts := append(append([]cel.CompileOption(nil), t.compileOpts...),
+		cel.WithGroup(TenantOverridesGroup), cel.WithMutationPolicy(policy))
+	programs, err := cel.CompileCELPrograms(cfg.CEL.Expressions, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ConfigMap %s/%s: %w", namespace, configMap.Name, err)
+	}
+	// The tenant expressions are applied after the global ones, whose
+	// resource amounts are kept
+	return cel.NewCELMutator(programs, cel.WithFollowUp()), nil
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+)
+
+var _ = Describe("Tenant overrides", func() {
+	var (
+		c         client.Client
+		overrides *TenantOverrides
+		plr       *tektondevv1.PipelineRun
+	)
+
+	newConfigMap := func(namespace, data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: config.TenantOverridesConfigMap},
+			Data:       map[string]string{TenantOverridesKey: data},
+		}
+	}
+	newClient := func(funcs interceptor.Funcs, objs ...client.Object) client.Client {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		return fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithInterceptorFuncs(funcs).
+			Build()
+	}
+	newOverrides := func(policy config.TenantOverrides) *TenantOverrides {
+		overrides, err := NewTenantOverrides(c, policy)
+		Expect(err).NotTo(HaveOccurred())
+		return overrides
+	}
+	admit := func(ctx context.Context) error {
+		programs, err := cel.CompileCELPrograms([]string{`[priority("default"), annotation("team", "global")]`})
+		Expect(err).NotTo(HaveOccurred())
+		defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "pipelines-queue"},
+			[]PipelineRunMutator{cel.NewCELMutator(programs)}, WithTenantOverrides(overrides))
+		Expect(err).NotTo(HaveOccurred())
+		return defaulter.Default(ctx, plr)
+	}
+
+	BeforeEach(func() {
+		c = newClient(interceptor.Funcs{},
+			newConfigMap("tenant", `
+cel:
+  expressions:
+    - 'annotation("tenant.tenants.acme.com/cost-center", "42")'
+`),
+			newConfigMap("invalid", "cel: [not, a, map]"),
+			newConfigMap("not-compiling", `
+cel:
+  expressions:
+    - 'annotation("tenant.tenants.acme.com/cost-center", 42'
+`),
+		)
+		overrides = newOverrides(config.TenantOverrides{
+			Enabled:            true,
+			AllowedKeyPrefixes: []string{"{namespace}.tenants.acme.com/"},
+		})
+		plr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "tenant"},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "test-pipeline"},
+			},
+		}
+	})
+
+	It("applies the expressions of the tenant after the global ones", func(ctx context.Context) {
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Labels).To(HaveKeyWithValue(kueueconstants.WorkloadPriorityClassLabel, "default"))
+		Expect(plr.Annotations).To(HaveKeyWithValue("team", "global"))
+		Expect(plr.Annotations).To(HaveKeyWithValue("tenant.tenants.acme.com/cost-center", "42"))
+	})
+
+	DescribeTable("keeps the resource requests of the global expressions",
+		func(ctx context.Context, tenantExpression, expectedCPU, expectedApplied string) {
+			overrides = newOverrides(config.TenantOverrides{
+				Enabled:            true,
+				AllowedTypes:       []string{"annotation", "resource"},
+				AllowedKeyPrefixes: []string{"{namespace}.tenants.acme.com/"},
+			})
+			Expect(c.Update(ctx, newConfigMap("tenant", "cel:\n  expressions:\n    - '"+tenantExpression+"'\n"))).
+				To(Succeed())
+			programs, err := cel.CompileCELPrograms([]string{`resource("cpu", 500)`})
+			Expect(err).NotTo(HaveOccurred())
+			defaulter, err := NewCustomDefaulter(&config.Config{QueueName: "pipelines-queue"},
+				[]PipelineRunMutator{cel.NewCELMutator(programs)}, WithTenantOverrides(overrides))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).To(HaveKeyWithValue("kueue.konflux-ci.dev/requests-cpu", expectedCPU))
+			Expect(plr.Annotations).To(HaveKeyWithValue(cel.AnnotationAppliedResources, expectedApplied))
+
+			// The webhook may be invoked again with the mutated PipelineRun
+			Expect(defaulter.Default(ctx, plr)).To(Succeed())
+			Expect(plr.Annotations).To(HaveKeyWithValue("kueue.konflux-ci.dev/requests-cpu", expectedCPU))
+			Expect(plr.Annotations).To(HaveKeyWithValue(cel.AnnotationAppliedResources, expectedApplied))
+		},
+		Entry("with a tenant annotation", `annotation("tenant.tenants.acme.com/team", "a")`,
+			"500", `{"kueue.konflux-ci.dev/requests-cpu":500}`),
+		Entry("with a tenant resource", `resource("cpu", 250)`,
+			"750", `{"kueue.konflux-ci.dev/requests-cpu":750}`),
+	)
+
+	It("only applies the global expressions in the namespaces without overrides", func(ctx context.Context) {
+		plr.Namespace = "other"
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Labels).To(HaveKeyWithValue(kueueconstants.WorkloadPriorityClassLabel, "default"))
+		Expect(plr.Annotations).To(Equal(map[string]string{"team": "global"}))
+	})
+
+	DescribeTable("skips the tenant expressions denied by the policy",
+		func(ctx context.Context, expression string) {
+			Expect(c.Update(ctx, newConfigMap("tenant", "cel:\n  expressions:\n    - '"+expression+"'\n"))).To(Succeed())
+			Expect(admit(ctx)).To(Succeed())
+			Expect(plr.Labels).To(HaveKeyWithValue(kueueconstants.WorkloadPriorityClassLabel, "default"))
+			Expect(plr.Annotations).To(Equal(map[string]string{"team": "global"}))
+		},
+		Entry("priority", `priority("high")`),
+		Entry("label", `label("tenant.tenants.acme.com/team", "a")`),
+		Entry("annotation of another tenant", `annotation("other.tenants.acme.com/team", "a")`),
+		Entry("annotation without prefix", `annotation("team", "tenant")`),
+		Entry("resource", `[annotation("tenant.tenants.acme.com/team", "a"), resource("cpu", 2)]`),
+	)
+
+	It("allows the configured mutation types", func(ctx context.Context) {
+		overrides = newOverrides(config.TenantOverrides{Enabled: true, AllowedTypes: []string{"annotation", "label"}})
+		Expect(c.Update(ctx, newConfigMap("tenant", "cel:\n  expressions:\n    - 'label(\"team\", \"tenant\")'\n"))).
+			To(Succeed())
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Labels).To(HaveKeyWithValue("team", "tenant"))
+	})
+
+	It("compiles the expressions again once the ConfigMap changes", func(ctx context.Context) {
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Annotations).To(HaveKeyWithValue("tenant.tenants.acme.com/cost-center", "42"))
+
+		Expect(c.Update(ctx, newConfigMap("tenant", `
+cel:
+  expressions:
+    - 'annotation("tenant.tenants.acme.com/cost-center", "43")'
+`))).To(Succeed())
+		plr.Annotations = nil
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Annotations).To(HaveKeyWithValue("tenant.tenants.acme.com/cost-center", "43"))
+
+		Expect(c.Delete(ctx, newConfigMap("tenant", ""))).To(Succeed())
+		plr.Annotations = nil
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Annotations).To(Equal(map[string]string{"team": "global"}))
+		Expect(overrides.sets).NotTo(HaveKey("tenant"))
+	})
+
+	DescribeTable("applies the global expressions when the overrides are invalid",
+		func(ctx context.Context, namespace string) {
+			plr.Namespace = namespace
+			Expect(admit(ctx)).To(Succeed())
+			Expect(plr.Labels).To(HaveKeyWithValue(kueueconstants.WorkloadPriorityClassLabel, "default"))
+			Expect(plr.Annotations).To(Equal(map[string]string{"team": "global"}))
+			Expect(overrides.sets[namespace].err).To(HaveOccurred())
+		},
+		Entry("invalid YAML", "invalid"),
+		Entry("expression not compiling", "not-compiling"),
+	)
+
+	It("applies the global expressions when the ConfigMap can't be read", func(ctx context.Context) {
+		c = newClient(interceptor.Funcs{
+			Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+				return errors.New("connection refused")
+			},
+		})
+		overrides = newOverrides(config.TenantOverrides{Enabled: true})
+		Expect(admit(ctx)).To(Succeed())
+		Expect(plr.Annotations).To(Equal(map[string]string{"team": "global"}))
+	})
+
+	It("rejects unknown mutation types", func() {
+		_, err := NewTenantOverrides(c, config.TenantOverrides{AllowedTypes: []string{"annotations"}})
+		Expect(err).To(MatchError(ContainSubstring(`invalid tenantOverrides allowedTypes type "annotations"`)))
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+)
+
+// +kubebuilder:webhook:path=/validate-tekton-dev-v1-pipelinerun,mutating=false,failurePolicy=ignore,sideEffects=None,groups=tekton.dev,resources=pipelineruns,verbs=update,versions=v1,name=pipelinerun-update-validation.tekton-kueue.io,admissionReviewVersions=v1
+
+// SetupPipelineRunValidatorWebhookWithManager registers the webhook
+// validating the updates and the deletions of the PipelineRuns in the
+// manager.
+func SetupPipelineRunValidatorWebhookWithManager(mgr ctrl.Manager, updates *UpdateValidator, deletions *DeletionProtector) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&tekv1.PipelineRun{}).
+		WithValidator(&pipelineRunValidator{updates: updates, deletions: deletions}).
+		WithLogConstructor(logConstructor).
+		Complete()
+}
+
+// pipelineRunValidator serves the validations of the updates and the
+// deletions, which share the path of the webhook.
+type pipelineRunValidator struct {
+	updates   *UpdateValidator
+	deletions *DeletionProtector
+}
+
+var _ webhook.CustomValidator = &pipelineRunValidator{}
+
+func (v *pipelineRunValidator) ValidateCreate(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *pipelineRunValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.updates.ValidateUpdate(ctx, oldObj, newObj)
+}
+
+func (v *pipelineRunValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.deletions.ValidateDelete(ctx, obj)
+}
+
+// UpdateValidator denies the updates of the PipelineRuns for which a CEL
+// validation expression returns false, see cel.CompileValidationPrograms.
+type UpdateValidator struct {
+	programs []*cel.ValidationProgram
+}
+
+var _ webhook.CustomValidator = &UpdateValidator{}
+
+// NewUpdateValidator returns the validator evaluating the programs. All the
+// updates are allowed when there's no program.
+func NewUpdateValidator(programs []*cel.ValidationProgram) *UpdateValidator {
+	return &UpdateValidator{programs: programs}
+}
+
+// ValidateCreate implements webhook.CustomValidator, the creations aren't
+// validated.
+func (v *UpdateValidator) ValidateCreate(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator. It denies the update
+// with the messages of the validations returning false. The updates of the
+// PipelineRuns being deleted, e.g. the removal of their finalizers, are
+// allowed, like the updates whose validation fails to be evaluated.
+func (v *UpdateValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if len(v.programs) == 0 {
+		return nil, nil
+	}
+	plr, ok := newObj.(*tekv1.PipelineRun)
+	if !ok {
+		return nil, k8serrors.NewBadRequest(fmt.Sprintf("expected a PipelineRun object but got %T", newObj))
+	}
+	oldPlr, ok := oldObj.(*tekv1.PipelineRun)
+	if !ok {
+		return nil, k8serrors.NewBadRequest(fmt.Sprintf("expected a PipelineRun object but got %T", oldObj))
+	}
+	if !plr.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	log := ctrl.LoggerFrom(ctx)
+
+	results, err := cel.EvaluateValidations(ctx, v.programs, plr, oldPlr)
+	if err != nil {
+		// The update isn't blocked by the failures of the webhook
+		log.Error(err, "Allowing the update, unable to evaluate the validations")
+		return nil, nil
+	}
+	var denials []string
+	for _, result := range results {
+		if !result.Allowed {
+			denials = append(denials, result.Message)
+		}
+	}
+	if len(denials) == 0 {
+		return nil, nil
+	}
+	log.V(1).Info("Denying the update of the PipelineRun", "denials", denials)
+	return nil, k8serrors.NewForbidden(tekv1.Resource("pipelineruns"), plr.Name,
+		errors.New(strings.Join(denials, "; ")))
+}
+
+// ValidateDelete implements webhook.CustomValidator, the deletions aren't
+// validated.
+func (v *UpdateValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektondevv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/common"
+)
+
+var _ = Describe("Update validation", func() {
+	var oldPlr *tektondevv1.PipelineRun
+
+	const keepQueueLabel = `check(!("kueue.x-k8s.io/queue-name" in oldPipelineRun.metadata.labels) ||
+		"kueue.x-k8s.io/queue-name" in pipelineRun.metadata.labels,
+		"the kueue.x-k8s.io/queue-name label can't be removed")`
+
+	newValidator := func(expressions ...string) *UpdateValidator {
+		programs, err := cel.CompileValidationPrograms(expressions)
+		Expect(err).NotTo(HaveOccurred())
+		return NewUpdateValidator(programs)
+	}
+
+	BeforeEach(func() {
+		oldPlr = &tektondevv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "build", Namespace: "tenant",
+				Labels: map[string]string{common.QueueLabel: "pipelines-queue"},
+			},
+			Spec: tektondevv1.PipelineRunSpec{
+				PipelineRef: &tektondevv1.PipelineRef{Name: "build"},
+				Status:      tektondevv1.PipelineRunSpecStatusPending,
+			},
+		}
+	})
+
+	It("denies the removal of the queue label", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+		delete(plr.Labels, common.QueueLabel)
+
+		_, err := newValidator(keepQueueLabel).ValidateUpdate(ctx, oldPlr, plr)
+		Expect(k8serrors.IsForbidden(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("the kueue.x-k8s.io/queue-name label can't be removed")))
+	})
+
+	It("allows the unrelated edits", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+		plr.Labels["app"] = "web"
+		plr.Spec.Status = ""
+
+		_, err := newValidator(keepQueueLabel).ValidateUpdate(ctx, oldPlr, plr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("joins the messages of the denials", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+		delete(plr.Labels, common.QueueLabel)
+
+		_, err := newValidator(keepQueueLabel, "true", `pipelineRun.metadata.labels.size() > 0`).
+			ValidateUpdate(ctx, oldPlr, plr)
+		Expect(err).To(MatchError(ContainSubstring(
+			`the kueue.x-k8s.io/queue-name label can't be removed; ` +
+				`the update is denied by the validation expression "pipelineRun.metadata.labels.size() > 0"`)))
+	})
+
+	It("allows the updates of the PipelineRuns being deleted", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+		delete(plr.Labels, common.QueueLabel)
+		plr.DeletionTimestamp = ptr.To(metav1.Now())
+
+		_, err := newValidator(keepQueueLabel).ValidateUpdate(ctx, oldPlr, plr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("allows the updates whose validation can't be evaluated", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+
+		_, err := newValidator(`pipelineRun.metadata.labels["missing"] == ""`).ValidateUpdate(ctx, oldPlr, plr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("allows all the updates without validations", func(ctx context.Context) {
+		plr := oldPlr.DeepCopy()
+		delete(plr.Labels, common.QueueLabel)
+
+		_, err := NewUpdateValidator(nil).ValidateUpdate(ctx, oldPlr, plr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("validates the updates and the deletions on the same path", func(ctx context.Context) {
+		protector, err := NewDeletionProtector(nil, "", nil)
+		Expect(err).NotTo(HaveOccurred())
+		validator := &pipelineRunValidator{updates: newValidator(keepQueueLabel), deletions: protector}
+
+		plr := oldPlr.DeepCopy()
+		delete(plr.Labels, common.QueueLabel)
+		_, err = validator.ValidateUpdate(ctx, oldPlr, plr)
+		Expect(k8serrors.IsForbidden(err)).To(BeTrue())
+		_, err = validator.ValidateDelete(ctx, oldPlr)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -0,0 +1,182 @@
+// Package celtest helps testing CEL expressions and webhooks embedding the
+// CEL mutator: it builds fixture PipelineRuns, and checks the labels and
+// annotations they end up with. It only depends on the standard testing
+// package, so it can be used without Ginkgo or Gomega.
+//
+//	pipelineRun := celtest.NewPipelineRun(
+//		celtest.WithLabels(map[string]string{"pipelinesascode.tekton.dev/event-type": "push"}),
+//		celtest.WithPipelineSpec(celtest.PlatformTask("build-arm64", "linux/arm64")),
+//	)
+//	// mutate the PipelineRun
+//	celtest.AssertMutations(t, pipelineRun, wantLabels, wantAnnotations)
+package celtest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultName is the name of the PipelineRuns, and of the Pipeline they
+	// reference, unless set with WithName.
+	DefaultName = "test-pipeline"
+	// DefaultNamespace is the namespace of the PipelineRuns, unless set
+	// with WithNamespace.
+	DefaultNamespace = "test-namespace"
+)
+
+// Option customizes the PipelineRun built by NewPipelineRun.
+type Option func(*tekv1.PipelineRun)
+
+// NewPipelineRun returns a PipelineRun named DefaultName in
+// DefaultNamespace, referencing the Pipeline DefaultName, customized by
+// opts in order.
+func NewPipelineRun(opts ...Option) *tekv1.PipelineRun {
+	pipelineRun := &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultName,
+			Namespace: DefaultNamespace,
+		},
+		Spec: tekv1.PipelineRunSpec{
+			PipelineRef: &tekv1.PipelineRef{Name: DefaultName},
+		},
+	}
+	for _, opt := range opts {
+		opt(pipelineRun)
+	}
+	return pipelineRun
+}
+
+// WithName sets the name of the PipelineRun.
+func WithName(name string) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Name = name
+	}
+}
+
+// WithNamespace sets the namespace of the PipelineRun.
+func WithNamespace(namespace string) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Namespace = namespace
+	}
+}
+
+// WithLabels adds labels to the PipelineRun. The map isn't retained, and
+// a nil or empty map leaves the labels unset.
+func WithLabels(labels map[string]string) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Labels = merge(pipelineRun.Labels, labels)
+	}
+}
+
+// WithAnnotations adds annotations to the PipelineRun. The map isn't
+// retained, and a nil or empty map leaves the annotations unset.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Annotations = merge(pipelineRun.Annotations, annotations)
+	}
+}
+
+// WithParams appends params to the PipelineRun.
+func WithParams(params ...tekv1.Param) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Spec.Params = append(pipelineRun.Spec.Params, params...)
+	}
+}
+
+// WithPipelineSpec replaces the Pipeline reference with an embedded
+// Pipeline made of tasks, which can be empty.
+func WithPipelineSpec(tasks ...tekv1.PipelineTask) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Spec.PipelineRef = nil
+		pipelineRun.Spec.PipelineSpec = &tekv1.PipelineSpec{Tasks: tasks}
+	}
+}
+
+// WithTimeouts sets the timeouts of the PipelineRun.
+func WithTimeouts(timeouts *tekv1.TimeoutFields) Option {
+	return func(pipelineRun *tekv1.PipelineRun) {
+		pipelineRun.Spec.Timeouts = timeouts
+	}
+}
+
+// StringParam returns a string param.
+func StringParam(name, value string) tekv1.Param {
+	return tekv1.Param{
+		Name:  name,
+		Value: tekv1.ParamValue{Type: tekv1.ParamTypeString, StringVal: value},
+	}
+}
+
+// ArrayParam returns an array param, like the build-platforms param of the
+// multi-platform builds.
+func ArrayParam(name string, values ...string) tekv1.Param {
+	return tekv1.Param{
+		Name:  name,
+		Value: tekv1.ParamValue{Type: tekv1.ParamTypeArray, ArrayVal: values},
+	}
+}
+
+// Task returns a pipeline task with params, to be embedded with
+// WithPipelineSpec.
+func Task(name string, params ...tekv1.Param) tekv1.PipelineTask {
+	return tekv1.PipelineTask{Name: name, Params: params}
+}
+
+// PlatformTask returns a pipeline task with the PLATFORM param of the
+// multi-platform builds.
+func PlatformTask(name, platform string) tekv1.PipelineTask {
+	return Task(name, StringParam("PLATFORM", platform))
+}
+
+// AssertMutations reports an error for each label and annotation of the
+// PipelineRun differing from wantLabels and wantAnnotations. A nil map is
+// the same as an empty one.
+func AssertMutations(t testing.TB, pipelineRun *tekv1.PipelineRun, wantLabels, wantAnnotations map[string]string) {
+	t.Helper()
+	if pipelineRun == nil {
+		t.Fatal("the PipelineRun is nil")
+	}
+	for _, diff := range diffMaps(pipelineRun.Labels, wantLabels) {
+		t.Errorf("label %s", diff)
+	}
+	for _, diff := range diffMaps(pipelineRun.Annotations, wantAnnotations) {
+		t.Errorf("annotation %s", diff)
+	}
+}
+
+// diffMaps describes the differences between got and want, sorted by key.
+func diffMaps(got, want map[string]string) []string {
+	keys := slices.Sorted(maps.Keys(merge(maps.Clone(got), want)))
+	var diffs []string
+	for _, key := range keys {
+		gotValue, gotOK := got[key]
+		wantValue, wantOK := want[key]
+		switch {
+		case !gotOK:
+			diffs = append(diffs, fmt.Sprintf("%q is missing, want %q", key, wantValue))
+		case !wantOK:
+			diffs = append(diffs, fmt.Sprintf("%q is unexpected, got %q", key, gotValue))
+		case gotValue != wantValue:
+			diffs = append(diffs, fmt.Sprintf("%q is %q, want %q", key, gotValue, wantValue))
+		}
+	}
+	return diffs
+}
+
+// merge copies src into dst, allocating dst when src isn't empty.
+func merge(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	maps.Copy(dst, src)
+	return dst
+}
@@ -0,0 +1,87 @@
+package celtest
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// recorder records the errors reported by the assertions.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNewPipelineRun(t *testing.T) {
+	pipelineRun := NewPipelineRun()
+	if pipelineRun.Name != DefaultName || pipelineRun.Namespace != DefaultNamespace {
+		t.Errorf("got %s/%s, want %s/%s", pipelineRun.Namespace, pipelineRun.Name, DefaultNamespace, DefaultName)
+	}
+	if pipelineRun.Spec.PipelineRef == nil || pipelineRun.Spec.PipelineRef.Name != DefaultName {
+		t.Errorf("got the Pipeline reference %v, want %s", pipelineRun.Spec.PipelineRef, DefaultName)
+	}
+	if pipelineRun.Labels != nil || pipelineRun.Annotations != nil {
+		t.Errorf("got the labels %v and the annotations %v, want none", pipelineRun.Labels, pipelineRun.Annotations)
+	}
+
+	labels := map[string]string{"env": "prod"}
+	pipelineRun = NewPipelineRun(
+		WithNamespace("mintmaker"),
+		WithLabels(labels),
+		WithLabels(map[string]string{"team": "a"}),
+		WithAnnotations(nil),
+		WithParams(ArrayParam("build-platforms", "linux/arm64", "linux/amd64")),
+		WithPipelineSpec(PlatformTask("build-arm64", "linux/arm64"), Task("cleanup")),
+	)
+	labels["env"] = "changed"
+
+	if pipelineRun.Namespace != "mintmaker" {
+		t.Errorf("got the namespace %s, want mintmaker", pipelineRun.Namespace)
+	}
+	if pipelineRun.Labels["env"] != "prod" || pipelineRun.Labels["team"] != "a" {
+		t.Errorf("got the labels %v, want env=prod and team=a", pipelineRun.Labels)
+	}
+	if pipelineRun.Annotations != nil {
+		t.Errorf("got the annotations %v, want none", pipelineRun.Annotations)
+	}
+	if got := pipelineRun.Spec.Params[0].Value.ArrayVal; !slices.Equal(got, []string{"linux/arm64", "linux/amd64"}) {
+		t.Errorf("got the build-platforms %v", got)
+	}
+	if pipelineRun.Spec.PipelineRef != nil {
+		t.Errorf("got the Pipeline reference %v, want an embedded Pipeline", pipelineRun.Spec.PipelineRef)
+	}
+	tasks := pipelineRun.Spec.PipelineSpec.Tasks
+	if len(tasks) != 2 || tasks[0].Params[0].Name != "PLATFORM" || tasks[0].Params[0].Value.StringVal != "linux/arm64" {
+		t.Errorf("got the tasks %v", tasks)
+	}
+}
+
+func TestAssertMutations(t *testing.T) {
+	pipelineRun := NewPipelineRun(
+		WithLabels(map[string]string{"env": "prod", "team": "a"}),
+	)
+
+	r := &recorder{TB: t}
+	AssertMutations(r, pipelineRun, map[string]string{"env": "prod", "team": "a"}, map[string]string{})
+	if len(r.errors) != 0 {
+		t.Errorf("got the errors %v, want none", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	AssertMutations(r, pipelineRun, map[string]string{"env": "dev", "queue": "default"}, map[string]string{"owner": "a"})
+	want := []string{
+		`label "env" is "prod", want "dev"`,
+		`label "queue" is missing, want "default"`,
+		`label "team" is unexpected, got "a"`,
+		`annotation "owner" is missing, want "a"`,
+	}
+	if !slices.Equal(r.errors, want) {
+		t.Errorf("got the errors %q, want %q", r.errors, want)
+	}
+}
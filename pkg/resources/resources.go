@@ -0,0 +1,87 @@
+// Package resources implements the annotation scheme the PipelineRuns use
+// to request resources from Kueue: each kueue.konflux-ci.dev/requests-<name>
+// annotation requests the quantity of its value of the resource <name>.
+//
+//	requests, errs := resources.ParseResourceAnnotations(pipelineRun.Annotations)
+//	for _, err := range errs {
+//		// report the invalid annotation
+//	}
+//	cpu := requests[corev1.ResourceCPU]
+package resources
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// AnnotationPrefix is the prefix of the annotations requesting resources,
+// followed by the name of the resource.
+const AnnotationPrefix = "kueue.konflux-ci.dev/requests-"
+
+// AnnotationKeyFor returns the key of the annotation requesting the
+// resource.
+func AnnotationKeyFor(resourceName string) string {
+	return AnnotationPrefix + resourceName
+}
+
+// AnnotationError reports a resource annotation which can't be parsed.
+type AnnotationError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *AnnotationError) Error() string {
+	return fmt.Sprintf("invalid resource annotation %s=%q: %v", e.Key, e.Value, e.Err)
+}
+
+func (e *AnnotationError) Unwrap() error {
+	return e.Err
+}
+
+// ParseResourceAnnotations returns the resources requested by the
+// annotations with AnnotationPrefix. The annotations which can't be parsed
+// are skipped, and reported by an *AnnotationError each, sorted by key.
+func ParseResourceAnnotations(annotations map[string]string) (corev1.ResourceList, []error) {
+	return ParsePrefixedResourceAnnotations(annotations, AnnotationPrefix)
+}
+
+// ParsePrefixedResourceAnnotations is like ParseResourceAnnotations for the
+// annotations of several prefixes, by decreasing precedence, e.g. while the
+// annotations are migrated to a new prefix. The valid annotation of the
+// prefix with the highest precedence wins when several of them request the
+// same resource.
+func ParsePrefixedResourceAnnotations(annotations map[string]string, prefixes ...string) (corev1.ResourceList, []error) {
+	requests := corev1.ResourceList{}
+	var errs []error
+	// Walk the prefixes from the lowest precedence, so the highest one
+	// overwrites the others
+	for _, prefix := range slices.Backward(prefixes) {
+		for _, key := range slices.Sorted(maps.Keys(annotations)) {
+			name, ok := strings.CutPrefix(key, prefix)
+			if !ok {
+				continue
+			}
+			value := annotations[key]
+			if name == "" {
+				errs = append(errs, &AnnotationError{Key: key, Value: value, Err: fmt.Errorf("the resource name is empty")})
+				continue
+			}
+			quantity, err := resource.ParseQuantity(value)
+			if err != nil {
+				errs = append(errs, &AnnotationError{Key: key, Value: value, Err: err})
+				continue
+			}
+			requests[corev1.ResourceName(name)] = quantity
+		}
+	}
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.(*AnnotationError).Key, b.(*AnnotationError).Key)
+	})
+	return requests, errs
+}
@@ -0,0 +1,80 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestAnnotationKeyFor(t *testing.T) {
+	if got := AnnotationKeyFor("cpu"); got != "kueue.konflux-ci.dev/requests-cpu" {
+		t.Errorf("got %q, want kueue.konflux-ci.dev/requests-cpu", got)
+	}
+}
+
+func TestParseResourceAnnotations(t *testing.T) {
+	requests, errs := ParseResourceAnnotations(map[string]string{
+		AnnotationKeyFor("cpu"):         "500m",
+		AnnotationKeyFor("linux-arm64"): "2",
+		AnnotationKeyFor("memory"):      "lots",
+		AnnotationPrefix:                "1",
+		"kueue.konflux-ci.dev/priority": "high",
+	})
+
+	want := corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("500m"),
+		"linux-arm64":      resource.MustParse("2"),
+	}
+	if !equality.Semantic.DeepEqual(requests, want) {
+		t.Errorf("got the requests %v, want %v", requests, want)
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("got the errors %v, want 2", errs)
+	}
+	wantKeys := []string{AnnotationPrefix, AnnotationKeyFor("memory")}
+	for i, err := range errs {
+		var annotationErr *AnnotationError
+		if !errors.As(err, &annotationErr) {
+			t.Fatalf("got the error %v, want an *AnnotationError", err)
+		}
+		if annotationErr.Key != wantKeys[i] {
+			t.Errorf("got the key %q for error %d, want %q", annotationErr.Key, i, wantKeys[i])
+		}
+	}
+	if !errors.Is(errs[1], resource.ErrFormatWrong) {
+		t.Errorf("got the error %v, want it to wrap %v", errs[1], resource.ErrFormatWrong)
+	}
+}
+
+func TestParseResourceAnnotations_Empty(t *testing.T) {
+	requests, errs := ParseResourceAnnotations(nil)
+	if len(requests) != 0 || len(errs) != 0 {
+		t.Errorf("got the requests %v and the errors %v, want none", requests, errs)
+	}
+}
+
+func TestParsePrefixedResourceAnnotations(t *testing.T) {
+	const newPrefix = "queue.konflux.dev/requests-"
+	annotations := map[string]string{
+		AnnotationKeyFor("cpu"):    "1",
+		newPrefix + "cpu":          "2",
+		AnnotationKeyFor("memory"): "1Gi",
+		newPrefix + "memory":       "invalid",
+	}
+
+	requests, errs := ParsePrefixedResourceAnnotations(annotations, newPrefix, AnnotationPrefix)
+	want := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}
+	if !equality.Semantic.DeepEqual(requests, want) {
+		t.Errorf("got the requests %v, want %v", requests, want)
+	}
+	if len(errs) != 1 || errs[0].(*AnnotationError).Key != newPrefix+"memory" {
+		t.Errorf("got the errors %v, want the one of %smemory", errs, newPrefix)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/konflux-ci/tekton-queue/test/utils"
+)
+
+const (
+	// webhookServiceAccount is the service account of the webhook, allowed
+	// to read its metrics by the configReloadRoleBindingName binding
+	webhookServiceAccount = "tekton-kueue-webhook"
+	// configReloadRoleBindingName is the name of the binding allowing to
+	// read the metrics of the webhook in the configuration reload scenarios
+	configReloadRoleBindingName = "tekton-kueue-config-reload-metrics-binding"
+)
+
+// scrapes numbers the pods scraping the metrics of the webhook.
+var scrapes atomic.Int64
+
+// setCELExpressions replaces the CEL expressions in the ConfigMap deployed
+// by `make deploy`, and returns the previous configuration. The kubelet
+// updates the mounted configuration within about a minute, and the webhook
+// reads it every 10 seconds.
+func setCELExpressions(ctx context.Context, c client.Client, expressions ...string) (string, error) {
+	cm, err := configMap(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	previous := cm.Data["config.yaml"]
+	cfg := map[string]any{}
+	if err := yaml.Unmarshal([]byte(previous), &cfg); err != nil {
+		return "", err
+	}
+	cel, _ := cfg["cel"].(map[string]any)
+	if cel == nil {
+		cel = map[string]any{}
+	}
+	cel["expressions"] = expressions
+	cfg["cel"] = cel
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	cm.Data["config.yaml"] = string(data)
+	return previous, c.Update(ctx, cm)
+}
+
+// restoreConfig restores the configuration returned by setCELExpressions.
+func restoreConfig(ctx context.Context, c client.Client, content string) error {
+	cm, err := configMap(ctx, c)
+	if err != nil {
+		return err
+	}
+	cm.Data["config.yaml"] = content
+	return c.Update(ctx, cm)
+}
+
+// bindWebhookMetricsReader allows the service account of the webhook to read
+// its metrics, and returns a function deleting the binding.
+func bindWebhookMetricsReader() (func(), error) {
+	cmd := exec.Command("kubectl", "create", "clusterrolebinding", "--dry-run=client", "-o", "yaml",
+		configReloadRoleBindingName,
+		"--clusterrole=tekton-kueue-metrics-reader",
+		fmt.Sprintf("--serviceaccount=%s:%s", namespace, webhookServiceAccount),
+	)
+	crb, err := utils.Run(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cmd = exec.Command("kubectl", "apply", "-f", "-")
+	cmd.Stdin = strings.NewReader(crb)
+	if _, err := utils.Run(cmd); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd := exec.Command("kubectl", "delete", "clusterrolebinding", configReloadRoleBindingName, "--ignore-not-found")
+		_, _ = utils.Run(cmd)
+	}, nil
+}
+
+// webhookMetric scrapes the metrics of the webhook from a curl pod, and
+// returns the value of the metric, the name including its labels, or zero
+// when it isn't exposed yet.
+func webhookMetric(g Gomega, name string) float64 {
+	token, err := serviceAccountToken(webhookServiceAccount)
+	g.Expect(err).NotTo(HaveOccurred())
+	podName := fmt.Sprintf("curl-config-reload-%d", scrapes.Add(1))
+	defer func() {
+		cmd := exec.Command("kubectl", "delete", "pod", podName, "-n", namespace, "--wait=false")
+		_, _ = utils.Run(cmd)
+	}()
+	cmd := exec.Command("kubectl", "run", podName, "--restart=Never",
+		"--namespace", namespace,
+		"--image=curlimages/curl:latest",
+		"--overrides",
+		fmt.Sprintf(`{
+			"spec": {
+				"containers": [{
+					"name": "curl",
+					"image": "curlimages/curl:latest",
+					"command": ["/bin/sh", "-c"],
+					"args": ["curl -s -f -k -H 'Authorization: Bearer %s' https://tekton-kueue-webhook-service.%s.svc.cluster.local:8443/metrics"],
+					"securityContext": {
+						"allowPrivilegeEscalation": false,
+						"capabilities": {
+							"drop": ["ALL"]
+						},
+						"runAsNonRoot": true,
+						"runAsUser": 1000,
+						"seccompProfile": {
+							"type": "RuntimeDefault"
+						}
+					}
+				}],
+				"serviceAccount": "%s"
+			}
+		}`, token, namespace, webhookServiceAccount))
+	_, err = utils.Run(cmd)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Eventually(func(g Gomega) {
+		cmd := exec.Command("kubectl", "get", "pods", podName, "-o", "jsonpath={.status.phase}", "-n", namespace)
+		output, err := utils.Run(cmd)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(output).To(Equal("Succeeded"), "curl pod in wrong status")
+	}, 2*time.Minute).Should(Succeed())
+
+	cmd = exec.Command("kubectl", "logs", podName, "-n", namespace)
+	output, err := utils.Run(cmd)
+	g.Expect(err).NotTo(HaveOccurred())
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), name+" ")
+		if !found {
+			continue
+		}
+		metric, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		g.Expect(err).NotTo(HaveOccurred())
+		return metric
+	}
+	return 0
+}
+
+// errConfigMapNotFound is returned when the configuration ConfigMap isn't
+// deployed.
+var errConfigMapNotFound = errors.New("the configuration ConfigMap wasn't found")
+
+// configMap returns the configuration ConfigMap deployed by `make deploy`.
+func configMap(ctx context.Context, c client.Client) (*corev1.ConfigMap, error) {
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.List(ctx, configMaps, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		if _, ok := cm.Data["config.yaml"]; ok && strings.HasPrefix(cm.Name, "tekton-kueue-config") {
+			return cm, nil
+		}
+	}
+	return nil, errConfigMapNotFound
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// drainSetting drains the controller when appended to the configuration.
+const drainSetting = "controller:\n  drain: true\n"
+
+// setDrain drains the controller, or lifts the drain, in the ConfigMap
+// deployed by `make deploy`. The kubelet updates the mounted configuration
+// within about a minute, and the controller reads it every 10 seconds.
+func setDrain(ctx context.Context, c client.Client, drain bool) error {
+	cm, err := configMap(ctx, c)
+	if err != nil {
+		return err
+	}
+	content := strings.TrimSuffix(cm.Data["config.yaml"], drainSetting)
+	if drain {
+		content += drainSetting
+	}
+	cm.Data["config.yaml"] = content
+	return c.Update(ctx, cm)
+}
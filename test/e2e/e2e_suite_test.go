@@ -36,8 +36,11 @@ var (
 	// - CERT_MANAGER_INSTALL_SKIP=true: Skips CertManager installation during test setup.
 	// These variables are useful if Prometheus or CertManager is already installed, avoiding
 	// re-installation and conflicts.
-	skipPrometheusInstall  = os.Getenv("PROMETHEUS_INSTALL_SKIP") == "true"
-	skipCertManagerInstall = os.Getenv("CERT_MANAGER_INSTALL_SKIP") == "true"
+	// - RESOURCE_PREFIX_MIGRATION_MODE=<mode>: Deploys tekton-kueue migrating the resource annotation
+	// prefix in the given mode (dual-write, read-both or new-only), so the suite can run once per mode.
+	skipPrometheusInstall       = os.Getenv("PROMETHEUS_INSTALL_SKIP") == "true"
+	skipCertManagerInstall      = os.Getenv("CERT_MANAGER_INSTALL_SKIP") == "true"
+	resourcePrefixMigrationMode = os.Getenv("RESOURCE_PREFIX_MIGRATION_MODE")
 	// isPrometheusOperatorAlreadyInstalled will be set true when prometheus CRDs be found on the cluster
 	isPrometheusOperatorAlreadyInstalled = false
 	// isCertManagerAlreadyInstalled will be set true when CertManager CRDs be found on the cluster
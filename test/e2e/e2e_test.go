@@ -19,6 +19,7 @@ package e2e
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -29,6 +30,8 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/konflux-ci/tekton-queue/internal/common"
+	"github.com/konflux-ci/tekton-queue/internal/controller"
 	webhookv1 "github.com/konflux-ci/tekton-queue/internal/webhook/v1"
 	"github.com/konflux-ci/tekton-queue/test/utils"
 	corev1 "k8s.io/api/core/v1"
@@ -47,7 +50,9 @@ import (
 	kapi "knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	kueue "sigs.k8s.io/kueue/apis/kueue/v1beta1"
+	kueueconstants "sigs.k8s.io/kueue/pkg/controller/constants"
 	"sigs.k8s.io/kueue/pkg/controller/jobframework"
+	"sigs.k8s.io/yaml"
 )
 
 // namespace where the project is deployed in
@@ -112,8 +117,17 @@ var _ = Describe("Manager", Ordered, func() {
 		By("deploying the controller-manager")
 		projectImage := os.Getenv("IMG")
 		Expect(projectImage).ToNot(Equal(""), "IMG environment variable must be declared")
+		restoreConfig, err := configureResourcePrefixMigration()
+		Expect(err).NotTo(HaveOccurred(), "Failed to configure the resource annotation prefix migration")
+		restoreQueueNameValidation, err := configureQueueNameValidation()
+		Expect(err).NotTo(HaveOccurred(), "Failed to enable the queue name validation")
+		restoreManagedNamespaces, err := configureManagedNamespaces()
+		Expect(err).NotTo(HaveOccurred(), "Failed to configure the managed namespaces")
 		cmd = exec.Command("make", "deploy", fmt.Sprintf("IMG=%s", projectImage))
 		_, err = utils.Run(cmd)
+		restoreManagedNamespaces()
+		restoreQueueNameValidation()
+		restoreConfig()
 		Expect(err).NotTo(HaveOccurred(), "Failed to deploy the controller-manager")
 
 		By("Creating a k8s client")
@@ -462,13 +476,7 @@ var _ = Describe("Manager", Ordered, func() {
 		It("Starts PipelineRuns", func(ctx context.Context) {
 			for i := range plrCount {
 				plr := plrTemplate.DeepCopy()
-				Eventually(
-					func() error {
-						return k8sClient.Create(ctx, plr)
-					},
-					90*time.Second,
-					3*time.Second,
-				).Should(Succeed())
+				Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
 				plrs[i] = plr
 			}
 
@@ -500,27 +508,21 @@ var _ = Describe("Manager", Ordered, func() {
 
 		It("PipelineRuns were completed Successfully", func(ctx context.Context) {
 			for i := range plrCount {
-				key := plrs[i].GetNamespacedName()
-				plr := &tekv1.PipelineRun{}
-				Eventually(func() error {
-					err := k8sClient.Get(ctx, key, plr)
-					if err != nil {
-						return err
-					}
-					condition := plr.Status.GetCondition(kapi.ConditionSucceeded)
-					if condition == nil {
-						return fmt.Errorf("Success condition for PipelinerRun %s is nil", plr.Name)
-					}
-					success := (condition.Reason == tekv1.PipelineRunReasonSuccessful.String()) ||
-						(condition.Reason == tekv1.PipelineRunReasonCompleted.String())
-					if !success {
-						return fmt.Errorf("PipelineRun %s didn't succeed", plr.Name)
-					}
-					return nil
-				},
-					(15*plrCount)*int(time.Second),
-					3*time.Second,
-				).Should(Succeed())
+				_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(plrs[i]),
+					func(plr *tekv1.PipelineRun) error {
+						condition := plr.Status.GetCondition(kapi.ConditionSucceeded)
+						if condition == nil {
+							return fmt.Errorf("Success condition for PipelinerRun %s is nil", plr.Name)
+						}
+						success := (condition.Reason == tekv1.PipelineRunReasonSuccessful.String()) ||
+							(condition.Reason == tekv1.PipelineRunReasonCompleted.String())
+						if !success {
+							return fmt.Errorf("PipelineRun %s didn't succeed", plr.Name)
+						}
+						return nil
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
 			}
 		})
 	})
@@ -530,15 +532,9 @@ var _ = Describe("Manager", Ordered, func() {
 		It("PipelineRun is queued because lack of resources", func(ctx context.Context) {
 			plr = plrTemplate.DeepCopy()
 			plr.Annotations = map[string]string{
-				"kueue.konflux-ci.dev/requests-memory": "2Gi",
+				resourceRequestAnnotation("memory"): "2Gi",
 			}
-			Eventually(
-				func() error {
-					return k8sClient.Create(ctx, plr)
-				},
-				90*time.Second,
-				3*time.Second,
-			).Should(Succeed())
+			Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
 		})
 
 		It("Large Pipelinerun is Pending", func(ctx context.Context) {
@@ -569,13 +565,7 @@ var _ = Describe("Manager", Ordered, func() {
 			plr.Labels = map[string]string{
 				webhookv1.QueueLabel: "blocking-pipelines-queue",
 			}
-			Eventually(
-				func() error {
-					return k8sClient.Create(ctx, plr)
-				},
-				90*time.Second,
-				3*time.Second,
-			).Should(Succeed())
+			Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
 		})
 
 		It("Pipelinerun is Pending", func(ctx context.Context) {
@@ -598,8 +588,391 @@ var _ = Describe("Manager", Ordered, func() {
 			)
 		})
 	})
+
+	Context("PipelineRun with a missing queue is rejected", func() {
+		It("Rejects the PipelineRun, listing the available queues", func(ctx context.Context) {
+			Eventually(func(g Gomega) {
+				plr := plrTemplate.DeepCopy()
+				plr.Labels = map[string]string{
+					webhookv1.QueueLabel: "missing-pipelines-queue",
+				}
+				err := k8sClient.Create(ctx, plr)
+				if err == nil {
+					// Admitted before the LocalQueues were synced
+					_ = k8sClient.Delete(ctx, plr)
+				}
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(`LocalQueue "missing-pipelines-queue"`))
+				g.Expect(err.Error()).To(ContainSubstring(
+					"the available LocalQueues are: blocking-pipelines-queue, pipelines-queue"))
+			}).Should(Succeed())
+		})
+	})
+
+	Context("PipelineRun in an unmanaged namespace", func() {
+		It("Runs immediately, without being queued", func(ctx context.Context) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: unmanagedNamespace}}
+			Expect(k8sClient.Create(ctx, ns)).To(Satisfy(func(err error) bool {
+				return err == nil || kerrors.IsAlreadyExists(err)
+			}))
+			DeferCleanup(func(ctx context.Context) {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, ns))).To(Succeed())
+			})
+
+			plr := plrTemplate.DeepCopy()
+			plr.Namespace = unmanagedNamespace
+			// The namespaces may not be cached yet by the webhook, which
+			// then queues the PipelineRuns of all the namespaces
+			Eventually(func(g Gomega) {
+				created := plr.DeepCopy()
+				g.Expect(k8sClient.Create(ctx, created)).To(Succeed())
+				if created.Spec.Status != "" || created.Labels[webhookv1.QueueLabel] != "" {
+					_ = k8sClient.Delete(ctx, created)
+				}
+				g.Expect(created.Spec.Status).To(BeEmpty())
+				g.Expect(created.Labels).NotTo(HaveKey(webhookv1.QueueLabel))
+				plr = created
+			}).Should(Succeed())
+
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(plr),
+				func(plr *tekv1.PipelineRun) error {
+					if !plr.IsSuccessful() {
+						return errors.New("PipelineRun didn't succeed")
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+			workloads := &kueue.WorkloadList{}
+			Expect(k8sClient.List(ctx, workloads, client.InNamespace(unmanagedNamespace))).To(Succeed())
+			Expect(workloads.Items).To(BeEmpty())
+		})
+	})
+
+	Context("PipelineRun created during a drain", Ordered, func() {
+		// The configuration is reloaded by the kubelet, then the controller
+		const drainTimeout = 3 * time.Minute
+		var plr *tekv1.PipelineRun
+
+		AfterAll(func(ctx context.Context) {
+			Expect(setDrain(ctx, k8sClient, false)).To(Succeed())
+		})
+
+		It("Drains the controller", func(ctx context.Context) {
+			Expect(setDrain(ctx, k8sClient, true)).To(Succeed())
+
+			// The pending Workloads are deactivated once the controller
+			// drains
+			probe := plrTemplate.DeepCopy()
+			probe.Labels = map[string]string{webhookv1.QueueLabel: "blocking-pipelines-queue"}
+			Expect(utils.CreateAndWait(ctx, k8sClient, probe)).To(Succeed())
+			Eventually(func(g Gomega) {
+				wl, err := GetOwnedWorkload(k8sClient, probe, ctx)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(wl.Annotations).To(HaveKey(controller.AnnotationDrained))
+			}, drainTimeout, 5*time.Second).Should(Succeed())
+		})
+
+		It("Doesn't start the new PipelineRuns", func(ctx context.Context) {
+			plr = plrTemplate.DeepCopy()
+			Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
+			Eventually(func(g Gomega) {
+				wl, err := GetOwnedWorkload(k8sClient, plr, ctx)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(wl.Spec.Active).To(HaveValue(BeFalse()))
+			}).Should(Succeed())
+			Consistently(func(g Gomega) {
+				current := &tekv1.PipelineRun{}
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(plr), current)).To(Succeed())
+				g.Expect(current.Spec.Status).To(Equal(tekv1.PipelineRunSpecStatus(tekv1.PipelineRunSpecStatusPending)))
+			}, 20*time.Second, 5*time.Second).Should(Succeed())
+		})
+
+		It("Starts the PipelineRuns once the drain is lifted", func(ctx context.Context) {
+			Expect(setDrain(ctx, k8sClient, false)).To(Succeed())
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(plr),
+				func(plr *tekv1.PipelineRun) error {
+					if !plr.IsSuccessful() {
+						return errors.New("PipelineRun didn't succeed")
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("PipelineRun admitted after a configuration update", Ordered, func() {
+		// The configuration is reloaded by the kubelet, then the webhook
+		const reloadTimeout = 3 * time.Minute
+		const reloadedPriority = "e2e-reloaded"
+		var (
+			previousConfig string
+			unbind         func()
+			generation     float64
+			failures       float64
+		)
+		generationMetric := "tekton_kueue_webhook_config_generation"
+		failuresMetric := `tekton_kueue_webhook_config_reloads_total{result="failure"}`
+		priority := func(ctx context.Context) string {
+			plr := plrTemplate.DeepCopy()
+			Expect(k8sClient.Create(ctx, plr)).To(Succeed())
+			DeferCleanup(func(ctx context.Context) {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, plr))).To(Succeed())
+			})
+			return plr.Labels["kueue.x-k8s.io/priority-class"]
+		}
+
+		BeforeAll(func() {
+			var err error
+			unbind, err = bindWebhookMetricsReader()
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func(g Gomega) {
+				generation = webhookMetric(g, generationMetric)
+				g.Expect(generation).To(BeNumerically(">=", 1))
+				failures = webhookMetric(g, failuresMetric)
+			}, reloadTimeout, 5*time.Second).Should(Succeed())
+		})
+
+		AfterAll(func(ctx context.Context) {
+			if previousConfig != "" {
+				Expect(restoreConfig(ctx, k8sClient, previousConfig)).To(Succeed())
+			}
+			if unbind != nil {
+				unbind()
+			}
+		})
+
+		It("Reloads the CEL expressions", func(ctx context.Context) {
+			var err error
+			previousConfig, err = setCELExpressions(ctx, k8sClient, fmt.Sprintf("priority(%q)", reloadedPriority))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func(g Gomega) {
+				reloaded := webhookMetric(g, generationMetric)
+				g.Expect(reloaded).To(BeNumerically(">", generation))
+				generation = reloaded
+			}, reloadTimeout, 5*time.Second).Should(Succeed())
+
+			Expect(priority(ctx)).To(Equal(reloadedPriority))
+		})
+
+		It("Keeps the last-known-good CEL expressions when the update is invalid", func(ctx context.Context) {
+			_, err := setCELExpressions(ctx, k8sClient, "priority(")
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func(g Gomega) {
+				g.Expect(webhookMetric(g, failuresMetric)).To(BeNumerically(">", failures))
+			}, reloadTimeout, 5*time.Second).Should(Succeed())
+
+			// The webhook still serves the expressions of the previous update
+			Eventually(func(g Gomega) {
+				g.Expect(webhookMetric(g, generationMetric)).To(Equal(generation))
+			}).Should(Succeed())
+			Expect(priority(ctx)).To(Equal(reloadedPriority))
+		})
+	})
+
+	Context("Lower priority PipelineRun is preempted by a higher priority one", Ordered, func() {
+		const preemptionQueue = "preemption-pipelines-queue"
+		var lowPlr, highPlr *tekv1.PipelineRun
+
+		It("Deploys a ClusterQueue with preemption enabled", func() {
+			cmd := exec.Command(
+				"kubectl",
+				"apply",
+				"--server-side",
+				"-n",
+				nsName,
+				"-f",
+				"config/samples/kueue/kueue-preemption-resources.yaml",
+			)
+			_, err := utils.Run(cmd)
+			Expect(err).To(Succeed(), "Failed to apply kueue preemption resources")
+		})
+
+		It("Starts a low priority PipelineRun", func(ctx context.Context) {
+			lowPlr = plrTemplate.DeepCopy()
+			lowPlr.Labels = map[string]string{
+				webhookv1.QueueLabel:            preemptionQueue,
+				"kueue.x-k8s.io/priority-class": "low-priority",
+			}
+			// The second task is never scheduled once the PipelineRun is
+			// stopped, so the stopped PipelineRun ends up cancelled.
+			lowPlr.Spec.PipelineSpec = &tekv1.PipelineSpec{
+				Tasks: []tekv1.PipelineTask{
+					sleepTask("sleep", 60),
+					{
+						Name:     "after-sleep",
+						RunAfter: []string{"sleep"},
+						TaskSpec: plrTemplate.Spec.PipelineSpec.Tasks[0].TaskSpec,
+					},
+				},
+			}
+			Expect(utils.CreateAndWait(ctx, k8sClient, lowPlr)).To(Succeed())
+
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(lowPlr),
+				func(plr *tekv1.PipelineRun) error {
+					if plr.Spec.Status != "" {
+						return fmt.Errorf("PipelineRun wasn't admitted, status is %s", plr.Spec.Status)
+					}
+					if !plr.HasStarted() {
+						return errors.New("PipelineRun didn't start")
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Starts a high priority PipelineRun", func(ctx context.Context) {
+			highPlr = plrTemplate.DeepCopy()
+			highPlr.Labels = map[string]string{
+				webhookv1.QueueLabel:            preemptionQueue,
+				"kueue.x-k8s.io/priority-class": "high-priority",
+			}
+			Expect(utils.CreateAndWait(ctx, k8sClient, highPlr)).To(Succeed())
+		})
+
+		It("The low priority PipelineRun is stopped and cancelled", func(ctx context.Context) {
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(lowPlr),
+				func(plr *tekv1.PipelineRun) error {
+					if plr.Spec.Status != tekv1.PipelineRunSpecStatusStoppedRunFinally {
+						return fmt.Errorf("PipelineRun wasn't stopped, status is %q", plr.Spec.Status)
+					}
+					if !plr.IsDone() {
+						return errors.New("PipelineRun isn't done")
+					}
+					condition := plr.Status.GetCondition(kapi.ConditionSucceeded)
+					if condition.Reason != tekv1.PipelineRunReasonCancelled.String() {
+						return fmt.Errorf("PipelineRun wasn't cancelled, reason is %s", condition.Reason)
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			wl, err := GetOwnedWorkload(k8sClient, lowPlr, ctx)
+			Expect(err).NotTo(HaveOccurred())
+			cond := apimeta.FindStatusCondition(wl.Status.Conditions, kueue.WorkloadPreempted)
+			Expect(cond).NotTo(BeNil(), "Workload %s wasn't preempted", wl.Name)
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+		})
+
+		It("The high priority PipelineRun completes successfully", func(ctx context.Context) {
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(highPlr),
+				func(plr *tekv1.PipelineRun) error {
+					if !plr.IsSuccessful() {
+						return errors.New("PipelineRun didn't succeed")
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("PipelineRun with extra pod sets", Ordered, func() {
+		const podSetsQueue = "podsets-pipelines-queue"
+
+		It("Deploys a ClusterQueue with two flavors", func() {
+			cmd := exec.Command(
+				"kubectl",
+				"apply",
+				"--server-side",
+				"-n",
+				nsName,
+				"-f",
+				"config/samples/kueue/kueue-podsets-resources.yaml",
+			)
+			_, err := utils.Run(cmd)
+			Expect(err).To(Succeed(), "Failed to apply kueue pod sets resources")
+		})
+
+		It("Assigns each pod set its flavor", func(ctx context.Context) {
+			plr := plrTemplate.DeepCopy()
+			plr.Labels = map[string]string{webhookv1.QueueLabel: podSetsQueue}
+			// The annotation the podset CEL function sets
+			plr.Annotations = map[string]string{
+				common.PodSetsAnnotation: `[{"name":"arm-builders","count":3,` +
+					`"requests":{"kueue.konflux-ci.dev/linux-arm64":"1"}}]`,
+			}
+			Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
+
+			Eventually(func(g Gomega) {
+				wl, err := GetOwnedWorkload(k8sClient, plr, ctx)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(wl.Spec.PodSets).To(HaveLen(2))
+				g.Expect(wl.Spec.PodSets[1].Count).To(Equal(int32(3)))
+				g.Expect(wl.Status.Admission).NotTo(BeNil())
+				flavors := map[kueue.PodSetReference]kueue.ResourceFlavorReference{}
+				for _, assignment := range wl.Status.Admission.PodSetAssignments {
+					for _, flavor := range assignment.Flavors {
+						flavors[assignment.Name] = flavor
+					}
+				}
+				g.Expect(flavors).To(Equal(map[kueue.PodSetReference]kueue.ResourceFlavorReference{
+					common.DefaultPodSetName: "amd64-flavor",
+					"arm-builders":           "arm64-flavor",
+				}))
+			}).Should(Succeed())
+
+			_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(plr),
+				func(plr *tekv1.PipelineRun) error {
+					if !plr.IsSuccessful() {
+						return errors.New("PipelineRun didn't succeed")
+					}
+					return nil
+				},
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("mutate subcommand reading a PipelineRun from the cluster", func() {
+		It("Prints the mutated PipelineRun without the server-populated fields", func(ctx context.Context) {
+			plr := plrTemplate.DeepCopy()
+			Expect(utils.CreateAndWait(ctx, k8sClient, plr)).To(Succeed())
+
+			projectDir, err := utils.GetProjectDir()
+			Expect(err).NotTo(HaveOccurred())
+			cmd := exec.Command("go", "run", "./cmd", "mutate",
+				"--config-dir", "config/webhook",
+				"--from-cluster", fmt.Sprintf("%s/%s", plr.Namespace, plr.Name))
+			cmd.Dir = projectDir
+			cmd.Stderr = GinkgoWriter
+			// Only the standard output holds the PipelineRun, the logs are
+			// written to the standard error
+			output, err := cmd.Output()
+			Expect(err).NotTo(HaveOccurred(), "Failed to mutate the PipelineRun of the cluster")
+
+			mutated := &tekv1.PipelineRun{}
+			Expect(yaml.Unmarshal(output, mutated)).To(Succeed())
+			Expect(mutated.Name).To(Equal(plr.Name))
+			Expect(mutated.UID).To(BeEmpty())
+			Expect(mutated.ResourceVersion).To(BeEmpty())
+			Expect(mutated.Status.StartTime).To(BeNil())
+			Expect(mutated.Labels).To(HaveKey(kueueconstants.WorkloadPriorityClassLabel))
+		})
+	})
 })
 
+// sleepTask returns a PipelineTask which sleeps for the given number of seconds.
+func sleepTask(name string, seconds int) tekv1.PipelineTask {
+	return tekv1.PipelineTask{
+		Name: name,
+		TaskSpec: &tekv1.EmbeddedTask{
+			TaskSpec: tekv1.TaskSpec{
+				Steps: []tekv1.Step{
+					{
+						Name:    name,
+						Image:   "registry.access.redhat.com/ubi9/ubi-micro:latest",
+						Command: []string{"sleep", fmt.Sprintf("%d", seconds)},
+					},
+				},
+			},
+		},
+	}
+}
+
 func EnsureMatchingWorkloadExistWithStatusCondition(
 	statusCondition string,
 	expectedStatus metav1.ConditionStatus,
@@ -636,22 +1009,17 @@ func EnsurePipelineRunSpecStatusIs(
 	k8sClient client.Client,
 	ctx context.Context,
 ) {
-	Eventually(
-		func() error {
-			key := plr.GetNamespacedName()
-			err := k8sClient.Get(ctx, key, plr)
-			if err != nil {
-				return err
-			}
-			if plr.Spec.Status != tekv1.PipelineRunSpecStatusPending {
-				return fmt.Errorf("PipelineRun status is %s and not Pending", plr.Spec.Status)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	_, err := utils.WaitForCondition(ctx, k8sClient, client.ObjectKeyFromObject(plr),
+		func(plr *tekv1.PipelineRun) error {
+			if string(plr.Spec.Status) != status {
+				return fmt.Errorf("PipelineRun status is %q and not %q", plr.Spec.Status, status)
 			}
-
 			return nil
 		},
-		30*time.Second,
-		3*time.Second,
-	).Should(Succeed())
+	)
+	Expect(err).NotTo(HaveOccurred())
 }
 
 func GetOwnedWorkload(k8sClient client.Client, plr *tekv1.PipelineRun, ctx context.Context) (*kueue.Workload, error) {
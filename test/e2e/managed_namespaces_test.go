@@ -0,0 +1,52 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/konflux-ci/tekton-queue/test/utils"
+)
+
+// unmanagedNamespace is the namespace excluded from the managed namespaces,
+// whose PipelineRuns run without being queued.
+const unmanagedNamespace = "unmanaged-ns"
+
+// configureManagedNamespaces excludes unmanagedNamespace from the managed
+// namespaces in the configuration deployed by `make deploy`. The returned
+// function restores the original configuration.
+func configureManagedNamespaces() (func(), error) {
+	dir, err := utils.GetProjectDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "config", "webhook", "config.yaml")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content := string(original) + "managedNamespaces:\n" +
+		"  matchExpressions:\n" +
+		"    - key: kubernetes.io/metadata.name\n" +
+		"      operator: NotIn\n" +
+		"      values: [" + unmanagedNamespace + "]\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.WriteFile(path, original, 0o644) }, nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/konflux-ci/tekton-queue/test/utils"
+)
+
+// configureQueueNameValidation enables the validation of the queue names in
+// the configuration deployed by `make deploy`. The returned function
+// restores the original configuration.
+func configureQueueNameValidation() (func(), error) {
+	dir, err := utils.GetProjectDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "config", "webhook", "config.yaml")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content := string(original) + "webhook:\n  validateQueueName: true\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.WriteFile(path, original, 0o644) }, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/konflux-ci/tekton-queue/internal/cel"
+	"github.com/konflux-ci/tekton-queue/internal/config"
+	"github.com/konflux-ci/tekton-queue/test/utils"
+)
+
+// newResourceAnnotationPrefix is the prefix the resource annotations are
+// migrated to when RESOURCE_PREFIX_MIGRATION_MODE is set.
+const newResourceAnnotationPrefix = "queue.konflux.dev/requests-"
+
+// configureResourcePrefixMigration adds the resource annotation prefix
+// migration to the configuration deployed by `make deploy`, when a mode is
+// set. The returned function restores the original configuration.
+func configureResourcePrefixMigration() (func(), error) {
+	if resourcePrefixMigrationMode == "" {
+		return func() {}, nil
+	}
+	migration := config.ResourceAnnotationPrefixMigration{
+		From: cel.ResourceAnnotationPrefix,
+		To:   newResourceAnnotationPrefix,
+		Mode: config.ResourceAnnotationPrefixMode(resourcePrefixMigrationMode),
+	}
+	if err := migration.Validate(); err != nil {
+		return nil, err
+	}
+
+	dir, err := utils.GetProjectDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "config", "webhook", "config.yaml")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("%sresourceAnnotationPrefixMigration:\n  from: %s\n  to: %s\n  mode: %s\n",
+		original, migration.From, migration.To, migration.Mode)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return nil, err
+	}
+	return func() { _ = os.WriteFile(path, original, 0o644) }, nil
+}
+
+// resourceRequestAnnotation returns the annotation requesting the resource
+// which is honored in the configured migration mode. It keeps using the old
+// prefix until the migration completes, so the tests cover the PipelineRuns
+// created before the migration.
+func resourceRequestAnnotation(resource string) string {
+	if config.ResourceAnnotationPrefixMode(resourcePrefixMigrationMode) == config.ResourceAnnotationPrefixNewOnly {
+		return newResourceAnnotationPrefix + resource
+	}
+	return cel.ResourceAnnotationPrefix + resource
+}
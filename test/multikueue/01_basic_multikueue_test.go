@@ -88,8 +88,8 @@ var _ = Describe("MultiKueue Basic Scheduling", Ordered, Label("multikueue", "sm
 
 			yamlString := string(data)
 			plr = utils.MustParseV1PipelineRun(t, yamlString)
-			plr, err = HubTektonClientset.TektonV1().PipelineRuns(nsName).Create(ctx, plr, meta.CreateOptions{})
-			Expect(err).NotTo(HaveOccurred())
+			plr.Namespace = nsName
+			Expect(utils.CreateAndWait(ctx, HubClient, plr)).To(Succeed())
 		})
 		By(" Check Labels on pipelinerun "+plr.Name, func() {
 			createdPLR, err := HubTektonClientset.TektonV1().PipelineRuns(nsName).Get(ctx, plr.Name, meta.GetOptions{})
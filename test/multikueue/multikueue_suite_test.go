@@ -24,10 +24,15 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	tekton "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	kueue "sigs.k8s.io/kueue/client-go/clientset/versioned"
 )
 
@@ -45,6 +50,7 @@ var (
 	HubClientset       *kubernetes.Clientset
 	HubTektonClientset *tekton.Clientset
 	HubKueueClientset  *kueue.Clientset
+	HubClient          client.Client
 
 	SpokeClientset       *kubernetes.Clientset
 	SpokeKueueClientset  *kueue.Clientset
@@ -86,6 +92,12 @@ var _ = BeforeSuite(func() {
 		HubKueueClientset, err = kueue.NewForConfig(restConfig)
 		Expect(err).NotTo(HaveOccurred())
 
+		scheme := runtime.NewScheme()
+		utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+		utilruntime.Must(tekv1.AddToScheme(scheme))
+		HubClient, err = client.New(restConfig, client.Options{Scheme: scheme})
+		Expect(err).NotTo(HaveOccurred())
+
 		spokeConfig := clientcmd.
 			NewNonInteractiveClientConfig(*rawConfig, SpokeKubeContext, &clientcmd.ConfigOverrides{}, nil)
 		restConfig, err = spokeConfig.ClientConfig()
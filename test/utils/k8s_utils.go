@@ -0,0 +1,126 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// CreateTimeout is how long CreateAndWait retries to create an object,
+	// e.g. while the admission webhook isn't ready yet.
+	CreateTimeout = 90 * time.Second
+	// WaitTimeout is how long CreateAndWait and WaitForCondition wait for an
+	// object. A shorter deadline on the context takes precedence.
+	WaitTimeout = 2 * time.Minute
+	// PollInterval is the interval between two attempts.
+	PollInterval = 3 * time.Second
+)
+
+// CreateAndWait creates obj, retrying until CreateTimeout expires, and waits
+// until it can be read back using the client. obj is updated in place with the
+// object returned by the server, so the name assigned to objects created with
+// GenerateName is available to the caller once CreateAndWait returns.
+func CreateAndWait(ctx context.Context, c client.Client, obj client.Object) error {
+	var lastErr error
+	err := wait.PollUntilContextTimeout(ctx, PollInterval, CreateTimeout, true, func(ctx context.Context) (bool, error) {
+		lastErr = c.Create(ctx, obj)
+		if kerrors.IsAlreadyExists(lastErr) || kerrors.IsInvalid(lastErr) {
+			// Retrying won't help
+			return false, lastErr
+		}
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", describe(c, obj), lastErrOr(lastErr, err))
+	}
+
+	key := client.ObjectKeyFromObject(obj)
+	err = wait.PollUntilContextTimeout(ctx, PollInterval, WaitTimeout, true, func(ctx context.Context) (bool, error) {
+		lastErr = c.Get(ctx, key, obj)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s was created but can't be read back: %w", describe(c, obj), lastErrOr(lastErr, err))
+	}
+	return nil
+}
+
+// WaitForCondition polls the object with the given key until condFn returns
+// nil, and returns the last observed object. condFn should return an error
+// describing why the object doesn't satisfy the condition yet; on timeout,
+// the returned error includes the last such error and the last observed
+// object.
+func WaitForCondition[T any, PT interface {
+	*T
+	client.Object
+}](ctx context.Context, c client.Client, key client.ObjectKey, condFn func(PT) error) (PT, error) {
+	obj := PT(new(T))
+	if key.Name == "" {
+		// This happens when the key is taken from an object created with
+		// GenerateName before the server response was captured.
+		return obj, fmt.Errorf("can't wait for %s with an empty name in namespace %q", describe(c, obj), key.Namespace)
+	}
+
+	var lastErr error
+	observed := false
+	err := wait.PollUntilContextTimeout(ctx, PollInterval, WaitTimeout, true, func(ctx context.Context) (bool, error) {
+		current := PT(new(T))
+		if lastErr = c.Get(ctx, key, current); lastErr != nil {
+			return false, nil
+		}
+		obj, observed = current, true
+		lastErr = condFn(obj)
+		return lastErr == nil, nil
+	})
+	if err == nil {
+		return obj, nil
+	}
+
+	msg := fmt.Sprintf("timed out waiting for %s %s: %v", describe(c, obj), key, lastErrOr(lastErr, err))
+	if observed {
+		if data, yamlErr := yaml.Marshal(obj); yamlErr == nil {
+			msg += "\nlast observed object:\n" + string(data)
+		}
+	} else {
+		msg += "\nthe object was never observed"
+	}
+	return obj, errors.New(msg)
+}
+
+// describe returns the kind of the object, falling back to its Go type.
+func describe(c client.Client, obj client.Object) string {
+	if gvk, err := c.GroupVersionKindFor(obj); err == nil {
+		return gvk.Kind
+	}
+	return fmt.Sprintf("%T", obj)
+}
+
+func lastErrOr(lastErr, err error) error {
+	if lastErr != nil {
+		return lastErr
+	}
+	return err
+}
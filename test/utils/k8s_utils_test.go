@@ -0,0 +1,199 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	tekv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// useShortTimeouts shortens the package timeouts for the duration of the test.
+func useShortTimeouts(t *testing.T) {
+	createTimeout, waitTimeout, pollInterval := CreateTimeout, WaitTimeout, PollInterval
+	CreateTimeout, WaitTimeout, PollInterval = time.Second, time.Second, 10*time.Millisecond
+	t.Cleanup(func() {
+		CreateTimeout, WaitTimeout, PollInterval = createTimeout, waitTimeout, pollInterval
+	})
+}
+
+func newFakeClientBuilder() *fake.ClientBuilder {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(tekv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme)
+}
+
+func newPipelineRun() *tekv1.PipelineRun {
+	return &tekv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "pipeline-",
+			Namespace:    "test-ns",
+		},
+	}
+}
+
+func TestCreateAndWait(t *testing.T) {
+	useShortTimeouts(t)
+
+	t.Run("captures the generated name", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newFakeClientBuilder().Build()
+
+		plr := newPipelineRun()
+		g.Expect(CreateAndWait(context.Background(), c, plr)).To(Succeed())
+
+		g.Expect(plr.Name).To(HavePrefix("pipeline-"))
+		g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(plr), &tekv1.PipelineRun{})).To(Succeed())
+	})
+
+	t.Run("retries until the object is created", func(t *testing.T) {
+		g := NewWithT(t)
+		attempts := 0
+		c := newFakeClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				attempts++
+				if attempts < 3 {
+					return errors.New("webhook isn't ready")
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		plr := newPipelineRun()
+		g.Expect(CreateAndWait(context.Background(), c, plr)).To(Succeed())
+		g.Expect(attempts).To(Equal(3))
+		g.Expect(plr.Name).NotTo(BeEmpty())
+	})
+
+	t.Run("waits until the object can be read back", func(t *testing.T) {
+		g := NewWithT(t)
+		gets := 0
+		c := newFakeClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				gets++
+				if gets < 3 {
+					return kerrors.NewNotFound(schema.GroupResource{Group: "tekton.dev", Resource: "pipelineruns"}, key.Name)
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+
+		g.Expect(CreateAndWait(context.Background(), c, newPipelineRun())).To(Succeed())
+		g.Expect(gets).To(Equal(3))
+	})
+
+	t.Run("reports the last error", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newFakeClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(context.Context, client.WithWatch, client.Object, ...client.CreateOption) error {
+				return errors.New("webhook isn't ready")
+			},
+		}).Build()
+
+		err := CreateAndWait(context.Background(), c, newPipelineRun())
+		g.Expect(err).To(MatchError(ContainSubstring("failed to create PipelineRun: webhook isn't ready")))
+	})
+
+	t.Run("doesn't retry when the object already exists", func(t *testing.T) {
+		g := NewWithT(t)
+		existing := newPipelineRun()
+		existing.Name = "existing"
+		c := newFakeClientBuilder().WithObjects(existing.DeepCopy()).Build()
+
+		err := CreateAndWait(context.Background(), c, existing)
+		g.Expect(kerrors.IsAlreadyExists(errors.Unwrap(err))).To(BeTrue(), "unexpected error: %v", err)
+	})
+}
+
+func TestWaitForCondition(t *testing.T) {
+	useShortTimeouts(t)
+
+	t.Run("returns the object once the condition is met", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPipelineRun()
+		plr.Name = "test"
+		c := newFakeClientBuilder().WithObjects(plr).Build()
+
+		polls := 0
+		got, err := WaitForCondition(context.Background(), c, client.ObjectKeyFromObject(plr),
+			func(plr *tekv1.PipelineRun) error {
+				polls++
+				if polls < 3 {
+					return errors.New("not yet")
+				}
+				return nil
+			},
+		)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(got.Name).To(Equal("test"))
+		g.Expect(polls).To(Equal(3))
+	})
+
+	t.Run("rejects an empty name", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newFakeClientBuilder().Build()
+
+		_, err := WaitForCondition(context.Background(), c, types.NamespacedName{Namespace: "test-ns"},
+			func(*tekv1.PipelineRun) error { return nil },
+		)
+		g.Expect(err).To(MatchError(ContainSubstring(`can't wait for PipelineRun with an empty name in namespace "test-ns"`)))
+	})
+
+	t.Run("reports the last observed object on timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		plr := newPipelineRun()
+		plr.Name = "test"
+		plr.Spec.Status = tekv1.PipelineRunSpecStatusPending
+		c := newFakeClientBuilder().WithObjects(plr).Build()
+
+		_, err := WaitForCondition(context.Background(), c, client.ObjectKeyFromObject(plr),
+			func(plr *tekv1.PipelineRun) error {
+				return errors.New("PipelineRun is still pending")
+			},
+		)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timed out waiting for PipelineRun test-ns/test: PipelineRun is still pending"))
+		g.Expect(err.Error()).To(ContainSubstring("last observed object:"))
+		g.Expect(err.Error()).To(ContainSubstring("status: PipelineRunPending"))
+	})
+
+	t.Run("reports objects which were never observed", func(t *testing.T) {
+		g := NewWithT(t)
+		c := newFakeClientBuilder().Build()
+
+		_, err := WaitForCondition(context.Background(), c, types.NamespacedName{Namespace: "test-ns", Name: "missing"},
+			func(*tekv1.PipelineRun) error { return nil },
+		)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timed out waiting for PipelineRun test-ns/missing"))
+		g.Expect(err.Error()).To(ContainSubstring("not found"))
+		g.Expect(err.Error()).To(ContainSubstring("the object was never observed"))
+	})
+}